@@ -0,0 +1,185 @@
+package announce
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/tools"
+	"xiaozhi-server-go/src/router/admin"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultMaxChars = 500
+
+// DeviceSpeaker 在在线设备上播报文本
+type DeviceSpeaker interface {
+	// SpeakOnDevice 打断设备的当前播报，合成并播报文本
+	SpeakOnDevice(deviceID string, text string) error
+	DeviceStatus(deviceID string) (tools.Device, bool)
+	OnlineDevices() []tools.Device
+}
+
+// speakRequest 播报请求，text 与 announcement 二选一
+type speakRequest struct {
+	Text         string            `json:"text"`
+	Announcement string            `json:"announcement"` // 预设播报的名称，对应 speak_api.announcements
+	Vars         map[string]string `json:"vars"`         // 替换文本中的 {{名称}}
+	DeviceIDs    []string          `json:"device_ids"`   // 广播的目标设备，为空表示所有在线设备
+}
+
+// speakResult 单个设备的播报结果
+type speakResult struct {
+	DeviceID string `json:"device_id"`
+	Success  bool   `json:"success"`
+	Message  string `json:"message,omitempty"`
+}
+
+// DefaultSpeakService 主动播报服务，供门铃提醒、广播通知和自动化流程让设备播报文本
+type DefaultSpeakService struct {
+	config  *configs.Config
+	speaker DeviceSpeaker
+}
+
+// NewDefaultSpeakService 构造函数
+func NewDefaultSpeakService(config *configs.Config, speaker DeviceSpeaker) (*DefaultSpeakService, error) {
+	return &DefaultSpeakService{config: config, speaker: speaker}, nil
+}
+
+// Start 注册主动播报路由，未配置管理员令牌时不开放
+func (s *DefaultSpeakService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	if !admin.Enabled(s.config) {
+		logrus.Info("未配置web.admin_token，主动播报接口未开放")
+		return nil
+	}
+	group := admin.Group(apiGroup, s.config)
+	group.POST("/devices/:device_id/speak", s.handleSpeak)
+	group.POST("/devices/speak", s.handleBroadcast)
+	group.GET("/announcements", s.handleAnnouncements)
+
+	logrus.Info("主动播报HTTP服务路由注册完成")
+	return nil
+}
+
+// handleSpeak 让指定设备播报文本，打断设备当前的播报
+// 请求体：{"text":"快递到了"} 或 {"announcement":"doorbell","vars":{"location":"前门"}}
+func (s *DefaultSpeakService) handleSpeak(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	var req speakRequest
+	text, ok := s.bindRequest(c, &req)
+	if !ok {
+		return
+	}
+	if _, online := s.speaker.DeviceStatus(deviceID); !online {
+		s.respondError(c, http.StatusNotFound, "设备不在线")
+		return
+	}
+	if err := s.speaker.SpeakOnDevice(deviceID, text); err != nil {
+		s.respondError(c, http.StatusConflict, "播报失败: "+err.Error())
+		return
+	}
+	logrus.WithField("device_id", deviceID).Infof("推送播报: %s", text)
+	c.JSON(http.StatusOK, gin.H{"success": true, "device_id": deviceID, "text": text})
+}
+
+// handleBroadcast 让多个设备播报同一段文本，device_ids 为空时广播到所有在线设备
+// 请求体：{"announcement":"notice","vars":{"content":"今晚八点停电检修"},"device_ids":["aa:bb:cc:dd:ee:ff"]}
+func (s *DefaultSpeakService) handleBroadcast(c *gin.Context) {
+	var req speakRequest
+	text, ok := s.bindRequest(c, &req)
+	if !ok {
+		return
+	}
+	deviceIDs := req.DeviceIDs
+	if len(deviceIDs) == 0 {
+		for _, device := range s.speaker.OnlineDevices() {
+			deviceIDs = append(deviceIDs, device.ID)
+		}
+	}
+
+	results := make([]speakResult, 0, len(deviceIDs))
+	delivered := 0
+	for _, deviceID := range deviceIDs {
+		result := speakResult{DeviceID: deviceID}
+		if _, online := s.speaker.DeviceStatus(deviceID); !online {
+			result.Message = "设备不在线"
+		} else if err := s.speaker.SpeakOnDevice(deviceID, text); err != nil {
+			result.Message = err.Error()
+		} else {
+			result.Success = true
+			delivered++
+		}
+		results = append(results, result)
+	}
+	logrus.Infof("广播播报到 %d/%d 台设备: %s", delivered, len(deviceIDs), text)
+	c.JSON(http.StatusOK, gin.H{"success": true, "text": text, "delivered": delivered, "results": results})
+}
+
+// handleAnnouncements 列出预设播报
+func (s *DefaultSpeakService) handleAnnouncements(c *gin.Context) {
+	names := make([]string, 0, len(s.config.SpeakAPI.Announcements))
+	for name := range s.config.SpeakAPI.Announcements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	announcements := make([]gin.H, 0, len(names))
+	for _, name := range names {
+		announcements = append(announcements, gin.H{"name": name, "text": s.config.SpeakAPI.Announcements[name]})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "announcements": announcements})
+}
+
+// bindRequest 解析播报请求并生成要播报的文本，失败时已返回错误响应
+func (s *DefaultSpeakService) bindRequest(c *gin.Context, req *speakRequest) (string, bool) {
+	if err := c.ShouldBindJSON(req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "请求格式错误: "+err.Error())
+		return "", false
+	}
+	text, err := s.resolveText(req)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return text, true
+}
+
+// resolveText 取请求中的文本或预设播报，替换其中的变量
+func (s *DefaultSpeakService) resolveText(req *speakRequest) (string, error) {
+	text := req.Text
+	if req.Announcement != "" {
+		if text != "" {
+			return "", fmt.Errorf("text 与 announcement 只能指定一个")
+		}
+		announcement, ok := s.config.SpeakAPI.Announcements[req.Announcement]
+		if !ok {
+			return "", fmt.Errorf("未知的预设播报: %s", req.Announcement)
+		}
+		text = announcement
+	}
+	for name, value := range req.Vars {
+		text = strings.ReplaceAll(text, "{{"+name+"}}", value)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("缺少要播报的文本")
+	}
+	maxChars := s.config.SpeakAPI.MaxChars
+	if maxChars <= 0 {
+		maxChars = defaultMaxChars
+	}
+	if utf8.RuneCountInString(text) > maxChars {
+		return "", fmt.Errorf("文本过长，最多%d字", maxChars)
+	}
+	return text, nil
+}
+
+// respondError 返回错误响应
+func (s *DefaultSpeakService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{"success": false, "message": message})
+}
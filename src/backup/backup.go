@@ -0,0 +1,284 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+const (
+	// 备份格式版本，恢复时拒绝不认识的版本。
+	// 版本1按模型的JSON字段导出，会丢失 json:"-" 的列；版本2按列名导出
+	formatVersion       = 2
+	legacyFormatVersion = 1
+
+	archivePrefix = "xiaozhi-backup-"
+	archiveSuffix = ".tar.gz"
+
+	manifestName = "manifest.json"
+	configName   = "config.yaml"
+	tableDir     = "db/"
+
+	batchSize = 500
+)
+
+// Manifest 备份清单，位于归档的第一个文件
+type Manifest struct {
+	Version   int              `json:"version"`
+	CreatedAt time.Time        `json:"created_at"`
+	Dialect   string           `json:"dialect"` // 备份来源的数据库类型，恢复时可以是其他类型
+	Tables    map[string]int64 `json:"tables"`  // 表名 -> 行数
+	Config    bool             `json:"config"`  // 是否包含生效中的配置
+}
+
+// Result 一次备份的结果
+type Result struct {
+	Path     string    // 本地归档路径
+	Remote   string    // 上传后的 s3:// 地址，未上传时为空
+	Manifest *Manifest // 归档清单
+}
+
+// Create 把数据库各表和生效中的配置写入 dir 下的新归档
+// 各表以JSON行导出，每行是列名到值的映射，不依赖 mysqldump 等外部工具，也可以恢复到不同类型的数据库
+func Create(ctx context.Context, db *gorm.DB, config *configs.Config, dir string) (*Result, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %v", err)
+	}
+
+	// 同一秒内多次备份（如恢复前的自动备份）时加序号，不覆盖已有归档
+	stamp := time.Now().Format("20060102-150405")
+	path := filepath.Join(dir, archivePrefix+stamp+archiveSuffix)
+	for i := 1; fileExists(path); i++ {
+		path = filepath.Join(dir, fmt.Sprintf("%s%s-%d%s", archivePrefix, stamp, i, archiveSuffix))
+	}
+	// 先写临时文件，完成后再改名，避免留下不完整的归档被当作最新备份
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("创建备份文件失败: %v", err)
+	}
+	defer os.Remove(tmpPath)
+
+	manifest, err := writeArchive(ctx, file, db, config)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("写入备份文件失败: %v", closeErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return nil, fmt.Errorf("保存备份文件失败: %v", err)
+	}
+	return &Result{Path: path, Manifest: manifest}, nil
+}
+
+// writeArchive 依次写入清单、配置和各表数据
+func writeArchive(ctx context.Context, w io.Writer, db *gorm.DB, config *configs.Config) (*Manifest, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest := &Manifest{
+		Version:   formatVersion,
+		CreatedAt: time.Now(),
+		Dialect:   db.Dialector.Name(),
+		Tables:    make(map[string]int64),
+		Config:    config != nil,
+	}
+
+	// tar需要预先知道文件大小，表数据先导出到临时文件
+	type tableDump struct {
+		name string
+		path string
+	}
+	var dumps []tableDump
+	defer func() {
+		for _, dump := range dumps {
+			os.Remove(dump.path)
+		}
+	}()
+	for _, model := range database.Models() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		table, err := tableName(db, model)
+		if err != nil {
+			return nil, err
+		}
+		path, rows, err := dumpTable(db, model)
+		if err != nil {
+			return nil, fmt.Errorf("导出表%s失败: %v", table, err)
+		}
+		dumps = append(dumps, tableDump{name: table, path: path})
+		manifest.Tables[table] = rows
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化备份清单失败: %v", err)
+	}
+	if err := writeEntry(tw, manifestName, manifestData); err != nil {
+		return nil, err
+	}
+	if config != nil {
+		configData, err := yaml.Marshal(config)
+		if err != nil {
+			return nil, fmt.Errorf("序列化配置失败: %v", err)
+		}
+		if err := writeEntry(tw, configName, configData); err != nil {
+			return nil, err
+		}
+	}
+	for _, dump := range dumps {
+		if err := writeFileEntry(tw, tableDir+dump.name+".jsonl", dump.path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("写入备份文件失败: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("写入备份文件失败: %v", err)
+	}
+	return manifest, nil
+}
+
+// dumpTable 分批读取表中所有记录（含软删除的记录），每条记录按列名取值，以一行JSON写入临时文件。
+// 不直接序列化模型，API中隐藏的字段（json:"-"，如声纹向量、录音路径）同样需要备份
+func dumpTable(db *gorm.DB, model interface{}) (string, int64, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", 0, fmt.Errorf("解析模型失败: %v", err)
+	}
+
+	file, err := os.CreateTemp("", "xiaozhi-backup-table-*.jsonl")
+	if err != nil {
+		return "", 0, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	var rows int64
+	batch := reflect.New(reflect.SliceOf(reflect.TypeOf(model)))
+	result := db.Unscoped().Model(model).FindInBatches(batch.Interface(), batchSize, func(tx *gorm.DB, _ int) error {
+		records := batch.Elem()
+		for i := 0; i < records.Len(); i++ {
+			record := reflect.Indirect(records.Index(i))
+			columns := make(map[string]interface{}, len(stmt.Schema.DBNames))
+			for _, name := range stmt.Schema.DBNames {
+				value, _ := stmt.Schema.FieldsByDBName[name].ValueOf(tx.Statement.Context, record)
+				columns[name] = value
+			}
+			if err := encoder.Encode(columns); err != nil {
+				return err
+			}
+		}
+		rows += int64(records.Len())
+		return nil
+	})
+	if result.Error == nil {
+		result.Error = writer.Flush()
+	}
+	if result.Error != nil {
+		os.Remove(file.Name())
+		return "", 0, result.Error
+	}
+	return file.Name(), rows, nil
+}
+
+// tableName 解析模型对应的表名
+func tableName(db *gorm.DB, model interface{}) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", fmt.Errorf("解析模型失败: %v", err)
+	}
+	return stmt.Schema.Table, nil
+}
+
+// writeEntry 写入内存中的归档文件
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("写入%s失败: %v", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("写入%s失败: %v", name, err)
+	}
+	return nil
+}
+
+// writeFileEntry 把本地文件写入归档
+func writeFileEntry(tw *tar.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取临时文件失败: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("读取临时文件失败: %v", err)
+	}
+	header := &tar.Header{Name: name, Mode: 0600, Size: info.Size(), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("写入%s失败: %v", name, err)
+	}
+	if _, err := io.Copy(tw, file); err != nil {
+		return fmt.Errorf("写入%s失败: %v", name, err)
+	}
+	return nil
+}
+
+// fileExists 文件是否已存在
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// prune 只保留最新的 keep 份本地备份
+func prune(dir string, keep int) {
+	if keep <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logrus.WithError(err).Warn("读取备份目录失败")
+		return
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, archivePrefix) && strings.HasSuffix(name, archiveSuffix) {
+			names = append(names, name)
+		}
+	}
+	if len(names) <= keep {
+		return
+	}
+	// 文件名中的时间戳保证按名称排序即按时间排序
+	sort.Strings(names)
+	for _, name := range names[:len(names)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			logrus.WithError(err).WithField("file", name).Warn("删除过期备份失败")
+			continue
+		}
+		logrus.WithField("file", name).Info("已删除过期备份")
+	}
+}
@@ -0,0 +1,142 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/datatypes"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+func openTestDB(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name)), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(database.Models()...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+var fixedTime = time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+
+// fillValue 按字段类型生成非零值，不认识的类型返回false
+func fillValue(field *schema.Field, seed int) (reflect.Value, bool) {
+	fieldType := field.FieldType
+	pointer := fieldType.Kind() == reflect.Ptr
+	if pointer {
+		fieldType = fieldType.Elem()
+	}
+	value := reflect.New(fieldType).Elem()
+	switch {
+	case fieldType == reflect.TypeOf(datatypes.JSON{}):
+		value.Set(reflect.ValueOf(datatypes.JSON(fmt.Sprintf("[%d,0.5]", seed))))
+	case fieldType == reflect.TypeOf(time.Time{}):
+		value.Set(reflect.ValueOf(fixedTime.Add(time.Duration(seed) * time.Second)))
+	case fieldType.Kind() == reflect.String:
+		value.SetString(fmt.Sprintf("%s-%d", field.DBName, seed))
+	case fieldType.Kind() == reflect.Bool:
+		value.SetBool(true)
+	case fieldType.Kind() >= reflect.Int && fieldType.Kind() <= reflect.Int64:
+		value.SetInt(int64(seed))
+	case fieldType.Kind() >= reflect.Uint && fieldType.Kind() <= reflect.Uint64:
+		value.SetUint(uint64(seed))
+	default:
+		return reflect.Value{}, false
+	}
+	if pointer {
+		ptr := reflect.New(fieldType)
+		ptr.Elem().Set(value)
+		return ptr, true
+	}
+	return value, true
+}
+
+// columnValues 读取表中唯一一条记录的各列取值
+func columnValues(t *testing.T, db *gorm.DB, model interface{}) map[string]interface{} {
+	t.Helper()
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		t.Fatal(err)
+	}
+	record := reflect.New(stmt.Schema.ModelType)
+	if err := db.Unscoped().Model(model).First(record.Interface()).Error; err != nil {
+		t.Fatalf("%s: %v", stmt.Schema.Table, err)
+	}
+	values := make(map[string]interface{})
+	for _, name := range stmt.Schema.DBNames {
+		value, _ := stmt.Schema.FieldsByDBName[name].ValueOf(context.Background(), record.Elem())
+		if tm, ok := value.(time.Time); ok {
+			value = tm.UTC()
+		}
+		if tm, ok := value.(*time.Time); ok && tm != nil {
+			value = tm.UTC()
+		}
+		values[name] = value
+	}
+	return values
+}
+
+func TestBackupRestoreRoundTripsEveryColumn(t *testing.T) {
+	source := openTestDB(t, "source.db")
+	for i, model := range database.Models() {
+		stmt := &gorm.Statement{DB: source}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		record := reflect.New(stmt.Schema.ModelType)
+		for _, name := range stmt.Schema.DBNames {
+			field := stmt.Schema.FieldsByDBName[name]
+			if value, ok := fillValue(field, i+1); ok {
+				if err := field.Set(context.Background(), record.Elem(), value.Interface()); err != nil {
+					t.Fatalf("%s.%s: %v", stmt.Schema.Table, name, err)
+				}
+			}
+		}
+		if err := source.Session(&gorm.Session{SkipHooks: true}).Omit(clause.Associations).Create(record.Interface()).Error; err != nil {
+			t.Fatalf("insert %s: %v", stmt.Schema.Table, err)
+		}
+	}
+
+	result, err := Create(context.Background(), source, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	target := openTestDB(t, "target.db")
+	if err := Restore(target, result.Path); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	for _, model := range database.Models() {
+		want := columnValues(t, source, model)
+		got := columnValues(t, target, model)
+		for name, value := range want {
+			if !reflect.DeepEqual(got[name], value) {
+				t.Errorf("%T column %s = %#v, want %#v", model, name, got[name], value)
+			}
+		}
+	}
+
+	// API中隐藏的字段同样需要恢复
+	var voiceprint models.Voiceprint
+	var recording models.Recording
+	var firmware models.Firmware
+	target.First(&voiceprint)
+	target.First(&recording)
+	target.First(&firmware)
+	if len(voiceprint.Embedding) == 0 || recording.Path == "" || firmware.Remote == "" {
+		t.Errorf("hidden columns lost: embedding=%q path=%q remote=%q", voiceprint.Embedding, recording.Path, firmware.Remote)
+	}
+}
@@ -0,0 +1,110 @@
+package backup
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+
+	"gorm.io/gorm"
+)
+
+// RunRestoreCommand 执行 restore 子命令，从备份恢复数据库和配置
+//
+//	xiaozhi-server restore -file backups/xiaozhi-backup-20250101-030000.tar.gz -yes
+//	xiaozhi-server restore -file s3://bucket/prefix/xiaozhi-backup-20250101-030000.tar.gz -config-out .config.yaml -yes
+//
+// 不带 -yes 时只显示备份内容；恢复数据库前会先把当前数据备份到 backup.dir，恢复期间请停止服务
+func RunRestoreCommand(config *configs.Config, db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	file := flags.String("file", "", "备份文件路径，或 s3://bucket/key（使用 backup.s3 的凭据下载）")
+	configOut := flags.String("config-out", "", "把备份中的配置写到该路径，为空时不恢复配置")
+	skipDB := flags.Bool("skip-db", false, "不恢复数据库，只恢复配置")
+	yes := flags.Bool("yes", false, "确认覆盖当前数据库和配置文件")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		flags.Usage()
+		return fmt.Errorf("缺少 -file 参数")
+	}
+
+	path := *file
+	if strings.HasPrefix(path, "s3://") {
+		client, err := NewS3Client(config.Backup.S3)
+		if err != nil {
+			return err
+		}
+		if client == nil {
+			return fmt.Errorf("从S3恢复需要配置 backup.s3")
+		}
+		tmp, err := os.CreateTemp("", "xiaozhi-restore-*"+archiveSuffix)
+		if err != nil {
+			return fmt.Errorf("创建临时文件失败: %v", err)
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		fmt.Printf("正在下载 %s\n", path)
+		if err := client.Download(path, tmp.Name()); err != nil {
+			return err
+		}
+		path = tmp.Name()
+	}
+
+	manifest, err := ReadManifest(path)
+	if err != nil {
+		return err
+	}
+	printManifest(*file, manifest)
+	if !*yes {
+		fmt.Println("确认无误后加上 -yes 重新执行以恢复")
+		return nil
+	}
+
+	if !*skipDB {
+		safety, err := Create(context.Background(), db, config, backupDir(config))
+		if err != nil {
+			return fmt.Errorf("恢复前备份当前数据失败: %v", err)
+		}
+		fmt.Printf("当前数据已备份到 %s\n", safety.Path)
+		if err := Restore(db, path); err != nil {
+			return err
+		}
+		fmt.Println("数据库已恢复")
+	}
+	if *configOut != "" {
+		if err := ExtractConfig(path, *configOut); err != nil {
+			return err
+		}
+		fmt.Printf("配置已写入 %s，重启服务后生效\n", *configOut)
+	}
+	return nil
+}
+
+// printManifest 显示备份内容
+func printManifest(source string, manifest *Manifest) {
+	fmt.Printf("备份文件: %s\n", source)
+	fmt.Printf("创建时间: %s\n", manifest.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("数据库类型: %s\n", manifest.Dialect)
+	fmt.Printf("包含配置: %v\n", manifest.Config)
+	tables := make([]string, 0, len(manifest.Tables))
+	for table := range manifest.Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Printf("  %-24s %d 行\n", table, manifest.Tables[table])
+	}
+}
+
+// backupDir 本地备份目录
+func backupDir(config *configs.Config) string {
+	if config.Backup.Dir != "" {
+		return config.Backup.Dir
+	}
+	return defaultDir
+}
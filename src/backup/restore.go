@@ -0,0 +1,261 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"xiaozhi-server-go/src/configs/database"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxLineSize 单条记录JSON的最大长度
+const maxLineSize = 16 * 1024 * 1024
+
+// ReadManifest 读取归档中的备份清单
+func ReadManifest(path string) (*Manifest, error) {
+	var manifest *Manifest
+	err := walkArchive(path, func(name string, r io.Reader) (bool, error) {
+		if name != manifestName {
+			return true, nil
+		}
+		manifest = &Manifest{}
+		if err := json.NewDecoder(r).Decode(manifest); err != nil {
+			return false, fmt.Errorf("解析备份清单失败: %v", err)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("备份文件中缺少%s", manifestName)
+	}
+	if manifest.Version != formatVersion && manifest.Version != legacyFormatVersion {
+		return nil, fmt.Errorf("不支持的备份格式版本: %d", manifest.Version)
+	}
+	return manifest, nil
+}
+
+// ExtractConfig 把归档中的配置写到 dest，备份不含配置时返回错误
+func ExtractConfig(path, dest string) error {
+	found := false
+	err := walkArchive(path, func(name string, r io.Reader) (bool, error) {
+		if name != configName {
+			return true, nil
+		}
+		found = true
+		file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return false, fmt.Errorf("创建配置文件失败: %v", err)
+		}
+		defer file.Close()
+		if _, err := io.Copy(file, r); err != nil {
+			return false, fmt.Errorf("写入配置文件失败: %v", err)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("备份文件中不包含配置")
+	}
+	return nil
+}
+
+// Restore 用归档中的数据替换数据库中各表的全部记录，在一个事务中完成，失败时不修改数据库
+// 只恢复当前版本仍然存在的表，归档中多余的表会被忽略
+func Restore(db *gorm.DB, path string) error {
+	manifest, err := ReadManifest(path)
+	if err != nil {
+		return err
+	}
+
+	models := make(map[string]interface{})
+	var order []string
+	for _, model := range database.Models() {
+		table, err := tableName(db, model)
+		if err != nil {
+			return err
+		}
+		models[table] = model
+		order = append(order, table)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// 先清空所有表，避免归档中缺少某张表时残留旧数据
+		for i := len(order) - 1; i >= 0; i-- {
+			if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(models[order[i]]).Error; err != nil {
+				return fmt.Errorf("清空表%s失败: %v", order[i], err)
+			}
+		}
+
+		return walkArchive(path, func(name string, r io.Reader) (bool, error) {
+			if !strings.HasPrefix(name, tableDir) {
+				return true, nil
+			}
+			table := strings.TrimSuffix(strings.TrimPrefix(name, tableDir), ".jsonl")
+			model, ok := models[table]
+			if !ok {
+				logrus.WithField("table", table).Warn("当前版本没有该表，跳过恢复")
+				return true, nil
+			}
+			rows, err := restoreTable(tx, model, r, manifest.Version)
+			if err != nil {
+				return false, fmt.Errorf("恢复表%s失败: %v", table, err)
+			}
+			if err := resetSequence(tx, model, table); err != nil {
+				return false, fmt.Errorf("重置表%s的自增序列失败: %v", table, err)
+			}
+			logrus.WithFields(logrus.Fields{
+				"table": table,
+				"rows":  rows,
+			}).Info("表数据已恢复")
+			return true, nil
+		})
+	})
+}
+
+// restoreTable 按原主键分批插入记录
+func restoreTable(tx *gorm.DB, model interface{}, r io.Reader, version int) (int64, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return 0, err
+	}
+	decode := decodeColumns
+	if version == legacyFormatVersion {
+		decode = decodeLegacyRecord
+	}
+
+	// 以列名写入而不是直接插入结构体，否则gorm会把带默认值的零值字段（如 enabled 默认1）替换成默认值
+	batch := make([]map[string]interface{}, 0, batchSize)
+	insert := tx.Session(&gorm.Session{SkipHooks: true}).Table(stmt.Schema.Table)
+	var rows int64
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := insert.Create(&batch).Error; err != nil {
+			return err
+		}
+		rows += int64(len(batch))
+		batch = make([]map[string]interface{}, 0, batchSize)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		columns, err := decode(tx, stmt, scanner.Bytes())
+		if err != nil {
+			return rows, fmt.Errorf("解析第%d条记录失败: %v", rows+int64(len(batch))+1, err)
+		}
+		batch = append(batch, columns)
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return rows, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return rows, err
+	}
+	return rows, flush()
+}
+
+// decodeColumns 解析按列名导出的记录，各列按模型字段的类型解码，归档中没有的列使用数据库默认值
+func decodeColumns(tx *gorm.DB, stmt *gorm.Statement, line []byte) (map[string]interface{}, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return nil, err
+	}
+	columns := make(map[string]interface{}, len(stmt.Schema.DBNames))
+	for _, name := range stmt.Schema.DBNames {
+		data, ok := raw[name]
+		if !ok {
+			continue
+		}
+		value := reflect.New(stmt.Schema.FieldsByDBName[name].FieldType)
+		if err := json.Unmarshal(data, value.Interface()); err != nil {
+			return nil, fmt.Errorf("解析列%s失败: %v", name, err)
+		}
+		columns[name] = value.Elem().Interface()
+	}
+	return columns, nil
+}
+
+// decodeLegacyRecord 解析版本1按模型JSON导出的记录，json:"-" 的列在归档中不存在，恢复为零值
+func decodeLegacyRecord(tx *gorm.DB, stmt *gorm.Statement, line []byte) (map[string]interface{}, error) {
+	record := reflect.New(stmt.Schema.ModelType)
+	if err := json.Unmarshal(line, record.Interface()); err != nil {
+		return nil, err
+	}
+	columns := make(map[string]interface{}, len(stmt.Schema.DBNames))
+	for _, name := range stmt.Schema.DBNames {
+		value, _ := stmt.Schema.FieldsByDBName[name].ValueOf(tx.Statement.Context, record.Elem())
+		columns[name] = value
+	}
+	return columns, nil
+}
+
+// resetSequence 显式写入主键后 PostgreSQL 的自增序列不会前进，需要手动对齐到当前最大值
+func resetSequence(tx *gorm.DB, model interface{}, table string) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	field := stmt.Schema.PrioritizedPrimaryField
+	if field == nil || !field.AutoIncrement {
+		return nil
+	}
+	return tx.Exec(
+		fmt.Sprintf("SELECT setval(pg_get_serial_sequence(?, ?), COALESCE((SELECT MAX(%s) FROM %s), 0) + 1, false)",
+			tx.Statement.Quote(field.DBName), tx.Statement.Quote(table)),
+		table, field.DBName,
+	).Error
+}
+
+// walkArchive 依次把归档中的文件交给 fn，fn 返回false时停止
+func walkArchive(path string, fn func(name string, r io.Reader) (bool, error)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开备份文件失败: %v", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("读取备份文件失败: %v", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取备份文件失败: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		next, err := fn(header.Name, tr)
+		if err != nil || !next {
+			return err
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package backup
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+// s3Timeout 单次上传或下载的超时时间
+const s3Timeout = 30 * time.Minute
+
+// S3Client 最小的S3兼容存储客户端，只实现上传、下载和删除，使用 Signature V4 签名。
+// 备份和固件管理共用
+type S3Client struct {
+	config     configs.BackupS3Config
+	httpClient *http.Client
+}
+
+// NewS3Client 创建S3客户端，未配置 bucket 时返回nil
+func NewS3Client(config configs.BackupS3Config) (*S3Client, error) {
+	if config.Bucket == "" {
+		return nil, nil
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, fmt.Errorf("S3备份需要配置 access_key 和 secret_key")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+	return &S3Client{
+		config:     config,
+		httpClient: &http.Client{Timeout: s3Timeout},
+	}, nil
+}
+
+// objectKey 加上配置的前缀
+func (c *S3Client) objectKey(name string) string {
+	prefix := strings.Trim(c.config.Prefix, "/")
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+// objectURL 按路径风格或虚拟主机风格拼接对象地址
+func (c *S3Client) objectURL(bucket, key string) (*url.URL, error) {
+	endpoint, err := url.Parse(c.config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("无效的S3地址: %s", c.config.Endpoint)
+	}
+	u := *endpoint
+	if c.config.PathStyle {
+		u.Path = path.Join("/", endpoint.Path, bucket, key)
+	} else {
+		u.Host = bucket + "." + endpoint.Host
+		u.Path = path.Join("/", endpoint.Path, key)
+	}
+	return &u, nil
+}
+
+// Upload 上传本地文件，返回 s3://bucket/key 形式的地址
+func (c *S3Client) Upload(localPath string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %v", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败: %v", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	key := c.objectKey(path.Base(localPath))
+	u, err := c.objectURL(c.config.Bucket, key)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPut, u.String(), file)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType(localPath))
+	c.sign(req, hex.EncodeToString(hash.Sum(nil)), time.Now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("上传到S3失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("上传到S3失败: %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return fmt.Sprintf("s3://%s/%s", c.config.Bucket, key), nil
+}
+
+// Download 把 s3://bucket/key 下载到本地文件
+func (c *S3Client) Download(remote, localPath string) error {
+	bucket, key, ok := parseS3URL(remote)
+	if !ok {
+		return fmt.Errorf("无效的S3地址: %s", remote)
+	}
+	u, err := c.objectURL(bucket, key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	c.sign(req, emptyPayloadHash, time.Now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("从S3下载失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("从S3下载失败: %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	file, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("创建本地文件失败: %v", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		return fmt.Errorf("从S3下载失败: %v", err)
+	}
+	return nil
+}
+
+// Delete 删除 s3://bucket/key，对象不存在时不返回错误
+func (c *S3Client) Delete(remote string) error {
+	bucket, key, ok := parseS3URL(remote)
+	if !ok {
+		return fmt.Errorf("无效的S3地址: %s", remote)
+	}
+	u, err := c.objectURL(bucket, key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	c.sign(req, emptyPayloadHash, time.Now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("从S3删除失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("从S3删除失败: %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// contentType 上传对象的类型，备份为gzip压缩包，其他文件按二进制上传
+func contentType(name string) string {
+	if strings.HasSuffix(name, ".gz") {
+		return "application/gzip"
+	}
+	return "application/octet-stream"
+}
+
+// parseS3URL 解析 s3://bucket/key
+func parseS3URL(remote string) (bucket, key string, ok bool) {
+	rest, found := strings.CutPrefix(remote, "s3://")
+	if !found {
+		return "", "", false
+	}
+	bucket, key, found = strings.Cut(rest, "/")
+	return bucket, key, found && bucket != "" && key != ""
+}
+
+// emptyPayloadHash 空请求体的SHA-256
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign 按 AWS Signature V4 为请求添加认证头
+func (c *S3Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.config.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.config.SecretKey), date)
+	key = hmacSHA256(key, c.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.config.AccessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 计算HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,146 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/task"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultInterval = 24 * time.Hour
+	defaultDir      = "backups"
+	backupTimeout   = 30 * time.Minute // 单次备份的最长执行时间
+)
+
+// TaskTypeBackup 定时备份任务
+const TaskTypeBackup task.TaskType = "backup"
+
+func init() {
+	task.RegisterTaskTimeout(TaskTypeBackup, backupTimeout)
+}
+
+// backupRetry S3上传等偶发失败时重试，重试会重新打包一份备份
+var backupRetry = task.RetryPolicy{MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: 10 * time.Minute, Jitter: 0.2}
+
+// Scheduler 按 backup.interval 定时向任务管理器提交备份任务
+type Scheduler struct {
+	config  *configs.Config
+	db      *gorm.DB
+	taskMgr *task.TaskManager
+	s3      *S3Client
+
+	running atomic.Bool // 上一次备份未完成时跳过本次
+}
+
+// NewScheduler 构造函数
+func NewScheduler(config *configs.Config, db *gorm.DB, taskMgr *task.TaskManager) (*Scheduler, error) {
+	if db == nil || taskMgr == nil {
+		return nil, fmt.Errorf("数据库或任务管理器未初始化")
+	}
+	s3, err := NewS3Client(config.Backup.S3)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scheduler{
+		config:  config,
+		db:      db,
+		taskMgr: taskMgr,
+		s3:      s3,
+	}
+	// 备份任务通过共享队列提交，可能由其他实例提交、在本实例执行
+	task.RegisterTaskExecutor(TaskTypeBackup, s.execute)
+	return s, nil
+}
+
+// execute 备份任务的执行函数
+func (s *Scheduler) execute(t *task.Task) error {
+	_, err := s.RunOnce(t.Context)
+	if err != nil {
+		logrus.WithError(err).Error("定时备份失败")
+	}
+	return err
+}
+
+// Start 启动定时器，ctx 取消后停止
+func (s *Scheduler) Start(ctx context.Context) {
+	interval := defaultInterval
+	if s.config.Backup.Interval != "" {
+		if parsed, err := time.ParseDuration(s.config.Backup.Interval); err == nil && parsed > 0 {
+			interval = parsed
+		} else {
+			logrus.Warnf("backup.interval 配置无效: %s，使用默认值 %s", s.config.Backup.Interval, defaultInterval)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.submit()
+			}
+		}
+	}()
+	logrus.WithFields(logrus.Fields{
+		"interval": interval,
+		"dir":      s.dir(),
+		"s3":       s.s3 != nil,
+	}).Info("定时备份已启动")
+}
+
+// submit 通过共享队列提交备份任务，由任一实例执行；备份属于服务端内部任务，不占用客户端配额
+func (s *Scheduler) submit() {
+	if s.running.Load() {
+		logrus.Warn("上一次备份尚未完成，跳过本次备份")
+		return
+	}
+	if _, err := s.taskMgr.SubmitShared(TaskTypeBackup, struct{}{}, backupRetry); err != nil {
+		logrus.WithError(err).Error("提交备份任务失败")
+	}
+}
+
+// RunOnce 立即执行一次备份，上传到S3并清理过期的本地备份
+func (s *Scheduler) RunOnce(ctx context.Context) (*Result, error) {
+	if !s.running.CompareAndSwap(false, true) {
+		return nil, fmt.Errorf("已有备份正在进行")
+	}
+	defer s.running.Store(false)
+
+	start := time.Now()
+	result, err := Create(ctx, s.db, s.config, s.dir())
+	if err != nil {
+		return nil, err
+	}
+	if s.s3 != nil {
+		remote, err := s.s3.Upload(result.Path)
+		if err != nil {
+			// 本地备份已完成，保留本地文件，下次备份时会重新上传新的归档
+			return result, err
+		}
+		result.Remote = remote
+	}
+	prune(s.dir(), s.config.Backup.Keep)
+
+	logrus.WithFields(logrus.Fields{
+		"path":     result.Path,
+		"remote":   result.Remote,
+		"tables":   len(result.Manifest.Tables),
+		"duration": time.Since(start),
+	}).Info("备份完成")
+	return result, nil
+}
+
+// dir 本地备份目录
+func (s *Scheduler) dir() string {
+	return backupDir(s.config)
+}
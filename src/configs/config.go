@@ -1,7 +1,9 @@
 package configs
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -40,15 +42,30 @@ type Config struct {
 		StaticDir string `yaml:"static_dir"`
 		Websocket string `yaml:"websocket"`
 		VisionURL string `yaml:"vision"`
+		// 管理接口统一使用的管理员令牌（Authorization: Bearer），为空时不开放管理接口
+		AdminToken string `yaml:"admin_token"`
+		// 外部应用调用Vision接口的API Key，与设备token相互独立
+		VisionAPIKeys []VisionAPIKeyConfig `yaml:"vision_api_keys"`
+		CORS          CORSConfig           `yaml:"cors"`
+		Security      SecurityHeaderConfig `yaml:"security_headers"`
 	} `yaml:"web"`
 
 	DefaultPrompt    string   `yaml:"prompt"`
 	Roles            []string `yaml:"roles"` // 角色列表
 	DeleteAudio      bool     `yaml:"delete_audio"`
+	TTSParallelism   int      `yaml:"tts_parallelism"` // 单连接同时合成的句子数
 	QuickReply       bool     `yaml:"quick_reply"`
 	QuickReplyWords  []string `yaml:"quick_reply_words"`
+	ProsodyMarkup    bool     `yaml:"prosody_markup"` // 允许LLM输出韵律标记（停顿、重音、语速）
 	UsePrivateConfig bool     `yaml:"use_private_config"`
-	LocalMCPFun      []string `yaml:"local_mcp_fun"` // 本地MCP函数映射
+	LocalMCPFun      []string `yaml:"local_mcp_fun"`    // 本地MCP函数映射
+	FunctionPlugins  []string `yaml:"function_plugins"` // 启用的函数调用插件，见 core/tools
+
+	// 下发给设备的音频格式
+	AudioOutput AudioOutputConfig `yaml:"audio_output"`
+
+	// 快速回复词和常用短句预合成
+	TTSPrewarm TTSPrewarmConfig `yaml:"tts_prewarm"`
 
 	SelectedModule map[string]string `yaml:"selected_module"`
 
@@ -58,10 +75,134 @@ type Config struct {
 	LLM   map[string]LLMConfig  `yaml:"LLM"`
 	VLLLM map[string]VLLMConfig `yaml:"VLLLM"`
 
+	Embedding map[string]EmbeddingConfig `yaml:"Embedding"`
+
+	WakeWord map[string]WakeWordConfig `yaml:"WakeWord"`
+
 	CMDExit []string `yaml:"CMD_exit"`
 
 	// 连通性检查配置
 	ConnectivityCheck ConnectivityCheckConfig `yaml:"connectivity_check"`
+
+	// 资源池大小配置
+	Pool ResourcePoolConfig `yaml:"pool"`
+
+	// 异步任务管理器配置
+	Task TaskConfig `yaml:"task"`
+
+	// 设备休眠省电配置
+	PowerSaving PowerSavingConfig `yaml:"power_saving"`
+
+	// LLM输出后处理链，按顺序执行
+	PostProcess []PostProcessorConfig `yaml:"post_process"`
+
+	// 对话触发的自动化规则
+	Rules []RuleConfig `yaml:"rules"`
+
+	// LLM交互录制与回放
+	Sandbox SandboxConfig `yaml:"sandbox"`
+
+	// 设备对讲
+	Intercom IntercomConfig `yaml:"intercom"`
+
+	// 内置技能：天气、日历、新闻
+	Skills SkillsConfig `yaml:"skills"`
+
+	// 单轮对话看门狗
+	TurnWatchdog TurnWatchdogConfig `yaml:"turn_watchdog"`
+
+	// 用户沉默时的追问
+	Reprompt RepromptConfig `yaml:"reprompt"`
+
+	// 播报期间用户开口时打断
+	BargeIn BargeInConfig `yaml:"barge_in"`
+
+	// token用量统计与成本核算
+	Usage UsageConfig `yaml:"usage"`
+
+	// 短问题的LLM回复缓存
+	LLMCache LLMCacheConfig `yaml:"llm_cache"`
+
+	// 识别置信度低时向用户确认
+	ASRClarify ASRClarifyConfig `yaml:"asr_clarify"`
+
+	// LLM重试与故障转移
+	LLMFailover LLMFailoverConfig `yaml:"llm_failover"`
+
+	// 新设备首次连接引导
+	Onboarding OnboardingConfig `yaml:"onboarding"`
+
+	// 对话上下文长度管理
+	ContextWindow ContextWindowConfig `yaml:"context_window"`
+
+	// 对话轮次过多时自动压缩为摘要
+	Summarization SummarizationConfig `yaml:"summarization"`
+
+	// 长期记忆
+	Memory MemoryConfig `yaml:"memory"`
+
+	// 系统提示词模板变量
+	PromptTemplate PromptTemplateConfig `yaml:"prompt_template"`
+
+	// 日志与录制的个人信息脱敏
+	Redaction RedactionConfig `yaml:"redaction"`
+
+	// 故障注入，用于在测试环境验证容错逻辑
+	Chaos ChaosConfig `yaml:"chaos"`
+
+	// 幂等请求的对冲，降低长尾延迟
+	Hedge HedgeConfig `yaml:"hedge"`
+
+	// 对话记录与用户评价
+	Feedback FeedbackConfig `yaml:"feedback"`
+
+	// 数据库与配置的定时备份
+	Backup BackupConfig `yaml:"backup"`
+
+	// 维护模式
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+
+	// OTA固件管理
+	OTA OTAConfig `yaml:"ota"`
+
+	// 对外的MCP服务端点
+	MCPServer MCPServerConfig `yaml:"mcp_server"`
+
+	// 音乐源
+	Music MusicConfig `yaml:"music"`
+
+	// 文生图
+	ImageGen ImageGenConfig `yaml:"image_gen"`
+
+	// 数据库中的助手角色管理
+	RoleAPI RoleAPIConfig `yaml:"role_api"`
+
+	// 声纹识别
+	Voiceprint VoiceprintConfig `yaml:"voiceprint"`
+
+	// 情绪识别与表情
+	Emotion EmotionConfig `yaml:"emotion"`
+
+	// 同声传译模式
+	Translation TranslationConfig `yaml:"translation"`
+
+	// 对话录音保存
+	Recording RecordingConfig `yaml:"recording"`
+
+	// 主动播报接口
+	SpeakAPI SpeakAPIConfig `yaml:"speak_api"`
+
+	// 配置管理接口
+	ConfigAPI ConfigAPIConfig `yaml:"config_api"`
+
+	// 监控指标
+	Metrics MetricsConfig `yaml:"metrics"`
+
+	// 定时任务
+	Schedule ScheduleConfig `yaml:"schedule"`
+
+	// 批量离线识别
+	Transcribe TranscribeConfig `yaml:"transcribe"`
 }
 
 // VADConfig VAD配置结构
@@ -73,19 +214,29 @@ type VADConfig struct {
 	Extra              map[string]interface{} `yaml:",inline"`
 }
 
+// WakeWordConfig 服务端唤醒词检测配置结构
+type WakeWordConfig struct {
+	Type      string                 `yaml:"type"`
+	Keywords  []string               `yaml:"keywords"` // 默认唤醒词，设备可在 devices.wake_words 中单独设置
+	Threshold float64                `yaml:"threshold"`
+	Extra     map[string]interface{} `yaml:",inline"`
+}
+
 // ASRConfig ASR配置结构
 type ASRConfig map[string]interface{}
 
 // TTSConfig TTS配置结构
 type TTSConfig struct {
-	Type            string   `yaml:"type"`
-	Voice           string   `yaml:"voice"`
-	Format          string   `yaml:"format"`
-	OutputDir       string   `yaml:"output_dir"`
-	AppID           string   `yaml:"appid"`
-	Token           string   `yaml:"token"`
-	Cluster         string   `yaml:"cluster"`
-	SurportedVoices []string `yaml:"surported_voices"` // 支持的语音列表
+	Type            string                 `yaml:"type"`
+	Voice           string                 `yaml:"voice"`
+	Format          string                 `yaml:"format"`
+	OutputDir       string                 `yaml:"output_dir"`
+	AppID           string                 `yaml:"appid"`
+	Token           string                 `yaml:"token"`
+	Cluster         string                 `yaml:"cluster"`
+	Speed           float64                `yaml:"speed"`            // 语速倍率，默认1.0
+	SurportedVoices []string               `yaml:"surported_voices"` // 支持的语音列表
+	Extra           map[string]interface{} `yaml:",inline"`          // 额外配置，如 tls
 }
 
 // LLMConfig LLM配置结构
@@ -124,6 +275,515 @@ type ConnectivityCheckConfig struct {
 	} `yaml:"test_modes"`
 }
 
+// TaskConfig 异步任务管理器配置结构，任务包括TTS预合成、批量识别、长文本合成、定时任务等
+type TaskConfig struct {
+	MaxWorkers           int             `yaml:"max_workers"`            // 工作者数量，默认12
+	MaxBackgroundWorkers int             `yaml:"max_background_workers"` // 后台任务最多占用的工作者数，默认为 max_workers 的四分之三
+	MaxTasksPerClient    int             `yaml:"max_tasks_per_client"`   // 每个设备同时执行的任务数上限，默认20
+	RatePerMinute        float64         `yaml:"rate_per_minute"`        // 每个设备每分钟可以提交的任务数，0表示不限
+	Burst                int             `yaml:"burst"`                  // 允许连续提交的任务数，0表示等于 rate_per_minute
+	Timeouts             map[string]int  `yaml:"timeouts"`               // 按任务类型覆盖单次执行的超时秒数，未配置的类型默认5分钟
+	Queue                TaskQueueConfig `yaml:"queue"`                  // 多实例共享的后台任务队列
+}
+
+// TaskQueueConfig 多实例共享的任务队列配置，通过共享队列提交的后台任务由任一实例执行
+type TaskQueueConfig struct {
+	Type       string `yaml:"type"`        // 为空时只在本实例执行，redis 时使用Redis列表在实例间共享
+	Addr       string `yaml:"addr"`        // Redis地址 host:port
+	Username   string `yaml:"username"`    // Redis 6 ACL 用户名，为空时只用密码认证
+	Password   string `yaml:"password"`    // Redis密码
+	DB         int    `yaml:"db"`          // Redis数据库编号
+	TLS        bool   `yaml:"tls"`         // 使用TLS连接Redis
+	Key        string `yaml:"key"`         // 队列的键名，默认 xiaozhi:tasks
+	InstanceID string `yaml:"instance_id"` // 实例标识，需在实例间唯一且重启后不变，默认主机名
+	Consumers  int    `yaml:"consumers"`   // 本实例同时执行的共享任务数，默认等于后台任务工作者上限
+}
+
+// ResourcePoolConfig 资源池配置结构，作用于每个连接占用的ASR、LLM、TTS、VLLLM资源池
+type ResourcePoolConfig struct {
+	MinSize       int     `yaml:"min_size"`        // 至少保持的空闲资源数，默认5
+	MaxSize       int     `yaml:"max_size"`        // 资源总数上限，默认20
+	Autoscale     bool    `yaml:"autoscale"`       // 按活跃连接数自动调整 min_size 和 max_size
+	Headroom      float64 `yaml:"headroom"`        // 自动调整时空闲资源至少为活跃连接数的该比例，默认0.2
+	Limit         int     `yaml:"limit"`           // 自动调整时资源总数不超过该值，默认200
+	IdleTTL       int     `yaml:"idle_ttl"`        // 超出 min_size 的空闲资源闲置超过该秒数后释放，0表示不释放
+	WaitTimeoutMs int     `yaml:"wait_timeout_ms"` // 资源耗尽时新连接排队等待的最长毫秒数，先到先得，0表示立即失败
+
+	// 泄漏检测
+	LeakThreshold int  `yaml:"leak_threshold"` // 资源借出超过该秒数仍未归还时报告借用者，0表示不检测
+	ReclaimLeaks  bool `yaml:"reclaim_leaks"`  // 超过阈值且借用者已离线时强制回收资源
+}
+
+// TTSPrewarmConfig 常用短句预合成配置结构
+type TTSPrewarmConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Phrases []string `yaml:"phrases"` // 除快速回复词外需要预合成的常用短句，回复内容完全一致时使用缓存音频
+}
+
+// PowerSavingConfig 设备休眠省电配置结构
+type PowerSavingConfig struct {
+	KeepaliveInterval int `yaml:"keepalive_interval"` // 休眠期间设备心跳间隔（秒）
+	MaxQueuedPushes   int `yaml:"max_queued_pushes"`  // 休眠期间最多缓存的非紧急推送数
+}
+
+// TurnWatchdogConfig 单轮对话看门狗配置结构，各项为0时使用默认值
+type TurnWatchdogConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	MaxTurnSeconds int    `yaml:"max_turn_seconds"` // 单轮对话从开始到播报结束的最长时间（秒）
+	ASRTimeout     int    `yaml:"asr_timeout"`      // 停止拾音后等待识别结果的最长时间（秒）
+	LLMTimeout     int    `yaml:"llm_timeout"`      // LLM无任何输出的最长时间（秒）
+	TTSTimeout     int    `yaml:"tts_timeout"`      // 单句合成与播放的最长时间（秒）
+	Message        string `yaml:"message"`          // 超时后的致歉语
+}
+
+// RepromptConfig 助手提问后用户沉默时的追问配置结构
+type RepromptConfig struct {
+	Enabled      bool              `yaml:"enabled"`
+	Timeout      int               `yaml:"timeout"`       // 提问播报结束后等待用户回答的时间（秒）
+	MaxReprompts int               `yaml:"max_reprompts"` // 结束等待前的追问次数，默认1次
+	Phrases      map[string]string `yaml:"phrases"`       // 按角色名称配置追问语，default 为默认追问语
+}
+
+// BargeInConfig 语音打断配置结构，依赖服务端VAD检测用户开口
+type BargeInConfig struct {
+	Enabled     bool `yaml:"enabled"`
+	GracePeriod int  `yaml:"grace_period"` // 开始播报后的保护时间（毫秒），期间不触发打断，避免回声误触发
+}
+
+// UsageConfig token用量统计配置结构
+type UsageConfig struct {
+	Currency  string                `yaml:"currency"`   // 价格币种，仅用于展示
+	Pricing   map[string]UsagePrice `yaml:"pricing"`    // 按模型名称配置的价格
+	Budget    BudgetConfig          `yaml:"budget"`     // 每月用量预算
+	ReportDir string                `yaml:"report_dir"` // 每日用量报告的保存目录，报告由定时任务 schedule.usage_report 生成
+}
+
+// BudgetConfig 每月用量预算配置结构，单个设备或分组的预算通过 /api/usage/budgets 设置
+type BudgetConfig struct {
+	Enabled             bool                `yaml:"enabled"`
+	MonthlyTokens       int64               `yaml:"monthly_tokens"`        // 未单独设置预算的设备每月token上限，0表示不限
+	MonthlyAudioMinutes int64               `yaml:"monthly_audio_minutes"` // 未单独设置预算的设备每月音频分钟数上限，0表示不限
+	Groups              map[string][]string `yaml:"groups"`                // 设备分组：分组名 -> 设备ID列表，分组预算按组内设备用量之和计算
+	Action              string              `yaml:"action"`                // 超出预算后：downgrade 切换到 fallback_llm，decline 婉拒对话
+	FallbackLLM         string              `yaml:"fallback_llm"`          // 降级使用的LLM，对应LLM配置中的名称，通常为本地模型
+	DeclineMessage      string              `yaml:"decline_message"`       // 婉拒时的播报内容
+}
+
+// UsagePrice 模型价格，单位为每千token
+type UsagePrice struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+}
+
+// ContextWindowConfig 对话上下文长度管理配置结构
+type ContextWindowConfig struct {
+	Enabled       bool           `yaml:"enabled"`
+	MaxTokens     int            `yaml:"max_tokens"`     // 未在 models 中配置的模型使用的上下文窗口
+	ReserveTokens int            `yaml:"reserve_tokens"` // 为回复预留的token数，LLM配置了max_tokens时以其为准
+	Strategy      string         `yaml:"strategy"`       // truncate：直接移除最早的轮次；summarize：压缩为摘要
+	Models        map[string]int `yaml:"models"`         // 模型名称 -> 上下文窗口
+}
+
+// SummarizationConfig 对话自动摘要配置结构
+type SummarizationConfig struct {
+	Enabled   bool `yaml:"enabled"`
+	MaxTurns  int  `yaml:"max_turns"`  // 对话超过该轮数时压缩较早的轮次，默认20轮
+	KeepTurns int  `yaml:"keep_turns"` // 压缩后保留的最近轮数，默认6轮
+	CarryOver bool `yaml:"carry_over"` // 设备重新连接时载入上次会话的摘要（需要连接数据库）
+}
+
+// MemoryConfig 长期记忆配置结构，需要在 selected_module 中配置Embedding
+type MemoryConfig struct {
+	Enabled  bool              `yaml:"enabled"`
+	Store    MemoryStoreConfig `yaml:"store"`
+	TopK     int               `yaml:"top_k"`     // 每轮对话检索的记忆条数，默认5条
+	MinScore float64           `yaml:"min_score"` // 相似度低于该值的记忆不放入提示词，默认0.5
+	MaxFacts int               `yaml:"max_facts"` // 每次会话最多记住的事实条数，默认10条
+}
+
+// MemoryStoreConfig 记忆向量库配置
+type MemoryStoreConfig struct {
+	Type  string                 `yaml:"type"`    // sqlite-vec 或 qdrant
+	Extra map[string]interface{} `yaml:",inline"` // 向量库参数
+}
+
+// PromptTemplateConfig 系统提示词模板配置结构
+type PromptTemplateConfig struct {
+	FromDatabase bool   `yaml:"from_database"` // 使用数据库 system_config 表中的提示词，为空时仍使用 prompt
+	Location     string `yaml:"location"`      // 设备未上报位置时 {{location}} 的默认值
+}
+
+// ChaosConfig 故障注入配置结构
+type ChaosConfig struct {
+	Enabled bool                 `yaml:"enabled"`
+	Seed    int64                `yaml:"seed"`    // 随机数种子，0表示按时间生成，固定种子可复现
+	Targets map[string]ChaosRule `yaml:"targets"` // 注入点：pool、llm、tts、asr、vlllm
+}
+
+// ChaosRule 单个注入点的故障规则
+type ChaosRule struct {
+	FailureRate float64 `yaml:"failure_rate"` // 失败概率，0-1
+	DelayRate   float64 `yaml:"delay_rate"`   // 延迟概率，0-1
+	DelayMinMs  int     `yaml:"delay_min_ms"`
+	DelayMaxMs  int     `yaml:"delay_max_ms"`
+}
+
+// FeedbackConfig 对话评价配置结构
+type FeedbackConfig struct {
+	Enabled bool   `yaml:"enabled"` // 保存每轮问答并接受设备和 /api/feedback 的评价，需要数据库
+	Reply   string `yaml:"reply"`   // 设备评价后播报的提示语，为空时不播报
+}
+
+// BackupConfig 定时备份配置结构
+type BackupConfig struct {
+	Enabled  bool           `yaml:"enabled"`
+	Interval string         `yaml:"interval"` // 备份间隔，如 24h
+	Dir      string         `yaml:"dir"`      // 本地备份目录，上传到S3时也先写入这里
+	Keep     int            `yaml:"keep"`     // 本地保留的备份份数，0表示不清理
+	S3       BackupS3Config `yaml:"s3"`       // 配置 bucket 后同时上传到S3兼容存储
+}
+
+// MaintenanceConfig 维护模式配置结构
+type MaintenanceConfig struct {
+	Enabled    bool   `yaml:"enabled"`     // 启动时即进入维护模式
+	Message    string `yaml:"message"`     // 新会话播报的维护公告
+	RetryAfter int    `yaml:"retry_after"` // 拒绝OTA请求时建议设备重试的间隔（秒）
+}
+
+// OTAConfig OTA固件管理配置结构
+type OTAConfig struct {
+	Dir         string         `yaml:"dir"`           // 固件目录，默认 ota_bin
+	MaxUploadMB int            `yaml:"max_upload_mb"` // 上传固件的大小上限（MB），默认32
+	S3          BackupS3Config `yaml:"s3"`            // 配置 bucket 后固件同时保存到S3兼容存储，本地目录作为下载缓存
+
+	DownloadRateKB int `yaml:"download_rate_kb"` // 单个固件下载的限速（KB/s），0表示不限速
+}
+
+// MusicConfig 音乐源配置结构，在 function_plugins 中启用 play_song、music_control 后生效
+type MusicConfig struct {
+	Type       string                 `yaml:"type"`        // local 或 subsonic，为空时不启用
+	MaxResults int                    `yaml:"max_results"` // 搜索结果加入播放列表的歌曲数，默认10首
+	Extra      map[string]interface{} `yaml:",inline"`     // 音乐源参数
+}
+
+// ImageGenConfig 文生图配置结构，在 function_plugins 中启用 draw_image 后生效
+type ImageGenConfig struct {
+	Type        string                 `yaml:"type"`         // openai 或 sdwebui，为空时不启用
+	URL         string                 `yaml:"url"`          // 设备下载图片的地址前缀，对应 /api/images
+	Dir         string                 `yaml:"dir"`          // 图片保存目录，默认 data/images
+	Size        string                 `yaml:"size"`         // 图片尺寸 宽x高
+	PreviewSize int                    `yaml:"preview_size"` // 预览图的最大边长，默认240
+	Extra       map[string]interface{} `yaml:",inline"`      // 文生图服务参数
+}
+
+// MCPServerConfig 对外的MCP服务端点配置，客户端使用 web.admin_token 连接
+type MCPServerConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// RoleAPIConfig 角色管理接口配置，角色保存在数据库的 roles 表，接口使用 web.admin_token 鉴权
+type RoleAPIConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// VoiceprintConfig 声纹识别配置结构，识别出用户后应用该用户的设置和长期记忆
+type VoiceprintConfig struct {
+	Enabled    bool                   `yaml:"enabled"`
+	Type       string                 `yaml:"type"`        // 3d-speaker 或 rest
+	Threshold  float64                `yaml:"threshold"`   // 余弦相似度达到该值才认为是同一个人，默认0.6
+	MinSeconds float64                `yaml:"min_seconds"` // 参与识别的最短音频时长（秒），默认1秒
+	MaxSeconds float64                `yaml:"max_seconds"` // 只使用每句话最后这么长的音频（秒），默认10秒
+	Extra      map[string]interface{} `yaml:",inline"`     // 声纹提取器参数
+}
+
+// AudioOutputConfig 下发音频格式配置结构，设备在 hello 中声明支持的输出格式时按设备的优先顺序协商
+type AudioOutputConfig struct {
+	Format     string   `yaml:"format"`      // 设备没有声明时使用的格式：opus 或 pcm，默认opus
+	SampleRate int      `yaml:"sample_rate"` // 设备没有声明时使用的采样率，默认24000
+	Formats    []string `yaml:"formats"`     // 允许协商的格式，为空表示都允许
+	FFmpegPath string   `yaml:"ffmpeg_path"` // mp3 编码使用的 ffmpeg 路径，默认从 PATH 查找
+}
+
+// EmotionConfig 情绪识别配置结构
+type EmotionConfig struct {
+	Enabled       bool    `yaml:"enabled"`
+	PromptHint    bool    `yaml:"prompt_hint"`     // 把识别到的用户情绪附加到系统提示词
+	Energy        bool    `yaml:"energy"`          // 结合说话音量与平时的差异
+	EnergyDeltaDB float64 `yaml:"energy_delta_db"` // 音量比平时高或低这么多分贝时视为大声或小声，默认6
+}
+
+// TranslationConfig 同声传译模式配置结构
+type TranslationConfig struct {
+	DefaultTarget string            `yaml:"default_target"` // 没有指定目标语言时使用，默认英语
+	Voices        map[string]string `yaml:"voices"`         // 目标语言 -> 播报译文的音色
+	ExitWords     []string          `yaml:"exit_words"`     // 翻译模式下说出这些词时退出
+}
+
+// SpeakAPIConfig 主动播报接口配置结构
+type SpeakAPIConfig struct {
+	MaxChars      int               `yaml:"max_chars"`     // 单次播报的最大字数，默认500
+	Announcements map[string]string `yaml:"announcements"` // 预设播报：名称 -> 文本，文本中的 {{变量}} 由请求的 vars 替换
+}
+
+// ConfigAPIConfig 配置管理接口配置结构，接口使用 web.admin_token 鉴权
+type ConfigAPIConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// MetricsConfig 监控指标接口配置结构
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled"` // 是否开放 /api/metrics，配置了 web.admin_token 时抓取需要该令牌
+}
+
+// ScheduleConfig 定时任务配置结构，任务保存在数据库中，服务重启后继续生效
+type ScheduleConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	LogCleanup  string `yaml:"log_cleanup"`  // 清理过期日志的cron表达式，为空时不定时清理
+	UsageReport string `yaml:"usage_report"` // 生成前一天用量报告的cron表达式，为空时不生成
+}
+
+// TranscribeConfig 批量离线识别配置结构
+type TranscribeConfig struct {
+	CallbackHosts []string `yaml:"callback_hosts"` // 允许回调的主机名，支持 *.example.com，为空时不接受 callback_url
+	MaxJobs       int      `yaml:"max_jobs"`       // 内存中保留的任务数上限（含已完成待查询的），默认100
+	MaxPendingMB  int      `yaml:"max_pending_mb"` // 等待识别的PCM数据总量上限（MB），默认200
+}
+
+// RecordingConfig 对话录音保存配置结构，用于调试和质检
+type RecordingConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	Dir           string `yaml:"dir"`            // 录音保存目录，默认 data/recordings
+	User          bool   `yaml:"user"`           // 保存用户每轮说的话
+	Reply         bool   `yaml:"reply"`          // 保存合成的回复语音
+	RetentionDays int    `yaml:"retention_days"` // 录音保留天数，0表示不自动清理
+}
+
+// BackupS3Config S3兼容存储配置结构
+type BackupS3Config struct {
+	Endpoint  string `yaml:"endpoint"`   // 如 https://s3.us-east-1.amazonaws.com，为空时按 region 使用AWS地址
+	Region    string `yaml:"region"`     // 签名使用的区域
+	Bucket    string `yaml:"bucket"`     // 为空时不上传
+	Prefix    string `yaml:"prefix"`     // 对象键前缀
+	AccessKey string `yaml:"access_key"` // 访问密钥ID
+	SecretKey string `yaml:"secret_key"` // 访问密钥
+	PathStyle bool   `yaml:"path_style"` // 使用 endpoint/bucket/key 形式的地址，MinIO等通常需要开启
+}
+
+// HedgeConfig 请求对冲配置结构
+type HedgeConfig struct {
+	TTS       HedgeRule `yaml:"tts"`
+	Embedding HedgeRule `yaml:"embedding"`
+}
+
+// HedgeRule 单类请求的对冲规则
+type HedgeRule struct {
+	Enabled  bool `yaml:"enabled"`
+	DelayMs  int  `yaml:"delay_ms"`  // 主请求超过该时间（毫秒）未返回时发送对冲请求
+	MaxChars int  `yaml:"max_chars"` // 文本不超过该长度才对冲，0表示不限制
+}
+
+// RedactionConfig 个人信息脱敏配置结构，只作用于落盘的日志和录制，不影响交给LLM的对话
+type RedactionConfig struct {
+	Enabled     bool               `yaml:"enabled"`
+	Logs        bool               `yaml:"logs"`        // 对日志脱敏
+	Recordings  bool               `yaml:"recordings"`  // 对sandbox录制的对话脱敏
+	Transcripts bool               `yaml:"transcripts"` // 对保存的对话记录、摘要和录音文本脱敏
+	Builtin     []string           `yaml:"builtin"`     // 启用的内置规则：id_card、phone、address，为空表示全部启用
+	Patterns    []RedactionPattern `yaml:"patterns"`    // 自定义规则，在内置规则之后匹配
+}
+
+// RedactionPattern 自定义脱敏规则
+type RedactionPattern struct {
+	Name        string `yaml:"name"`
+	Pattern     string `yaml:"pattern"`     // 正则表达式
+	Replacement string `yaml:"replacement"` // 替换文本，默认 [已脱敏]
+}
+
+// OnboardingConfig 新设备首次连接引导配置结构
+type OnboardingConfig struct {
+	Enabled      bool              `yaml:"enabled"`
+	Greeting     string            `yaml:"greeting"`     // 开场白，同时请用户给助手起名字
+	VoicePrompt  string            `yaml:"voice_prompt"` // 选择音色的提问，%s 替换为可选音色
+	Voices       map[string]string `yaml:"voices"`       // 可选音色：口语名称 -> 音色ID，为空时跳过选择音色
+	Capabilities string            `yaml:"capabilities"` // 能力介绍，引导结束时播报
+}
+
+// LLMFailoverConfig LLM重试与故障转移配置结构
+type LLMFailoverConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	MaxRetries   int    `yaml:"max_retries"`   // 每个LLM的重试次数
+	BaseDelayMs  int    `yaml:"base_delay_ms"` // 首次重试间隔（毫秒），之后按指数增长
+	MaxDelayMs   int    `yaml:"max_delay_ms"`  // 重试间隔上限（毫秒）
+	StallTimeout int    `yaml:"stall_timeout"` // 流式输出停滞超过该秒数视为故障，0表示不检测
+	Fallback     string `yaml:"fallback"`      // 备用LLM，对应LLM配置中的名称，为空时只重试
+}
+
+// ASRClarifyConfig 低置信度识别结果确认配置结构
+type ASRClarifyConfig struct {
+	Enabled   bool    `yaml:"enabled"`
+	Threshold float64 `yaml:"threshold"` // 置信度低于该值时先向用户确认（0~1）
+	Prompt    string  `yaml:"prompt"`    // 确认话术，%s 替换为识别文本
+	Retry     string  `yaml:"retry"`     // 用户否认后请其重说的话术
+}
+
+// LLMCacheConfig LLM回复缓存配置结构
+type LLMCacheConfig struct {
+	Enabled             bool     `yaml:"enabled"`
+	TTL                 int      `yaml:"ttl"`                  // 缓存有效期（秒）
+	MaxEntries          int      `yaml:"max_entries"`          // 最多缓存条数，超出后淘汰最久未使用的
+	MaxQueryLength      int      `yaml:"max_query_length"`     // 只缓存不超过该字数的问题
+	Semantic            bool     `yaml:"semantic"`             // 使用Embedding模块按语义相似度匹配
+	SimilarityThreshold float64  `yaml:"similarity_threshold"` // 语义匹配的余弦相似度阈值
+	Bypass              []string `yaml:"bypass"`               // 包含这些词的问题（时间、天气等随时变化的）不参与缓存，不配置时使用内置列表
+}
+
+// PostProcessorConfig 后处理器配置结构
+type PostProcessorConfig struct {
+	Name   string                 `yaml:"name"`   // 后处理器名称：think、markdown、emoji、banned_phrases
+	Params map[string]interface{} `yaml:"params"` // 后处理器参数
+}
+
+// IntercomConfig 设备对讲配置结构
+type IntercomConfig struct {
+	Revoice bool                  `yaml:"revoice"` // 目标设备用TTS播报消息，关闭时只推送文字
+	Groups  []IntercomGroupConfig `yaml:"groups"`  // 对讲分组，只能在同组设备之间对讲
+}
+
+// IntercomGroupConfig 对讲分组
+type IntercomGroupConfig struct {
+	Name    string            `yaml:"name"`
+	Devices map[string]string `yaml:"devices"` // 设备名称（如"厨房"） -> 设备ID
+}
+
+// SkillsConfig 内置技能配置结构，需要在 local_mcp_fun 中启用对应的工具
+type SkillsConfig struct {
+	Weather  WeatherSkillConfig            `yaml:"weather"`
+	Calendar CalendarSkillConfig           `yaml:"calendar"`
+	News     NewsSkillConfig               `yaml:"news"`
+	Tenants  map[string]SkillsTenantConfig `yaml:"tenants"` // 按设备所属用户ID覆盖的凭据
+}
+
+// SkillsTenantConfig 单个用户的技能凭据，未填写的项使用全局配置
+type SkillsTenantConfig struct {
+	Weather  WeatherSkillConfig  `yaml:"weather"`
+	Calendar CalendarSkillConfig `yaml:"calendar"`
+	News     NewsSkillConfig     `yaml:"news"`
+}
+
+// WeatherSkillConfig 天气查询配置
+type WeatherSkillConfig struct {
+	Provider    string `yaml:"provider"`     // openweathermap 或 qweather
+	APIURL      string `yaml:"api_url"`      // 接口地址，为空时使用提供者的默认地址
+	APIKey      string `yaml:"api_key"`      // 接口密钥
+	DefaultCity string `yaml:"default_city"` // 用户没有说城市时查询的城市
+}
+
+// CalendarSkillConfig CalDAV日历配置
+type CalendarSkillConfig struct {
+	URL      string `yaml:"url"` // 日历集合地址，如 https://caldav.example.com/calendars/user/home/
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Timezone string `yaml:"timezone"` // 日程时间的时区，为空时使用服务器时区
+}
+
+// NewsSkillConfig RSS新闻播报配置
+type NewsSkillConfig struct {
+	Feeds    map[string]string `yaml:"feeds"`     // 分类名称 -> RSS/Atom 订阅地址
+	MaxItems int               `yaml:"max_items"` // 每次播报的新闻条数，默认5条
+}
+
+// CORSConfig 跨域配置结构
+type CORSConfig struct {
+	AllowOrigins     []string          `yaml:"allow_origins"`     // 允许的来源，为空或包含"*"表示允许所有来源
+	AllowHeaders     []string          `yaml:"allow_headers"`     // 允许的请求头，为空时使用默认列表
+	ExposeHeaders    []string          `yaml:"expose_headers"`    // 暴露给浏览器的响应头
+	AllowCredentials bool              `yaml:"allow_credentials"` // 是否允许携带凭证
+	MaxAge           int               `yaml:"max_age"`           // 预检结果缓存时间（秒），0表示不设置
+	Routes           []CORSRouteConfig `yaml:"routes"`            // 按路由前缀覆盖允许的来源
+}
+
+// Validate 校验跨域配置。允许所有来源时不能同时允许携带凭证，否则任意网站都可以带着用户的凭证访问接口
+func (c CORSConfig) Validate() error {
+	if !c.AllowCredentials {
+		return nil
+	}
+	if len(c.AllowOrigins) == 0 || hasWildcardOrigin(c.AllowOrigins) {
+		return fmt.Errorf("allow_credentials 为 true 时 allow_origins 必须列出具体来源，不能为空或包含\"*\"")
+	}
+	for _, route := range c.Routes {
+		if hasWildcardOrigin(route.AllowOrigins) {
+			return fmt.Errorf("allow_credentials 为 true 时路由 %s 的 allow_origins 不能包含\"*\"", route.Prefix)
+		}
+	}
+	return nil
+}
+
+func hasWildcardOrigin(origins []string) bool {
+	for _, origin := range origins {
+		if strings.TrimSpace(origin) == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSRouteConfig 按路由前缀配置的来源白名单，最长前缀优先
+type CORSRouteConfig struct {
+	Prefix       string   `yaml:"prefix"`        // 路由前缀，如 /api/sandbox
+	AllowOrigins []string `yaml:"allow_origins"` // 该前缀下允许的来源，为空表示禁止跨域访问
+}
+
+// SecurityHeaderConfig 安全响应头配置结构
+type SecurityHeaderConfig struct {
+	Disabled              bool   `yaml:"disabled"`                // 是否关闭安全响应头
+	FrameOptions          string `yaml:"frame_options"`           // X-Frame-Options，默认 DENY
+	ReferrerPolicy        string `yaml:"referrer_policy"`         // Referrer-Policy，默认 no-referrer
+	HSTSMaxAge            int    `yaml:"hsts_max_age"`            // Strict-Transport-Security 的 max-age（秒），0表示不发送
+	ContentSecurityPolicy string `yaml:"content_security_policy"` // Content-Security-Policy，为空表示不发送
+}
+
+// SandboxConfig LLM交互录制与回放配置结构
+type SandboxConfig struct {
+	Record  bool     `yaml:"record"`  // 是否录制会话的LLM交互
+	Devices []string `yaml:"devices"` // 仅录制这些设备，为空表示所有设备
+	Dir     string   `yaml:"dir"`     // 录制文件目录，默认 tmp/sandbox
+}
+
+// RuleConfig 自动化规则配置结构
+type RuleConfig struct {
+	Name     string             `yaml:"name"`     // 规则名称，用于日志
+	Disabled bool               `yaml:"disabled"` // 是否停用
+	Cooldown int                `yaml:"cooldown"` // 同一设备两次触发的最小间隔（秒），0表示不限
+	Trigger  RuleTriggerConfig  `yaml:"trigger"`
+	Actions  []RuleActionConfig `yaml:"actions"`
+}
+
+// RuleTriggerConfig 规则触发条件
+type RuleTriggerConfig struct {
+	Type     string   `yaml:"type"`     // intent、keyword、device_offline
+	Intents  []string `yaml:"intents"`  // intent：匹配的函数调用名称
+	Keywords []string `yaml:"keywords"` // keyword：用户语音中包含任一关键词即触发
+	Devices  []string `yaml:"devices"`  // 仅对这些设备生效，为空表示所有设备
+}
+
+// RuleActionConfig 规则触发后执行的动作
+type RuleActionConfig struct {
+	Type     string                 `yaml:"type"`      // webhook、device_command、notification
+	URL      string                 `yaml:"url"`       // webhook：回调地址
+	Headers  map[string]string      `yaml:"headers"`   // webhook：附加请求头
+	DeviceID string                 `yaml:"device_id"` // device_command/notification：目标设备，为空表示触发规则的设备
+	Payload  map[string]interface{} `yaml:"payload"`   // device_command：原样下发给设备的消息
+	Title    string                 `yaml:"title"`     // notification：标题
+	Message  string                 `yaml:"message"`   // notification：内容，支持 {device_id}、{text}、{intent} 占位符
+}
+
+// VisionAPIKeyConfig Vision接口API Key配置结构
+type VisionAPIKeyConfig struct {
+	Name       string `yaml:"name"`        // 调用方名称，用于日志
+	Key        string `yaml:"key"`         // API Key，通过 X-API-Key 请求头传递
+	DailyQuota int    `yaml:"daily_quota"` // 每日调用次数上限，0表示不限
+}
+
 // VLLMConfig VLLLM配置结构（视觉语言大模型）
 type VLLMConfig struct {
 	Type        string                 `yaml:"type"`        // API类型，复用LLM的类型
@@ -134,9 +794,39 @@ type VLLMConfig struct {
 	MaxTokens   int                    `yaml:"max_tokens"`  // 最大令牌数
 	TopP        float64                `yaml:"top_p"`       // TopP参数
 	Security    SecurityConfig         `yaml:"security"`    // 图片安全配置
+	Clip        ClipConfig             `yaml:"clip"`        // 短视频/动图抽帧配置
+	Preprocess  PreprocessConfig       `yaml:"preprocess"`  // 图片发送前的缩放与压缩配置
 	Extra       map[string]interface{} `yaml:",inline"`     // 额外配置
 }
 
+// PreprocessConfig 图片发送给模型前的预处理配置，缩小大图以降低上传耗时和token开销
+type PreprocessConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	MaxDimension int    `yaml:"max_dimension"` // 长边超过该像素时等比缩小，0表示不缩放
+	Quality      int    `yaml:"quality"`       // 输出JPEG的质量（1-100），默认85
+	Format       string `yaml:"format"`        // 输出格式：jpeg、png，为空时尽量保持原格式
+}
+
+// ClipConfig 短视频和动图抽帧配置，抽出的关键帧按时间顺序作为多张图片发送给模型
+type ClipConfig struct {
+	Enabled     bool    `yaml:"enabled"`      // 是否接受MJPEG、MP4短视频和GIF动图
+	FrameRate   float64 `yaml:"frame_rate"`   // 每秒抽取的帧数，片段较长时自动降低以不超过 max_frames
+	MaxFrames   int     `yaml:"max_frames"`   // 单次最多发送的帧数
+	MaxDuration string  `yaml:"max_duration"` // 只分析片段的前一段时间，如 10s
+	MJPEGFPS    float64 `yaml:"mjpeg_fps"`    // MJPEG 不含时间戳，按该帧率推算每帧的时间
+	FFmpegPath  string  `yaml:"ffmpeg_path"`  // MP4 解码使用的 ffmpeg 路径，默认从 PATH 查找
+}
+
+// EmbeddingConfig 文本向量化配置结构
+type EmbeddingConfig struct {
+	Type       string                 `yaml:"type"`       // 提供者类型：openai、dashscope、local
+	ModelName  string                 `yaml:"model_name"` // 模型名称
+	BaseURL    string                 `yaml:"url"`        // API地址
+	APIKey     string                 `yaml:"api_key"`    // API密钥
+	Dimensions int                    `yaml:"dimensions"` // 输出向量维度，0表示使用模型默认值
+	Extra      map[string]interface{} `yaml:",inline"`    // 额外配置
+}
+
 // LoadConfig 从文件加载配置
 func LoadConfig() (*Config, string, error) {
 	path := ".config.yaml"
@@ -152,6 +842,9 @@ func LoadConfig() (*Config, string, error) {
 	if err := yaml.Unmarshal(data, config); err != nil {
 		return nil, path, err
 	}
+	if err := config.Web.CORS.Validate(); err != nil {
+		return nil, path, fmt.Errorf("web.cors 配置无效: %v", err)
+	}
 
 	return config, path, nil
 }
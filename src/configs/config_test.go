@@ -0,0 +1,43 @@
+package configs
+
+import "testing"
+
+func TestCORSConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  CORSConfig
+		wantErr bool
+	}{
+		{name: "允许所有来源不带凭证", config: CORSConfig{AllowOrigins: []string{"*"}}},
+		{name: "来源为空不带凭证", config: CORSConfig{}},
+		{name: "具体来源带凭证", config: CORSConfig{AllowOrigins: []string{"https://admin.example.com"}, AllowCredentials: true}},
+		{name: "通配符带凭证", config: CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true}, wantErr: true},
+		{name: "来源为空带凭证", config: CORSConfig{AllowCredentials: true}, wantErr: true},
+		{name: "列表中包含通配符带凭证", config: CORSConfig{AllowOrigins: []string{"https://a.example.com", "*"}, AllowCredentials: true}, wantErr: true},
+		{
+			name: "路由通配符带凭证",
+			config: CORSConfig{
+				AllowOrigins:     []string{"https://admin.example.com"},
+				AllowCredentials: true,
+				Routes:           []CORSRouteConfig{{Prefix: "/api/vision", AllowOrigins: []string{"*"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "路由禁止跨域带凭证",
+			config: CORSConfig{
+				AllowOrigins:     []string{"https://admin.example.com"},
+				AllowCredentials: true,
+				Routes:           []CORSRouteConfig{{Prefix: "/api/sandbox", AllowOrigins: []string{}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
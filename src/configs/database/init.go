@@ -136,14 +136,30 @@ func InitDB(logger interface{}) (*gorm.DB, string, error) {
 	return db, dbType, nil
 }
 
-// migrateTables 自动迁移模型表结构
-func migrateTables(db *gorm.DB) error {
-	return db.AutoMigrate(
+// Models 所有需要迁移的模型，备份与恢复也按该顺序处理各表
+func Models() []interface{} {
+	return []interface{}{
 		&models.SystemConfig{},
 		&models.User{},
 		&models.UserSetting{},
 		&models.ModuleConfig{},
-	)
+		&models.Device{},
+		&models.UsageRecord{},
+		&models.DeviceProfile{},
+		&models.ConversationTurn{},
+		&models.ConversationSummary{},
+		&models.UsageBudget{},
+		&models.Role{},
+		&models.Voiceprint{},
+		&models.Recording{},
+		&models.ScheduledJob{},
+		&models.Firmware{},
+	}
+}
+
+// migrateTables 自动迁移模型表结构
+func migrateTables(db *gorm.DB) error {
+	return db.AutoMigrate(Models()...)
 }
 
 // InsertDefaultConfigIfNeeded 首次启动插入默认配置
@@ -3,6 +3,8 @@ package server
 import (
 	"context"
 
+	"xiaozhi-server-go/src/configs"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -10,3 +12,8 @@ import (
 type CfgService interface {
 	Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error
 }
+
+// ProviderReloader 按新配置重建提供者资源池，进行中的会话不受影响
+type ProviderReloader interface {
+	Reload(config *configs.Config) error
+}
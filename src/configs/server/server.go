@@ -2,20 +2,24 @@ package server
 
 import (
 	"context"
+	"net/http"
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/router/admin"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 type DefaultCfgService struct {
-	config *configs.Config
+	config   *configs.Config
+	reloader ProviderReloader
 }
 
 // NewDefaultCfgService 构造函数
-func NewDefaultCfgService(config *configs.Config, logger interface{}) (*DefaultCfgService, error) {
+func NewDefaultCfgService(config *configs.Config, reloader ProviderReloader) (*DefaultCfgService, error) {
 	service := &DefaultCfgService{
-		config: config,
+		config:   config,
+		reloader: reloader,
 	}
 
 	return service, nil
@@ -26,7 +30,11 @@ func (s *DefaultCfgService) Start(ctx context.Context, engine *gin.Engine, apiGr
 
 	apiGroup.GET("/cfg", s.handleGet)
 	apiGroup.POST("/cfg", s.handlePost)
-	apiGroup.OPTIONS("/cfg", s.handleOptions)
+	if s.config.ConfigAPI.Enabled && admin.Enabled(s.config) && s.reloader != nil {
+		admin.Group(apiGroup, s.config).POST("/cfg/reload", s.handleReload)
+	} else {
+		logrus.Info("未开启config_api或未配置web.admin_token，配置重载接口未开放")
+	}
 
 	logrus.Info("Cfg HTTP服务路由注册完成")
 	return nil
@@ -46,9 +54,19 @@ func (s *DefaultCfgService) handlePost(c *gin.Context) {
 	})
 }
 
-func (s *DefaultCfgService) handleOptions(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	c.Header("Access-Control-Allow-Headers", "Content-Type")
-	c.Status(204) // No Content
+// handleReload 重新读取配置文件并重建提供者或池配置有变化的资源池，不需要重启服务
+// 只重载 selected_module、ASR、LLM、TTS、VLLLM、Embedding 和 pool 等资源池相关配置，其他配置仍需重启生效
+func (s *DefaultCfgService) handleReload(c *gin.Context) {
+	config, path, err := configs.LoadConfig()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "读取配置文件失败: " + err.Error()})
+		return
+	}
+	if err := s.reloader.Reload(config); err != nil {
+		logrus.WithError(err).Error("重载资源池失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "重载资源池失败: " + err.Error()})
+		return
+	}
+	logrus.Infof("已按配置文件 %s 重载资源池", path)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "资源池已重载", "selected_module": config.SelectedModule})
 }
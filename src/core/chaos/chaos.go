@@ -0,0 +1,115 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 注入点
+const (
+	TargetPool  = "pool"  // 资源池获取资源
+	TargetLLM   = "llm"   // LLM请求，位于重试与故障转移之内
+	TargetTTS   = "tts"   // 语音合成
+	TargetASR   = "asr"   // 送入ASR的每个音频分片
+	TargetVLLLM = "vlllm" // 视觉模型请求
+)
+
+// ErrInjected 注入的故障，按服务端错误分类，会触发重试与故障转移
+var ErrInjected = fmt.Errorf("故障注入")
+
+// Injector 故障注入器，按配置的概率随机延迟或失败，用于在测试环境验证降级、重试、看门狗等容错逻辑
+type Injector struct {
+	rules map[string]configs.ChaosRule
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New 按配置创建故障注入器，未开启时返回nil；nil注入器不做任何事
+func New(config configs.ChaosConfig) *Injector {
+	if !config.Enabled || len(config.Targets) == 0 {
+		return nil
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{
+		rules: config.Targets,
+		rand:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Inject 在注入点调用，可能先延迟，再按概率返回错误；ctx 取消时立即返回
+func (i *Injector) Inject(ctx context.Context, target string) error {
+	if i == nil {
+		return nil
+	}
+	rule, ok := i.rules[target]
+	if !ok {
+		return nil
+	}
+
+	if delay := i.delay(rule); delay > 0 {
+		logrus.WithFields(logrus.Fields{"target": target, "delay": delay}).Warn("故障注入：延迟")
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if i.hit(rule.FailureRate) {
+		logrus.WithField("target", target).Warn("故障注入：失败")
+		return &types.ProviderError{
+			Kind:     types.ErrorKindServer,
+			Provider: target,
+			Err:      ErrInjected,
+		}
+	}
+	return nil
+}
+
+// Func 绑定注入点，供不接收ctx的调用方使用
+func (i *Injector) Func(target string) func() error {
+	if i == nil {
+		return nil
+	}
+	return func() error {
+		return i.Inject(context.Background(), target)
+	}
+}
+
+// hit 按概率命中
+func (i *Injector) hit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rand.Float64() < rate
+}
+
+// delay 本次注入的延迟，未命中时为0
+func (i *Injector) delay(rule configs.ChaosRule) time.Duration {
+	if !i.hit(rule.DelayRate) {
+		return 0
+	}
+	min, max := rule.DelayMinMs, rule.DelayMaxMs
+	if max <= min {
+		return time.Duration(min) * time.Millisecond
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return time.Duration(min+i.rand.Intn(max-min+1)) * time.Millisecond
+}
@@ -0,0 +1,146 @@
+package chat
+
+import (
+	"context"
+	"unicode"
+	"unicode/utf8"
+)
+
+// messageOverheadTokens 每条消息的角色、分隔符等固定开销
+const messageOverheadTokens = 4
+
+// EstimateTokens 估算文本的token数
+// 各模型分词器不同，这里按偏保守的经验值估算：中日韩字符每字1个token，其余字符每4字节1个token
+func EstimateTokens(text string) int {
+	tokens, otherBytes := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			tokens++
+		} else {
+			otherBytes += utf8.RuneLen(r)
+		}
+	}
+	return tokens + (otherBytes+3)/4
+}
+
+// EstimateMessageTokens 估算单条消息的token数，包含工具调用参数
+func EstimateMessageTokens(msg Message) int {
+	tokens := messageOverheadTokens + EstimateTokens(msg.Content)
+	for _, call := range msg.ToolCalls {
+		tokens += EstimateTokens(call.Function.Name) + EstimateTokens(call.Function.Arguments)
+	}
+	return tokens
+}
+
+// Summarizer 将被移出上下文的早期对话压缩为摘要，previous 为之前的摘要
+type Summarizer func(ctx context.Context, previous string, messages []Message) (string, error)
+
+// EstimateTokens 估算当前对话（含摘要）的token数
+func (dm *DialogueManager) EstimateTokens() int {
+	tokens := 0
+	for _, msg := range dm.GetLLMDialogue() {
+		tokens += EstimateMessageTokens(msg)
+	}
+	return tokens
+}
+
+// FitContext 对话超过 budget 个token时，从最早的轮次开始移出上下文，返回移出的消息数
+// 按轮次（以用户消息开始）整体移除，避免工具调用与结果被拆开；系统消息和最近一轮始终保留
+// summarizer 不为nil时把移出的轮次压缩为摘要附加在系统消息后，摘要失败时直接丢弃
+func (dm *DialogueManager) FitContext(ctx context.Context, budget int, summarizer Summarizer) int {
+	if budget <= 0 || dm.EstimateTokens() <= budget {
+		return 0
+	}
+
+	start := 0
+	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
+		start = 1
+	}
+	// 各轮次的起始位置
+	var turns []int
+	for i := start; i < len(dm.dialogue); i++ {
+		if dm.dialogue[i].Role == "user" || i == start {
+			turns = append(turns, i)
+		}
+	}
+
+	total := dm.EstimateTokens()
+	cut := start
+	for t := 1; t < len(turns) && total > budget; t++ {
+		for _, msg := range dm.dialogue[cut:turns[t]] {
+			total -= EstimateMessageTokens(msg)
+		}
+		cut = turns[t]
+	}
+	if cut == start {
+		return 0
+	}
+
+	return dm.removeTurns(ctx, start, cut, summarizer)
+}
+
+// SummarizeTurns 对话超过 maxTurns 轮时，把最近 keepTurns 轮之前的轮次压缩为摘要，返回移出的消息数
+// 摘要失败时保留原对话，下一轮再尝试
+func (dm *DialogueManager) SummarizeTurns(ctx context.Context, maxTurns, keepTurns int, summarizer Summarizer) int {
+	start := 0
+	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
+		start = 1
+	}
+	var turns []int
+	for i := start; i < len(dm.dialogue); i++ {
+		if dm.dialogue[i].Role == "user" {
+			turns = append(turns, i)
+		}
+	}
+	if maxTurns <= 0 || len(turns) <= maxTurns {
+		return 0
+	}
+	if keepTurns < 1 {
+		keepTurns = 1
+	}
+	if keepTurns > len(turns) {
+		return 0
+	}
+	cut := turns[len(turns)-keepTurns]
+
+	removed := dm.dialogue[start:cut]
+	summary, err := summarizer(ctx, dm.summary, removed)
+	if err != nil {
+		if dm.logger != nil {
+			dm.logger.Warn("压缩早期对话失败，下一轮再尝试: %v", err)
+		}
+		return 0
+	}
+	dm.summary = summary
+	dm.dialogue = append(dm.dialogue[:start], dm.dialogue[cut:]...)
+	return len(removed)
+}
+
+// removeTurns 移出 [start, cut) 的消息，summarizer 不为nil时压缩为摘要，摘要失败时直接丢弃
+func (dm *DialogueManager) removeTurns(ctx context.Context, start, cut int, summarizer Summarizer) int {
+	removed := make([]Message, cut-start)
+	copy(removed, dm.dialogue[start:cut])
+	dm.dialogue = append(dm.dialogue[:start], dm.dialogue[cut:]...)
+
+	if summarizer != nil {
+		summary, err := summarizer(ctx, dm.summary, removed)
+		if err != nil {
+			if dm.logger != nil {
+				dm.logger.Warn("压缩早期对话失败，直接丢弃: %v", err)
+			}
+		} else {
+			dm.summary = summary
+		}
+	}
+	return len(removed)
+}
+
+// Summary 获取早期对话的摘要
+func (dm *DialogueManager) Summary() string {
+	return dm.summary
+}
+
+// SetSummary 设置早期对话的摘要，如接续之前会话时载入上次保存的摘要
+func (dm *DialogueManager) SetSummary(summary string) {
+	dm.summary = summary
+}
@@ -14,6 +14,7 @@ type DialogueManager struct {
 	logger   *utils.Logger
 	dialogue []Message
 	memory   MemoryInterface
+	summary  string // 已移出上下文的早期对话摘要
 }
 
 // NewDialogueManager 创建对话管理器实例
@@ -42,6 +43,14 @@ func (dm *DialogueManager) SetSystemMessage(systemMessage string) {
 	}, dm.dialogue...)
 }
 
+// SystemMessage 获取系统消息内容，没有时返回空字符串
+func (dm *DialogueManager) SystemMessage() string {
+	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
+		return dm.dialogue[0].Content
+	}
+	return ""
+}
+
 // 保留最近的几条对话消息
 func (dm *DialogueManager) KeepRecentMessages(maxMessages int) {
 	if maxMessages <= 0 || len(dm.dialogue) <= maxMessages {
@@ -64,9 +73,15 @@ func (dm *DialogueManager) Put(message Message) {
 	dm.dialogue = append(dm.dialogue, message)
 }
 
-// GetLLMDialogue 获取完整对话历史
+// GetLLMDialogue 获取完整对话历史，有早期对话摘要时附加在系统消息后
 func (dm *DialogueManager) GetLLMDialogue() []Message {
-	return dm.dialogue
+	if dm.summary == "" || len(dm.dialogue) == 0 || dm.dialogue[0].Role != "system" {
+		return dm.dialogue
+	}
+	dialogue := make([]Message, len(dm.dialogue))
+	copy(dialogue, dm.dialogue)
+	dialogue[0].Content += "\n\n之前对话的摘要：" + dm.summary
+	return dialogue
 }
 
 // GetLLMDialogueWithMemory 获取带记忆的对话
@@ -90,6 +105,35 @@ func (dm *DialogueManager) GetLLMDialogueWithMemory(memoryStr string) []Message
 // Clear 清空对话历史
 func (dm *DialogueManager) Clear() {
 	dm.dialogue = make([]Message, 0)
+	dm.summary = ""
+}
+
+// Snapshot 复制当前对话历史（不含system消息），用于会话转移
+func (dm *DialogueManager) Snapshot() []Message {
+	messages := make([]Message, 0, len(dm.dialogue))
+	for _, msg := range dm.dialogue {
+		if msg.Role == "system" {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// Restore 用转移过来的对话历史替换当前对话，保留本地的system消息
+func (dm *DialogueManager) Restore(messages []Message) {
+	dialogue := make([]Message, 0, len(messages)+1)
+	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
+		dialogue = append(dialogue, dm.dialogue[0])
+	}
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			continue
+		}
+		dialogue = append(dialogue, msg)
+	}
+	dm.dialogue = dialogue
+	dm.summary = ""
 }
 
 // ToJSON 将对话历史转换为JSON字符串
@@ -0,0 +1,22 @@
+package chat
+
+import "regexp"
+
+// rePromptVariable 提示词模板变量，形如 {{time}} 或 {{ nickname }}
+var rePromptVariable = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// RenderPrompt 替换提示词中的模板变量，未提供的变量原样保留
+func RenderPrompt(template string, variables map[string]string) string {
+	return rePromptVariable.ReplaceAllStringFunc(template, func(match string) string {
+		name := rePromptVariable.FindStringSubmatch(match)[1]
+		if value, ok := variables[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// HasPromptVariables 提示词是否包含模板变量
+func HasPromptVariables(template string) bool {
+	return rePromptVariable.MatchString(template)
+}
@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"sync"
+	"time"
+)
+
+// SessionSnapshot 会话快照，用于在同一用户的设备之间转移对话上下文
+type SessionSnapshot struct {
+	SessionID    string    `json:"session_id"`
+	FromDeviceID string    `json:"from_device_id"`
+	ToDeviceID   string    `json:"to_device_id"`
+	UserID       int64     `json:"user_id"`
+	Dialogue     []Message `json:"dialogue"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SessionStore 会话存储，按目标设备暂存待接续的会话快照
+type SessionStore struct {
+	mu      sync.Mutex
+	pending map[string]*SessionSnapshot // 目标设备ID -> 会话快照
+	ttl     time.Duration
+}
+
+// NewSessionStore 创建会话存储，ttl 为快照的有效期
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	return &SessionStore{
+		pending: make(map[string]*SessionSnapshot),
+		ttl:     ttl,
+	}
+}
+
+// Put 保存待接续的会话快照，同一目标设备只保留最新的一份
+func (s *SessionStore) Put(snapshot *SessionSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if snapshot.CreatedAt.IsZero() {
+		snapshot.CreatedAt = time.Now()
+	}
+	s.pending[snapshot.ToDeviceID] = snapshot
+	s.cleanupLocked()
+}
+
+// Has 检查目标设备是否有待接续的会话
+func (s *SessionStore) Has(deviceID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanupLocked()
+	_, ok := s.pending[deviceID]
+	return ok
+}
+
+// Take 取出目标设备待接续的会话快照，取出后即从存储中移除
+func (s *SessionStore) Take(deviceID string) (*SessionSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanupLocked()
+	snapshot, ok := s.pending[deviceID]
+	if ok {
+		delete(s.pending, deviceID)
+	}
+	return snapshot, ok
+}
+
+// cleanupLocked 清理过期的快照，调用方需持有锁
+func (s *SessionStore) cleanupLocked() {
+	now := time.Now()
+	for deviceID, snapshot := range s.pending {
+		if now.Sub(snapshot.CreatedAt) > s.ttl {
+			delete(s.pending, deviceID)
+		}
+	}
+}
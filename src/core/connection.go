@@ -13,17 +13,31 @@ import (
 	"time"
 
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/chaos"
 	"xiaozhi-server-go/src/core/chat"
 	"xiaozhi-server-go/src/core/function"
 	"xiaozhi-server-go/src/core/image"
+	"xiaozhi-server-go/src/core/iot"
+	"xiaozhi-server-go/src/core/llmcache"
 	"xiaozhi-server-go/src/core/mcp"
+	"xiaozhi-server-go/src/core/memory"
+	"xiaozhi-server-go/src/core/music"
 	"xiaozhi-server-go/src/core/pool"
+	"xiaozhi-server-go/src/core/postprocess"
 	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/llm"
 	"xiaozhi-server-go/src/core/providers/tts"
 	"xiaozhi-server-go/src/core/providers/vlllm"
+	"xiaozhi-server-go/src/core/recording"
+	"xiaozhi-server-go/src/core/rules"
+	"xiaozhi-server-go/src/core/speaker"
+	"xiaozhi-server-go/src/core/tools"
 	"xiaozhi-server-go/src/core/types"
 	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/imagegen"
+	"xiaozhi-server-go/src/sandbox"
 	"xiaozhi-server-go/src/task"
+	"xiaozhi-server-go/src/usage"
 
 	"github.com/sirupsen/logrus"
 
@@ -70,8 +84,12 @@ type ConnectionHandler struct {
 		tts   providers.TTSProvider
 		vlllm *vlllm.Provider // VLLLM提供者，可选
 	}
+	providerGuard providerGuard // 休眠换绑提供者时的读写锁和使用计数
 
-	initailVoice string // 初始语音名称
+	postProcess *postprocess.Chain // LLM输出后处理链
+
+	initailVoice string  // 初始语音名称
+	initialSpeed float64 // 初始语速
 
 	// 会话相关
 	sessionID string
@@ -90,20 +108,24 @@ type ConnectionHandler struct {
 	serverAudioChannels      int
 	serverAudioFrameDuration int
 
-	clientListenMode string
-	isDeviceVerified bool
-	closeAfterChat   bool
+	clientListenMode   string
+	isDeviceVerified   bool
+	closeAfterChat     bool
+	maintenanceSession bool // 维护期间建立的会话，只播报维护公告
 
 	// 语音处理相关
-	clientVoiceStop bool  // true客户端语音停止, 不再上传语音数据
+	clientVoiceStop int32 // 1表示true客户端语音停止, 不再上传语音数据
 	serverVoiceStop int32 // 1表示true服务端语音停止, 不再下发语音数据
 
+	serverSpeakSince int64 // 服务端开始播报的时间（Unix毫秒），0表示未在播报
+
 	opusDecoder *utils.OpusDecoder // Opus解码器
 
 	// 对话相关
 	dialogueManager     *chat.DialogueManager
-	tts_last_text_index int
-	client_asr_text     string // 客户端ASR文本
+	tts_last_text_index int64  // 本轮最后一句的序号，通过 lastTextIndex/setLastTextIndex 读写
+	client_asr_text     string // 客户端ASR文本，由 asrTextMu 保护
+	asrTextMu           sync.Mutex
 	quickReplyCache     *utils.QuickReplyCache
 
 	// 并发控制
@@ -120,16 +142,91 @@ type ConnectionHandler struct {
 
 	audioMessagesQueue chan struct {
 		filepath  string
+		stream    <-chan []byte // 流式合成的音频数据，非空时优先于filepath
 		text      string
 		round     int // 轮次
 		textIndex int
 	}
 
-	talkRound      int       // 轮次计数
-	roundStartTime time.Time // 轮次开始时间
+	// 并行合成的结果，按序号重排后进入audioMessagesQueue
+	ttsResults chan ttsSegmentResult
+	ttsSem     chan struct{} // 限制单连接同时合成的句子数
+	ttsEpoch   int32         // 打断时递增，使正在合成的旧结果失效
+
+	replyEncoder replyEncoderState // 本轮回复共用的下发编码器
+
+	talkRound      int64      // 轮次计数，通过 currentRound/nextRound 读写
+	turnMu         sync.Mutex // 串行化 startNewTurn
+	roundStartTime time.Time  // 轮次开始时间
+	connectedAt    time.Time  // 连接建立时间
 	// functions
 	functionRegister *function.FunctionRegistry
 	mcpManager       *mcp.Manager
+	toolRounds       int // 当前轮次已交回LLM的工具调用次数
+	toolRoundsTurn   int // toolRounds 对应的轮次
+	watchdog         turnWatchdog
+	reprompt         repromptState
+	budget           budgetState    // 每月用量预算
+	vad              vadState       // 服务端语音活动检测
+	wakeWord         wakeWordState  // 服务端唤醒词检测
+	role             string         // 当前角色名称，默认角色为空
+	form             *function.Form // 进行中的工具参数收集，没有时为nil
+	asrConfidence    float64        // 本句识别结果的置信度
+	hasAsrConfidence bool
+	clarifying       string         // 等待用户确认的低置信度识别文本
+	onboarding       *function.Form // 进行中的首次连接引导，没有时为nil
+	profileLoaded    bool           // 已加载设备个性化设置
+	summaryLoaded    bool           // 已载入之前会话的对话摘要
+
+	promptVars map[string]string // 设备上报的提示词变量，如电量、位置
+
+	turnQuestion string // 本轮用户的问题，保存对话记录时使用
+
+	musicSource music.Source // 音乐源，未配置时为nil
+	music       musicState
+
+	iotThings *iot.Things // 设备上报的IoT外设及其状态
+
+	activeRole roleState // 数据库中的角色，role 字段同时记录角色名称
+
+	speaker speakerState // 声纹识别到的用户
+
+	mood moodState // 本轮用户情绪与回复每句话的情绪
+
+	translation translationState // 同声传译模式
+
+	recording recordingState // 用户本句话的录音
+
+	textChat textChatState // 文字聊天
+
+	providerSelection pool.ProviderSelection // 设备绑定用户选择的提供者，为空时使用 selected_module
+
+	memoryOwnerKey string   // 长期记忆所属的用户，首次使用时确定
+	turnMemories   []string // 本轮检索到的长期记忆
+
+	sessionTransferer SessionTransferer // 会话转移协调器，可选
+	rules             *rules.Engine     // 自动化规则引擎，可选
+	recorder          *sandbox.Recorder // LLM交互录制器，未开启录制时为nil
+	transcripts       *utils.Redactor   // 保存对话记录前脱敏，未开启时为nil
+	intercom          Intercom          // 设备对讲协调器，可选
+	eventStore        *EventStore       // 按设备保存的事件序号与未确认事件，可选
+	llmCache          *llmcache.Cache   // 短问题的LLM回复缓存，未启用时为nil
+	memories          *memory.Service   // 长期记忆，未启用时为nil
+	speakers          *speaker.Service  // 声纹识别，未启用时为nil
+	recordings        *recording.Store  // 对话录音存储，未启用时为nil
+	reminders         ReminderScheduler // 定时提醒，未启用时为nil
+	images            *imagegen.Service // 文生图，未配置时为nil
+	events            *eventConn        // 下行事件信封装饰器
+	chaos             *chaos.Injector   // 故障注入器，未开启时为nil
+
+	// 休眠省电相关
+	asleep          int32           // 1表示设备处于休眠状态，语音资源已归还
+	powerController PowerController // 资源控制器，可选
+	sleepStopChan   chan struct{}   // 唤醒时停止休眠心跳检查
+	sleepVoice      string          // 休眠前使用的语音
+	sleepSpeed      float64         // 休眠前使用的语速
+	pendingPushes   []map[string]interface{}
+	pendingPushesMu sync.Mutex
 
 	mcpResultHandlers map[string]func(interface{}) // MCP处理器映射
 	ctx               context.Context
@@ -145,7 +242,6 @@ func NewConnectionHandler(
 ) *ConnectionHandler {
 	handler := &ConnectionHandler{
 		config:           config,
-		logger:           logger,
 		clientListenMode: "auto",
 		stopChan:         make(chan struct{}),
 		clientAudioQueue: make(chan []byte, 100),
@@ -157,15 +253,21 @@ func NewConnectionHandler(
 		}, 100),
 		audioMessagesQueue: make(chan struct {
 			filepath  string
+			stream    <-chan []byte
 			text      string
 			round     int // 轮次
 			textIndex int
 		}, 100),
 
+		ttsResults: make(chan ttsSegmentResult, 100),
+
 		tts_last_text_index: -1,
 
 		talkRound: 0,
 
+		promptVars:  make(map[string]string),
+		connectedAt: time.Now(),
+
 		serverAudioFormat:        "opus", // 默认使用Opus格式
 		serverAudioSampleRate:    24000,
 		serverAudioChannels:      1,
@@ -175,6 +277,14 @@ func NewConnectionHandler(
 
 		headers: make(map[string]string),
 	}
+	parallelism := config.TTSParallelism
+	if parallelism <= 0 {
+		parallelism = defaultTTSParallelism
+	}
+	handler.ttsSem = make(chan struct{}, parallelism)
+
+	// 派生连接级日志记录器，自动附加 device_id、session_id、turn_id
+	handler.logger = logger.WithFieldsFunc(handler.logContextFields)
 
 	for key, values := range req.Header {
 		if len(values) > 0 {
@@ -189,7 +299,7 @@ func NewConnectionHandler(
 		if key == "Session-Id" {
 			handler.sessionID = values[0] // 会话ID
 		}
-		logger.Info("HTTP头部信息: %s: %s", key, values[0])
+		handler.logger.Info("HTTP头部信息: %s: %s", key, values[0])
 	}
 
 	if handler.sessionID == "" {
@@ -209,20 +319,57 @@ func NewConnectionHandler(
 		handler.mcpManager = providerSet.MCP
 	}
 
+	// 按配置录制本会话的LLM交互，供回放对比
+	if getter, ok := handler.providers.llm.(interface{ Config() *llm.Config }); ok {
+		llmConfig := getter.Config()
+		handler.recorder = sandbox.NewRecorder(config.Sandbox, handler.sessionID, handler.deviceID, llmConfig.Type, llmConfig.ModelName)
+		if handler.recorder != nil && config.Redaction.Recordings {
+			redactor, err := utils.NewRedactor(config.Redaction)
+			if err != nil {
+				handler.logger.Error("脱敏规则配置无效，录制不脱敏: %v", err)
+			}
+			handler.recorder.SetRedactor(redactor)
+		}
+	}
+	if config.Redaction.Transcripts {
+		redactor, err := utils.NewRedactor(config.Redaction)
+		if err != nil {
+			handler.logger.Error("脱敏规则配置无效，对话记录不脱敏: %v", err)
+		}
+		handler.transcripts = redactor
+	}
+
 	ttsProvider := "default" // 默认TTS提供者名称
 	voiceName := "default"
 	if getter, ok := handler.providers.tts.(configGetter); ok {
 		ttsProvider = getter.Config().Type
 		voiceName = getter.Config().Voice
 		handler.initailVoice = voiceName // 保存初始语音名称
+		handler.initialSpeed = getter.Config().Speed
 	}
-	logger.Info("使用TTS提供者: %s, 语音名称: %s", ttsProvider, voiceName)
-	handler.quickReplyCache = utils.NewQuickReplyCache(ttsProvider, voiceName)
+	handler.logger.Info("使用TTS提供者: %s, 语音名称: %s", ttsProvider, voiceName)
+	handler.quickReplyCache = quickReplyCacheFor(handler.providers.tts)
 
 	// 初始化对话管理器
 	handler.dialogueManager = chat.NewDialogueManager(handler.logger, nil)
-	handler.dialogueManager.SetSystemMessage(config.DefaultPrompt)
+	systemPrompt := basePrompt(config)
+	if config.ProsodyMarkup {
+		systemPrompt += tts.ProsodyPrompt
+	}
+	handler.dialogueManager.SetSystemMessage(systemPrompt)
+
+	postProcess, err := postprocess.NewChain(config.PostProcess)
+	if err != nil {
+		handler.logger.Error("后处理链配置无效，使用默认配置: %v", err)
+		postProcess, _ = postprocess.NewChain(nil)
+	}
+	handler.postProcess = postProcess
+	handler.chaos = chaos.New(config.Chaos)
 	handler.functionRegister = function.NewFunctionRegistry()
+	handler.iotThings = iot.NewThings()
+	if err := tools.Bind(handler.functionRegister, config.FunctionPlugins); err != nil {
+		handler.logger.Error("%v", err)
+	}
 	handler.initMCPResultHandlers()
 
 	return handler
@@ -232,8 +379,9 @@ func (h *ConnectionHandler) SetTaskCallback(callback func(func(*ConnectionHandle
 	h.safeCallbackFunc = callback
 }
 
-func (h *ConnectionHandler) SubmitTask(taskType string, params map[string]interface{}) {
-	_task, id := task.NewTask(h.ctx, "", params)
+// SubmitTask 以设备为单位提交异步任务，超出设备的频率或配额限制时播报提示
+func (h *ConnectionHandler) SubmitTask(taskType string, params map[string]interface{}) error {
+	_task, id := task.NewTask(h.ctx, task.TaskType(taskType), params)
 	h.LogInfo(fmt.Sprintf("提交任务: %s, ID: %s, 参数: %v", _task.Type, id, params))
 	// 创建安全回调用于任务完成时调用
 	var taskCallback func(result interface{})
@@ -253,7 +401,33 @@ func (h *ConnectionHandler) SubmitTask(taskType string, params map[string]interf
 	}
 	cb := task.NewCallBack(taskCallback)
 	_task.Callback = cb
-	h.taskMgr.SubmitTask(h.sessionID, _task)
+
+	// 按设备限流，重连后仍然计入同一个设备
+	clientID := h.deviceID
+	if clientID == "" {
+		clientID = h.sessionID
+	}
+	if err := h.taskMgr.SubmitTask(clientID, _task); err != nil {
+		h.LogError(fmt.Sprintf("提交任务失败: %s, %v", _task.Type, err))
+		var quotaErr *task.QuotaError
+		if errors.As(err, &quotaErr) {
+			h.SystemSpeak(taskRejectionMessage(quotaErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// taskRejectionMessage 任务被限流或超出配额时播报给用户的提示
+func taskRejectionMessage(err *task.QuotaError) string {
+	switch err.Reason {
+	case task.QuotaRateLimited:
+		return fmt.Sprintf("操作太频繁了，请%d秒后再试", err.RetryAfterSeconds())
+	case task.QuotaDaily:
+		return "今天的任务次数已经用完了，明天再试吧"
+	default:
+		return "还有任务正在处理，请等它们完成后再试"
+	}
 }
 
 func (h *ConnectionHandler) handleTaskComplete(task *task.Task, id string, result interface{}) {
@@ -262,16 +436,21 @@ func (h *ConnectionHandler) handleTaskComplete(task *task.Task, id string, resul
 
 func (h *ConnectionHandler) LogInfo(msg string) {
 	if h.logger != nil {
-		h.logger.Info(msg, map[string]interface{}{
-			"device": h.deviceID,
-		})
+		h.logger.Info("%s", msg)
 	}
 }
 func (h *ConnectionHandler) LogError(msg string) {
 	if h.logger != nil {
-		h.logger.Error(msg, map[string]interface{}{
-			"device": h.deviceID,
-		})
+		h.logger.Error("%s", msg)
+	}
+}
+
+// logContextFields 连接级日志上下文字段，每次写日志时动态读取
+func (h *ConnectionHandler) logContextFields() map[string]interface{} {
+	return map[string]interface{}{
+		"device_id":  h.deviceID,
+		"session_id": h.sessionID,
+		"turn_id":    h.currentRound(),
 	}
 }
 
@@ -279,12 +458,15 @@ func (h *ConnectionHandler) LogError(msg string) {
 func (h *ConnectionHandler) Handle(conn Connection) {
 	defer conn.Close()
 
-	h.conn = conn
+	// 下行消息经事件装饰器发送，客户端在hello中协商后启用事件信封
+	h.events = newEventConn(conn, h.eventStore.Outbox(h.deviceID), func() int { return h.currentRound() })
+	h.conn = h.events
 
 	// 启动消息处理协程
 	go h.processClientAudioMessagesCoroutine() // 添加客户端音频消息处理协程
 	go h.processClientTextMessagesCoroutine()  // 添加客户端文本消息处理协程
 	go h.processTTSQueueCoroutine()            // 添加TTS队列处理协程
+	go h.ttsReorderCoroutine()                 // 添加TTS结果排序协程
 	go h.sendAudioMessageCoroutine()           // 添加音频消息发送协程
 
 	// 优化后的MCP管理器处理
@@ -302,7 +484,7 @@ func (h *ConnectionHandler) Handle(conn Connection) {
 			"client_id":  h.clientId,
 			"token":      h.config.Server.Token,
 		}
-		if err := h.mcpManager.BindConnection(conn, h.functionRegister, params); err != nil {
+		if err := h.mcpManager.BindConnection(h.conn, h.functionRegister, params); err != nil {
 			h.LogError(fmt.Sprintf("绑定MCP管理器连接失败: %v", err))
 			return
 		}
@@ -353,20 +535,52 @@ func (h *ConnectionHandler) processClientAudioMessagesCoroutine() {
 			if h.closeAfterChat {
 				continue
 			}
-			if err := h.providers.asr.AddAudio(audioData); err != nil {
-				h.LogError(fmt.Sprintf("处理音频数据失败: %v", err))
+			if h.isAsleep() || !h.beginProviderUse() {
+				continue
 			}
+			h.processClientAudio(audioData)
+			h.endProviderUse()
 		}
 	}
 }
 
+// processClientAudio 处理一段上行音频，调用方需持有提供者使用计数，
+// 识别结果触发的对话也在此期间完成，休眠会等待其结束后再归还提供者
+func (h *ConnectionHandler) processClientAudio(audioData []byte) {
+	asr := h.asrProvider()
+	if asr == nil {
+		return
+	}
+	if h.clientListenMode == listenModeWakeWord {
+		h.detectWakeWord(audioData)
+		return
+	}
+	if err := h.chaos.Inject(h.ctx, chaos.TargetASR); err != nil {
+		h.LogError(fmt.Sprintf("处理音频数据失败: %v", err))
+		return
+	}
+	if err := asr.AddAudio(audioData); err != nil {
+		h.LogError(fmt.Sprintf("处理音频数据失败: %v", err))
+		return
+	}
+	h.addAudioUsage("ASR", h.clientAudioMs(audioData))
+	h.detectVoice(audioData)
+	h.collectSpeakerAudio(audioData)
+	h.collectEmotionAudio(audioData)
+	h.collectRecordingAudio(audioData)
+}
+
 func (h *ConnectionHandler) sendAudioMessageCoroutine() {
 	for {
 		select {
 		case <-h.stopChan:
 			return
 		case task := <-h.audioMessagesQueue:
-			h.sendAudioMessage(task.filepath, task.text, task.textIndex, task.round)
+			if task.stream != nil {
+				h.sendAudioStream(task.stream, task.text, task.textIndex, task.round)
+			} else {
+				h.sendAudioMessage(task.filepath, task.text, task.textIndex, task.round)
+			}
 		}
 	}
 }
@@ -375,10 +589,12 @@ func (h *ConnectionHandler) sendAudioMessageCoroutine() {
 // 返回true则停止语音识别，返回false会继续语音识别
 func (h *ConnectionHandler) OnAsrResult(result string) bool {
 	//h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
-	if h.providers.asr.GetSilenceCount() >= 2 {
+	h.captureAsrConfidence()
+	if asr := h.asrProvider(); asr != nil && asr.GetSilenceCount() >= 2 {
 		h.LogInfo("检测到连续两次静音，结束对话")
 		h.closeAfterChat = true // 如果连续两次静音，则结束对话
 		result = "长时间未检测到用户说话，请礼貌的结束对话"
+		h.hasAsrConfidence = false
 	}
 	if h.clientListenMode == "auto" {
 		if result == "" {
@@ -388,12 +604,12 @@ func (h *ConnectionHandler) OnAsrResult(result string) bool {
 		h.handleChatMessage(context.Background(), result)
 		return true
 	} else if h.clientListenMode == "manual" {
-		h.client_asr_text += result
+		text := h.appendClientASRText(result)
 		if result != "" {
-			h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, h.client_asr_text))
+			h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, text))
 		}
-		if h.clientVoiceStop {
-			h.handleChatMessage(context.Background(), h.client_asr_text)
+		if h.isClientVoiceStop() {
+			h.handleChatMessage(context.Background(), text)
 			return true
 		}
 		return false
@@ -402,7 +618,9 @@ func (h *ConnectionHandler) OnAsrResult(result string) bool {
 			return false
 		}
 		h.stopServerSpeak()
-		h.providers.asr.Reset() // 重置ASR状态，准备下一次识别
+		if asr := h.asrProvider(); asr != nil {
+			asr.Reset() // 重置ASR状态，准备下一次识别
+		}
 		h.LogInfo(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
 		h.handleChatMessage(context.Background(), result)
 		return true
@@ -413,6 +631,8 @@ func (h *ConnectionHandler) OnAsrResult(result string) bool {
 // clientAbortChat 处理中止消息
 func (h *ConnectionHandler) clientAbortChat() error {
 	h.LogInfo("收到客户端中止消息，停止语音识别")
+	h.endTurn()
+	h.cancelReprompt()
 	h.stopServerSpeak()
 	h.sendTTSMessage("stop", "", 0)
 	h.clearSpeakStatus()
@@ -441,23 +661,30 @@ func (h *ConnectionHandler) QuitIntent(text string) bool {
 
 func (h *ConnectionHandler) quickReplyWakeUpWords(text string) bool {
 	// 检查是否包含唤醒词
-	if !h.config.QuickReply || h.talkRound != 1 {
+	if !h.config.QuickReply || h.currentRound() != 1 {
 		return false
 	}
 	if !utils.IsWakeUpWord(text) {
 		return false
 	}
 
-	repalyWords := h.config.QuickReplyWords
+	repalyWords := h.quickReplyWords()
 	reply_text := utils.RandomSelectFromArray(repalyWords)
-	h.tts_last_text_index = 1 // 重置文本索引
-	h.SpeakAndPlay(reply_text, 1, h.talkRound)
+	h.setLastTextIndex(1) // 重置文本索引
+	h.SpeakAndPlay(reply_text, 1, h.currentRound())
 
 	return true
 }
 
 // handleChatMessage 处理聊天消息
 func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string) error {
+	// 设备进入休眠后提供者即将或已经归还，不再开始新的对话
+	if !h.beginProviderUse() {
+		h.LogInfo("设备休眠中，忽略聊天消息")
+		return nil
+	}
+	defer h.endProviderUse()
+
 	if text == "" {
 		h.logger.Warn("收到空聊天消息，忽略")
 		h.clientAbortChat()
@@ -468,11 +695,14 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 		return fmt.Errorf("用户请求退出对话")
 	}
 
+	h.cancelReprompt()
+
 	// 增加对话轮次
-	h.talkRound++
+	currentRound := h.nextRound()
 	h.roundStartTime = time.Now()
-	currentRound := h.talkRound
 	h.LogInfo(fmt.Sprintf("开始新的对话轮次: %d", currentRound))
+	ctx = h.beginTurn(ctx, currentRound)
+	h.markTextChatRound(ctx, currentRound)
 
 	// 判断是否需要验证
 	if h.isNeedAuth() {
@@ -505,18 +735,50 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 	}
 
 	h.LogInfo("收到聊天消息: " + text)
+	h.identifySpeaker(ctx)
+	h.detectUserEmotion(text)
+	h.saveUserRecording(text, currentRound)
+	h.rules.Fire(rules.Event{Type: rules.TriggerKeyword, DeviceID: h.deviceID, Text: text})
 
 	if h.quickReplyWakeUpWords(text) {
 		return nil
 	}
 
+	// 翻译模式下只翻译用户的话，不进入对话
+	if h.translationTarget() != "" {
+		return h.handleTranslation(ctx, text, currentRound)
+	}
+
+	// 识别置信度低时先向用户确认
+	text, handled := h.resolveClarification(text)
+	if handled {
+		return nil
+	}
+
+	// 首次连接引导中，用户的回答交给引导流程处理
+	if h.onboarding != nil {
+		return h.handleOnboardingAnswer(text)
+	}
+
+	// 工具参数收集中，用户的回答交给表单处理
+	if form := h.activeForm(); form != nil {
+		return h.handleFormAnswer(ctx, form, text)
+	}
+
+	// 超出用量预算且不能降级时婉拒
+	if h.declineOverBudget(currentRound) {
+		return nil
+	}
+
 	// 添加用户消息到对话历史
 	h.dialogueManager.Put(chat.Message{
 		Role:    "user",
 		Content: text,
 	})
+	h.turnQuestion = text
+	h.recallMemories(ctx, text)
 
-	return h.genResponseByLLM(ctx, h.dialogueManager.GetLLMDialogue(), currentRound)
+	return h.genResponseByLLM(ctx, h.llmDialogue(ctx), currentRound)
 }
 
 func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []providers.Message, round int) error {
@@ -524,48 +786,74 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 		if r := recover(); r != nil {
 			h.LogError(fmt.Sprintf("genResponseByLLM发生panic: %v", r))
 			errorMsg := "抱歉，处理您的请求时发生了错误"
-			h.tts_last_text_index = 1 // 重置文本索引
+			h.setLastTextIndex(1) // 重置文本索引
 			h.SpeakAndPlay(errorMsg, 1, round)
 		}
 	}()
 
 	llmStartTime := time.Now()
+	h.turnStage(turnStageLLM)
+	ctx = types.WithUsageReporter(ctx, h.usageReporter("LLM"))
 	//h.logger.Info("开始生成LLM回复, round:%d ", round)
 	for _, msg := range messages {
 		_ = msg
 		//msg.Print()
 	}
-	// 使用LLM生成回复
+	cacheLookup := h.lookupLLMCache(ctx, messages)
+	if cacheLookup != nil && cacheLookup.Hit {
+		h.LogInfo(fmt.Sprintf("LLM回复命中缓存，耗时 %s, round: %d", time.Since(llmStartTime), round))
+		h.speakCachedResponse(cacheLookup.Response, round)
+		return nil
+	}
+
+	// 使用LLM生成回复，超出用量预算时使用降级LLM
 	tools := h.functionRegister.GetAllFunctions()
-	responses, err := h.providers.llm.ResponseWithFunctions(ctx, h.sessionID, messages, tools)
+	llmProvider := h.activeLLM()
+	responses, err := llmProvider.ResponseWithFunctions(ctx, h.sessionID, messages, tools)
+	if err != nil && types.ErrorKindOf(err).Retryable() && !h.config.LLMFailover.Enabled {
+		// 超时、网络、服务端错误重试一次，启用故障转移时由提供者按策略重试
+		h.LogError(fmt.Sprintf("LLM请求失败，重试一次: %v", err))
+		time.Sleep(llmRetryDelay)
+		responses, err = llmProvider.ResponseWithFunctions(ctx, h.sessionID, messages, tools)
+	}
 	if err != nil {
-		return fmt.Errorf("LLM生成回复失败: %v", err)
+		if ctx.Err() != nil {
+			// 本轮对话已被打断，或看门狗已取消并致歉
+			return ctx.Err()
+		}
+		return h.handleProviderError("LLM", err, round)
 	}
 
 	// 处理回复
 	var responseMessage []string
 	processedChars := 0
 	textIndex := 0
+	pipeline, err := h.postProcess.NewPipeline()
+	if err != nil {
+		return fmt.Errorf("创建后处理管道失败: %v", err)
+	}
 
 	atomic.StoreInt32(&h.serverVoiceStop, 0)
 
 	// 处理流式响应
 	toolCallFlag := false
-	functionName := ""
-	functionID := ""
-	functionArguments := ""
+	var toolCalls []types.ToolCall
 	contentArguments := ""
 
 	for response := range responses {
+		if ctx.Err() != nil {
+			drainResponses(responses)
+			return ctx.Err()
+		}
+		h.turnStage(turnStageLLM)
 		content := response.Content
-		toolCall := response.ToolCalls
 
-		if response.Error != "" {
-			h.LogError(fmt.Sprintf("LLM响应错误: %s", response.Error))
-			errorMsg := "抱歉，服务暂时不可用，请稍后再试"
-			h.tts_last_text_index = 1 // 重置文本索引
-			h.SpeakAndPlay(errorMsg, 1, round)
-			return fmt.Errorf("LLM响应错误: %s", response.Error)
+		if response.Err != nil || response.Error != "" {
+			respErr := response.Err
+			if respErr == nil {
+				respErr = errors.New(response.Error)
+			}
+			return h.handleProviderError("LLM", respErr, round)
 		}
 
 		if content != "" {
@@ -577,29 +865,18 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 			toolCallFlag = true
 		}
 
-		if len(toolCall) > 0 {
+		for _, delta := range response.ToolCalls {
 			toolCallFlag = true
-			if toolCall[0].ID != "" {
-				functionID = toolCall[0].ID
-			}
-			if toolCall[0].Function.Name != "" {
-				functionName = toolCall[0].Function.Name
-			}
-			if toolCall[0].Function.Arguments != "" {
-				functionArguments += toolCall[0].Function.Arguments
-			}
+			toolCalls = mergeToolCallDelta(toolCalls, delta)
 		}
 
 		if content != "" {
-			if strings.Contains(content, "服务响应异常") {
-				h.LogError(fmt.Sprintf("检测到LLM服务异常: %s", content))
-				errorMsg := "抱歉，服务暂时不可用，请稍后再试"
-				h.tts_last_text_index = 1 // 重置文本索引
-				h.SpeakAndPlay(errorMsg, 1, round)
-				return fmt.Errorf("LLM服务异常")
+			if toolCallFlag {
+				continue
 			}
 
-			if toolCallFlag {
+			// 后处理，处理器可能缓存部分文本
+			if content = pipeline.Process(content); content == "" {
 				continue
 			}
 
@@ -622,7 +899,7 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 				} else {
 					h.LogInfo(fmt.Sprintf("LLM回复分段: %s, index: %d, round:%d", segment, textIndex, round))
 				}
-				h.tts_last_text_index = textIndex
+				h.setLastTextIndex(textIndex)
 				err := h.SpeakAndPlay(segment, textIndex, round)
 				if err != nil {
 					h.LogError(fmt.Sprintf("播放LLM回复分段失败: %v", err))
@@ -632,63 +909,48 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 		}
 	}
 
-	if toolCallFlag {
-		bHasError := false
-		if functionID == "" {
-			a := utils.Extract_json_from_string(contentArguments)
-			if a != nil {
-				functionName = a["name"].(string)
-				argumentsJson, err := json.Marshal(a["arguments"])
-				if err != nil {
-					h.LogError(fmt.Sprintf("函数调用参数解析失败: %v", err))
-				}
-				functionArguments = string(argumentsJson)
-				functionID = uuid.New().String()
-			} else {
-				bHasError = true
-			}
-			if bHasError {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	h.turnStage(turnStageTTS)
+
+	// 模型不支持原生工具调用时，从 <tool_call> 文本中解析
+	if toolCallFlag && len(toolCalls) == 0 {
+		if a := utils.Extract_json_from_string(contentArguments); a != nil {
+			name, _ := a["name"].(string)
+			argumentsJson, err := json.Marshal(a["arguments"])
+			if err != nil {
 				h.LogError(fmt.Sprintf("函数调用参数解析失败: %v", err))
 			}
+			toolCalls = append(toolCalls, types.ToolCall{
+				ID:       uuid.New().String(),
+				Type:     "function",
+				Function: types.FunctionCall{Name: name, Arguments: string(argumentsJson)},
+			})
+		} else {
+			h.LogError(fmt.Sprintf("函数调用解析失败: %s", contentArguments))
 		}
-		if !bHasError {
-			// 清空responseMessage
-			responseMessage = []string{}
-			arguments := make(map[string]interface{})
-			if err := json.Unmarshal([]byte(functionArguments), &arguments); err != nil {
-				h.LogError(fmt.Sprintf("函数调用参数解析失败: %v", err))
-			}
-			functionCallData := map[string]interface{}{
-				"id":        functionID,
-				"name":      functionName,
-				"arguments": functionArguments,
-			}
-			h.LogInfo(fmt.Sprintf("函数调用: %v", arguments))
-			if h.mcpManager.IsMCPTool(functionName) {
-				// 处理MCP函数调用
-				result, err := h.mcpManager.ExecuteTool(ctx, functionName, arguments)
-				if err != nil {
-					h.LogError(fmt.Sprintf("MCP函数调用失败: %v", err))
-					if result == nil {
-						result = "MCP工具调用失败"
-					}
-				}
-				// 判断result 是否是types.ActionResponse类型
-				if actionResult, ok := result.(types.ActionResponse); ok {
-					h.handleFunctionResult(actionResult, functionCallData, textIndex)
-				} else {
-					h.LogInfo(fmt.Sprintf("MCP函数调用结果: %v", result))
-					actionResult := types.ActionResponse{
-						Action: types.ActionTypeReqLLM, // 动作类型
-						Result: result,                 // 动作产生的结果
-					}
-					h.handleFunctionResult(actionResult, functionCallData, textIndex)
-				}
+	}
 
-			} else {
-				// 处理普通函数调用
-				//h.functionRegister.CallFunction(functionName, functionCallData)
-			}
+	h.recorder.RecordTurn(sandbox.Turn{
+		Round:     round,
+		Messages:  messages,
+		Tools:     tools,
+		Output:    contentArguments,
+		ToolCalls: toolCalls,
+		LatencyMs: time.Since(llmStartTime).Milliseconds(),
+	})
+
+	if len(toolCalls) > 0 {
+		// 清空responseMessage
+		responseMessage = []string{}
+		h.executeToolCalls(ctx, toolCalls, textIndex)
+	}
+
+	// 输出后处理链缓存的文本
+	if !toolCallFlag {
+		if rest := pipeline.Flush(); rest != "" {
+			responseMessage = append(responseMessage, rest)
 		}
 	}
 
@@ -699,7 +961,7 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 		if remainingText != "" {
 			textIndex++
 			h.LogInfo(fmt.Sprintf("LLM回复分段[剩余文本]: %s, index: %d, round:%d", remainingText, textIndex, round))
-			h.tts_last_text_index = textIndex
+			h.setLastTextIndex(textIndex)
 			h.SpeakAndPlay(remainingText, textIndex, round)
 		}
 	} else {
@@ -715,11 +977,22 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 			Role:    "assistant",
 			Content: content,
 		})
+		h.expectAnswer(content)
+		cacheLookup.Store(content)
+		h.recordTurn(content, h.llmModelName(), round)
 	}
 
 	return nil
 }
 
+// usageReporter 按设备记录提供者上报的token用量
+func (h *ConnectionHandler) usageReporter(kind string) types.UsageReporter {
+	return func(u types.Usage) {
+		h.logger.Debug("%s token用量: 模型=%s, 输入=%d, 输出=%d", kind, u.Model, u.PromptTokens, u.CompletionTokens)
+		usage.Record(h.deviceID, kind, u)
+	}
+}
+
 func (h *ConnectionHandler) handleFunctionResult(result types.ActionResponse, functionCallData map[string]interface{}, textIndex int) {
 	switch result.Action {
 	case types.ActionTypeError:
@@ -737,40 +1010,17 @@ func (h *ConnectionHandler) handleFunctionResult(result types.ActionResponse, fu
 		h.LogInfo(fmt.Sprintf("函数调用后请求LLM: %v", result.Result))
 		text, ok := result.Result.(string)
 		if ok && len(text) > 0 {
-			functionID := functionCallData["id"].(string)
-			functionName := functionCallData["name"].(string)
-			functionArguments := functionCallData["arguments"].(string)
-			h.LogInfo(fmt.Sprintf("函数调用结果: %s", text))
-			h.LogInfo(fmt.Sprintf("函数调用参数: %s", functionArguments))
-			h.LogInfo(fmt.Sprintf("函数调用名称: %s", functionName))
-			h.LogInfo(fmt.Sprintf("函数调用ID: %s", functionID))
-
-			// 添加 assistant 消息，包含 tool_calls
-			h.dialogueManager.Put(chat.Message{
-				Role: "assistant",
-				ToolCalls: []types.ToolCall{{
-					ID: functionID,
-					Function: types.FunctionCall{
-						Arguments: functionArguments,
-						Name:      functionName,
-					},
-					Type:  "function",
-					Index: 0,
-				}},
-			})
-
-			// 添加 tool 消息
-			toolCallID := functionID
-			if toolCallID == "" {
-				toolCallID = uuid.New().String()
-			}
-			h.dialogueManager.Put(chat.Message{
-				Role:       "tool",
-				ToolCallID: toolCallID,
-				Content:    text,
-			})
-			h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.talkRound)
-
+			functionID, _ := functionCallData["id"].(string)
+			functionName, _ := functionCallData["name"].(string)
+			functionArguments, _ := functionCallData["arguments"].(string)
+			h.feedToolResults([]toolResult{{
+				call: types.ToolCall{
+					ID:       functionID,
+					Type:     "function",
+					Function: types.FunctionCall{Name: functionName, Arguments: functionArguments},
+				},
+				content: text,
+			}})
 		} else {
 			h.LogError(fmt.Sprintf("函数调用结果解析失败: %v", result.Result))
 			// 发送错误消息
@@ -789,8 +1039,8 @@ func (h *ConnectionHandler) SystemSpeak(text string) error {
 	index := 0
 	for _, item := range texts {
 		index++
-		h.tts_last_text_index = index // 重置文本索引
-		h.SpeakAndPlay(item, index, h.talkRound)
+		h.setLastTextIndex(index) // 重置文本索引
+		h.SpeakAndPlay(item, index, h.currentRound())
 	}
 	return nil
 }
@@ -807,19 +1057,7 @@ func (h *ConnectionHandler) isNeedAuth() bool {
 func (h *ConnectionHandler) checkAndBroadcastAuthCode() error {
 	// 这里简化了认证逻辑，实际需要根据具体需求实现
 	text := "请联系管理员进行设备认证"
-	return h.SpeakAndPlay(text, 0, h.talkRound)
-}
-
-// processTTSQueueCoroutine 处理TTS队列
-func (h *ConnectionHandler) processTTSQueueCoroutine() {
-	for {
-		select {
-		case <-h.stopChan:
-			return
-		case task := <-h.ttsQueue:
-			h.processTTSTask(task.text, task.textIndex, task.round)
-		}
-	}
+	return h.SpeakAndPlay(text, 0, h.currentRound())
 }
 
 // 服务端打断说话
@@ -846,6 +1084,12 @@ func (h *ConnectionHandler) deleteAudioFileIfNeeded(filepath string, reason stri
 		return
 	}
 
+	// 长文本合成文件由合成服务管理生命周期
+	if utils.IsLongformAudioFile(filepath) {
+		h.LogInfo(fmt.Sprintf(reason+" 跳过删除长文本合成文件: %s", filepath))
+		return
+	}
+
 	// 删除非缓存音频文件
 	if err := os.Remove(filepath); err != nil {
 		h.LogError(fmt.Sprintf(reason+" 删除音频文件失败: %v", err))
@@ -854,19 +1098,13 @@ func (h *ConnectionHandler) deleteAudioFileIfNeeded(filepath string, reason stri
 	}
 }
 
-// processTTSTask 处理单个TTS任务
-func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int) {
-	filepath := ""
+// processTTSTask 处理单个TTS任务，返回音频文件或音频流，以及下发给设备的文本
+func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int) (filepath string, stream <-chan []byte, outText string) {
 	defer func() {
-		h.audioMessagesQueue <- struct {
-			filepath  string
-			text      string
-			round     int
-			textIndex int
-		}{filepath, text, round, textIndex}
+		outText = text
 	}()
 
-	if utils.IsQuickReplyHit(text, h.config.QuickReplyWords) {
+	if h.isCachedPhrase(text) {
 		// 尝试从缓存查找音频文件
 		if cachedFile := h.quickReplyCache.FindCachedAudio(text); cachedFile != "" {
 			h.LogInfo(fmt.Sprintf("使用缓存的快速回复音频: %s", cachedFile))
@@ -883,15 +1121,46 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 		return
 	}
 
+	provider := h.ttsProvider()
+	if provider == nil {
+		h.logger.Warn("TTS提供者不可用(设备休眠中)，跳过合成, 索引: %d", textIndex)
+		return
+	}
+
+	if err := h.chaos.Inject(h.ctx, chaos.TargetTTS); err != nil {
+		h.LogError(fmt.Sprintf("TTS转换失败:text(%s) %v", text, err))
+		return
+	}
+
+	// 韵律标记按提供者能力转换为SSML或去除，下发给设备的文本不含标记
+	ttsText := tts.PrepareText(provider, text)
+	text = tts.StripProsodyMarkup(text)
+
+	// 支持流式合成的提供者，边合成边播放，快速回复词仍走文件以便缓存
+	if streamer, ok := provider.(tts.Provider); ok && !h.isCachedPhrase(text) {
+		audioStream, err := streamer.SynthesizeStream(h.ctx, ttsText)
+		if err == nil {
+			stream = audioStream
+			h.logger.Debug("TTS流式合成开始: text(%s), index(%d), 耗时: %s", text, textIndex, time.Since(ttsStartTime))
+			return
+		}
+		// 鉴权失败和额度耗尽时文件合成同样会失败，不再回退
+		if kind := types.ErrorKindOf(err); kind == types.ErrorKindAuth || kind == types.ErrorKindQuota {
+			h.LogError(fmt.Sprintf("TTS流式合成失败[%s]:text(%s) %v", kind, text, err))
+			return
+		}
+		h.LogError(fmt.Sprintf("TTS流式合成失败，回退到文件合成:text(%s) %v", text, err))
+	}
+
 	// 生成语音文件
-	filepath, err := h.providers.tts.ToTTS(text)
+	filepath, err := provider.ToTTS(ttsText)
 	if err != nil {
-		h.LogError(fmt.Sprintf("TTS转换失败:text(%s) %v", text, err))
+		h.LogError(fmt.Sprintf("TTS转换失败[%s]:text(%s) %v", types.ErrorKindOf(err), text, err))
 		return
 	} else {
 		h.logger.Debug(fmt.Sprintf("TTS转换成功: text(%s), index(%d) %s", text, textIndex, filepath))
 		// 如果是快速回复词，保存到缓存
-		if utils.IsQuickReplyHit(text, h.config.QuickReplyWords) {
+		if h.isCachedPhrase(text) {
 			if err := h.quickReplyCache.SaveCachedAudio(text, filepath); err != nil {
 				h.LogError(fmt.Sprintf("保存快速回复音频失败: %v", err))
 			} else {
@@ -911,7 +1180,7 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 		ttsSpentTime := now.Sub(ttsStartTime)
 		h.logger.Debug(fmt.Sprintf("TTS转换耗时: %s, 文本: %s, 索引: %d", ttsSpentTime, text, textIndex))
 	}
-
+	return
 }
 
 // speakAndPlay 合成并播放语音
@@ -927,8 +1196,8 @@ func (h *ConnectionHandler) SpeakAndPlay(text string, textIndex int, round int)
 
 	originText := text // 保存原始文本用于日志
 	text = utils.RemoveAllEmoji(text)
-	text = utils.RemoveMarkdownSyntax(text) // 移除Markdown语法
-	if text == "" {
+	text = tts.MapProsodyText(text, utils.RemoveMarkdownSyntax) // 移除Markdown语法，保留韵律标记
+	if tts.StripProsodyMarkup(text) == "" {
 		h.logger.Warn("SpeakAndPlay 收到空文本，无法合成语音, %d, text:%s.", textIndex, originText)
 		return errors.New("收到空文本，无法合成语音")
 	}
@@ -938,6 +1207,7 @@ func (h *ConnectionHandler) SpeakAndPlay(text string, textIndex int, round int)
 		text = ""
 		return errors.New("服务端语音已停止，无法合成语音")
 	}
+	h.markReplyEmotion(originText, textIndex, round)
 
 	if len(text) > 255 {
 		h.logger.Warn(fmt.Sprintf("文本过长，超过255字符限制，截断合成语音: %s", text))
@@ -949,8 +1219,10 @@ func (h *ConnectionHandler) SpeakAndPlay(text string, textIndex int, round int)
 
 func (h *ConnectionHandler) clearSpeakStatus() {
 	h.LogInfo("清除服务端讲话状态 ")
-	h.tts_last_text_index = -1
-	h.providers.asr.Reset() // 重置ASR状态
+	h.setLastTextIndex(-1)
+	if asr := h.asrProvider(); asr != nil {
+		asr.Reset() // 重置ASR状态
+	}
 }
 
 func (h *ConnectionHandler) closeOpusDecoder() {
@@ -967,6 +1239,9 @@ func (h *ConnectionHandler) cleanTTSAndAudioQueue(bClose bool) error {
 	if bClose {
 		msgPrefix = "关闭连接，"
 	}
+	// 使正在并行合成的结果失效
+	atomic.AddInt32(&h.ttsEpoch, 1)
+
 	// 终止tts任务，不再继续将文本加入到tts队列，清空ttsQueue队列
 	for {
 		select {
@@ -985,11 +1260,12 @@ clearAudioQueue:
 		select {
 		case task := <-h.audioMessagesQueue:
 			h.LogInfo(fmt.Sprintf(msgPrefix+"丢弃一个音频任务: %s", task.text))
-			// 根据配置删除被丢弃的音频文件
-			h.deleteAudioFileIfNeeded(task.filepath, msgPrefix+"丢弃音频任务时")
+			h.discardAudio(task.filepath, task.stream, msgPrefix+"丢弃音频任务时")
 		default:
 			// 队列已清空，退出循环
 			h.LogInfo(msgPrefix + "audioMessagesQueue队列已清空，停止处理音频任务")
+			// 被打断的回复不再输出编码器中剩余的音频
+			h.finishReplyEncoder(false)
 			return nil
 		}
 	}
@@ -999,18 +1275,51 @@ clearAudioQueue:
 func (h *ConnectionHandler) Close() {
 	h.closeOnce.Do(func() {
 		close(h.stopChan)
+		h.endTurn()
+		h.cancelReprompt()
 
 		h.closeOpusDecoder()
-		if h.providers.tts != nil {
-			h.providers.tts.SetVoice(h.initailVoice) // 恢复初始语音
-		}
-		if h.providers.asr != nil {
-			if err := h.providers.asr.Reset(); err != nil {
+		h.releaseVAD()
+		h.releaseWakeWord()
+		h.restoreTTSConfig() // 恢复初始语音和语速
+		if asr := h.asrProvider(); asr != nil {
+			if err := asr.Reset(); err != nil {
 				h.LogError(fmt.Sprintf("重置ASR状态失败: %v", err))
 			}
 		}
 		h.cleanTTSAndAudioQueue(true)
+		h.releaseBudget()
+		h.releaseRole()
+		h.releaseSpeaker()
+	})
+}
+
+// genResponseByVisionFallback 无法识别图片时，把失败原因和文字部分交给普通LLM回答
+func (h *ConnectionHandler) genResponseByVisionFallback(ctx context.Context, messages []providers.Message, text string, reason string, round int) error {
+	fallbackText := fmt.Sprintf("用户发送了一张图片并询问：%s\n（%s，你看不到这张图片。请先用一句话告诉用户暂时看不了图片，再尽量根据文字回答。）", text, reason)
+	fallbackMessages := append(messages, providers.Message{
+		Role:    "user",
+		Content: fallbackText,
 	})
+	h.turnQuestion = text
+	return h.genResponseByLLM(ctx, fallbackMessages, round)
+}
+
+// visionFailureReason 向LLM说明图片识别失败的原因，nil 表示没有配置VLLLM
+func visionFailureReason(err error) string {
+	if err == nil {
+		return "当前没有配置图片识别服务"
+	}
+	switch types.ErrorKindOf(err) {
+	case types.ErrorKindTimeout:
+		return "图片识别服务响应超时"
+	case types.ErrorKindQuota:
+		return "图片识别服务的调用额度已用完"
+	case types.ErrorKindAuth, types.ErrorKindNetwork, types.ErrorKindServer:
+		return "图片识别服务暂时不可用"
+	default:
+		return "图片识别失败"
+	}
 }
 
 // genResponseByVLLM 使用VLLLM处理包含图片的消息
@@ -1024,16 +1333,24 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 	})
 
 	// 使用VLLLM处理图片和文本
-	responses, err := h.providers.vlllm.ResponseWithImage(ctx, h.sessionID, messages, imageData, text)
+	vision := h.vlllmProvider()
+	if vision == nil {
+		return h.genResponseByVisionFallback(ctx, messages, text, visionFailureReason(nil), round)
+	}
+	ctx = types.WithUsageReporter(ctx, h.usageReporter("VLLLM"))
+	var responses <-chan string
+	var streamErrs <-chan error
+	err := h.chaos.Inject(ctx, chaos.TargetVLLLM)
+	if err == nil {
+		responses, streamErrs, err = vision.ResponseWithImage(ctx, h.sessionID, messages, imageData, text)
+	}
+	if err != nil && types.ErrorKindOf(err) == types.ErrorKindContentFilter {
+		// 内容被拦截时直接致歉，不再降级
+		return h.handleProviderError("VLLLM", err, round)
+	}
 	if err != nil {
 		h.LogError(fmt.Sprintf("VLLLM生成回复失败，尝试降级到普通LLM: %v", err))
-		// 降级策略：只使用文本部分调用普通LLM
-		fallbackText := fmt.Sprintf("用户发送了一张图片并询问：%s（注：当前无法处理图片，只能根据文字回答）", text)
-		fallbackMessages := append(messages, providers.Message{
-			Role:    "user",
-			Content: fallbackText,
-		})
-		return h.genResponseByLLM(ctx, fallbackMessages, round)
+		return h.genResponseByVisionFallback(ctx, messages, text, visionFailureReason(err), round)
 	}
 
 	// 处理VLLLM流式回复
@@ -1043,7 +1360,7 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 
 	atomic.StoreInt32(&h.serverVoiceStop, 0)
 
-	for response := range responses {
+	for response := range h.postProcess.Wrap(responses) {
 		if response == "" {
 			continue
 		}
@@ -1056,7 +1373,7 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 		// 按标点符号分割
 		if segment, chars := utils.SplitAtLastPunctuation(currentText); chars > 0 {
 			textIndex++
-			h.tts_last_text_index = textIndex
+			h.setLastTextIndex(textIndex)
 			h.SpeakAndPlay(segment, textIndex, round)
 			processedChars += chars
 		}
@@ -1066,18 +1383,32 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 	remainingText := utils.JoinStrings(responseMessage)[processedChars:]
 	if remainingText != "" {
 		textIndex++
-		h.tts_last_text_index = textIndex
+		h.setLastTextIndex(textIndex)
 		h.SpeakAndPlay(remainingText, textIndex, round)
 	}
 
 	// 获取完整回复内容
 	content := utils.JoinStrings(responseMessage)
 
+	if streamErr := <-streamErrs; streamErr != nil && ctx.Err() == nil {
+		// 尚未播报任何内容时改由普通LLM回答（内容被拦截时直接致歉），已播报部分内容时保留已有回复
+		if content == "" {
+			if types.ErrorKindOf(streamErr) == types.ErrorKindContentFilter {
+				return h.handleProviderError("VLLLM", streamErr, round)
+			}
+			h.LogError(fmt.Sprintf("VLLLM流式回复失败，尝试降级到普通LLM: %v", streamErr))
+			return h.genResponseByVisionFallback(ctx, messages, text, visionFailureReason(streamErr), round)
+		}
+		h.LogError(fmt.Sprintf("VLLLM流式回复中断，保留已播报的内容: %v", streamErr))
+	}
+
 	// 添加VLLLM回复到对话历史
 	h.dialogueManager.Put(chat.Message{
 		Role:    "assistant",
 		Content: content,
 	})
+	h.turnQuestion = text
+	h.recordTurn(content, vision.GetConfig().ModelName, round)
 
 	h.LogInfo(fmt.Sprintf("VLLLM回复处理完成 …%v", map[string]interface{}{
 		"content_length": len(content),
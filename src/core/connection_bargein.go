@@ -0,0 +1,61 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// trackServerSpeaking 根据下发的TTS状态记录服务端是否正在播报
+func (h *ConnectionHandler) trackServerSpeaking(state string) {
+	switch state {
+	case "start":
+		atomic.CompareAndSwapInt64(&h.serverSpeakSince, 0, time.Now().UnixMilli())
+	case "stop":
+		atomic.StoreInt64(&h.serverSpeakSince, 0)
+	}
+}
+
+// isServerSpeaking 服务端是否正在播报，且已超过打断保护时间
+func (h *ConnectionHandler) isServerSpeaking() bool {
+	since := atomic.LoadInt64(&h.serverSpeakSince)
+	if since == 0 {
+		return false
+	}
+	grace := time.Duration(h.config.BargeIn.GracePeriod) * time.Millisecond
+	return time.Since(time.UnixMilli(since)) >= grace
+}
+
+// checkBargeIn 服务端VAD检测到用户开口时调用，播报期间开口则打断当前轮次
+func (h *ConnectionHandler) checkBargeIn() {
+	if !h.config.BargeIn.Enabled || h.closeAfterChat || !h.isServerSpeaking() {
+		return
+	}
+	round := h.currentRound()
+	h.LogInfo(fmt.Sprintf("播报期间检测到用户说话，打断对话轮次 %d", round))
+	h.bargeIn()
+	if err := h.sendAbortMessage("barge_in"); err != nil {
+		h.LogError(fmt.Sprintf("发送中止消息失败: %v", err))
+	}
+	h.sendTTSMessage("stop", "", 0)
+}
+
+// bargeIn 结束当前轮次并重新开始识别，用户正在说的话作为新一轮对话的输入
+// 与按键打断不同，VAD保持在语音段中，说话结束时照常结束识别
+func (h *ConnectionHandler) bargeIn() {
+	h.startNewTurn(true)
+}
+
+// sendAbortMessage 通知设备立即停止播放已缓冲的音频
+func (h *ConnectionHandler) sendAbortMessage(reason string) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":       "abort",
+		"reason":     reason,
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化中止消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, data)
+}
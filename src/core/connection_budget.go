@@ -0,0 +1,183 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/usage"
+)
+
+const (
+	// budgetCheckInterval 预算检查结果的缓存时间，避免每轮对话都统计本月用量
+	budgetCheckInterval   = time.Minute
+	defaultDeclineMessage = "这个月的聊天额度已经用完啦，下个月再来找我聊天吧"
+	defaultFrameDuration  = 60 // 客户端未上报帧时长时的默认值（毫秒）
+)
+
+// budgetState 每月用量预算状态
+type budgetState struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	exceeded  bool
+	llm       llm.Provider // 超出预算后使用的降级LLM，首次使用时创建，连接关闭时释放
+	llmFailed bool         // 降级LLM创建失败，改为婉拒
+
+	asrAudioMs int64 // 尚未记录的识别音频时长（毫秒）
+	ttsAudioMs int64 // 尚未记录的合成音频时长（毫秒）
+}
+
+// budgetExceeded 设备本月用量是否超出预算，未开启预算或无法统计时视为未超出
+func (h *ConnectionHandler) budgetExceeded() bool {
+	cfg := h.config.Usage.Budget
+	if !cfg.Enabled || h.deviceID == "" || database.DB == nil {
+		return false
+	}
+	b := &h.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if time.Since(b.checkedAt) < budgetCheckInterval {
+		return b.exceeded
+	}
+
+	h.flushAudioUsage()
+	status, err := usage.CheckBudget(database.DB, cfg, h.deviceID)
+	if err != nil {
+		h.LogError(fmt.Sprintf("检查用量预算失败: %v", err))
+		return b.exceeded
+	}
+	if status.Exceeded && !b.exceeded {
+		h.LogInfo(fmt.Sprintf("设备本月用量已超出预算: %+v", status.Budgets))
+	}
+	b.checkedAt = time.Now()
+	b.exceeded = status.Exceeded
+	return b.exceeded
+}
+
+// budgetLLM 超出预算后使用的降级LLM，按 decline 处理或无法创建时返回nil
+func (h *ConnectionHandler) budgetLLM() providers.LLMProvider {
+	cfg := h.config.Usage.Budget
+	if cfg.Action == usage.BudgetActionDecline || cfg.FallbackLLM == "" {
+		return nil
+	}
+	b := &h.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.llm != nil || b.llmFailed {
+		return b.llm
+	}
+
+	provider, err := h.newLLMProvider(cfg.FallbackLLM)
+	if err != nil {
+		h.LogError(fmt.Sprintf("创建降级LLM失败，超出预算后婉拒对话: %v", err))
+		b.llmFailed = true
+		return nil
+	}
+	h.LogInfo(fmt.Sprintf("超出用量预算，切换到降级LLM: %s", cfg.FallbackLLM))
+	b.llm = provider
+	return provider
+}
+
+// newLLMProvider 按LLM配置名称创建连接独占的LLM，用完后需要调用 Cleanup
+func (h *ConnectionHandler) newLLMProvider(name string) (llm.Provider, error) {
+	llmCfg, ok := h.config.LLM[name]
+	if !ok {
+		return nil, fmt.Errorf("LLM配置 %s 不存在", name)
+	}
+	return llm.Create(llmCfg.Type, &llm.Config{
+		Type:        llmCfg.Type,
+		ModelName:   llmCfg.ModelName,
+		BaseURL:     llmCfg.BaseURL,
+		APIKey:      llmCfg.APIKey,
+		Temperature: llmCfg.Temperature,
+		MaxTokens:   llmCfg.MaxTokens,
+		TopP:        llmCfg.TopP,
+		Extra:       llmCfg.Extra,
+	})
+}
+
+// activeLLM 本轮使用的LLM：超出预算且配置了降级LLM时使用降级LLM，其次依次使用当前角色、识别到的用户指定的LLM
+func (h *ConnectionHandler) activeLLM() providers.LLMProvider {
+	if h.budgetExceeded() {
+		if provider := h.budgetLLM(); provider != nil {
+			return provider
+		}
+	}
+	if provider := h.roleLLM(); provider != nil {
+		return provider
+	}
+	if provider := h.speakerLLM(); provider != nil {
+		return provider
+	}
+	return h.llmProvider()
+}
+
+// declineOverBudget 超出预算且不能降级时婉拒本轮对话，返回 true 表示已婉拒
+func (h *ConnectionHandler) declineOverBudget(round int) bool {
+	if !h.budgetExceeded() || h.budgetLLM() != nil {
+		return false
+	}
+	message := h.config.Usage.Budget.DeclineMessage
+	if message == "" {
+		message = defaultDeclineMessage
+	}
+	h.LogInfo("设备本月用量已超出预算，婉拒本轮对话")
+	h.setLastTextIndex(1)
+	h.SpeakAndPlay(message, 1, round)
+	return true
+}
+
+// addAudioUsage 累计识别或合成的音频时长，开启预算时才统计
+func (h *ConnectionHandler) addAudioUsage(kind string, ms int) {
+	if !h.config.Usage.Budget.Enabled || ms <= 0 {
+		return
+	}
+	switch kind {
+	case "ASR":
+		atomic.AddInt64(&h.budget.asrAudioMs, int64(ms))
+	case "TTS":
+		atomic.AddInt64(&h.budget.ttsAudioMs, int64(ms))
+	}
+}
+
+// clientAudioMs 一个上行音频包的时长（毫秒），Opus每包为一帧，PCM按16位采样计算
+func (h *ConnectionHandler) clientAudioMs(data []byte) int {
+	if h.clientAudioFormat == "pcm" {
+		bytesPerMs := h.clientAudioSampleRate * max(h.clientAudioChannels, 1) * 2 / 1000
+		if bytesPerMs <= 0 {
+			return 0
+		}
+		return len(data) / bytesPerMs
+	}
+	if h.clientAudioFrameDuration > 0 {
+		return h.clientAudioFrameDuration
+	}
+	return defaultFrameDuration
+}
+
+// flushAudioUsage 把累计的音频时长写入用量记录
+func (h *ConnectionHandler) flushAudioUsage() {
+	if h.deviceID == "" {
+		return
+	}
+	usage.RecordAudio(h.deviceID, "ASR", "", atomic.SwapInt64(&h.budget.asrAudioMs, 0))
+	usage.RecordAudio(h.deviceID, "TTS", "", atomic.SwapInt64(&h.budget.ttsAudioMs, 0))
+}
+
+// releaseBudget 连接关闭时记录剩余的音频时长并释放降级LLM
+func (h *ConnectionHandler) releaseBudget() {
+	h.flushAudioUsage()
+	b := &h.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.llm != nil {
+		if err := b.llm.Cleanup(); err != nil {
+			h.LogError(fmt.Sprintf("释放降级LLM失败: %v", err))
+		}
+		b.llm = nil
+	}
+}
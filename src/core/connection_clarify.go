@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	defaultClarifyThreshold = 0.6
+	defaultClarifyPrompt    = "你是想说“%s”吗？"
+	defaultClarifyRetry     = "抱歉，我没听清，请再说一遍"
+)
+
+// captureAsrConfidence 记录本句识别结果的置信度，在识别结果交给 handleChatMessage 之前调用
+func (h *ConnectionHandler) captureAsrConfidence() {
+	h.asrConfidence, h.hasAsrConfidence = 0, false
+	if reporter, ok := h.asrProvider().(providers.AsrConfidenceReporter); ok {
+		h.asrConfidence, h.hasAsrConfidence = reporter.LastConfidence()
+	}
+}
+
+// resolveClarification 处理低置信度识别结果的确认
+// 返回应交给LLM的文本；handled 为 true 表示本句已处理（已向用户确认或请用户重说）
+func (h *ConnectionHandler) resolveClarification(text string) (string, bool) {
+	confidence, hasConfidence := h.asrConfidence, h.hasAsrConfidence
+	h.asrConfidence, h.hasAsrConfidence = 0, false
+	cfg := h.config.ASRClarify
+
+	if pending := h.clarifying; pending != "" {
+		// 上一句在等待用户确认
+		h.clarifying = ""
+		switch {
+		case utils.IsNegativeReply(text):
+			retry := cfg.Retry
+			if retry == "" {
+				retry = defaultClarifyRetry
+			}
+			h.SystemSpeak(retry)
+			return "", true
+		case utils.IsAffirmativeReply(text):
+			h.LogInfo(fmt.Sprintf("用户确认了识别结果: %s", pending))
+			return pending, false
+		}
+		// 用户直接换了说法，按新的一句处理
+	}
+
+	if !cfg.Enabled || !hasConfidence {
+		return text, false
+	}
+	threshold := cfg.Threshold
+	if threshold <= 0 {
+		threshold = defaultClarifyThreshold
+	}
+	if confidence >= threshold {
+		return text, false
+	}
+
+	h.LogInfo(fmt.Sprintf("识别置信度 %.2f 低于阈值 %.2f，向用户确认: %s", confidence, threshold, text))
+	prompt := cfg.Prompt
+	if prompt == "" {
+		prompt = defaultClarifyPrompt
+	}
+	if strings.Contains(prompt, "%s") {
+		prompt = fmt.Sprintf(prompt, text)
+	}
+	h.clarifying = text
+	h.turnStage(turnStageTTS)
+	h.SystemSpeak(prompt)
+	return "", true
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"xiaozhi-server-go/src/core/utils"
+)
+
+// mp3SampleRates MP3支持的采样率
+var mp3SampleRates = map[int]bool{
+	8000: true, 11025: true, 12000: true, 16000: true, 22050: true, 24000: true, 32000: true, 44100: true, 48000: true,
+}
+
+// negotiateAudioOutput 按设备在 hello 中声明支持的输出格式选择下发音频的格式和采样率
+// 示例: "audio_params":{"format":"opus","output_formats":[{"format":"opus","sample_rate":16000},{"format":"pcm","sample_rate":16000}]}
+// 设备没有声明或声明的格式都不支持时使用 audio_output 配置，上行为PCM的设备下发也使用PCM
+func (h *ConnectionHandler) negotiateAudioOutput(audioParams map[string]interface{}) {
+	cfg := h.config.AudioOutput
+	format, sampleRate := cfg.Format, cfg.SampleRate
+	if format == "" {
+		format = utils.AudioFormatOpus
+	}
+	if sampleRate <= 0 {
+		sampleRate = utils.DefaultOutputSampleRate
+	}
+	if h.clientAudioFormat == utils.AudioFormatPCM {
+		format = utils.AudioFormatPCM
+	}
+
+	offers, _ := audioParams["output_formats"].([]interface{})
+	for i, item := range offers {
+		offer, _ := item.(map[string]interface{})
+		offerFormat, _ := offer["format"].(string)
+		offerRate := sampleRate
+		if rate, ok := offer["sample_rate"].(float64); ok {
+			offerRate = int(rate)
+		}
+		// 服务端只输出单声道
+		if channels, ok := offer["channels"].(float64); ok && int(channels) != 1 {
+			continue
+		}
+		if h.supportsAudioOutput(offerFormat, offerRate) {
+			format, sampleRate = offerFormat, offerRate
+			break
+		}
+		if i == len(offers)-1 {
+			h.logger.Warn("设备声明的输出格式都不支持，使用默认格式: %v", offers)
+		}
+	}
+
+	h.serverAudioFormat = format
+	h.serverAudioSampleRate = sampleRate
+	h.serverAudioChannels = 1
+	h.LogInfo(fmt.Sprintf("下发音频参数: format=%s, sample_rate=%d, frame_duration=%d",
+		h.serverAudioFormat, h.serverAudioSampleRate, h.serverAudioFrameDuration))
+}
+
+// supportsAudioOutput 判断服务端能否按该格式和采样率下发音频
+func (h *ConnectionHandler) supportsAudioOutput(format string, sampleRate int) bool {
+	if allowed := h.config.AudioOutput.Formats; len(allowed) > 0 && !slices.Contains(allowed, format) {
+		return false
+	}
+	switch format {
+	case utils.AudioFormatOpus:
+		return utils.IsOpusSampleRate(sampleRate)
+	case utils.AudioFormatPCM, utils.AudioFormatWAV:
+		return sampleRate >= 8000 && sampleRate <= 48000
+	case utils.AudioFormatMP3:
+		return mp3SampleRates[sampleRate] && utils.FFmpegAvailable(h.config.AudioOutput.FFmpegPath)
+	}
+	return false
+}
+
+// audioOutput 当前连接下发音频的编码参数
+func (h *ConnectionHandler) audioOutput() utils.AudioOutput {
+	return utils.AudioOutput{
+		Format:     h.serverAudioFormat,
+		SampleRate: h.serverAudioSampleRate,
+		FFmpegPath: h.config.AudioOutput.FFmpegPath,
+	}
+}
+
+// replyEncoderState 一轮回复所有句子共用的下发编码器。MP3 需要启动 ffmpeg 进程，
+// 按句新建会为每句话启动一个进程，因此整轮回复共用一个，最后一句发送后再 Flush
+type replyEncoderState struct {
+	mu      sync.Mutex
+	encoder utils.FrameEncoder
+}
+
+// replyAudioOutput 发送回复音频使用的编码参数，MP3 下发时带上本轮共用的编码器
+func (h *ConnectionHandler) replyAudioOutput() utils.AudioOutput {
+	output := h.audioOutput()
+	if output.Format != utils.AudioFormatMP3 {
+		return output
+	}
+	s := &h.replyEncoder
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.encoder == nil {
+		encoder, err := utils.NewFrameEncoder(output)
+		if err != nil {
+			// 创建失败时不共用，由单句编码返回错误
+			h.LogError(fmt.Sprintf("创建回复音频编码器失败: %v", err))
+			return output
+		}
+		s.encoder = encoder
+	}
+	output.Encoder = s.encoder
+	return output
+}
+
+// finishReplyEncoder 本轮回复结束：正常结束时输出编码器缓冲中剩余的音频，被打断时直接丢弃
+func (h *ConnectionHandler) finishReplyEncoder(flush bool) {
+	s := &h.replyEncoder
+	s.mu.Lock()
+	encoder := s.encoder
+	s.encoder = nil
+	s.mu.Unlock()
+	if encoder == nil {
+		return
+	}
+	defer encoder.Close()
+	if !flush {
+		return
+	}
+	rest, err := encoder.Flush()
+	if err != nil {
+		h.LogError(fmt.Sprintf("结束回复音频编码失败: %v", err))
+	}
+	if len(rest) > 0 {
+		if err := h.conn.WriteMessage(2, rest); err != nil {
+			h.LogError(fmt.Sprintf("发送剩余音频数据失败: %v", err))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/types"
+)
+
+const (
+	defaultContextWindow     = 8000
+	defaultReserveTokens     = 1024
+	minContextBudget         = 512
+	summarizeTimeout         = 15 * time.Second
+	contextStrategySummarize = "summarize"
+	summarizeSystemPrompt    = "请用简洁的中文总结以下对话的要点，保留用户的偏好、提到的事实和未完成的事项，不超过200字。"
+)
+
+// llmDialogue 获取交给LLM的对话历史，轮次过多时先压缩较早的轮次，超过上下文窗口时再移除或压缩最早的轮次，
+// 并替换系统提示词中的模板变量、附加相关的长期记忆
+func (h *ConnectionHandler) llmDialogue(ctx context.Context) []providers.Message {
+	h.summarizeOldTurns(ctx)
+
+	cfg := h.config.ContextWindow
+	if !cfg.Enabled {
+		return h.withEmotion(h.withMemories(h.renderSystemPrompt(h.dialogueManager.GetLLMDialogue())))
+	}
+
+	var summarizer chat.Summarizer
+	if cfg.Strategy == contextStrategySummarize {
+		summarizer = h.summarizeDialogue
+	}
+	budget := h.contextBudget()
+	if dropped := h.dialogueManager.FitContext(ctx, budget, summarizer); dropped > 0 {
+		h.LogInfo(fmt.Sprintf("对话超过上下文预算 %d tokens，已移出最早的 %d 条消息（策略: %s）", budget, dropped, cfg.Strategy))
+	}
+	return h.withEmotion(h.withMemories(h.renderSystemPrompt(h.dialogueManager.GetLLMDialogue())))
+}
+
+// contextBudget 对话历史可用的token数：模型上下文窗口减去回复预留和工具定义
+func (h *ConnectionHandler) contextBudget() int {
+	cfg := h.config.ContextWindow
+	window := cfg.MaxTokens
+	reserve := cfg.ReserveTokens
+	if getter, ok := h.llmProvider().(interface{ Config() *llm.Config }); ok {
+		llmConfig := getter.Config()
+		if w, ok := cfg.Models[llmConfig.ModelName]; ok {
+			window = w
+		}
+		if llmConfig.MaxTokens > 0 {
+			reserve = llmConfig.MaxTokens
+		}
+	}
+	if window <= 0 {
+		window = defaultContextWindow
+	}
+	if reserve <= 0 {
+		reserve = defaultReserveTokens
+	}
+
+	budget := window - reserve
+	if tools := h.functionRegister.GetAllFunctions(); len(tools) > 0 {
+		if data, err := json.Marshal(tools); err == nil {
+			budget -= chat.EstimateTokens(string(data))
+		}
+	}
+	if budget < minContextBudget {
+		budget = minContextBudget
+	}
+	return budget
+}
+
+// summarizeDialogue 调用当前LLM把移出上下文的对话压缩为摘要
+func (h *ConnectionHandler) summarizeDialogue(ctx context.Context, previous string, messages []chat.Message) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("此前的摘要：" + previous + "\n")
+	}
+	for _, msg := range messages {
+		switch msg.Role {
+		case "user":
+			transcript.WriteString("用户：" + msg.Content + "\n")
+		case "assistant":
+			if msg.Content != "" {
+				transcript.WriteString("助手：" + msg.Content + "\n")
+			}
+		case "tool":
+			transcript.WriteString("工具结果：" + msg.Content + "\n")
+		}
+	}
+
+	provider := h.llmProvider()
+	if provider == nil {
+		return "", fmt.Errorf("LLM提供者不可用")
+	}
+	ctx, cancel := context.WithTimeout(types.WithUsageReporter(ctx, h.usageReporter("LLM")), summarizeTimeout)
+	defer cancel()
+	responses, err := provider.Response(ctx, h.sessionID, []providers.Message{
+		{Role: "system", Content: summarizeSystemPrompt},
+		{Role: "user", Content: transcript.String()},
+	})
+	if err != nil {
+		return "", fmt.Errorf("请求摘要失败: %v", err)
+	}
+	var summary strings.Builder
+	for chunk := range responses {
+		summary.WriteString(chunk)
+	}
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("请求摘要超时: %v", ctx.Err())
+	}
+	if summary.Len() == 0 {
+		return "", fmt.Errorf("摘要为空")
+	}
+	return strings.TrimSpace(summary.String()), nil
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+
+	"xiaozhi-server-go/src/core/emotion"
+	"xiaozhi-server-go/src/core/providers"
+)
+
+const defaultEnergyDeltaDB = 6
+
+// moodState 情绪识别状态：统计本句话的音量，记录本轮用户情绪和回复每句话的情绪
+type moodState struct {
+	mu       sync.Mutex
+	energy   emotion.Energy
+	baseline emotion.Baseline
+
+	user     string // 本轮用户的情绪，没有识别时为空
+	loudness string // 本轮说话音量与平时相比：loud、quiet 或空
+
+	round     int            // 下面的回复情绪所属的轮次
+	reply     string         // 最近一次下发的回复情绪
+	sentences map[int]string // 回复每句话的情绪，按句子序号
+}
+
+// collectEmotionAudio 累加上行音频的音量，识别本句话的情绪时与平时的音量比较
+func (h *ConnectionHandler) collectEmotionAudio(pcm []byte) {
+	if !h.config.Emotion.Enabled || !h.config.Emotion.Energy {
+		return
+	}
+	h.mood.mu.Lock()
+	h.mood.energy.Add(pcm)
+	h.mood.mu.Unlock()
+}
+
+// detectUserEmotion 识别用户本句话的情绪，文字聊天没有音频时只按文本判断
+func (h *ConnectionHandler) detectUserEmotion(text string) {
+	if !h.config.Emotion.Enabled {
+		return
+	}
+	delta := h.config.Emotion.EnergyDeltaDB
+	if delta <= 0 {
+		delta = defaultEnergyDeltaDB
+	}
+
+	m := &h.mood
+	m.mu.Lock()
+	m.user = emotion.Detect(text)
+	m.loudness = ""
+	if db, ok := m.energy.DB(); ok {
+		m.loudness = m.baseline.Compare(db, delta)
+	}
+	m.energy.Reset()
+	user, loudness := m.user, m.loudness
+	m.mu.Unlock()
+	if user != emotion.Neutral || loudness != "" {
+		h.logger.Debug("识别到用户情绪: %s %s", user, loudness)
+	}
+}
+
+// userEmotionName 本轮用户情绪的中文名称，作为提示词变量 {{user_emotion}}
+func (h *ConnectionHandler) userEmotionName() string {
+	h.mood.mu.Lock()
+	defer h.mood.mu.Unlock()
+	if h.mood.user == "" {
+		return "未知"
+	}
+	return emotion.Name(h.mood.user)
+}
+
+// withEmotion 把本轮用户的情绪附加到系统消息后，返回新的消息列表，不修改对话历史
+func (h *ConnectionHandler) withEmotion(messages []providers.Message) []providers.Message {
+	if !h.config.Emotion.PromptHint || len(messages) == 0 || messages[0].Role != "system" {
+		return messages
+	}
+	h.mood.mu.Lock()
+	user, loudness := h.mood.user, h.mood.loudness
+	h.mood.mu.Unlock()
+	if (user == "" || user == emotion.Neutral) && loudness == "" {
+		return messages
+	}
+
+	hint := "用户现在的情绪可能是：" + emotion.Name(user)
+	switch loudness {
+	case emotion.Loud:
+		hint += "，说话声音比平时大"
+	case emotion.Quiet:
+		hint += "，说话声音比平时小"
+	}
+	result := make([]providers.Message, len(messages))
+	copy(result, messages)
+	result[0].Content += "\n\n" + hint + "。回复时体谅用户的情绪，自然地调整语气，不要直接点明。"
+	return result
+}
+
+// markReplyEmotion 识别回复中一句话的情绪，与上一句不同时通知设备切换表情
+// 没有明显情绪的句子沿用上一句的情绪，sentence_start 消息携带该句的情绪
+func (h *ConnectionHandler) markReplyEmotion(text string, textIndex int, round int) {
+	if !h.config.Emotion.Enabled {
+		return
+	}
+	detected := emotion.Detect(text)
+
+	m := &h.mood
+	m.mu.Lock()
+	if m.round != round || m.sentences == nil {
+		m.round, m.reply, m.sentences = round, "", make(map[int]string)
+	}
+	if detected == emotion.Neutral && m.reply != "" {
+		detected = m.reply
+	}
+	m.sentences[textIndex] = detected
+	changed := detected != m.reply
+	m.reply = detected
+	m.mu.Unlock()
+
+	if changed {
+		if err := h.sendEmotionMessage(detected); err != nil {
+			h.LogError(fmt.Sprintf("发送回复情绪消息失败: %v", err))
+		}
+	}
+}
+
+// sentenceEmotion 当前轮次回复中一句话的情绪，没有记录时为空
+func (h *ConnectionHandler) sentenceEmotion(textIndex int) string {
+	h.mood.mu.Lock()
+	defer h.mood.mu.Unlock()
+	if h.mood.round != h.currentRound() {
+		return ""
+	}
+	return h.mood.sentences[textIndex]
+}
@@ -0,0 +1,250 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// eventProtocolVersion 客户端hello中 version 不低于该值时，下行文本消息使用事件信封
+	eventProtocolVersion = 2
+	// maxPendingEvents 每个设备最多保留的未确认关键事件数
+	maxPendingEvents = 64
+	// eventOutboxTTL 设备离线超过该时间后丢弃其未确认事件，重新从序号1开始
+	eventOutboxTTL = 10 * time.Minute
+)
+
+// Event 下行事件信封
+//
+//	{"v":2,"type":"tts","seq":12,"turn_id":3,"ack":true,"payload":{"state":"stop",...}}
+//
+// seq 在同一设备内单调递增（断线重连后延续），ack 为 true 的关键事件需要客户端回复
+// {"type":"ack","seq":12}，确认为累计确认，未确认的事件在客户端重连时重发
+type Event struct {
+	V          int                    `json:"v"`
+	Type       string                 `json:"type"`
+	Seq        uint64                 `json:"seq"`
+	TurnID     int                    `json:"turn_id"`
+	Ack        bool                   `json:"ack,omitempty"`
+	Retransmit bool                   `json:"retransmit,omitempty"`
+	Payload    map[string]interface{} `json:"payload"`
+}
+
+// isCriticalEvent 判断是否为需要确认的关键控制事件，流式文本等可丢失的消息不需要确认
+func isCriticalEvent(msgType string, payload map[string]interface{}) bool {
+	switch msgType {
+	case "session", "power", "intercom", "alert", "tts_config":
+		return true
+	case "tts":
+		state, _ := payload["state"].(string)
+		return state == "start" || state == "stop"
+	}
+	return false
+}
+
+// EventOutbox 单个设备的事件序号与未确认事件缓存
+type EventOutbox struct {
+	mu         sync.Mutex
+	lastSeq    uint64
+	pending    []Event
+	lastActive time.Time
+}
+
+// next 分配下一个序号，关键事件同时加入待确认缓存
+func (o *EventOutbox) next(event Event) Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.lastSeq++
+	event.Seq = o.lastSeq
+	o.lastActive = time.Now()
+	if event.Ack {
+		if len(o.pending) >= maxPendingEvents {
+			// 丢弃最早的未确认事件
+			o.pending = o.pending[1:]
+		}
+		o.pending = append(o.pending, event)
+	}
+	return event
+}
+
+// ack 累计确认序号不大于seq的事件
+func (o *EventOutbox) ack(seq uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	i := 0
+	for i < len(o.pending) && o.pending[i].Seq <= seq {
+		i++
+	}
+	o.pending = o.pending[i:]
+	o.lastActive = time.Now()
+}
+
+// unacked 返回当前未确认的事件副本
+func (o *EventOutbox) unacked() []Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	events := make([]Event, len(o.pending))
+	copy(events, o.pending)
+	return events
+}
+
+// EventStore 按设备保存事件发件箱，使序号和未确认事件在断线重连后延续
+type EventStore struct {
+	mu       sync.Mutex
+	outboxes map[string]*EventOutbox
+}
+
+// NewEventStore 创建事件存储
+func NewEventStore() *EventStore {
+	return &EventStore{outboxes: make(map[string]*EventOutbox)}
+}
+
+// Outbox 获取设备的发件箱，不存在或已过期时新建
+func (s *EventStore) Outbox(deviceID string) *EventOutbox {
+	if s == nil || deviceID == "" {
+		return &EventOutbox{lastActive: time.Now()}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, outbox := range s.outboxes {
+		outbox.mu.Lock()
+		expired := now.Sub(outbox.lastActive) > eventOutboxTTL
+		outbox.mu.Unlock()
+		if expired {
+			delete(s.outboxes, id)
+		}
+	}
+
+	outbox, ok := s.outboxes[deviceID]
+	if !ok {
+		outbox = &EventOutbox{lastActive: now}
+		s.outboxes[deviceID] = outbox
+	}
+	return outbox
+}
+
+// eventConn 连接装饰器，启用事件协议后将下行文本消息封装为事件信封
+type eventConn struct {
+	Connection
+	enabled int32 // 1表示客户端支持事件协议
+	outbox  *EventOutbox
+	turnID  func() int
+	writeMu sync.Mutex // 保证序号顺序与发送顺序一致
+}
+
+func newEventConn(conn Connection, outbox *EventOutbox, turnID func() int) *eventConn {
+	return &eventConn{Connection: conn, outbox: outbox, turnID: turnID}
+}
+
+// WriteMessage Connection接口实现
+func (c *eventConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != 1 || atomic.LoadInt32(&c.enabled) == 0 {
+		return c.Connection.WriteMessage(messageType, data)
+	}
+
+	msgType := "text"
+	payload := make(map[string]interface{})
+	if err := json.Unmarshal(data, &payload); err != nil {
+		// 非JSON文本按text事件下发
+		payload = map[string]interface{}{"text": string(data)}
+	} else {
+		msgType, _ = payload["type"].(string)
+		delete(payload, "type")
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	event := c.outbox.next(Event{
+		V:       eventProtocolVersion,
+		Type:    msgType,
+		TurnID:  c.turnID(),
+		Ack:     isCriticalEvent(msgType, payload),
+		Payload: payload,
+	})
+	return c.writeEvent(event)
+}
+
+func (c *eventConn) writeEvent(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化事件失败: %v", err)
+	}
+	return c.Connection.WriteMessage(1, data)
+}
+
+// enable 启用事件协议，lastSeq 为客户端重连前已收到的最大序号
+func (c *eventConn) enable(lastSeq uint64) {
+	if lastSeq > 0 {
+		c.outbox.ack(lastSeq)
+	}
+	atomic.StoreInt32(&c.enabled, 1)
+}
+
+// retransmit 重发未确认的关键事件
+func (c *eventConn) retransmit() (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	events := c.outbox.unacked()
+	for _, event := range events {
+		event.Retransmit = true
+		if err := c.writeEvent(event); err != nil {
+			return 0, err
+		}
+	}
+	return len(events), nil
+}
+
+// eventsEnabled 客户端是否已启用事件协议
+func (h *ConnectionHandler) eventsEnabled() bool {
+	return h.events != nil && atomic.LoadInt32(&h.events.enabled) == 1
+}
+
+// handleAckMessage 处理客户端对关键事件的确认
+func (h *ConnectionHandler) handleAckMessage(msgMap map[string]interface{}) error {
+	seq, ok := msgMap["seq"].(float64)
+	if !ok || seq < 0 {
+		return fmt.Errorf("ack消息缺少seq参数")
+	}
+	if h.events != nil {
+		h.events.outbox.ack(uint64(seq))
+	}
+	return nil
+}
+
+// negotiateEventProtocol 根据客户端hello协商事件协议，需在回复hello前调用
+func (h *ConnectionHandler) negotiateEventProtocol(msgMap map[string]interface{}) bool {
+	version, _ := msgMap["version"].(float64)
+	if h.events == nil || int(version) < eventProtocolVersion {
+		return false
+	}
+	lastSeq, _ := msgMap["last_seq"].(float64)
+	if lastSeq < 0 {
+		lastSeq = 0
+	}
+	h.events.enable(uint64(lastSeq))
+	h.LogInfo(fmt.Sprintf("客户端启用事件协议 v%d，last_seq=%d", eventProtocolVersion, uint64(lastSeq)))
+	return true
+}
+
+// retransmitEvents 重发客户端断线期间未确认的关键事件
+func (h *ConnectionHandler) retransmitEvents() {
+	n, err := h.events.retransmit()
+	if err != nil {
+		h.LogError(fmt.Sprintf("重发未确认事件失败: %v", err))
+		return
+	}
+	if n > 0 {
+		h.LogInfo(fmt.Sprintf("已重发未确认事件: %d 条", n))
+	}
+}
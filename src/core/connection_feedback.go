@@ -0,0 +1,81 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/feedback"
+	"xiaozhi-server-go/src/models"
+)
+
+// recordTurn 开启对话评价时保存本轮问答，供用户之后评价；开启 redaction.transcripts 时先脱敏
+func (h *ConnectionHandler) recordTurn(answer, model string, round int) {
+	if !h.config.Feedback.Enabled || answer == "" {
+		return
+	}
+	feedback.RecordTurn(models.ConversationTurn{
+		SessionID: h.sessionID,
+		DeviceID:  h.deviceID,
+		Round:     round,
+		Question:  h.transcripts.Redact(h.turnQuestion),
+		Answer:    h.transcripts.Redact(answer),
+		Model:     model,
+	})
+}
+
+// llmModelName 当前LLM的模型名称
+func (h *ConnectionHandler) llmModelName() string {
+	if getter, ok := h.activeLLM().(interface{ Config() *llm.Config }); ok {
+		return getter.Config().ModelName
+	}
+	return ""
+}
+
+// handleFeedbackMessage 处理设备对上一条回复的评价
+// 示例: {"type":"feedback","rating":"bad"}
+func (h *ConnectionHandler) handleFeedbackMessage(msgMap map[string]interface{}) error {
+	if !h.config.Feedback.Enabled {
+		return h.sendFeedbackMessage("error", map[string]interface{}{"message": "未开启对话评价"})
+	}
+	value, _ := msgMap["rating"].(string)
+	rating, err := feedback.ParseRating(value)
+	if err != nil {
+		return h.sendFeedbackMessage("error", map[string]interface{}{"message": err.Error()})
+	}
+	comment, _ := msgMap["comment"].(string)
+
+	turn, err := feedback.Rate(database.DB, feedback.Target{SessionID: h.sessionID}, rating, feedback.SourceDevice, comment)
+	if err != nil {
+		h.LogError(fmt.Sprintf("保存对话评价失败: %v", err))
+		return h.sendFeedbackMessage("error", map[string]interface{}{"message": err.Error()})
+	}
+	h.recorder.SetFeedback(turn.Round, rating)
+	h.LogInfo(fmt.Sprintf("用户评价了第 %d 轮回复: %s", turn.Round, rating))
+
+	if reply := h.config.Feedback.Reply; reply != "" && !h.isAsleep() {
+		h.SystemSpeak(reply)
+	}
+	return h.sendFeedbackMessage("success", map[string]interface{}{
+		"turn_id": turn.ID,
+		"rating":  rating,
+	})
+}
+
+// sendFeedbackMessage 发送评价结果
+func (h *ConnectionHandler) sendFeedbackMessage(state string, extra map[string]interface{}) error {
+	msg := map[string]interface{}{
+		"type":       "feedback",
+		"state":      state,
+		"session_id": h.sessionID,
+	}
+	for k, v := range extra {
+		msg[k] = v
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化feedback消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, data)
+}
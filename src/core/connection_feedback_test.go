@@ -0,0 +1,57 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTranscriptDB 使用临时数据库，返回开启对话记录脱敏的连接
+func setupTranscriptDB(t *testing.T, models ...interface{}) (*gorm.DB, *ConnectionHandler) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+
+	config := &configs.Config{}
+	config.Redaction = configs.RedactionConfig{Enabled: true, Transcripts: true}
+	redactor, err := utils.NewRedactor(config.Redaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, &ConnectionHandler{config: config, sessionID: "s1", deviceID: "aa:aa", transcripts: redactor}
+}
+
+func TestRecordTurnRedactsTranscript(t *testing.T) {
+	db, h := setupTranscriptDB(t, &models.ConversationTurn{})
+	h.config.Feedback.Enabled = true
+	h.turnQuestion = "我的手机号是13812345678"
+	h.recordTurn("好的，已记下13812345678", "qwen-plus", 1)
+
+	var turn models.ConversationTurn
+	deadline := time.Now().Add(2 * time.Second)
+	for db.First(&turn).Error != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("turn was not saved")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if turn.Question != "我的手机号是[电话]" || turn.Answer != "好的，已记下[电话]" {
+		t.Errorf("saved question %q, answer %q, want the phone number redacted", turn.Question, turn.Answer)
+	}
+}
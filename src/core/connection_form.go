@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/core/function"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/google/uuid"
+)
+
+// formTimeout 用户超过该时间没有回答时放弃参数收集，回到普通对话
+const formTimeout = 2 * time.Minute
+
+// startForm 工具声明了必填参数时检查LLM给出的参数，缺少参数或需要确认时开始多轮收集
+// 返回的话术不为空表示需要先向用户提问，否则 arguments 为校验转换后的参数
+func (h *ConnectionHandler) startForm(name string, arguments map[string]interface{}) (string, map[string]interface{}) {
+	spec, ok := h.functionRegister.GetSlots(name)
+	if !ok {
+		return "", arguments
+	}
+	form := function.NewForm(name, spec, arguments)
+	prompt := form.Prompt()
+	if prompt == "" {
+		return "", form.Arguments
+	}
+	h.form = form
+	h.LogInfo(fmt.Sprintf("函数 %s 参数不全或需要确认，开始收集: %v", name, form.Arguments))
+	h.dialogueManager.Put(chat.Message{Role: "assistant", Content: prompt})
+	h.expectAnswer(prompt)
+	return prompt, nil
+}
+
+// activeForm 获取进行中的参数收集，超时的表单直接丢弃
+func (h *ConnectionHandler) activeForm() *function.Form {
+	if h.form != nil && time.Since(h.form.Updated) > formTimeout {
+		h.LogInfo(fmt.Sprintf("函数 %s 参数收集超时，已放弃", h.form.Tool))
+		h.form = nil
+	}
+	return h.form
+}
+
+// handleFormAnswer 处理参数收集过程中用户的回答
+func (h *ConnectionHandler) handleFormAnswer(ctx context.Context, form *function.Form, text string) error {
+	h.dialogueManager.Put(chat.Message{Role: "user", Content: text})
+	h.turnStage(turnStageTTS)
+
+	result, reply := form.Answer(text)
+	switch result {
+	case function.FormCancelled:
+		h.form = nil
+		h.LogInfo(fmt.Sprintf("用户取消了函数 %s 的参数收集", form.Tool))
+		reply = "好的，已取消"
+	case function.FormReady:
+		h.form = nil
+		h.LogInfo(fmt.Sprintf("函数 %s 参数收集完成: %v", form.Tool, form.Arguments))
+		argumentsJson, err := json.Marshal(form.Arguments)
+		if err != nil {
+			h.LogError(fmt.Sprintf("函数调用参数序列化失败: %v", err))
+		}
+		h.handleFunctionResult(h.callLocalFunction(ctx, form.Tool, form.Arguments), map[string]interface{}{
+			"id":        uuid.New().String(),
+			"name":      form.Tool,
+			"arguments": string(argumentsJson),
+		}, 0)
+		return nil
+	}
+
+	h.dialogueManager.Put(chat.Message{Role: "assistant", Content: reply})
+	h.expectAnswer(reply)
+	return h.SystemSpeak(reply)
+}
+
+// callLocalFunction 执行本地函数，失败时把错误信息交回LLM
+func (h *ConnectionHandler) callLocalFunction(ctx context.Context, name string, arguments map[string]interface{}) types.ActionResponse {
+	result, err := h.functionRegister.CallFunction(ctx, name, arguments)
+	if err != nil {
+		h.LogError(fmt.Sprintf("函数 %s 执行失败: %v", name, err))
+		return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: fmt.Sprintf("函数执行失败: %v", err)}
+	}
+	return result
+}
@@ -0,0 +1,21 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/types"
+)
+
+// llmRetryDelay 可重试错误的重试间隔
+const llmRetryDelay = 500 * time.Millisecond
+
+// handleProviderError 按错误分类记录日志并播报对应的致歉语
+func (h *ConnectionHandler) handleProviderError(source string, err error, round int) error {
+	kind := types.ErrorKindOf(err)
+	h.logger.Error("%s服务错误, 分类: %s, 错误: %v", source, kind, err)
+
+	h.setLastTextIndex(1) // 重置文本索引
+	h.SpeakAndPlay(kind.SpokenMessage(), 1, round)
+	return fmt.Errorf("%s服务错误[%s]: %v", source, kind, err)
+}
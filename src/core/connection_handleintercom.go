@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+)
+
+// IntercomMessage 设备对讲消息
+type IntercomMessage struct {
+	FromDeviceID string
+	FromName     string // 发起设备在对讲分组中的名称
+	ToDeviceID   string
+	ToName       string
+	Text         string
+}
+
+// Intercom 设备对讲协调器，负责将消息投递到目标设备
+type Intercom interface {
+	SendIntercom(msg IntercomMessage) error
+}
+
+// mcp_handler_intercom 处理对讲工具调用，向同组的目标设备传话
+func (h *ConnectionHandler) mcp_handler_intercom(args interface{}) {
+	params, ok := args.(map[string]string)
+	if !ok {
+		h.logger.Error("mcp_handler_intercom: args is not a map[string]string")
+		return
+	}
+	target, text := params["target"], params["message"]
+	if text == "" {
+		h.SystemSpeak("要转告什么内容呢？")
+		return
+	}
+	if h.intercom == nil {
+		h.SystemSpeak("对讲功能未启用")
+		return
+	}
+
+	msg, err := h.resolveIntercom(target)
+	if err != nil {
+		h.LogError(fmt.Sprintf("对讲目标解析失败: %v", err))
+		h.SystemSpeak(err.Error())
+		return
+	}
+	msg.Text = text
+
+	if err := h.intercom.SendIntercom(msg); err != nil {
+		h.LogError(fmt.Sprintf("对讲消息发送失败: %v", err))
+		h.SystemSpeak(fmt.Sprintf("%s现在不在线，没能转告", target))
+		return
+	}
+	h.LogInfo(fmt.Sprintf("对讲消息已发送到 %s(%s)", msg.ToName, msg.ToDeviceID))
+	h.SystemSpeak(fmt.Sprintf("好的，已经转告%s", target))
+}
+
+// resolveIntercom 在当前设备所在的对讲分组中查找目标设备
+func (h *ConnectionHandler) resolveIntercom(target string) (IntercomMessage, error) {
+	for _, group := range h.config.Intercom.Groups {
+		fromName := ""
+		for name, deviceID := range group.Devices {
+			if deviceID == h.deviceID {
+				fromName = name
+				break
+			}
+		}
+		if fromName == "" {
+			continue
+		}
+		toDeviceID, ok := group.Devices[target]
+		if !ok {
+			continue
+		}
+		if toDeviceID == h.deviceID {
+			return IntercomMessage{}, fmt.Errorf("不能给自己传话")
+		}
+		return IntercomMessage{
+			FromDeviceID: h.deviceID,
+			FromName:     fromName,
+			ToDeviceID:   toDeviceID,
+			ToName:       target,
+		}, nil
+	}
+	return IntercomMessage{}, fmt.Errorf("没有找到叫%s的设备", target)
+}
+
+// receiveIntercom 接收其他设备的对讲消息，先推送文字，开启重新配音且设备未休眠时再语音播报
+func (h *ConnectionHandler) receiveIntercom(msg IntercomMessage, revoice bool) error {
+	if err := h.PushMessage(map[string]interface{}{
+		"type":           "intercom",
+		"from":           msg.FromName,
+		"from_device_id": msg.FromDeviceID,
+		"text":           msg.Text,
+	}, false); err != nil {
+		return err
+	}
+	if !revoice || h.isAsleep() {
+		return nil
+	}
+	return h.SpeakAnnouncement(fmt.Sprintf("%s说：%s", msg.FromName, msg.Text))
+}
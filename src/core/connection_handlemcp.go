@@ -12,11 +12,15 @@ func (h *ConnectionHandler) initMCPResultHandlers() {
 	// 初始化MCP结果处理器
 	// 这里可以添加更多的处理器初始化逻辑
 	h.mcpResultHandlers = map[string]func(args interface{}){
-		"mcp_handler_exit":         h.mcp_handler_exit,
-		"mcp_handler_take_photo":   h.mcp_handler_take_photo,
-		"mcp_handler_change_voice": h.mcp_handler_change_voice,
-		"mcp_handler_change_role":  h.mcp_handler_change_role,
-		"mcp_handler_play_music":   h.mcp_handler_play_music,
+		"mcp_handler_exit":          h.mcp_handler_exit,
+		"mcp_handler_take_photo":    h.mcp_handler_take_photo,
+		"mcp_handler_change_voice":  h.mcp_handler_change_voice,
+		"mcp_handler_change_role":   h.mcp_handler_change_role,
+		"mcp_handler_play_music":    h.mcp_handler_play_music,
+		"mcp_handler_intercom":      h.mcp_handler_intercom,
+		"mcp_handler_play_song":     h.mcp_handler_play_song,
+		"mcp_handler_music_control": h.mcp_handler_music_control,
+		"mcp_handler_draw_image":    h.mcp_handler_draw_image,
 	}
 }
 
@@ -52,7 +56,7 @@ func (h *ConnectionHandler) mcp_handler_play_music(args interface{}) {
 			h.SystemSpeak("没有找到名为" + songName + "的歌曲")
 		} else {
 			//h.SystemSpeak("这就为您播放音乐: " + songName)
-			h.sendAudioMessage(path, name, h.tts_last_text_index, h.talkRound)
+			h.sendAudioMessage(path, name, h.lastTextIndex(), h.currentRound())
 		}
 	} else {
 		h.logger.Error("mcp_handler_play_music: args is not a string")
@@ -62,7 +66,12 @@ func (h *ConnectionHandler) mcp_handler_play_music(args interface{}) {
 func (h *ConnectionHandler) mcp_handler_change_voice(args interface{}) {
 	if voice, ok := args.(string); ok {
 		h.logger.Info("mcp_handler_change_voice: %s", voice)
-		if err := h.providers.tts.SetVoice(voice); err != nil {
+		provider := h.ttsProvider()
+		if provider == nil {
+			h.logger.Error("mcp_handler_change_voice: TTS提供者不可用")
+			return
+		}
+		if err := provider.SetVoice(voice); err != nil {
 			h.logger.Error("mcp_handler_change_voice: SetVoice failed: %v", err)
 			h.SystemSpeak("切换语音失败，没有叫" + voice + "的音色")
 		} else {
@@ -79,17 +88,19 @@ func (h *ConnectionHandler) mcp_handler_change_role(args interface{}) {
 		prompt := params["prompt"]
 
 		h.logger.Info("mcp_handler_change_role: %s", role)
+		h.role = role
 		h.dialogueManager.SetSystemMessage(prompt)
 		h.dialogueManager.KeepRecentMessages(5) // 保留最近5条消息
-		if getter, ok := h.providers.tts.(configGetter); ok {
+		provider := h.ttsProvider()
+		if getter, ok := provider.(configGetter); ok {
 			ttsProvider := getter.Config().Type
 			if ttsProvider == "edge" {
 				if role == "陕西女友" {
-					h.providers.tts.SetVoice("zh-CN-shaanxi-XiaoniNeural") // 陕西女友音色
+					provider.SetVoice("zh-CN-shaanxi-XiaoniNeural") // 陕西女友音色
 				} else if role == "英语老师" {
-					h.providers.tts.SetVoice("zh-CN-XiaoyiNeural") // 英语老师音色
+					provider.SetVoice("zh-CN-XiaoyiNeural") // 英语老师音色
 				} else if role == "好奇小男孩" {
-					h.providers.tts.SetVoice("zh-CN-YunxiNeural") // 好奇小男孩音色
+					provider.SetVoice("zh-CN-YunxiNeural") // 好奇小男孩音色
 				}
 			}
 		}
@@ -118,7 +129,7 @@ func (h *ConnectionHandler) mcp_handler_take_photo(args interface{}) {
 
 	if !visionResponse.Success {
 		h.logger.Error("拍照失败: %s", visionResponse.Message)
-		h.genResponseByLLM(context.Background(), h.dialogueManager.GetLLMDialogue(), h.talkRound)
+		h.genResponseByLLM(context.Background(), h.llmDialogue(context.Background()), h.currentRound())
 
 	}
 
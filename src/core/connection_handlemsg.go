@@ -15,6 +15,9 @@ import (
 func (h *ConnectionHandler) handleMessage(messageType int, message []byte) error {
 	switch messageType {
 	case 1: // 文本消息
+		if h.handleInterruptIfAny(message) {
+			return nil
+		}
 		h.clientTextQueue <- string(message)
 		return nil
 	case 2: // 二进制消息（音频数据）
@@ -74,6 +77,26 @@ func (h *ConnectionHandler) processClientTextMessage(ctx context.Context, text s
 		return fmt.Errorf("消息类型错误")
 	}
 
+	// 休眠中的设备发来交互消息时自动唤醒
+	if h.isAsleep() {
+		switch msgType {
+		case "power", "mcp", "iot", "session", "ack", "context", "feedback":
+		default:
+			if err := h.exitSleep(); err != nil {
+				h.LogError(fmt.Sprintf("自动唤醒失败: %v", err))
+			}
+		}
+	}
+
+	// 维护公告会话不再开始新的对话
+	if h.maintenanceSession {
+		switch msgType {
+		case "listen", "chat", "image":
+			h.LogInfo(fmt.Sprintf("维护模式下忽略%s消息", msgType))
+			return nil
+		}
+	}
+
 	switch msgType {
 	case "hello":
 		return h.handleHelloMessage(msgMap)
@@ -91,6 +114,24 @@ func (h *ConnectionHandler) processClientTextMessage(ctx context.Context, text s
 		return h.handleImageMessage(ctx, msgMap)
 	case "mcp":
 		return h.mcpManager.HandleXiaoZhiMCPMessage(msgMap)
+	case "session":
+		return h.handleSessionMessage(msgMap)
+	case "tts_config":
+		return h.handleTTSConfigMessage(msgMap)
+	case "power":
+		return h.handlePowerMessage(msgMap)
+	case "ack":
+		return h.handleAckMessage(msgMap)
+	case "context":
+		return h.handleContextMessage(msgMap)
+	case "feedback":
+		return h.handleFeedbackMessage(msgMap)
+	case "music":
+		return h.handleMusicMessage(msgMap)
+	case "role":
+		return h.handleRoleMessage(msgMap)
+	case "translate":
+		return h.handleTranslateMessage(msgMap)
 	default:
 		h.logger.Warn("=== 未知消息类型 ===", map[string]interface{}{
 			"unknown_type": msgType,
@@ -115,13 +156,10 @@ func (h *ConnectionHandler) handleVisionMessage(msgMap map[string]interface{}) e
 func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) error {
 	h.LogInfo("收到客户端欢迎消息: " + fmt.Sprintf("%v", msgMap))
 	// 获取客户端编码格式
-	if audioParams, ok := msgMap["audio_params"].(map[string]interface{}); ok {
+	audioParams, ok := msgMap["audio_params"].(map[string]interface{})
+	if ok {
 		if format, ok := audioParams["format"].(string); ok {
 			h.clientAudioFormat = format
-			if format == "pcm" {
-				// 客户端使用PCM格式，服务端也使用PCM格式
-				h.serverAudioFormat = "pcm"
-			}
 		}
 		if sampleRate, ok := audioParams["sample_rate"].(float64); ok {
 			h.clientAudioSampleRate = int(sampleRate)
@@ -135,7 +173,12 @@ func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) er
 		h.LogInfo(fmt.Sprintf("客户端音频参数: format=%s, sample_rate=%d, channels=%d, frame_duration=%d",
 			h.clientAudioFormat, h.clientAudioSampleRate, h.clientAudioChannels, h.clientAudioFrameDuration))
 	}
+	h.negotiateAudioOutput(audioParams)
+	eventsEnabled := h.negotiateEventProtocol(msgMap)
 	h.sendHelloMessage()
+	if eventsEnabled {
+		h.retransmitEvents()
+	}
 	h.closeOpusDecoder()
 	// 初始化opus解码器
 	opusDecoder, err := utils.NewOpusDecoder(&utils.OpusDecoderConfig{
@@ -148,12 +191,37 @@ func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) er
 		h.opusDecoder = opusDecoder
 		h.LogInfo("Opus解码器初始化成功")
 	}
+	h.initVAD()
+	h.initWakeWord()
+
+	// 维护期间的新会话只播报公告
+	if h.announceMaintenance() {
+		return nil
+	}
+
+	// 检查是否有从其他设备转移过来的会话
+	h.checkTransferredSession()
+
+	// 应用设备个性化设置，新设备开始首次连接引导
+	h.loadDeviceProfile()
+
+	// 应用设备保存的角色
+	h.loadDeviceRole()
+	h.registerTranslationFunction()
+	h.registerReminderFunction()
+
+	// 接续该设备之前会话的对话摘要
+	h.loadPreviousSummary()
 
 	return nil
 }
 
 // handleListenMessage 处理语音相关消息
 func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) error {
+	// 文字聊天不涉及拾音状态，不改变设备的拾音模式
+	if mode, _ := msgMap["mode"].(string); mode == listenModeText {
+		return h.handleTextListen(msgMap)
+	}
 
 	// 处理state参数
 	state, ok := msgMap["state"].(string)
@@ -165,19 +233,29 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 	if mode, ok := msgMap["mode"].(string); ok {
 		h.clientListenMode = mode
 		h.LogInfo(fmt.Sprintf("客户端拾音模式：%s， %s", h.clientListenMode, state))
-		h.providers.asr.SetListener(h)
+		if asr := h.asrProvider(); asr != nil {
+			asr.SetListener(h)
+		}
 	}
 
 	switch state {
 	case "start":
-		if h.client_asr_text != "" && h.clientListenMode == "manual" {
+		if h.clientASRText() != "" && h.clientListenMode == "manual" {
 			h.clientAbortChat()
 		}
-		h.clientVoiceStop = false
-		h.client_asr_text = ""
+		h.setClientVoiceStop(false)
+		h.resetClientASRText()
+		h.resetVAD()
+		h.resetRecordingAudio()
+		if h.clientListenMode == listenModeWakeWord {
+			h.startWakeWordListen()
+		}
 	case "stop":
-		h.clientVoiceStop = true
+		h.setClientVoiceStop(true)
 		h.LogInfo("客户端停止语音识别")
+		if h.clientListenMode == "manual" {
+			h.turnStage(turnStageASR)
+		}
 	case "detect":
 		text, hasText := msgMap["text"].(string)
 
@@ -196,26 +274,10 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 	return nil
 }
 
-// handleIotMessage 处理IOT设备消息
-func (h *ConnectionHandler) handleIotMessage(msgMap map[string]interface{}) error {
-	if descriptors, ok := msgMap["descriptors"].([]interface{}); ok {
-		// 处理设备描述符
-		// 这里需要实现具体的IOT设备描述符处理逻辑
-		h.LogInfo(fmt.Sprintf("收到IOT设备描述符：%v", descriptors))
-	}
-	if states, ok := msgMap["states"].([]interface{}); ok {
-		// 处理设备状态
-		// 这里需要实现具体的IOT设备状态处理逻辑
-		h.LogInfo(fmt.Sprintf("收到IOT设备状态：%v", states))
-	}
-	return nil
-}
-
 // handleImageMessage 处理图片消息
 func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[string]interface{}) error {
 	// 增加对话轮次
-	h.talkRound++
-	currentRound := h.talkRound
+	currentRound := h.nextRound()
 	h.LogInfo(fmt.Sprintf("开始新的图片对话轮次: %d", currentRound))
 
 	// 判断是否需要验证
@@ -228,12 +290,6 @@ func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[s
 		return nil
 	}
 
-	// 检查是否有VLLLM Provider
-	if h.providers.vlllm == nil {
-		h.logger.Warn("未配置VLLLM服务，图片消息将被忽略")
-		return h.conn.WriteMessage(1, []byte("系统暂不支持图片处理功能"))
-	}
-
 	// 解析文本内容
 	text, ok := msgMap["text"].(string)
 	if !ok {
@@ -288,6 +344,12 @@ func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[s
 		h.logger.Error(fmt.Sprintf("发送思考状态情绪消息失败: %v", err))
 		return fmt.Errorf("发送情绪消息失败: %v", err)
 	}
+	h.detectUserEmotion(text)
+
+	// 超出用量预算且不能降级时婉拒
+	if h.declineOverBudget(currentRound) {
+		return nil
+	}
 
 	// 添加用户消息到对话历史（包含图片信息的描述）
 	userMessage := fmt.Sprintf("%s [用户发送了一张%s格式的图片]", text, imageData.Format)
@@ -308,6 +370,17 @@ func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[s
 			Content: msg.Content,
 		})
 	}
+	messages = h.renderSystemPrompt(messages)
 
+	// 没有VLLLM时只根据文字部分由普通LLM回答
+	if h.vlllmProvider() == nil {
+		h.logger.Warn("未配置VLLLM服务，图片消息降级到普通LLM")
+		return h.genResponseByVisionFallback(ctx, messages, text, visionFailureReason(nil), currentRound)
+	}
+	// 超出用量预算后不再调用视觉模型，由降级LLM根据文字部分回答
+	if h.budgetExceeded() {
+		h.LogInfo("设备本月用量已超出预算，图片消息降级到普通LLM")
+		return h.genResponseByVisionFallback(ctx, messages, text, "本月的图片识别额度已用完", currentRound)
+	}
 	return h.genResponseByVLLM(ctx, messages, imageData, text, currentRound)
 }
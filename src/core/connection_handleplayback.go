@@ -0,0 +1,42 @@
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// PlayAudioFile 打断当前播报，在设备上播放服务端生成的音频文件（如长文本合成结果）
+func (h *ConnectionHandler) PlayAudioFile(filepath string, text string) error {
+	if h.isAsleep() {
+		return fmt.Errorf("设备休眠中，无法播放")
+	}
+
+	h.stopServerSpeak()
+	round := h.nextRound()
+	atomic.StoreInt32(&h.serverVoiceStop, 0)
+
+	if err := h.sendTTSMessage("start", "", 0); err != nil {
+		return fmt.Errorf("发送TTS开始状态失败: %v", err)
+	}
+	h.setLastTextIndex(1)
+	h.LogInfo(fmt.Sprintf("开始播放推送音频: %s", filepath))
+	go h.sendAudioMessage(filepath, text, 1, round)
+	return nil
+}
+
+// SpeakAnnouncement 打断当前播报，合成并播报服务端主动下发的文本（如对讲消息）
+func (h *ConnectionHandler) SpeakAnnouncement(text string) error {
+	if h.isAsleep() {
+		return fmt.Errorf("设备休眠中，无法播报")
+	}
+
+	h.stopServerSpeak()
+	h.nextRound()
+	atomic.StoreInt32(&h.serverVoiceStop, 0)
+
+	if err := h.sendTTSMessage("start", "", 0); err != nil {
+		return fmt.Errorf("发送TTS开始状态失败: %v", err)
+	}
+	h.LogInfo(fmt.Sprintf("播报推送文本: %s", text))
+	return h.SystemSpeak(text)
+}
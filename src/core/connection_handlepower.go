@@ -0,0 +1,235 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"xiaozhi-server-go/src/core/pool"
+)
+
+const (
+	defaultKeepaliveInterval = 300 // 休眠期间默认心跳间隔（秒）
+	defaultMaxQueuedPushes   = 50
+)
+
+// PowerController 连接资源控制器，设备休眠时释放池化资源，唤醒时重新申请
+type PowerController interface {
+	ReleaseProviders() error
+	AcquireProviders() error
+}
+
+// handlePowerMessage 处理设备休眠/唤醒消息
+// sleep: 设备进入休眠，释放语音相关资源，进入低频心跳模式
+// wake: 设备唤醒，重新申请资源并下发休眠期间缓存的推送
+// keepalive: 休眠期间的低频心跳
+func (h *ConnectionHandler) handlePowerMessage(msgMap map[string]interface{}) error {
+	state, ok := msgMap["state"].(string)
+	if !ok {
+		return fmt.Errorf("power消息缺少state参数")
+	}
+
+	switch state {
+	case "sleep":
+		return h.enterSleep()
+	case "wake":
+		return h.exitSleep()
+	case "keepalive":
+		return h.sendPowerMessage("keepalive", nil)
+	default:
+		return fmt.Errorf("未知的power状态: %s", state)
+	}
+}
+
+// isAsleep 设备是否处于休眠状态
+func (h *ConnectionHandler) isAsleep() bool {
+	return atomic.LoadInt32(&h.asleep) == 1
+}
+
+// enterSleep 进入休眠状态
+func (h *ConnectionHandler) enterSleep() error {
+	if !atomic.CompareAndSwapInt32(&h.asleep, 0, 1) {
+		return h.sendPowerMessage("sleep", nil)
+	}
+
+	h.LogInfo("设备进入休眠状态，释放语音资源")
+	h.endTurn() // 取消进行中的LLM请求，让本轮尽快结束
+	h.stopServerSpeak()
+	h.setClientVoiceStop(true)
+
+	// 音频协程中的识别和对话、任务工作者中的合成可能仍在使用提供者，等待其结束后再归还
+	if !h.drainProviderUse() {
+		return nil // 连接已关闭，资源由连接清理时归还
+	}
+	if h.powerController != nil {
+		if err := h.powerController.ReleaseProviders(); err != nil {
+			h.LogError(fmt.Sprintf("休眠时释放资源失败: %v", err))
+		}
+	}
+
+	h.sleepStopChan = make(chan struct{})
+	go h.sleepKeepaliveCoroutine(h.sleepStopChan)
+
+	return h.sendPowerMessage("sleep", map[string]interface{}{
+		"keepalive_interval": h.keepaliveInterval(),
+	})
+}
+
+// exitSleep 退出休眠状态
+func (h *ConnectionHandler) exitSleep() error {
+	if !h.isAsleep() {
+		return nil
+	}
+
+	h.LogInfo("设备唤醒，重新申请语音资源")
+	// 先申请资源，失败时保持休眠状态，避免使用已归还的提供者
+	if h.powerController != nil {
+		if err := h.powerController.AcquireProviders(); err != nil {
+			h.LogError(fmt.Sprintf("唤醒时申请资源失败: %v", err))
+			return h.sendPowerMessage("error", map[string]interface{}{"message": "资源申请失败，请稍后重试"})
+		}
+	}
+
+	if h.sleepStopChan != nil {
+		close(h.sleepStopChan)
+		h.sleepStopChan = nil
+	}
+	atomic.StoreInt32(&h.serverVoiceStop, 0)
+	h.resumeProviderUse()
+	atomic.StoreInt32(&h.asleep, 0)
+
+	if err := h.sendPowerMessage("wake", nil); err != nil {
+		return err
+	}
+	h.flushQueuedPushes()
+	return nil
+}
+
+// sleepKeepaliveCoroutine 休眠期间的低频心跳检查，超过3个心跳间隔无任何消息则关闭连接
+func (h *ConnectionHandler) sleepKeepaliveCoroutine(stop chan struct{}) {
+	interval := time.Duration(h.keepaliveInterval()) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-h.stopChan:
+			return
+		case <-ticker.C:
+			if h.conn != nil && h.conn.IsStale(3*interval) {
+				h.LogInfo("休眠设备心跳超时，关闭连接")
+				h.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// bindProviders 绑定重新申请到的提供者，先恢复休眠前的配置再对其他协程可见
+func (h *ConnectionHandler) bindProviders(set *pool.ProviderSet) {
+	if set.ASR != nil {
+		set.ASR.SetListener(h)
+	}
+	// 恢复休眠前使用的语音和语速
+	if set.TTS != nil && h.sleepVoice != "" && h.sleepVoice != h.initailVoice {
+		set.TTS.SetVoice(h.sleepVoice)
+	}
+	if getter, ok := set.TTS.(configGetter); ok {
+		getter.Config().Speed = h.sleepSpeed
+	}
+
+	h.providerGuard.mu.Lock()
+	defer h.providerGuard.mu.Unlock()
+	h.providers.asr = set.ASR
+	h.providers.llm = set.LLM
+	h.providers.tts = set.TTS
+	h.providers.vlllm = set.VLLLM
+}
+
+// unbindProviders 解绑即将归还到池中的提供者，调用前进行中的操作已经结束
+func (h *ConnectionHandler) unbindProviders() {
+	h.sleepVoice = ""
+	h.sleepSpeed = h.initialSpeed
+	if getter, ok := h.ttsProvider().(configGetter); ok {
+		h.sleepVoice = getter.Config().Voice
+		h.sleepSpeed = getter.Config().Speed
+	}
+	h.restoreTTSConfig() // 归还前恢复初始语音和语速
+
+	h.providerGuard.mu.Lock()
+	defer h.providerGuard.mu.Unlock()
+	h.providers.asr = nil
+	h.providers.llm = nil
+	h.providers.tts = nil
+	h.providers.vlllm = nil
+}
+
+// PushMessage 向设备推送消息，设备休眠时非紧急消息会缓存到唤醒后再下发
+func (h *ConnectionHandler) PushMessage(msg map[string]interface{}, urgent bool) error {
+	if h.isAsleep() && !urgent {
+		h.pendingPushesMu.Lock()
+		defer h.pendingPushesMu.Unlock()
+
+		maxQueued := h.config.PowerSaving.MaxQueuedPushes
+		if maxQueued <= 0 {
+			maxQueued = defaultMaxQueuedPushes
+		}
+		if len(h.pendingPushes) >= maxQueued {
+			// 丢弃最早的推送
+			h.pendingPushes = h.pendingPushes[1:]
+		}
+		h.pendingPushes = append(h.pendingPushes, msg)
+		return nil
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化推送消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, data)
+}
+
+// flushQueuedPushes 下发休眠期间缓存的推送
+func (h *ConnectionHandler) flushQueuedPushes() {
+	h.pendingPushesMu.Lock()
+	pending := h.pendingPushes
+	h.pendingPushes = nil
+	h.pendingPushesMu.Unlock()
+
+	if len(pending) > 0 {
+		h.LogInfo(fmt.Sprintf("下发休眠期间缓存的推送: %d 条", len(pending)))
+	}
+	for _, msg := range pending {
+		if err := h.PushMessage(msg, true); err != nil {
+			h.LogError(fmt.Sprintf("下发缓存推送失败: %v", err))
+		}
+	}
+}
+
+// keepaliveInterval 休眠期间心跳间隔（秒）
+func (h *ConnectionHandler) keepaliveInterval() int {
+	if h.config.PowerSaving.KeepaliveInterval > 0 {
+		return h.config.PowerSaving.KeepaliveInterval
+	}
+	return defaultKeepaliveInterval
+}
+
+// sendPowerMessage 发送休眠状态消息
+func (h *ConnectionHandler) sendPowerMessage(state string, extra map[string]interface{}) error {
+	msg := map[string]interface{}{
+		"type":       "power",
+		"state":      state,
+		"session_id": h.sessionID,
+	}
+	for k, v := range extra {
+		msg[k] = v
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化power消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, data)
+}
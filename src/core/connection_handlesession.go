@@ -0,0 +1,145 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/chat"
+)
+
+// SessionTransferer 会话转移协调器，负责校验设备归属、暂存快照并通知目标设备
+type SessionTransferer interface {
+	// TransferSession 将会话快照转移到目标设备
+	TransferSession(snapshot *chat.SessionSnapshot) error
+	// TakeTransferredSession 取出转移到指定设备的会话快照
+	TakeTransferredSession(deviceID string) (*chat.SessionSnapshot, bool)
+	// HasTransferredSession 检查指定设备是否有待接续的会话
+	HasTransferredSession(deviceID string) bool
+}
+
+// handleSessionMessage 处理会话转移相关消息
+// transfer: 将当前会话转移到同一用户的另一台设备
+// resume: 在当前设备上接续转移过来的会话
+func (h *ConnectionHandler) handleSessionMessage(msgMap map[string]interface{}) error {
+	action, ok := msgMap["action"].(string)
+	if !ok {
+		return fmt.Errorf("session消息缺少action参数")
+	}
+
+	switch action {
+	case "transfer":
+		targetDeviceID, ok := msgMap["target_device_id"].(string)
+		if !ok || targetDeviceID == "" {
+			return fmt.Errorf("session消息缺少target_device_id参数")
+		}
+		return h.transferSession(targetDeviceID)
+	case "resume":
+		return h.resumeTransferredSession()
+	default:
+		return fmt.Errorf("未知的session动作: %s", action)
+	}
+}
+
+// transferSession 将当前对话上下文转移到目标设备
+func (h *ConnectionHandler) transferSession(targetDeviceID string) error {
+	if h.sessionTransferer == nil {
+		return h.sendSessionMessage("error", map[string]interface{}{"message": "服务端未启用会话转移"})
+	}
+	if h.deviceID == "" {
+		return h.sendSessionMessage("error", map[string]interface{}{"message": "当前连接缺少设备ID，无法转移会话"})
+	}
+	if targetDeviceID == h.deviceID {
+		return h.sendSessionMessage("error", map[string]interface{}{"message": "目标设备与当前设备相同"})
+	}
+
+	snapshot := &chat.SessionSnapshot{
+		SessionID:    h.sessionID,
+		FromDeviceID: h.deviceID,
+		ToDeviceID:   targetDeviceID,
+		Dialogue:     h.dialogueManager.Snapshot(),
+		CreatedAt:    time.Now(),
+	}
+	if err := h.sessionTransferer.TransferSession(snapshot); err != nil {
+		h.LogError(fmt.Sprintf("转移会话到设备 %s 失败: %v", targetDeviceID, err))
+		return h.sendSessionMessage("error", map[string]interface{}{"message": err.Error()})
+	}
+
+	h.LogInfo(fmt.Sprintf("会话已转移到设备 %s，消息数: %d", targetDeviceID, len(snapshot.Dialogue)))
+	// 当前设备停止播报，由目标设备接续
+	h.clientAbortChat()
+	return h.sendSessionMessage("transferred", map[string]interface{}{
+		"target_device_id": targetDeviceID,
+	})
+}
+
+// resumeTransferredSession 在当前设备上恢复转移过来的对话上下文
+func (h *ConnectionHandler) resumeTransferredSession() error {
+	if h.sessionTransferer == nil || h.deviceID == "" {
+		return nil
+	}
+
+	snapshot, ok := h.sessionTransferer.TakeTransferredSession(h.deviceID)
+	if !ok {
+		h.LogInfo("没有待接续的会话")
+		return nil
+	}
+
+	h.dialogueManager.Restore(snapshot.Dialogue)
+	h.LogInfo(fmt.Sprintf("已接续来自设备 %s 的会话，消息数: %d", snapshot.FromDeviceID, len(snapshot.Dialogue)))
+	// 设备休眠时作为非紧急推送，唤醒后再通知
+	return h.PushMessage(map[string]interface{}{
+		"type":           "session",
+		"state":          "resumed",
+		"session_id":     h.sessionID,
+		"from_device_id": snapshot.FromDeviceID,
+		"message_count":  len(snapshot.Dialogue),
+	}, false)
+}
+
+// checkTransferredSession 连接建立后检查是否有待接续的会话
+func (h *ConnectionHandler) checkTransferredSession() {
+	if h.sessionTransferer == nil || h.deviceID == "" {
+		return
+	}
+	if !h.sessionTransferer.HasTransferredSession(h.deviceID) {
+		return
+	}
+	if err := h.resumeTransferredSession(); err != nil {
+		h.LogError(fmt.Sprintf("接续会话失败: %v", err))
+	}
+}
+
+// notifySessionResume 通知当前连接接续会话，由消息处理协程执行，避免并发修改对话历史
+func (h *ConnectionHandler) notifySessionResume() bool {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":   "session",
+		"action": "resume",
+	})
+	if err != nil {
+		return false
+	}
+	select {
+	case h.clientTextQueue <- string(data):
+		return true
+	default:
+		return false
+	}
+}
+
+// sendSessionMessage 发送会话状态消息
+func (h *ConnectionHandler) sendSessionMessage(state string, extra map[string]interface{}) error {
+	msg := map[string]interface{}{
+		"type":       "session",
+		"state":      state,
+		"session_id": h.sessionID,
+	}
+	for k, v := range extra {
+		msg[k] = v
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化session消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, data)
+}
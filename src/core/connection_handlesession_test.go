@@ -0,0 +1,112 @@
+package core
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/models"
+	"xiaozhi-server-go/src/service"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTransferDB 创建临时数据库，登记设备A、B、C和两个用户
+func setupTransferDB(t *testing.T) (*models.User, *models.User) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserSetting{}, &models.Device{}); err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+
+	alice := &models.User{Username: "alice", Password: "x"}
+	bob := &models.User{Username: "bob", Password: "x"}
+	for _, user := range []*models.User{alice, bob} {
+		if err := db.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, deviceID := range []string{"aa:aa", "bb:bb", "cc:cc"} {
+		device := &models.Device{DeviceID: deviceID, SerialNumber: deviceID, ClientID: string(rune('a' + i))}
+		if err := db.Create(device).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return alice, bob
+}
+
+func TestTransferSessionBetweenBoundDevices(t *testing.T) {
+	alice, bob := setupTransferDB(t)
+	devices := service.NewDevice(&configs.Config{})
+	for deviceID, user := range map[string]*models.User{"aa:aa": alice, "bb:bb": alice, "cc:cc": bob} {
+		if err := devices.BindUser(deviceID, user.ID); err != nil {
+			t.Fatalf("bind %s: %v", deviceID, err)
+		}
+	}
+
+	ws := &WebSocketServer{sessionStore: chat.NewSessionStore(0)}
+	// 目标设备在线
+	target := &ConnectionHandler{deviceID: "bb:bb", clientTextQueue: make(chan string, 1)}
+	ws.activeConnections.Store("client-b", &ConnectionContext{handler: target})
+
+	dialogue := []chat.Message{{Role: "user", Content: "明天天气怎么样"}, {Role: "assistant", Content: "明天晴"}}
+	snapshot := &chat.SessionSnapshot{FromDeviceID: "aa:aa", ToDeviceID: "bb:bb", Dialogue: dialogue}
+	if err := ws.TransferSession(snapshot); err != nil {
+		t.Fatalf("TransferSession: %v", err)
+	}
+	if snapshot.UserID != alice.ID {
+		t.Errorf("snapshot user = %d, want %d", snapshot.UserID, alice.ID)
+	}
+
+	// 在线的目标设备收到接续通知
+	select {
+	case msg := <-target.clientTextQueue:
+		var parsed map[string]string
+		if err := json.Unmarshal([]byte(msg), &parsed); err != nil || parsed["type"] != "session" || parsed["action"] != "resume" {
+			t.Errorf("notification = %s", msg)
+		}
+	default:
+		t.Fatal("online target was not notified")
+	}
+	got, ok := ws.TakeTransferredSession("bb:bb")
+	if !ok || len(got.Dialogue) != len(dialogue) || got.Dialogue[1].Content != "明天晴" {
+		t.Fatalf("transferred session = %+v, %v", got, ok)
+	}
+
+	// 不同用户的设备之间不能转移
+	if err := ws.TransferSession(&chat.SessionSnapshot{FromDeviceID: "aa:aa", ToDeviceID: "cc:cc"}); err == nil {
+		t.Error("transfer to another user's device succeeded")
+	}
+
+	// 解绑后不能再转移
+	if err := devices.UnbindUser("aa:aa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.TransferSession(&chat.SessionSnapshot{FromDeviceID: "aa:aa", ToDeviceID: "bb:bb"}); err == nil {
+		t.Error("transfer from an unbound device succeeded")
+	}
+}
+
+func TestBindUserErrors(t *testing.T) {
+	alice, _ := setupTransferDB(t)
+	devices := service.NewDevice(&configs.Config{})
+	if err := devices.BindUser("aa:aa", alice.ID+100); err != service.ErrUserNotFound {
+		t.Errorf("unknown user: %v", err)
+	}
+	if err := devices.BindUser("ff:ff", alice.ID); err != service.ErrDeviceNotFound {
+		t.Errorf("unknown device: %v", err)
+	}
+	if userID, err := devices.DeviceUser("aa:aa"); err != nil || userID != 0 {
+		t.Errorf("DeviceUser before binding = %d, %v", userID, err)
+	}
+}
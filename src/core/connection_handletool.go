@@ -0,0 +1,162 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/core/rules"
+	"xiaozhi-server-go/src/core/tools"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/google/uuid"
+)
+
+// maxToolRounds 同一轮对话中连续交回LLM的工具调用次数上限，防止模型反复调用工具
+const maxToolRounds = 5
+
+// toolResult 需要交回LLM继续生成的工具调用结果
+type toolResult struct {
+	call    types.ToolCall
+	content string
+}
+
+// mergeToolCallDelta 合并流式返回的工具调用增量
+// 带有新ID的增量开始一个新的调用（支持并行调用），否则名称与参数追加到最后一个调用上
+func mergeToolCallDelta(calls []types.ToolCall, delta types.ToolCall) []types.ToolCall {
+	if len(calls) == 0 || (delta.ID != "" && delta.ID != calls[len(calls)-1].ID) {
+		if delta.Type == "" {
+			delta.Type = "function"
+		}
+		delta.Index = len(calls)
+		return append(calls, delta)
+	}
+	last := &calls[len(calls)-1]
+	if delta.Function.Name != "" {
+		last.Function.Name = delta.Function.Name
+	}
+	last.Function.Arguments += delta.Function.Arguments
+	return calls
+}
+
+// executeToolCalls 依次执行LLM返回的工具调用
+// 需要LLM继续生成的结果汇总后一次性写入对话历史，再请求一轮回复
+func (h *ConnectionHandler) executeToolCalls(ctx context.Context, calls []types.ToolCall, textIndex int) {
+	var pending []toolResult
+	for _, call := range calls {
+		if call.ID == "" {
+			call.ID = uuid.New().String()
+		}
+		arguments := make(map[string]interface{})
+		if call.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &arguments); err != nil {
+				h.LogError(fmt.Sprintf("函数调用参数解析失败: %v", err))
+			}
+		}
+		h.LogInfo(fmt.Sprintf("函数调用: %s %v", call.Function.Name, arguments))
+		h.rules.Fire(rules.Event{Type: rules.TriggerIntent, DeviceID: h.deviceID, Intent: call.Function.Name})
+
+		result := h.executeToolCall(ctx, call.Function.Name, arguments)
+		if result.Action == types.ActionTypeReqLLM {
+			if text, ok := result.Result.(string); ok && text != "" {
+				pending = append(pending, toolResult{call: call, content: text})
+				continue
+			}
+		}
+		h.handleFunctionResult(result, map[string]interface{}{
+			"id":        call.ID,
+			"name":      call.Function.Name,
+			"arguments": call.Function.Arguments,
+		}, textIndex)
+	}
+
+	if len(pending) > 0 {
+		h.feedToolResults(pending)
+	}
+}
+
+// executeToolCall 执行单个工具：MCP工具交给MCP管理器，函数插件等本地函数交给函数注册表
+// 未知函数或执行失败时把错误信息交回LLM，由模型决定如何回复用户
+func (h *ConnectionHandler) executeToolCall(ctx context.Context, name string, arguments map[string]interface{}) types.ActionResponse {
+	ctx = types.WithDeviceID(ctx, h.deviceID)
+	ctx = tools.WithDevice(ctx, h.deviceStatus())
+	if h.mcpManager != nil && h.mcpManager.IsMCPTool(name) {
+		result, err := h.mcpManager.ExecuteTool(ctx, name, arguments)
+		if err != nil {
+			h.LogError(fmt.Sprintf("MCP函数调用失败: %v", err))
+			if result == nil {
+				result = "MCP工具调用失败"
+			}
+		}
+		if actionResult, ok := result.(types.ActionResponse); ok {
+			return actionResult
+		}
+		h.LogInfo(fmt.Sprintf("MCP函数调用结果: %v", result))
+		text, ok := result.(string)
+		if !ok {
+			data, _ := json.Marshal(result)
+			text = string(data)
+		}
+		return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: text}
+	}
+
+	if h.functionRegister.HasHandler(name) {
+		prompt, arguments := h.startForm(name, arguments)
+		if prompt != "" {
+			return types.ActionResponse{Action: types.ActionTypeResponse, Response: prompt}
+		}
+		return h.callLocalFunction(ctx, name, arguments)
+	}
+
+	h.LogError(fmt.Sprintf("函数未找到: %s", name))
+	return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: fmt.Sprintf("函数 %s 不存在", name)}
+}
+
+// deviceStatus 当前设备的连接信息和设备上报的运行时信息
+func (h *ConnectionHandler) deviceStatus() tools.Device {
+	return tools.Device{
+		ID:          h.deviceID,
+		ClientID:    h.clientId,
+		ConnectedAt: h.connectedAt,
+		ListenMode:  h.clientListenMode,
+		Info:        maps.Clone(h.promptVars),
+	}
+}
+
+// feedToolResults 将工具调用及结果写入对话历史，并请求LLM基于结果继续生成
+func (h *ConnectionHandler) feedToolResults(results []toolResult) {
+	if h.toolRoundsTurn != h.currentRound() {
+		h.toolRoundsTurn = h.currentRound()
+		h.toolRounds = 0
+	}
+	h.toolRounds++
+	if h.toolRounds > maxToolRounds {
+		h.LogError(fmt.Sprintf("工具调用次数超过上限 %d，停止继续请求LLM", maxToolRounds))
+		h.SystemSpeak("抱歉，这个问题我暂时处理不了")
+		return
+	}
+
+	calls := make([]types.ToolCall, len(results))
+	for i, r := range results {
+		calls[i] = r.call
+		calls[i].Type = "function"
+		calls[i].Index = i
+	}
+	// 添加 assistant 消息，包含 tool_calls
+	h.dialogueManager.Put(chat.Message{
+		Role:      "assistant",
+		ToolCalls: calls,
+	})
+	// 每个调用对应一条 tool 消息
+	for _, r := range results {
+		h.LogInfo(fmt.Sprintf("函数调用结果: %s -> %s", r.call.Function.Name, r.content))
+		h.dialogueManager.Put(chat.Message{
+			Role:       "tool",
+			ToolCallID: r.call.ID,
+			Content:    r.content,
+		})
+	}
+	ctx := h.turnContext()
+	h.genResponseByLLM(ctx, h.llmDialogue(ctx), h.currentRound())
+}
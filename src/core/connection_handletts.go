@@ -0,0 +1,97 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+// speedSetter 支持调整语速的TTS提供者
+type speedSetter interface {
+	SetSpeed(speed float64) error
+}
+
+// handleTTSConfigMessage 处理 tts_config 消息，仅修改当前连接使用的TTS提供者的音色和语速
+// 示例: {"type":"tts_config","voice":"zh-CN-XiaoxiaoNeural","speed":1.2}
+func (h *ConnectionHandler) handleTTSConfigMessage(msgMap map[string]interface{}) error {
+	provider := h.ttsProvider()
+	if provider == nil {
+		return h.sendTTSConfigMessage("error", map[string]interface{}{"message": "TTS提供者不可用"})
+	}
+
+	voice, hasVoice := msgMap["voice"].(string)
+	speed, hasSpeed := msgMap["speed"].(float64)
+	if !hasVoice && !hasSpeed {
+		return fmt.Errorf("tts_config消息缺少voice或speed参数")
+	}
+
+	if hasVoice {
+		if err := provider.SetVoice(voice); err != nil {
+			h.LogError(fmt.Sprintf("切换音色失败: %v", err))
+			return h.sendTTSConfigMessage("error", map[string]interface{}{"message": err.Error()})
+		}
+	}
+
+	if hasSpeed {
+		setter, ok := provider.(speedSetter)
+		if !ok {
+			return h.sendTTSConfigMessage("error", map[string]interface{}{"message": "当前TTS提供者不支持调整语速"})
+		}
+		if err := setter.SetSpeed(speed); err != nil {
+			h.LogError(fmt.Sprintf("调整语速失败: %v", err))
+			return h.sendTTSConfigMessage("error", map[string]interface{}{"message": err.Error()})
+		}
+	}
+
+	current := map[string]interface{}{}
+	if getter, ok := provider.(configGetter); ok {
+		current["voice"] = getter.Config().Voice
+		current["speed"] = getter.Config().Speed
+		// 音色或语速变化后快速回复缓存需要按新参数区分
+		h.quickReplyCache = quickReplyCacheFor(provider)
+		h.prewarmQuickReplies()
+	}
+	h.LogInfo(fmt.Sprintf("已更新连接TTS配置: %v", current))
+	return h.sendTTSConfigMessage("success", current)
+}
+
+// quickReplyCacheFor 按TTS提供者当前的音色和语速创建快速回复缓存
+func quickReplyCacheFor(provider providers.TTSProvider) *utils.QuickReplyCache {
+	if getter, ok := provider.(configGetter); ok {
+		return utils.NewQuickReplyCache(getter.Config().Type, getter.Config().Voice, getter.Config().Speed)
+	}
+	return utils.NewQuickReplyCache("default", "default", 0)
+}
+
+// restoreTTSConfig 恢复初始音色和语速，提供者归还到池中前调用
+func (h *ConnectionHandler) restoreTTSConfig() {
+	provider := h.ttsProvider()
+	if provider == nil {
+		return
+	}
+	if getter, ok := provider.(configGetter); ok {
+		if getter.Config().Voice != h.initailVoice {
+			provider.SetVoice(h.initailVoice)
+		}
+		getter.Config().Speed = h.initialSpeed
+	}
+}
+
+// sendTTSConfigMessage 发送TTS配置结果消息
+func (h *ConnectionHandler) sendTTSConfigMessage(state string, extra map[string]interface{}) error {
+	msg := map[string]interface{}{
+		"type":       "tts_config",
+		"state":      state,
+		"session_id": h.sessionID,
+	}
+	for k, v := range extra {
+		msg[k] = v
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化tts_config消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, data)
+}
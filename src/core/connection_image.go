@@ -0,0 +1,29 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"xiaozhi-server-go/src/task"
+)
+
+// mcp_handler_draw_image 提交画图任务，图片在后台生成，画好后推送到设备
+func (h *ConnectionHandler) mcp_handler_draw_image(args interface{}) {
+	prompt, _ := args.(string)
+	if h.images == nil || h.deviceID == "" {
+		h.SystemSpeak("还没有配置画图功能")
+		return
+	}
+	if err := h.images.Draw(h.deviceID, prompt); err != nil {
+		h.LogError(fmt.Sprintf("提交画图任务失败: %v", err))
+		var quotaErr *task.QuotaError
+		if errors.As(err, &quotaErr) {
+			h.SystemSpeak(taskRejectionMessage(quotaErr))
+			return
+		}
+		h.SystemSpeak("现在没法画图，请稍后再试")
+		return
+	}
+	h.LogInfo(fmt.Sprintf("提交画图任务: %s", prompt))
+	h.SystemSpeak("好的，我这就开始画，画好了告诉你")
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"xiaozhi-server-go/src/core/types"
+)
+
+// interruptMessage 设备打断指令，只解析判断消息类型需要的字段
+type interruptMessage struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// handleInterruptIfAny 设备打断指令在读取协程中直接处理，不进入文本消息队列，
+// 避免排在正在执行的对话（如 chat 消息触发的LLM请求）之后
+func (h *ConnectionHandler) handleInterruptIfAny(message []byte) bool {
+	var msg interruptMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Type != "interrupt" {
+		return false
+	}
+	reason := msg.Reason
+	if reason == "" {
+		reason = "button"
+	}
+	round := h.currentRound()
+	h.LogInfo(fmt.Sprintf("收到设备打断指令(%s)，结束对话轮次 %d", reason, round))
+	h.interruptTurn()
+	if err := h.sendInterruptMessage(round); err != nil {
+		h.LogError(fmt.Sprintf("发送打断结果失败: %v", err))
+	}
+	return true
+}
+
+// interruptTurn 立即结束当前轮次：取消LLM请求、丢弃合成与待播放的音频、重置识别状态
+// 与语音打断（abort）不同，轮次号随之递增，已在途的旧轮次输出都会被丢弃
+func (h *ConnectionHandler) interruptTurn() {
+	h.startNewTurn(false)
+	h.sendTTSMessage("stop", "", 0)
+}
+
+// sendInterruptMessage 通知设备打断已完成
+func (h *ConnectionHandler) sendInterruptMessage(round int) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":       "interrupt",
+		"state":      "done",
+		"turn_id":    round,
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化打断结果失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, data)
+}
+
+// drainResponses 提前结束读取LLM流式响应时取走剩余数据，避免提供者的发送协程阻塞
+func drainResponses(responses <-chan types.Response) {
+	go func() {
+		for range responses {
+		}
+	}()
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"xiaozhi-server-go/src/core/iot"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// handleIotMessage 处理设备上报的IoT外设描述和状态
+// 示例: {"type":"iot","update":true,"descriptors":[{"name":"Speaker","description":"扬声器","properties":{...},"methods":{...}}]}
+// 示例: {"type":"iot","update":true,"states":[{"name":"Speaker","state":{"volume":50}}]}
+func (h *ConnectionHandler) handleIotMessage(msgMap map[string]interface{}) error {
+	if raw, ok := msgMap["descriptors"].([]interface{}); ok {
+		descriptors, err := iot.Parse[iot.Descriptor](raw)
+		if err != nil {
+			return fmt.Errorf("解析IoT设备描述失败: %v", err)
+		}
+		for _, descriptor := range descriptors {
+			h.registerIotThing(descriptor)
+		}
+	}
+	if raw, ok := msgMap["states"].([]interface{}); ok {
+		states, err := iot.Parse[iot.State](raw)
+		if err != nil {
+			return fmt.Errorf("解析IoT设备状态失败: %v", err)
+		}
+		for _, state := range states {
+			if state.Name == "" {
+				continue
+			}
+			h.iotThings.UpdateState(state)
+			h.logger.Debug("IoT设备状态更新: %s %v", state.Name, state.State)
+		}
+	}
+	return nil
+}
+
+// registerIotThing 保存外设描述，把外设的每个方法注册为LLM函数，有属性时再注册一个查询状态的函数
+// 设备重新上报同名外设时先注销之前注册的函数
+func (h *ConnectionHandler) registerIotThing(descriptor iot.Descriptor) {
+	if descriptor.Name == "" {
+		return
+	}
+	if previous, ok := h.iotThings.Descriptor(descriptor.Name); ok {
+		for method := range previous.Methods {
+			h.functionRegister.UnregisterFunction(iot.ToolName(previous.Name, method))
+		}
+		h.functionRegister.UnregisterFunction(iot.StateToolName(previous.Name))
+	}
+	h.iotThings.SetDescriptor(descriptor)
+
+	title := descriptor.Description
+	if title == "" {
+		title = descriptor.Name
+	}
+	names := make([]string, 0, len(descriptor.Methods)+1)
+	for methodName, method := range descriptor.Methods {
+		name := iot.ToolName(descriptor.Name, methodName)
+		tool := openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        name,
+				Description: title + " - " + method.Description,
+				Parameters:  method.Schema(),
+			},
+		}
+		if err := h.functionRegister.RegisterFunctionWithHandler(name, tool, h.iotMethodHandler(descriptor.Name, methodName)); err != nil {
+			h.LogError(fmt.Sprintf("注册IoT函数 %s 失败: %v", name, err))
+			continue
+		}
+		names = append(names, name)
+	}
+
+	if len(descriptor.Properties) > 0 {
+		name := iot.StateToolName(descriptor.Name)
+		properties := make([]string, 0, len(descriptor.Properties))
+		for _, property := range descriptor.Properties {
+			properties = append(properties, property.Description)
+		}
+		sort.Strings(properties)
+		tool := openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        name,
+				Description: "查询" + title + "的当前状态：" + strings.Join(properties, "、"),
+				Parameters:  map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
+			},
+		}
+		thing := descriptor.Name
+		handler := func(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error) {
+			return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: h.iotThings.Describe(thing)}, nil
+		}
+		if err := h.functionRegister.RegisterFunctionWithHandler(name, tool, handler); err != nil {
+			h.LogError(fmt.Sprintf("注册IoT函数 %s 失败: %v", name, err))
+		} else {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	h.LogInfo(fmt.Sprintf("IoT设备 %s（%s）注册函数: %v", descriptor.Name, title, names))
+}
+
+// iotMethodHandler 外设方法对应的函数逻辑：校验参数后向设备下发指令，由LLM组织回复
+func (h *ConnectionHandler) iotMethodHandler(thing, methodName string) func(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error) {
+	return func(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error) {
+		descriptor, ok := h.iotThings.Descriptor(thing)
+		method, exists := descriptor.Methods[methodName]
+		if !ok || !exists {
+			return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: "设备已不支持该操作"}, nil
+		}
+		parameters, err := method.Arguments(arguments)
+		if err != nil {
+			return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: err.Error()}, nil
+		}
+
+		command := iot.Command{Name: thing, Method: methodName, Parameters: parameters}
+		if err := h.sendIotCommands(command); err != nil {
+			return types.ActionResponse{}, fmt.Errorf("下发IoT指令失败: %v", err)
+		}
+		h.LogInfo(fmt.Sprintf("下发IoT指令: %s.%s %v", thing, methodName, parameters))
+
+		result := "已执行：" + method.Description
+		if len(parameters) > 0 {
+			result += fmt.Sprintf("，参数 %v", parameters)
+		}
+		return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: result}, nil
+	}
+}
+
+// sendIotCommands 向设备下发IoT指令
+func (h *ConnectionHandler) sendIotCommands(commands ...iot.Command) error {
+	return h.PushMessage(map[string]interface{}{
+		"type":       "iot",
+		"session_id": h.sessionID,
+		"commands":   commands,
+	}, true)
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/core/llmcache"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+// lookupLLMCache 以系统提示词、角色、上一轮对话和最后一条用户消息查找缓存
+// 只有最后一条消息是用户提问时才参与缓存，工具结果交回LLM等情况返回nil
+func (h *ConnectionHandler) lookupLLMCache(ctx context.Context, messages []providers.Message) *llmcache.Lookup {
+	if h.llmCache == nil || len(messages) == 0 {
+		return nil
+	}
+	last := messages[len(messages)-1]
+	if last.Role != "user" {
+		return nil
+	}
+	systemPrompt := ""
+	if messages[0].Role == "system" {
+		systemPrompt = messages[0].Content
+	}
+	scope := llmcache.Scope(h.selectedLLMName(), systemPrompt, h.role, previousExchange(messages[:len(messages)-1]))
+	return h.llmCache.Lookup(ctx, scope, last.Content)
+}
+
+// previousExchange 提问之前的上一轮对话（最近的一条用户消息及其后的回复），没有历史时为空
+func previousExchange(history []providers.Message) []string {
+	start := -1
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+	exchange := make([]string, 0, len(history)-start)
+	for _, msg := range history[start:] {
+		exchange = append(exchange, msg.Role+":"+msg.Content)
+	}
+	return exchange
+}
+
+// speakCachedResponse 播报缓存命中的回复，并像正常回复一样写入对话历史和对话记录
+func (h *ConnectionHandler) speakCachedResponse(content string, round int) {
+	atomic.StoreInt32(&h.serverVoiceStop, 0)
+	h.turnStage(turnStageTTS)
+
+	segments := utils.SplitByPunctuation(content)
+	if len(segments) == 0 {
+		segments = []string{content}
+	}
+	// 先设置最后一段的序号，避免第一段播放完毕时被误判为最后一段
+	h.setLastTextIndex(len(segments))
+	for i, segment := range segments {
+		if err := h.SpeakAndPlay(segment, i+1, round); err != nil {
+			h.LogError(fmt.Sprintf("播放缓存回复分段失败: %v", err))
+		}
+	}
+
+	h.dialogueManager.Put(chat.Message{
+		Role:    "assistant",
+		Content: content,
+	})
+	h.expectAnswer(content)
+	h.recordTurn(content, h.llmModelName(), round)
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"fmt"
+
+	"xiaozhi-server-go/src/maintenance"
+)
+
+// announceMaintenance 维护期间建立的会话只播报维护公告，播报完成后断开连接
+// 返回 true 表示已进入公告流程，不再进行会话接续和首次引导
+func (h *ConnectionHandler) announceMaintenance() bool {
+	state := maintenance.Current()
+	if !state.Enabled {
+		return false
+	}
+	h.maintenanceSession = true
+	h.closeAfterChat = true // 播报结束后关闭连接，期间上传的音频不再识别
+	h.LogInfo("服务处于维护模式，播报维护公告后断开")
+
+	if err := h.PushMessage(map[string]interface{}{
+		"type":    "alert",
+		"status":  "maintenance",
+		"message": state.Message,
+		"emotion": "neutral",
+	}, true); err != nil {
+		h.LogError(fmt.Sprintf("发送维护公告失败: %v", err))
+	}
+	if err := h.sendTTSMessage("start", "", 0); err != nil {
+		h.LogError(fmt.Sprintf("发送TTS开始状态失败: %v", err))
+	}
+	if err := h.SystemSpeak(state.Message); err != nil {
+		// 无法播报时直接断开，设备已收到文字公告
+		h.LogError(fmt.Sprintf("播报维护公告失败: %v", err))
+		h.Close()
+	}
+	return true
+}
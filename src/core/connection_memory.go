@@ -0,0 +1,153 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/service"
+)
+
+const (
+	extractMemoryTimeout = 30 * time.Second
+	maxTranscriptRunes   = 6000
+	extractMemoryPrompt  = "你负责为语音助手整理关于用户的长期记忆。请从下面的对话中提取关于用户本人、值得长期记住的事实，" +
+		"如姓名、家庭成员、喜好、习惯、健康状况、重要日期和长期计划；不要提取天气、新闻等临时信息，也不要提取助手说的话。" +
+		"每条事实用一句完整的中文陈述，主语用“用户”，最多%d条。只输出JSON字符串数组，没有值得记住的事实时输出[]。"
+)
+
+// memoryOwner 记忆所属的用户：设备绑定了用户时按用户保存，同一用户的设备共享记忆，否则按设备保存
+func (h *ConnectionHandler) memoryOwner() string {
+	if h.memoryOwnerKey != "" || h.deviceID == "" {
+		return h.memoryOwnerKey
+	}
+	h.memoryOwnerKey = "device:" + h.deviceID
+	if database.DB != nil {
+		userID, err := service.DeviceOwner(h.deviceID)
+		if err != nil && !errors.Is(err, service.ErrDeviceNotFound) {
+			h.LogError(fmt.Sprintf("查询设备所属用户失败: %v", err))
+		}
+		if err == nil && userID != 0 {
+			h.memoryOwnerKey = fmt.Sprintf("user:%d", userID)
+		}
+	}
+	return h.memoryOwnerKey
+}
+
+// recallMemories 按用户本轮的问题检索相关记忆，本轮请求LLM时放入系统提示词
+func (h *ConnectionHandler) recallMemories(ctx context.Context, text string) {
+	h.turnMemories = nil
+	if h.memories == nil {
+		return
+	}
+	memories, err := h.memories.Recall(ctx, h.memoryOwner(), text)
+	if err != nil {
+		h.LogError(fmt.Sprintf("检索长期记忆失败: %v", err))
+		return
+	}
+	if len(memories) > 0 {
+		h.logger.Debug("检索到长期记忆: %v", memories)
+	}
+	h.turnMemories = memories
+}
+
+// withMemories 把本轮检索到的记忆附加到系统消息后，返回新的消息列表，不修改对话历史
+func (h *ConnectionHandler) withMemories(messages []providers.Message) []providers.Message {
+	if len(h.turnMemories) == 0 || len(messages) == 0 || messages[0].Role != "system" {
+		return messages
+	}
+	result := make([]providers.Message, len(messages))
+	copy(result, messages)
+	result[0].Content += "\n\n关于用户你记得以下信息，回答时自然地参考，不要逐条复述：\n- " + strings.Join(h.turnMemories, "\n- ")
+	return result
+}
+
+// saveMemories 会话结束时从对话中提取关于用户的事实并保存为长期记忆
+// 在归还资源之前调用，使用当前连接的LLM
+func (h *ConnectionHandler) saveMemories() {
+	if h.memories == nil || h.llmProvider() == nil {
+		return
+	}
+	owner := h.memoryOwner()
+	if owner == "" {
+		return
+	}
+
+	var transcript strings.Builder
+	hasUser := false
+	for _, msg := range h.dialogueManager.Snapshot() {
+		switch msg.Role {
+		case "user":
+			hasUser = true
+			transcript.WriteString("用户：" + msg.Content + "\n")
+		case "assistant":
+			if msg.Content != "" {
+				transcript.WriteString("助手：" + msg.Content + "\n")
+			}
+		}
+	}
+	if !hasUser {
+		return
+	}
+	text := []rune(transcript.String())
+	if len(text) > maxTranscriptRunes {
+		text = text[len(text)-maxTranscriptRunes:]
+	}
+
+	ctx, cancel := context.WithTimeout(types.WithUsageReporter(context.Background(), h.usageReporter("LLM")), extractMemoryTimeout)
+	defer cancel()
+	facts, err := h.extractFacts(ctx, string(text))
+	if err != nil {
+		h.LogError(fmt.Sprintf("提取长期记忆失败: %v", err))
+		return
+	}
+	if len(facts) == 0 {
+		return
+	}
+	saved, err := h.memories.Remember(ctx, owner, facts)
+	if err != nil {
+		h.LogError(fmt.Sprintf("保存长期记忆失败: %v", err))
+		return
+	}
+	h.LogInfo(fmt.Sprintf("提取到 %d 条关于用户的事实，新保存 %d 条长期记忆", len(facts), saved))
+}
+
+// extractFacts 调用当前LLM从对话记录中提取事实
+func (h *ConnectionHandler) extractFacts(ctx context.Context, transcript string) ([]string, error) {
+	provider := h.llmProvider()
+	if provider == nil {
+		return nil, fmt.Errorf("LLM提供者不可用")
+	}
+	responses, err := provider.Response(ctx, h.sessionID, []providers.Message{
+		{Role: "system", Content: fmt.Sprintf(extractMemoryPrompt, h.memories.MaxFacts())},
+		{Role: "user", Content: transcript},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("请求LLM失败: %v", err)
+	}
+	var reply strings.Builder
+	for chunk := range responses {
+		reply.WriteString(chunk)
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("请求LLM超时: %v", ctx.Err())
+	}
+
+	// 模型可能在数组前后附加说明或代码块标记，只解析方括号之间的内容
+	content := reply.String()
+	start, end := strings.Index(content, "["), strings.LastIndex(content, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("LLM没有返回JSON数组: %s", content)
+	}
+	var facts []string
+	if err := json.Unmarshal([]byte(content[start:end+1]), &facts); err != nil {
+		return nil, fmt.Errorf("解析事实列表失败: %v", err)
+	}
+	return facts, nil
+}
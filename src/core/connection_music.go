@@ -0,0 +1,346 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"xiaozhi-server-go/src/core/music"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	defaultMusicResults = 10
+	musicSearchTimeout  = 10 * time.Second
+	musicIdleTimeout    = 10 * time.Minute // 暂停超过该时长后结束播放，释放音频流
+	musicReadSize       = 32 * 1024
+)
+
+// 音乐播放控制指令，设备通过 {"type":"music","action":"pause"} 或语音调用 music_control 发出
+const (
+	musicPause  = "pause"
+	musicResume = "resume"
+	musicSkip   = "skip"
+	musicStop   = "stop"
+)
+
+var errMusicEnd = errors.New("结束当前歌曲")
+
+// musicState 连接的音乐播放状态，同一时间只有一个播放列表
+type musicState struct {
+	mu      sync.Mutex
+	control chan string // 进行中的播放列表的控制通道，没有播放时为nil
+	paused  bool
+}
+
+// mcp_handler_play_song 搜索音乐源并把结果作为播放列表依次推流播放
+func (h *ConnectionHandler) mcp_handler_play_song(args interface{}) {
+	query, _ := args.(string)
+	if query == "random" || query == "随机" {
+		query = ""
+	}
+	if h.musicSource == nil {
+		h.SystemSpeak("还没有配置音乐源")
+		return
+	}
+
+	limit := h.config.Music.MaxResults
+	if limit <= 0 {
+		limit = defaultMusicResults
+	}
+	ctx, cancel := context.WithTimeout(h.ctx, musicSearchTimeout)
+	tracks, err := h.musicSource.Search(ctx, query, limit)
+	cancel()
+	if err != nil {
+		h.LogError(fmt.Sprintf("搜索音乐失败: %v", err))
+		h.SystemSpeak("音乐服务暂时不可用")
+		return
+	}
+	if len(tracks) == 0 {
+		h.SystemSpeak("没有找到" + query)
+		return
+	}
+
+	h.stopMusic()
+	h.endTurn()
+	control := make(chan string, 4)
+	h.music.mu.Lock()
+	h.music.control = control
+	h.music.paused = false
+	h.music.mu.Unlock()
+	h.LogInfo(fmt.Sprintf("开始播放音乐: %s，播放列表 %d 首", tracks[0].Name(), len(tracks)))
+	go h.runMusic(control, tracks)
+}
+
+// mcp_handler_music_control 语音控制音乐播放
+func (h *ConnectionHandler) mcp_handler_music_control(args interface{}) {
+	action, _ := args.(string)
+	h.music.mu.Lock()
+	playing, paused := h.music.control != nil, h.music.paused
+	h.music.mu.Unlock()
+	if !playing {
+		h.SystemSpeak("现在没有在播放音乐")
+		return
+	}
+
+	switch action {
+	case musicPause:
+		// 用户唤醒设备时播放已经自动暂停
+		if !paused {
+			h.controlMusic(musicPause)
+		}
+		h.SystemSpeak("好的，已暂停")
+	case musicStop:
+		h.stopMusic()
+		h.SystemSpeak("好的")
+	case musicResume, musicSkip:
+		h.endTurn()
+		h.controlMusic(action)
+	default:
+		h.LogError(fmt.Sprintf("未知的音乐控制指令: %s", action))
+	}
+}
+
+// handleMusicMessage 处理设备发来的音乐控制消息
+// 示例: {"type":"music","action":"pause"}，action 为 pause、resume、skip、stop
+func (h *ConnectionHandler) handleMusicMessage(msgMap map[string]interface{}) error {
+	action, _ := msgMap["action"].(string)
+	switch action {
+	case musicPause, musicResume, musicSkip:
+		if !h.controlMusic(action) {
+			return h.sendMusicStatus("stopped", nil, 0, 0)
+		}
+		return nil
+	case musicStop:
+		h.stopMusic()
+		h.sendTTSMessage("stop", "", 0)
+		return h.sendMusicStatus("stopped", nil, 0, 0)
+	default:
+		return fmt.Errorf("未知的音乐控制指令: %s", action)
+	}
+}
+
+// controlMusic 向进行中的播放列表发送控制指令，没有播放时返回false
+func (h *ConnectionHandler) controlMusic(action string) bool {
+	h.music.mu.Lock()
+	defer h.music.mu.Unlock()
+	if h.music.control == nil {
+		return false
+	}
+	select {
+	case h.music.control <- action:
+	default:
+		h.LogError(fmt.Sprintf("音乐控制指令过多，丢弃: %s", action))
+	}
+	return true
+}
+
+// stopMusic 结束进行中的播放列表，播放协程退出时不再通知设备
+func (h *ConnectionHandler) stopMusic() {
+	h.music.mu.Lock()
+	defer h.music.mu.Unlock()
+	if h.music.control == nil {
+		return
+	}
+	select {
+	case h.music.control <- musicStop:
+	default:
+	}
+	h.music.control = nil
+	h.music.paused = false
+}
+
+// setMusicPaused 更新暂停状态，control 已不是当前播放列表时忽略
+func (h *ConnectionHandler) setMusicPaused(control chan string, paused bool) {
+	h.music.mu.Lock()
+	defer h.music.mu.Unlock()
+	if h.music.control == control {
+		h.music.paused = paused
+	}
+}
+
+// runMusic 依次播放列表中的歌曲，直到播放完毕、收到停止指令或连接关闭
+func (h *ConnectionHandler) runMusic(control chan string, tracks []music.Track) {
+	defer func() {
+		h.music.mu.Lock()
+		owner := h.music.control == control
+		if owner {
+			h.music.control = nil
+			h.music.paused = false
+		}
+		h.music.mu.Unlock()
+		// 被新的播放列表替换或主动停止时，由调用方负责通知设备
+		if owner {
+			h.sendTTSMessage("stop", "", 0)
+			h.sendMusicStatus("stopped", nil, 0, 0)
+		}
+	}()
+
+	for i := 0; i < len(tracks); i++ {
+		if h.playTrack(control, tracks, i) == musicStop {
+			return
+		}
+	}
+}
+
+// playTrack 打开并推流播放一首歌曲，返回结束原因：播放完毕或 skip 时返回 skip，停止时返回 stop
+// 播放中设备被唤醒或开始了新的对话时自动暂停，之后可以继续播放
+func (h *ConnectionHandler) playTrack(control chan string, tracks []music.Track, index int) string {
+	track := tracks[index]
+	ctx, cancel := context.WithCancel(h.ctx)
+	defer cancel()
+	reader, err := h.musicSource.Open(ctx, track)
+	if err != nil {
+		h.LogError(fmt.Sprintf("打开歌曲 %s 失败: %v", track.Name(), err))
+		return musicSkip
+	}
+	defer reader.Close()
+
+	chunks := make(chan []byte, 8)
+	go func() {
+		defer close(chunks)
+		for {
+			buf := make([]byte, musicReadSize)
+			n, err := reader.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- buf[:n]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	round := h.beginMusicRound(track, tracks, index)
+	result := musicSkip
+	preBufferFrames := 3
+	preBufferTime := time.Duration(h.serverAudioFrameDuration*preBufferFrames) * time.Millisecond
+	var startTime time.Time
+	frameCount, playPosition := 0, 0
+
+	_, err = utils.StreamAudioToFrames(chunks, h.audioOutput(), func(frame []byte) error {
+		for {
+			action := ""
+			select {
+			case action = <-control:
+			case <-h.stopChan:
+				result = musicStop
+				return errMusicEnd
+			default:
+			}
+			interrupted := action == "" && (atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.currentRound())
+			if interrupted {
+				action = musicPause
+			}
+
+			switch action {
+			case musicSkip, musicStop:
+				result = action
+				return errMusicEnd
+			case musicPause:
+				if !interrupted {
+					h.sendTTSMessage("stop", "", 0)
+				}
+				next := h.waitMusic(control, track, tracks, index)
+				if next != musicResume {
+					result = next
+					return errMusicEnd
+				}
+				round = h.beginMusicRound(track, tracks, index)
+				frameCount, playPosition = 0, 0
+				continue
+			}
+			break
+		}
+
+		if frameCount == 0 {
+			startTime = time.Now()
+		}
+		// 预缓冲之后按播放进度流控
+		if frameCount >= preBufferFrames {
+			expectedTime := startTime.Add(time.Duration(playPosition)*time.Millisecond - preBufferTime)
+			if delay := time.Until(expectedTime); delay > 0 {
+				time.Sleep(delay)
+			}
+		}
+		if len(frame) > 0 {
+			if err := h.conn.WriteMessage(2, frame); err != nil {
+				return fmt.Errorf("发送音频帧失败: %v", err)
+			}
+		}
+		frameCount++
+		playPosition += h.serverAudioFrameDuration
+		return nil
+	})
+	if err != nil && err != errMusicEnd {
+		h.LogError(fmt.Sprintf("播放歌曲 %s 失败: %v", track.Name(), err))
+	}
+	if result == musicSkip && round == h.currentRound() {
+		h.sendTTSMessage("sentence_end", "正在播放："+track.Name(), 1)
+	}
+	return result
+}
+
+// beginMusicRound 开始或继续播放时占用新的轮次，使之前的播报失效，返回播放使用的轮次
+func (h *ConnectionHandler) beginMusicRound(track music.Track, tracks []music.Track, index int) int {
+	h.stopServerSpeak()
+	round := h.nextRound()
+	atomic.StoreInt32(&h.serverVoiceStop, 0)
+	h.music.mu.Lock()
+	h.music.paused = false
+	h.music.mu.Unlock()
+
+	h.sendTTSMessage("start", "", 0)
+	h.sendTTSMessage("sentence_start", "正在播放："+track.Name(), 1)
+	h.sendMusicStatus("playing", &track, index, len(tracks))
+	return round
+}
+
+// waitMusic 暂停播放，等待继续、切歌或停止，长时间没有指令时停止
+func (h *ConnectionHandler) waitMusic(control chan string, track music.Track, tracks []music.Track, index int) string {
+	h.setMusicPaused(control, true)
+	h.sendMusicStatus("paused", &track, index, len(tracks))
+	h.LogInfo(fmt.Sprintf("音乐已暂停: %s", track.Name()))
+
+	timer := time.NewTimer(musicIdleTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case action := <-control:
+			if action != musicPause {
+				return action
+			}
+		case <-timer.C:
+			h.LogInfo("音乐暂停时间过长，结束播放")
+			return musicStop
+		case <-h.stopChan:
+			return musicStop
+		}
+	}
+}
+
+// sendMusicStatus 通知设备音乐播放状态
+func (h *ConnectionHandler) sendMusicStatus(state string, track *music.Track, index, total int) error {
+	msg := map[string]interface{}{
+		"type":       "music",
+		"state":      state,
+		"session_id": h.sessionID,
+	}
+	if track != nil {
+		msg["title"] = track.Title
+		msg["artist"] = track.Artist
+		msg["index"] = index + 1
+		msg["total"] = total
+		if track.Duration > 0 {
+			msg["duration"] = track.Duration
+		}
+	}
+	return h.PushMessage(msg, true)
+}
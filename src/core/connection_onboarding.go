@@ -0,0 +1,179 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/function"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultOnboardingGreeting     = "你好，很高兴认识你！先给我起个名字吧，你想叫我什么？"
+	defaultOnboardingVoicePrompt  = "你喜欢哪种声音？可以选%s"
+	defaultOnboardingCapabilities = "设置好啦！有需要随时叫我。"
+	maxNicknameLength             = 10
+)
+
+// nicknamePrefixes 用户起名时常见的说法，提取名字前去掉
+var nicknamePrefixes = []string{"以后你就叫", "你就叫", "就叫你", "就叫", "叫你", "你叫", "叫"}
+
+// loadDeviceProfile 连接建立后加载设备的个性化设置，新设备开始首次连接引导
+func (h *ConnectionHandler) loadDeviceProfile() {
+	if h.profileLoaded || database.DB == nil || h.deviceID == "" {
+		return
+	}
+	h.profileLoaded = true
+
+	var profile models.DeviceProfile
+	err := database.DB.Where("device_id = ?", h.deviceID).Take(&profile).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		h.LogError(fmt.Sprintf("加载设备个性化设置失败: %v", err))
+		return
+	}
+	if err == nil && profile.OnboardedAt != nil {
+		h.applyDeviceProfile(profile.Nickname, profile.Voice)
+		return
+	}
+	if h.config.Onboarding.Enabled {
+		h.startOnboarding()
+	}
+}
+
+// applyDeviceProfile 应用助手名字和音色，只影响当前连接
+func (h *ConnectionHandler) applyDeviceProfile(nickname, voice string) {
+	if provider := h.ttsProvider(); voice != "" && provider != nil {
+		if err := provider.SetVoice(voice); err != nil {
+			h.LogError(fmt.Sprintf("应用设备音色失败: %v", err))
+		} else {
+			h.quickReplyCache = quickReplyCacheFor(provider)
+			h.prewarmQuickReplies()
+		}
+	}
+	if nickname != "" {
+		if system := h.dialogueManager.SystemMessage(); system != "" {
+			h.dialogueManager.SetSystemMessage(system + fmt.Sprintf("\n你的名字叫%s。", nickname))
+		}
+	}
+	h.LogInfo(fmt.Sprintf("已应用设备个性化设置: 名字=%s, 音色=%s", nickname, voice))
+}
+
+// startOnboarding 开始首次连接引导：起名字、选择音色，最后介绍能力
+func (h *ConnectionHandler) startOnboarding() {
+	cfg := h.config.Onboarding
+	greeting := cfg.Greeting
+	if greeting == "" {
+		greeting = defaultOnboardingGreeting
+	}
+	spec := function.SlotSpec{Slots: []function.Slot{
+		{Name: "name", Prompt: greeting, Parse: parseNickname},
+	}}
+	if len(cfg.Voices) > 0 {
+		names := make([]string, 0, len(cfg.Voices))
+		for name := range cfg.Voices {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		voicePrompt := cfg.VoicePrompt
+		if voicePrompt == "" {
+			voicePrompt = defaultOnboardingVoicePrompt
+		}
+		spec.Slots = append(spec.Slots, function.Slot{
+			Name:   "voice",
+			Prompt: fmt.Sprintf(voicePrompt, strings.Join(names, "、")),
+			Parse: func(value interface{}) (interface{}, error) {
+				text := fmt.Sprint(value)
+				for _, name := range names {
+					if strings.Contains(text, name) {
+						return name, nil
+					}
+				}
+				return nil, fmt.Errorf("没有这个声音")
+			},
+		})
+	}
+
+	h.onboarding = function.NewForm("onboarding", spec, nil)
+	prompt := h.onboarding.Prompt()
+	h.LogInfo("新设备首次连接，开始引导")
+	if err := h.sendTTSMessage("start", "", 0); err != nil {
+		h.LogError(fmt.Sprintf("发送TTS开始状态失败: %v", err))
+	}
+	h.expectAnswer(prompt)
+	h.SystemSpeak(prompt)
+}
+
+// handleOnboardingAnswer 处理引导过程中用户的回答
+func (h *ConnectionHandler) handleOnboardingAnswer(text string) error {
+	h.turnStage(turnStageTTS)
+	result, reply := h.onboarding.Answer(text)
+	switch result {
+	case function.FormContinue:
+		h.expectAnswer(reply)
+		return h.SystemSpeak(reply)
+	case function.FormCancelled:
+		h.LogInfo("用户跳过了首次连接引导")
+		h.onboarding = nil
+		return h.finishOnboarding("", "")
+	}
+
+	arguments := h.onboarding.Arguments
+	h.onboarding = nil
+	nickname, _ := arguments["name"].(string)
+	voiceName, _ := arguments["voice"].(string)
+	return h.finishOnboarding(nickname, h.config.Onboarding.Voices[voiceName])
+}
+
+// finishOnboarding 应用并保存引导结果，播报能力介绍
+func (h *ConnectionHandler) finishOnboarding(nickname, voice string) error {
+	h.applyDeviceProfile(nickname, voice)
+
+	now := time.Now()
+	profile := models.DeviceProfile{
+		DeviceID:    h.deviceID,
+		Nickname:    nickname,
+		Voice:       voice,
+		OnboardedAt: &now,
+	}
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "device_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"nickname", "voice", "onboarded_at", "updated_at"}),
+	}).Create(&profile).Error
+	if err != nil {
+		h.LogError(fmt.Sprintf("保存设备个性化设置失败: %v", err))
+	}
+
+	capabilities := h.config.Onboarding.Capabilities
+	if capabilities == "" {
+		capabilities = defaultOnboardingCapabilities
+	}
+	return h.SystemSpeak(capabilities)
+}
+
+// parseNickname 从用户的回答中提取助手名字
+func parseNickname(value interface{}) (interface{}, error) {
+	name := utils.RemoveAllPunctuation(strings.TrimSpace(fmt.Sprint(value)))
+	for _, prefix := range nicknamePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			name = strings.TrimPrefix(name, prefix)
+			break
+		}
+	}
+	name = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(name, "好了"), "吧"))
+	switch n := utf8.RuneCountInString(name); {
+	case n == 0:
+		return nil, fmt.Errorf("没有听清")
+	case n > maxNicknameLength:
+		return nil, fmt.Errorf("这个名字有点长")
+	}
+	return name, nil
+}
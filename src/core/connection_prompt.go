@@ -0,0 +1,76 @@
+package core
+
+import (
+	"strconv"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/models"
+)
+
+var weekdayNames = [...]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// contextFields 设备可通过 context 消息上报的提示词变量
+var contextFields = []string{"device_name", "nickname", "location", "battery"}
+
+// basePrompt 系统提示词模板，配置了从数据库读取时优先使用 system_config.prompt
+func basePrompt(config *configs.Config) string {
+	if config.PromptTemplate.FromDatabase && database.DB != nil {
+		var systemConfig models.SystemConfig
+		if err := database.DB.Select("prompt").Take(&systemConfig).Error; err == nil && systemConfig.Prompt != "" {
+			return systemConfig.Prompt
+		}
+	}
+	return config.DefaultPrompt
+}
+
+// handleContextMessage 处理设备上报的运行时信息，只更新消息中携带的字段
+// 示例: {"type":"context","battery":80,"location":"北京"}
+func (h *ConnectionHandler) handleContextMessage(msgMap map[string]interface{}) error {
+	for _, name := range contextFields {
+		switch value := msgMap[name].(type) {
+		case string:
+			h.promptVars[name] = value
+		case float64:
+			h.promptVars[name] = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	h.logger.Debug("设备运行时信息: %v", h.promptVars)
+	return nil
+}
+
+// promptVariables 请求时的模板变量：当前时间、设备信息和设备上报的运行时信息
+func (h *ConnectionHandler) promptVariables() map[string]string {
+	now := time.Now()
+	variables := map[string]string{
+		"time":         now.Format("2006-01-02 15:04"),
+		"date":         now.Format("2006年1月2日"),
+		"weekday":      weekdayNames[now.Weekday()],
+		"device_id":    h.deviceID,
+		"device_name":  h.deviceID,
+		"nickname":     "",
+		"location":     h.config.PromptTemplate.Location,
+		"battery":      "未知",
+		"user_emotion": h.userEmotionName(),
+	}
+	for name, value := range h.promptVars {
+		if value != "" {
+			variables[name] = value
+		}
+	}
+	return variables
+}
+
+// renderSystemPrompt 在请求时替换系统提示词中的模板变量，返回新的消息列表，不修改对话历史
+func (h *ConnectionHandler) renderSystemPrompt(messages []providers.Message) []providers.Message {
+	if len(messages) == 0 || messages[0].Role != "system" || !chat.HasPromptVariables(messages[0].Content) {
+		return messages
+	}
+	rendered := make([]providers.Message, len(messages))
+	copy(rendered, messages)
+	rendered[0].Content = chat.RenderPrompt(messages[0].Content, h.promptVariables())
+	return rendered
+}
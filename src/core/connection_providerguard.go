@@ -0,0 +1,104 @@
+package core
+
+import (
+	"sync"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/vlllm"
+)
+
+// providerGuard 保护休眠时会换绑的提供者。
+// 提供者字段的读写使用读写锁；使用提供者的操作（处理一段上行音频、一轮对话、合成一个句子）
+// 期间计数，休眠时先等计数归零，再把提供者归还到资源池
+type providerGuard struct {
+	mu sync.RWMutex
+
+	useMu    sync.Mutex
+	inUse    int
+	draining bool
+	idle     chan struct{} // draining 期间计数归零时关闭
+}
+
+// asrProvider 当前绑定的ASR提供者，休眠期间为nil
+func (h *ConnectionHandler) asrProvider() providers.ASRProvider {
+	h.providerGuard.mu.RLock()
+	defer h.providerGuard.mu.RUnlock()
+	return h.providers.asr
+}
+
+// llmProvider 当前绑定的LLM提供者，休眠期间为nil
+func (h *ConnectionHandler) llmProvider() providers.LLMProvider {
+	h.providerGuard.mu.RLock()
+	defer h.providerGuard.mu.RUnlock()
+	return h.providers.llm
+}
+
+// ttsProvider 当前绑定的TTS提供者，休眠期间为nil
+func (h *ConnectionHandler) ttsProvider() providers.TTSProvider {
+	h.providerGuard.mu.RLock()
+	defer h.providerGuard.mu.RUnlock()
+	return h.providers.tts
+}
+
+// vlllmProvider 当前绑定的VLLLM提供者，未配置或休眠期间为nil
+func (h *ConnectionHandler) vlllmProvider() *vlllm.Provider {
+	h.providerGuard.mu.RLock()
+	defer h.providerGuard.mu.RUnlock()
+	return h.providers.vlllm
+}
+
+// beginProviderUse 开始一次使用提供者的操作，正在进入休眠时返回false，调用方应放弃该操作。
+// 返回true时必须调用 endProviderUse，可以嵌套
+func (h *ConnectionHandler) beginProviderUse() bool {
+	g := &h.providerGuard
+	g.useMu.Lock()
+	defer g.useMu.Unlock()
+	if g.draining {
+		return false
+	}
+	g.inUse++
+	return true
+}
+
+// endProviderUse 结束一次使用提供者的操作
+func (h *ConnectionHandler) endProviderUse() {
+	g := &h.providerGuard
+	g.useMu.Lock()
+	defer g.useMu.Unlock()
+	g.inUse--
+	if g.inUse == 0 && g.idle != nil {
+		close(g.idle)
+		g.idle = nil
+	}
+}
+
+// drainProviderUse 拒绝新的操作并等待进行中的操作结束，连接关闭时返回false
+func (h *ConnectionHandler) drainProviderUse() bool {
+	g := &h.providerGuard
+	g.useMu.Lock()
+	g.draining = true
+	if g.inUse == 0 {
+		g.useMu.Unlock()
+		return true
+	}
+	if g.idle == nil {
+		g.idle = make(chan struct{})
+	}
+	idle := g.idle
+	g.useMu.Unlock()
+
+	select {
+	case <-idle:
+		return true
+	case <-h.stopChan:
+		return false
+	}
+}
+
+// resumeProviderUse 重新绑定提供者后允许新的操作
+func (h *ConnectionHandler) resumeProviderUse() {
+	g := &h.providerGuard
+	g.useMu.Lock()
+	defer g.useMu.Unlock()
+	g.draining = false
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"xiaozhi-server-go/src/core/pool"
+)
+
+func TestDrainProviderUseWaitsForInFlightUse(t *testing.T) {
+	h := &ConnectionHandler{stopChan: make(chan struct{})}
+	if !h.beginProviderUse() {
+		t.Fatal("beginProviderUse refused before draining")
+	}
+
+	drained := make(chan bool, 1)
+	go func() { drained <- h.drainProviderUse() }()
+
+	// 进行中的操作结束之前不能归还提供者
+	select {
+	case <-drained:
+		t.Fatal("drain returned while a use was in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if h.beginProviderUse() {
+		t.Fatal("beginProviderUse accepted a new use while draining")
+	}
+
+	h.endProviderUse()
+	select {
+	case ok := <-drained:
+		if !ok {
+			t.Fatal("drain reported connection closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("drain did not return after the use ended")
+	}
+
+	h.resumeProviderUse()
+	if !h.beginProviderUse() {
+		t.Fatal("beginProviderUse refused after resume")
+	}
+	h.endProviderUse()
+}
+
+func TestDrainProviderUseStopsOnClose(t *testing.T) {
+	h := &ConnectionHandler{stopChan: make(chan struct{})}
+	h.beginProviderUse()
+	close(h.stopChan)
+	if h.drainProviderUse() {
+		t.Fatal("drain reported idle on a closed connection")
+	}
+}
+
+// 使用 -race 运行：其他协程读取提供者的同时休眠和唤醒换绑提供者
+func TestProviderSwapIsRaceFree(t *testing.T) {
+	h := &ConnectionHandler{stopChan: make(chan struct{})}
+	set := &pool.ProviderSet{}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				_ = h.asrProvider()
+				_ = h.llmProvider()
+				_ = h.ttsProvider()
+				_ = h.vlllmProvider()
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		h.unbindProviders()
+		h.bindProviders(set)
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"xiaozhi-server-go/src/core/recording"
+)
+
+// maxUserRecordingSeconds 用户一句话最多保存这么长的录音，只保留最近的部分
+const maxUserRecordingSeconds = 60
+
+// recordingState 收集用户本句话的上行音频，识别出文本后保存为录音
+type recordingState struct {
+	mu  sync.Mutex
+	pcm []byte
+}
+
+// collectRecordingAudio 收集上行PCM，保存用户录音时使用
+func (h *ConnectionHandler) collectRecordingAudio(pcm []byte) {
+	if !h.recordings.RecordsUser() {
+		return
+	}
+	r := &h.recording
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pcm = append(r.pcm, pcm...)
+	limit := maxUserRecordingSeconds * h.clientAudioSampleRate * h.clientAudioChannels * 2
+	if limit > 0 && len(r.pcm) > limit {
+		r.pcm = append(r.pcm[:0], r.pcm[len(r.pcm)-limit:]...)
+	}
+}
+
+// resetRecordingAudio 丢弃已收集的音频，设备开始新一次拾音时调用
+func (h *ConnectionHandler) resetRecordingAudio() {
+	h.recording.mu.Lock()
+	h.recording.pcm = nil
+	h.recording.mu.Unlock()
+}
+
+// recordingMeta 本连接的录音所属的对话，开启 redaction.transcripts 时录音文本先脱敏
+func (h *ConnectionHandler) recordingMeta(round, sentence int, text string) recording.Meta {
+	return recording.Meta{
+		SessionID: h.sessionID,
+		DeviceID:  h.deviceID,
+		Round:     round,
+		Sentence:  sentence,
+		Text:      h.transcripts.Redact(text),
+	}
+}
+
+// saveUserRecording 把本句话收集到的音频保存为用户录音，文字聊天没有音频时跳过
+func (h *ConnectionHandler) saveUserRecording(text string, round int) {
+	if !h.recordings.RecordsUser() {
+		return
+	}
+	h.recording.mu.Lock()
+	pcm := h.recording.pcm
+	h.recording.pcm = nil
+	h.recording.mu.Unlock()
+	if len(pcm) == 0 {
+		return
+	}
+
+	meta := h.recordingMeta(round, 0, text)
+	sampleRate, channels := h.clientAudioSampleRate, h.clientAudioChannels
+	go func() {
+		if _, err := h.recordings.SaveUser(meta, pcm, sampleRate, channels); err != nil {
+			h.LogError(fmt.Sprintf("保存用户录音失败: %v", err))
+		}
+	}()
+}
+
+// saveReplyRecording 保存回复一句话合成的音频文件，在按 delete_audio 删除临时文件之前调用
+func (h *ConnectionHandler) saveReplyRecording(filepath, text string, textIndex, round int, duration float64) {
+	if !h.recordings.RecordsReply() {
+		return
+	}
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		h.LogError(fmt.Sprintf("读取回复音频失败: %v", err))
+		return
+	}
+	meta := h.recordingMeta(round, textIndex, text)
+	go func() {
+		if _, err := h.recordings.SaveReply(meta, recording.DetectFormat(data), data, int64(duration*1000)); err != nil {
+			h.LogError(fmt.Sprintf("保存回复录音失败: %v", err))
+		}
+	}()
+}
+
+// teeReplyRecording 转发流式合成的音频，同时收集完整的音频，合成结束后保存为回复录音
+// 返回的通道需要被消费完，中途停止播放时由调用方继续消费
+func (h *ConnectionHandler) teeReplyRecording(stream <-chan []byte, text string, textIndex, round int) <-chan []byte {
+	if !h.recordings.RecordsReply() {
+		return stream
+	}
+	out := make(chan []byte, cap(stream))
+	meta := h.recordingMeta(round, textIndex, text)
+	go func() {
+		defer close(out)
+		var data []byte
+		for chunk := range stream {
+			data = append(data, chunk...)
+			out <- chunk
+		}
+		if len(data) == 0 {
+			return
+		}
+		if _, err := h.recordings.SaveReply(meta, recording.DetectFormat(data), data, 0); err != nil {
+			h.LogError(fmt.Sprintf("保存回复录音失败: %v", err))
+		}
+	}()
+	return out
+}
@@ -0,0 +1,23 @@
+package core
+
+import (
+	"testing"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+func TestRecordingMetaRedactsText(t *testing.T) {
+	redactor, err := utils.NewRedactor(configs.RedactionConfig{Enabled: true, Transcripts: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &ConnectionHandler{sessionID: "s1", deviceID: "aa:aa", transcripts: redactor}
+	meta := h.recordingMeta(2, 1, "请打13812345678")
+	if meta.Text != "请打[电话]" {
+		t.Errorf("meta text %q, want the phone number redacted", meta.Text)
+	}
+	if meta.Round != 2 || meta.Sentence != 1 || meta.DeviceID != "aa:aa" {
+		t.Errorf("meta = %+v", meta)
+	}
+}
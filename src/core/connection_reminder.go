@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ReminderScheduler 创建到时在设备上播报的提醒
+type ReminderScheduler interface {
+	Remind(deviceID, text string, at time.Time) error
+}
+
+// registerReminderFunction 注册设置提醒的LLM函数，未启用定时任务时不注册
+func (h *ConnectionHandler) registerReminderFunction() {
+	if h.reminders == nil || h.deviceID == "" {
+		return
+	}
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "set_reminder",
+			Description: "用户让你在之后的某个时间提醒他时调用，如“十分钟后提醒我关火”“明早七点叫我起床”。minutes 和 time 二选一",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"minutes": map[string]interface{}{"type": "integer", "description": "多少分钟后提醒"},
+					"time":    map[string]interface{}{"type": "string", "description": "提醒的时刻，24小时制 HH:MM，已经过去时为明天的这个时刻"},
+					"content": map[string]interface{}{"type": "string", "description": "提醒的内容，如“关火”"},
+				},
+				"required": []string{"content"},
+			},
+		},
+	}
+	handler := func(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error) {
+		content, _ := arguments["content"].(string)
+		content = strings.TrimSpace(content)
+		at, err := reminderTime(arguments, time.Now())
+		if err != nil || content == "" {
+			return types.ActionResponse{Action: types.ActionTypeResponse, Response: "没听清要什么时候提醒你什么，请再说一遍"}, nil
+		}
+		if err := h.reminders.Remind(h.deviceID, content, at); err != nil {
+			h.LogError(fmt.Sprintf("设置提醒失败: %v", err))
+			return types.ActionResponse{Action: types.ActionTypeResponse, Response: "提醒没有设置成功，请稍后再试"}, nil
+		}
+		h.LogInfo(fmt.Sprintf("设置提醒: %s %s", at.Format("2006-01-02 15:04"), content))
+		return types.ActionResponse{
+			Action:   types.ActionTypeResponse,
+			Response: fmt.Sprintf("好的，%s提醒你%s", at.Format("1月2日15点04分"), content),
+		}, nil
+	}
+	if err := h.functionRegister.RegisterFunctionWithHandler("set_reminder", tool, handler); err != nil {
+		h.LogError(fmt.Sprintf("注册set_reminder函数失败: %v", err))
+	}
+}
+
+// reminderTime 按 minutes 或 time 参数计算提醒时间
+func reminderTime(arguments map[string]interface{}, now time.Time) (time.Time, error) {
+	if minutes, ok := arguments["minutes"].(float64); ok && minutes > 0 {
+		return now.Add(time.Duration(minutes * float64(time.Minute))), nil
+	}
+	clock, _ := arguments["time"].(string)
+	t, err := time.ParseInLocation("15:04", strings.TrimSpace(clock), now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("提醒时间无效: %q", clock)
+	}
+	at := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at, nil
+}
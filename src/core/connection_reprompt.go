@@ -0,0 +1,130 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRepromptTimeout = 8 * time.Second
+	defaultRepromptPhrase  = "你还在吗？"
+)
+
+// repromptState 追问状态机
+// 助手的回复以问句结尾时进入等待状态，播报结束后开始计时；
+// 用户在超时前说话则退出等待，否则追问并重新计时，追问次数用完后结束本轮等待
+type repromptState struct {
+	mu      sync.Mutex
+	waiting bool // 助手提出了问题，等待用户回答
+	count   int  // 已追问次数
+	timer   *time.Timer
+}
+
+// isQuestion 判断回复是否以问句结尾
+func isQuestion(text string) bool {
+	text = strings.TrimRight(strings.TrimSpace(text), "～~。.!！\"”'’)）")
+	return strings.HasSuffix(text, "?") || strings.HasSuffix(text, "？") || strings.HasSuffix(text, "吗")
+}
+
+// expectAnswer 记录助手的回复，回复为问句时进入等待状态
+func (h *ConnectionHandler) expectAnswer(reply string) {
+	if !h.config.Reprompt.Enabled {
+		return
+	}
+	r := &h.reprompt
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopTimer()
+	r.waiting = isQuestion(reply)
+	r.count = 0
+}
+
+// armReprompt 播报结束后开始等待用户回答
+func (h *ConnectionHandler) armReprompt() {
+	r := &h.reprompt
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.waiting {
+		return
+	}
+	timeout := defaultRepromptTimeout
+	if h.config.Reprompt.Timeout > 0 {
+		timeout = time.Duration(h.config.Reprompt.Timeout) * time.Second
+	}
+	r.stopTimer()
+	r.timer = time.AfterFunc(timeout, h.onRepromptTimeout)
+}
+
+// cancelReprompt 用户开始新的对话或打断播报，退出等待状态
+func (h *ConnectionHandler) cancelReprompt() {
+	r := &h.reprompt
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopTimer()
+	r.waiting = false
+	r.count = 0
+}
+
+// stopTimer 停止计时器，调用方需持有锁
+func (r *repromptState) stopTimer() {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+// onRepromptTimeout 等待超时，追问或结束本轮等待
+func (h *ConnectionHandler) onRepromptTimeout() {
+	select {
+	case <-h.stopChan:
+		return
+	default:
+	}
+
+	maxReprompts := h.config.Reprompt.MaxReprompts
+	if maxReprompts <= 0 {
+		maxReprompts = 1
+	}
+
+	r := &h.reprompt
+	r.mu.Lock()
+	if !r.waiting || h.isAsleep() {
+		r.mu.Unlock()
+		return
+	}
+	r.timer = nil
+	if r.count >= maxReprompts {
+		r.waiting = false
+		r.count = 0
+		r.mu.Unlock()
+		h.LogInfo("用户未回应追问，结束本轮等待")
+		h.clearSpeakStatus()
+		return
+	}
+	r.count++
+	count := r.count
+	r.mu.Unlock()
+
+	phrase := h.repromptPhrase()
+	h.LogInfo(fmt.Sprintf("用户未回答，第 %d 次追问: %s", count, phrase))
+	if err := h.sendTTSMessage("start", "", 0); err != nil {
+		h.LogError(fmt.Sprintf("发送TTS开始状态失败: %v", err))
+		return
+	}
+	// 追问语播报结束后 armReprompt 会重新计时
+	h.SystemSpeak(phrase)
+}
+
+// repromptPhrase 按当前角色选择追问语
+func (h *ConnectionHandler) repromptPhrase() string {
+	phrases := h.config.Reprompt.Phrases
+	if phrase, ok := phrases[h.role]; ok && h.role != "" && phrase != "" {
+		return phrase
+	}
+	if phrase := phrases["default"]; phrase != "" {
+		return phrase
+	}
+	return defaultRepromptPhrase
+}
@@ -0,0 +1,256 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/models"
+	"xiaozhi-server-go/src/roles"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// roleKeepMessages 切换角色时保留的最近对话消息数
+const roleKeepMessages = 5
+
+// roleState 连接当前使用的数据库角色
+type roleState struct {
+	mu      sync.Mutex
+	loaded  bool
+	current *models.Role // 为nil时使用默认角色
+	llm     llm.Provider // 角色指定的LLM，切换角色时创建，连接关闭时释放
+
+	// 第一次切换角色前的系统提示词和音色，恢复默认角色时使用
+	defaultSaved  bool
+	defaultPrompt string
+	defaultVoice  string
+}
+
+// loadDeviceRole 连接建立后应用设备保存的角色，有可选角色时注册 switch_role 函数
+func (h *ConnectionHandler) loadDeviceRole() {
+	if database.DB == nil || h.deviceID == "" {
+		return
+	}
+	h.activeRole.mu.Lock()
+	loaded := h.activeRole.loaded
+	h.activeRole.loaded = true
+	h.activeRole.mu.Unlock()
+	if loaded {
+		return
+	}
+
+	list, err := roles.List(database.DB)
+	if err != nil {
+		h.LogError(err.Error())
+		return
+	}
+	if len(list) == 0 {
+		return
+	}
+	h.registerRoleFunction(list)
+
+	role, err := roles.DeviceRole(database.DB, h.deviceID)
+	if err != nil {
+		h.LogError(err.Error())
+		return
+	}
+	if role != nil {
+		h.applyRole(role)
+	}
+}
+
+// registerRoleFunction 注册切换角色的LLM函数，可选角色在连接建立时确定
+func (h *ConnectionHandler) registerRoleFunction(list []models.Role) {
+	options := make([]string, 0, len(list))
+	for _, role := range list {
+		if role.Description != "" {
+			options = append(options, role.Name+"（"+role.Description+"）")
+		} else {
+			options = append(options, role.Name)
+		}
+	}
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name: "switch_role",
+			Description: "用户想切换助手的角色或模式时调用，如“切换到英语老师模式”。可选的角色有：" +
+				strings.Join(options, "、") + "；恢复默认角色时传“" + roles.DefaultName + "”",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"role": map[string]interface{}{"type": "string", "description": "角色名称"},
+				},
+				"required": []string{"role"},
+			},
+		},
+	}
+	handler := func(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error) {
+		name, _ := arguments["role"].(string)
+		reply, err := h.switchRole(name)
+		if errors.Is(err, roles.ErrNotFound) {
+			return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: "没有叫" + name + "的角色，可选的角色有：" + strings.Join(options, "、")}, nil
+		}
+		if err != nil {
+			return types.ActionResponse{}, err
+		}
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: reply}, nil
+	}
+	if err := h.functionRegister.RegisterFunctionWithHandler("switch_role", tool, handler); err != nil {
+		h.LogError(fmt.Sprintf("注册switch_role函数失败: %v", err))
+	}
+}
+
+// switchRole 按名称切换角色并保存到设备个性化设置，返回播报给用户的回复
+func (h *ConnectionHandler) switchRole(name string) (string, error) {
+	if database.DB == nil {
+		return "", fmt.Errorf("数据库未连接")
+	}
+	var role *models.Role
+	name = strings.TrimSpace(name)
+	if name != "" && name != roles.DefaultName {
+		var err error
+		if role, err = roles.Find(database.DB, name); err != nil {
+			return "", err
+		}
+	}
+
+	roleID := int64(0)
+	if role != nil {
+		roleID = role.ID
+	}
+	if err := roles.SetDeviceRole(database.DB, h.deviceID, roleID); err != nil {
+		h.LogError(err.Error())
+	}
+	h.applyRole(role)
+	if role == nil {
+		return "好的，已恢复默认角色", nil
+	}
+	return "好的，已切换到" + role.Name, nil
+}
+
+// applyRole 应用角色的提示词、音色和LLM，role 为nil时恢复默认角色，只影响当前连接
+func (h *ConnectionHandler) applyRole(role *models.Role) {
+	s := &h.activeRole
+	s.mu.Lock()
+	if !s.defaultSaved {
+		s.defaultSaved = true
+		s.defaultPrompt = h.dialogueManager.SystemMessage()
+		if getter, ok := h.ttsProvider().(configGetter); ok {
+			s.defaultVoice = getter.Config().Voice
+		}
+	}
+	prompt, voice := s.defaultPrompt, s.defaultVoice
+	var provider llm.Provider
+	if role != nil {
+		if role.Prompt != "" {
+			prompt = role.Prompt
+			if h.config.ProsodyMarkup {
+				prompt += tts.ProsodyPrompt
+			}
+		}
+		if role.Voice != "" {
+			voice = role.Voice
+		}
+		if role.LLM != "" {
+			var err error
+			if provider, err = h.newLLMProvider(role.LLM); err != nil {
+				h.LogError(fmt.Sprintf("创建角色 %s 的LLM失败，使用默认LLM: %v", role.Name, err))
+				provider = nil
+			}
+		}
+	}
+	previous := s.llm
+	s.current, s.llm = role, provider
+	s.mu.Unlock()
+
+	if previous != nil {
+		if err := previous.Cleanup(); err != nil {
+			h.LogError(fmt.Sprintf("释放角色LLM失败: %v", err))
+		}
+	}
+	h.dialogueManager.SetSystemMessage(prompt)
+	h.dialogueManager.KeepRecentMessages(roleKeepMessages)
+	h.setRoleVoice(voice)
+
+	name := roles.DefaultName
+	h.role = ""
+	if role != nil {
+		name = role.Name
+		h.role = role.Name
+	}
+	h.LogInfo(fmt.Sprintf("已切换角色: %s", name))
+	h.sendRoleStatus()
+}
+
+// setRoleVoice 切换TTS音色，音色与当前音色相同时不切换
+func (h *ConnectionHandler) setRoleVoice(voice string) {
+	provider := h.ttsProvider()
+	getter, ok := provider.(configGetter)
+	if voice == "" || !ok || getter.Config().Voice == voice {
+		return
+	}
+	if err := provider.SetVoice(voice); err != nil {
+		h.LogError(fmt.Sprintf("切换角色音色失败: %v", err))
+		return
+	}
+	h.quickReplyCache = quickReplyCacheFor(provider)
+	h.prewarmQuickReplies()
+}
+
+// roleLLM 当前角色指定的LLM，没有指定时返回nil
+func (h *ConnectionHandler) roleLLM() providers.LLMProvider {
+	h.activeRole.mu.Lock()
+	defer h.activeRole.mu.Unlock()
+	if h.activeRole.llm == nil {
+		return nil
+	}
+	return h.activeRole.llm
+}
+
+// releaseRole 连接关闭时释放角色的LLM
+func (h *ConnectionHandler) releaseRole() {
+	s := &h.activeRole
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.llm != nil {
+		if err := s.llm.Cleanup(); err != nil {
+			h.LogError(fmt.Sprintf("释放角色LLM失败: %v", err))
+		}
+		s.llm = nil
+	}
+}
+
+// handleRoleMessage 处理设备发来的切换角色消息，name 为空或“默认”时恢复默认角色
+// 示例: {"type":"role","name":"英语老师"}
+func (h *ConnectionHandler) handleRoleMessage(msgMap map[string]interface{}) error {
+	name, _ := msgMap["name"].(string)
+	if _, err := h.switchRole(name); err != nil {
+		h.sendRoleStatus()
+		return fmt.Errorf("切换角色失败: %v", err)
+	}
+	return nil
+}
+
+// sendRoleStatus 通知设备当前的角色
+func (h *ConnectionHandler) sendRoleStatus() error {
+	h.activeRole.mu.Lock()
+	msg := map[string]interface{}{
+		"type":       "role",
+		"name":       roles.DefaultName,
+		"session_id": h.sessionID,
+	}
+	if role := h.activeRole.current; role != nil {
+		msg["name"] = role.Name
+		msg["role_id"] = role.ID
+	}
+	h.activeRole.mu.Unlock()
+	return h.PushMessage(msg, true)
+}
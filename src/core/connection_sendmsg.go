@@ -2,6 +2,7 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync/atomic"
 	"time"
@@ -23,6 +24,9 @@ func (h *ConnectionHandler) sendHelloMessage() error {
 	hello := make(map[string]interface{})
 	hello["type"] = "hello"
 	hello["version"] = 1
+	if h.eventsEnabled() {
+		hello["version"] = eventProtocolVersion
+	}
 	hello["transport"] = "websocket"
 	hello["session_id"] = h.sessionID
 	hello["audio_params"] = map[string]interface{}{
@@ -40,6 +44,7 @@ func (h *ConnectionHandler) sendHelloMessage() error {
 }
 
 func (h *ConnectionHandler) sendTTSMessage(state string, text string, textIndex int) error {
+	h.trackServerSpeaking(state)
 	// 发送TTS状态结束通知
 	stateMsg := map[string]interface{}{
 		"type":        "tts",
@@ -47,7 +52,13 @@ func (h *ConnectionHandler) sendTTSMessage(state string, text string, textIndex
 		"session_id":  h.sessionID,
 		"text":        text,
 		"index":       textIndex,
-		"audio_codec": "opus", // 标识使用Opus编码
+		"audio_codec": h.serverAudioFormat, // 标识下发音频的编码格式
+	}
+	// 句子开始时携带该句回复的情绪，有屏幕的设备据此切换表情
+	if state == "sentence_start" {
+		if emotion := h.sentenceEmotion(textIndex); emotion != "" {
+			stateMsg["emotion"] = emotion
+		}
 	}
 	data, err := json.Marshal(stateMsg)
 	if err != nil {
@@ -96,26 +107,18 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 	defer func() {
 		// 音频发送完成后，根据配置决定是否删除文件
 		h.deleteAudioFileIfNeeded(filepath, "音频发送完成")
-
-		h.LogInfo(fmt.Sprintf("TTS音频发送任务结束(%t): %s, 索引: %d/%d", bFinishSuccess, text, textIndex, h.tts_last_text_index))
-		h.providers.asr.ResetStartListenTime()
-		if textIndex == h.tts_last_text_index {
-			h.sendTTSMessage("stop", "", textIndex)
-			if h.closeAfterChat {
-				h.Close()
-			} else {
-				h.clearSpeakStatus()
-			}
-		}
+		h.finishAudioMessage(text, textIndex, bFinishSuccess)
 	}()
 
 	if len(filepath) == 0 {
+		// 不合成语音的文字聊天只下发文本
+		bFinishSuccess = h.sendSilentSentence(text, textIndex, round)
 		return
 	}
 	// 检查轮次
-	if round != h.talkRound {
+	if round != h.currentRound() {
 		h.LogInfo(fmt.Sprintf("sendAudioMessage: 跳过过期轮次的音频: 任务轮次=%d, 当前轮次=%d, 文本=%s",
-			round, h.talkRound, text))
+			round, h.currentRound(), text))
 		// 即使跳过，也要根据配置删除音频文件
 		h.deleteAudioFileIfNeeded(filepath, "跳过过期轮次")
 		return
@@ -132,21 +135,13 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 	var duration float64
 	var err error
 
-	// 使用TTS提供者的方法将音频转为Opus格式
-	if h.serverAudioFormat == "pcm" {
-		h.LogInfo("服务端音频格式为PCM，直接发送")
-		audioData, duration, err = utils.AudioToPCMData(filepath)
-		if err != nil {
-			h.LogError(fmt.Sprintf("音频转PCM失败: %v", err))
-			return
-		}
-	} else if h.serverAudioFormat == "opus" {
-		audioData, duration, err = utils.AudioToOpusData(filepath)
-		if err != nil {
-			h.LogError(fmt.Sprintf("音频转Opus失败: %v", err))
-			return
-		}
+	// 按协商的下发格式编码
+	audioData, duration, err = utils.AudioFileToFrames(filepath, h.replyAudioOutput(), h.serverAudioFrameDuration)
+	if err != nil {
+		h.LogError(fmt.Sprintf("音频转%s失败: %v", h.serverAudioFormat, err))
+		return
 	}
+	h.saveReplyRecording(filepath, text, textIndex, round, duration)
 
 	// 发送TTS状态开始通知
 	if err := h.sendTTSMessage("sentence_start", text, textIndex); err != nil {
@@ -159,7 +154,7 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 		spentTime := now.Sub(h.roundStartTime)
 		h.logger.Debug("回复首句耗时 %s 第一句话【%s】, round: %d", spentTime, text, round)
 	}
-	h.logger.Debug("TTS发送(%s): \"%s\" (索引:%d/%d，时长:%f，帧数:%d)", h.serverAudioFormat, text, textIndex, h.tts_last_text_index, duration, len(audioData))
+	h.logger.Debug("TTS发送(%s): \"%s\" (索引:%d/%d，时长:%f，帧数:%d)", h.serverAudioFormat, text, textIndex, h.lastTextIndex(), duration, len(audioData))
 
 	// 分时发送音频数据
 	if err := h.sendAudioFrames(audioData, text, round); err != nil {
@@ -176,6 +171,126 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 	bFinishSuccess = true
 }
 
+// finishAudioMessage 单句音频发送结束后的处理，最后一句时通知客户端TTS结束
+func (h *ConnectionHandler) finishAudioMessage(text string, textIndex int, bFinishSuccess bool) {
+	h.LogInfo(fmt.Sprintf("TTS音频发送任务结束(%t): %s, 索引: %d/%d", bFinishSuccess, text, textIndex, h.lastTextIndex()))
+	if asr := h.asrProvider(); asr != nil {
+		asr.ResetStartListenTime()
+	}
+	if textIndex == h.lastTextIndex() {
+		h.finishReplyEncoder(bFinishSuccess)
+		h.endTurn()
+		h.flushAudioUsage()
+		h.sendTTSMessage("stop", "", textIndex)
+		if h.closeAfterChat {
+			h.Close()
+		} else {
+			h.clearSpeakStatus()
+			h.armReprompt()
+		}
+	} else {
+		h.turnStage(turnStageTTS)
+	}
+}
+
+// sendAudioStream 边接收流式合成的音频边转码发送，首帧到达即开始播放
+func (h *ConnectionHandler) sendAudioStream(stream <-chan []byte, text string, textIndex int, round int) {
+	bFinishSuccess := false
+	defer func() {
+		// 确保合成协程不会因通道未被消费而阻塞
+		go func() {
+			for range stream {
+			}
+		}()
+		h.finishAudioMessage(text, textIndex, bFinishSuccess)
+	}()
+
+	// 检查轮次
+	if round != h.currentRound() {
+		h.LogInfo(fmt.Sprintf("sendAudioStream: 跳过过期轮次的音频: 任务轮次=%d, 当前轮次=%d, 文本=%s",
+			round, h.currentRound(), text))
+		return
+	}
+
+	if atomic.LoadInt32(&h.serverVoiceStop) == 1 { // 服务端语音停止
+		h.LogInfo(fmt.Sprintf("sendAudioStream 服务端语音停止, 不再发送音频数据：%s", text))
+		return
+	}
+	stream = h.teeReplyRecording(stream, text, textIndex, round)
+
+	// 发送TTS状态开始通知
+	if err := h.sendTTSMessage("sentence_start", text, textIndex); err != nil {
+		h.LogError(fmt.Sprintf("发送TTS开始状态失败: %v", err))
+		return
+	}
+
+	errInterrupted := errors.New("音频发送被中断")
+	preBufferFrames := 3
+	preBufferTime := time.Duration(h.serverAudioFrameDuration*preBufferFrames) * time.Millisecond
+	var startTime time.Time
+	frameCount := 0
+	playPosition := 0 // 播放位置（毫秒）
+
+	duration, err := utils.StreamAudioToFrames(stream, h.replyAudioOutput(), func(frame []byte) error {
+		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.currentRound() {
+			return errInterrupted
+		}
+
+		if frameCount == 0 {
+			startTime = time.Now()
+			if textIndex == 1 {
+				h.logger.Debug("回复首句耗时 %s 第一帧音频【%s】, round: %d", time.Since(h.roundStartTime), text, round)
+			}
+		}
+
+		// 预缓冲之后按播放进度流控
+		if frameCount >= preBufferFrames {
+			expectedTime := startTime.Add(time.Duration(playPosition)*time.Millisecond - preBufferTime)
+			if delay := time.Until(expectedTime); delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-h.stopChan:
+					timer.Stop()
+					return errInterrupted
+				}
+				if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.currentRound() {
+					return errInterrupted
+				}
+			}
+		}
+
+		if len(frame) > 0 {
+			if err := h.conn.WriteMessage(2, frame); err != nil {
+				return fmt.Errorf("发送音频帧失败: %v", err)
+			}
+		}
+		frameCount++
+		playPosition += h.serverAudioFrameDuration
+		return nil
+	})
+	h.addAudioUsage("TTS", playPosition)
+	if err == errInterrupted {
+		h.LogInfo(fmt.Sprintf("流式音频发送被中断: 帧=%d, 文本=%s", frameCount, text))
+		return
+	}
+	if err != nil {
+		h.LogError(fmt.Sprintf("流式发送音频数据失败: %v", err))
+		return
+	}
+
+	time.Sleep(preBufferTime) // 确保预缓冲时间已过
+	h.logger.Debug("TTS流式发送(%s): \"%s\" (索引:%d/%d，时长:%f，帧数:%d)", h.serverAudioFormat, text, textIndex, h.lastTextIndex(), duration, frameCount)
+
+	// 发送TTS状态结束通知
+	if err := h.sendTTSMessage("sentence_end", text, textIndex); err != nil {
+		h.LogError(fmt.Sprintf("发送TTS结束状态失败: %v", err))
+		return
+	}
+
+	bFinishSuccess = true
+}
+
 // sendAudioFrames 分时发送音频帧，避免撑爆客户端缓冲区
 func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, round int) error {
 	if len(audioData) == 0 {
@@ -184,6 +299,10 @@ func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, rou
 
 	startTime := time.Now()
 	playPosition := 0 // 播放位置（毫秒）
+	defer func() {
+		// 被打断时只统计已发送的部分
+		h.addAudioUsage("TTS", playPosition)
+	}()
 
 	// 预缓冲：发送前几帧，提升播放流畅度
 	preBufferFrames := 3
@@ -195,13 +314,15 @@ func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, rou
 	// 发送预缓冲帧
 	for i := 0; i < preBufferFrames; i++ {
 		// 检查是否被打断
-		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
+		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.currentRound() {
 			h.LogInfo(fmt.Sprintf("音频发送被中断(预缓冲阶段): 帧=%d/%d, 文本=%s", i+1, preBufferFrames, text))
 			return nil
 		}
 
-		if err := h.conn.WriteMessage(2, audioData[i]); err != nil {
-			return fmt.Errorf("发送预缓冲音频帧失败: %v", err)
+		if len(audioData[i]) > 0 {
+			if err := h.conn.WriteMessage(2, audioData[i]); err != nil {
+				return fmt.Errorf("发送预缓冲音频帧失败: %v", err)
+			}
 		}
 		playPosition += h.serverAudioFrameDuration
 	}
@@ -210,7 +331,7 @@ func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, rou
 	remainingFrames := audioData[preBufferFrames:]
 	for i, chunk := range remainingFrames {
 		// 检查是否被打断或轮次变化
-		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
+		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.currentRound() {
 			h.LogInfo(fmt.Sprintf("音频发送被中断: 帧=%d/%d, 文本=%s", i+preBufferFrames+1, len(audioData), text))
 			return nil
 		}
@@ -238,7 +359,7 @@ func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, rou
 				select {
 				case <-ticker.C:
 					// 检查中断条件
-					if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
+					if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.currentRound() {
 						h.LogInfo(fmt.Sprintf("音频发送在延迟中被中断: 帧=%d/%d, 文本=%s", i+preBufferFrames+1, len(audioData), text))
 						return nil
 					}
@@ -248,9 +369,11 @@ func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, rou
 			}
 		}
 
-		// 发送音频帧
-		if err := h.conn.WriteMessage(2, chunk); err != nil {
-			return fmt.Errorf("发送音频帧失败: %v", err)
+		// 发送音频帧，编码器缓冲中的空帧只推进播放进度
+		if len(chunk) > 0 {
+			if err := h.conn.WriteMessage(2, chunk); err != nil {
+				return fmt.Errorf("发送音频帧失败: %v", err)
+			}
 		}
 
 		playPosition += h.serverAudioFrameDuration
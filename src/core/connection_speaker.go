@@ -0,0 +1,179 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/speaker"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+)
+
+// identifySpeakerTimeout 识别说话人的超时时间，超时后本轮沿用上一次的识别结果
+const identifySpeakerTimeout = 3 * time.Second
+
+// speakerState 声纹识别状态：收集本句话的音频，记录识别到的用户及其设置
+type speakerState struct {
+	mu        sync.Mutex
+	resampler *utils.PCMResampler
+	samples   []float32 // 本句话最近的音频，16kHz单声道
+
+	userID          int64
+	llm             llm.Provider // 用户选择的LLM，与默认LLM相同时为nil
+	quickReplyWords []string
+
+	// 第一次识别到用户前的系统提示词，用户没有自定义提示词时使用
+	defaultSaved  bool
+	defaultPrompt string
+}
+
+// collectSpeakerAudio 收集上行音频供声纹识别，只保留最近 max_seconds 的音频
+func (h *ConnectionHandler) collectSpeakerAudio(pcm []byte) {
+	if h.speakers == nil {
+		return
+	}
+	s := &h.speaker
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resampler == nil {
+		resampler, err := utils.NewPCMResampler(h.clientAudioSampleRate, h.clientAudioChannels, speaker.SampleRate)
+		if err != nil {
+			return
+		}
+		s.resampler = resampler
+	}
+	s.samples = append(s.samples, s.resampler.Float32(pcm)...)
+	if limit := h.speakers.MaxSamples(); len(s.samples) > limit {
+		s.samples = append(s.samples[:0], s.samples[len(s.samples)-limit:]...)
+	}
+}
+
+// identifySpeaker 识别本句话的说话人，识别到其他已登记用户时切换到该用户的设置
+// 没有收集到音频（如文字聊天）或相似度不足时沿用上一次的识别结果
+func (h *ConnectionHandler) identifySpeaker(ctx context.Context) {
+	if h.speakers == nil {
+		return
+	}
+	s := &h.speaker
+	s.mu.Lock()
+	samples := s.samples
+	s.samples = nil
+	current := s.userID
+	s.mu.Unlock()
+	if len(samples) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, identifySpeakerTimeout)
+	defer cancel()
+	match, ok, err := h.speakers.Identify(ctx, samples)
+	if err != nil {
+		h.LogError(fmt.Sprintf("识别说话人失败: %v", err))
+		return
+	}
+	if !ok {
+		h.logger.Debug("没有识别到已登记的说话人，最高相似度 %.2f", match.Score)
+		return
+	}
+	if match.UserID != current {
+		h.applySpeaker(match)
+	}
+}
+
+// applySpeaker 应用识别到的用户：长期记忆按该用户保存，使用用户的提示词、LLM和快捷回复词
+func (h *ConnectionHandler) applySpeaker(match speaker.Match) {
+	var user models.User
+	if err := database.DB.Preload("Setting").Where("id = ?", match.UserID).Take(&user).Error; err != nil {
+		h.LogError(fmt.Sprintf("加载说话人 %d 的设置失败: %v", match.UserID, err))
+		return
+	}
+	setting := user.Setting
+
+	var provider llm.Provider
+	if name := setting.SelectedLLM; name != "" && name != h.selectedLLMName() {
+		var err error
+		if provider, err = h.newLLMProvider(name); err != nil {
+			h.LogError(fmt.Sprintf("创建用户 %s 选择的LLM失败，使用默认LLM: %v", user.Username, err))
+			provider = nil
+		}
+	}
+	var words []string
+	if len(setting.QuickReplyWords) > 0 {
+		if err := json.Unmarshal(setting.QuickReplyWords, &words); err != nil {
+			h.LogError(fmt.Sprintf("解析用户 %s 的快捷回复词失败: %v", user.Username, err))
+		}
+	}
+
+	s := &h.speaker
+	s.mu.Lock()
+	if !s.defaultSaved {
+		s.defaultSaved = true
+		s.defaultPrompt = h.dialogueManager.SystemMessage()
+	}
+	previous := s.llm
+	s.userID, s.llm, s.quickReplyWords = user.ID, provider, words
+	prompt := s.defaultPrompt
+	s.mu.Unlock()
+	if previous != nil {
+		if err := previous.Cleanup(); err != nil {
+			h.LogError(fmt.Sprintf("释放说话人LLM失败: %v", err))
+		}
+	}
+
+	h.memoryOwnerKey = fmt.Sprintf("user:%d", user.ID)
+	h.activeRole.mu.Lock()
+	hasRole := h.activeRole.current != nil
+	h.activeRole.mu.Unlock()
+	// 切换了角色时以角色的提示词为准
+	if !hasRole {
+		if setting.PromptOverride != "" {
+			prompt = setting.PromptOverride
+			if h.config.ProsodyMarkup {
+				prompt += tts.ProsodyPrompt
+			}
+		}
+		h.dialogueManager.SetSystemMessage(prompt)
+	}
+	h.LogInfo(fmt.Sprintf("识别到说话人: %s（用户ID %d，相似度 %.2f）", user.Username, user.ID, match.Score))
+}
+
+// speakerLLM 识别到的用户选择的LLM，没有时返回nil
+func (h *ConnectionHandler) speakerLLM() providers.LLMProvider {
+	h.speaker.mu.Lock()
+	defer h.speaker.mu.Unlock()
+	if h.speaker.llm == nil {
+		return nil
+	}
+	return h.speaker.llm
+}
+
+// quickReplyWords 快捷回复词，识别到的用户设置了快捷回复词时优先使用
+func (h *ConnectionHandler) quickReplyWords() []string {
+	h.speaker.mu.Lock()
+	defer h.speaker.mu.Unlock()
+	if len(h.speaker.quickReplyWords) > 0 {
+		return h.speaker.quickReplyWords
+	}
+	return h.config.QuickReplyWords
+}
+
+// releaseSpeaker 连接关闭时释放用户选择的LLM
+func (h *ConnectionHandler) releaseSpeaker() {
+	s := &h.speaker
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = nil
+	if s.llm != nil {
+		if err := s.llm.Cleanup(); err != nil {
+			h.LogError(fmt.Sprintf("释放说话人LLM失败: %v", err))
+		}
+		s.llm = nil
+	}
+}
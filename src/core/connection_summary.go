@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultSummarizeMaxTurns  = 20
+	defaultSummarizeKeepTurns = 6
+)
+
+// summarizeOldTurns 对话轮次超过阈值时，把较早的轮次压缩为摘要并保存到会话记录
+func (h *ConnectionHandler) summarizeOldTurns(ctx context.Context) {
+	cfg := h.config.Summarization
+	if !cfg.Enabled || h.llmProvider() == nil {
+		return
+	}
+	maxTurns, keepTurns := cfg.MaxTurns, cfg.KeepTurns
+	if maxTurns <= 0 {
+		maxTurns = defaultSummarizeMaxTurns
+	}
+	if keepTurns <= 0 {
+		keepTurns = defaultSummarizeKeepTurns
+	}
+	if keepTurns >= maxTurns {
+		keepTurns = maxTurns - 1
+	}
+
+	removed := h.dialogueManager.SummarizeTurns(ctx, maxTurns, keepTurns, h.summarizeDialogue)
+	if removed == 0 {
+		return
+	}
+	h.LogInfo(fmt.Sprintf("对话超过 %d 轮，已把较早的 %d 条消息压缩为摘要", maxTurns, removed))
+	go h.saveSummary(h.dialogueManager.Summary())
+}
+
+// saveSummary 保存当前会话的摘要，同一会话只保留最新的一条；开启 redaction.transcripts 时先脱敏，
+// 对话上下文中仍使用原文
+func (h *ConnectionHandler) saveSummary(summary string) {
+	if database.DB == nil || summary == "" {
+		return
+	}
+	record := models.ConversationSummary{
+		SessionID: h.sessionID,
+		DeviceID:  h.deviceID,
+		Summary:   h.transcripts.Redact(summary),
+	}
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "session_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"summary", "updated_at"}),
+	}).Create(&record).Error
+	if err != nil {
+		h.LogError(fmt.Sprintf("保存对话摘要失败: %v", err))
+	}
+}
+
+// loadPreviousSummary 载入该设备最近一次会话的摘要，接续之前的对话
+// 当前对话已经有摘要时不覆盖
+func (h *ConnectionHandler) loadPreviousSummary() {
+	cfg := h.config.Summarization
+	if !cfg.Enabled || !cfg.CarryOver || h.summaryLoaded || database.DB == nil || h.deviceID == "" {
+		return
+	}
+	h.summaryLoaded = true
+	if h.dialogueManager.Summary() != "" {
+		return
+	}
+
+	var record models.ConversationSummary
+	err := database.DB.Where("device_id = ? AND session_id <> ?", h.deviceID, h.sessionID).
+		Order("updated_at DESC").Take(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	if err != nil {
+		h.LogError(fmt.Sprintf("加载之前的对话摘要失败: %v", err))
+		return
+	}
+	h.dialogueManager.SetSummary(record.Summary)
+	h.LogInfo(fmt.Sprintf("已载入会话 %s 的对话摘要", record.SessionID))
+}
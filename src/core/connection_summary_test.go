@@ -0,0 +1,20 @@
+package core
+
+import (
+	"testing"
+
+	"xiaozhi-server-go/src/models"
+)
+
+func TestSaveSummaryRedactsSummary(t *testing.T) {
+	db, h := setupTranscriptDB(t, &models.ConversationSummary{})
+	h.saveSummary("用户提到手机号13812345678，想订周末的票")
+
+	var record models.ConversationSummary
+	if err := db.First(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	if record.Summary != "用户提到手机号[电话]，想订周末的票" {
+		t.Errorf("saved summary %q, want personal information redacted", record.Summary)
+	}
+}
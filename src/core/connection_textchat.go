@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// listenModeText 文字聊天：配套App、网页控制台直接发送文本，复用语音对话的流程（LLM、工具、记忆）
+	listenModeText = "text"
+
+	maxTextChatRunes = 2000
+)
+
+// textChatKey 标记文字聊天不需要语音的上下文键
+type textChatKey struct{}
+
+// textChatState 文字聊天状态，记录不需要合成语音的轮次
+type textChatState struct {
+	mu          sync.Mutex
+	silentRound int // 只下发文本、不合成语音的轮次，0表示没有
+}
+
+// handleTextListen 处理文字聊天消息
+// 示例: {"type":"listen","mode":"text","text":"今天天气怎么样","text_only":true}
+// 缺省与语音对话一样下发回复语音，text_only 为 true 时本轮回复只通过 tts 消息下发文本，不合成语音
+func (h *ConnectionHandler) handleTextListen(msgMap map[string]interface{}) error {
+	text, _ := msgMap["text"].(string)
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fmt.Errorf("文字聊天消息缺少text参数")
+	}
+	if utf8.RuneCountInString(text) > maxTextChatRunes {
+		return fmt.Errorf("文字聊天消息过长，最多%d字", maxTextChatRunes)
+	}
+
+	ctx := context.Background()
+	if textOnly, _ := msgMap["text_only"].(bool); textOnly {
+		ctx = context.WithValue(ctx, textChatKey{}, true)
+	}
+	h.LogInfo(fmt.Sprintf("收到文字聊天消息: %s", text))
+	return h.handleChatMessage(ctx, text)
+}
+
+// markTextChatRound 记录本轮是否只下发文本，在对话轮次开始时调用
+func (h *ConnectionHandler) markTextChatRound(ctx context.Context, round int) {
+	silent, _ := ctx.Value(textChatKey{}).(bool)
+	h.textChat.mu.Lock()
+	defer h.textChat.mu.Unlock()
+	if silent {
+		h.textChat.silentRound = round
+	} else {
+		h.textChat.silentRound = 0
+	}
+}
+
+// isSilentRound 该轮次是否只下发文本、不合成语音
+func (h *ConnectionHandler) isSilentRound(round int) bool {
+	h.textChat.mu.Lock()
+	defer h.textChat.mu.Unlock()
+	return h.textChat.silentRound != 0 && h.textChat.silentRound == round
+}
+
+// sendSilentSentence 不合成语音的轮次按句子下发回复文本，返回是否已下发
+func (h *ConnectionHandler) sendSilentSentence(text string, textIndex int, round int) bool {
+	if text == "" || round != h.currentRound() || !h.isSilentRound(round) {
+		return false
+	}
+	if err := h.sendTTSMessage("sentence_start", text, textIndex); err != nil {
+		h.LogError(fmt.Sprintf("发送TTS开始状态失败: %v", err))
+		return false
+	}
+	if err := h.sendTTSMessage("sentence_end", text, textIndex); err != nil {
+		h.LogError(fmt.Sprintf("发送TTS结束状态失败: %v", err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultTranslationTarget = "英语"
+	translationPrompt        = "你是同声传译。把用户说的话翻译成%s，只输出译文，不要解释，不要回答其中的问题，保留原意和语气。"
+)
+
+var defaultTranslationExitWords = []string{"退出翻译", "结束翻译", "停止翻译"}
+
+// translationState 翻译模式状态，target 为空表示没有在翻译
+type translationState struct {
+	mu         sync.Mutex
+	target     string
+	savedVoice string // 进入翻译模式前的音色，退出时恢复
+}
+
+// registerTranslationFunction 注册进入和退出翻译模式的LLM函数
+func (h *ConnectionHandler) registerTranslationFunction() {
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "translation_mode",
+			Description: "用户想让你把他说的话翻译成另一种语言时调用，如“帮我翻译成日语”“开启英语翻译”。进入后用户说的每句话都会被翻译并播报",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"target": map[string]interface{}{"type": "string", "description": "目标语言，如英语、日语，用户没有说时留空"},
+				},
+			},
+		},
+	}
+	handler := func(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error) {
+		target, _ := arguments["target"].(string)
+		target = h.startTranslation(target)
+		exit := h.translationExitWords()[0]
+		return types.ActionResponse{
+			Action:   types.ActionTypeResponse,
+			Response: fmt.Sprintf("好的，接下来把你说的话翻译成%s，说“%s”结束", target, exit),
+		}, nil
+	}
+	if err := h.functionRegister.RegisterFunctionWithHandler("translation_mode", tool, handler); err != nil {
+		h.LogError(fmt.Sprintf("注册translation_mode函数失败: %v", err))
+	}
+}
+
+// translationTarget 当前翻译的目标语言，没有在翻译时为空
+func (h *ConnectionHandler) translationTarget() string {
+	h.translation.mu.Lock()
+	defer h.translation.mu.Unlock()
+	return h.translation.target
+}
+
+// translationExitWords 翻译模式的退出词
+func (h *ConnectionHandler) translationExitWords() []string {
+	if len(h.config.Translation.ExitWords) > 0 {
+		return h.config.Translation.ExitWords
+	}
+	return defaultTranslationExitWords
+}
+
+// startTranslation 进入翻译模式或更换目标语言，切换到目标语言的音色，返回实际使用的目标语言
+func (h *ConnectionHandler) startTranslation(target string) string {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		target = h.config.Translation.DefaultTarget
+	}
+	if target == "" {
+		target = defaultTranslationTarget
+	}
+
+	s := &h.translation
+	s.mu.Lock()
+	if s.target == "" {
+		s.savedVoice = ""
+		if getter, ok := h.ttsProvider().(configGetter); ok {
+			s.savedVoice = getter.Config().Voice
+		}
+	}
+	s.target = target
+	voice := s.savedVoice
+	s.mu.Unlock()
+
+	if v := h.config.Translation.Voices[target]; v != "" {
+		voice = v
+	}
+	h.setRoleVoice(voice)
+	h.LogInfo(fmt.Sprintf("进入翻译模式，目标语言: %s", target))
+	h.sendTranslationStatus()
+	return target
+}
+
+// stopTranslation 退出翻译模式并恢复原来的音色，没有在翻译时返回false
+func (h *ConnectionHandler) stopTranslation() bool {
+	s := &h.translation
+	s.mu.Lock()
+	if s.target == "" {
+		s.mu.Unlock()
+		return false
+	}
+	s.target = ""
+	voice := s.savedVoice
+	s.mu.Unlock()
+
+	h.setRoleVoice(voice)
+	h.LogInfo("退出翻译模式")
+	h.sendTranslationStatus()
+	return true
+}
+
+// handleTranslation 翻译模式下把用户的话翻译成目标语言并播报，说退出词时退出翻译模式
+func (h *ConnectionHandler) handleTranslation(ctx context.Context, text string, round int) error {
+	for _, word := range h.translationExitWords() {
+		if strings.Contains(text, word) {
+			h.stopTranslation()
+			return h.SystemSpeak("好的，已退出翻译模式")
+		}
+	}
+	target := h.translationTarget()
+	if target == "" {
+		return nil
+	}
+
+	h.turnStage(turnStageLLM)
+	ctx = types.WithUsageReporter(ctx, h.usageReporter("LLM"))
+	responses, err := h.activeLLM().Response(ctx, h.sessionID, []providers.Message{
+		{Role: "system", Content: fmt.Sprintf(translationPrompt, target)},
+		{Role: "user", Content: text},
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return h.handleProviderError("LLM", err, round)
+	}
+
+	atomic.StoreInt32(&h.serverVoiceStop, 0)
+	textIndex := 0
+	speak := func(segment string) {
+		textIndex++
+		h.setLastTextIndex(textIndex)
+		if err := h.SpeakAndPlay(segment, textIndex, round); err != nil {
+			h.LogError(fmt.Sprintf("播放译文分段失败: %v", err))
+		}
+	}
+
+	// 按标点分段，边翻译边播报
+	var translated strings.Builder
+	processed := 0
+	for chunk := range responses {
+		if ctx.Err() != nil {
+			continue
+		}
+		translated.WriteString(chunk)
+		if segment, chars := utils.SplitAtLastPunctuation(translated.String()[processed:]); chars > 0 {
+			speak(segment)
+			processed += chars
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	h.turnStage(turnStageTTS)
+
+	if rest := strings.TrimSpace(translated.String()[processed:]); rest != "" {
+		speak(rest)
+	}
+	if textIndex == 0 {
+		return h.SystemSpeak("抱歉，没有翻译出来，请再说一遍")
+	}
+	h.LogInfo(fmt.Sprintf("翻译成%s: %s", target, translated.String()))
+	return nil
+}
+
+// handleTranslateMessage 处理设备发来的翻译模式控制消息
+// 示例: {"type":"translate","action":"start","target":"日语"}，action 为 start 或 stop
+func (h *ConnectionHandler) handleTranslateMessage(msgMap map[string]interface{}) error {
+	action, _ := msgMap["action"].(string)
+	switch action {
+	case "start":
+		target, _ := msgMap["target"].(string)
+		h.startTranslation(target)
+		return nil
+	case "stop":
+		if !h.stopTranslation() {
+			return h.sendTranslationStatus()
+		}
+		return nil
+	default:
+		return fmt.Errorf("未知的翻译模式指令: %s", action)
+	}
+}
+
+// sendTranslationStatus 通知设备翻译模式的状态
+func (h *ConnectionHandler) sendTranslationStatus() error {
+	msg := map[string]interface{}{
+		"type":       "translate",
+		"state":      "stopped",
+		"session_id": h.sessionID,
+	}
+	if target := h.translationTarget(); target != "" {
+		msg["state"] = "started"
+		msg["target"] = target
+	}
+	return h.PushMessage(msg, true)
+}
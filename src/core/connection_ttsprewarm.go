@@ -0,0 +1,183 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/task"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TaskTypeTTSPrewarm 预先合成快速回复词和常用短句，保存到快速回复缓存，之后直接播放缓存音频
+const TaskTypeTTSPrewarm task.TaskType = "tts_prewarm"
+
+func init() {
+	task.RegisterTaskExecutor(TaskTypeTTSPrewarm, func(t *task.Task) error {
+		job, ok := t.Params.(*ttsPrewarmJob)
+		if !ok {
+			return fmt.Errorf("invalid params for task type %v", TaskTypeTTSPrewarm)
+		}
+		return job.run(t.Context)
+	})
+}
+
+// ttsPrewarmJob 预合成任务参数
+type ttsPrewarmJob struct {
+	provider providers.TTSProvider
+	cache    *utils.QuickReplyCache
+	phrases  []string
+	stop     func() bool // 返回true时停止，例如连接上的提供者即将归还
+}
+
+// run 合成缓存中还没有的短句，单句失败不影响其他短句
+func (job *ttsPrewarmJob) run(ctx context.Context) error {
+	synthesized, failed := 0, 0
+	var lastErr error
+	for _, phrase := range job.phrases {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if job.stop != nil && job.stop() {
+			return fmt.Errorf("提供者即将归还，停止预合成")
+		}
+		if job.cache.FindCachedAudio(phrase) != "" {
+			continue
+		}
+		// 连接上的提供者可能在任务执行期间切换音色或语速，此时停止，避免按旧参数缓存
+		if current := quickReplyCacheFor(job.provider); current.Variant() != job.cache.Variant() {
+			return fmt.Errorf("TTS参数已切换为 %s，停止预合成", current.Variant())
+		}
+		path, err := job.provider.ToTTS(phrase)
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		err = job.cache.SaveCachedAudio(phrase, path)
+		os.Remove(path)
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		synthesized++
+	}
+
+	fields := logrus.Fields{"tts": job.cache.TTSProvider, "voice": job.cache.VoiceName, "synthesized": synthesized}
+	if failed > 0 {
+		logrus.WithFields(fields).WithField("failed", failed).Warnf("部分短句预合成失败: %v", lastErr)
+		if synthesized == 0 {
+			return fmt.Errorf("预合成失败: %v", lastErr)
+		}
+		return nil
+	}
+	if synthesized > 0 {
+		logrus.WithFields(fields).Info("常用短句预合成完成")
+	}
+	return nil
+}
+
+// prewarmPhrases 需要预合成的短句：快速回复词加上 tts_prewarm.phrases，去重
+func prewarmPhrases(quickReplyWords []string, config *configs.Config) []string {
+	seen := make(map[string]bool)
+	var phrases []string
+	for _, list := range [][]string{quickReplyWords, config.TTSPrewarm.Phrases} {
+		for _, phrase := range list {
+			if phrase != "" && !seen[phrase] {
+				seen[phrase] = true
+				phrases = append(phrases, phrase)
+			}
+		}
+	}
+	return phrases
+}
+
+// submitTTSPrewarm 以后台优先级提交预合成任务，stop 可为nil，done 在任务结束或提交失败时调用
+func submitTTSPrewarm(ctx context.Context, taskMgr *task.TaskManager, provider providers.TTSProvider, phrases []string, stop func() bool, done func()) error {
+	finish := func() {
+		if done != nil {
+			done()
+		}
+	}
+	if taskMgr == nil || provider == nil || len(phrases) == 0 {
+		finish()
+		return nil
+	}
+	job := &ttsPrewarmJob{
+		provider: provider,
+		cache:    quickReplyCacheFor(provider),
+		phrases:  phrases,
+		stop:     stop,
+	}
+	t, _ := task.NewTask(ctx, TaskTypeTTSPrewarm, job)
+	t.Priority = task.PriorityBackground
+	t.Callback = task.NewCallBack(func(result interface{}) {
+		finish()
+	})
+	if err := taskMgr.SubmitInternalTask(t); err != nil {
+		finish()
+		return err
+	}
+	return nil
+}
+
+// isCachedPhrase 文本是否为预合成或首次合成后缓存的短句
+func (h *ConnectionHandler) isCachedPhrase(text string) bool {
+	return utils.IsQuickReplyHit(text, h.quickReplyWords()) ||
+		utils.IsInArray(text, h.config.TTSPrewarm.Phrases)
+}
+
+// prewarmQuickReplies 音色变化后按新音色预合成快速回复词和常用短句，连接关闭或设备休眠时停止。
+// 任务期间持有提供者使用计数，休眠会等待正在合成的短句结束后再归还提供者
+func (h *ConnectionHandler) prewarmQuickReplies() {
+	if !h.config.TTSPrewarm.Enabled {
+		return
+	}
+	provider := h.ttsProvider()
+	if provider == nil || !h.beginProviderUse() {
+		return
+	}
+	phrases := prewarmPhrases(h.quickReplyWords(), h.config)
+	if err := submitTTSPrewarm(h.ctx, h.taskMgr, provider, phrases, h.isAsleep, h.endProviderUse); err != nil {
+		h.LogError(fmt.Sprintf("提交短句预合成任务失败: %v", err))
+	}
+}
+
+// prewarmTTS 按默认音色预合成快速回复词和常用短句，启动和重载配置时调用。
+// TTS提供者从资源池单独借用，任务结束后归还
+func (ws *WebSocketServer) prewarmTTS(config *configs.Config) {
+	if !config.TTSPrewarm.Enabled {
+		return
+	}
+	phrases := prewarmPhrases(config.QuickReplyWords, config)
+	if len(phrases) == 0 {
+		return
+	}
+	provider, err := ws.poolManager.GetTTSProvider()
+	if err != nil {
+		logrus.Warnf("获取TTS提供者失败，跳过短句预合成: %v", err)
+		return
+	}
+	release := func() {
+		if err := ws.poolManager.ReturnTTSProvider(provider); err != nil {
+			logrus.Warnf("归还TTS提供者失败: %v", err)
+		}
+	}
+	if err := submitTTSPrewarm(context.Background(), ws.taskMgr, provider, phrases, nil, release); err != nil {
+		logrus.Warnf("提交短句预合成任务失败: %v", err)
+	}
+}
+
+// Reload 重载资源池配置，TTS配置变化后按新的默认音色预合成短句，ProviderReloader接口实现
+func (ws *WebSocketServer) Reload(config *configs.Config) error {
+	if err := ws.poolManager.Reload(config); err != nil {
+		return err
+	}
+	ws.prewarmTTS(config)
+	return nil
+}
@@ -0,0 +1,221 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"xiaozhi-server-go/src/task"
+)
+
+// defaultTTSParallelism 单连接默认同时合成的句子数
+const defaultTTSParallelism = 3
+
+// ttsSegmentResult 单个句子的合成结果
+type ttsSegmentResult struct {
+	seq       int   // 同一批次内的播放序号
+	epoch     int32 // 所属批次，打断后递增
+	filepath  string
+	stream    <-chan []byte
+	text      string
+	round     int
+	textIndex int
+}
+
+// processTTSQueueCoroutine 处理TTS队列，为每个句子分配序号并提交并行合成
+func (h *ConnectionHandler) processTTSQueueCoroutine() {
+	seq := 0
+	epoch := atomic.LoadInt32(&h.ttsEpoch)
+	for {
+		select {
+		case <-h.stopChan:
+			return
+		case item := <-h.ttsQueue:
+			// 打断后序号从0重新开始
+			if current := atomic.LoadInt32(&h.ttsEpoch); current != epoch {
+				epoch = current
+				seq = 0
+			}
+			// 并发已满时等待，当前句播放期间最多预合成 ttsSem 容量个句子
+			select {
+			case h.ttsSem <- struct{}{}:
+			case <-h.stopChan:
+				return
+			}
+			h.dispatchTTSSegment(seq, epoch, item.text, item.textIndex, item.round)
+			seq++
+		}
+	}
+}
+
+// dispatchTTSSegment 通过任务管理器的工作池合成一个句子，结果写入 ttsResults。
+// 无论合成成功、失败、超时还是任务被丢弃，每个序号都恰好交付一次结果（失败时为空结果），
+// 否则重排协程会一直等待该序号，后续句子都无法播放
+func (h *ConnectionHandler) dispatchTTSSegment(seq int, epoch int32, text string, textIndex int, round int) {
+	empty := ttsSegmentResult{seq: seq, epoch: epoch, text: text, round: round, textIndex: textIndex}
+	var once sync.Once
+	stopWatch := func() bool { return false }
+	deliver := func(result ttsSegmentResult) (delivered bool) {
+		once.Do(func() {
+			delivered = true
+			if result.stream != nil {
+				// 流式合成在读完之前仍在进行，读完后才释放并发名额
+				result.stream = h.releaseWhenDrained(result.stream)
+			} else {
+				<-h.ttsSem
+			}
+			select {
+			case h.ttsResults <- result:
+			case <-h.stopChan:
+				h.discardAudio(result.filepath, result.stream, "连接关闭时")
+			}
+		})
+		return delivered
+	}
+
+	// 文字聊天不需要语音时跳过合成，由播放协程只下发文本
+	if h.isSilentRound(round) {
+		deliver(empty)
+		return
+	}
+
+	run := func() error {
+		// 设备进入休眠后TTS提供者即将归还，不再合成
+		if !h.beginProviderUse() {
+			deliver(empty)
+			stopWatch()
+			return nil
+		}
+		filepath, stream, outText := h.processTTSTask(text, textIndex, round)
+		if stream != nil {
+			// 流式合成读完之前仍在使用提供者
+			stream = h.afterDrained(stream, h.endProviderUse)
+		} else {
+			h.endProviderUse()
+		}
+		if !deliver(ttsSegmentResult{seq, epoch, filepath, stream, outText, round, textIndex}) {
+			// 合成超时，已交付空结果
+			h.discardAudio(filepath, stream, "合成超时后")
+		}
+		stopWatch()
+		return nil
+	}
+
+	if h.taskMgr == nil {
+		go run()
+		return
+	}
+
+	t, _ := task.NewTask(h.ctx, task.TaskTypeFunc, run)
+	t.Priority = task.PriorityRealtime
+	// 单句合成超过TTS阶段超时后放弃，避免卡住的TTS服务占用工作者
+	t.Timeout = h.stageTimeout(turnStageTTS)
+	// 任务以任何方式结束都交付结果，已交付时不重复
+	t.Callback = task.NewCallBack(func(result interface{}) {
+		if result != nil {
+			h.LogError(fmt.Sprintf("TTS合成任务执行失败: %v, 索引: %d", result, textIndex))
+		}
+		deliver(empty)
+		stopWatch()
+	})
+	// 连接的context结束后任务不再执行也不会回调，此时同样交付空结果。
+	// run 和回调都在提交之后执行，读取 stopWatch 时已赋值
+	stopWatch = context.AfterFunc(h.ctx, func() { deliver(empty) })
+	if err := h.taskMgr.SubmitInternalTask(t); err != nil {
+		// 工作池繁忙时退回到独立协程合成
+		h.logger.Debug("TTS合成任务提交失败，使用独立协程: %v", err)
+		go run()
+	}
+}
+
+// releaseWhenDrained 转发合成流，数据读完或连接关闭后释放并发名额
+func (h *ConnectionHandler) releaseWhenDrained(stream <-chan []byte) <-chan []byte {
+	return h.afterDrained(stream, func() { <-h.ttsSem })
+}
+
+// afterDrained 转发合成流，数据读完或连接关闭后调用 done
+func (h *ConnectionHandler) afterDrained(stream <-chan []byte, done func()) <-chan []byte {
+	out := make(chan []byte, cap(stream))
+	go func() {
+		defer done()
+		defer close(out)
+		for chunk := range stream {
+			select {
+			case out <- chunk:
+			case <-h.stopChan:
+				for range stream {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// ttsReorderCoroutine 按序号重排并行合成的结果，保证严格按句子顺序播放
+func (h *ConnectionHandler) ttsReorderCoroutine() {
+	pending := make(map[int]ttsSegmentResult)
+	next := 0
+	epoch := atomic.LoadInt32(&h.ttsEpoch)
+
+	discardPending := func(reason string) {
+		for seq, result := range pending {
+			h.discardAudio(result.filepath, result.stream, reason)
+			delete(pending, seq)
+		}
+	}
+
+	for {
+		select {
+		case <-h.stopChan:
+			discardPending("连接关闭时")
+			return
+		case result := <-h.ttsResults:
+			if current := atomic.LoadInt32(&h.ttsEpoch); current != epoch {
+				discardPending("打断后丢弃合成结果时")
+				epoch = current
+				next = 0
+			}
+			if result.epoch != epoch {
+				h.discardAudio(result.filepath, result.stream, "打断后丢弃合成结果时")
+				continue
+			}
+
+			pending[result.seq] = result
+			for {
+				ready, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				select {
+				case h.audioMessagesQueue <- struct {
+					filepath  string
+					stream    <-chan []byte
+					text      string
+					round     int
+					textIndex int
+				}{ready.filepath, ready.stream, ready.text, ready.round, ready.textIndex}:
+				case <-h.stopChan:
+					h.discardAudio(ready.filepath, ready.stream, "连接关闭时")
+					discardPending("连接关闭时")
+					return
+				}
+			}
+		}
+	}
+}
+
+// discardAudio 丢弃未播放的音频，消费剩余的流式数据并按配置删除音频文件
+func (h *ConnectionHandler) discardAudio(filepath string, stream <-chan []byte, reason string) {
+	if stream != nil {
+		// 消费剩余的流式数据，避免合成协程阻塞
+		go func(stream <-chan []byte) {
+			for range stream {
+			}
+		}(stream)
+	}
+	h.deleteAudioFileIfNeeded(filepath, reason)
+}
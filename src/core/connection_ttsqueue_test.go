@@ -0,0 +1,55 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReleaseWhenDrained(t *testing.T) {
+	h := &ConnectionHandler{ttsSem: make(chan struct{}, 1), stopChan: make(chan struct{})}
+	h.ttsSem <- struct{}{}
+
+	stream := make(chan []byte, 2)
+	out := h.releaseWhenDrained(stream)
+	stream <- []byte{1}
+	if got := <-out; len(got) != 1 {
+		t.Fatalf("chunk = %v", got)
+	}
+	// 流未结束时仍占用并发名额
+	select {
+	case h.ttsSem <- struct{}{}:
+		t.Fatal("semaphore released before the stream was drained")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(stream)
+	for range out {
+	}
+	select {
+	case h.ttsSem <- struct{}{}:
+	case <-time.After(time.Second):
+		t.Fatal("semaphore not released after the stream was drained")
+	}
+}
+
+func TestReleaseWhenDrainedOnStop(t *testing.T) {
+	h := &ConnectionHandler{ttsSem: make(chan struct{}, 1), stopChan: make(chan struct{})}
+	h.ttsSem <- struct{}{}
+
+	stream := make(chan []byte)
+	out := h.releaseWhenDrained(stream)
+	close(h.stopChan)
+	go func() {
+		stream <- []byte{1}
+		stream <- []byte{2}
+		close(stream)
+	}()
+
+	select {
+	case h.ttsSem <- struct{}{}:
+	case <-time.After(time.Second):
+		t.Fatal("semaphore not released after the connection stopped")
+	}
+	for range out {
+	}
+}
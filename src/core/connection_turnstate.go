@@ -0,0 +1,85 @@
+package core
+
+import (
+	"sync/atomic"
+)
+
+// 轮次号、最后一句的序号、客户端识别文本和拾音状态由读取、文本、音频、发送等多个协程读写，
+// 统一通过以下方法访问；打断当前轮次统一使用 startNewTurn
+
+// currentRound 当前轮次号
+func (h *ConnectionHandler) currentRound() int {
+	return int(atomic.LoadInt64(&h.talkRound))
+}
+
+// nextRound 开始新的轮次，返回新的轮次号，旧轮次在途的输出随之失效
+func (h *ConnectionHandler) nextRound() int {
+	return int(atomic.AddInt64(&h.talkRound, 1))
+}
+
+// lastTextIndex 本轮最后一句的序号，发送完该句后结束本轮播报
+func (h *ConnectionHandler) lastTextIndex() int {
+	return int(atomic.LoadInt64(&h.tts_last_text_index))
+}
+
+// setLastTextIndex 设置本轮最后一句的序号
+func (h *ConnectionHandler) setLastTextIndex(index int) {
+	atomic.StoreInt64(&h.tts_last_text_index, int64(index))
+}
+
+// isClientVoiceStop 客户端是否已停止拾音
+func (h *ConnectionHandler) isClientVoiceStop() bool {
+	return atomic.LoadInt32(&h.clientVoiceStop) == 1
+}
+
+// setClientVoiceStop 设置客户端拾音状态
+func (h *ConnectionHandler) setClientVoiceStop(stop bool) {
+	var v int32
+	if stop {
+		v = 1
+	}
+	atomic.StoreInt32(&h.clientVoiceStop, v)
+}
+
+// clientASRText 手动拾音模式下已累积的识别文本
+func (h *ConnectionHandler) clientASRText() string {
+	h.asrTextMu.Lock()
+	defer h.asrTextMu.Unlock()
+	return h.client_asr_text
+}
+
+// appendClientASRText 追加识别结果，返回累积后的文本
+func (h *ConnectionHandler) appendClientASRText(text string) string {
+	h.asrTextMu.Lock()
+	defer h.asrTextMu.Unlock()
+	h.client_asr_text += text
+	return h.client_asr_text
+}
+
+// resetClientASRText 清空累积的识别文本
+func (h *ConnectionHandler) resetClientASRText() {
+	h.asrTextMu.Lock()
+	defer h.asrTextMu.Unlock()
+	h.client_asr_text = ""
+}
+
+// startNewTurn 结束当前轮次并开始新的轮次：取消LLM请求和追问、丢弃合成与待播放的音频、清空识别文本，返回新的轮次号。
+// 按键打断在读取协程、语音打断在音频协程中发起，同时发起时依次执行。
+// userSpeaking 为true时用户正在说话（语音打断），VAD保持在语音段中并继续拾音；否则重置VAD
+func (h *ConnectionHandler) startNewTurn(userSpeaking bool) int {
+	h.turnMu.Lock()
+	defer h.turnMu.Unlock()
+
+	h.endTurn() // 取消本轮上下文，流式LLM请求随之结束
+	h.cancelReprompt()
+	round := h.nextRound()
+	h.stopServerSpeak()
+	h.clearSpeakStatus()
+	h.resetClientASRText()
+	if userSpeaking {
+		h.setClientVoiceStop(false)
+	} else {
+		h.resetVAD()
+	}
+	return round
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"sync"
+	"testing"
+)
+
+// 使用 -race 运行：按键打断和语音打断在不同协程中发起，同时其他协程读写轮次和识别状态
+func TestStartNewTurnConcurrent(t *testing.T) {
+	h := &ConnectionHandler{stopChan: make(chan struct{})}
+	const perWriter = 50
+
+	var writers, readers sync.WaitGroup
+	done := make(chan struct{})
+	for _, userSpeaking := range []bool{false, true} {
+		writers.Add(1)
+		go func(userSpeaking bool) {
+			defer writers.Done()
+			for i := 0; i < perWriter; i++ {
+				h.startNewTurn(userSpeaking)
+			}
+		}(userSpeaking)
+	}
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			_ = h.currentRound()
+			_ = h.lastTextIndex()
+			_ = h.isClientVoiceStop()
+			h.appendClientASRText("好")
+		}
+	}()
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	if got := h.currentRound(); got != 2*perWriter {
+		t.Errorf("round = %d, want %d", got, 2*perWriter)
+	}
+}
+
+func TestStartNewTurnResetsListenState(t *testing.T) {
+	h := &ConnectionHandler{stopChan: make(chan struct{})}
+	h.appendClientASRText("打开")
+	h.setClientVoiceStop(true)
+	h.setLastTextIndex(3)
+
+	// 语音打断：用户正在说话，继续拾音
+	if round := h.startNewTurn(true); round != 1 {
+		t.Errorf("round = %d, want 1", round)
+	}
+	if h.clientASRText() != "" || h.isClientVoiceStop() || h.lastTextIndex() != -1 {
+		t.Errorf("state after barge-in: text=%q stop=%v last=%d", h.clientASRText(), h.isClientVoiceStop(), h.lastTextIndex())
+	}
+
+	// 按键打断不改变拾音状态
+	h.setClientVoiceStop(true)
+	h.startNewTurn(false)
+	if !h.isClientVoiceStop() {
+		t.Error("button interrupt changed the listen state")
+	}
+}
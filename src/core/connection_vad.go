@@ -0,0 +1,125 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/vad"
+)
+
+// vadState 服务端语音活动检测，按 selected_module 中的VAD启用
+type vadState struct {
+	mu        sync.Mutex
+	provider  vad.Provider
+	segmenter *vad.Segmenter
+}
+
+// initVAD 客户端音频参数确定后创建VAD，Silero不可用时回退到webrtc
+func (h *ConnectionHandler) initVAD() {
+	h.releaseVAD()
+	name := h.config.SelectedModule["VAD"]
+	if name == "" {
+		return
+	}
+	if h.clientAudioFormat == "opus" && h.opusDecoder == nil {
+		h.LogError("Opus解码器不可用，不启用服务端VAD")
+		return
+	}
+	cfg, ok := h.config.VAD[name]
+	if !ok {
+		h.LogError(fmt.Sprintf("VAD配置 %s 不存在，不启用服务端VAD", name))
+		return
+	}
+	vadConfig := &vad.Config{
+		Type:               cfg.Type,
+		ModelDir:           cfg.ModelDir,
+		Threshold:          cfg.Threshold,
+		MinSilenceDuration: cfg.MinSilenceDuration,
+		Extra:              cfg.Extra,
+	}
+	provider, err := vad.Create(cfg.Type, vadConfig)
+	if err != nil && cfg.Type != "webrtc" {
+		h.LogError(fmt.Sprintf("创建VAD %s 失败，回退到webrtc: %v", name, err))
+		provider, err = vad.Create("webrtc", vadConfig)
+	}
+	if err != nil {
+		h.LogError(fmt.Sprintf("创建VAD失败，不启用服务端VAD: %v", err))
+		return
+	}
+	segmenter, err := vad.NewSegmenter(provider, vadConfig, h.clientAudioSampleRate, h.clientAudioChannels)
+	if err != nil {
+		provider.Cleanup()
+		h.LogError(fmt.Sprintf("创建VAD分段器失败，不启用服务端VAD: %v", err))
+		return
+	}
+
+	h.vad.mu.Lock()
+	h.vad.provider = provider
+	h.vad.segmenter = segmenter
+	h.vad.mu.Unlock()
+	h.LogInfo(fmt.Sprintf("服务端VAD已启用: %s", name))
+}
+
+// detectVoice 对解码后的上行PCM做语音检测，播报期间开口时打断，检测到说话结束时结束本次识别
+func (h *ConnectionHandler) detectVoice(pcm []byte) {
+	h.vad.mu.Lock()
+	if h.vad.segmenter == nil {
+		h.vad.mu.Unlock()
+		return
+	}
+	events, err := h.vad.segmenter.Feed(pcm)
+	h.vad.mu.Unlock()
+	if err != nil {
+		h.LogError(err.Error())
+	}
+
+	for _, event := range events {
+		switch event {
+		case vad.EventSpeechStart:
+			h.logger.Debug("服务端VAD检测到开始说话")
+			h.checkBargeIn()
+		case vad.EventSpeechEnd:
+			h.onVoiceEnd()
+		}
+	}
+}
+
+// onVoiceEnd 说话结束：手动模式下视同客户端停止拾音，并通知ASR尽快返回最终结果
+func (h *ConnectionHandler) onVoiceEnd() {
+	h.LogInfo("服务端VAD检测到说话结束")
+	if h.clientListenMode == "manual" && atomic.CompareAndSwapInt32(&h.clientVoiceStop, 0, 1) {
+		h.turnStage(turnStageASR)
+	}
+	if finisher, ok := h.asrProvider().(providers.AsrFinisher); ok {
+		if err := finisher.Finish(); err != nil {
+			h.LogError(fmt.Sprintf("结束语音识别失败: %v", err))
+		}
+	}
+}
+
+// resetVAD 开始新一次拾音时清除分段状态
+func (h *ConnectionHandler) resetVAD() {
+	h.vad.mu.Lock()
+	defer h.vad.mu.Unlock()
+	if h.vad.segmenter == nil {
+		return
+	}
+	if err := h.vad.segmenter.Reset(); err != nil {
+		h.LogError(fmt.Sprintf("重置VAD状态失败: %v", err))
+	}
+}
+
+// releaseVAD 释放VAD
+func (h *ConnectionHandler) releaseVAD() {
+	h.vad.mu.Lock()
+	defer h.vad.mu.Unlock()
+	if h.vad.provider != nil {
+		if err := h.vad.provider.Cleanup(); err != nil {
+			h.LogError(fmt.Sprintf("释放VAD失败: %v", err))
+		}
+	}
+	h.vad.provider = nil
+	h.vad.segmenter = nil
+}
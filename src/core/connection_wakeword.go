@@ -0,0 +1,193 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/providers/wakeword"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+)
+
+// listenModeWakeWord 设备持续上传音频，由服务端检测唤醒词，检测到之后切换为auto模式
+const listenModeWakeWord = "wakeword"
+
+// wakeWordState 服务端唤醒词检测，按 selected_module 中的WakeWord启用
+type wakeWordState struct {
+	mu        sync.Mutex
+	provider  wakeword.Provider
+	resampler *utils.PCMResampler
+}
+
+// initWakeWord 客户端音频参数确定后创建唤醒词检测，并应用设备单独设置的唤醒词
+func (h *ConnectionHandler) initWakeWord() {
+	h.releaseWakeWord()
+	name := h.config.SelectedModule["WakeWord"]
+	if name == "" {
+		return
+	}
+	if h.clientAudioFormat == "opus" && h.opusDecoder == nil {
+		h.LogError("Opus解码器不可用，不启用服务端唤醒词检测")
+		return
+	}
+	cfg, ok := h.config.WakeWord[name]
+	if !ok {
+		h.LogError(fmt.Sprintf("唤醒词检测配置 %s 不存在，不启用服务端唤醒词检测", name))
+		return
+	}
+	provider, err := wakeword.Create(cfg.Type, &wakeword.Config{
+		Type:      cfg.Type,
+		Keywords:  cfg.Keywords,
+		Threshold: cfg.Threshold,
+		Extra:     cfg.Extra,
+	})
+	if err != nil {
+		h.LogError(fmt.Sprintf("创建唤醒词检测失败，不启用服务端唤醒词检测: %v", err))
+		return
+	}
+	resampler, err := utils.NewPCMResampler(h.clientAudioSampleRate, h.clientAudioChannels, provider.SampleRate())
+	if err != nil {
+		provider.Cleanup()
+		h.LogError(fmt.Sprintf("创建唤醒词检测失败，不启用服务端唤醒词检测: %v", err))
+		return
+	}
+	keywords := h.deviceWakeWords()
+	if len(keywords) > 0 {
+		if err := provider.SetKeywords(keywords); err != nil {
+			h.LogError(fmt.Sprintf("设备唤醒词无效，使用默认唤醒词: %v", err))
+			keywords = nil
+		}
+	}
+
+	h.wakeWord.mu.Lock()
+	h.wakeWord.provider = provider
+	h.wakeWord.resampler = resampler
+	h.wakeWord.mu.Unlock()
+	if len(keywords) == 0 {
+		keywords = cfg.Keywords
+	}
+	h.LogInfo(fmt.Sprintf("服务端唤醒词检测已启用: %s, 唤醒词: %v", name, keywords))
+}
+
+// deviceWakeWords 设备在 devices.wake_words 中单独设置的唤醒词
+func (h *ConnectionHandler) deviceWakeWords() []string {
+	if database.DB == nil || h.deviceID == "" {
+		return nil
+	}
+	var device models.Device
+	err := database.DB.Select("wake_words").Where("device_id = ?", h.deviceID).Take(&device).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			h.LogError(fmt.Sprintf("加载设备唤醒词失败: %v", err))
+		}
+		return nil
+	}
+	if len(device.WakeWords) == 0 {
+		return nil
+	}
+	var keywords []string
+	if err := json.Unmarshal(device.WakeWords, &keywords); err != nil {
+		h.LogError(fmt.Sprintf("解析设备唤醒词失败: %v", err))
+		return nil
+	}
+	return keywords
+}
+
+// detectWakeWord 拾音模式为wakeword时检测唤醒词，这期间的音频不交给ASR
+func (h *ConnectionHandler) detectWakeWord(pcm []byte) {
+	h.wakeWord.mu.Lock()
+	if h.wakeWord.provider == nil {
+		h.wakeWord.mu.Unlock()
+		return
+	}
+	keyword, err := h.wakeWord.provider.AcceptAudio(h.wakeWord.resampler.Float32(pcm))
+	if keyword != "" {
+		// 清除已检测的唤醒词音频，下次进入wakeword模式时不会立即再次唤醒
+		h.wakeWord.resampler.Reset()
+		if err := h.wakeWord.provider.Reset(); err != nil {
+			h.LogError(fmt.Sprintf("重置唤醒词检测失败: %v", err))
+		}
+	}
+	h.wakeWord.mu.Unlock()
+	if err != nil {
+		h.LogError(fmt.Sprintf("唤醒词检测失败: %v", err))
+		return
+	}
+	if keyword != "" {
+		h.onWakeWord(keyword)
+	}
+}
+
+// onWakeWord 检测到唤醒词：通知设备并切换为auto拾音，之后按设备端唤醒（listen detect）处理
+func (h *ConnectionHandler) onWakeWord(keyword string) {
+	h.LogInfo(fmt.Sprintf("服务端检测到唤醒词: %s", keyword))
+	h.clientListenMode = "auto"
+	h.setClientVoiceStop(false)
+	h.resetClientASRText()
+	h.resetVAD()
+	if err := h.sendWakeWordMessage(keyword); err != nil {
+		h.LogError(fmt.Sprintf("发送唤醒词消息失败: %v", err))
+	}
+
+	// 交给消息处理协程执行，与设备端检测到唤醒词时的处理一致
+	data, err := json.Marshal(map[string]interface{}{
+		"type":  "listen",
+		"state": "detect",
+		"text":  keyword,
+	})
+	if err != nil {
+		return
+	}
+	select {
+	case h.clientTextQueue <- string(data):
+	default:
+		h.LogError("消息队列已满，丢弃唤醒词消息")
+	}
+}
+
+// sendWakeWordMessage 通知设备服务端检测到唤醒词，设备应切换到auto拾音状态
+func (h *ConnectionHandler) sendWakeWordMessage(keyword string) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":       "wakeword",
+		"state":      "detected",
+		"keyword":    keyword,
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化唤醒词消息失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, data)
+}
+
+// startWakeWordListen 进入wakeword拾音模式时丢弃之前的音频，未启用服务端唤醒词检测时改为auto模式
+func (h *ConnectionHandler) startWakeWordListen() {
+	h.wakeWord.mu.Lock()
+	defer h.wakeWord.mu.Unlock()
+	if h.wakeWord.provider == nil {
+		h.LogError("未启用服务端唤醒词检测，按auto模式拾音")
+		h.clientListenMode = "auto"
+		return
+	}
+	h.wakeWord.resampler.Reset()
+	if err := h.wakeWord.provider.Reset(); err != nil {
+		h.LogError(fmt.Sprintf("重置唤醒词检测失败: %v", err))
+	}
+}
+
+// releaseWakeWord 释放唤醒词检测
+func (h *ConnectionHandler) releaseWakeWord() {
+	h.wakeWord.mu.Lock()
+	defer h.wakeWord.mu.Unlock()
+	if h.wakeWord.provider != nil {
+		if err := h.wakeWord.provider.Cleanup(); err != nil {
+			h.LogError(fmt.Sprintf("释放唤醒词检测失败: %v", err))
+		}
+	}
+	h.wakeWord.provider = nil
+	h.wakeWord.resampler = nil
+}
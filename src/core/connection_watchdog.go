@@ -0,0 +1,205 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 对话轮次所处的阶段
+const (
+	turnStageASR = "asr" // 停止拾音后等待识别结果
+	turnStageLLM = "llm" // 等待LLM输出
+	turnStageTTS = "tts" // 合成与播放
+)
+
+// 看门狗默认限制
+const (
+	defaultMaxTurnDuration = 120 * time.Second
+	defaultASRTimeout      = 10 * time.Second
+	defaultLLMTimeout      = 20 * time.Second
+	defaultTTSTimeout      = 30 * time.Second
+	defaultWatchdogMessage = "抱歉，我刚才走神了，请再说一遍吧"
+)
+
+// turnWatchdog 单轮对话看门狗
+// 整轮计时器限制一轮对话的总时长，阶段计时器在每次有进展时重新计时，限制单个阶段的停滞时间
+type turnWatchdog struct {
+	mu         sync.Mutex
+	active     bool
+	round      int
+	stage      string
+	turnTimer  *time.Timer
+	stageTimer *time.Timer
+	stageGen   int // 阶段计时器的代数，过期计时器的回调据此忽略
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+// stop 停止计时器并取消本轮上下文，调用方需持有锁
+func (w *turnWatchdog) stop() {
+	if w.turnTimer != nil {
+		w.turnTimer.Stop()
+		w.turnTimer = nil
+	}
+	if w.stageTimer != nil {
+		w.stageTimer.Stop()
+		w.stageTimer = nil
+	}
+	if w.cancel != nil {
+		w.cancel()
+		w.cancel = nil
+	}
+	w.ctx = nil
+	w.active = false
+	w.stage = ""
+}
+
+// watchdogEnabled 是否启用单轮对话看门狗
+func (h *ConnectionHandler) watchdogEnabled() bool {
+	return h.config != nil && h.config.TurnWatchdog.Enabled
+}
+
+// stageTimeout 获取阶段的超时时间
+func (h *ConnectionHandler) stageTimeout(stage string) time.Duration {
+	cfg := h.config.TurnWatchdog
+	switch stage {
+	case turnStageASR:
+		return secondsOrDefault(cfg.ASRTimeout, defaultASRTimeout)
+	case turnStageLLM:
+		return secondsOrDefault(cfg.LLMTimeout, defaultLLMTimeout)
+	case turnStageTTS:
+		return secondsOrDefault(cfg.TTSTimeout, defaultTTSTimeout)
+	}
+	return secondsOrDefault(cfg.MaxTurnSeconds, defaultMaxTurnDuration)
+}
+
+func secondsOrDefault(seconds int, def time.Duration) time.Duration {
+	if seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// beginTurn 开始一轮对话，返回的上下文在本轮结束、被打断或超时时取消
+// 未启用看门狗时不计时，但仍可通过 endTurn 取消
+func (h *ConnectionHandler) beginTurn(ctx context.Context, round int) context.Context {
+	w := &h.watchdog
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stop()
+	turnCtx, cancel := context.WithCancel(ctx)
+	w.round = round
+	w.ctx = turnCtx
+	w.cancel = cancel
+	if !h.watchdogEnabled() {
+		return turnCtx
+	}
+	w.active = true
+	w.turnTimer = time.AfterFunc(h.stageTimeout(""), func() {
+		h.onTurnTimeout(round, "", 0)
+	})
+	h.armStageLocked(turnStageLLM)
+	return turnCtx
+}
+
+// turnStage 标记当前轮次进入某个阶段或在该阶段有了进展，重新开始阶段计时
+// 停止拾音时尚未开始新的轮次，此时只启动识别阶段的计时
+func (h *ConnectionHandler) turnStage(stage string) {
+	if !h.watchdogEnabled() {
+		return
+	}
+	w := &h.watchdog
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.active {
+		if stage != turnStageASR {
+			return
+		}
+		w.active = true
+		w.round = h.currentRound()
+	}
+	h.armStageLocked(stage)
+}
+
+// armStageLocked 重新启动阶段计时器，调用方需持有锁
+func (h *ConnectionHandler) armStageLocked(stage string) {
+	w := &h.watchdog
+	if w.stageTimer != nil {
+		w.stageTimer.Stop()
+	}
+	w.stageGen++
+	round, gen := w.round, w.stageGen
+	w.stage = stage
+	w.stageTimer = time.AfterFunc(h.stageTimeout(stage), func() {
+		h.onTurnTimeout(round, stage, gen)
+	})
+}
+
+// endTurn 本轮对话正常结束或被打断，停止计时
+func (h *ConnectionHandler) endTurn() {
+	w := &h.watchdog
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stop()
+}
+
+// turnContext 当前轮次的上下文，没有进行中的轮次时返回 context.Background()
+func (h *ConnectionHandler) turnContext() context.Context {
+	w := &h.watchdog
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ctx == nil {
+		return context.Background()
+	}
+	return w.ctx
+}
+
+// onTurnTimeout 本轮对话超时：取消LLM请求，清空合成与播放队列，重置识别状态后向用户致歉
+// stage 为空表示整轮计时器触发
+func (h *ConnectionHandler) onTurnTimeout(round int, stage string, gen int) {
+	w := &h.watchdog
+	w.mu.Lock()
+	if !w.active || w.round != round || (stage != "" && w.stageGen != gen) {
+		// 计时器触发时本轮已结束或已进入其他阶段
+		w.mu.Unlock()
+		return
+	}
+	if stage == "" {
+		stage = w.stage
+		h.LogError(fmt.Sprintf("对话轮次 %d 超过最长时长 %s，当前阶段: %s", round, h.stageTimeout(""), stage))
+	} else {
+		h.LogError(fmt.Sprintf("对话轮次 %d 在%s阶段超过 %s 没有进展", round, stage, h.stageTimeout(stage)))
+	}
+	w.stop()
+	w.mu.Unlock()
+
+	select {
+	case <-h.stopChan:
+		return
+	default:
+	}
+
+	h.stopServerSpeak()
+	if asr := h.asrProvider(); asr != nil {
+		if err := asr.Reset(); err != nil {
+			h.LogError(fmt.Sprintf("重置ASR状态失败: %v", err))
+		}
+	}
+	h.resetClientASRText()
+
+	message := h.config.TurnWatchdog.Message
+	if message == "" {
+		message = defaultWatchdogMessage
+	}
+	atomic.StoreInt32(&h.serverVoiceStop, 0)
+	if stage == turnStageASR {
+		// 识别阶段尚未发送tts start
+		h.sendTTSMessage("start", "", 0)
+	}
+	h.SystemSpeak(message)
+}
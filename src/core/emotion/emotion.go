@@ -0,0 +1,183 @@
+// Package emotion 根据文本关键词和说话音量识别情绪，情绪名称与 utils.EmotionEmoji 一致，
+// 设备据此显示对应的表情
+package emotion
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	Neutral = "neutral"
+
+	// 音量相对平时的变化
+	Loud  = "loud"
+	Quiet = "quiet"
+
+	silenceDB = -50.0 // 低于该音量的帧视为静音，不参与计算
+)
+
+// rule 一种情绪的关键词，靠前的规则优先
+type rule struct {
+	emotion string
+	words   []string
+}
+
+var rules = []rule{
+	{"angry", []string{"生气", "气死", "烦死", "讨厌", "闭嘴", "烦人", "受不了", "恼火", "混蛋", "滚开"}},
+	{"crying", []string{"想哭", "哭了", "大哭", "呜呜"}},
+	{"sad", []string{"难过", "伤心", "不开心", "不高兴", "郁闷", "失落", "心情不好", "难受", "委屈", "孤独", "抱歉", "遗憾", "可惜"}},
+	{"laughing", []string{"哈哈", "笑死", "好笑", "太逗了"}},
+	{"loving", []string{"喜欢你", "爱你", "想你", "么么哒", "抱抱"}},
+	{"surprised", []string{"真的吗", "不会吧", "天哪", "天啊", "居然", "竟然", "没想到", "哇"}},
+	{"happy", []string{"开心", "高兴", "太好了", "好棒", "真棒", "快乐", "兴奋", "恭喜", "太棒了"}},
+	{"embarrassed", []string{"尴尬", "不好意思", "害羞"}},
+	{"sleepy", []string{"好困", "困了", "好累", "累死", "想睡", "晚安"}},
+	{"confused", []string{"不明白", "不懂", "什么意思", "搞不懂", "听不懂"}},
+}
+
+// names 情绪的中文名称，放入提示词
+var names = map[string]string{
+	"neutral":     "平静",
+	"happy":       "开心",
+	"laughing":    "开怀大笑",
+	"sad":         "难过",
+	"angry":       "生气",
+	"crying":      "想哭",
+	"loving":      "亲昵",
+	"embarrassed": "尴尬",
+	"surprised":   "惊讶",
+	"sleepy":      "疲惫",
+	"confused":    "困惑",
+}
+
+// negations 关键词前出现这些字时视为否定，如“不难过”
+var negations = []string{"不", "没", "别"}
+
+// Detect 识别文本的情绪，文本中有表情符号时以表情为准，没有识别到时返回 neutral
+func Detect(text string) string {
+	for _, r := range text {
+		if emotion, ok := emojiEmotions[r]; ok {
+			return emotion
+		}
+	}
+	for _, rule := range rules {
+		for _, word := range rule.words {
+			if containsAffirmed(text, word) {
+				return rule.emotion
+			}
+		}
+	}
+	return Neutral
+}
+
+// emojiEmotions 表情符号对应的情绪
+var emojiEmotions = func() map[rune]string {
+	m := make(map[rune]string, len(utils.EmotionEmoji))
+	for emotion, emoji := range utils.EmotionEmoji {
+		for _, r := range emoji {
+			m[r] = emotion
+		}
+	}
+	return m
+}()
+
+// containsAffirmed 文本中出现关键词且没有被否定
+func containsAffirmed(text, word string) bool {
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], word)
+		if i < 0 {
+			return false
+		}
+		i += offset
+		negated := false
+		for _, n := range negations {
+			if strings.HasSuffix(text[:i], n) {
+				negated = true
+				break
+			}
+		}
+		if !negated {
+			return true
+		}
+		offset = i + len(word)
+	}
+}
+
+// Name 情绪的中文名称
+func Name(emotion string) string {
+	if name, ok := names[emotion]; ok {
+		return name
+	}
+	return names[Neutral]
+}
+
+// Energy 统计一句话的音量，输入为16位小端PCM
+type Energy struct {
+	sum    float64
+	frames int
+}
+
+// Add 累加一帧音频的音量，静音帧被忽略
+func (e *Energy) Add(pcm []byte) {
+	n := len(pcm) / 2
+	if n == 0 {
+		return
+	}
+	var square float64
+	for i := 0; i < n; i++ {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm[i*2:]))) / 32768
+		square += sample * sample
+	}
+	db := 10 * math.Log10(square/float64(n)+1e-12)
+	if db < silenceDB {
+		return
+	}
+	e.sum += db
+	e.frames++
+}
+
+// DB 有声帧的平均音量（dBFS），没有有声帧时返回false
+func (e *Energy) DB() (float64, bool) {
+	if e.frames == 0 {
+		return 0, false
+	}
+	return e.sum / float64(e.frames), true
+}
+
+// Reset 开始统计新的一句话
+func (e *Energy) Reset() {
+	*e = Energy{}
+}
+
+// Baseline 用户平时说话的音量，按之前每句话的音量滑动平均
+type Baseline struct {
+	db      float64
+	samples int
+}
+
+// minBaselineSamples 至少统计了这么多句话后才判断音量变化
+const minBaselineSamples = 2
+
+// Compare 比较本句音量与平时音量，差值超过 delta 分贝时返回 loud 或 quiet，并把本句计入平均
+func (b *Baseline) Compare(db, delta float64) string {
+	level := ""
+	if b.samples >= minBaselineSamples {
+		switch {
+		case db >= b.db+delta:
+			level = Loud
+		case db <= b.db-delta:
+			level = Quiet
+		}
+	}
+	if b.samples == 0 {
+		b.db = db
+	} else {
+		b.db = b.db*0.8 + db*0.2
+	}
+	b.samples++
+	return level
+}
@@ -1,18 +1,27 @@
 package function
 
 import (
+	"context"
 	"fmt"
+	"xiaozhi-server-go/src/core/types"
 
 	"github.com/sashabaranov/go-openai"
 )
 
+// Handler 本地函数的执行逻辑，返回的动作决定后续处理（直接回复、将结果交给LLM继续生成等）
+type Handler func(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error)
+
 type FunctionRegistry struct {
 	functions map[string]openai.Tool
+	handlers  map[string]Handler
+	slots     map[string]SlotSpec
 }
 
 func NewFunctionRegistry() *FunctionRegistry {
 	return &FunctionRegistry{
 		functions: make(map[string]openai.Tool),
+		handlers:  make(map[string]Handler),
+		slots:     make(map[string]SlotSpec),
 	}
 }
 
@@ -24,6 +33,30 @@ func (fr *FunctionRegistry) RegisterFunction(name string, function openai.Tool)
 	return nil
 }
 
+// RegisterFunctionWithHandler 注册函数定义及其本地执行逻辑
+func (fr *FunctionRegistry) RegisterFunctionWithHandler(name string, function openai.Tool, handler Handler) error {
+	if err := fr.RegisterFunction(name, function); err != nil {
+		return err
+	}
+	fr.handlers[name] = handler
+	return nil
+}
+
+// HasHandler 函数是否注册了本地执行逻辑
+func (fr *FunctionRegistry) HasHandler(name string) bool {
+	_, exists := fr.handlers[name]
+	return exists
+}
+
+// CallFunction 执行已注册的本地函数
+func (fr *FunctionRegistry) CallFunction(ctx context.Context, name string, arguments map[string]interface{}) (types.ActionResponse, error) {
+	handler, exists := fr.handlers[name]
+	if !exists {
+		return types.ActionResponse{Action: types.ActionTypeNotFound, Result: name}, fmt.Errorf("function not found: %s", name)
+	}
+	return handler(ctx, arguments)
+}
+
 func (fr *FunctionRegistry) GetFunction(name string) (openai.Tool, error) {
 	if function, exists := fr.functions[name]; exists {
 		return function, nil
@@ -44,6 +77,12 @@ func (fr *FunctionRegistry) UnregisterAllFunctions() error {
 	for name := range fr.functions {
 		delete(fr.functions, name)
 	}
+	for name := range fr.handlers {
+		delete(fr.handlers, name)
+	}
+	for name := range fr.slots {
+		delete(fr.slots, name)
+	}
 	return nil
 }
 
@@ -51,6 +90,8 @@ func (fr *FunctionRegistry) UnregisterFunction(name string) error {
 	// Unregister a specific function
 	if _, exists := fr.functions[name]; exists {
 		delete(fr.functions, name)
+		delete(fr.handlers, name)
+		delete(fr.slots, name)
 	} else {
 		return fmt.Errorf("function not found: %s", name)
 	}
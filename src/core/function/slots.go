@@ -0,0 +1,157 @@
+package function
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/utils"
+)
+
+// Slot 工具执行前必须收集齐的参数
+type Slot struct {
+	Name   string
+	Prompt string // 缺少该参数时向用户提问的话术
+	// Parse 校验并转换LLM给出的参数或用户的回答，返回的错误信息会播报给用户后重新提问
+	// 为空时直接使用去除首尾空白的文本
+	Parse func(value interface{}) (interface{}, error)
+}
+
+// SlotSpec 工具的参数收集规则
+type SlotSpec struct {
+	Slots []Slot
+	// Confirm 根据收集到的参数生成确认话术，为空表示参数齐全后直接执行
+	Confirm func(arguments map[string]interface{}) string
+}
+
+// RegisterSlots 为已注册的函数声明必填参数，参数不全时由对话处理器多轮向用户收集
+func (fr *FunctionRegistry) RegisterSlots(name string, spec SlotSpec) error {
+	if _, exists := fr.functions[name]; !exists {
+		return fmt.Errorf("function not found: %s", name)
+	}
+	fr.slots[name] = spec
+	return nil
+}
+
+// GetSlots 获取函数的参数收集规则
+func (fr *FunctionRegistry) GetSlots(name string) (SlotSpec, bool) {
+	spec, exists := fr.slots[name]
+	return spec, exists
+}
+
+// FormResult 用户回答后的表单状态
+type FormResult int
+
+const (
+	FormContinue  FormResult = iota // 继续收集，播报返回的话术后等待回答
+	FormReady                       // 参数齐全且已确认，可以执行
+	FormCancelled                   // 用户取消
+)
+
+var cancelWords = []string{"取消", "算了", "不用了", "不要了", "不设了"}
+
+// Form 一次多轮参数收集的状态
+type Form struct {
+	Tool      string
+	Arguments map[string]interface{}
+	Updated   time.Time // 最近一次向用户提问的时间
+
+	spec       SlotSpec
+	current    *Slot // 正在收集的参数
+	confirming bool  // 参数已齐全，等待用户确认
+}
+
+// NewForm 以LLM给出的参数创建表单，校验不通过的参数视为缺失
+func NewForm(tool string, spec SlotSpec, arguments map[string]interface{}) *Form {
+	f := &Form{
+		Tool:      tool,
+		Arguments: make(map[string]interface{}),
+		Updated:   time.Now(),
+		spec:      spec,
+	}
+	for name, value := range arguments {
+		f.Arguments[name] = value
+	}
+	for i := range spec.Slots {
+		slot := &spec.Slots[i]
+		value, ok := f.Arguments[slot.Name]
+		if !ok {
+			continue
+		}
+		if parsed, err := slot.parse(value); err != nil {
+			delete(f.Arguments, slot.Name)
+		} else {
+			f.Arguments[slot.Name] = parsed
+		}
+	}
+	return f
+}
+
+// Prompt 返回下一步向用户说的话：缺少的参数或确认话术，为空表示可以直接执行
+func (f *Form) Prompt() string {
+	f.Updated = time.Now()
+	for i := range f.spec.Slots {
+		slot := &f.spec.Slots[i]
+		if _, ok := f.Arguments[slot.Name]; !ok {
+			f.current = slot
+			return slot.Prompt
+		}
+	}
+	f.current = nil
+	if f.spec.Confirm != nil {
+		f.confirming = true
+		return f.spec.Confirm(f.Arguments)
+	}
+	return ""
+}
+
+// Answer 处理用户的回答
+func (f *Form) Answer(text string) (FormResult, string) {
+	text = strings.TrimSpace(text)
+	if containsAny(text, cancelWords) {
+		return FormCancelled, ""
+	}
+
+	if f.confirming {
+		switch {
+		case utils.IsNegativeReply(text):
+			return FormCancelled, ""
+		case utils.IsAffirmativeReply(text):
+			return FormReady, ""
+		}
+		return FormContinue, f.Prompt()
+	}
+
+	if f.current != nil {
+		value, err := f.current.parse(text)
+		if err != nil {
+			f.Updated = time.Now()
+			return FormContinue, fmt.Sprintf("%v，%s", err, f.current.Prompt)
+		}
+		f.Arguments[f.current.Name] = value
+	}
+	if prompt := f.Prompt(); prompt != "" {
+		return FormContinue, prompt
+	}
+	return FormReady, ""
+}
+
+func (s *Slot) parse(value interface{}) (interface{}, error) {
+	if s.Parse != nil {
+		return s.Parse(value)
+	}
+	text := strings.TrimSpace(fmt.Sprint(value))
+	if text == "" {
+		return nil, fmt.Errorf("没有听清")
+	}
+	return text, nil
+}
+
+func containsAny(text string, words []string) bool {
+	for _, word := range words {
+		if strings.Contains(text, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package hedge
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 对冲请求的统计目标
+const (
+	TargetTTS       = "tts"
+	TargetEmbedding = "embedding"
+)
+
+// Policy 对冲策略
+type Policy struct {
+	Delay    time.Duration // 主请求超过该时间未返回时发送对冲请求
+	MaxChars int           // 文本不超过该长度才对冲，0表示不限制
+}
+
+// Stats 对冲请求统计
+type Stats struct {
+	Target     string  `json:"target"`
+	Requests   int64   `json:"requests"`    // 请求总数
+	Hedged     int64   `json:"hedged"`      // 发送了对冲请求的次数
+	HedgeWins  int64   `json:"hedge_wins"`  // 对冲请求先返回的次数
+	ExtraChars int64   `json:"extra_chars"` // 对冲请求额外提交的字符数，用于估算增加的成本
+	WinRate    float64 `json:"win_rate"`    // 对冲请求的胜出比例
+}
+
+var (
+	statsMu sync.Mutex
+	stats   = make(map[string]*Stats)
+)
+
+// record 在锁内更新目标的统计
+func record(target string, update func(s *Stats)) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	s, ok := stats[target]
+	if !ok {
+		s = &Stats{Target: target}
+		stats[target] = s
+	}
+	update(s)
+}
+
+// Snapshot 获取各目标的对冲统计
+func Snapshot() []Stats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	result := make([]Stats, 0, len(stats))
+	for _, s := range stats {
+		snapshot := *s
+		if snapshot.Hedged > 0 {
+			snapshot.WinRate = float64(snapshot.HedgeWins) / float64(snapshot.Hedged)
+		}
+		result = append(result, snapshot)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Target < result[j].Target })
+	return result
+}
+
+// Do 调用 call，超过 policy.Delay 未返回时再发送一次相同的请求，使用先成功的结果
+// 只用于幂等请求；主请求在对冲前失败时直接返回错误，不当作重试。
+// 落后的请求会被取消，若仍然成功则把结果交给 discard 清理（如删除生成的音频文件），discard 可为nil
+func Do[T any](ctx context.Context, target string, policy Policy, chars int, call func(ctx context.Context) (T, error), discard func(T)) (T, error) {
+	type result struct {
+		value  T
+		err    error
+		hedged bool
+	}
+	record(target, func(s *Stats) { s.Requests++ })
+
+	results := make(chan result, 2)
+	var cancels []context.CancelFunc
+	launch := func(hedged bool) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func() {
+			value, err := call(attemptCtx)
+			results <- result{value: value, err: err, hedged: hedged}
+		}()
+	}
+	// finish 取消所有请求，并在后台清理仍未返回的请求结果
+	finish := func(pending int) {
+		for _, cancel := range cancels {
+			cancel()
+		}
+		if pending == 0 || discard == nil {
+			return
+		}
+		go func() {
+			for ; pending > 0; pending-- {
+				if r := <-results; r.err == nil {
+					discard(r.value)
+				}
+			}
+		}()
+	}
+
+	launch(false)
+	pending := 1
+	timer := time.NewTimer(policy.Delay)
+	defer timer.Stop()
+
+	var zero T
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			launch(true)
+			pending++
+			record(target, func(s *Stats) {
+				s.Hedged++
+				s.ExtraChars += int64(chars)
+			})
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if r.hedged {
+					record(target, func(s *Stats) { s.HedgeWins++ })
+				}
+				finish(pending)
+				return r.value, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if pending == 0 {
+				finish(0)
+				return zero, firstErr
+			}
+		case <-ctx.Done():
+			finish(pending)
+			return zero, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// 短视频和动图格式
+const (
+	ClipFormatGIF   = "gif"
+	ClipFormatMJPEG = "mjpeg"
+	ClipFormatMP4   = "mp4"
+)
+
+// 抽帧默认值
+const (
+	defaultClipFrameRate   = 1.0
+	defaultClipMaxFrames   = 8
+	defaultClipMaxDuration = 10 * time.Second
+	defaultMJPEGFPS        = 10.0
+	defaultGIFFrameDelay   = 100 * time.Millisecond // 多数浏览器把0延迟的帧按100ms播放
+	clipJPEGQuality        = 85
+)
+
+// Frame 处理后的单张图片，或从短视频、动图中抽取的一帧
+type Frame struct {
+	Data   string        // base64编码的图片数据
+	Format string        // 图片格式，抽取的帧统一为jpeg
+	Offset time.Duration // 该帧在片段中的时间，单张图片为0
+}
+
+// ProcessFrames 处理图片或短视频，返回按时间顺序排列的帧
+// 未启用抽帧或数据不是短视频/动图时只返回一帧；启用预处理时各帧会被缩放或转换格式，以帧的 Format 为准
+func (p *ImageProcessor) ProcessFrames(ctx context.Context, imageData ImageData) ([]Frame, error) {
+	atomic.AddInt64(&p.metrics.TotalProcessed, 1)
+
+	loaded, err := p.loadImage(ctx, imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.config.Clip.Enabled {
+		raw, err := base64.StdEncoding.DecodeString(loaded.Data)
+		if err != nil {
+			atomic.AddInt64(&p.metrics.FailedValidations, 1)
+			return nil, fmt.Errorf("base64解码失败: %v", err)
+		}
+		if format := DetectClipFormat(raw, loaded.Format); format != "" {
+			frames, err := p.extractFrames(ctx, raw, format)
+			if err != nil {
+				atomic.AddInt64(&p.metrics.FailedValidations, 1)
+				return nil, fmt.Errorf("视频抽帧失败: %v", err)
+			}
+			// 只有一帧的GIF按普通图片处理
+			if len(frames) > 0 {
+				for i := range frames {
+					frames[i] = p.preprocess(frames[i])
+				}
+				atomic.AddInt64(&p.metrics.ClipsProcessed, 1)
+				atomic.AddInt64(&p.metrics.FramesExtracted, int64(len(frames)))
+				logrus.WithFields(logrus.Fields{
+					"format": format,
+					"frames": len(frames),
+					"size":   len(raw),
+				}).Info("视频抽帧完成")
+				return frames, nil
+			}
+		}
+	}
+
+	data, err := p.validateImage(loaded)
+	if err != nil {
+		return nil, err
+	}
+	return []Frame{p.preprocess(Frame{Data: data, Format: loaded.Format})}, nil
+}
+
+// DetectClipFormat 根据文件内容识别短视频或动图格式，不是时返回空字符串
+// GIF 是否为动图需要解码后才能确定；MJPEG 为多张JPEG首尾相接，也可由 declaredFormat 指定
+func DetectClipFormat(data []byte, declaredFormat string) string {
+	switch {
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		return ClipFormatMP4
+	case bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a")):
+		return ClipFormatGIF
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		declared := strings.ToLower(declaredFormat)
+		if declared == "mjpeg" || declared == "mjpg" || len(splitJPEGFrames(data, 2)) > 1 {
+			return ClipFormatMJPEG
+		}
+	}
+	return ""
+}
+
+// extractFrames 按格式抽取关键帧
+func (p *ImageProcessor) extractFrames(ctx context.Context, data []byte, format string) ([]Frame, error) {
+	if int64(len(data)) > p.config.Security.MaxFileSize {
+		return nil, fmt.Errorf("文件过大: %d bytes，最大允许: %d bytes", len(data), p.config.Security.MaxFileSize)
+	}
+
+	var frames []Frame
+	var err error
+	switch format {
+	case ClipFormatGIF:
+		frames, err = p.extractGIFFrames(data)
+	case ClipFormatMJPEG:
+		frames, err = p.extractMJPEGFrames(data)
+	case ClipFormatMP4:
+		frames, err = p.extractMP4Frames(ctx, data)
+	default:
+		return nil, fmt.Errorf("不支持的视频格式: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 抽出的帧同样要经过尺寸等安全检查
+	for _, frame := range frames {
+		raw, _ := base64.StdEncoding.DecodeString(frame.Data)
+		if result := p.validator.deepValidateImage(raw, frame.Format); !result.IsValid {
+			return nil, fmt.Errorf("%s处的帧验证失败: %v", frame.Offset, result.Error)
+		}
+	}
+	return frames, nil
+}
+
+// extractGIFFrames 合成GIF各帧画面并按时间抽取，单帧GIF返回nil
+func (p *ImageProcessor) extractGIFFrames(data []byte) ([]Frame, error) {
+	config, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("GIF解码失败: %v", err)
+	}
+	if int64(config.Width)*int64(config.Height) > p.config.Security.MaxPixels {
+		return nil, fmt.Errorf("GIF画布过大: %dx%d", config.Width, config.Height)
+	}
+
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("GIF解码失败: %v", err)
+	}
+	if len(anim.Image) < 2 {
+		return nil, nil
+	}
+
+	starts := make([]time.Duration, len(anim.Image))
+	var total time.Duration
+	for i := range anim.Image {
+		starts[i] = total
+		delay := defaultGIFFrameDelay
+		if i < len(anim.Delay) && anim.Delay[i] > 0 {
+			delay = time.Duration(anim.Delay[i]) * 10 * time.Millisecond
+		}
+		total += delay
+	}
+	selected := p.selectFrames(starts, total)
+
+	// 按处置方式逐帧叠加到画布上，才能得到每一时刻完整的画面
+	canvas := image.NewRGBA(image.Rect(0, 0, config.Width, config.Height))
+	frames := make([]Frame, 0, len(selected))
+	next := 0
+	for i, img := range anim.Image {
+		if next >= len(selected) {
+			break
+		}
+		disposal := byte(0)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvas.Bounds())
+			draw.Draw(previous, previous.Bounds(), canvas, image.Point{}, draw.Src)
+		}
+		draw.Draw(canvas, img.Bounds(), img, img.Bounds().Min, draw.Over)
+
+		if i == selected[next] {
+			encoded, err := encodeJPEGFrame(canvas)
+			if err != nil {
+				return nil, err
+			}
+			frames = append(frames, Frame{Data: encoded, Format: "jpeg", Offset: starts[i]})
+			next++
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, img.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, nil
+}
+
+// extractMJPEGFrames 拆分MJPEG并按 mjpeg_fps 推算的时间抽取
+func (p *ImageProcessor) extractMJPEGFrames(data []byte) ([]Frame, error) {
+	segments := splitJPEGFrames(data, 0)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("MJPEG中没有有效的JPEG帧")
+	}
+
+	fps := p.config.Clip.MJPEGFPS
+	if fps <= 0 {
+		fps = defaultMJPEGFPS
+	}
+	starts := make([]time.Duration, len(segments))
+	for i := range segments {
+		starts[i] = time.Duration(float64(i) / fps * float64(time.Second))
+	}
+	total := time.Duration(float64(len(segments)) / fps * float64(time.Second))
+
+	selected := p.selectFrames(starts, total)
+	frames := make([]Frame, 0, len(selected))
+	for _, i := range selected {
+		frames = append(frames, Frame{
+			Data:   base64.StdEncoding.EncodeToString(segments[i]),
+			Format: "jpeg",
+			Offset: starts[i],
+		})
+	}
+	return frames, nil
+}
+
+// extractMP4Frames 调用 ffmpeg 解码MP4，按抽帧频率输出JPEG
+// 不预先探测时长，抽帧频率按 max_duration 内不超过 max_frames 计算
+func (p *ImageProcessor) extractMP4Frames(ctx context.Context, data []byte) ([]Frame, error) {
+	ffmpeg := p.config.Clip.FFmpegPath
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	if _, err := exec.LookPath(ffmpeg); err != nil {
+		return nil, fmt.Errorf("解码MP4需要ffmpeg: %v", err)
+	}
+
+	// MP4的索引可能在文件末尾，不能从管道读取，先写入临时文件
+	tempPath := filepath.Join(p.tempDir, fmt.Sprintf("clip_%d_%s.mp4", time.Now().UnixNano(), uuid.New().String()))
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("写入临时文件失败: %v", err)
+	}
+	defer os.Remove(tempPath)
+
+	maxDuration := p.clipMaxDuration()
+	interval := p.frameInterval(maxDuration)
+	rate := float64(time.Second) / float64(interval)
+
+	cmd := exec.CommandContext(ctx, ffmpeg,
+		"-v", "error",
+		"-t", strconv.FormatFloat(maxDuration.Seconds(), 'f', 3, 64),
+		"-i", tempPath,
+		"-vf", "fps="+strconv.FormatFloat(rate, 'f', 4, 64),
+		"-frames:v", strconv.Itoa(p.clipMaxFrames()),
+		"-f", "image2pipe",
+		"-c:v", "mjpeg",
+		"-q:v", "3",
+		"pipe:1",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg解码失败: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	segments := splitJPEGFrames(stdout.Bytes(), 0)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("MP4中没有解码出视频帧")
+	}
+	frames := make([]Frame, 0, len(segments))
+	for i, segment := range segments {
+		frames = append(frames, Frame{
+			Data:   base64.StdEncoding.EncodeToString(segment),
+			Format: "jpeg",
+			Offset: time.Duration(i) * interval,
+		})
+	}
+	return frames, nil
+}
+
+// selectFrames 按抽帧间隔选出各时间点正在显示的帧，starts 为各帧开始时间
+func (p *ImageProcessor) selectFrames(starts []time.Duration, total time.Duration) []int {
+	if maxDuration := p.clipMaxDuration(); total > maxDuration {
+		total = maxDuration
+	}
+	interval := p.frameInterval(total)
+	maxFrames := p.clipMaxFrames()
+
+	var selected []int
+	current := 0
+	for t := time.Duration(0); t < total && len(selected) < maxFrames; t += interval {
+		for current+1 < len(starts) && starts[current+1] <= t {
+			current++
+		}
+		if len(selected) == 0 || selected[len(selected)-1] != current {
+			selected = append(selected, current)
+		}
+	}
+	return selected
+}
+
+// frameInterval 抽帧间隔，片段较长时拉大间隔，让 max_frames 帧均匀覆盖整个片段
+func (p *ImageProcessor) frameInterval(total time.Duration) time.Duration {
+	rate := p.config.Clip.FrameRate
+	if rate <= 0 {
+		rate = defaultClipFrameRate
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+	if spread := total / time.Duration(p.clipMaxFrames()); spread > interval {
+		interval = spread
+	}
+	return interval
+}
+
+// clipMaxFrames 单次最多发送的帧数
+func (p *ImageProcessor) clipMaxFrames() int {
+	if p.config.Clip.MaxFrames > 0 {
+		return p.config.Clip.MaxFrames
+	}
+	return defaultClipMaxFrames
+}
+
+// clipMaxDuration 只分析片段开头的这段时间
+func (p *ImageProcessor) clipMaxDuration() time.Duration {
+	if duration, err := time.ParseDuration(p.config.Clip.MaxDuration); err == nil && duration > 0 {
+		return duration
+	}
+	return defaultClipMaxDuration
+}
+
+// encodeJPEGFrame 把合成的画面编码为base64的JPEG
+func encodeJPEGFrame(img image.Image) (string, error) {
+	encoded, err := encodeJPEG(img, clipJPEGQuality)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+// splitJPEGFrames 按JPEG标记结构拆分首尾相接的JPEG，limit 大于0时最多拆出 limit 帧
+// 按段长度跳过APP段，EXIF缩略图中的SOI/EOI不会被误认为帧边界；帧之间的multipart头等数据被忽略
+func splitJPEGFrames(data []byte, limit int) [][]byte {
+	var frames [][]byte
+	pos := 0
+	for limit <= 0 || len(frames) < limit {
+		start := bytes.Index(data[pos:], []byte{0xFF, 0xD8, 0xFF})
+		if start < 0 {
+			break
+		}
+		start += pos
+		end := jpegEnd(data, start)
+		if end < 0 {
+			break
+		}
+		frames = append(frames, data[start:end])
+		pos = end
+	}
+	return frames
+}
+
+// jpegEnd 从SOI开始解析JPEG，返回EOI之后的位置，数据不完整时返回-1
+func jpegEnd(data []byte, start int) int {
+	i := start + 2
+	for i+1 < len(data) {
+		if data[i] != 0xFF {
+			return -1
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// 填充字节
+			i++
+			continue
+		case marker == 0xD9:
+			return i + 2
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			i += 2
+			continue
+		}
+		if i+3 >= len(data) {
+			return -1
+		}
+		length := int(data[i+2])<<8 | int(data[i+3])
+		i += 2 + length
+		if marker != 0xDA {
+			continue
+		}
+		// SOS之后是熵编码数据，其中的0xFF后跟0x00或RST标记，遇到其他标记时结束
+		for i+1 < len(data) {
+			if data[i] == 0xFF && data[i+1] != 0x00 && (data[i+1] < 0xD0 || data[i+1] > 0xD7) {
+				break
+			}
+			i++
+		}
+	}
+	return -1
+}
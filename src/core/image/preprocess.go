@@ -0,0 +1,126 @@
+package image
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+	_ "golang.org/x/image/bmp" // 注册BMP解码器，BMP转为JPEG后再发送
+	xdraw "golang.org/x/image/draw"
+)
+
+// defaultPreprocessQuality 未配置时输出JPEG的质量
+const defaultPreprocessQuality = 85
+
+// preprocess 按配置缩放图片或转换格式，不需要处理或无法解码时原样返回
+func (p *ImageProcessor) preprocess(frame Frame) Frame {
+	config := p.config.Preprocess
+	if !config.Enabled {
+		return frame
+	}
+	raw, err := base64.StdEncoding.DecodeString(frame.Data)
+	if err != nil {
+		return frame
+	}
+	img, sourceFormat, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		logrus.WithError(err).Debug("图片无法解码，跳过预处理")
+		return frame
+	}
+
+	bounds := img.Bounds()
+	width, height := scaledSize(bounds.Dx(), bounds.Dy(), config.MaxDimension)
+	resize := width != bounds.Dx() || height != bounds.Dy()
+	target := preprocessFormat(config.Format, sourceFormat)
+	if !resize && (config.Format == "" || target == sourceFormat) {
+		return frame
+	}
+
+	if resize {
+		scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+		xdraw.BiLinear.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+		img = scaled
+	}
+
+	var encoded []byte
+	switch target {
+	case "png":
+		var buf bytes.Buffer
+		err = png.Encode(&buf, img)
+		encoded = buf.Bytes()
+	default:
+		quality := config.Quality
+		if quality <= 0 || quality > 100 {
+			quality = defaultPreprocessQuality
+		}
+		encoded, err = encodeJPEG(img, quality)
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("图片预处理编码失败，使用原图")
+		return frame
+	}
+
+	atomic.AddInt64(&p.metrics.Preprocessed, 1)
+	atomic.AddInt64(&p.metrics.BytesSaved, int64(len(raw)-len(encoded)))
+	logrus.WithFields(logrus.Fields{
+		"from":      fmt.Sprintf("%dx%d %s %d bytes", bounds.Dx(), bounds.Dy(), sourceFormat, len(raw)),
+		"to":        fmt.Sprintf("%dx%d %s %d bytes", width, height, target, len(encoded)),
+		"offset_ms": frame.Offset.Milliseconds(),
+	}).Debug("图片预处理完成")
+
+	return Frame{
+		Data:   base64.StdEncoding.EncodeToString(encoded),
+		Format: target,
+		Offset: frame.Offset,
+	}
+}
+
+// scaledSize 长边超过 maxDimension 时等比缩小后的尺寸
+func scaledSize(width, height, maxDimension int) (int, int) {
+	longest := width
+	if height > longest {
+		longest = height
+	}
+	if maxDimension <= 0 || longest <= maxDimension {
+		return width, height
+	}
+	scale := float64(maxDimension) / float64(longest)
+	width = max(1, int(float64(width)*scale+0.5))
+	height = max(1, int(float64(height)*scale+0.5))
+	return width, height
+}
+
+// preprocessFormat 输出格式，未配置时保持JPEG、PNG，其他格式（webp、gif、bmp）无法编码，转为JPEG
+func preprocessFormat(configured, source string) string {
+	switch strings.ToLower(configured) {
+	case "jpeg", "jpg":
+		return "jpeg"
+	case "png":
+		return "png"
+	}
+	if source == "png" {
+		return "png"
+	}
+	return "jpeg"
+}
+
+// encodeJPEG 编码为JPEG，透明区域填充白色，避免变成黑色
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	opaque := image.NewRGBA(img.Bounds())
+	draw.Draw(opaque, opaque.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(opaque, opaque.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, opaque, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("编码JPEG失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
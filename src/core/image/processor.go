@@ -63,6 +63,15 @@ func NewImageProcessor(config *configs.VLLMConfig) (*ImageProcessor, error) {
 func (p *ImageProcessor) ProcessImage(ctx context.Context, imageData ImageData) (string, error) {
 	atomic.AddInt64(&p.metrics.TotalProcessed, 1)
 
+	finalImageData, err := p.loadImage(ctx, imageData)
+	if err != nil {
+		return "", err
+	}
+	return p.validateImage(finalImageData)
+}
+
+// loadImage 下载URL图片或直接使用base64数据
+func (p *ImageProcessor) loadImage(ctx context.Context, imageData ImageData) (ImageData, error) {
 	var finalImageData ImageData
 
 	// 根据输入类型处理图片
@@ -73,7 +82,7 @@ func (p *ImageProcessor) ProcessImage(ctx context.Context, imageData ImageData)
 		base64Data, err := p.processURLImage(ctx, imageData.URL, imageData.Format)
 		if err != nil {
 			atomic.AddInt64(&p.metrics.FailedValidations, 1)
-			return "", fmt.Errorf("URL图片处理失败: %v", err)
+			return ImageData{}, fmt.Errorf("URL图片处理失败: %v", err)
 		}
 
 		finalImageData = ImageData{
@@ -96,9 +105,13 @@ func (p *ImageProcessor) ProcessImage(ctx context.Context, imageData ImageData)
 			"data_length": len(imageData.Data),
 		}).Debug("Base64图片处理开始")
 	} else {
-		return "", fmt.Errorf("图片数据为空：既没有URL也没有base64数据")
+		return ImageData{}, fmt.Errorf("图片数据为空：既没有URL也没有base64数据")
 	}
+	return finalImageData, nil
+}
 
+// validateImage 安全验证图片，返回base64编码的图片
+func (p *ImageProcessor) validateImage(finalImageData ImageData) (string, error) {
 	// 安全验证
 	validationResult := p.validator.ValidateImageData(finalImageData)
 	if !validationResult.IsValid {
@@ -239,6 +252,16 @@ func (p *ImageProcessor) isValidImageContentType(contentType string) bool {
 		"image/bmp",
 	}
 
+	// 启用抽帧时也接受短视频
+	if p.config.Clip.Enabled {
+		validContentTypes = append(validContentTypes,
+			"video/mp4",
+			"video/x-motion-jpeg",
+			"video/mjpeg",
+			"multipart/x-mixed-replace",
+		)
+	}
+
 	contentTypeLower := strings.ToLower(contentType)
 	for _, validType := range validContentTypes {
 		if strings.Contains(contentTypeLower, validType) {
@@ -257,6 +280,10 @@ func (p *ImageProcessor) GetMetrics() ImageMetrics {
 		Base64Direct:      atomic.LoadInt64(&p.metrics.Base64Direct),
 		FailedValidations: atomic.LoadInt64(&p.metrics.FailedValidations),
 		SecurityIncidents: atomic.LoadInt64(&p.metrics.SecurityIncidents),
+		ClipsProcessed:    atomic.LoadInt64(&p.metrics.ClipsProcessed),
+		FramesExtracted:   atomic.LoadInt64(&p.metrics.FramesExtracted),
+		Preprocessed:      atomic.LoadInt64(&p.metrics.Preprocessed),
+		BytesSaved:        atomic.LoadInt64(&p.metrics.BytesSaved),
 	}
 }
 
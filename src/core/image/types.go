@@ -32,4 +32,8 @@ type ImageMetrics struct {
 	Base64Direct    int64 // Base64直接处理次数
 	FailedValidations int64 // 验证失败次数
 	SecurityIncidents int64 // 安全事件次数
+	ClipsProcessed int64 // 短视频/动图抽帧次数
+	FramesExtracted int64 // 抽取的帧总数
+	Preprocessed int64 // 经过缩放或格式转换的图片数量
+	BytesSaved int64 // 预处理减少的字节数
 } 
\ No newline at end of file
@@ -0,0 +1,231 @@
+// Package iot 设备外设（IoT）协议：设备通过 iot 消息上报外设描述和状态，
+// 服务端把外设的方法注册为LLM函数，调用时向设备下发指令，与小智ESP32固件的IoT协议一致
+package iot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Property 外设属性或方法参数，type 为 number、boolean 或 string
+type Property struct {
+	Description string `json:"description"`
+	Type        string `json:"type"`
+}
+
+// Method 外设方法
+type Method struct {
+	Description string              `json:"description"`
+	Parameters  map[string]Property `json:"parameters"`
+}
+
+// Descriptor 外设描述
+// 示例: {"name":"Speaker","description":"扬声器","properties":{"volume":{"description":"当前音量值","type":"number"}},
+// "methods":{"SetVolume":{"description":"设置音量","parameters":{"volume":{"description":"0到100之间的整数","type":"number"}}}}}
+type Descriptor struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Properties  map[string]Property `json:"properties"`
+	Methods     map[string]Method   `json:"methods"`
+}
+
+// Command 下发给设备的指令
+type Command struct {
+	Name       string                 `json:"name"`
+	Method     string                 `json:"method"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// State 外设状态
+type State struct {
+	Name  string                 `json:"name"`
+	State map[string]interface{} `json:"state"`
+}
+
+// Parse 把消息中的数组解析为指定类型
+func Parse[T any](raw []interface{}) ([]T, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var result []T
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ToolName 外设方法对应的LLM函数名，如 Speaker.SetVolume -> iot_speaker_set_volume
+func ToolName(device, method string) string {
+	return "iot_" + snakeCase(device) + "_" + snakeCase(method)
+}
+
+// StateToolName 查询外设状态的LLM函数名
+func StateToolName(device string) string {
+	return "iot_" + snakeCase(device) + "_get_state"
+}
+
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// Schema 方法参数的JSON Schema，所有参数均为必填
+func (m Method) Schema() map[string]interface{} {
+	properties := make(map[string]interface{}, len(m.Parameters))
+	required := make([]string, 0, len(m.Parameters))
+	for name, param := range m.Parameters {
+		properties[name] = map[string]interface{}{
+			"type":        jsonType(param.Type),
+			"description": param.Description,
+		}
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// Arguments 按参数类型校验并转换LLM传入的参数，模型把数字或布尔值写成字符串时自动转换
+func (m Method) Arguments(arguments map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(m.Parameters))
+	for name, param := range m.Parameters {
+		value, ok := arguments[name]
+		if !ok {
+			return nil, fmt.Errorf("缺少参数 %s", name)
+		}
+		converted, err := convert(value, param.Type)
+		if err != nil {
+			return nil, fmt.Errorf("参数 %s 无效: %v", name, err)
+		}
+		result[name] = converted
+	}
+	return result, nil
+}
+
+func jsonType(t string) string {
+	switch t {
+	case "number", "boolean", "string":
+		return t
+	default:
+		return "string"
+	}
+}
+
+func convert(value interface{}, t string) (interface{}, error) {
+	switch t {
+	case "number":
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case string:
+			return strconv.ParseFloat(strings.TrimSpace(v), 64)
+		}
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(strings.TrimSpace(v))
+		}
+	default:
+		return fmt.Sprint(value), nil
+	}
+	return nil, fmt.Errorf("应为 %s 类型", t)
+}
+
+// Things 一个连接上报的全部外设及其最新状态
+type Things struct {
+	mu          sync.RWMutex
+	descriptors map[string]Descriptor
+	states      map[string]map[string]interface{}
+}
+
+// NewThings 创建外设集合
+func NewThings() *Things {
+	return &Things{
+		descriptors: make(map[string]Descriptor),
+		states:      make(map[string]map[string]interface{}),
+	}
+}
+
+// SetDescriptor 保存外设描述，同名外设重复上报时覆盖
+func (t *Things) SetDescriptor(descriptor Descriptor) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.descriptors[descriptor.Name] = descriptor
+}
+
+// Descriptor 查询外设描述
+func (t *Things) Descriptor(name string) (Descriptor, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	descriptor, ok := t.descriptors[name]
+	return descriptor, ok
+}
+
+// UpdateState 合并外设上报的状态，设备可以只上报变化的属性
+func (t *Things) UpdateState(state State) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	current, ok := t.states[state.Name]
+	if !ok {
+		current = make(map[string]interface{})
+		t.states[state.Name] = current
+	}
+	for key, value := range state.State {
+		current[key] = value
+	}
+}
+
+// Describe 用自然语言描述外设当前状态，交给LLM组织回复
+func (t *Things) Describe(name string) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	descriptor := t.descriptors[name]
+	state := t.states[name]
+	title := descriptor.Description
+	if title == "" {
+		title = name
+	}
+	if len(state) == 0 {
+		return title + "还没有上报状态"
+	}
+
+	keys := make([]string, 0, len(state))
+	for key := range state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		label := key
+		if property, ok := descriptor.Properties[key]; ok && property.Description != "" {
+			label = property.Description
+		}
+		parts = append(parts, fmt.Sprintf("%s：%v", label, state[key]))
+	}
+	return title + "当前状态：" + strings.Join(parts, "，")
+}
@@ -0,0 +1,244 @@
+package llmcache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTTL                 = 5 * time.Minute
+	defaultMaxEntries          = 1000
+	defaultMaxQueryLength      = 20
+	defaultSimilarityThreshold = 0.92
+)
+
+// defaultBypass 答案随时间或设备状态变化的问题，不参与缓存
+var defaultBypass = []string{"几点", "时间", "日期", "几号", "今天", "明天", "昨天", "星期", "周几", "现在", "天气", "温度", "新闻", "最新", "电量", "音量", "提醒", "闹钟"}
+
+// Embedder 计算文本向量，开启语义匹配时使用
+type Embedder func(ctx context.Context, text string) ([]float32, error)
+
+// Cache 短问题的LLM回复缓存，所有连接共享
+// 缓存键由作用域（LLM模块、系统提示词、角色和上一轮对话）和归一化后的问题组成；开启语义匹配时，
+// 同一作用域内向量相似度超过阈值的问题视为相同
+type Cache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	maxQuery   int
+	threshold  float64
+	bypass     []string // 包含这些词的问题不参与缓存，已归一化
+	embed      Embedder
+	entries    map[string]*list.Element
+	lru        *list.List // 前端为最近使用
+}
+
+type entry struct {
+	key      string
+	scope    string
+	vector   []float32
+	response string
+	expires  time.Time
+}
+
+// New 按配置创建缓存，未启用时返回nil
+func New(cfg configs.LLMCacheConfig) *Cache {
+	if !cfg.Enabled {
+		return nil
+	}
+	c := &Cache{
+		ttl:        time.Duration(cfg.TTL) * time.Second,
+		maxEntries: cfg.MaxEntries,
+		maxQuery:   cfg.MaxQueryLength,
+		threshold:  cfg.SimilarityThreshold,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+	if c.ttl <= 0 {
+		c.ttl = defaultTTL
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = defaultMaxEntries
+	}
+	if c.maxQuery <= 0 {
+		c.maxQuery = defaultMaxQueryLength
+	}
+	if c.threshold <= 0 {
+		c.threshold = defaultSimilarityThreshold
+	}
+	bypass := cfg.Bypass
+	if bypass == nil {
+		bypass = defaultBypass
+	}
+	for _, word := range bypass {
+		if word = normalize(word); word != "" {
+			c.bypass = append(c.bypass, word)
+		}
+	}
+	return c
+}
+
+// SetEmbedder 设置向量计算函数，开启语义匹配
+func (c *Cache) SetEmbedder(embed Embedder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.embed = embed
+}
+
+// Scope 由LLM模块、系统提示词、角色和上一轮对话计算缓存作用域，
+// 切换模型或角色后不会命中之前的回复，“为什么”等追问只命中同样上文的回复
+func Scope(llmName, systemPrompt, role string, previous []string) string {
+	parts := append([]string{llmName, systemPrompt, role}, previous...)
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// normalize 移除标点和空白并转为小写，使“几点了？”与“几点了”命中同一条缓存
+func normalize(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(utils.RemoveAllPunctuation(query)), ""))
+}
+
+// Lookup 一次缓存查找，未命中时在回复生成后调用 Store 写入
+type Lookup struct {
+	Hit      bool
+	Response string
+
+	cache  *Cache
+	scope  string
+	key    string
+	vector []float32
+}
+
+// Lookup 查找缓存，问题过长、为空或与时间状态相关时返回nil，表示不参与缓存
+func (c *Cache) Lookup(ctx context.Context, scope, query string) *Lookup {
+	if c == nil {
+		return nil
+	}
+	normalized := normalize(query)
+	if normalized == "" || utf8.RuneCountInString(normalized) > c.maxQuery || c.bypassed(normalized) {
+		return nil
+	}
+	l := &Lookup{cache: c, scope: scope, key: scope + ":" + normalized}
+
+	c.mu.Lock()
+	embed := c.embed
+	if e, ok := c.get(l.key); ok {
+		c.mu.Unlock()
+		l.Hit, l.Response = true, e.response
+		return l
+	}
+	c.mu.Unlock()
+
+	if embed == nil {
+		return l
+	}
+	vector, err := embed(ctx, normalized)
+	if err != nil {
+		logrus.WithError(err).Warn("LLM缓存计算问题向量失败，仅使用精确匹配")
+		return l
+	}
+	l.vector = vector
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var best *entry
+	bestScore := c.threshold
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*entry)
+		if e.scope != scope || e.vector == nil || time.Now().After(e.expires) {
+			continue
+		}
+		if score := cosine(vector, e.vector); score >= bestScore {
+			best, bestScore = e, score
+		}
+	}
+	if best != nil {
+		c.lru.MoveToFront(c.entries[best.key])
+		l.Hit, l.Response = true, best.response
+	}
+	return l
+}
+
+// Store 写入本次问题的回复
+func (l *Lookup) Store(response string) {
+	if l == nil || l.Hit || strings.TrimSpace(response) == "" {
+		return
+	}
+	c := l.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &entry{
+		key:      l.key,
+		scope:    l.scope,
+		vector:   l.vector,
+		response: response,
+		expires:  time.Now().Add(c.ttl),
+	}
+	if el, ok := c.entries[l.key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[l.key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+}
+
+// bypassed 问题是否包含不参与缓存的词
+func (c *Cache) bypassed(normalized string) bool {
+	for _, word := range c.bypass {
+		if strings.Contains(normalized, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// get 精确查找，过期条目顺带删除，调用方需持有锁
+func (c *Cache) get(key string) (*entry, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if time.Now().After(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e, true
+}
+
+// cosine 余弦相似度，维度不同时返回0
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,49 @@
+package llmcache
+
+import (
+	"context"
+	"testing"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+func TestLookupSkipsTimeSensitiveQuestions(t *testing.T) {
+	c := New(configs.LLMCacheConfig{Enabled: true})
+	scope := Scope("llm", "prompt", "", nil)
+	for _, query := range []string{"现在几点了？", "今天天气怎么样", "明天星期几"} {
+		if l := c.Lookup(context.Background(), scope, query); l != nil {
+			t.Errorf("Lookup(%q) took part in caching", query)
+		}
+	}
+	if l := c.Lookup(context.Background(), scope, "你叫什么名字"); l == nil {
+		t.Error("Lookup skipped an ordinary question")
+	}
+
+	// 配置为空列表时不跳过
+	c = New(configs.LLMCacheConfig{Enabled: true, Bypass: []string{}})
+	if l := c.Lookup(context.Background(), scope, "现在几点了"); l == nil {
+		t.Error("empty bypass list still skipped the question")
+	}
+}
+
+func TestScopeSeparatesFollowUps(t *testing.T) {
+	c := New(configs.LLMCacheConfig{Enabled: true})
+	first := Scope("llm", "prompt", "", []string{"user:讲个笑话", "assistant:笑话A"})
+	other := Scope("llm", "prompt", "", []string{"user:介绍一下长城", "assistant:长城介绍"})
+
+	l := c.Lookup(context.Background(), first, "为什么")
+	if l == nil || l.Hit {
+		t.Fatalf("first lookup = %+v, want a miss", l)
+	}
+	l.Store("因为笑话A很好笑")
+
+	if l := c.Lookup(context.Background(), first, "为什么？"); l == nil || !l.Hit {
+		t.Error("same follow-up after the same exchange missed the cache")
+	}
+	if l := c.Lookup(context.Background(), other, "为什么"); l == nil || l.Hit {
+		t.Error("follow-up after a different exchange hit the cache")
+	}
+	if l := c.Lookup(context.Background(), Scope("llm", "prompt", "老师", nil), "为什么"); l == nil || l.Hit {
+		t.Error("follow-up with another role hit the cache")
+	}
+}
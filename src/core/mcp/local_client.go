@@ -63,6 +63,18 @@ func (c *LocalClient) RegisterTools() {
 		} else if funcName == "play_music" {
 			c.AddToolPlayMusic()
 			logrus.Info("RegisterTools: play_music tool registered")
+		} else if funcName == "intercom" {
+			c.AddToolIntercom()
+			logrus.Info("RegisterTools: intercom tool registered")
+		} else if funcName == "weather" {
+			c.AddToolWeather()
+			logrus.Info("RegisterTools: weather tool registered")
+		} else if funcName == "calendar" {
+			c.AddToolCalendar()
+			logrus.Info("RegisterTools: calendar tool registered")
+		} else if funcName == "news" {
+			c.AddToolNews()
+			logrus.Info("RegisterTools: news tool registered")
 		} else {
 			logrus.WithField("funcName", funcName).Warn("RegisterTools: unknown function name")
 		}
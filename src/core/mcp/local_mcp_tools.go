@@ -2,8 +2,10 @@ package mcp
 
 import (
 	"context"
+	"sort"
 	"strings"
 	"time"
+	"xiaozhi-server-go/src/core/skills"
 	"xiaozhi-server-go/src/core/types"
 
 	"github.com/sirupsen/logrus"
@@ -179,3 +181,142 @@ func (c *LocalClient) AddToolPlayMusic() error {
 
 	return nil
 }
+
+func (c *LocalClient) AddToolIntercom() error {
+	names := []string{}
+	for _, group := range c.cfg.Intercom.Groups {
+		for name := range group.Devices {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		logrus.Warn("AddToolIntercom: intercom groups is empty, Skipping tool registration")
+		return nil
+	}
+	sort.Strings(names)
+
+	InputSchema := ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"target": map[string]any{
+				"type":        "string",
+				"description": "目标设备名称",
+			},
+			"message": map[string]any{
+				"type":        "string",
+				"description": "要转告的内容",
+			},
+		},
+		Required: []string{"target", "message"},
+	}
+
+	c.AddTool("intercom",
+		"当用户想让另一台设备传话、通知其他房间的人时调用，可选的设备有：["+strings.Join(names, ", ")+"]",
+		InputSchema,
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			target, _ := args["target"].(string)
+			message, _ := args["message"].(string)
+			res := types.ActionResponse{
+				Action: types.ActionTypeCallHandler, // 动作类型
+				Result: types.ActionResponseCall{
+					FuncName: "mcp_handler_intercom", // 函数名
+					Args: map[string]string{
+						"target":  target,
+						"message": message,
+					},
+				},
+			}
+			return res, nil
+		})
+
+	return nil
+}
+
+// skillResult 内置技能的结果交给LLM组织回复，失败时把原因交给LLM向用户说明
+func skillResult(text string, err error) (interface{}, error) {
+	if err != nil {
+		return types.ActionResponse{
+			Action: types.ActionTypeReqLLM,
+			Result: "查询失败：" + err.Error(),
+		}, err
+	}
+	return types.ActionResponse{
+		Action: types.ActionTypeReqLLM,
+		Result: text,
+	}, nil
+}
+
+func (c *LocalClient) AddToolWeather() error {
+	InputSchema := ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"city": map[string]any{
+				"type":        "string",
+				"description": "城市名称，如 北京；用户没有说城市时为空",
+			},
+		},
+		Required: []string{},
+	}
+
+	c.AddTool("get_weather",
+		"当用户询问天气、气温、是否下雨、穿衣建议时调用",
+		InputSchema,
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			city, _ := args["city"].(string)
+			cfg := skills.Resolve(ctx, &c.cfg.Skills)
+			return skillResult(skills.Weather(ctx, cfg.Weather, city))
+		})
+
+	return nil
+}
+
+func (c *LocalClient) AddToolCalendar() error {
+	InputSchema := ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"range": map[string]any{
+				"type":        "string",
+				"enum":        []string{skills.CalendarToday, skills.CalendarTomorrow, skills.CalendarWeek},
+				"description": "查询范围：today 今天，tomorrow 明天，week 未来七天",
+			},
+		},
+		Required: []string{"range"},
+	}
+
+	c.AddTool("get_calendar",
+		"当用户询问日程、会议、今天或明天有什么安排时调用",
+		InputSchema,
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			span, _ := args["range"].(string)
+			cfg := skills.Resolve(ctx, &c.cfg.Skills)
+			return skillResult(skills.Calendar(ctx, cfg.Calendar, span))
+		})
+
+	return nil
+}
+
+func (c *LocalClient) AddToolNews() error {
+	categories := skills.NewsCategories(c.cfg.Skills.News)
+
+	InputSchema := ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"category": map[string]any{
+				"type":        "string",
+				"description": "新闻分类，可选的分类有：[" + strings.Join(categories, ", ") + "]，用户没有指定时为空",
+			},
+		},
+		Required: []string{},
+	}
+
+	c.AddTool("get_news",
+		"当用户想听新闻、新闻简报、最近发生了什么时调用",
+		InputSchema,
+		func(ctx context.Context, args map[string]any) (interface{}, error) {
+			category, _ := args["category"].(string)
+			cfg := skills.Resolve(ctx, &c.cfg.Skills)
+			return skillResult(skills.News(ctx, cfg.News, category))
+		})
+
+	return nil
+}
@@ -0,0 +1,189 @@
+// Package memory 长期记忆：把对话中值得记住的用户事实向量化后存入向量库，
+// 对话时按相似度检索相关记忆放入系统提示词
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTopK      = 5
+	defaultMinScore  = 0.5
+	defaultMaxFacts  = 10
+	duplicateScore   = 0.9 // 与已有记忆的相似度超过该值时视为重复，不再保存
+	operationTimeout = 10 * time.Second
+)
+
+// Record 一条记忆
+type Record struct {
+	Owner     string // 记忆所属的用户，设备未绑定用户时为设备ID
+	Text      string
+	Vector    []float32
+	CreatedAt time.Time
+}
+
+// Match 检索到的记忆及其相似度（余弦相似度，越大越相似）
+type Match struct {
+	Record
+	Score float64
+}
+
+// Store 向量库接口
+type Store interface {
+	// Add 保存记忆，向量维度在第一次保存时确定
+	Add(ctx context.Context, records []Record) error
+	// Search 在指定用户的记忆中检索与向量最相似的 topK 条，按相似度从高到低排序
+	Search(ctx context.Context, owner string, vector []float32, topK int) ([]Match, error)
+	Close() error
+}
+
+// StoreFactory 向量库工厂函数类型
+type StoreFactory func(config *configs.MemoryStoreConfig) (Store, error)
+
+var (
+	factories = make(map[string]StoreFactory)
+)
+
+// Register 注册向量库工厂
+func Register(name string, factory StoreFactory) {
+	factories[name] = factory
+}
+
+// Create 创建向量库实例
+func Create(name string, config *configs.MemoryStoreConfig) (Store, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的向量库: %s", name)
+	}
+
+	store, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建向量库失败: %v", err)
+	}
+
+	return store, nil
+}
+
+// Embedder 计算文本向量
+type Embedder func(ctx context.Context, text string) ([]float32, error)
+
+// Service 长期记忆服务，所有连接共享
+type Service struct {
+	store    Store
+	embed    Embedder
+	topK     int
+	minScore float64
+	maxFacts int
+}
+
+// New 按配置创建记忆服务，未启用时返回nil
+func New(cfg configs.MemoryConfig, embed Embedder) (*Service, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	store, err := Create(cfg.Store.Type, &cfg.Store)
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		store:    store,
+		embed:    embed,
+		topK:     cfg.TopK,
+		minScore: cfg.MinScore,
+		maxFacts: cfg.MaxFacts,
+	}
+	if s.topK <= 0 {
+		s.topK = defaultTopK
+	}
+	if s.minScore <= 0 {
+		s.minScore = defaultMinScore
+	}
+	if s.maxFacts <= 0 {
+		s.maxFacts = defaultMaxFacts
+	}
+	return s, nil
+}
+
+// MaxFacts 每次会话最多保存的事实条数
+func (s *Service) MaxFacts() int {
+	return s.maxFacts
+}
+
+// Recall 检索与问题相关的记忆，返回记忆文本，相似度不足的记忆被过滤掉
+func (s *Service) Recall(ctx context.Context, owner, query string) ([]string, error) {
+	query = strings.TrimSpace(query)
+	if owner == "" || query == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+
+	vector, err := s.embed(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := s.store.Search(ctx, owner, vector, s.topK)
+	if err != nil {
+		return nil, fmt.Errorf("检索记忆失败: %v", err)
+	}
+	var memories []string
+	for _, match := range matches {
+		if match.Score >= s.minScore {
+			memories = append(memories, match.Text)
+		}
+	}
+	return memories, nil
+}
+
+// Remember 保存新的事实，与已有记忆重复的事实会被跳过，返回实际保存的条数
+func (s *Service) Remember(ctx context.Context, owner string, facts []string) (int, error) {
+	if owner == "" || len(facts) == 0 {
+		return 0, nil
+	}
+	if len(facts) > s.maxFacts {
+		facts = facts[:s.maxFacts]
+	}
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout*time.Duration(len(facts)))
+	defer cancel()
+
+	now := time.Now()
+	var records []Record
+	for _, fact := range facts {
+		fact = strings.TrimSpace(fact)
+		if fact == "" {
+			continue
+		}
+		vector, err := s.embed(ctx, fact)
+		if err != nil {
+			return 0, err
+		}
+		matches, err := s.store.Search(ctx, owner, vector, 1)
+		if err != nil {
+			return 0, fmt.Errorf("检索记忆失败: %v", err)
+		}
+		if len(matches) > 0 && matches[0].Score >= duplicateScore {
+			logrus.WithField("fact", fact).Debug("记忆已存在，跳过")
+			continue
+		}
+		records = append(records, Record{Owner: owner, Text: fact, Vector: vector, CreatedAt: now})
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+	if err := s.store.Add(ctx, records); err != nil {
+		return 0, fmt.Errorf("保存记忆失败: %v", err)
+	}
+	return len(records), nil
+}
+
+// Close 关闭向量库
+func (s *Service) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,201 @@
+package qdrant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/memory"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultURL        = "http://localhost:6333"
+	defaultCollection = "xiaozhi_memory"
+	requestTimeout    = 10 * time.Second
+)
+
+// Store 通过 REST 接口访问 Qdrant，所有用户共用一个集合，按 payload 中的 owner 过滤
+type Store struct {
+	url        string
+	collection string
+	apiKey     string
+	client     *http.Client
+
+	mu      sync.Mutex
+	ensured bool // 集合已确认存在
+}
+
+// NewStore 创建Qdrant向量库，集合在第一次保存时按向量维度创建
+func NewStore(config *configs.MemoryStoreConfig) (*Store, error) {
+	s := &Store{
+		url:        defaultURL,
+		collection: defaultCollection,
+		client:     &http.Client{Timeout: requestTimeout},
+	}
+	if url, _ := config.Extra["url"].(string); url != "" {
+		s.url = strings.TrimRight(url, "/")
+	}
+	if collection, _ := config.Extra["collection"].(string); collection != "" {
+		s.collection = collection
+	}
+	s.apiKey, _ = config.Extra["api_key"].(string)
+	return s, nil
+}
+
+// do 发送请求，out 不为空时解析响应
+func (s *Store) do(ctx context.Context, method, path string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.url+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("api-key", s.apiKey)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("请求Qdrant失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("读取Qdrant响应失败: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return resp.StatusCode, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("Qdrant返回错误，状态码 %d: %s", resp.StatusCode, string(data))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("解析Qdrant响应失败: %v", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// ensureCollection 集合不存在时按向量维度创建，使用余弦距离
+func (s *Store) ensureCollection(ctx context.Context, dimensions int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ensured {
+		return nil
+	}
+	status, err := s.do(ctx, http.MethodGet, "/collections/"+s.collection, nil, nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		body := map[string]interface{}{
+			"vectors": map[string]interface{}{"size": dimensions, "distance": "Cosine"},
+		}
+		if _, err := s.do(ctx, http.MethodPut, "/collections/"+s.collection, body, nil); err != nil {
+			return fmt.Errorf("创建Qdrant集合失败: %v", err)
+		}
+		index := map[string]interface{}{"field_name": "owner", "field_schema": "keyword"}
+		if _, err := s.do(ctx, http.MethodPut, "/collections/"+s.collection+"/index", index, nil); err != nil {
+			return fmt.Errorf("创建Qdrant索引失败: %v", err)
+		}
+	}
+	s.ensured = true
+	return nil
+}
+
+// Add 实现 memory.Store 接口
+func (s *Store) Add(ctx context.Context, records []memory.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := s.ensureCollection(ctx, len(records[0].Vector)); err != nil {
+		return err
+	}
+	points := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		points = append(points, map[string]interface{}{
+			"id":     uuid.New().String(),
+			"vector": record.Vector,
+			"payload": map[string]interface{}{
+				"owner":      record.Owner,
+				"text":       record.Text,
+				"created_at": record.CreatedAt.Unix(),
+			},
+		})
+	}
+	_, err := s.do(ctx, http.MethodPut, "/collections/"+s.collection+"/points?wait=true",
+		map[string]interface{}{"points": points}, nil)
+	return err
+}
+
+// Search 实现 memory.Store 接口，Qdrant的余弦距离得分即为相似度
+func (s *Store) Search(ctx context.Context, owner string, vector []float32, topK int) ([]memory.Match, error) {
+	body := map[string]interface{}{
+		"vector":       vector,
+		"limit":        topK,
+		"with_payload": true,
+		"filter": map[string]interface{}{
+			"must": []map[string]interface{}{
+				{"key": "owner", "match": map[string]interface{}{"value": owner}},
+			},
+		},
+	}
+	var result struct {
+		Result []struct {
+			Score   float64 `json:"score"`
+			Payload struct {
+				Owner     string `json:"owner"`
+				Text      string `json:"text"`
+				CreatedAt int64  `json:"created_at"`
+			} `json:"payload"`
+		} `json:"result"`
+	}
+	status, err := s.do(ctx, http.MethodPost, "/collections/"+s.collection+"/points/search", body, &result)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		// 还没有保存过记忆，集合尚未创建
+		return nil, nil
+	}
+
+	matches := make([]memory.Match, 0, len(result.Result))
+	for _, point := range result.Result {
+		matches = append(matches, memory.Match{
+			Record: memory.Record{
+				Owner:     point.Payload.Owner,
+				Text:      point.Payload.Text,
+				CreatedAt: time.Unix(point.Payload.CreatedAt, 0),
+			},
+			Score: point.Score,
+		})
+	}
+	return matches, nil
+}
+
+// Close 实现 memory.Store 接口
+func (s *Store) Close() error {
+	return nil
+}
+
+func init() {
+	memory.Register("qdrant", func(config *configs.MemoryStoreConfig) (memory.Store, error) {
+		return NewStore(config)
+	})
+}
@@ -0,0 +1,193 @@
+package sqlitevec
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/memory"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	driverName  = "sqlite3_vec"
+	defaultPath = "data/memory.db"
+)
+
+var (
+	registerOnce   sync.Once
+	dimensionsExpr = regexp.MustCompile(`(?i)FLOAT\[(\d+)\]`)
+)
+
+// Store 使用 sqlite-vec 扩展的本地向量库
+// 记忆文本存放在 memories 表，向量存放在以用户为分区键的 vec0 虚拟表 memory_vectors 中，两表以 rowid 关联
+type Store struct {
+	db *sql.DB
+
+	mu         sync.Mutex
+	dimensions int // 向量表的维度，0表示向量表尚未创建
+}
+
+// NewStore 打开数据库并加载 sqlite-vec 扩展
+// extension 为扩展动态库路径（如 ./lib/vec0.so），同一进程只能加载一个扩展路径
+func NewStore(config *configs.MemoryStoreConfig) (*Store, error) {
+	extension, _ := config.Extra["extension"].(string)
+	if extension == "" {
+		return nil, fmt.Errorf("缺少sqlite-vec扩展路径 extension")
+	}
+	path, _ := config.Extra["path"].(string)
+	if path == "" {
+		path = defaultPath
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建记忆数据库目录失败: %v", err)
+	}
+	registerOnce.Do(func() {
+		sql.Register(driverName, &sqlite3.SQLiteDriver{Extensions: []string{extension}})
+	})
+
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return nil, fmt.Errorf("打开记忆数据库失败: %v", err)
+	}
+	var version string
+	if err := db.QueryRow("SELECT vec_version()").Scan(&version); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("加载sqlite-vec扩展失败: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS memories (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner TEXT NOT NULL,
+		text TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("创建记忆表失败: %v", err)
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_memories_owner ON memories(owner)"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("创建记忆索引失败: %v", err)
+	}
+
+	s := &Store{db: db}
+	// 向量表已存在时沿用其维度
+	var ddl string
+	err = db.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'memory_vectors'").Scan(&ddl)
+	if err == nil {
+		if m := dimensionsExpr.FindStringSubmatch(ddl); m != nil {
+			s.dimensions, _ = strconv.Atoi(m[1])
+		}
+	}
+	return s, nil
+}
+
+// ensureTable 第一次保存时按向量维度创建向量表
+func (s *Store) ensureTable(dimensions int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dimensions != 0 {
+		if s.dimensions != dimensions {
+			return fmt.Errorf("向量维度 %d 与已有记忆的维度 %d 不一致，更换Embedding模型后需要清空记忆库", dimensions, s.dimensions)
+		}
+		return nil
+	}
+	ddl := fmt.Sprintf("CREATE VIRTUAL TABLE memory_vectors USING vec0(owner TEXT PARTITION KEY, embedding FLOAT[%d] distance_metric=cosine)", dimensions)
+	if _, err := s.db.Exec(ddl); err != nil {
+		return fmt.Errorf("创建向量表失败: %v", err)
+	}
+	s.dimensions = dimensions
+	return nil
+}
+
+// Add 实现 memory.Store 接口
+func (s *Store) Add(ctx context.Context, records []memory.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := s.ensureTable(len(records[0].Vector)); err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, record := range records {
+		if len(record.Vector) != s.dimensions {
+			return fmt.Errorf("向量维度 %d 与向量表的维度 %d 不一致", len(record.Vector), s.dimensions)
+		}
+		result, err := tx.ExecContext(ctx, "INSERT INTO memories (owner, text, created_at) VALUES (?, ?, ?)",
+			record.Owner, record.Text, record.CreatedAt.Unix())
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		vector, _ := json.Marshal(record.Vector)
+		if _, err := tx.ExecContext(ctx, "INSERT INTO memory_vectors (rowid, owner, embedding) VALUES (?, ?, ?)",
+			id, record.Owner, string(vector)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Search 实现 memory.Store 接口，余弦距离换算为相似度
+func (s *Store) Search(ctx context.Context, owner string, vector []float32, topK int) ([]memory.Match, error) {
+	s.mu.Lock()
+	dimensions := s.dimensions
+	s.mu.Unlock()
+	if dimensions == 0 {
+		return nil, nil
+	}
+	if len(vector) != dimensions {
+		return nil, fmt.Errorf("向量维度 %d 与向量表的维度 %d 不一致", len(vector), dimensions)
+	}
+
+	query, _ := json.Marshal(vector)
+	rows, err := s.db.QueryContext(ctx, `WITH knn AS (
+		SELECT rowid, distance FROM memory_vectors WHERE embedding MATCH ? AND k = ? AND owner = ?
+	)
+	SELECT m.owner, m.text, m.created_at, knn.distance FROM knn JOIN memories m ON m.id = knn.rowid
+	ORDER BY knn.distance`, string(query), topK, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []memory.Match
+	for rows.Next() {
+		var match memory.Match
+		var createdAt int64
+		var distance float64
+		if err := rows.Scan(&match.Owner, &match.Text, &createdAt, &distance); err != nil {
+			return nil, err
+		}
+		match.CreatedAt = time.Unix(createdAt, 0)
+		match.Score = 1 - distance
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+// Close 实现 memory.Store 接口
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func init() {
+	memory.Register("sqlite-vec", func(config *configs.MemoryStoreConfig) (memory.Store, error) {
+		return NewStore(config)
+	})
+}
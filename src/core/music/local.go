@@ -0,0 +1,110 @@
+package music
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	defaultMusicDir = "./music"
+	minSimilarity   = 0.5 // 文件名与搜索词的相似度低于该值时不算匹配
+)
+
+// LocalSource 本地目录音乐源，文件名为“歌名”或“歌手 - 歌名”，支持 MP3 和 WAV
+type LocalSource struct {
+	dir string
+}
+
+// NewLocalSource 创建本地目录音乐源
+func NewLocalSource(config *configs.MusicConfig) (*LocalSource, error) {
+	dir, _ := config.Extra["dir"].(string)
+	if dir == "" {
+		dir = defaultMusicDir
+	}
+	return &LocalSource{dir: dir}, nil
+}
+
+// tracks 目录下的所有歌曲
+func (s *LocalSource) tracks() ([]Track, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取音乐目录失败: %v", err)
+	}
+	var tracks []Track
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".mp3" && ext != ".wav") {
+			continue
+		}
+		track := Track{ID: filepath.Join(s.dir, entry.Name()), Title: strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))}
+		if artist, title, ok := strings.Cut(track.Title, " - "); ok {
+			track.Artist, track.Title = strings.TrimSpace(artist), strings.TrimSpace(title)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, nil
+}
+
+// Search 实现 Source 接口，按文件名模糊匹配
+func (s *LocalSource) Search(ctx context.Context, query string, limit int) ([]Track, error) {
+	tracks, err := s.tracks()
+	if err != nil {
+		return nil, err
+	}
+	if query == "" {
+		rand.Shuffle(len(tracks), func(i, j int) { tracks[i], tracks[j] = tracks[j], tracks[i] })
+		if len(tracks) > limit {
+			tracks = tracks[:limit]
+		}
+		return tracks, nil
+	}
+
+	type scored struct {
+		track Track
+		score float64
+	}
+	var matches []scored
+	for _, track := range tracks {
+		score := utils.MusicSimilarity(query, track.Title)
+		if track.Artist != "" {
+			score = max(score, utils.MusicSimilarity(query, track.Artist+track.Title), utils.MusicSimilarity(query, track.Artist))
+		}
+		if score >= minSimilarity {
+			matches = append(matches, scored{track, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	result := make([]Track, 0, limit)
+	for _, match := range matches {
+		if len(result) >= limit {
+			break
+		}
+		result = append(result, match.track)
+	}
+	return result, nil
+}
+
+// Open 实现 Source 接口
+func (s *LocalSource) Open(ctx context.Context, track Track) (io.ReadCloser, error) {
+	file, err := os.Open(track.ID)
+	if err != nil {
+		return nil, fmt.Errorf("打开音乐文件失败: %v", err)
+	}
+	return file, nil
+}
+
+func init() {
+	Register("local", func(config *configs.MusicConfig) (Source, error) {
+		return NewLocalSource(config)
+	})
+}
@@ -0,0 +1,63 @@
+// Package music 音乐源：在本地目录或 Navidrome 等 Subsonic 兼容服务中搜索歌曲，
+// 打开的音频流交给对话处理器实时转码为 Opus 帧推送到设备
+package music
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+// Track 一首歌曲
+type Track struct {
+	ID       string // 音乐源内的标识，本地目录为文件路径
+	Title    string
+	Artist   string
+	Album    string
+	Duration int // 时长（秒），未知时为0
+}
+
+// Name 播报用的歌曲名称
+func (t Track) Name() string {
+	if t.Artist == "" {
+		return t.Title
+	}
+	return t.Artist + "的" + t.Title
+}
+
+// Source 音乐源接口
+type Source interface {
+	// Search 按歌名、歌手搜索歌曲，按匹配程度排序；query 为空时随机返回 limit 首
+	Search(ctx context.Context, query string, limit int) ([]Track, error)
+	// Open 打开歌曲的音频流，格式为 MP3 或 WAV
+	Open(ctx context.Context, track Track) (io.ReadCloser, error)
+}
+
+// SourceFactory 音乐源工厂函数类型
+type SourceFactory func(config *configs.MusicConfig) (Source, error)
+
+var (
+	factories = make(map[string]SourceFactory)
+)
+
+// Register 注册音乐源工厂
+func Register(name string, factory SourceFactory) {
+	factories[name] = factory
+}
+
+// Create 创建音乐源实例
+func Create(name string, config *configs.MusicConfig) (Source, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的音乐源: %s", name)
+	}
+
+	source, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建音乐源失败: %v", err)
+	}
+
+	return source, nil
+}
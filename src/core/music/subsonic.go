@@ -0,0 +1,184 @@
+package music
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+const (
+	subsonicAPIVersion = "1.16.1"
+	subsonicClient     = "xiaozhi-server"
+	apiTimeout         = 10 * time.Second
+)
+
+// SubsonicSource Navidrome、Airsonic 等 Subsonic 兼容服务，统一转码为 MP3 推流
+type SubsonicSource struct {
+	url      string
+	username string
+	password string
+	api      *http.Client // 搜索等接口，带超时
+	stream   *http.Client // 音频流，由上下文控制结束
+}
+
+// subsonicSong 接口返回的歌曲
+type subsonicSong struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Album    string `json:"album"`
+	Duration int    `json:"duration"`
+}
+
+// subsonicResponse 接口响应，只解析用到的字段
+type subsonicResponse struct {
+	Response struct {
+		Status string `json:"status"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+		SearchResult3 struct {
+			Song []subsonicSong `json:"song"`
+		} `json:"searchResult3"`
+		RandomSongs struct {
+			Song []subsonicSong `json:"song"`
+		} `json:"randomSongs"`
+	} `json:"subsonic-response"`
+}
+
+// NewSubsonicSource 创建Subsonic音乐源
+func NewSubsonicSource(config *configs.MusicConfig) (*SubsonicSource, error) {
+	s := &SubsonicSource{
+		api:    &http.Client{Timeout: apiTimeout},
+		stream: &http.Client{},
+	}
+	s.url, _ = config.Extra["url"].(string)
+	s.username, _ = config.Extra["username"].(string)
+	s.password, _ = config.Extra["password"].(string)
+	if s.url == "" || s.username == "" {
+		return nil, fmt.Errorf("缺少Subsonic服务地址 url 或用户名 username")
+	}
+	s.url = strings.TrimRight(s.url, "/")
+	return s, nil
+}
+
+// endpoint 带认证参数的接口地址，密码以加盐MD5令牌的形式传递
+func (s *SubsonicSource) endpoint(method string, params url.Values) string {
+	salt := make([]byte, 8)
+	rand.Read(salt)
+	saltHex := hex.EncodeToString(salt)
+	token := md5.Sum([]byte(s.password + saltHex))
+
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("u", s.username)
+	params.Set("t", hex.EncodeToString(token[:]))
+	params.Set("s", saltHex)
+	params.Set("v", subsonicAPIVersion)
+	params.Set("c", subsonicClient)
+	params.Set("f", "json")
+	return s.url + "/rest/" + method + "?" + params.Encode()
+}
+
+// call 调用接口并检查响应状态
+func (s *SubsonicSource) call(ctx context.Context, method string, params url.Values) (*subsonicResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint(method, params), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.api.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求Subsonic失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Subsonic返回错误，状态码 %d", resp.StatusCode)
+	}
+	var result subsonicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析Subsonic响应失败: %v", err)
+	}
+	if result.Response.Status != "ok" {
+		if result.Response.Error != nil {
+			return nil, fmt.Errorf("Subsonic返回错误 %d: %s", result.Response.Error.Code, result.Response.Error.Message)
+		}
+		return nil, fmt.Errorf("Subsonic返回错误状态: %s", result.Response.Status)
+	}
+	return &result, nil
+}
+
+// Search 实现 Source 接口
+func (s *SubsonicSource) Search(ctx context.Context, query string, limit int) ([]Track, error) {
+	var songs []subsonicSong
+	if query == "" {
+		result, err := s.call(ctx, "getRandomSongs", url.Values{"size": {strconv.Itoa(limit)}})
+		if err != nil {
+			return nil, err
+		}
+		songs = result.Response.RandomSongs.Song
+	} else {
+		result, err := s.call(ctx, "search3", url.Values{
+			"query":       {query},
+			"songCount":   {strconv.Itoa(limit)},
+			"artistCount": {"0"},
+			"albumCount":  {"0"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		songs = result.Response.SearchResult3.Song
+	}
+
+	tracks := make([]Track, 0, len(songs))
+	for _, song := range songs {
+		tracks = append(tracks, Track{
+			ID:       song.ID,
+			Title:    song.Title,
+			Artist:   song.Artist,
+			Album:    song.Album,
+			Duration: song.Duration,
+		})
+	}
+	return tracks, nil
+}
+
+// Open 实现 Source 接口，由服务端转码为MP3
+func (s *SubsonicSource) Open(ctx context.Context, track Track) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint("stream", url.Values{
+		"id":     {track.ID},
+		"format": {"mp3"},
+	}), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.stream.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求音频流失败: %v", err)
+	}
+	// 出错时接口返回JSON而不是音频
+	if resp.StatusCode != http.StatusOK || strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("获取音频流失败，状态码 %d: %s", resp.StatusCode, string(body))
+	}
+	return resp.Body, nil
+}
+
+func init() {
+	Register("subsonic", func(config *configs.MusicConfig) (Source, error) {
+		return NewSubsonicSource(config)
+	})
+}
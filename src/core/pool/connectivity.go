@@ -537,7 +537,7 @@ func (hc *HealthChecker) checkVLLLMProvider(ctx context.Context, vlllmType strin
 		}
 
 		// 调用VLLLM的ResponseWithImage方法
-		responseChan, err := vlllmProvider.ResponseWithImage(testCtx, "health_check", []providers.Message{}, imageData, testPrompt)
+		responseChan, errChan, err := vlllmProvider.ResponseWithImage(testCtx, "health_check", []providers.Message{}, imageData, testPrompt)
 		if err != nil {
 			result.Success = false
 			result.Error = fmt.Errorf("VLLLM图像分析测试失败: %v", err)
@@ -551,6 +551,13 @@ func (hc *HealthChecker) checkVLLLMProvider(ctx context.Context, vlllmType strin
 		for content := range responseChan {
 			response.WriteString(content)
 		}
+		if err := <-errChan; err != nil {
+			result.Success = false
+			result.Error = fmt.Errorf("VLLLM图像分析测试失败: %v", err)
+			result.Duration = time.Since(start)
+			hc.results["VLLLM"] = result
+			return result.Error
+		}
 		responseText := response.String()
 
 		// 验证响应
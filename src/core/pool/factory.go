@@ -1,20 +1,32 @@
 package pool
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"time"
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/chaos"
+
+	"xiaozhi-server-go/src/core/hedge"
 	"xiaozhi-server-go/src/core/mcp"
 	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/providers/embedding"
 	"xiaozhi-server-go/src/core/providers/llm"
 	"xiaozhi-server-go/src/core/providers/tts"
 	"xiaozhi-server-go/src/core/providers/vlllm"
+
+	"github.com/sirupsen/logrus"
 )
 
+// defaultHedgeDelay 未配置 delay_ms 时发送对冲请求前的等待时间
+const defaultHedgeDelay = 500 * time.Millisecond
+
 /*
 * 工厂类，用于创建不同类型的资源池工厂。
 * 通过配置文件和提供者类型，动态创建资源池工厂。
-* 支持ASR、LLM、TTS和VLLLM等多种提供者类型。
+* 支持ASR、LLM、TTS、VLLLM和Embedding等多种提供者类型。
 * 每个工厂实现了ResourceFactory接口，提供Create和Destroy方法。
  */
 
@@ -43,6 +55,39 @@ func (f *ProviderFactory) Destroy(resource interface{}) error {
 	return nil
 }
 
+// HealthCheck 检查空闲的提供者，提供者实现了 providers.HealthChecker 时调用，其他提供者视为健康
+func (f *ProviderFactory) HealthCheck(resource interface{}) error {
+	if checker, ok := resource.(providers.HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// sameAs 判断 other 是否创建相同配置的提供者，重载配置时用于沿用原来的资源池
+// 故障注入器带有随机数状态，只比较是否开启
+func (f *ProviderFactory) sameAs(other ResourceFactory) bool {
+	o, ok := other.(*ProviderFactory)
+	if !ok || f.providerType != o.providerType || len(f.params) != len(o.params) {
+		return false
+	}
+	if !reflect.DeepEqual(f.config, o.config) {
+		return false
+	}
+	for key, value := range f.params {
+		otherValue, ok := o.params[key]
+		if !ok {
+			return false
+		}
+		if _, isInjector := value.(*chaos.Injector); isInjector {
+			continue
+		}
+		if !reflect.DeepEqual(value, otherValue) {
+			return false
+		}
+	}
+	return true
+}
+
 func (f *ProviderFactory) createProvider() (interface{}, error) {
 	switch f.providerType {
 	case "asr":
@@ -53,15 +98,54 @@ func (f *ProviderFactory) createProvider() (interface{}, error) {
 		return asr.Create(asrType, cfg, delete_audio)
 	case "llm":
 		cfg := f.config.(*llm.Config)
-		return llm.Create(cfg.Type, cfg)
+		provider, err := llm.Create(cfg.Type, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if injector, ok := f.params["chaos"].(*chaos.Injector); ok {
+			// 只对主提供者注入故障，便于验证切换到备用提供者
+			provider = llm.NewFaultProvider(provider, func(ctx context.Context) error {
+				return injector.Inject(ctx, chaos.TargetLLM)
+			})
+		}
+		policy, ok := f.params["failover"].(*llm.FailoverPolicy)
+		if !ok {
+			return provider, nil
+		}
+		var fallback llm.Provider
+		if fallbackCfg, ok := f.params["fallback"].(*llm.Config); ok {
+			if fallback, err = llm.Create(fallbackCfg.Type, fallbackCfg); err != nil {
+				provider.Cleanup()
+				return nil, fmt.Errorf("创建备用LLM失败: %v", err)
+			}
+		}
+		return llm.NewFailoverProvider(provider, fallback, *policy), nil
 	case "tts":
-		cfg := f.config.(*tts.Config)
+		// 每个实例使用独立的配置副本，连接内切换音色、语速不影响其他连接
+		cfg := *f.config.(*tts.Config)
 		params := f.params
 		delete_audio, _ := params["delete_audio"].(bool)
-		return tts.Create(cfg.Type, cfg, delete_audio)
+		provider, err := tts.Create(cfg.Type, &cfg, delete_audio)
+		if err != nil {
+			return nil, err
+		}
+		if policy, ok := params["hedge"].(*hedge.Policy); ok {
+			return tts.NewHedgeProvider(provider, *policy), nil
+		}
+		return provider, nil
 	case "vlllm":
 		cfg := f.config.(*configs.VLLMConfig)
 		return vlllm.Create(cfg.Type, cfg)
+	case "embedding":
+		cfg := f.config.(*configs.EmbeddingConfig)
+		provider, err := embedding.Create(cfg.Type, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if policy, ok := f.params["hedge"].(*hedge.Policy); ok {
+			return embedding.NewHedgeProvider(provider, *policy), nil
+		}
+		return provider, nil
 	case "mcp":
 		cfg := f.config.(*configs.Config)
 		return mcp.NewManagerForPool(cfg), nil
@@ -89,27 +173,52 @@ func NewASRFactory(asrType string, config *configs.Config) ResourceFactory {
 }
 
 func NewLLMFactory(llmType string, config *configs.Config) ResourceFactory {
-	if llmCfg, ok := config.LLM[llmType]; ok {
-		return &ProviderFactory{
-			providerType: "llm",
-			config: &llm.Config{
-				Type:        llmCfg.Type,
-				ModelName:   llmCfg.ModelName,
-				BaseURL:     llmCfg.BaseURL,
-				APIKey:      llmCfg.APIKey,
-				Temperature: llmCfg.Temperature,
-				MaxTokens:   llmCfg.MaxTokens,
-				TopP:        llmCfg.TopP,
-				Extra:       llmCfg.Extra,
-			},
+	llmCfg, ok := config.LLM[llmType]
+	if !ok {
+		return nil
+	}
+	factory := &ProviderFactory{
+		providerType: "llm",
+		config:       newLLMConfig(llmCfg),
+		params:       map[string]interface{}{},
+	}
+	if injector := chaos.New(config.Chaos); injector != nil {
+		factory.params["chaos"] = injector
+	}
+	if failover := config.LLMFailover; failover.Enabled {
+		factory.params["failover"] = &llm.FailoverPolicy{
+			MaxRetries:   failover.MaxRetries,
+			BaseDelay:    time.Duration(failover.BaseDelayMs) * time.Millisecond,
+			MaxDelay:     time.Duration(failover.MaxDelayMs) * time.Millisecond,
+			StallTimeout: time.Duration(failover.StallTimeout) * time.Second,
+		}
+		if failover.Fallback != "" && failover.Fallback != llmType {
+			if fallbackCfg, ok := config.LLM[failover.Fallback]; ok {
+				factory.params["fallback"] = newLLMConfig(fallbackCfg)
+			} else {
+				logrus.Warnf("备用LLM配置 %s 不存在，仅启用重试", failover.Fallback)
+			}
 		}
 	}
-	return nil
+	return factory
+}
+
+func newLLMConfig(llmCfg configs.LLMConfig) *llm.Config {
+	return &llm.Config{
+		Type:        llmCfg.Type,
+		ModelName:   llmCfg.ModelName,
+		BaseURL:     llmCfg.BaseURL,
+		APIKey:      llmCfg.APIKey,
+		Temperature: llmCfg.Temperature,
+		MaxTokens:   llmCfg.MaxTokens,
+		TopP:        llmCfg.TopP,
+		Extra:       llmCfg.Extra,
+	}
 }
 
 func NewTTSFactory(ttsType string, config *configs.Config) ResourceFactory {
 	if ttsCfg, ok := config.TTS[ttsType]; ok {
-		return &ProviderFactory{
+		factory := &ProviderFactory{
 			providerType: "tts",
 			config: &tts.Config{
 				Type:            ttsCfg.Type,
@@ -119,13 +228,19 @@ func NewTTSFactory(ttsType string, config *configs.Config) ResourceFactory {
 				AppID:           ttsCfg.AppID,
 				Token:           ttsCfg.Token,
 				Cluster:         ttsCfg.Cluster,
+				Speed:           ttsCfg.Speed,
 				SurportedVoices: ttsCfg.SurportedVoices,
+				Extra:           ttsCfg.Extra,
 			},
 			params: map[string]interface{}{
 				"type":         ttsCfg.Type,
 				"delete_audio": config.DeleteAudio,
 			},
 		}
+		if rule := config.Hedge.TTS; rule.Enabled {
+			factory.params["hedge"] = newHedgePolicy(rule)
+		}
+		return factory
 	}
 	return nil
 }
@@ -140,6 +255,32 @@ func NewVLLLMFactory(vlllmType string, config *configs.Config) ResourceFactory {
 	return nil
 }
 
+func NewEmbeddingFactory(embeddingType string, config *configs.Config) ResourceFactory {
+	if embeddingCfg, ok := config.Embedding[embeddingType]; ok {
+		factory := &ProviderFactory{
+			providerType: "embedding",
+			config:       &embeddingCfg,
+			params:       map[string]interface{}{},
+		}
+		if rule := config.Hedge.Embedding; rule.Enabled {
+			factory.params["hedge"] = newHedgePolicy(rule)
+		}
+		return factory
+	}
+	return nil
+}
+
+func newHedgePolicy(rule configs.HedgeRule) *hedge.Policy {
+	delay := time.Duration(rule.DelayMs) * time.Millisecond
+	if delay <= 0 {
+		delay = defaultHedgeDelay
+	}
+	return &hedge.Policy{
+		Delay:    delay,
+		MaxChars: rule.MaxChars,
+	}
+}
+
 func NewMCPFactory(config *configs.Config) ResourceFactory {
 	return &ProviderFactory{
 		providerType: "mcp",
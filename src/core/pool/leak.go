@@ -0,0 +1,141 @@
+package pool
+
+import (
+	"reflect"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/sirupsen/logrus"
+)
+
+// leakCheckInterval 检查借出未归还资源的间隔
+const leakCheckInterval = time.Minute
+
+// borrow 借出中的资源
+type borrow struct {
+	pool   *ResourcePool
+	owner  string // 借用者的客户端ID，离线任务为空
+	since  time.Time
+	warned bool // 已经报告过超时，避免每次检查重复输出
+}
+
+// get 从资源池为 owner 获取资源并记录来源和借出时间
+func (pm *PoolManager) get(pool *ResourcePool, owner string) (interface{}, error) {
+	resource, err := pool.Get()
+	if err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(resource).Comparable() {
+		pm.borrowMu.Lock()
+		pm.borrowed[resource] = &borrow{pool: pool, owner: owner, since: time.Now()}
+		pm.borrowMu.Unlock()
+	}
+	return resource, nil
+}
+
+// put 重置资源并归还到借出它的资源池，来源未知时归还到 pool，已被强制回收的资源直接忽略
+func (pm *PoolManager) put(pool *ResourcePool, resource interface{}) error {
+	if reflect.TypeOf(resource).Comparable() {
+		pm.borrowMu.Lock()
+		if _, ok := pm.reclaimed[resource]; ok {
+			delete(pm.reclaimed, resource)
+			pm.borrowMu.Unlock()
+			logrus.Warn("归还的资源已被强制回收，忽略")
+			return nil
+		}
+		if b, ok := pm.borrowed[resource]; ok {
+			pool = b.pool
+			delete(pm.borrowed, resource)
+		}
+		pm.borrowMu.Unlock()
+	}
+	if pool == nil {
+		return nil
+	}
+	if err := pool.Reset(resource); err != nil {
+		logrus.WithError(err).Warn("重置资源状态失败")
+	}
+	return pool.Put(resource)
+}
+
+// SetOwnerChecker 设置判断借用者是否在线的函数，强制回收只回收借用者已离线的资源
+func (pm *PoolManager) SetOwnerChecker(active func(owner string) bool) {
+	pm.borrowMu.Lock()
+	defer pm.borrowMu.Unlock()
+	pm.ownerActive = active
+}
+
+// applyLeakDetection 更新泄漏检测配置，开启时启动检查协程
+func (pm *PoolManager) applyLeakDetection(cfg configs.ResourcePoolConfig) {
+	pm.borrowMu.Lock()
+	pm.leakThreshold = time.Duration(cfg.LeakThreshold) * time.Second
+	pm.reclaimLeaks = cfg.ReclaimLeaks
+	pm.borrowMu.Unlock()
+	if cfg.LeakThreshold > 0 {
+		pm.leakOnce.Do(func() { go pm.detectLeaks() })
+	}
+}
+
+// detectLeaks 定期检查借出超过阈值仍未归还的资源，直到 Close
+func (pm *PoolManager) detectLeaks() {
+	ticker := time.NewTicker(leakCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pm.stopChan:
+			return
+		case <-ticker.C:
+			pm.checkLeaks()
+		}
+	}
+}
+
+// checkLeaks 报告借出超过阈值的资源及其借用者，开启 reclaim_leaks 时强制回收借用者已离线的资源。
+// 离线任务（长文本合成、离线转写、向量化等）借出的资源没有借用者，无法判断任务是否仍在使用，只报告不回收
+func (pm *PoolManager) checkLeaks() {
+	type leak struct {
+		resource interface{}
+		*borrow
+	}
+	var reclaim []leak
+
+	pm.borrowMu.Lock()
+	threshold := pm.leakThreshold
+	if threshold <= 0 {
+		pm.borrowMu.Unlock()
+		return
+	}
+	for resource, b := range pm.borrowed {
+		held := time.Since(b.since)
+		if held < threshold {
+			continue
+		}
+		online := b.owner != "" && pm.ownerActive != nil && pm.ownerActive(b.owner)
+		if pm.reclaimLeaks && b.owner != "" && !online {
+			delete(pm.borrowed, resource)
+			pm.reclaimed[resource] = struct{}{}
+			reclaim = append(reclaim, leak{resource, b})
+			continue
+		}
+		if !b.warned {
+			b.warned = true
+			logrus.WithFields(logrus.Fields{
+				"owner":  b.owner,
+				"online": online,
+				"held":   held.Round(time.Second).String(),
+			}).Warnf("资源借出超过 %v 仍未归还，可能已泄漏", threshold)
+		}
+	}
+	pm.borrowMu.Unlock()
+
+	for _, l := range reclaim {
+		logrus.WithFields(logrus.Fields{
+			"owner": l.owner,
+			"held":  time.Since(l.since).Round(time.Second).String(),
+		}).Warn("借用者已离线，强制回收未归还的资源")
+		if err := l.pool.Reclaim(l.resource); err != nil {
+			logrus.WithError(err).Error("销毁强制回收的资源失败")
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package pool
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeResource struct{ id int }
+
+type fakeFactory struct {
+	mu        sync.Mutex
+	created   int
+	destroyed []*fakeResource
+}
+
+func (f *fakeFactory) Create() (interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created++
+	return &fakeResource{id: f.created}, nil
+}
+
+func (f *fakeFactory) Destroy(resource interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.destroyed = append(f.destroyed, resource.(*fakeResource))
+	return nil
+}
+
+func TestCheckLeaksReclaimsOnlyOfflineOwners(t *testing.T) {
+	factory := &fakeFactory{}
+	pool, err := NewResourcePool(factory, PoolConfig{MaxSize: 4, CheckInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	pm := &PoolManager{
+		borrowed:      make(map[interface{}]*borrow),
+		reclaimed:     make(map[interface{}]struct{}),
+		leakThreshold: time.Minute,
+		reclaimLeaks:  true,
+		ownerActive:   func(owner string) bool { return owner == "online" },
+	}
+	held := make(map[string]interface{})
+	for _, owner := range []string{"", "online", "gone"} {
+		resource, err := pm.get(pool, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pm.borrowed[resource].since = time.Now().Add(-time.Hour)
+		held[owner] = resource
+	}
+
+	pm.checkLeaks()
+
+	// 只回收借用者已断开的资源，离线任务（没有借用者）和在线连接的资源保留
+	if len(factory.destroyed) != 1 || factory.destroyed[0] != held["gone"] {
+		t.Fatalf("destroyed = %v, want only the offline owner's resource", factory.destroyed)
+	}
+	for _, owner := range []string{"", "online"} {
+		if _, ok := pm.borrowed[held[owner]]; !ok {
+			t.Errorf("borrow of owner %q was dropped", owner)
+		}
+	}
+
+	// 被回收的资源之后归还时忽略，其他资源正常归还
+	for _, resource := range held {
+		if err := pm.put(pool, resource); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if available, total := pool.GetStats(); available != 2 || total != 2 {
+		t.Errorf("stats = %d/%d, want 2/2", available, total)
+	}
+}
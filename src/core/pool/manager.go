@@ -3,23 +3,87 @@ package pool
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/chaos"
 	"xiaozhi-server-go/src/core/mcp"
 	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/embedding"
 	"xiaozhi-server-go/src/core/providers/vlllm"
 	"xiaozhi-server-go/src/core/utils"
 
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	defaultPoolMinSize  = 5
+	defaultPoolMaxSize  = 20
+	defaultPoolHeadroom = 0.2
+	defaultPoolLimit    = 200
+	autoscaleInterval   = 10 * time.Second
+)
+
+// poolSet 一组资源池，重载配置时整体替换
+type poolSet struct {
+	asr       *ResourcePool
+	llm       *ResourcePool
+	tts       *ResourcePool
+	vlllm     *ResourcePool
+	mcp       *ResourcePool
+	embedding *ResourcePool
+
+	// 用户选择的非默认提供者的资源池，键为“类型:配置名称”，首次使用时创建
+	// 写时复制，已取出的快照不受影响
+	extra map[string]*ResourcePool
+}
+
+// all 所有已初始化的资源池
+func (s poolSet) all() []*ResourcePool {
+	var pools []*ResourcePool
+	for _, pool := range []*ResourcePool{s.asr, s.llm, s.tts, s.vlllm, s.mcp, s.embedding} {
+		if pool != nil {
+			pools = append(pools, pool)
+		}
+	}
+	for _, pool := range s.extra {
+		pools = append(pools, pool)
+	}
+	return pools
+}
+
 // PoolManager 资源池管理器
 type PoolManager struct {
-	asrPool   *ResourcePool
-	llmPool   *ResourcePool
-	ttsPool   *ResourcePool
-	vlllmPool *ResourcePool
-	mcpPool   *ResourcePool
+	poolsMu sync.RWMutex
+	pools   poolSet
+	config  *configs.Config // 当前资源池使用的配置
+
+	// 借出的资源所属的资源池和借用者，重载配置后资源仍归还到原来的池
+	borrowMu      sync.Mutex
+	borrowed      map[interface{}]*borrow
+	reclaimed     map[interface{}]struct{} // 被强制回收的资源，借用者之后归还时忽略
+	leakThreshold time.Duration
+	reclaimLeaks  bool
+	ownerActive   func(owner string) bool // 借用者是否仍然在线，未设置时视为已离线
+	leakOnce      sync.Once
+
+	// 按活跃连接数调整资源池大小
+	autoscaleEnabled bool
+	headroom         float64
+	connections      func() int // 活跃连接数，未设置时不调整
+	connMu           sync.Mutex
+	autoscaleOnce    sync.Once
+	stopChan         chan struct{}
+	closeOnce        sync.Once
+}
+
+// ProviderSelection 用户选择的提供者，对应配置中 ASR、LLM、TTS、VLLLM 下的名称，为空时使用 selected_module
+type ProviderSelection struct {
+	ASR   string
+	LLM   string
+	TTS   string
+	VLLLM string
 }
 
 // ProviderSet 提供者集合
@@ -33,20 +97,45 @@ type ProviderSet struct {
 
 // NewPoolManager 创建资源池管理器
 func NewPoolManager(config *configs.Config) (*PoolManager, error) {
-	pm := &PoolManager{}
+	pm := &PoolManager{
+		borrowed:  make(map[interface{}]*borrow),
+		reclaimed: make(map[interface{}]struct{}),
+		stopChan:  make(chan struct{}),
+	}
 
 	// 暂时跳过连通性检查
 	// if err := pm.performConnectivityCheck(config, logrus.New()); err != nil {
 	// 	return nil, fmt.Errorf("资源连通性检查失败: %v", err)
 	// }
 
-	poolConfig := PoolConfig{
-		MinSize:       5,
-		MaxSize:       20,
-		RefillSize:    3,
-		CheckInterval: 30 * time.Second,
+	pools, err := buildPools(config, poolSet{})
+	if err != nil {
+		return nil, err
+	}
+	pm.pools = pools
+	pm.config = config
+	pm.applyAutoscale(config.Pool)
+	pm.applyLeakDetection(config.Pool)
+
+	return pm, nil
+}
+
+// buildPools 按配置创建资源池，old 中提供者配置和池配置都没有变化的资源池直接沿用
+func buildPools(config *configs.Config, old poolSet) (poolSet, error) {
+	var pools poolSet
+	fail := func(err error) (poolSet, error) {
+		// 关闭本次新建的资源池
+		for _, pool := range pools.all() {
+			if !old.contains(pool) {
+				pool.Close()
+			}
+		}
+		return poolSet{}, err
 	}
 
+	poolConfig := newPoolConfig(config.Pool)
+	injector := chaos.New(config.Chaos)
+
 	// 检查配置是否包含所需的模块
 	selectedModule := config.SelectedModule
 
@@ -54,51 +143,57 @@ func NewPoolManager(config *configs.Config) (*PoolManager, error) {
 	if asrType, ok := selectedModule["ASR"]; ok && asrType != "" {
 		asrFactory := NewASRFactory(asrType, config)
 		if asrFactory == nil {
-			return nil, fmt.Errorf("创建ASR工厂失败: 找不到配置 %s", asrType)
+			return fail(fmt.Errorf("创建ASR工厂失败: 找不到配置 %s", asrType))
 		}
-		asrPool, err := NewResourcePool(asrFactory, poolConfig)
+		asrPool, err := reusePool(old.asr, asrFactory, poolConfig, injector)
 		if err != nil {
-			return nil, fmt.Errorf("初始化ASR资源池失败: %v", err)
+			return fail(fmt.Errorf("初始化ASR资源池失败: %v", err))
+		}
+		pools.asr = asrPool
+		if asrPool != old.asr {
+			_, cnt := asrPool.GetStats()
+			logrus.Infof("ASR资源池初始化成功，类型: %s, 数量：%d", asrType, cnt)
 		}
-		pm.asrPool = asrPool
-		_, cnt := asrPool.GetStats()
-		logrus.Infof("ASR资源池初始化成功，类型: %s, 数量：%d", asrType, cnt)
 	}
 
 	// 初始化LLM池
 	if llmType, ok := selectedModule["LLM"]; ok && llmType != "" {
 		llmFactory := NewLLMFactory(llmType, config)
 		if llmFactory == nil {
-			return nil, fmt.Errorf("创建LLM工厂失败: 找不到配置 %s", llmType)
+			return fail(fmt.Errorf("创建LLM工厂失败: 找不到配置 %s", llmType))
 		}
-		llmPool, err := NewResourcePool(llmFactory, poolConfig)
+		llmPool, err := reusePool(old.llm, llmFactory, poolConfig, injector)
 		if err != nil {
-			return nil, fmt.Errorf("初始化LLM资源池失败: %v", err)
+			return fail(fmt.Errorf("初始化LLM资源池失败: %v", err))
+		}
+		pools.llm = llmPool
+		if llmPool != old.llm {
+			_, cnt := llmPool.GetStats()
+			logrus.WithFields(logrus.Fields{
+				"type":  llmType,
+				"count": cnt,
+			}).Info("LLM资源池初始化成功")
 		}
-		pm.llmPool = llmPool
-		_, cnt := llmPool.GetStats()
-		logrus.WithFields(logrus.Fields{
-			"type":  llmType,
-			"count": cnt,
-		}).Info("LLM资源池初始化成功")
 	}
 
 	// 初始化TTS池
 	if ttsType, ok := selectedModule["TTS"]; ok && ttsType != "" {
 		ttsFactory := NewTTSFactory(ttsType, config)
 		if ttsFactory == nil {
-			return nil, fmt.Errorf("创建TTS工厂失败: 找不到配置 %s", ttsType)
+			return fail(fmt.Errorf("创建TTS工厂失败: 找不到配置 %s", ttsType))
 		}
-		ttsPool, err := NewResourcePool(ttsFactory, poolConfig)
+		ttsPool, err := reusePool(old.tts, ttsFactory, poolConfig, injector)
 		if err != nil {
-			return nil, fmt.Errorf("初始化TTS资源池失败: %v", err)
+			return fail(fmt.Errorf("初始化TTS资源池失败: %v", err))
+		}
+		pools.tts = ttsPool
+		if ttsPool != old.tts {
+			_, cnt := ttsPool.GetStats()
+			logrus.WithFields(logrus.Fields{
+				"type":  ttsType,
+				"count": cnt,
+			}).Info("TTS资源池初始化成功")
 		}
-		pm.ttsPool = ttsPool
-		_, cnt := ttsPool.GetStats()
-		logrus.WithFields(logrus.Fields{
-			"type":  ttsType,
-			"count": cnt,
-		}).Info("TTS资源池初始化成功")
 	}
 
 	// 初始化VLLLM池（可选）
@@ -107,87 +202,343 @@ func NewPoolManager(config *configs.Config) (*PoolManager, error) {
 		if vlllmFactory == nil {
 			logrus.WithField("type", vlllmType).Warn("创建VLLLM工厂失败: 找不到配置")
 		} else {
-			vlllmPool, err := NewResourcePool(vlllmFactory, poolConfig)
+			vlllmPool, err := reusePool(old.vlllm, vlllmFactory, poolConfig, injector)
 			if err != nil {
 				logrus.WithError(err).Warn("初始化VLLLM资源池失败（将继续使用普通LLM）")
 			} else {
-				pm.vlllmPool = vlllmPool
+				pools.vlllm = vlllmPool
 			}
 		}
-		if pm.vlllmPool != nil {
-			_, cnt := pm.vlllmPool.GetStats()
+		if pools.vlllm == nil {
+			logrus.Warn("VLLLM资源池未初始化，将使用普通LLM")
+		} else if pools.vlllm != old.vlllm {
+			_, cnt := pools.vlllm.GetStats()
 			logrus.WithFields(logrus.Fields{
 				"type":  vlllmType,
 				"count": cnt,
 			}).Info("VLLLM资源池初始化成功")
+		}
+	}
+
+	// 初始化Embedding池（可选，供记忆检索和向量化接口使用）
+	if embeddingType, ok := selectedModule["Embedding"]; ok && embeddingType != "" {
+		embeddingFactory := NewEmbeddingFactory(embeddingType, config)
+		if embeddingFactory == nil {
+			logrus.WithField("type", embeddingType).Warn("创建Embedding工厂失败: 找不到配置")
+		} else if embeddingPool, err := reusePool(old.embedding, embeddingFactory, PoolConfig{
+			MinSize:       1,
+			MaxSize:       10,
+			RefillSize:    1,
+			CheckInterval: 30 * time.Second,
+		}, injector); err != nil {
+			logrus.WithError(err).Warn("初始化Embedding资源池失败，向量化功能将不可用")
 		} else {
-			logrus.Warn("VLLLM资源池未初始化，将使用普通LLM")
+			pools.embedding = embeddingPool
+			if embeddingPool != old.embedding {
+				_, cnt := embeddingPool.GetStats()
+				logrus.WithFields(logrus.Fields{
+					"type":  embeddingType,
+					"count": cnt,
+				}).Info("Embedding资源池初始化成功")
+			}
+		}
+	}
+
+	// MCP管理器与设备连接绑定，不依赖提供者配置，重载时沿用原来的池
+	if old.mcp != nil {
+		pools.mcp = old.mcp
+	} else {
+		mcpPoolConfig := PoolConfig{
+			MinSize:       2,
+			MaxSize:       20,
+			RefillSize:    1,
+			CheckInterval: 30 * time.Second,
+			Limit:         poolConfig.Limit,
+			IdleTTL:       poolConfig.IdleTTL,
+			WaitTimeout:   poolConfig.WaitTimeout,
+		}
+
+		// 初始化MCP池（总是初始化，因为MCP是核心功能）
+		logrus.Info("开始初始化MCP资源池，请等待...")
+		mcpFactory := NewMCPFactory(config)
+		if mcpFactory != nil {
+			mcpPool, err := reusePool(nil, mcpFactory, mcpPoolConfig, injector)
+			if err != nil {
+				return fail(fmt.Errorf("初始化MCP资源池失败: %v", err))
+			}
+			pools.mcp = mcpPool
+			_, cnt := mcpPool.GetStats()
+			logrus.WithField("count", cnt).Info("MCP资源池初始化成功")
+		} else {
+			logrus.Warn("创建MCP工厂失败，MCP功能将不可用")
+		}
+	}
+
+	if injector != nil {
+		logrus.Warn("故障注入已开启，请勿在生产环境使用")
+	}
+
+	return pools, nil
+}
+
+// contains 判断资源池是否属于这一组
+func (s poolSet) contains(pool *ResourcePool) bool {
+	for _, p := range s.all() {
+		if p == pool {
+			return true
+		}
+	}
+	return false
+}
+
+// reusePool 提供者配置和池配置都没有变化时沿用 old，否则创建新的资源池
+func reusePool(old *ResourcePool, factory ResourceFactory, config PoolConfig, injector *chaos.Injector) (*ResourcePool, error) {
+	if old != nil && old.base == config {
+		if f, ok := factory.(*ProviderFactory); ok && f.sameAs(old.factory) {
+			return old, nil
 		}
 	}
+	pool, err := NewResourcePool(factory, config)
+	if err != nil {
+		return nil, err
+	}
+	// 故障注入，仅用于测试环境
+	if injector != nil {
+		pool.SetFaultInjector(injector.Func(chaos.TargetPool))
+	}
+	return pool, nil
+}
 
-	poolConfig = PoolConfig{
-		MinSize:       2,
-		MaxSize:       20,
-		RefillSize:    1,
+// newPoolConfig 按配置生成连接资源池的配置，未配置的项使用默认值
+func newPoolConfig(cfg configs.ResourcePoolConfig) PoolConfig {
+	poolConfig := PoolConfig{
+		MinSize:       cfg.MinSize,
+		MaxSize:       cfg.MaxSize,
+		RefillSize:    3,
 		CheckInterval: 30 * time.Second,
+		IdleTTL:       time.Duration(cfg.IdleTTL) * time.Second,
+		WaitTimeout:   time.Duration(cfg.WaitTimeoutMs) * time.Millisecond,
+	}
+	if poolConfig.MinSize <= 0 {
+		poolConfig.MinSize = defaultPoolMinSize
+	}
+	if poolConfig.MaxSize <= 0 {
+		poolConfig.MaxSize = defaultPoolMaxSize
+	}
+	if poolConfig.MaxSize < poolConfig.MinSize {
+		poolConfig.MaxSize = poolConfig.MinSize
 	}
+	if cfg.Autoscale {
+		poolConfig.Limit = cfg.Limit
+		if poolConfig.Limit <= 0 {
+			poolConfig.Limit = defaultPoolLimit
+		}
+	}
+	return poolConfig
+}
 
-	// 初始化MCP池（总是初始化，因为MCP是核心功能）
-	logrus.Info("开始初始化MCP资源池，请等待...")
-	mcpFactory := NewMCPFactory(config)
-	if mcpFactory != nil {
-		mcpPool, err := NewResourcePool(mcpFactory, poolConfig)
-		if err != nil {
-			return nil, fmt.Errorf("初始化MCP资源池失败: %v", err)
+// Reload 按新配置重建提供者或池配置有变化的资源池并原子替换，没有变化的资源池继续使用
+// 新会话立即使用新的资源池，进行中的会话继续使用已借出的资源，归还时销毁，旧资源池的空闲资源立即释放
+// 新资源池创建失败时保持原来的资源池不变
+func (pm *PoolManager) Reload(config *configs.Config) error {
+	pm.poolsMu.RLock()
+	old := pm.pools
+	pm.poolsMu.RUnlock()
+
+	pools, err := buildPools(config, old)
+	if err != nil {
+		return err
+	}
+
+	pm.poolsMu.Lock()
+	old = pm.pools // 期间可能新建了用户选择的资源池
+	pm.pools = pools
+	pm.config = config
+	pm.poolsMu.Unlock()
+
+	retired := 0
+	for _, pool := range old.all() {
+		if !pools.contains(pool) {
+			pool.Close()
+			retired++
 		}
-		pm.mcpPool = mcpPool
-		_, cnt := mcpPool.GetStats()
-		logrus.WithField("count", cnt).Info("MCP资源池初始化成功")
-	} else {
-		logrus.Warn("创建MCP工厂失败，MCP功能将不可用")
 	}
+	pm.applyAutoscale(config.Pool)
+	pm.applyLeakDetection(config.Pool)
+	logrus.Infof("资源池配置已重载，替换 %d 个资源池", retired)
+	return nil
+}
 
-	return pm, nil
+// Config 当前资源池使用的配置
+func (pm *PoolManager) Config() *configs.Config {
+	pm.poolsMu.RLock()
+	defer pm.poolsMu.RUnlock()
+	return pm.config
+}
+
+// current 当前使用的资源池
+func (pm *PoolManager) current() poolSet {
+	pm.poolsMu.RLock()
+	defer pm.poolsMu.RUnlock()
+	return pm.pools
+}
+
+// poolFor 用户选择的提供者所在的资源池，name 为空、与 selected_module 相同或找不到配置时使用默认资源池 fallback
+// 每种不同的提供者配置使用独立的资源池，首次选择时创建，不预先创建资源，空闲资源按 pool.idle_ttl 释放
+func (pm *PoolManager) poolFor(kind, name string, fallback *ResourcePool) *ResourcePool {
+	key := kind + ":" + name
+	pm.poolsMu.RLock()
+	config := pm.config
+	pool, ok := pm.pools.extra[key]
+	pm.poolsMu.RUnlock()
+	if name == "" || name == config.SelectedModule[kind] {
+		return fallback
+	}
+	if ok {
+		return pool
+	}
+
+	var factory ResourceFactory
+	switch kind {
+	case "ASR":
+		factory = NewASRFactory(name, config)
+	case "LLM":
+		factory = NewLLMFactory(name, config)
+	case "TTS":
+		factory = NewTTSFactory(name, config)
+	case "VLLLM":
+		factory = NewVLLLMFactory(name, config)
+	}
+	if factory == nil {
+		logrus.Warnf("用户选择的%s配置 %s 不存在，使用默认配置", kind, name)
+		return fallback
+	}
+
+	pm.poolsMu.Lock()
+	defer pm.poolsMu.Unlock()
+	if pool, ok := pm.pools.extra[key]; ok {
+		return pool
+	}
+	if pm.config != config {
+		// 创建期间配置已重载，本次使用默认资源池
+		return fallback
+	}
+	poolConfig := newPoolConfig(config.Pool)
+	poolConfig.MinSize = 0
+	pool, err := NewResourcePool(factory, poolConfig)
+	if err != nil {
+		logrus.WithError(err).Warnf("初始化%s资源池 %s 失败，使用默认配置", kind, name)
+		return fallback
+	}
+	extra := make(map[string]*ResourcePool, len(pm.pools.extra)+1)
+	for k, v := range pm.pools.extra {
+		extra[k] = v
+	}
+	extra[key] = pool
+	pm.pools.extra = extra
+	logrus.Infof("%s资源池 %s 初始化成功，供选择了该配置的用户使用", kind, name)
+	return pool
+}
+
+// applyAutoscale 更新按连接数调整资源池大小的配置，开启时启动调整协程
+func (pm *PoolManager) applyAutoscale(cfg configs.ResourcePoolConfig) {
+	pm.connMu.Lock()
+	pm.autoscaleEnabled = cfg.Autoscale
+	pm.headroom = cfg.Headroom
+	if pm.headroom <= 0 {
+		pm.headroom = defaultPoolHeadroom
+	}
+	pm.connMu.Unlock()
+	if cfg.Autoscale {
+		pm.autoscaleOnce.Do(func() { go pm.autoscale() })
+	}
+}
+
+// SetConnectionCounter 设置活跃连接数的来源，开启 pool.autoscale 时按连接数调整资源池大小
+func (pm *PoolManager) SetConnectionCounter(counter func() int) {
+	pm.connMu.Lock()
+	defer pm.connMu.Unlock()
+	pm.connections = counter
+}
+
+// autoscale 定期按活跃连接数调整每个连接占用的资源池的大小，直到 Close
+func (pm *PoolManager) autoscale() {
+	ticker := time.NewTicker(autoscaleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pm.stopChan:
+			return
+		case <-ticker.C:
+		}
+		pm.connMu.Lock()
+		enabled, counter, headroom := pm.autoscaleEnabled, pm.connections, pm.headroom
+		pm.connMu.Unlock()
+		if !enabled || counter == nil {
+			continue
+		}
+		connections := counter()
+		pools := pm.current()
+		for name, pool := range map[string]*ResourcePool{"asr": pools.asr, "llm": pools.llm, "tts": pools.tts, "vlllm": pools.vlllm, "mcp": pools.mcp} {
+			if pool == nil {
+				continue
+			}
+			before := pool.GetDetailedStats()
+			minSize, maxSize := pool.Scale(connections, headroom)
+			if minSize != before["min"] || maxSize != before["max"] {
+				logrus.Debugf("按 %d 个活跃连接调整%s资源池: min=%d max=%d", connections, name, minSize, maxSize)
+			}
+		}
+	}
 }
 
-// GetProviderSet 获取一套提供者
-func (pm *PoolManager) GetProviderSet() (*ProviderSet, error) {
+// GetProviderSet 按用户选择为 owner 获取一套提供者，selection 为空时使用 selected_module
+// owner 为借用者的客户端ID，用于泄漏检测
+func (pm *PoolManager) GetProviderSet(owner string, selection ProviderSelection) (*ProviderSet, error) {
 	set := &ProviderSet{}
+	pools := pm.current()
+	fail := func(err error) (*ProviderSet, error) {
+		// 归还已申请到的资源，避免连接失败后一直占用资源池
+		if returnErr := pm.ReturnProviderSet(set); returnErr != nil {
+			logrus.WithError(returnErr).Warn("归还部分提供者失败")
+		}
+		return nil, err
+	}
 
-	if pm.asrPool != nil {
-		asr, err := pm.asrPool.Get()
+	if pool := pm.poolFor("ASR", selection.ASR, pools.asr); pool != nil {
+		asr, err := pm.get(pool, owner)
 		if err != nil {
-			return nil, fmt.Errorf("获取ASR提供者失败: %v", err)
+			return fail(fmt.Errorf("获取ASR提供者失败: %v", err))
 		}
 		set.ASR = asr.(providers.ASRProvider)
 	}
 
-	if pm.llmPool != nil {
-		llm, err := pm.llmPool.Get()
+	if pool := pm.poolFor("LLM", selection.LLM, pools.llm); pool != nil {
+		llm, err := pm.get(pool, owner)
 		if err != nil {
-			return nil, fmt.Errorf("获取LLM提供者失败: %v", err)
+			return fail(fmt.Errorf("获取LLM提供者失败: %v", err))
 		}
 		set.LLM = llm.(providers.LLMProvider)
 	}
 
-	if pm.ttsPool != nil {
-		tts, err := pm.ttsPool.Get()
+	if pool := pm.poolFor("TTS", selection.TTS, pools.tts); pool != nil {
+		tts, err := pm.get(pool, owner)
 		if err != nil {
-			return nil, fmt.Errorf("获取TTS提供者失败: %v", err)
+			return fail(fmt.Errorf("获取TTS提供者失败: %v", err))
 		}
 		set.TTS = tts.(providers.TTSProvider)
 	}
 
-	if pm.vlllmPool != nil {
-		vlllmProvider, err := pm.vlllmPool.Get()
+	if pool := pm.poolFor("VLLLM", selection.VLLLM, pools.vlllm); pool != nil {
+		vlllmProvider, err := pm.get(pool, owner)
 		if err == nil {
 			// 直接转换，因为我们知道这是从 vlllm 工厂创建的
 			set.VLLLM = vlllmProvider.(*vlllm.Provider)
 		}
 	}
 
-	if pm.mcpPool != nil {
-		mcpManager, err := pm.mcpPool.Get()
+	if pools.mcp != nil {
+		mcpManager, err := pm.get(pools.mcp, owner)
 		if err == nil {
 			// 直接转换，因为我们知道这是从 mcp 工厂创建的
 			set.MCP = mcpManager.(*mcp.Manager)
@@ -197,22 +548,118 @@ func (pm *PoolManager) GetProviderSet() (*ProviderSet, error) {
 	return set, nil
 }
 
-// Close 关闭所有资源池
-func (pm *PoolManager) Close() {
-	if pm.asrPool != nil {
-		pm.asrPool.Close()
+// GetSpeechProviderSet 按用户选择获取不含MCP的提供者集合，用于设备唤醒后重新申请语音相关资源
+// MCP管理器与设备连接绑定，休眠期间不归还
+func (pm *PoolManager) GetSpeechProviderSet(owner string, selection ProviderSelection) (*ProviderSet, error) {
+	set := &ProviderSet{}
+	pools := pm.current()
+	fail := func(err error) (*ProviderSet, error) {
+		// 归还已申请到的资源，避免泄漏
+		if returnErr := pm.ReturnProviderSet(set); returnErr != nil {
+			logrus.WithError(returnErr).Warn("归还部分提供者失败")
+		}
+		return nil, err
+	}
+
+	if pool := pm.poolFor("ASR", selection.ASR, pools.asr); pool != nil {
+		asr, err := pm.get(pool, owner)
+		if err != nil {
+			return fail(fmt.Errorf("获取ASR提供者失败: %v", err))
+		}
+		set.ASR = asr.(providers.ASRProvider)
+	}
+
+	if pool := pm.poolFor("LLM", selection.LLM, pools.llm); pool != nil {
+		llm, err := pm.get(pool, owner)
+		if err != nil {
+			return fail(fmt.Errorf("获取LLM提供者失败: %v", err))
+		}
+		set.LLM = llm.(providers.LLMProvider)
+	}
+
+	if pool := pm.poolFor("TTS", selection.TTS, pools.tts); pool != nil {
+		tts, err := pm.get(pool, owner)
+		if err != nil {
+			return fail(fmt.Errorf("获取TTS提供者失败: %v", err))
+		}
+		set.TTS = tts.(providers.TTSProvider)
+	}
+
+	if pool := pm.poolFor("VLLLM", selection.VLLLM, pools.vlllm); pool != nil {
+		vlllmProvider, err := pm.get(pool, owner)
+		if err == nil {
+			set.VLLLM = vlllmProvider.(*vlllm.Provider)
+		}
+	}
+
+	return set, nil
+}
+
+// GetASRProvider 单独获取一个ASR提供者，用于不占用整套资源的离线识别
+func (pm *PoolManager) GetASRProvider() (providers.ASRProvider, error) {
+	pool, owner := pm.current().asr, ""
+	if pool == nil {
+		return nil, fmt.Errorf("ASR资源池未初始化")
+	}
+	asr, err := pm.get(pool, owner)
+	if err != nil {
+		return nil, fmt.Errorf("获取ASR提供者失败: %v", err)
+	}
+	return asr.(providers.ASRProvider), nil
+}
+
+// ReturnASRProvider 归还单独获取的ASR提供者
+func (pm *PoolManager) ReturnASRProvider(asr providers.ASRProvider) error {
+	return pm.ReturnProviderSet(&ProviderSet{ASR: asr})
+}
+
+// GetTTSProvider 单独获取一个TTS提供者，用于不占用整套资源的离线合成
+func (pm *PoolManager) GetTTSProvider() (providers.TTSProvider, error) {
+	pool, owner := pm.current().tts, ""
+	if pool == nil {
+		return nil, fmt.Errorf("TTS资源池未初始化")
+	}
+	tts, err := pm.get(pool, owner)
+	if err != nil {
+		return nil, fmt.Errorf("获取TTS提供者失败: %v", err)
+	}
+	return tts.(providers.TTSProvider), nil
+}
+
+// ReturnTTSProvider 归还单独获取的TTS提供者
+func (pm *PoolManager) ReturnTTSProvider(tts providers.TTSProvider) error {
+	return pm.ReturnProviderSet(&ProviderSet{TTS: tts})
+}
+
+// GetEmbeddingProvider 单独获取一个向量化提供者
+func (pm *PoolManager) GetEmbeddingProvider() (embedding.Provider, error) {
+	pool, owner := pm.current().embedding, ""
+	if pool == nil {
+		return nil, fmt.Errorf("Embedding资源池未初始化")
 	}
-	if pm.llmPool != nil {
-		pm.llmPool.Close()
+	provider, err := pm.get(pool, owner)
+	if err != nil {
+		return nil, fmt.Errorf("获取Embedding提供者失败: %v", err)
 	}
-	if pm.ttsPool != nil {
-		pm.ttsPool.Close()
+	return provider.(embedding.Provider), nil
+}
+
+// ReturnEmbeddingProvider 归还向量化提供者
+func (pm *PoolManager) ReturnEmbeddingProvider(provider embedding.Provider) error {
+	if provider == nil {
+		return nil
 	}
-	if pm.vlllmPool != nil {
-		pm.vlllmPool.Close()
+	if err := pm.put(pm.current().embedding, provider); err != nil {
+		return fmt.Errorf("归还Embedding提供者失败: %v", err)
 	}
-	if pm.mcpPool != nil {
-		pm.mcpPool.Close()
+	return nil
+}
+
+// Close 关闭所有资源池
+func (pm *PoolManager) Close() {
+	pm.closeOnce.Do(func() { close(pm.stopChan) })
+	for _, pool := range pm.current().all() {
+		pool.Close()
 	}
 }
 
@@ -223,72 +670,30 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	}
 
 	var errs []error
-
-	// 归还ASR提供者
-	if set.ASR != nil && pm.asrPool != nil {
-		// 重置资源状态
-		if err := pm.asrPool.Reset(set.ASR); err != nil {
-			logrus.WithError(err).Warn("重置ASR资源状态失败")
-		}
-		// 归还到池中
-		if err := pm.asrPool.Put(set.ASR); err != nil {
-			errs = append(errs, fmt.Errorf("归还ASR提供者失败: %v", err))
-			logrus.WithError(err).Error("归还ASR提供者失败")
+	pools := pm.current()
+	giveBack := func(name string, pool *ResourcePool, resource interface{}) {
+		if err := pm.put(pool, resource); err != nil {
+			errs = append(errs, fmt.Errorf("归还%s提供者失败: %v", name, err))
+			logrus.WithError(err).Errorf("归还%s提供者失败", name)
 		} else {
-			logrus.Debug("ASR提供者已成功归还到池中")
+			logrus.Debugf("%s提供者已成功归还到池中", name)
 		}
 	}
 
-	// 归还LLM提供者
-	if set.LLM != nil && pm.llmPool != nil {
-		if err := pm.llmPool.Reset(set.LLM); err != nil {
-			logrus.WithError(err).Warn("重置LLM资源状态失败")
-		}
-		if err := pm.llmPool.Put(set.LLM); err != nil {
-			errs = append(errs, fmt.Errorf("归还LLM提供者失败: %v", err))
-			logrus.WithError(err).Error("归还LLM提供者失败")
-		} else {
-			logrus.Debug("LLM提供者已成功归还到池中")
-		}
+	if set.ASR != nil {
+		giveBack("ASR", pools.asr, set.ASR)
 	}
-
-	// 归还TTS提供者
-	if set.TTS != nil && pm.ttsPool != nil {
-		if err := pm.ttsPool.Reset(set.TTS); err != nil {
-			logrus.WithError(err).Warn("重置TTS资源状态失败")
-		}
-		if err := pm.ttsPool.Put(set.TTS); err != nil {
-			errs = append(errs, fmt.Errorf("归还TTS提供者失败: %v", err))
-			logrus.WithError(err).Error("归还TTS提供者失败")
-		} else {
-			logrus.Debug("TTS提供者已成功归还到池中")
-		}
+	if set.LLM != nil {
+		giveBack("LLM", pools.llm, set.LLM)
 	}
-
-	// 归还VLLLM提供者
-	if set.VLLLM != nil && pm.vlllmPool != nil {
-		if err := pm.vlllmPool.Reset(set.VLLLM); err != nil {
-			logrus.WithError(err).Warn("重置VLLLM资源状态失败")
-		}
-		if err := pm.vlllmPool.Put(set.VLLLM); err != nil {
-			errs = append(errs, fmt.Errorf("归还VLLLM提供者失败: %v", err))
-			logrus.WithError(err).Error("归还VLLLM提供者失败")
-		} else {
-			logrus.Debug("VLLLM提供者已成功归还到池中")
-		}
+	if set.TTS != nil {
+		giveBack("TTS", pools.tts, set.TTS)
 	}
-
-	// 归还MCP提供者
-	if set.MCP != nil && pm.mcpPool != nil {
-		if err := pm.mcpPool.Reset(set.MCP); err != nil {
-			logrus.WithError(err).Warn("重置MCP资源状态失败")
-		}
-		if err := pm.mcpPool.Put(set.MCP); err != nil {
-			errs = append(errs, fmt.Errorf("归还MCP提供者失败: %v", err))
-			logrus.WithError(err).Error("归还MCP提供者失败")
-		} else {
-			logrus.Debug("MCP提供者已成功归还到池中")
-		}
+	if set.VLLLM != nil {
+		giveBack("VLLLM", pools.vlllm, set.VLLLM)
+	}
+	if set.MCP != nil {
+		giveBack("MCP", pools.mcp, set.MCP)
 	}
 
 	if len(errs) > 0 {
@@ -302,33 +707,25 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 // GetStats 获取所有池的统计信息
 func (pm *PoolManager) GetStats() map[string]map[string]int {
 	stats := make(map[string]map[string]int)
-
-	if pm.asrPool != nil {
-		available, total := pm.asrPool.GetStats()
-		stats["asr"] = map[string]int{"available": available, "total": total}
-	}
-
-	if pm.llmPool != nil {
-		available, total := pm.llmPool.GetStats()
-		stats["llm"] = map[string]int{"available": available, "total": total}
-	}
-
-	if pm.ttsPool != nil {
-		available, total := pm.ttsPool.GetStats()
-		stats["tts"] = map[string]int{"available": available, "total": total}
+	for name, pool := range pm.current().named() {
+		available, total := pool.GetStats()
+		stats[name] = map[string]int{"available": available, "total": total}
 	}
+	return stats
+}
 
-	if pm.vlllmPool != nil {
-		available, total := pm.vlllmPool.GetStats()
-		stats["vlllm"] = map[string]int{"available": available, "total": total}
+// named 按名称列出已初始化的资源池
+func (s poolSet) named() map[string]*ResourcePool {
+	pools := make(map[string]*ResourcePool)
+	for name, pool := range map[string]*ResourcePool{"asr": s.asr, "llm": s.llm, "tts": s.tts, "vlllm": s.vlllm, "embedding": s.embedding, "mcp": s.mcp} {
+		if pool != nil {
+			pools[name] = pool
+		}
 	}
-
-	if pm.mcpPool != nil {
-		available, total := pm.mcpPool.GetStats()
-		stats["mcp"] = map[string]int{"available": available, "total": total}
+	for key, pool := range s.extra {
+		pools[strings.ToLower(key)] = pool
 	}
-
-	return stats
+	return pools
 }
 
 // performConnectivityCheck 执行连通性检查
@@ -358,26 +755,8 @@ func (pm *PoolManager) performConnectivityCheck(config *configs.Config, logger *
 // GetDetailedStats 获取所有池的详细统计信息
 func (pm *PoolManager) GetDetailedStats() map[string]map[string]int {
 	stats := make(map[string]map[string]int)
-
-	if pm.asrPool != nil {
-		stats["asr"] = pm.asrPool.GetDetailedStats()
-	}
-
-	if pm.llmPool != nil {
-		stats["llm"] = pm.llmPool.GetDetailedStats()
-	}
-
-	if pm.ttsPool != nil {
-		stats["tts"] = pm.ttsPool.GetDetailedStats()
-	}
-
-	if pm.vlllmPool != nil {
-		stats["vlllm"] = pm.vlllmPool.GetDetailedStats()
+	for name, pool := range pm.current().named() {
+		stats[name] = pool.GetDetailedStats()
 	}
-
-	if pm.mcpPool != nil {
-		stats["mcp"] = pm.mcpPool.GetDetailedStats()
-	}
-
 	return stats
 }
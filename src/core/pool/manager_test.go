@@ -0,0 +1,55 @@
+package pool
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+)
+
+type fakeASR struct{ providers.ASRProvider }
+
+func (*fakeASR) Reset() error { return nil }
+
+type asrFactory struct{}
+
+func (asrFactory) Create() (interface{}, error) { return &fakeASR{}, nil }
+
+func (asrFactory) Destroy(resource interface{}) error { return nil }
+
+type failingFactory struct{}
+
+func (failingFactory) Create() (interface{}, error) { return nil, errors.New("创建失败") }
+
+func (failingFactory) Destroy(resource interface{}) error { return nil }
+
+func TestGetProviderSetReturnsAcquiredProvidersOnFailure(t *testing.T) {
+	asrPool, err := NewResourcePool(asrFactory{}, PoolConfig{MaxSize: 1, CheckInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer asrPool.Close()
+	llmPool, err := NewResourcePool(failingFactory{}, PoolConfig{MaxSize: 1, CheckInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer llmPool.Close()
+
+	pm := &PoolManager{
+		pools:     poolSet{asr: asrPool, llm: llmPool},
+		borrowed:  make(map[interface{}]*borrow),
+		reclaimed: make(map[interface{}]struct{}),
+	}
+	if _, err := pm.GetProviderSet("client", ProviderSelection{}); err == nil {
+		t.Fatal("GetProviderSet succeeded without an LLM")
+	}
+
+	// 已申请到的ASR提供者需要归还，不再计入借出
+	if len(pm.borrowed) != 0 {
+		t.Errorf("%d providers still borrowed after the failure", len(pm.borrowed))
+	}
+	if available, total := asrPool.GetStats(); available != total {
+		t.Errorf("ASR pool stats = %d/%d, want the provider back in the pool", available, total)
+	}
+}
@@ -0,0 +1,117 @@
+package pool
+
+import (
+	"reflect"
+	"time"
+)
+
+// poolMetrics 资源池从创建起的累计统计，用于按实际负载确定池的大小
+type poolMetrics struct {
+	acquired      int           // 成功获取资源的次数
+	acquireFailed int           // 获取失败的次数：池已满、等待超时、创建失败或池已关闭
+	waited        int           // 排队等待过的获取次数
+	acquireTotal  time.Duration // 成功获取资源的累计耗时，包括排队和创建资源
+	acquireMax    time.Duration
+	created       int
+	createFailed  int
+	destroyed     int
+	returned      int           // 归还的次数
+	inUseTotal    time.Duration // 资源从借出到归还的累计时长
+	inUseMax      time.Duration
+
+	borrowedAt map[interface{}]time.Time // 借出中的资源及借出时间
+}
+
+// create 创建资源并计数
+func (p *ResourcePool) create() (interface{}, error) {
+	resource, err := p.factory.Create()
+	p.metricsMu.Lock()
+	if err != nil {
+		p.metrics.createFailed++
+	} else {
+		p.metrics.created++
+	}
+	p.metricsMu.Unlock()
+	return resource, err
+}
+
+// destroy 销毁资源并计数
+func (p *ResourcePool) destroy(resource interface{}) error {
+	p.metricsMu.Lock()
+	p.metrics.destroyed++
+	p.metricsMu.Unlock()
+	return p.factory.Destroy(resource)
+}
+
+// recordAcquire 记录一次获取的耗时和结果
+func (p *ResourcePool) recordAcquire(elapsed time.Duration, waited bool, err error) {
+	p.metricsMu.Lock()
+	defer p.metricsMu.Unlock()
+	if waited {
+		p.metrics.waited++
+	}
+	if err != nil {
+		p.metrics.acquireFailed++
+		return
+	}
+	p.metrics.acquired++
+	p.metrics.acquireTotal += elapsed
+	if elapsed > p.metrics.acquireMax {
+		p.metrics.acquireMax = elapsed
+	}
+}
+
+// markBorrowed 记录资源的借出时间，资源类型不可比较时不统计占用时长
+func (p *ResourcePool) markBorrowed(resource interface{}) {
+	if !reflect.TypeOf(resource).Comparable() {
+		return
+	}
+	p.metricsMu.Lock()
+	p.metrics.borrowedAt[resource] = time.Now()
+	p.metricsMu.Unlock()
+}
+
+// markReturned 统计资源从借出到归还的时长
+func (p *ResourcePool) markReturned(resource interface{}) {
+	if !reflect.TypeOf(resource).Comparable() {
+		return
+	}
+	p.metricsMu.Lock()
+	defer p.metricsMu.Unlock()
+	since, ok := p.metrics.borrowedAt[resource]
+	if !ok {
+		return
+	}
+	delete(p.metrics.borrowedAt, resource)
+	held := time.Since(since)
+	p.metrics.returned++
+	p.metrics.inUseTotal += held
+	if held > p.metrics.inUseMax {
+		p.metrics.inUseMax = held
+	}
+}
+
+// addMetrics 把累计统计加入 stats，耗时单位为毫秒
+func (p *ResourcePool) addMetrics(stats map[string]int) {
+	p.metricsMu.Lock()
+	defer p.metricsMu.Unlock()
+	m := &p.metrics
+	stats["acquired"] = m.acquired
+	stats["acquire_failed"] = m.acquireFailed
+	stats["waited"] = m.waited
+	stats["acquire_avg_ms"] = averageMs(m.acquireTotal, m.acquired)
+	stats["acquire_max_ms"] = int(m.acquireMax.Milliseconds())
+	stats["created"] = m.created
+	stats["create_failed"] = m.createFailed
+	stats["destroyed"] = m.destroyed
+	stats["returned"] = m.returned
+	stats["in_use_avg_ms"] = averageMs(m.inUseTotal, m.returned)
+	stats["in_use_max_ms"] = int(m.inUseMax.Milliseconds())
+}
+
+func averageMs(total time.Duration, count int) int {
+	if count == 0 {
+		return 0
+	}
+	return int(total.Milliseconds() / int64(count))
+}
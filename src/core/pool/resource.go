@@ -2,37 +2,65 @@ package pool
 
 import (
 	"fmt"
+	"math"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
-// ResourceFactory 资源工厂接口
+// ResourceFactory 资源工厂接口，需要探活的资源的工厂同时实现 ResourceHealthChecker
 type ResourceFactory interface {
 	Create() (interface{}, error)
 	Destroy(resource interface{}) error
 }
 
+// ResourceHealthChecker 可选的资源健康检查，维护协程定期检查空闲资源，返回错误的资源被销毁并重新创建
+// 检查需要自行控制超时，不能长时间阻塞
+type ResourceHealthChecker interface {
+	HealthCheck(resource interface{}) error
+}
+
 // PoolConfig 资源池配置
 type PoolConfig struct {
 	MinSize       int           // 最小池大小
 	MaxSize       int           // 最大池大小
 	RefillSize    int           // 重新填充大小
 	CheckInterval time.Duration // 检查间隔
+
+	// 按连接数调整大小时总数的上限，0表示不超过 MaxSize
+	Limit int
+	// 超出 MinSize 的空闲资源闲置超过该时长后释放，0表示不释放
+	IdleTTL time.Duration
+	// 资源耗尽时 Get 排队等待归还的最长时间，先到先得，0表示立即失败
+	WaitTimeout time.Duration
+}
+
+// idleResource 池中的空闲资源及其归还时间
+type idleResource struct {
+	resource interface{}
+	since    time.Time
 }
 
 // ResourcePool 资源池
 type ResourcePool struct {
 	factory    ResourceFactory
 	config     PoolConfig
-	resources  chan interface{}
+	base       PoolConfig // 创建时的配置，按连接数调整大小时不低于该配置
+	resources  chan idleResource
 	totalCount int
 	inUseCount int
+	evicted    int // 健康检查失败被销毁的资源数
 	mu         sync.RWMutex
 	closeOnce  sync.Once
 	closed     bool
 	stopChan   chan struct{}
+	inject     func() error // 故障注入，未开启时为nil
+
+	waiters []chan interface{} // 排队等待资源的 Get，按到达顺序
+
+	metricsMu sync.Mutex
+	metrics   poolMetrics
 }
 
 // NewResourcePool 创建新的资源池
@@ -44,22 +72,28 @@ func NewResourcePool(factory ResourceFactory, config PoolConfig) (*ResourcePool,
 		return nil, fmt.Errorf("无效的池配置: MinSize=%d, MaxSize=%d", config.MinSize, config.MaxSize)
 	}
 
+	capacity := config.MaxSize
+	if config.Limit > capacity {
+		capacity = config.Limit
+	}
 	pool := &ResourcePool{
 		factory:   factory,
 		config:    config,
-		resources: make(chan interface{}, config.MaxSize),
+		base:      config,
+		resources: make(chan idleResource, capacity),
 		stopChan:  make(chan struct{}),
+		metrics:   poolMetrics{borrowedAt: make(map[interface{}]time.Time)},
 	}
 
 	// 初始化最小数量的资源
 	for i := 0; i < config.MinSize; i++ {
-		resource, err := factory.Create()
+		resource, err := pool.create()
 		if err != nil {
 			// 清理已创建的资源
 			pool.Close()
 			return nil, fmt.Errorf("创建初始资源失败: %v", err)
 		}
-		pool.resources <- resource
+		pool.resources <- idleResource{resource, time.Now()}
 		pool.totalCount++
 	}
 
@@ -69,38 +103,113 @@ func NewResourcePool(factory ResourceFactory, config PoolConfig) (*ResourcePool,
 	return pool, nil
 }
 
+// SetFaultInjector 设置故障注入函数，获取资源前调用，返回错误时获取失败
+func (p *ResourcePool) SetFaultInjector(inject func() error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inject = inject
+}
+
 // Get 获取资源
 func (p *ResourcePool) Get() (interface{}, error) {
+	start := time.Now()
+	resource, waited, err := p.acquire()
+	p.recordAcquire(time.Since(start), waited, err)
+	return resource, err
+}
+
+// acquire 获取资源，waited 表示是否排队等待过
+func (p *ResourcePool) acquire() (resource interface{}, waited bool, err error) {
 	p.mu.RLock()
 	if p.closed {
 		p.mu.RUnlock()
-		return nil, fmt.Errorf("资源池已关闭")
+		return nil, false, fmt.Errorf("资源池已关闭")
 	}
+	inject := p.inject
 	p.mu.RUnlock()
 
-	select {
-	case resource := <-p.resources:
-		p.mu.Lock()
-		p.inUseCount++
-		p.mu.Unlock()
-		return resource, nil
-	default:
+	if inject != nil {
+		if err := inject(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	p.mu.Lock()
+	// 已有排队的请求时不插队
+	if len(p.waiters) == 0 {
+		select {
+		case idle := <-p.resources:
+			p.inUseCount++
+			p.markBorrowed(idle.resource)
+			p.mu.Unlock()
+			return idle.resource, false, nil
+		default:
+		}
 		// 没有可用资源，尝试创建新的
-		p.mu.Lock()
 		if p.totalCount < p.config.MaxSize {
-			resource, err := p.factory.Create()
+			resource, err := p.create()
 			if err != nil {
 				p.mu.Unlock()
-				return nil, fmt.Errorf("创建新资源失败: %v", err)
+				return nil, false, fmt.Errorf("创建新资源失败: %v", err)
 			}
 			p.totalCount++
 			p.inUseCount++
+			p.markBorrowed(resource)
 			p.mu.Unlock()
-			return resource, nil
+			return resource, false, nil
 		}
+	}
+	timeout := p.config.WaitTimeout
+	if timeout <= 0 {
 		p.mu.Unlock()
-		return nil, fmt.Errorf("资源池已满，无法获取资源")
+		return nil, false, fmt.Errorf("资源池已满，无法获取资源")
 	}
+	wait := make(chan interface{}, 1)
+	p.waiters = append(p.waiters, wait)
+	p.mu.Unlock()
+	resource, err = p.await(wait, timeout)
+	return resource, true, err
+}
+
+// await 等待归还的资源交给 wait，超时后退出队列
+func (p *ResourcePool) await(wait chan interface{}, timeout time.Duration) (interface{}, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case resource, ok := <-wait:
+		if !ok {
+			return nil, fmt.Errorf("资源池已关闭")
+		}
+		return resource, nil
+	case <-timer.C:
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, w := range p.waiters {
+		if w == wait {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return nil, fmt.Errorf("等待资源超时(%v)，资源池已满", timeout)
+		}
+	}
+	// 超时的同时已经分配到资源
+	if resource, ok := <-wait; ok {
+		return resource, nil
+	}
+	return nil, fmt.Errorf("资源池已关闭")
+}
+
+// handOff 把空闲资源交给最早排队的 Get，没有排队时返回false，调用方需持有写锁
+func (p *ResourcePool) handOff(resource interface{}) bool {
+	if len(p.waiters) == 0 {
+		return false
+	}
+	wait := p.waiters[0]
+	p.waiters = p.waiters[1:]
+	wait <- resource
+	p.inUseCount++
+	p.markBorrowed(resource)
+	return true
 }
 
 // Put 归还资源
@@ -108,25 +217,50 @@ func (p *ResourcePool) Put(resource interface{}) error {
 	if resource == nil {
 		return fmt.Errorf("资源不能为空")
 	}
+	p.markReturned(resource)
 
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if p.closed {
 		// 池已关闭，销毁资源
-		return p.factory.Destroy(resource)
+		return p.destroy(resource)
 	}
 
 	p.inUseCount--
 
+	if p.handOff(resource) {
+		return nil
+	}
+
+	// 按连接数缩小后总数超出上限时销毁多余资源
+	if p.totalCount > p.config.MaxSize {
+		p.totalCount--
+		return p.destroy(resource)
+	}
+
 	select {
-	case p.resources <- resource:
+	case p.resources <- idleResource{resource, time.Now()}:
 		return nil
 	default:
 		// 池已满，销毁多余资源
 		p.totalCount--
-		return p.factory.Destroy(resource)
+		return p.destroy(resource)
+	}
+}
+
+// Reclaim 强制回收借出后没有归还的资源：销毁资源并释放占用的名额，有排队的请求时立即补充
+func (p *ResourcePool) Reclaim(resource interface{}) error {
+	p.markReturned(resource)
+	p.mu.Lock()
+	if !p.closed {
+		p.inUseCount--
+		p.totalCount--
 	}
+	p.mu.Unlock()
+	err := p.destroy(resource)
+	p.refillPool()
+	return err
 }
 
 // Reset 重置资源状态
@@ -145,16 +279,21 @@ func (p *ResourcePool) GetStats() (available int, total int) {
 }
 
 // GetDetailedStats 获取详细统计信息
+// 获取耗时和占用时长的单位为毫秒，计数从资源池创建起累计
 func (p *ResourcePool) GetDetailedStats() map[string]int {
 	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return map[string]int{
+	stats := map[string]int{
 		"available": len(p.resources),
 		"total":     p.totalCount,
 		"in_use":    p.inUseCount,
 		"max":       p.config.MaxSize,
 		"min":       p.config.MinSize,
+		"evicted":   p.evicted,
+		"waiting":   len(p.waiters),
 	}
+	p.mu.RUnlock()
+	p.addMetrics(stats)
+	return stats
 }
 
 // Close 关闭资源池
@@ -163,12 +302,16 @@ func (p *ResourcePool) Close() {
 		p.mu.Lock()
 		p.closed = true
 		close(p.stopChan)
+		for _, wait := range p.waiters {
+			close(wait)
+		}
+		p.waiters = nil
 		p.mu.Unlock()
 
 		// 清理所有资源
 		close(p.resources)
-		for resource := range p.resources {
-			if err := p.factory.Destroy(resource); err != nil {
+		for idle := range p.resources {
+			if err := p.destroy(idle.resource); err != nil {
 				logrus.WithError(err).Error("销毁资源失败")
 			}
 		}
@@ -185,11 +328,154 @@ func (p *ResourcePool) maintain() {
 		case <-p.stopChan:
 			return
 		case <-ticker.C:
+			p.checkIdleResources()
+			p.shrinkIdle()
 			p.refillPool()
 		}
 	}
 }
 
+// checkIdleResources 检查当前空闲的资源，销毁不健康的资源并创建同样数量的新资源
+// 检查期间资源暂时移出池，不会被分配给新连接
+func (p *ResourcePool) checkIdleResources() {
+	checker, ok := p.factory.(ResourceHealthChecker)
+	if !ok {
+		return
+	}
+
+	evicted := 0
+	for n := len(p.resources); n > 0; n-- {
+		var idle idleResource
+		select {
+		case idle, ok = <-p.resources:
+		default:
+			ok = false // 空闲资源已被取走
+		}
+		if !ok {
+			break
+		}
+
+		if err := checker.HealthCheck(idle.resource); err != nil {
+			logrus.WithError(err).Warn("资源健康检查失败，销毁并重新创建")
+			if err := p.destroy(idle.resource); err != nil {
+				logrus.WithError(err).Error("销毁资源失败")
+			}
+			p.mu.Lock()
+			p.totalCount--
+			p.evicted++
+			p.mu.Unlock()
+			evicted++
+			continue
+		}
+		if !p.putIdle(idle) {
+			return
+		}
+	}
+
+	for ; evicted > 0; evicted-- {
+		resource, err := p.create()
+		if err != nil {
+			logrus.WithError(err).Error("重新创建资源失败")
+			return
+		}
+		p.mu.Lock()
+		full := p.closed || p.totalCount >= p.config.MaxSize
+		if !full {
+			p.totalCount++
+		}
+		p.mu.Unlock()
+		if full {
+			p.destroy(resource)
+			return
+		}
+		if !p.putIdle(idleResource{resource, time.Now()}) {
+			return
+		}
+	}
+}
+
+// putIdle 把空闲资源放回池中，池已关闭或已满时销毁资源并返回false
+func (p *ResourcePool) putIdle(idle idleResource) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		if p.handOff(idle.resource) {
+			return true
+		}
+		select {
+		case p.resources <- idle:
+			return true
+		default:
+		}
+	}
+	p.totalCount--
+	p.destroy(idle.resource)
+	return false
+}
+
+// shrinkIdle 释放闲置超过 IdleTTL 的多余空闲资源，至少保留 MinSize 个空闲资源
+func (p *ResourcePool) shrinkIdle() {
+	p.mu.RLock()
+	ttl, minSize := p.config.IdleTTL, p.config.MinSize
+	p.mu.RUnlock()
+	if ttl <= 0 {
+		return
+	}
+
+	released := 0
+	for n := len(p.resources) - minSize; n > 0; n-- {
+		var idle idleResource
+		ok := false
+		select {
+		case idle, ok = <-p.resources:
+		default:
+		}
+		if !ok {
+			break
+		}
+		// 通道先进先出，最早归还的资源没有过期时其余资源也没有过期
+		if time.Since(idle.since) < ttl {
+			p.putIdle(idle)
+			break
+		}
+		if err := p.destroy(idle.resource); err != nil {
+			logrus.WithError(err).Error("销毁资源失败")
+		}
+		p.mu.Lock()
+		p.totalCount--
+		p.mu.Unlock()
+		released++
+	}
+	if released > 0 {
+		logrus.Debugf("释放 %d 个闲置资源", released)
+	}
+}
+
+// Scale 按活跃连接数调整池的大小：空闲资源至少为连接数的 headroom 比例，总数上限为连接数加两倍的空闲数，
+// 都不低于创建时的配置，总数上限不超过 Limit
+func (p *ResourcePool) Scale(connections int, headroom float64) (minSize int, maxSize int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	minSize = int(math.Ceil(float64(connections) * headroom))
+	if minSize < p.base.MinSize {
+		minSize = p.base.MinSize
+	}
+	maxSize = connections + 2*minSize
+	if maxSize < p.base.MaxSize {
+		maxSize = p.base.MaxSize
+	}
+	if limit := cap(p.resources); maxSize > limit {
+		maxSize = limit
+	}
+	if minSize > maxSize {
+		minSize = maxSize
+	}
+	p.config.MinSize = minSize
+	p.config.MaxSize = maxSize
+	return minSize, maxSize
+}
+
 // refillPool 重新填充资源池
 func (p *ResourcePool) refillPool() {
 	p.mu.Lock()
@@ -200,25 +486,32 @@ func (p *ResourcePool) refillPool() {
 	}
 
 	currentAvailable := len(p.resources)
-	if currentAvailable < p.config.MinSize {
+	if currentAvailable < p.config.MinSize || len(p.waiters) > 0 {
 		needed := p.config.MinSize - currentAvailable
+		if needed < len(p.waiters) {
+			needed = len(p.waiters)
+		}
 		if needed > p.config.RefillSize {
 			needed = p.config.RefillSize
 		}
 
 		for i := 0; i < needed && p.totalCount < p.config.MaxSize; i++ {
-			resource, err := p.factory.Create()
+			resource, err := p.create()
 			if err != nil {
 				logrus.WithError(err).Error("重新填充资源失败")
 				continue
 			}
+			if p.handOff(resource) {
+				p.totalCount++
+				continue
+			}
 
 			select {
-			case p.resources <- resource:
+			case p.resources <- idleResource{resource, time.Now()}:
 				p.totalCount++
 			default:
 				// 池已满，销毁资源
-				p.factory.Destroy(resource)
+				p.destroy(resource)
 			}
 		}
 	}
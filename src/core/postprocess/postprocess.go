@@ -0,0 +1,117 @@
+package postprocess
+
+import (
+	"fmt"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+// Processor 流式文本后处理器，每个回复流使用独立实例，可在内部缓存跨分块的文本
+type Processor interface {
+	// Process 处理一个分块，返回可以立即输出的文本
+	Process(chunk string) string
+	// Flush 回复结束时输出缓存的剩余文本
+	Flush() string
+}
+
+// Factory 后处理器工厂函数类型
+type Factory func(params map[string]interface{}) (Processor, error)
+
+var factories = make(map[string]Factory)
+
+// Register 注册后处理器工厂
+func Register(name string, factory Factory) {
+	factories[name] = factory
+}
+
+// defaultProcessors 未配置后处理链时使用的默认处理器
+var defaultProcessors = []configs.PostProcessorConfig{{Name: "think"}}
+
+// Chain 后处理链配置，按顺序为每个回复流创建处理器
+type Chain struct {
+	configs []configs.PostProcessorConfig
+}
+
+// NewChain 根据配置创建后处理链，未配置时默认只移除思考标签
+func NewChain(cfgs []configs.PostProcessorConfig) (*Chain, error) {
+	if len(cfgs) == 0 {
+		cfgs = defaultProcessors
+	}
+	chain := &Chain{configs: cfgs}
+	// 提前创建一次，校验配置
+	if _, err := chain.NewPipeline(); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// NewPipeline 为一个回复流创建后处理管道
+func (c *Chain) NewPipeline() (*Pipeline, error) {
+	processors := make([]Processor, 0, len(c.configs))
+	for _, cfg := range c.configs {
+		factory, ok := factories[cfg.Name]
+		if !ok {
+			return nil, fmt.Errorf("未知的后处理器: %s", cfg.Name)
+		}
+		processor, err := factory(cfg.Params)
+		if err != nil {
+			return nil, fmt.Errorf("创建后处理器 %s 失败: %v", cfg.Name, err)
+		}
+		processors = append(processors, processor)
+	}
+	return &Pipeline{processors: processors}, nil
+}
+
+// Wrap 对文本流应用后处理，返回处理后的文本流
+func (c *Chain) Wrap(in <-chan string) <-chan string {
+	out := make(chan string, 10)
+	go func() {
+		defer close(out)
+
+		pipeline, err := c.NewPipeline()
+		if err != nil {
+			// NewChain 已校验过配置，这里不会失败，兜底直接透传
+			for chunk := range in {
+				out <- chunk
+			}
+			return
+		}
+		for chunk := range in {
+			if processed := pipeline.Process(chunk); processed != "" {
+				out <- processed
+			}
+		}
+		if rest := pipeline.Flush(); rest != "" {
+			out <- rest
+		}
+	}()
+	return out
+}
+
+// Pipeline 单个回复流的后处理管道
+type Pipeline struct {
+	processors []Processor
+}
+
+// Process 依次经过所有处理器处理一个分块
+func (p *Pipeline) Process(chunk string) string {
+	for _, processor := range p.processors {
+		if chunk == "" {
+			break
+		}
+		chunk = processor.Process(chunk)
+	}
+	return chunk
+}
+
+// Flush 依次输出各处理器缓存的文本，前一个处理器的剩余文本仍需经过后续处理器
+func (p *Pipeline) Flush() string {
+	rest := ""
+	for _, processor := range p.processors {
+		if rest != "" {
+			rest = processor.Process(rest)
+		}
+		rest += processor.Flush()
+	}
+	return rest
+}
@@ -0,0 +1,175 @@
+package postprocess
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+func init() {
+	Register("think", newThinkProcessor)
+	Register("markdown", newMarkdownProcessor)
+	Register("emoji", newEmojiProcessor)
+	Register("banned_phrases", newBannedPhrasesProcessor)
+}
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// thinkProcessor 移除 <think>...</think> 思考内容，标签可以跨分块
+type thinkProcessor struct {
+	buffer  string
+	inThink bool
+}
+
+func newThinkProcessor(params map[string]interface{}) (Processor, error) {
+	return &thinkProcessor{}, nil
+}
+
+func (p *thinkProcessor) Process(chunk string) string {
+	p.buffer += chunk
+	var out strings.Builder
+	for {
+		if !p.inThink {
+			if idx := strings.Index(p.buffer, thinkOpenTag); idx >= 0 {
+				out.WriteString(p.buffer[:idx])
+				p.buffer = p.buffer[idx+len(thinkOpenTag):]
+				p.inThink = true
+				continue
+			}
+			// 保留可能是标签开头的尾部
+			keep := partialSuffixLen(p.buffer, thinkOpenTag)
+			out.WriteString(p.buffer[:len(p.buffer)-keep])
+			p.buffer = p.buffer[len(p.buffer)-keep:]
+			return out.String()
+		}
+
+		if idx := strings.Index(p.buffer, thinkCloseTag); idx >= 0 {
+			p.buffer = p.buffer[idx+len(thinkCloseTag):]
+			p.inThink = false
+			continue
+		}
+		keep := partialSuffixLen(p.buffer, thinkCloseTag)
+		p.buffer = p.buffer[len(p.buffer)-keep:]
+		return out.String()
+	}
+}
+
+func (p *thinkProcessor) Flush() string {
+	rest := p.buffer
+	p.buffer = ""
+	if p.inThink {
+		return ""
+	}
+	return rest
+}
+
+// partialSuffixLen 返回 text 尾部与 tag 开头相同的最长长度（不含完整的 tag）
+func partialSuffixLen(text, tag string) int {
+	for n := len(tag) - 1; n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// markdownProcessor 移除Markdown符号，保留语音韵律标记
+type markdownProcessor struct{}
+
+func newMarkdownProcessor(params map[string]interface{}) (Processor, error) {
+	return &markdownProcessor{}, nil
+}
+
+func (p *markdownProcessor) Process(chunk string) string {
+	return tts.MapProsodyText(chunk, utils.RemoveMarkdownSyntax)
+}
+
+func (p *markdownProcessor) Flush() string {
+	return ""
+}
+
+// emojiProcessor 表情策略，policy 为 remove（默认）时移除表情，为 keep 时保留
+type emojiProcessor struct {
+	remove bool
+}
+
+func newEmojiProcessor(params map[string]interface{}) (Processor, error) {
+	policy, _ := params["policy"].(string)
+	switch policy {
+	case "", "remove":
+		return &emojiProcessor{remove: true}, nil
+	case "keep":
+		return &emojiProcessor{remove: false}, nil
+	default:
+		return nil, fmt.Errorf("未知的表情策略: %s", policy)
+	}
+}
+
+func (p *emojiProcessor) Process(chunk string) string {
+	if !p.remove {
+		return chunk
+	}
+	return utils.RemoveAllEmoji(chunk)
+}
+
+func (p *emojiProcessor) Flush() string {
+	return ""
+}
+
+// bannedPhrasesProcessor 将禁用语改写为指定文本，短语可以跨分块
+type bannedPhrasesProcessor struct {
+	replacer *strings.Replacer
+	maxLen   int
+	buffer   string
+}
+
+func newBannedPhrasesProcessor(params map[string]interface{}) (Processor, error) {
+	replacements, _ := params["replacements"].(map[string]interface{})
+	if len(replacements) == 0 {
+		return nil, fmt.Errorf("banned_phrases 缺少 replacements 参数")
+	}
+
+	pairs := make([]string, 0, len(replacements)*2)
+	maxLen := 0
+	for phrase, replacement := range replacements {
+		if phrase == "" {
+			continue
+		}
+		pairs = append(pairs, phrase, fmt.Sprint(replacement))
+		if len(phrase) > maxLen {
+			maxLen = len(phrase)
+		}
+	}
+	return &bannedPhrasesProcessor{
+		replacer: strings.NewReplacer(pairs...),
+		maxLen:   maxLen,
+	}, nil
+}
+
+func (p *bannedPhrasesProcessor) Process(chunk string) string {
+	p.buffer = p.replacer.Replace(p.buffer + chunk)
+
+	// 保留末尾可能是禁用语前半部分的文本，等待下一个分块
+	cut := len(p.buffer) - (p.maxLen - 1)
+	if cut <= 0 {
+		return ""
+	}
+	for cut > 0 && !utf8.RuneStart(p.buffer[cut]) {
+		cut--
+	}
+	out := p.buffer[:cut]
+	p.buffer = p.buffer[cut:]
+	return out
+}
+
+func (p *bannedPhrasesProcessor) Flush() string {
+	rest := p.replacer.Replace(p.buffer)
+	p.buffer = ""
+	return rest
+}
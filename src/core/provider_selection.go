@@ -0,0 +1,56 @@
+package core
+
+import (
+	"errors"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/pool"
+	"xiaozhi-server-go/src/models"
+	"xiaozhi-server-go/src/service"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// userProviderSelection 设备通过 PUT /api/devices/<设备ID>/user 绑定了用户时返回用户在 user_settings 中选择的ASR、LLM、TTS、VLLLM，
+// 没有数据库、设备未绑定用户或查询失败时返回空选择，使用 selected_module
+func userProviderSelection(deviceID string) pool.ProviderSelection {
+	var selection pool.ProviderSelection
+	if deviceID == "" || database.DB == nil {
+		return selection
+	}
+
+	userID, err := service.DeviceOwner(deviceID)
+	if err != nil {
+		if !errors.Is(err, service.ErrDeviceNotFound) {
+			logrus.Warnf("查询设备 %s 所属用户失败，使用默认提供者: %v", deviceID, err)
+		}
+		return selection
+	}
+	if userID == 0 {
+		return selection
+	}
+
+	var setting models.UserSetting
+	err = database.DB.Where("user_id = ?", userID).Take(&setting).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logrus.Warnf("查询用户 %d 的设置失败，使用默认提供者: %v", userID, err)
+		}
+		return selection
+	}
+	return pool.ProviderSelection{
+		ASR:   setting.SelectedASR,
+		LLM:   setting.SelectedLLM,
+		TTS:   setting.SelectedTTS,
+		VLLLM: setting.SelectedVLLLM,
+	}
+}
+
+// selectedLLMName 本连接使用的LLM配置名称
+func (h *ConnectionHandler) selectedLLMName() string {
+	if h.providerSelection.LLM != "" {
+		return h.providerSelection.LLM
+	}
+	return h.config.SelectedModule["LLM"]
+}
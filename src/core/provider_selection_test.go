@@ -0,0 +1,34 @@
+package core
+
+import (
+	"testing"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/pool"
+	"xiaozhi-server-go/src/models"
+	"xiaozhi-server-go/src/service"
+)
+
+func TestUserProviderSelection(t *testing.T) {
+	alice, _ := setupTransferDB(t)
+	setting := &models.UserSetting{UserID: alice.ID, SelectedLLM: "DeepSeekLLM", SelectedTTS: "EdgeTTS"}
+	if err := database.DB.Create(setting).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 未绑定时使用 selected_module
+	if got := userProviderSelection("aa:aa"); got != (pool.ProviderSelection{}) {
+		t.Errorf("unbound selection = %+v", got)
+	}
+	if err := service.NewDevice(&configs.Config{}).BindUser("aa:aa", alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	want := pool.ProviderSelection{LLM: "DeepSeekLLM", TTS: "EdgeTTS"}
+	if got := userProviderSelection("aa:aa"); got != want {
+		t.Errorf("bound selection = %+v, want %+v", got, want)
+	}
+	if got := userProviderSelection("ff:ff"); got != (pool.ProviderSelection{}) {
+		t.Errorf("unknown device selection = %+v", got)
+	}
+}
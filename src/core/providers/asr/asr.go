@@ -36,6 +36,9 @@ type BaseProvider struct {
 	SilenceCount    int       // 连续静音计数
 
 	listener providers.AsrEventListener
+
+	confidence    float64 // 最近一次识别结果的置信度
+	hasConfidence bool
 }
 
 func (p *BaseProvider) ResetStartListenTime() {
@@ -53,6 +56,23 @@ func (p *BaseProvider) GetSilenceCount() int {
 	return p.SilenceCount
 }
 
+// SetConfidence 记录最近一次识别结果的置信度，在回调 OnAsrResult 之前调用
+func (p *BaseProvider) SetConfidence(confidence float64) {
+	p.confidence = confidence
+	p.hasConfidence = true
+}
+
+// ClearConfidence 最近一次识别结果没有置信度
+func (p *BaseProvider) ClearConfidence() {
+	p.confidence = 0
+	p.hasConfidence = false
+}
+
+// LastConfidence 实现 providers.AsrConfidenceReporter 接口
+func (p *BaseProvider) LastConfidence() (float64, bool) {
+	return p.confidence, p.hasConfidence
+}
+
 // SetListener 设置事件监听器
 func (p *BaseProvider) SetListener(listener providers.AsrEventListener) {
 	p.listener = listener
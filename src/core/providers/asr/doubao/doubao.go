@@ -15,6 +15,9 @@ import (
 	"time"
 
 	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/types"
+
+	"xiaozhi-server-go/src/core/providers/transport"
 	"xiaozhi-server-go/src/core/utils"
 
 	"github.com/gorilla/websocket"
@@ -72,6 +75,7 @@ type Provider struct {
 	// 流式识别相关字段
 	conn        *websocket.Conn
 	isStreaming bool
+	finished    bool // 已发送最后一包音频，等待最终结果
 	reqID       string
 	result      string
 	err         error
@@ -342,7 +346,7 @@ func (p *Provider) AddAudioWithContext(ctx context.Context, data []byte) error {
 	}
 
 	// 检查是否有实际数据需要发送
-	if len(data) > 0 && p.isStreaming {
+	if len(data) > 0 && p.isStreaming && !p.finished {
 		// 直接发送音频数据
 		if err := p.sendAudioData(data, false); err != nil {
 			return err
@@ -373,6 +377,7 @@ func (p *Provider) StartStreaming(ctx context.Context) error {
 	p.InitAudioProcessing()
 	p.result = ""
 	p.err = nil
+	p.finished = false
 
 	// 确保旧连接已关闭
 	if p.conn != nil {
@@ -380,9 +385,11 @@ func (p *Provider) StartStreaming(ctx context.Context) error {
 	}
 
 	// 建立WebSocket连接
-	dialer := websocket.Dialer{
-		HandshakeTimeout: 10 * time.Second, // 设置握手超时
+	dialer, err := transport.NewDialer(p.Config().Data)
+	if err != nil {
+		return err
 	}
+	dialer.HandshakeTimeout = 10 * time.Second // 设置握手超时
 	headers := map[string][]string{
 		"X-Api-App-Key":     {p.appID},
 		"X-Api-Access-Key":  {p.accessToken},
@@ -393,7 +400,6 @@ func (p *Provider) StartStreaming(ctx context.Context) error {
 	// 重试机制
 	var conn *websocket.Conn
 	var resp *http.Response
-	var err error
 	maxRetries := 2
 
 	for i := 0; i <= maxRetries; i++ {
@@ -415,11 +421,7 @@ func (p *Provider) StartStreaming(ctx context.Context) error {
 	}
 
 	if err != nil {
-		statusCode := 0
-		if resp != nil {
-			statusCode = resp.StatusCode
-		}
-		return fmt.Errorf("WebSocket连接失败(状态码:%d): %v", statusCode, err)
+		return types.NewDialError("doubao", resp, err)
 	}
 
 	p.conn = conn
@@ -450,13 +452,13 @@ func (p *Provider) StartStreaming(ctx context.Context) error {
 
 	// 发送请求
 	if err := p.conn.WriteMessage(websocket.BinaryMessage, fullRequest); err != nil {
-		return fmt.Errorf("发送请求失败: %v", err)
+		return types.NewProviderError("doubao", fmt.Errorf("发送请求失败: %w", err))
 	}
 
 	// 读取响应
 	_, response, err := p.conn.ReadMessage()
 	if err != nil {
-		return fmt.Errorf("读取响应失败: %v", err)
+		return types.NewProviderError("doubao", fmt.Errorf("读取响应失败: %w", err))
 	} else {
 		logrus.WithField("length", len(response)).Debug("[DEBUG] 流式识别: 收到WebSocket消息")
 	}
@@ -470,7 +472,7 @@ func (p *Provider) StartStreaming(ctx context.Context) error {
 	if msg, ok := initialResult["payload_msg"].(map[string]interface{}); ok {
 		// Doubao ASR v3 uses 20000000 for success code in initial response
 		if code, ok := msg["code"].(float64); ok && int(code) != 20000000 {
-			return fmt.Errorf("ASR初始化错误: %v", msg)
+			return serverError(int64(code), fmt.Errorf("ASR初始化错误: %v", msg))
 		}
 	}
 
@@ -516,7 +518,7 @@ func (p *Provider) ReadMessage() {
 
 		_, response, err := conn.ReadMessage()
 		if err != nil {
-			p.setErrorAndStop(err)
+			p.setErrorAndStop(types.NewProviderError("doubao", err))
 			return
 		}
 
@@ -530,7 +532,7 @@ func (p *Provider) ReadMessage() {
 			logrus.WithField("result", result).Info("检测到code字段: 解析结果")
 			codeValue := code.(uint32)
 			if codeValue != 0 {
-				p.setErrorAndStop(fmt.Errorf("ASR服务端错误: Code=%d", codeValue))
+				p.setErrorAndStop(serverError(int64(codeValue), fmt.Errorf("ASR服务端错误: Code=%d", codeValue)))
 				return
 			}
 		}
@@ -551,6 +553,12 @@ func (p *Provider) ReadMessage() {
 				p.result = text
 				p.connMutex.Unlock()
 
+				if confidence, ok := parseConfidence(resultData); ok {
+					p.SetConfidence(confidence)
+				} else {
+					p.ClearConfidence()
+				}
+
 				if listener := p.BaseProvider.GetListener(); listener != nil {
 					if text == "" && p.SilenceTime() > idleTimeout {
 						p.BaseProvider.SilenceCount += 1
@@ -571,6 +579,39 @@ func (p *Provider) ReadMessage() {
 
 	}
 }
+
+// serverError 按豆包ASR的错误码分类：45000081 等待超时，55开头为服务端内部错误
+func serverError(code int64, err error) *types.ProviderError {
+	kind := types.ErrorKindUnknown
+	switch {
+	case code == 45000081:
+		kind = types.ErrorKindTimeout
+	case code/1000000 == 55:
+		kind = types.ErrorKindServer
+	}
+	return &types.ProviderError{Kind: kind, Provider: "doubao", Err: err}
+}
+
+// parseConfidence 读取识别结果的置信度，优先使用整体置信度，否则取各分句置信度的平均值
+func parseConfidence(resultData map[string]interface{}) (float64, bool) {
+	if confidence, ok := resultData["confidence"].(float64); ok {
+		return confidence, true
+	}
+	utterances, _ := resultData["utterances"].([]interface{})
+	sum, count := 0.0, 0
+	for _, item := range utterances {
+		utterance, _ := item.(map[string]interface{})
+		if confidence, ok := utterance["confidence"].(float64); ok {
+			sum += confidence
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
 func (p *Provider) setErrorAndStop(err error) {
 	p.connMutex.Lock()
 	defer p.connMutex.Unlock()
@@ -609,6 +650,18 @@ func (p *Provider) closeConnection() {
 	}
 }
 
+// Finish 实现 providers.AsrFinisher 接口，发送最后一包音频结束当前流
+// 最终结果返回前收到的音频被丢弃，流结束后再有音频时开始新的识别
+func (p *Provider) Finish() error {
+	p.connMutex.Lock()
+	defer p.connMutex.Unlock()
+	if !p.isStreaming || p.finished {
+		return nil
+	}
+	p.finished = true
+	return p.sendAudioData(nil, true)
+}
+
 // sendAudioData 直接发送音频数据，替代之前的sendCurrentBuffer
 func (p *Provider) sendAudioData(data []byte, isLast bool) error {
 	logrus.WithFields(logrus.Fields{
@@ -653,7 +706,7 @@ func (p *Provider) sendAudioData(data []byte, isLast bool) error {
 	audioMessage = append(audioMessage, compressedAudio...)
 
 	if err := p.conn.WriteMessage(websocket.BinaryMessage, audioMessage); err != nil {
-		return fmt.Errorf("发送音频数据失败: %v", err)
+		return types.NewProviderError("doubao", fmt.Errorf("发送音频数据失败: %w", err))
 	}
 
 	return nil
@@ -666,6 +719,7 @@ func (p *Provider) Reset() error {
 	defer p.connMutex.Unlock()
 
 	p.isStreaming = false
+	p.finished = false
 	p.closeConnection()
 
 	p.reqID = ""
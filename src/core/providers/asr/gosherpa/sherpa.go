@@ -2,15 +2,24 @@ package gosherpa
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 	"time"
 	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/types"
+
+	"xiaozhi-server-go/src/core/providers/transport"
 
 	"github.com/gorilla/websocket"
 )
 
+// pingTimeout 健康检查发送ping的超时时间
+const pingTimeout = 3 * time.Second
+
 type Provider struct {
 	*asr.BaseProvider
-	conn *websocket.Conn
+	conn   *websocket.Conn
+	broken atomic.Value // 连接断开的原因，连接正常时为空
 }
 
 func NewProvider(config *asr.Config, deleteFile bool) (*Provider, error) {
@@ -21,9 +30,11 @@ func NewProvider(config *asr.Config, deleteFile bool) (*Provider, error) {
 	}
 	// 初始化音频处理
 	provider.InitAudioProcessing()
-	dialer := websocket.Dialer{
-		HandshakeTimeout: 10 * time.Second, // 设置握手超时
+	dialer, err := transport.NewDialer(config.Data)
+	if err != nil {
+		return nil, err
 	}
+	dialer.HandshakeTimeout = 10 * time.Second // 设置握手超时
 	conn, _, err := dialer.DialContext(context.Background(), config.Data["addr"].(string), map[string][]string{})
 	if err != nil {
 		return nil, err
@@ -35,7 +46,11 @@ func NewProvider(config *asr.Config, deleteFile bool) (*Provider, error) {
 			}
 		}()
 		for {
-			messageType, p, _ := conn.ReadMessage()
+			messageType, p, err := conn.ReadMessage()
+			if err != nil {
+				provider.broken.Store(err.Error())
+				return
+			}
 			if messageType == websocket.TextMessage {
 				if listener := provider.GetListener(); listener != nil {
 					if finished := listener.OnAsrResult(string(p)); finished {
@@ -56,11 +71,31 @@ func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, er
 
 // 添加音频数据到缓冲区
 func (p *Provider) AddAudio(data []byte) error {
-	p.conn.WriteMessage(websocket.BinaryMessage, data)
+	if err := p.conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		p.broken.Store(err.Error())
+		return &types.ProviderError{Kind: types.ErrorKindNetwork, Provider: "gosherpa", Err: fmt.Errorf("发送音频失败: %w", err)}
+	}
+	return nil
+}
 
+// HealthCheck 检查与sherpa服务的websocket连接，读取协程已退出或ping发送失败时返回错误
+func (p *Provider) HealthCheck() error {
+	if reason, _ := p.broken.Load().(string); reason != "" {
+		return fmt.Errorf("go-sherpa-asr 连接已断开: %s", reason)
+	}
+	if err := p.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingTimeout)); err != nil {
+		p.broken.Store(err.Error())
+		return fmt.Errorf("go-sherpa-asr 连接已断开: %v", err)
+	}
 	return nil
 }
 
+// Cleanup 关闭与sherpa服务的连接
+func (p *Provider) Cleanup() error {
+	p.conn.Close()
+	return p.BaseProvider.Cleanup()
+}
+
 // 复位ASR状态
 func (p *Provider) Reset() error {
 	return nil
@@ -15,6 +15,23 @@ type AsrEventListener interface {
 	OnAsrResult(result string) bool
 }
 
+// AsrConfidenceReporter 可选接口，提供最近一次识别结果的置信度（0~1）
+// 服务端未返回置信度时 ok 为 false
+type AsrConfidenceReporter interface {
+	LastConfidence() (confidence float64, ok bool)
+}
+
+// HealthChecker 可选接口，资源池定期检查空闲的提供者，返回错误时销毁并重新创建
+// 检查需要很快返回，如检查长连接是否已断开
+type HealthChecker interface {
+	HealthCheck() error
+}
+
+// AsrFinisher 可选接口，服务端VAD检测到说话结束时通知ASR结束当前流，尽快返回最终结果
+type AsrFinisher interface {
+	Finish() error
+}
+
 // ASRProvider 语音识别提供者接口
 type ASRProvider interface {
 	Provider
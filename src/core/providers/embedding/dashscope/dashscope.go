@@ -0,0 +1,141 @@
+package dashscope
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers/embedding"
+
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+)
+
+const (
+	defaultBaseURL = "https://dashscope.aliyuncs.com/api/v1"
+	// maxBatchSize 单次请求最多的文本数
+	maxBatchSize = 10
+)
+
+// Provider 阿里云百炼（DashScope）原生向量化接口
+type Provider struct {
+	*embedding.BaseProvider
+	client  *http.Client
+	baseURL string
+}
+
+// 注册提供者
+func init() {
+	embedding.Register("dashscope", NewProvider)
+}
+
+// NewProvider 创建DashScope向量化提供者
+func NewProvider(config *configs.EmbeddingConfig) (embedding.Provider, error) {
+	provider := &Provider{
+		BaseProvider: embedding.NewBaseProvider(config),
+		baseURL:      strings.TrimSuffix(config.BaseURL, "/"),
+	}
+	if provider.baseURL == "" {
+		provider.baseURL = defaultBaseURL
+	}
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	if p.Config().APIKey == "" {
+		return fmt.Errorf("missing DashScope API key")
+	}
+	client, err := transport.NewHTTPClient(p.Config().Extra, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	p.client = client
+	return nil
+}
+
+type embeddingRequest struct {
+	Model string `json:"model"`
+	Input struct {
+		Texts []string `json:"texts"`
+	} `json:"input"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type embeddingResponse struct {
+	Output struct {
+		Embeddings []struct {
+			TextIndex int       `json:"text_index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"embeddings"`
+	} `json:"output"`
+}
+
+// Embed embedding.Provider接口实现，超过单次上限时分批请求
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := p.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (p *Provider) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	config := p.Config()
+	reqBody := embeddingRequest{Model: config.ModelName}
+	reqBody.Input.Texts = texts
+	if config.Dimensions > 0 {
+		reqBody.Parameters = map[string]interface{}{"dimension": config.Dimensions}
+	}
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	url := p.baseURL + "/services/embeddings/text-embedding/text-embedding"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+config.APIKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, types.NewProviderError("dashscope", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewProviderError("dashscope", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, types.NewHTTPError("dashscope", resp.StatusCode, string(body))
+	}
+
+	var result embeddingResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, item := range result.Output.Embeddings {
+		if item.TextIndex >= 0 && item.TextIndex < len(vectors) {
+			vectors[item.TextIndex] = item.Embedding
+		}
+	}
+	return vectors, nil
+}
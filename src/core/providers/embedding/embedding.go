@@ -0,0 +1,82 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+// Provider 文本向量化提供者接口
+type Provider interface {
+	Initialize() error
+	Cleanup() error
+
+	// Embed 批量计算文本向量，返回结果与输入顺序一致
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// ModelName 当前使用的模型名称
+	ModelName() string
+}
+
+// BaseProvider 向量化基础实现
+type BaseProvider struct {
+	config *configs.EmbeddingConfig
+}
+
+// NewBaseProvider 创建向量化基础提供者
+func NewBaseProvider(config *configs.EmbeddingConfig) *BaseProvider {
+	return &BaseProvider{
+		config: config,
+	}
+}
+
+// Config 获取配置
+func (p *BaseProvider) Config() *configs.EmbeddingConfig {
+	return p.config
+}
+
+// ModelName 获取模型名称
+func (p *BaseProvider) ModelName() string {
+	return p.config.ModelName
+}
+
+// Initialize 初始化提供者
+func (p *BaseProvider) Initialize() error {
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *BaseProvider) Cleanup() error {
+	return nil
+}
+
+// Factory 向量化工厂函数类型
+type Factory func(config *configs.EmbeddingConfig) (Provider, error)
+
+var (
+	factories = make(map[string]Factory)
+)
+
+// Register 注册向量化提供者工厂
+func Register(name string, factory Factory) {
+	factories[name] = factory
+}
+
+// Create 创建向量化提供者实例
+func Create(name string, config *configs.EmbeddingConfig) (Provider, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的向量化提供者: %s", name)
+	}
+
+	provider, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建向量化提供者失败: %v", err)
+	}
+
+	if err := provider.Initialize(); err != nil {
+		return nil, fmt.Errorf("初始化向量化提供者失败: %v", err)
+	}
+
+	return provider, nil
+}
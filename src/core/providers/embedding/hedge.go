@@ -0,0 +1,36 @@
+package embedding
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"xiaozhi-server-go/src/core/hedge"
+)
+
+// HedgeProvider 向量化请求迟迟未返回时再请求一次，使用先返回的结果
+type HedgeProvider struct {
+	Provider
+	policy hedge.Policy
+}
+
+// NewHedgeProvider 包装向量化提供者
+func NewHedgeProvider(provider Provider, policy hedge.Policy) *HedgeProvider {
+	return &HedgeProvider{
+		Provider: provider,
+		policy:   policy,
+	}
+}
+
+// Embed 对冲计算文本向量，文本总长度超过 MaxChars 时不对冲
+func (p *HedgeProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	chars := 0
+	for _, text := range texts {
+		chars += utf8.RuneCountInString(text)
+	}
+	if p.policy.MaxChars > 0 && chars > p.policy.MaxChars {
+		return p.Provider.Embed(ctx, texts)
+	}
+	return hedge.Do(ctx, hedge.TargetEmbedding, p.policy, chars, func(ctx context.Context) ([][]float32, error) {
+		return p.Provider.Embed(ctx, texts)
+	}, nil)
+}
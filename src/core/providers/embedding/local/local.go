@@ -0,0 +1,94 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers/embedding"
+
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+)
+
+const defaultBaseURL = "http://localhost:11434"
+
+// Provider 本地向量化服务，使用 Ollama 的 /api/embed 接口
+type Provider struct {
+	*embedding.BaseProvider
+	client  *http.Client
+	baseURL string
+}
+
+// 注册提供者
+func init() {
+	embedding.Register("local", NewProvider)
+}
+
+// NewProvider 创建本地向量化提供者
+func NewProvider(config *configs.EmbeddingConfig) (embedding.Provider, error) {
+	client, err := transport.NewHTTPClient(config.Extra, 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	provider := &Provider{
+		BaseProvider: embedding.NewBaseProvider(config),
+		baseURL:      strings.TrimSuffix(config.BaseURL, "/"),
+		client:       client,
+	}
+	if provider.baseURL == "" {
+		provider.baseURL = defaultBaseURL
+	}
+	return provider, nil
+}
+
+type embedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// Embed embedding.Provider接口实现
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	data, err := json.Marshal(embedRequest{Model: p.Config().ModelName, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/embed", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, types.NewProviderError("local", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewProviderError("local", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, types.NewHTTPError("local", resp.StatusCode, string(body))
+	}
+
+	var result embedResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("向量数量不匹配: 期望%d, 实际%d", len(texts), len(result.Embeddings))
+	}
+	return result.Embeddings, nil
+}
@@ -0,0 +1,73 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers/embedding"
+
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Provider OpenAI向量化提供者，也适用于兼容OpenAI接口的服务
+type Provider struct {
+	*embedding.BaseProvider
+	client *openai.Client
+}
+
+// 注册提供者
+func init() {
+	embedding.Register("openai", NewProvider)
+}
+
+// NewProvider 创建OpenAI向量化提供者
+func NewProvider(config *configs.EmbeddingConfig) (embedding.Provider, error) {
+	return &Provider{
+		BaseProvider: embedding.NewBaseProvider(config),
+	}, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	config := p.Config()
+	if config.APIKey == "" {
+		return fmt.Errorf("missing OpenAI API key")
+	}
+
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = config.BaseURL
+	}
+	httpClient, err := transport.NewHTTPClient(config.Extra, 0)
+	if err != nil {
+		return err
+	}
+	clientConfig.HTTPClient = httpClient
+	p.client = openai.NewClientWithConfig(clientConfig)
+	return nil
+}
+
+// Embed embedding.Provider接口实现
+func (p *Provider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	config := p.Config()
+	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input:      texts,
+		Model:      openai.EmbeddingModel(config.ModelName),
+		Dimensions: config.Dimensions,
+	})
+	if err != nil {
+		return nil, types.NewProviderError("openai", err)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		if item.Index >= 0 && item.Index < len(vectors) {
+			vectors[item.Index] = item.Embedding
+		}
+	}
+	return vectors, nil
+}
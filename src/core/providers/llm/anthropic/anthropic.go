@@ -0,0 +1,404 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBaseURL    = "https://api.anthropic.com"
+	defaultAPIVersion = "2023-06-01"
+)
+
+// Provider Anthropic Claude LLM提供者，使用 Messages API
+type Provider struct {
+	*llm.BaseProvider
+	client     *http.Client
+	baseURL    string
+	apiVersion string
+	maxTokens  int
+}
+
+// 注册提供者
+func init() {
+	llm.Register("anthropic", NewProvider)
+}
+
+// NewProvider 创建Anthropic提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	base := llm.NewBaseProvider(config)
+	provider := &Provider{
+		BaseProvider: base,
+		maxTokens:    config.MaxTokens,
+		baseURL:      strings.TrimSuffix(config.BaseURL, "/"),
+		apiVersion:   defaultAPIVersion,
+	}
+	if provider.maxTokens <= 0 {
+		provider.maxTokens = 500
+	}
+	if provider.baseURL == "" {
+		provider.baseURL = defaultBaseURL
+	}
+	if version, ok := config.Extra["anthropic_version"].(string); ok && version != "" {
+		provider.apiVersion = version
+	}
+
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	if p.Config().APIKey == "" {
+		return fmt.Errorf("missing Anthropic API key")
+	}
+	client, err := transport.NewHTTPClient(p.Config().Extra, 0)
+	if err != nil {
+		return fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	p.client = client
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	body, err := p.openStream(ctx, messages, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer body.Close()
+
+		err := readEvents(ctx, body, func(event streamEvent) {
+			if event.Type == "content_block_delta" && event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				responseChan <- event.Delta.Text
+			}
+		})
+		if err != nil {
+			logrus.WithError(err).Error("Anthropic流式响应中断")
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	body, err := p.openStream(ctx, messages, convertTools(tools))
+	if err != nil {
+		return nil, err
+	}
+
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer body.Close()
+
+		// 工具参数未流式返回时，在块结束时补一个空对象
+		toolBlock := -1
+		toolHasArgs := false
+		err := readEvents(ctx, body, func(event streamEvent) {
+			switch event.Type {
+			case "content_block_start":
+				if event.ContentBlock.Type == "tool_use" {
+					toolBlock = event.Index
+					toolHasArgs = false
+					responseChan <- types.Response{ToolCalls: []types.ToolCall{{
+						ID:       event.ContentBlock.ID,
+						Type:     "function",
+						Function: types.FunctionCall{Name: event.ContentBlock.Name},
+					}}}
+				}
+			case "content_block_delta":
+				switch event.Delta.Type {
+				case "text_delta":
+					responseChan <- types.Response{Content: event.Delta.Text}
+				case "input_json_delta":
+					if event.Delta.PartialJSON != "" {
+						toolHasArgs = true
+						responseChan <- types.Response{ToolCalls: []types.ToolCall{{
+							Function: types.FunctionCall{Arguments: event.Delta.PartialJSON},
+						}}}
+					}
+				}
+			case "content_block_stop":
+				if event.Index == toolBlock && !toolHasArgs {
+					responseChan <- types.Response{ToolCalls: []types.ToolCall{{
+						Function: types.FunctionCall{Arguments: "{}"},
+					}}}
+				}
+			case "message_delta":
+				if event.Delta.StopReason != "" {
+					responseChan <- types.Response{StopReason: event.Delta.StopReason}
+				}
+			}
+		})
+		if err != nil {
+			responseChan <- types.Response{Error: err.Error(), Err: err}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// messageRequest Messages API 请求体
+type messageRequest struct {
+	Model       string    `json:"model"`
+	System      string    `json:"system,omitempty"`
+	Messages    []message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature float64   `json:"temperature,omitempty"`
+	TopP        float64   `json:"top_p,omitempty"`
+	Tools       []tool    `json:"tools,omitempty"`
+	Stream      bool      `json:"stream"`
+}
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type tool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema"`
+}
+
+// streamEvent 流式响应事件，只解析用到的字段
+type streamEvent struct {
+	Type         string `json:"type"`
+	Index        int    `json:"index"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+	// message_start 携带模型和输入token数，message_delta 携带累计的输出token数
+	Message struct {
+		Model string     `json:"model"`
+		Usage eventUsage `json:"usage"`
+	} `json:"message"`
+	Usage eventUsage `json:"usage"`
+}
+
+// eventUsage 流式事件中的token用量
+type eventUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// openStream 发送流式请求，返回SSE响应体
+func (p *Provider) openStream(ctx context.Context, messages []types.Message, tools []tool) (io.ReadCloser, error) {
+	config := p.Config()
+	system, chatMessages := convertMessages(messages)
+	reqBody, err := json.Marshal(messageRequest{
+		Model:       config.ModelName,
+		System:      system,
+		Messages:    chatMessages,
+		MaxTokens:   p.maxTokens,
+		Temperature: config.Temperature,
+		TopP:        config.TopP,
+		Tools:       tools,
+		Stream:      true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", config.APIKey)
+	req.Header.Set("anthropic-version", p.apiVersion)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, types.NewProviderError("anthropic", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, types.NewHTTPError("anthropic", resp.StatusCode, string(body))
+	}
+	return resp.Body, nil
+}
+
+// readEvents 逐个解析SSE事件，遇到 error 事件或读取失败时返回结构化错误
+// 消息结束时通过上下文上报token用量
+func readEvents(ctx context.Context, body io.Reader, handle func(event streamEvent)) error {
+	usage := types.Usage{}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var event streamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			logrus.WithError(err).Debug("Anthropic事件解析失败: " + data)
+			continue
+		}
+		switch event.Type {
+		case "message_start":
+			usage.Model = event.Message.Model
+			usage.PromptTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			if event.Usage.OutputTokens > 0 {
+				usage.CompletionTokens = event.Usage.OutputTokens
+			}
+		}
+		switch event.Type {
+		case "error":
+			return types.NewHTTPError("anthropic", errorStatusCode(event.Error.Type),
+				fmt.Sprintf("%s: %s", event.Error.Type, event.Error.Message))
+		case "message_stop":
+			types.ReportUsage(ctx, usage)
+			return nil
+		default:
+			handle(event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return types.NewProviderError("anthropic", err)
+	}
+	return nil
+}
+
+// errorStatusCode 流式 error 事件类型对应的HTTP状态码，用于错误分类
+func errorStatusCode(errType string) int {
+	switch errType {
+	case "authentication_error", "permission_error":
+		return http.StatusUnauthorized
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "overloaded_error":
+		return 529
+	case "invalid_request_error":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// convertMessages 转换为Anthropic消息格式，system消息合并为顶层system字段，
+// tool消息转换为user消息中的tool_result块，相邻同角色消息合并
+func convertMessages(messages []types.Message) (string, []message) {
+	var systemParts []string
+	var result []message
+
+	appendBlocks := func(role string, blocks ...contentBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Content = append(result[n-1].Content, blocks...)
+			return
+		}
+		result = append(result, message{Role: role, Content: blocks})
+	}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				systemParts = append(systemParts, msg.Content)
+			}
+		case "tool":
+			appendBlocks("user", contentBlock{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
+			})
+		case "assistant":
+			var blocks []contentBlock
+			if msg.Content != "" {
+				blocks = append(blocks, contentBlock{Type: "text", Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, contentBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Function.Name,
+					Input: input,
+				})
+			}
+			appendBlocks("assistant", blocks...)
+		default:
+			if msg.Content != "" {
+				appendBlocks("user", contentBlock{Type: "text", Text: msg.Content})
+			}
+		}
+	}
+	return strings.Join(systemParts, "\n\n"), result
+}
+
+// convertTools 将OpenAI格式的工具定义转换为Anthropic格式
+func convertTools(tools []openai.Tool) []tool {
+	result := make([]tool, 0, len(tools))
+	for _, t := range tools {
+		if t.Function == nil {
+			continue
+		}
+		schema := t.Function.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		result = append(result, tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	return result
+}
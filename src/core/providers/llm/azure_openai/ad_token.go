@@ -0,0 +1,109 @@
+package azureopenai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers/transport"
+)
+
+const (
+	adScope        = "https://cognitiveservices.azure.com/.default"
+	adTokenURL     = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+	adTokenRefresh = 5 * time.Minute // 过期前提前刷新
+)
+
+// adClient 为每个请求附加Microsoft Entra ID访问令牌
+// 配置了 tenant_id/client_id/client_secret 时通过客户端凭据流程获取并自动续期，
+// 否则使用静态的 ad_token（由外部负责轮换）
+type adClient struct {
+	client       *http.Client
+	tenantID     string
+	clientID     string
+	clientSecret string
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func newADClient(extra map[string]interface{}) (*adClient, error) {
+	str := func(key string) string {
+		v, _ := extra[key].(string)
+		return v
+	}
+	httpClient, err := transport.NewHTTPClient(extra, 0)
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	c := &adClient{
+		client:       httpClient,
+		tenantID:     str("tenant_id"),
+		clientID:     str("client_id"),
+		clientSecret: str("client_secret"),
+	}
+	if c.tenantID != "" && c.clientID != "" && c.clientSecret != "" {
+		return c, nil
+	}
+	if token := str("ad_token"); token != "" {
+		c.token = token
+		return c, nil
+	}
+	return nil, fmt.Errorf("auth_mode 为 ad 时需配置 tenant_id/client_id/client_secret 或 ad_token")
+}
+
+// Do openai.HTTPDoer接口实现
+func (c *adClient) Do(req *http.Request) (*http.Response, error) {
+	token, err := c.getToken()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return c.client.Do(req)
+}
+
+func (c *adClient) getToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 静态令牌不过期检查
+	if c.clientSecret == "" {
+		return c.token, nil
+	}
+	if c.token != "" && time.Now().Add(adTokenRefresh).Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.clientID},
+		"client_secret": {c.clientSecret},
+		"scope":         {adScope},
+	}
+	resp, err := c.client.Post(fmt.Sprintf(adTokenURL, url.PathEscape(c.tenantID)), "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("获取Azure AD令牌失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("获取Azure AD令牌失败: HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析Azure AD令牌失败: %v", err)
+	}
+	c.token = result.AccessToken
+	c.expiresAt = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	return c.token, nil
+}
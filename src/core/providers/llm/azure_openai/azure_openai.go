@@ -0,0 +1,236 @@
+package azureopenai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"xiaozhi-server-go/src/core/providers/llm"
+	openaillm "xiaozhi-server-go/src/core/providers/llm/openai"
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultAPIVersion = "2024-10-21"
+
+// 鉴权方式
+const (
+	AuthModeKey = "key" // api-key 请求头（默认）
+	AuthModeAD  = "ad"  // Microsoft Entra ID（Azure AD）令牌
+)
+
+// Provider Azure OpenAI LLM提供者，按部署名称路由请求
+type Provider struct {
+	*llm.BaseProvider
+	client     *openai.Client
+	maxTokens  int
+	deployment string
+	apiVersion string
+	authMode   string
+}
+
+// 注册提供者
+func init() {
+	llm.Register("azure_openai", NewProvider)
+}
+
+// NewProvider 创建Azure OpenAI提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	base := llm.NewBaseProvider(config)
+	provider := &Provider{
+		BaseProvider: base,
+		maxTokens:    config.MaxTokens,
+		deployment:   config.ModelName,
+		apiVersion:   defaultAPIVersion,
+		authMode:     AuthModeKey,
+	}
+	if provider.maxTokens <= 0 {
+		provider.maxTokens = 500
+	}
+	if v, ok := config.Extra["deployment"].(string); ok && v != "" {
+		provider.deployment = v
+	}
+	if v, ok := config.Extra["api_version"].(string); ok && v != "" {
+		provider.apiVersion = v
+	}
+	if v, ok := config.Extra["auth_mode"].(string); ok && v != "" {
+		if v != AuthModeKey && v != AuthModeAD {
+			return nil, fmt.Errorf("未知的auth_mode: %s", v)
+		}
+		provider.authMode = v
+	}
+	if provider.deployment == "" {
+		return nil, fmt.Errorf("缺少Azure OpenAI部署名称")
+	}
+
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	config := p.Config()
+	if config.BaseURL == "" {
+		return fmt.Errorf("missing Azure OpenAI endpoint")
+	}
+
+	clientConfig := openai.DefaultAzureConfig(config.APIKey, strings.TrimSuffix(config.BaseURL, "/"))
+	clientConfig.APIVersion = p.apiVersion
+	// 请求统一路由到配置的部署，不按模型名称推导
+	clientConfig.AzureModelMapperFunc = func(string) string {
+		return p.deployment
+	}
+
+	if p.authMode == AuthModeAD {
+		doer, err := newADClient(config.Extra)
+		if err != nil {
+			return err
+		}
+		clientConfig.APIType = openai.APITypeAzureAD
+		clientConfig.HTTPClient = doer
+	} else if config.APIKey == "" {
+		return fmt.Errorf("missing Azure OpenAI API key")
+	} else {
+		httpClient, err := transport.NewHTTPClient(config.Extra, 0)
+		if err != nil {
+			return fmt.Errorf("创建HTTP客户端失败: %v", err)
+		}
+		clientConfig.HTTPClient = httpClient
+	}
+
+	p.client = openai.NewClientWithConfig(clientConfig)
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	stream, err := p.client.CreateChatCompletionStream(ctx, p.newRequest(messages, nil))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer stream.Close()
+
+		for {
+			response, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					logrus.WithError(wrapError(err)).Error("Azure OpenAI流式响应中断")
+				}
+				break
+			}
+			openaillm.ReportStreamUsage(ctx, response)
+			// 首个分块只包含 prompt_filter_results，没有 choices
+			if len(response.Choices) == 0 {
+				continue
+			}
+			choice := response.Choices[0]
+			if choice.Delta.Content != "" {
+				responseChan <- choice.Delta.Content
+			}
+			if choice.FinishReason == openai.FinishReasonContentFilter {
+				logrus.WithError(contentFilterError()).Warn("Azure OpenAI回复被内容筛选拦截")
+				break
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	stream, err := p.client.CreateChatCompletionStream(ctx, p.newRequest(messages, tools))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer stream.Close()
+
+		for {
+			response, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					providerErr := wrapError(err)
+					responseChan <- types.Response{Error: providerErr.Error(), Err: providerErr}
+				}
+				break
+			}
+			openaillm.ReportStreamUsage(ctx, response)
+			if len(response.Choices) == 0 {
+				continue
+			}
+
+			choice := response.Choices[0]
+			chunk := types.Response{Content: choice.Delta.Content}
+			for _, tc := range choice.Delta.ToolCalls {
+				chunk.ToolCalls = append(chunk.ToolCalls, types.ToolCall{
+					ID:   tc.ID,
+					Type: string(tc.Type),
+					Function: types.FunctionCall{
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					},
+				})
+			}
+			if chunk.Content != "" || len(chunk.ToolCalls) > 0 {
+				responseChan <- chunk
+			}
+			if choice.FinishReason == openai.FinishReasonContentFilter {
+				filterErr := contentFilterError()
+				responseChan <- types.Response{Error: filterErr.Error(), Err: filterErr}
+				break
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// newRequest 构造流式请求
+func (p *Provider) newRequest(messages []types.Message, tools []openai.Tool) openai.ChatCompletionRequest {
+	config := p.Config()
+	return openai.ChatCompletionRequest{
+		Model:       config.ModelName,
+		Messages:    openaillm.ConvertMessages(messages),
+		Tools:       tools,
+		Stream:      true,
+		MaxTokens:   p.maxTokens,
+		Temperature: float32(config.Temperature),
+		TopP:        float32(config.TopP),
+	}
+}
+
+// wrapError 转换为ProviderError，内容筛选错误单独标记
+func wrapError(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && fmt.Sprint(apiErr.Code) == "content_filter" {
+		return &types.ProviderError{Kind: types.ErrorKindContentFilter, Provider: "azure_openai", Err: err}
+	}
+	return types.NewProviderError("azure_openai", err)
+}
+
+func contentFilterError() error {
+	return &types.ProviderError{
+		Kind:     types.ErrorKindContentFilter,
+		Provider: "azure_openai",
+		Err:      fmt.Errorf("内容被Azure内容筛选拦截"),
+	}
+}
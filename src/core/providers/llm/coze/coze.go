@@ -10,6 +10,7 @@ import (
 	"io"
 	"sync"
 	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/providers/transport"
 	"xiaozhi-server-go/src/core/types"
 )
 
@@ -95,49 +96,51 @@ func (p *Provider) Initialize() error {
 		// 个人测试
 		authCli = coze.NewTokenAuth(p.accessToken)
 	}
-	p.client = coze.NewCozeAPI(authCli, coze.WithBaseURL(baseURL))
+	httpClient, err := transport.NewHTTPClient(config.Extra, 0)
+	if err != nil {
+		return fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	p.client = coze.NewCozeAPI(authCli, coze.WithBaseURL(baseURL), coze.WithHttpClient(httpClient))
 	return nil
 }
 
 // Response types.LLMProvider接口实现
 func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
-	responseChan := make(chan string, 10)
-
-	go func() {
-		defer close(responseChan)
-
-		var lastMsg string
-		if len(messages) > 0 {
-			lastMsg = messages[len(messages)-1].Content
-		}
-
-		conversationId, ok := p.sessionConversationMap.Load(sessionID)
-		if !ok {
-			conversation, err := p.client.Conversations.Create(ctx, &coze.CreateConversationsReq{
-				Messages: []*coze.Message{},
-			})
-			if err != nil {
-				responseChan <- fmt.Sprintf("【Coze服务创建会话失败: %v】", err)
-				return
-			}
-			conversationId = conversation.ID
-			p.sessionConversationMap.Store(sessionID, conversationId)
-		}
+	var lastMsg string
+	if len(messages) > 0 {
+		lastMsg = messages[len(messages)-1].Content
+	}
 
-		stream, err := p.client.Chat.Stream(ctx, &coze.CreateChatsReq{
-			BotID:  p.botID,
-			UserID: p.userID,
-			Messages: []*coze.Message{
-				coze.BuildUserQuestionObjects([]*coze.MessageObjectString{
-					coze.NewTextMessageObject(lastMsg),
-				}, nil),
-			},
-			ConversationID: conversationId.(string),
+	conversationId, ok := p.sessionConversationMap.Load(sessionID)
+	if !ok {
+		conversation, err := p.client.Conversations.Create(ctx, &coze.CreateConversationsReq{
+			Messages: []*coze.Message{},
 		})
 		if err != nil {
-			responseChan <- fmt.Sprintf("【Coze服务响应异常: %v】", err)
-			return
+			return nil, types.NewProviderError("coze", fmt.Errorf("创建会话失败: %w", err))
 		}
+		conversationId = conversation.ID
+		p.sessionConversationMap.Store(sessionID, conversationId)
+	}
+
+	stream, err := p.client.Chat.Stream(ctx, &coze.CreateChatsReq{
+		BotID:  p.botID,
+		UserID: p.userID,
+		Messages: []*coze.Message{
+			coze.BuildUserQuestionObjects([]*coze.MessageObjectString{
+				coze.NewTextMessageObject(lastMsg),
+			}, nil),
+		},
+		ConversationID: conversationId.(string),
+	})
+	if err != nil {
+		return nil, types.NewProviderError("coze", err)
+	}
+
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
 		defer stream.Close()
 
 		for {
@@ -152,6 +155,13 @@ func (p *Provider) Response(ctx context.Context, sessionID string, messages []ty
 			if event.Event == coze.ChatEventConversationMessageDelta {
 				responseChan <- event.Message.Content
 			}
+			if event.Event == coze.ChatEventConversationChatCompleted && event.Chat != nil && event.Chat.Usage != nil {
+				types.ReportUsage(ctx, types.Usage{
+					Model:            "coze",
+					PromptTokens:     event.Chat.Usage.InputCount,
+					CompletionTokens: event.Chat.Usage.OutputCount,
+				})
+			}
 		}
 	}()
 
@@ -172,8 +182,8 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 			functionBytes, err := json.Marshal(tools)
 			if err != nil {
 				responseChan <- types.Response{
-					Content: fmt.Sprintf("【序列化工具失败: %v】", err),
-					Error:   err.Error(),
+					Error: fmt.Sprintf("序列化工具失败: %v", err),
+					Err:   fmt.Errorf("序列化工具失败: %v", err),
 				}
 				return
 			}
@@ -198,8 +208,8 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 		respChan, err := p.Response(ctx, sessionID, messages)
 		if err != nil {
 			responseChan <- types.Response{
-				Content: fmt.Sprintf("【调用Response失败: %v】", err),
-				Error:   err.Error(),
+				Error: err.Error(),
+				Err:   err,
 			}
 			return
 		}
@@ -0,0 +1,303 @@
+package dashscope
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/core/providers/llm"
+	openaillm "xiaozhi-server-go/src/core/providers/llm/openai"
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultBaseURL = "https://dashscope.aliyuncs.com/api/v1"
+
+// Provider 通义千问LLM提供者，使用DashScope原生接口，支持联网搜索、增量输出等千问特有参数
+type Provider struct {
+	*llm.BaseProvider
+	client            *http.Client
+	baseURL           string
+	maxTokens         int
+	enableSearch      bool
+	searchOptions     map[string]interface{}
+	incrementalOutput bool
+	enableThinking    *bool
+}
+
+// 注册提供者
+func init() {
+	llm.Register("dashscope", NewProvider)
+}
+
+// NewProvider 创建DashScope提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	base := llm.NewBaseProvider(config)
+	provider := &Provider{
+		BaseProvider:      base,
+		baseURL:           strings.TrimSuffix(config.BaseURL, "/"),
+		maxTokens:         config.MaxTokens,
+		incrementalOutput: true,
+	}
+	if provider.maxTokens <= 0 {
+		provider.maxTokens = 500
+	}
+	if provider.baseURL == "" {
+		provider.baseURL = defaultBaseURL
+	}
+
+	if v, ok := config.Extra["enable_search"].(bool); ok {
+		provider.enableSearch = v
+	}
+	if v, ok := config.Extra["search_options"].(map[string]interface{}); ok {
+		provider.searchOptions = v
+	}
+	if v, ok := config.Extra["incremental_output"].(bool); ok {
+		provider.incrementalOutput = v
+	}
+	// enable_thinking 仅对 qwen3 等混合思考模型有效，未配置时使用模型默认值
+	if v, ok := config.Extra["enable_thinking"].(bool); ok {
+		provider.enableThinking = &v
+	}
+
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	if p.Config().APIKey == "" {
+		return fmt.Errorf("missing DashScope API key")
+	}
+	client, err := transport.NewHTTPClient(p.Config().Extra, 0)
+	if err != nil {
+		return fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	p.client = client
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	body, err := p.openStream(ctx, messages, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer body.Close()
+
+		err := p.readChunks(ctx, body, func(content string, _ []types.ToolCall) {
+			if content != "" {
+				responseChan <- content
+			}
+		})
+		if err != nil {
+			logrus.WithError(err).Error("DashScope流式响应中断")
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	body, err := p.openStream(ctx, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer body.Close()
+
+		err := p.readChunks(ctx, body, func(content string, toolCalls []types.ToolCall) {
+			if content != "" || len(toolCalls) > 0 {
+				responseChan <- types.Response{Content: content, ToolCalls: toolCalls}
+			}
+		})
+		if err != nil {
+			responseChan <- types.Response{Error: err.Error(), Err: err}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// generationRequest 文本生成请求体
+type generationRequest struct {
+	Model string `json:"model"`
+	Input struct {
+		Messages []openai.ChatCompletionMessage `json:"messages"`
+	} `json:"input"`
+	Parameters generationParameters `json:"parameters"`
+}
+
+type generationParameters struct {
+	ResultFormat      string                 `json:"result_format"`
+	IncrementalOutput bool                   `json:"incremental_output"`
+	MaxTokens         int                    `json:"max_tokens,omitempty"`
+	Temperature       float64                `json:"temperature,omitempty"`
+	TopP              float64                `json:"top_p,omitempty"`
+	EnableSearch      bool                   `json:"enable_search,omitempty"`
+	SearchOptions     map[string]interface{} `json:"search_options,omitempty"`
+	EnableThinking    *bool                  `json:"enable_thinking,omitempty"`
+	Tools             []openai.Tool          `json:"tools,omitempty"`
+}
+
+// streamChunk 流式响应分块，只解析用到的字段；出错时 code 和 message 非空
+type streamChunk struct {
+	Output struct {
+		Choices []struct {
+			Message struct {
+				Content          string            `json:"content"`
+				ReasoningContent string            `json:"reasoning_content"`
+				ToolCalls        []openai.ToolCall `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	} `json:"output"`
+	// 累计用量，以最后一个分块为准
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// openStream 发送流式请求，返回SSE响应体
+func (p *Provider) openStream(ctx context.Context, messages []types.Message, tools []openai.Tool) (io.ReadCloser, error) {
+	config := p.Config()
+	reqBody := generationRequest{Model: config.ModelName}
+	reqBody.Input.Messages = openaillm.ConvertMessages(messages)
+	reqBody.Parameters = generationParameters{
+		ResultFormat:      "message",
+		IncrementalOutput: p.incrementalOutput,
+		MaxTokens:         p.maxTokens,
+		Temperature:       config.Temperature,
+		TopP:              config.TopP,
+		EnableSearch:      p.enableSearch,
+		SearchOptions:     p.searchOptions,
+		EnableThinking:    p.enableThinking,
+		Tools:             tools,
+	}
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	url := p.baseURL + "/services/aigc/text-generation/generation"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	req.Header.Set("X-DashScope-SSE", "enable")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, types.NewProviderError("dashscope", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		var chunk streamChunk
+		if json.Unmarshal(body, &chunk) == nil && isContentFilter(chunk.Code) {
+			return nil, contentFilterError(chunk.Message)
+		}
+		return nil, types.NewHTTPError("dashscope", resp.StatusCode, string(body))
+	}
+	return resp.Body, nil
+}
+
+// readChunks 逐个解析SSE分块，思维链内容不输出；未开启增量输出时服务端返回累积文本，这里换算为增量
+func (p *Provider) readChunks(ctx context.Context, body io.Reader, handle func(content string, toolCalls []types.ToolCall)) error {
+	usage := types.Usage{Model: p.Config().ModelName}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	previous := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logrus.WithError(err).Debug("DashScope分块解析失败: " + data)
+			continue
+		}
+		if chunk.Code != "" {
+			if isContentFilter(chunk.Code) {
+				return contentFilterError(chunk.Message)
+			}
+			return types.NewProviderError("dashscope", fmt.Errorf("%s: %s (request_id=%s)", chunk.Code, chunk.Message, chunk.RequestID))
+		}
+		if chunk.Usage.InputTokens > 0 || chunk.Usage.OutputTokens > 0 {
+			usage.PromptTokens = chunk.Usage.InputTokens
+			usage.CompletionTokens = chunk.Usage.OutputTokens
+		}
+		if len(chunk.Output.Choices) == 0 {
+			continue
+		}
+
+		message := chunk.Output.Choices[0].Message
+		content := message.Content
+		if !p.incrementalOutput {
+			content = strings.TrimPrefix(message.Content, previous)
+			previous = message.Content
+		}
+
+		var toolCalls []types.ToolCall
+		for _, tc := range message.ToolCalls {
+			toolCalls = append(toolCalls, types.ToolCall{
+				ID:   tc.ID,
+				Type: string(tc.Type),
+				Function: types.FunctionCall{
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				},
+			})
+		}
+		handle(content, toolCalls)
+	}
+	if err := scanner.Err(); err != nil {
+		return types.NewProviderError("dashscope", err)
+	}
+	types.ReportUsage(ctx, usage)
+	return nil
+}
+
+// isContentFilter 输入或输出未通过阿里云内容安全审核
+func isContentFilter(code string) bool {
+	return code == "DataInspectionFailed" || code == "data_inspection_failed"
+}
+
+func contentFilterError(message string) error {
+	return &types.ProviderError{
+		Kind:     types.ErrorKindContentFilter,
+		Provider: "dashscope",
+		Err:      fmt.Errorf("内容被安全策略拦截: %s", message),
+	}
+}
@@ -0,0 +1,236 @@
+package deepseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"xiaozhi-server-go/src/core/providers/llm"
+	openaillm "xiaozhi-server-go/src/core/providers/llm/openai"
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultBaseURL = "https://api.deepseek.com"
+
+// 思维链处理方式
+const (
+	ReasoningModeFilter  = "filter"  // 丢弃思维链，只输出最终回答（默认）
+	ReasoningModeForward = "forward" // 思维链包裹在 <think> 标签中输出，由后处理链决定是否移除
+)
+
+// Provider DeepSeek LLM提供者，处理 deepseek-reasoner 流式返回的 reasoning_content 字段
+type Provider struct {
+	*llm.BaseProvider
+	client        *openai.Client
+	maxTokens     int
+	reasoningMode string
+}
+
+// 注册提供者
+func init() {
+	llm.Register("deepseek", NewProvider)
+}
+
+// NewProvider 创建DeepSeek提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	base := llm.NewBaseProvider(config)
+	provider := &Provider{
+		BaseProvider:  base,
+		maxTokens:     config.MaxTokens,
+		reasoningMode: ReasoningModeFilter,
+	}
+	if provider.maxTokens <= 0 {
+		provider.maxTokens = 500
+	}
+	if mode, ok := config.Extra["reasoning_mode"].(string); ok && mode != "" {
+		if mode != ReasoningModeFilter && mode != ReasoningModeForward {
+			return nil, fmt.Errorf("未知的reasoning_mode: %s", mode)
+		}
+		provider.reasoningMode = mode
+	}
+
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	config := p.Config()
+	if config.APIKey == "" {
+		return fmt.Errorf("missing DeepSeek API key")
+	}
+
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	clientConfig.BaseURL = defaultBaseURL
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = config.BaseURL
+	}
+
+	httpClient, err := transport.NewHTTPClient(config.Extra, 0)
+	if err != nil {
+		return fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	clientConfig.HTTPClient = httpClient
+
+	p.client = openai.NewClientWithConfig(clientConfig)
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	stream, err := p.client.CreateChatCompletionStream(ctx, p.newRequest(messages, nil))
+	if err != nil {
+		return nil, types.NewProviderError("deepseek", err)
+	}
+
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer stream.Close()
+
+		reasoning := p.newReasoningHandler()
+		for {
+			response, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					logrus.WithError(types.NewProviderError("deepseek", err)).Error("DeepSeek流式响应中断")
+				}
+				break
+			}
+			openaillm.ReportStreamUsage(ctx, response)
+			if len(response.Choices) == 0 {
+				continue
+			}
+			delta := response.Choices[0].Delta
+			if content := reasoning.handle(delta.ReasoningContent, delta.Content); content != "" {
+				responseChan <- content
+			}
+		}
+		if rest := reasoning.close(); rest != "" {
+			responseChan <- rest
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	stream, err := p.client.CreateChatCompletionStream(ctx, p.newRequest(messages, tools))
+	if err != nil {
+		return nil, types.NewProviderError("deepseek", err)
+	}
+
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer stream.Close()
+
+		reasoning := p.newReasoningHandler()
+		for {
+			response, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					providerErr := types.NewProviderError("deepseek", err)
+					responseChan <- types.Response{Error: providerErr.Error(), Err: providerErr}
+				}
+				break
+			}
+			openaillm.ReportStreamUsage(ctx, response)
+			if len(response.Choices) == 0 {
+				continue
+			}
+
+			delta := response.Choices[0].Delta
+			chunk := types.Response{
+				Content: reasoning.handle(delta.ReasoningContent, delta.Content),
+			}
+			if len(delta.ToolCalls) > 0 {
+				toolCalls := make([]types.ToolCall, len(delta.ToolCalls))
+				for i, tc := range delta.ToolCalls {
+					toolCalls[i] = types.ToolCall{
+						ID:   tc.ID,
+						Type: string(tc.Type),
+						Function: types.FunctionCall{
+							Name:      tc.Function.Name,
+							Arguments: tc.Function.Arguments,
+						},
+					}
+				}
+				chunk.ToolCalls = toolCalls
+			}
+			if chunk.Content != "" || len(chunk.ToolCalls) > 0 {
+				responseChan <- chunk
+			}
+		}
+		if rest := reasoning.close(); rest != "" {
+			responseChan <- types.Response{Content: rest}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// newRequest 构造流式请求，历史消息中不回传 reasoning_content
+func (p *Provider) newRequest(messages []types.Message, tools []openai.Tool) openai.ChatCompletionRequest {
+	config := p.Config()
+	return openai.ChatCompletionRequest{
+		Model:         config.ModelName,
+		Messages:      openaillm.ConvertMessages(messages),
+		Tools:         tools,
+		Stream:        true,
+		StreamOptions: openaillm.UsageStreamOptions(),
+		MaxTokens:     p.maxTokens,
+		Temperature:   float32(config.Temperature),
+		TopP:          float32(config.TopP),
+	}
+}
+
+// reasoningHandler 按 reasoning_mode 处理单个回复流中的思维链
+type reasoningHandler struct {
+	forward   bool
+	inThought bool
+}
+
+func (p *Provider) newReasoningHandler() *reasoningHandler {
+	return &reasoningHandler{forward: p.reasoningMode == ReasoningModeForward}
+}
+
+// handle 返回当前分块应输出的文本，forward 模式下思维链前后补齐 <think> 标签
+func (r *reasoningHandler) handle(reasoning, content string) string {
+	if !r.forward {
+		return content
+	}
+	out := ""
+	if reasoning != "" {
+		if !r.inThought {
+			out += "<think>"
+			r.inThought = true
+		}
+		out += reasoning
+	}
+	if content != "" {
+		out += r.close() + content
+	}
+	return out
+}
+
+// close 思维链未闭合时补齐结束标签
+func (r *reasoningHandler) close() string {
+	if r.inThought {
+		r.inThought = false
+		return "</think>"
+	}
+	return ""
+}
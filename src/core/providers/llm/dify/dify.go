@@ -0,0 +1,280 @@
+package dify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultBaseURL = "https://api.dify.ai/v1"
+
+// Provider Dify应用LLM提供者，调用 chat-messages 接口
+// 对话历史由Dify按 conversation_id 保存，每轮只发送最新的用户消息
+type Provider struct {
+	*llm.BaseProvider
+	client  *http.Client
+	baseURL string
+	user    string
+	inputs  map[string]interface{}
+
+	// sessionID -> Dify conversation_id
+	conversations sync.Map
+}
+
+// 注册提供者
+func init() {
+	llm.Register("dify", NewProvider)
+}
+
+// NewProvider 创建Dify提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	base := llm.NewBaseProvider(config)
+	provider := &Provider{
+		BaseProvider: base,
+		baseURL:      strings.TrimSuffix(config.BaseURL, "/"),
+		inputs:       map[string]interface{}{},
+	}
+	if provider.baseURL == "" {
+		provider.baseURL = defaultBaseURL
+	}
+	if v, ok := config.Extra["user"].(string); ok {
+		provider.user = v
+	}
+	if v, ok := config.Extra["inputs"].(map[string]interface{}); ok {
+		provider.inputs = v
+	}
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	if p.Config().APIKey == "" {
+		return fmt.Errorf("missing Dify API key")
+	}
+	client, err := transport.NewHTTPClient(p.Config().Extra, 0)
+	if err != nil {
+		return fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	p.client = client
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	body, err := p.openStream(ctx, sessionID, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer body.Close()
+
+		err := p.readEvents(ctx, body, sessionID, func(answer string) {
+			responseChan <- answer
+		})
+		if err != nil {
+			logrus.WithError(err).Error("Dify流式响应中断")
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+// 工具由Dify应用自身编排，这里不下发本地工具定义，只返回文本
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	body, err := p.openStream(ctx, sessionID, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer body.Close()
+
+		err := p.readEvents(ctx, body, sessionID, func(answer string) {
+			responseChan <- types.Response{Content: answer}
+		})
+		if err != nil {
+			responseChan <- types.Response{Error: err.Error(), Err: err}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// chatRequest chat-messages 请求体
+type chatRequest struct {
+	Inputs         map[string]interface{} `json:"inputs"`
+	Query          string                 `json:"query"`
+	ResponseMode   string                 `json:"response_mode"`
+	ConversationID string                 `json:"conversation_id,omitempty"`
+	User           string                 `json:"user"`
+}
+
+// streamEvent 流式事件，只解析用到的字段
+type streamEvent struct {
+	Event          string `json:"event"`
+	Answer         string `json:"answer"`
+	ConversationID string `json:"conversation_id"`
+	Status         int    `json:"status"`
+	Code           string `json:"code"`
+	Message        string `json:"message"`
+	// message_end 事件携带本次对话的token用量
+	Metadata struct {
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	} `json:"metadata"`
+}
+
+// openStream 发送最新的用户消息，返回SSE响应体
+// 本地对话历史中只有一条用户消息时视为新对话，不沿用之前的 conversation_id
+func (p *Provider) openStream(ctx context.Context, sessionID string, messages []types.Message) (io.ReadCloser, error) {
+	query, userTurns := lastUserQuery(messages)
+	if query == "" {
+		return nil, fmt.Errorf("没有可发送的用户消息")
+	}
+	if userTurns <= 1 {
+		p.conversations.Delete(sessionID)
+	}
+
+	conversationID := ""
+	if v, ok := p.conversations.Load(sessionID); ok {
+		conversationID = v.(string)
+	}
+	body, status, err := p.post(ctx, sessionID, query, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	// 会话在Dify侧已被删除时重新开始
+	if status == http.StatusNotFound && conversationID != "" {
+		body.Close()
+		p.conversations.Delete(sessionID)
+		body, status, err = p.post(ctx, sessionID, query, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if status != http.StatusOK {
+		defer body.Close()
+		data, _ := io.ReadAll(body)
+		return nil, types.NewHTTPError("dify", status, string(data))
+	}
+	return body, nil
+}
+
+func (p *Provider) post(ctx context.Context, sessionID, query, conversationID string) (io.ReadCloser, int, error) {
+	user := p.user
+	if user == "" {
+		user = sessionID
+	}
+	data, err := json.Marshal(chatRequest{
+		Inputs:         p.inputs,
+		Query:          query,
+		ResponseMode:   "streaming",
+		ConversationID: conversationID,
+		User:           user,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat-messages", bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.Config().APIKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, 0, types.NewProviderError("dify", err)
+	}
+	return resp.Body, resp.StatusCode, nil
+}
+
+// readEvents 解析SSE事件，记录Dify返回的 conversation_id
+func (p *Provider) readEvents(ctx context.Context, body io.Reader, sessionID string, handle func(answer string)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var event streamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			logrus.WithError(err).Debug("Dify事件解析失败: " + data)
+			continue
+		}
+		if event.ConversationID != "" {
+			p.conversations.Store(sessionID, event.ConversationID)
+		}
+
+		switch event.Event {
+		case "message", "agent_message":
+			if event.Answer != "" {
+				handle(event.Answer)
+			}
+		case "message_replace":
+			// 命中Dify内容审查，答案被替换为预设回复
+			logrus.WithField("session_id", sessionID).Warn("Dify回复被内容审查替换")
+			if event.Answer != "" {
+				handle(event.Answer)
+			}
+		case "error":
+			return types.NewHTTPError("dify", event.Status, fmt.Sprintf("%s: %s", event.Code, event.Message))
+		case "message_end":
+			types.ReportUsage(ctx, types.Usage{
+				Model:            "dify",
+				PromptTokens:     event.Metadata.Usage.PromptTokens,
+				CompletionTokens: event.Metadata.Usage.CompletionTokens,
+			})
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return types.NewProviderError("dify", err)
+	}
+	return nil
+}
+
+// lastUserQuery 返回最后一条用户消息以及用户消息总数
+func lastUserQuery(messages []types.Message) (string, int) {
+	query := ""
+	count := 0
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			query = msg.Content
+			count++
+		}
+	}
+	return query, count
+}
@@ -0,0 +1,250 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultFailoverRetries   = 2
+	defaultFailoverBaseDelay = 500 * time.Millisecond
+	defaultFailoverMaxDelay  = 5 * time.Second
+)
+
+// FailoverPolicy 重试与故障转移策略
+type FailoverPolicy struct {
+	MaxRetries   int           // 每个提供者的重试次数
+	BaseDelay    time.Duration // 首次重试间隔，之后按指数增长
+	MaxDelay     time.Duration // 重试间隔上限
+	StallTimeout time.Duration // 流式输出停滞超过该时间视为故障，0表示不检测
+}
+
+// FailoverProvider 为LLM提供者增加重试与故障转移
+// 限流、超时、网络和服务端错误按指数退避重试，主提供者仍失败时切换到备用提供者；
+// 流式输出停滞时，若尚未输出任何内容则同样重试或切换，已输出部分内容时直接返回错误，避免重复播报
+type FailoverProvider struct {
+	Provider
+	fallback Provider // 备用提供者，可为nil
+	policy   FailoverPolicy
+}
+
+// NewFailoverProvider 包装主提供者，fallback 为nil时只做重试
+func NewFailoverProvider(primary, fallback Provider, policy FailoverPolicy) *FailoverProvider {
+	if policy.MaxRetries < 0 {
+		policy.MaxRetries = defaultFailoverRetries
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = defaultFailoverBaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = defaultFailoverMaxDelay
+	}
+	return &FailoverProvider{
+		Provider: primary,
+		fallback: fallback,
+		policy:   policy,
+	}
+}
+
+// Config 主提供者的配置
+func (p *FailoverProvider) Config() *Config {
+	if getter, ok := p.Provider.(interface{ Config() *Config }); ok {
+		return getter.Config()
+	}
+	return &Config{}
+}
+
+// Cleanup 清理主、备提供者
+func (p *FailoverProvider) Cleanup() error {
+	err := p.Provider.Cleanup()
+	if p.fallback != nil {
+		if fallbackErr := p.fallback.Cleanup(); err == nil {
+			err = fallbackErr
+		}
+	}
+	return err
+}
+
+// Response types.LLMProvider接口实现
+func (p *FailoverProvider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	out := make(chan string, 10)
+	go func() {
+		defer close(out)
+		err := p.run(ctx, func(ctx context.Context, provider Provider) (bool, error) {
+			in, err := provider.Response(ctx, sessionID, messages)
+			if err != nil {
+				return false, err
+			}
+			return p.relayStrings(ctx, in, out)
+		})
+		if err != nil {
+			logrus.WithError(err).Error("LLM请求失败，重试与故障转移均未成功")
+		}
+	}()
+	return out, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现，最终失败时以错误分片返回
+func (p *FailoverProvider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	out := make(chan types.Response, 10)
+	go func() {
+		defer close(out)
+		err := p.run(ctx, func(ctx context.Context, provider Provider) (bool, error) {
+			in, err := provider.ResponseWithFunctions(ctx, sessionID, messages, tools)
+			if err != nil {
+				return false, err
+			}
+			return p.relayResponses(ctx, in, out)
+		})
+		if err != nil && ctx.Err() == nil {
+			out <- types.Response{Error: err.Error(), Err: err}
+		}
+	}()
+	return out, nil
+}
+
+// run 依次尝试主、备提供者，每个提供者按策略重试
+// attempt 返回是否已向调用方输出内容，已输出内容后不再重试
+func (p *FailoverProvider) run(ctx context.Context, attempt func(ctx context.Context, provider Provider) (bool, error)) error {
+	candidates := []Provider{p.Provider}
+	if p.fallback != nil {
+		candidates = append(candidates, p.fallback)
+	}
+
+	var lastErr error
+	for i, provider := range candidates {
+		if i > 0 {
+			logrus.WithError(lastErr).Warn("主LLM不可用，切换到备用LLM")
+		}
+		for retry := 0; retry <= p.policy.MaxRetries; retry++ {
+			if retry > 0 {
+				delay := p.backoff(retry)
+				logrus.WithError(lastErr).Warnf("LLM请求失败，%s 后第 %d 次重试", delay, retry)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(delay):
+				}
+			}
+
+			attemptCtx, cancel := context.WithCancel(ctx)
+			forwarded, err := attempt(attemptCtx, provider)
+			cancel()
+			if err == nil {
+				return nil
+			}
+			if forwarded || ctx.Err() != nil || !shouldFailover(err) {
+				return err
+			}
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// backoff 第 retry 次重试前的等待时间
+func (p *FailoverProvider) backoff(retry int) time.Duration {
+	delay := p.policy.BaseDelay << (retry - 1)
+	if delay <= 0 || delay > p.policy.MaxDelay {
+		delay = p.policy.MaxDelay
+	}
+	return delay
+}
+
+// shouldFailover 限流、超时、网络和服务端错误值得重试或切换提供者
+func shouldFailover(err error) bool {
+	kind := types.ErrorKindOf(err)
+	return kind.Retryable() || kind == types.ErrorKindQuota
+}
+
+// stallError 流式输出停滞的错误
+func (p *FailoverProvider) stallError() error {
+	return &types.ProviderError{
+		Kind:     types.ErrorKindTimeout,
+		Provider: "llm",
+		Err:      fmt.Errorf("流式输出停滞超过 %s", p.policy.StallTimeout),
+	}
+}
+
+// stallTimer 停滞检测计时器，未配置时返回永不触发的通道
+func (p *FailoverProvider) stallTimer() (*time.Timer, <-chan time.Time) {
+	if p.policy.StallTimeout <= 0 {
+		return nil, nil
+	}
+	timer := time.NewTimer(p.policy.StallTimeout)
+	return timer, timer.C
+}
+
+// relayStrings 转发文本分片，返回是否已转发内容
+func (p *FailoverProvider) relayStrings(ctx context.Context, in <-chan string, out chan<- string) (bool, error) {
+	timer, stalled := p.stallTimer()
+	if timer != nil {
+		defer timer.Stop()
+	}
+	forwarded := false
+	for {
+		select {
+		case <-ctx.Done():
+			return forwarded, ctx.Err()
+		case <-stalled:
+			return forwarded, p.stallError()
+		case chunk, ok := <-in:
+			if !ok {
+				return forwarded, nil
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return forwarded, ctx.Err()
+			}
+			forwarded = true
+			if timer != nil {
+				timer.Reset(p.policy.StallTimeout)
+			}
+		}
+	}
+}
+
+// relayResponses 转发回复分片，错误分片不转发而是作为错误返回
+func (p *FailoverProvider) relayResponses(ctx context.Context, in <-chan types.Response, out chan<- types.Response) (bool, error) {
+	timer, stalled := p.stallTimer()
+	if timer != nil {
+		defer timer.Stop()
+	}
+	forwarded := false
+	for {
+		select {
+		case <-ctx.Done():
+			return forwarded, ctx.Err()
+		case <-stalled:
+			return forwarded, p.stallError()
+		case response, ok := <-in:
+			if !ok {
+				return forwarded, nil
+			}
+			if response.Err != nil || response.Error != "" {
+				err := response.Err
+				if err == nil {
+					err = errors.New(response.Error)
+				}
+				return forwarded, err
+			}
+			select {
+			case out <- response:
+			case <-ctx.Done():
+				return forwarded, ctx.Err()
+			}
+			forwarded = true
+			if timer != nil {
+				timer.Reset(p.policy.StallTimeout)
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"context"
+
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// FaultProvider 在请求前调用注入函数，用于故障注入测试
+// 位于 FailoverProvider 之内，注入的失败会像真实故障一样触发重试与故障转移
+type FaultProvider struct {
+	Provider
+	inject func(ctx context.Context) error
+}
+
+// NewFaultProvider 包装LLM提供者，inject 返回错误时请求直接失败
+func NewFaultProvider(provider Provider, inject func(ctx context.Context) error) *FaultProvider {
+	return &FaultProvider{
+		Provider: provider,
+		inject:   inject,
+	}
+}
+
+// Config 被包装提供者的配置
+func (p *FaultProvider) Config() *Config {
+	if getter, ok := p.Provider.(interface{ Config() *Config }); ok {
+		return getter.Config()
+	}
+	return &Config{}
+}
+
+// Response types.LLMProvider接口实现
+func (p *FaultProvider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	if err := p.inject(ctx); err != nil {
+		return nil, err
+	}
+	return p.Provider.Response(ctx, sessionID, messages)
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *FaultProvider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	if err := p.inject(ctx); err != nil {
+		return nil, err
+	}
+	return p.Provider.ResponseWithFunctions(ctx, sessionID, messages, tools)
+}
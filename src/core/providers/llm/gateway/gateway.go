@@ -0,0 +1,234 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/core/providers/llm"
+	openaillm "xiaozhi-server-go/src/core/providers/llm/openai"
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+// sessionPlaceholder 请求头和查询参数中的会话ID占位符，每次请求时替换
+const sessionPlaceholder = "{session_id}"
+
+// Provider OpenAI兼容网关提供者（FastGPT、OneAPI、企业内部网关等），
+// 支持为每个请求附加自定义请求头和查询参数
+type Provider struct {
+	*llm.BaseProvider
+	client    *openai.Client
+	maxTokens int
+	name      string
+}
+
+// 注册提供者
+func init() {
+	llm.Register("gateway", NewProvider)
+	llm.Register("fastgpt", NewProvider)
+}
+
+// NewProvider 创建网关提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	base := llm.NewBaseProvider(config)
+	provider := &Provider{
+		BaseProvider: base,
+		maxTokens:    config.MaxTokens,
+		name:         config.Type,
+	}
+	if provider.maxTokens <= 0 {
+		provider.maxTokens = 500
+	}
+	if provider.name == "" {
+		provider.name = "gateway"
+	}
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	config := p.Config()
+	if config.BaseURL == "" {
+		return fmt.Errorf("missing gateway base_url")
+	}
+
+	headers, err := stringMap(config.Extra["headers"])
+	if err != nil {
+		return fmt.Errorf("解析网关headers配置失败: %v", err)
+	}
+	query, err := stringMap(config.Extra["query"])
+	if err != nil {
+		return fmt.Errorf("解析网关query配置失败: %v", err)
+	}
+
+	httpClient, err := transport.NewHTTPClient(config.Extra, 0)
+	if err != nil {
+		return fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+
+	// 部分网关使用自定义请求头鉴权，不需要api_key
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	clientConfig.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	clientConfig.HTTPClient = &gatewayClient{
+		client:  httpClient,
+		headers: headers,
+		query:   query,
+	}
+
+	p.client = openai.NewClientWithConfig(clientConfig)
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	ctx = context.WithValue(ctx, sessionKey{}, sessionID)
+	stream, err := p.client.CreateChatCompletionStream(ctx, p.newRequest(messages, nil))
+	if err != nil {
+		return nil, types.NewProviderError(p.name, err)
+	}
+
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer stream.Close()
+
+		for {
+			response, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					logrus.WithError(types.NewProviderError(p.name, err)).Error("网关流式响应中断")
+				}
+				break
+			}
+			openaillm.ReportStreamUsage(ctx, response)
+			if len(response.Choices) > 0 && response.Choices[0].Delta.Content != "" {
+				responseChan <- response.Choices[0].Delta.Content
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	ctx = context.WithValue(ctx, sessionKey{}, sessionID)
+	stream, err := p.client.CreateChatCompletionStream(ctx, p.newRequest(messages, tools))
+	if err != nil {
+		return nil, types.NewProviderError(p.name, err)
+	}
+
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer stream.Close()
+
+		for {
+			response, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					providerErr := types.NewProviderError(p.name, err)
+					responseChan <- types.Response{Error: providerErr.Error(), Err: providerErr}
+				}
+				break
+			}
+			openaillm.ReportStreamUsage(ctx, response)
+			if len(response.Choices) == 0 {
+				continue
+			}
+
+			delta := response.Choices[0].Delta
+			chunk := types.Response{Content: delta.Content}
+			for _, tc := range delta.ToolCalls {
+				chunk.ToolCalls = append(chunk.ToolCalls, types.ToolCall{
+					ID:   tc.ID,
+					Type: string(tc.Type),
+					Function: types.FunctionCall{
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					},
+				})
+			}
+			if chunk.Content != "" || len(chunk.ToolCalls) > 0 {
+				responseChan <- chunk
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// newRequest 构造流式请求
+func (p *Provider) newRequest(messages []types.Message, tools []openai.Tool) openai.ChatCompletionRequest {
+	config := p.Config()
+	return openai.ChatCompletionRequest{
+		Model:         config.ModelName,
+		Messages:      openaillm.ConvertMessages(messages),
+		Tools:         tools,
+		Stream:        true,
+		StreamOptions: openaillm.UsageStreamOptions(),
+		MaxTokens:     p.maxTokens,
+		Temperature:   float32(config.Temperature),
+		TopP:          float32(config.TopP),
+	}
+}
+
+// sessionKey 在请求上下文中传递会话ID
+type sessionKey struct{}
+
+// gatewayClient 为每个请求附加配置的请求头和查询参数
+type gatewayClient struct {
+	client  *http.Client
+	headers map[string]string
+	query   map[string]string
+}
+
+// Do openai.HTTPDoer接口实现
+func (c *gatewayClient) Do(req *http.Request) (*http.Response, error) {
+	sessionID, _ := req.Context().Value(sessionKey{}).(string)
+	expand := func(v string) string {
+		return strings.ReplaceAll(v, sessionPlaceholder, sessionID)
+	}
+
+	for k, v := range c.headers {
+		req.Header.Set(k, expand(v))
+	}
+	if len(c.query) > 0 {
+		q := req.URL.Query()
+		for k, v := range c.query {
+			q.Set(k, expand(v))
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	return c.client.Do(req)
+}
+
+// stringMap 将配置中的映射转换为字符串映射，非字符串值按默认格式输出
+func stringMap(v interface{}) (map[string]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("应为键值映射，实际为 %T", v)
+	}
+	result := make(map[string]string, len(m))
+	for k, val := range m {
+		result[k] = fmt.Sprint(val)
+	}
+	return result, nil
+}
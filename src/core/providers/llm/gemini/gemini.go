@@ -0,0 +1,420 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// harmCategories safety_threshold 统一应用的安全类别
+var harmCategories = []string{
+	"HARM_CATEGORY_HARASSMENT",
+	"HARM_CATEGORY_HATE_SPEECH",
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"HARM_CATEGORY_DANGEROUS_CONTENT",
+}
+
+// Provider Google Gemini LLM提供者，使用 streamGenerateContent 接口
+type Provider struct {
+	*llm.BaseProvider
+	client         *http.Client
+	baseURL        string
+	maxTokens      int
+	safetySettings []safetySetting
+}
+
+// 注册提供者
+func init() {
+	llm.Register("gemini", NewProvider)
+}
+
+// NewProvider 创建Gemini提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	base := llm.NewBaseProvider(config)
+	provider := &Provider{
+		BaseProvider: base,
+		maxTokens:    config.MaxTokens,
+		baseURL:      strings.TrimSuffix(config.BaseURL, "/"),
+	}
+	if provider.maxTokens <= 0 {
+		provider.maxTokens = 500
+	}
+	if provider.baseURL == "" {
+		provider.baseURL = defaultBaseURL
+	}
+
+	// safety_threshold 统一设置所有类别，safety_settings 按类别覆盖
+	if threshold, ok := config.Extra["safety_threshold"].(string); ok && threshold != "" {
+		for _, category := range harmCategories {
+			provider.safetySettings = append(provider.safetySettings, safetySetting{Category: category, Threshold: threshold})
+		}
+	}
+	if settings, ok := config.Extra["safety_settings"].(map[string]interface{}); ok {
+		for category, threshold := range settings {
+			provider.setSafety(category, fmt.Sprint(threshold))
+		}
+	}
+
+	return provider, nil
+}
+
+// setSafety 设置单个类别的安全阈值
+func (p *Provider) setSafety(category, threshold string) {
+	for i := range p.safetySettings {
+		if p.safetySettings[i].Category == category {
+			p.safetySettings[i].Threshold = threshold
+			return
+		}
+	}
+	p.safetySettings = append(p.safetySettings, safetySetting{Category: category, Threshold: threshold})
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	if p.Config().APIKey == "" {
+		return fmt.Errorf("missing Gemini API key")
+	}
+	client, err := transport.NewHTTPClient(p.Config().Extra, 0)
+	if err != nil {
+		return fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	p.client = client
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	body, err := p.openStream(ctx, messages, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer body.Close()
+
+		err := readChunks(ctx, body, func(part part) {
+			if part.Text != "" && !part.Thought {
+				responseChan <- part.Text
+			}
+		})
+		if err != nil {
+			logrus.WithError(err).Error("Gemini流式响应中断")
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	body, err := p.openStream(ctx, messages, convertTools(tools))
+	if err != nil {
+		return nil, err
+	}
+
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer body.Close()
+
+		err := readChunks(ctx, body, func(part part) {
+			switch {
+			case part.FunctionCall != nil:
+				// Gemini 一次返回完整的函数调用，没有调用ID，这里生成一个
+				args := string(part.FunctionCall.Args)
+				if args == "" || args == "null" {
+					args = "{}"
+				}
+				responseChan <- types.Response{ToolCalls: []types.ToolCall{{
+					ID:   "call_" + uuid.New().String(),
+					Type: "function",
+					Function: types.FunctionCall{
+						Name:      part.FunctionCall.Name,
+						Arguments: args,
+					},
+				}}}
+			case part.Text != "" && !part.Thought:
+				responseChan <- types.Response{Content: part.Text}
+			}
+		})
+		if err != nil {
+			responseChan <- types.Response{Error: err.Error(), Err: err}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// generateRequest generateContent 请求体
+type generateRequest struct {
+	Contents          []content        `json:"contents"`
+	SystemInstruction *content         `json:"systemInstruction,omitempty"`
+	GenerationConfig  generationConfig `json:"generationConfig"`
+	SafetySettings    []safetySetting  `json:"safetySettings,omitempty"`
+	Tools             []toolSet        `json:"tools,omitempty"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text             string            `json:"text,omitempty"`
+	Thought          bool              `json:"thought,omitempty"`
+	FunctionCall     *functionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *functionResponse `json:"functionResponse,omitempty"`
+}
+
+type functionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type functionResponse struct {
+	Name     string      `json:"name"`
+	Response interface{} `json:"response"`
+}
+
+type generationConfig struct {
+	Temperature     float64 `json:"temperature,omitempty"`
+	TopP            float64 `json:"topP,omitempty"`
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+}
+
+type safetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+type toolSet struct {
+	FunctionDeclarations []functionDeclaration `json:"functionDeclarations"`
+}
+
+type functionDeclaration struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters,omitempty"`
+}
+
+// streamChunk 流式响应分块，只解析用到的字段
+type streamChunk struct {
+	Candidates []struct {
+		Content      content `json:"content"`
+		FinishReason string  `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+	// 每个分块都携带截至当前的累计用量，以最后一个为准
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+}
+
+// openStream 发送流式请求，返回SSE响应体
+func (p *Provider) openStream(ctx context.Context, messages []types.Message, tools []toolSet) (io.ReadCloser, error) {
+	config := p.Config()
+	system, contents := convertMessages(messages)
+	reqBody, err := json.Marshal(generateRequest{
+		Contents:          contents,
+		SystemInstruction: system,
+		GenerationConfig: generationConfig{
+			Temperature:     config.Temperature,
+			TopP:            config.TopP,
+			MaxOutputTokens: p.maxTokens,
+		},
+		SafetySettings: p.safetySettings,
+		Tools:          tools,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", p.baseURL, config.ModelName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", config.APIKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, types.NewProviderError("gemini", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, types.NewHTTPError("gemini", resp.StatusCode, string(body))
+	}
+	return resp.Body, nil
+}
+
+// readChunks 逐个解析SSE分块中的内容片段，被安全策略拦截时返回内容过滤错误
+func readChunks(ctx context.Context, body io.Reader, handle func(part part)) error {
+	usage := types.Usage{}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logrus.WithError(err).Debug("Gemini分块解析失败: " + data)
+			continue
+		}
+		if chunk.Error != nil {
+			return types.NewHTTPError("gemini", chunk.Error.Code, fmt.Sprintf("%s: %s", chunk.Error.Status, chunk.Error.Message))
+		}
+		if reason := chunk.PromptFeedback.BlockReason; reason != "" {
+			return contentFilterError(reason)
+		}
+		if chunk.UsageMetadata != nil {
+			usage.Model = chunk.ModelVersion
+			usage.PromptTokens = chunk.UsageMetadata.PromptTokenCount
+			usage.CompletionTokens = chunk.UsageMetadata.CandidatesTokenCount
+		}
+		for _, candidate := range chunk.Candidates {
+			for _, part := range candidate.Content.Parts {
+				handle(part)
+			}
+			switch candidate.FinishReason {
+			case "SAFETY", "PROHIBITED_CONTENT", "BLOCKLIST", "SPII":
+				return contentFilterError(candidate.FinishReason)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return types.NewProviderError("gemini", err)
+	}
+	types.ReportUsage(ctx, usage)
+	return nil
+}
+
+func contentFilterError(reason string) error {
+	return &types.ProviderError{
+		Kind:     types.ErrorKindContentFilter,
+		Provider: "gemini",
+		Err:      fmt.Errorf("内容被安全策略拦截: %s", reason),
+	}
+}
+
+// convertMessages 转换为Gemini消息格式，system消息合并为 systemInstruction，
+// assistant 对应 model 角色，tool 消息转换为 functionResponse，相邻同角色消息合并
+func convertMessages(messages []types.Message) (*content, []content) {
+	var systemParts []part
+	var result []content
+	// tool 消息只有调用ID，需要找回函数名
+	toolNames := make(map[string]string)
+
+	appendParts := func(role string, parts ...part) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Parts = append(result[n-1].Parts, parts...)
+			return
+		}
+		result = append(result, content{Role: role, Parts: parts})
+	}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				systemParts = append(systemParts, part{Text: msg.Content})
+			}
+		case "assistant":
+			var parts []part
+			if msg.Content != "" {
+				parts = append(parts, part{Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				toolNames[tc.ID] = tc.Function.Name
+				args := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(args) {
+					args = json.RawMessage("{}")
+				}
+				parts = append(parts, part{FunctionCall: &functionCall{Name: tc.Function.Name, Args: args}})
+			}
+			appendParts("model", parts...)
+		case "tool":
+			// functionResponse 的 response 必须是对象，非JSON对象的结果包装为 content 字段
+			var response interface{}
+			var obj map[string]interface{}
+			if err := json.Unmarshal([]byte(msg.Content), &obj); err == nil {
+				response = obj
+			} else {
+				response = map[string]interface{}{"content": msg.Content}
+			}
+			appendParts("user", part{FunctionResponse: &functionResponse{
+				Name:     toolNames[msg.ToolCallID],
+				Response: response,
+			}})
+		default:
+			if msg.Content != "" {
+				appendParts("user", part{Text: msg.Content})
+			}
+		}
+	}
+
+	if len(systemParts) == 0 {
+		return nil, result
+	}
+	return &content{Parts: systemParts}, result
+}
+
+// convertTools 将OpenAI格式的工具定义转换为Gemini函数声明
+func convertTools(tools []openai.Tool) []toolSet {
+	declarations := make([]functionDeclaration, 0, len(tools))
+	for _, t := range tools {
+		if t.Function == nil {
+			continue
+		}
+		declarations = append(declarations, functionDeclaration{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			Parameters:  t.Function.Parameters,
+		})
+	}
+	if len(declarations) == 0 {
+		return nil
+	}
+	return []toolSet{{FunctionDeclarations: declarations}}
+}
@@ -0,0 +1,334 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// FunctionCallModeJSON 通过JSON输出模拟函数调用，用于不支持原生function calling的模型
+	FunctionCallModeJSON = "json"
+
+	defaultJSONModeRetries = 2
+)
+
+var reThinkBlock = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+// jsonModeSystemPrompt JSON模式下的工具调用提示词
+const jsonModeSystemPrompt = `
+====
+
+TOOL USE (JSON MODE)
+
+You can call the tools listed below. Reply with EXACTLY ONE JSON object and nothing else (no markdown, no code fences, no explanations).
+
+To call a tool:
+{"tool_call": {"name": "<tool name>", "arguments": {<arguments matching the tool parameters schema>}}}
+
+To answer the user directly without calling a tool:
+{"response": "<your reply to the user>"}
+
+All required parameters must be provided and must match the declared types.
+
+# Tools
+
+%s
+`
+
+// JSONModeProvider 为不支持原生函数调用的LLM提供JSON模式的工具调用能力
+// 通过提示词约束模型输出JSON，解析并按工具参数schema校验，失败时附带错误信息要求模型修复重试
+type JSONModeProvider struct {
+	Provider
+	maxRetries int
+}
+
+// NewJSONModeProvider 包装LLM提供者，使其ResponseWithFunctions走JSON解析模式
+func NewJSONModeProvider(provider Provider, maxRetries int) *JSONModeProvider {
+	if maxRetries < 0 {
+		maxRetries = defaultJSONModeRetries
+	}
+	return &JSONModeProvider{
+		Provider:   provider,
+		maxRetries: maxRetries,
+	}
+}
+
+// Config 被包装提供者的配置
+func (p *JSONModeProvider) Config() *Config {
+	if getter, ok := p.Provider.(interface{ Config() *Config }); ok {
+		return getter.Config()
+	}
+	return &Config{}
+}
+
+// jsonModeOutput 模型在JSON模式下的输出结构
+type jsonModeOutput struct {
+	ToolCall *struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"tool_call"`
+	Response *string `json:"response"`
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *JSONModeProvider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	if len(tools) == 0 {
+		return p.Provider.ResponseWithFunctions(ctx, sessionID, messages, tools)
+	}
+
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		toolsBytes, err := json.Marshal(tools)
+		if err != nil {
+			responseChan <- types.Response{
+				Error: fmt.Sprintf("序列化工具失败: %v", err),
+				Err:   fmt.Errorf("序列化工具失败: %v", err),
+			}
+			return
+		}
+
+		jsonMessages := buildJSONModeMessages(messages, fmt.Sprintf(jsonModeSystemPrompt, string(toolsBytes)))
+
+		var lastErr error
+		for attempt := 0; attempt <= p.maxRetries; attempt++ {
+			output, raw, err := p.requestOnce(ctx, sessionID, jsonMessages)
+			if err != nil {
+				responseChan <- types.Response{
+					Error: err.Error(),
+					Err:   err,
+				}
+				return
+			}
+
+			if output == nil {
+				err = fmt.Errorf("输出不是合法的JSON对象")
+			} else {
+				err = validateJSONModeOutput(output, tools)
+			}
+			if err == nil {
+				responseChan <- toJSONModeResponse(output)
+				return
+			}
+
+			lastErr = err
+			logrus.WithFields(logrus.Fields{
+				"attempt": attempt + 1,
+				"error":   err.Error(),
+			}).Warn("JSON模式输出校验失败，要求模型修复")
+
+			// 附带错误信息，要求模型修复输出
+			jsonMessages = append(jsonMessages,
+				types.Message{Role: "assistant", Content: raw},
+				types.Message{Role: "user", Content: fmt.Sprintf(
+					"Your previous reply is invalid: %v. Reply again with exactly one valid JSON object as instructed.", err)},
+			)
+		}
+
+		// 多次修复仍失败，返回兜底回复
+		logrus.WithError(lastErr).Error("JSON模式多次修复仍失败")
+		responseChan <- types.Response{
+			Content: "抱歉，我没有理解该如何处理这个请求",
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// requestOnce 调用一次模型并解析输出
+func (p *JSONModeProvider) requestOnce(ctx context.Context, sessionID string, messages []types.Message) (*jsonModeOutput, string, error) {
+	respChan, err := p.Provider.Response(ctx, sessionID, messages)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var builder strings.Builder
+	for token := range respChan {
+		builder.WriteString(token)
+	}
+	raw := builder.String()
+
+	cleaned := reThinkBlock.ReplaceAllString(raw, "")
+	jsonData := utils.Extract_json_from_string(cleaned)
+	if jsonData == nil {
+		return nil, raw, nil
+	}
+
+	jsonBytes, err := json.Marshal(jsonData)
+	if err != nil {
+		return nil, raw, nil
+	}
+	var output jsonModeOutput
+	if err := json.Unmarshal(jsonBytes, &output); err != nil {
+		return nil, raw, nil
+	}
+	return &output, raw, nil
+}
+
+// buildJSONModeMessages 注入JSON模式提示词，并把历史中的原生工具调用转换为纯文本
+func buildJSONModeMessages(messages []types.Message, prompt string) []types.Message {
+	result := make([]types.Message, 0, len(messages)+1)
+	hasSystem := false
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "system" && !hasSystem:
+			hasSystem = true
+			result = append(result, types.Message{Role: "system", Content: msg.Content + "\n" + prompt})
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			call := msg.ToolCalls[0]
+			arguments := json.RawMessage(call.Function.Arguments)
+			if !json.Valid(arguments) {
+				arguments = json.RawMessage("{}")
+			}
+			content, _ := json.Marshal(map[string]interface{}{
+				"tool_call": map[string]interface{}{
+					"name":      call.Function.Name,
+					"arguments": arguments,
+				},
+			})
+			result = append(result, types.Message{Role: "assistant", Content: string(content)})
+		case msg.Role == "tool":
+			result = append(result, types.Message{Role: "user", Content: "tool call result: " + msg.Content})
+		default:
+			result = append(result, types.Message{Role: msg.Role, Content: msg.Content})
+		}
+	}
+	if !hasSystem {
+		result = append([]types.Message{{Role: "system", Content: prompt}}, result...)
+	}
+	return result
+}
+
+// toJSONModeResponse 将校验通过的输出转换为标准响应
+func toJSONModeResponse(output *jsonModeOutput) types.Response {
+	if output.ToolCall == nil {
+		return types.Response{Content: *output.Response}
+	}
+
+	arguments := output.ToolCall.Arguments
+	if arguments == nil {
+		arguments = map[string]interface{}{}
+	}
+	argumentsJSON, _ := json.Marshal(arguments)
+	return types.Response{
+		ToolCalls: []types.ToolCall{{
+			ID:   uuid.New().String(),
+			Type: "function",
+			Function: types.FunctionCall{
+				Name:      output.ToolCall.Name,
+				Arguments: string(argumentsJSON),
+			},
+		}},
+	}
+}
+
+// toolSchema 工具参数的JSON Schema（仅校验需要的部分）
+type toolSchema struct {
+	Properties map[string]struct {
+		Type string        `json:"type"`
+		Enum []interface{} `json:"enum"`
+	} `json:"properties"`
+	Required []string `json:"required"`
+}
+
+// validateJSONModeOutput 按工具定义校验模型输出
+func validateJSONModeOutput(output *jsonModeOutput, tools []openai.Tool) error {
+	if output.ToolCall == nil {
+		if output.Response == nil {
+			return fmt.Errorf("缺少tool_call或response字段")
+		}
+		return nil
+	}
+
+	name := output.ToolCall.Name
+	var tool *openai.Tool
+	for i := range tools {
+		if tools[i].Function != nil && tools[i].Function.Name == name {
+			tool = &tools[i]
+			break
+		}
+	}
+	if tool == nil {
+		return fmt.Errorf("未知的工具: %s", name)
+	}
+	if tool.Function.Parameters == nil {
+		return nil
+	}
+
+	schemaBytes, err := json.Marshal(tool.Function.Parameters)
+	if err != nil {
+		return nil
+	}
+	var schema toolSchema
+	if err := json.Unmarshal(schemaBytes, &schema); err != nil {
+		return nil
+	}
+
+	arguments := output.ToolCall.Arguments
+	for _, required := range schema.Required {
+		if _, ok := arguments[required]; !ok {
+			return fmt.Errorf("工具 %s 缺少必填参数: %s", name, required)
+		}
+	}
+	for key, value := range arguments {
+		prop, ok := schema.Properties[key]
+		if !ok {
+			continue
+		}
+		if !matchSchemaType(prop.Type, value) {
+			return fmt.Errorf("工具 %s 参数 %s 类型应为 %s", name, key, prop.Type)
+		}
+		if len(prop.Enum) > 0 && !containsValue(prop.Enum, value) {
+			return fmt.Errorf("工具 %s 参数 %s 取值不在允许范围内: %v", name, key, prop.Enum)
+		}
+	}
+	return nil
+}
+
+// matchSchemaType 检查值是否符合JSON Schema类型
+func matchSchemaType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	default:
+		return true
+	}
+}
+
+// containsValue 检查枚举中是否包含指定值
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if fmt.Sprint(v) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// stubProvider 只提供配置的LLM提供者
+type stubProvider struct {
+	*BaseProvider
+}
+
+func (p *stubProvider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	return nil, nil
+}
+
+func (p *stubProvider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	return nil, nil
+}
+
+func TestJSONModeProviderExposesConfig(t *testing.T) {
+	config := &Config{Type: "openai", ModelName: "qwen-plus", MaxTokens: 512}
+	jsonMode := NewJSONModeProvider(&stubProvider{BaseProvider: NewBaseProvider(config)}, 0)
+
+	tests := []struct {
+		name     string
+		provider Provider
+	}{
+		{name: "JSON模式", provider: jsonMode},
+		{name: "故障转移包装JSON模式", provider: NewFailoverProvider(jsonMode, nil, FailoverPolicy{})},
+		{name: "故障注入包装JSON模式", provider: NewFaultProvider(jsonMode, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getter, ok := tt.provider.(interface{ Config() *Config })
+			if !ok {
+				t.Fatal("provider does not expose Config()")
+			}
+			if got := getter.Config(); got != config {
+				t.Errorf("Config() = %+v, want the wrapped provider's config", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package llamacpp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/core/providers/llm"
+	openaillm "xiaozhi-server-go/src/core/providers/llm/openai"
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBaseURL = "http://127.0.0.1:8080"
+
+	endpointChat       = "chat"       // /v1/chat/completions，由服务端按模型模板拼接对话
+	endpointCompletion = "completion" // /completion，本地按ChatML拼接提示词
+)
+
+// Provider llama.cpp server LLM提供者，适用于完全离线的推理设备
+type Provider struct {
+	*llm.BaseProvider
+	client    *http.Client
+	baseURL   string
+	endpoint  string
+	grammar   string
+	stop      []string
+	maxTokens int
+}
+
+// 注册提供者
+func init() {
+	llm.Register("llamacpp", NewProvider)
+}
+
+// NewProvider 创建llama.cpp提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	base := llm.NewBaseProvider(config)
+	provider := &Provider{
+		BaseProvider: base,
+		baseURL:      strings.TrimSuffix(config.BaseURL, "/"),
+		endpoint:     endpointChat,
+		maxTokens:    config.MaxTokens,
+	}
+	if provider.baseURL == "" {
+		provider.baseURL = defaultBaseURL
+	}
+	if provider.maxTokens <= 0 {
+		provider.maxTokens = 500
+	}
+	if v, ok := config.Extra["endpoint"].(string); ok && v != "" {
+		provider.endpoint = v
+	}
+	if v, ok := config.Extra["grammar"].(string); ok {
+		provider.grammar = v
+	}
+	if v, ok := config.Extra["stop"].([]interface{}); ok {
+		for _, s := range v {
+			if str, ok := s.(string); ok && str != "" {
+				provider.stop = append(provider.stop, str)
+			}
+		}
+	}
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	if p.endpoint != endpointChat && p.endpoint != endpointCompletion {
+		return fmt.Errorf("不支持的llama.cpp接口: %s", p.endpoint)
+	}
+	client, err := transport.NewHTTPClient(p.Config().Extra, 0)
+	if err != nil {
+		return fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	p.client = client
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	body, err := p.openStream(ctx, messages, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer body.Close()
+
+		err := p.readStream(ctx, body, func(chunk types.Response) {
+			if chunk.Content != "" {
+				responseChan <- chunk.Content
+			}
+		})
+		if err != nil {
+			logrus.WithError(err).Error("llama.cpp流式响应中断")
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+// completion 接口不支持原生工具调用，可配合 function_call_mode: json 使用
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	body, err := p.openStream(ctx, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer body.Close()
+
+		err := p.readStream(ctx, body, func(chunk types.Response) {
+			responseChan <- chunk
+		})
+		if err != nil {
+			responseChan <- types.Response{Error: err.Error(), Err: err}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// chatRequest /v1/chat/completions 请求体，grammar 为llama.cpp扩展字段
+type chatRequest struct {
+	Messages      []openai.ChatCompletionMessage `json:"messages"`
+	Tools         []openai.Tool                  `json:"tools,omitempty"`
+	Stream        bool                           `json:"stream"`
+	StreamOptions *openai.StreamOptions          `json:"stream_options,omitempty"`
+	MaxTokens     int                            `json:"max_tokens"`
+	Temperature   float64                        `json:"temperature,omitempty"`
+	TopP          float64                        `json:"top_p,omitempty"`
+	Stop          []string                       `json:"stop,omitempty"`
+	Grammar       string                         `json:"grammar,omitempty"`
+}
+
+// completionRequest /completion 请求体
+type completionRequest struct {
+	Prompt      string   `json:"prompt"`
+	Stream      bool     `json:"stream"`
+	NPredict    int      `json:"n_predict"`
+	Temperature float64  `json:"temperature,omitempty"`
+	TopP        float64  `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Grammar     string   `json:"grammar,omitempty"`
+	CachePrompt bool     `json:"cache_prompt"`
+}
+
+// openStream 按配置的接口发送请求，返回SSE响应体
+func (p *Provider) openStream(ctx context.Context, messages []types.Message, tools []openai.Tool) (io.ReadCloser, error) {
+	config := p.Config()
+
+	var path string
+	var payload interface{}
+	if p.endpoint == endpointCompletion {
+		path = "/completion"
+		payload = completionRequest{
+			Prompt:      buildChatMLPrompt(messages),
+			Stream:      true,
+			NPredict:    p.maxTokens,
+			Temperature: config.Temperature,
+			TopP:        config.TopP,
+			Stop:        append([]string{"<|im_end|>"}, p.stop...),
+			Grammar:     p.grammar,
+			CachePrompt: true,
+		}
+	} else {
+		path = "/v1/chat/completions"
+		payload = chatRequest{
+			Messages:      openaillm.ConvertMessages(messages),
+			Tools:         tools,
+			Stream:        true,
+			StreamOptions: openaillm.UsageStreamOptions(),
+			MaxTokens:     p.maxTokens,
+			Temperature:   config.Temperature,
+			TopP:          config.TopP,
+			Stop:          p.stop,
+			Grammar:       p.grammar,
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// llama-server 使用 --api-key 启动时需要鉴权
+	if config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, types.NewProviderError("llamacpp", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, types.NewHTTPError("llamacpp", resp.StatusCode, string(body))
+	}
+	return resp.Body, nil
+}
+
+// streamChunk 两种接口的流式数据块，只解析用到的字段
+type streamChunk struct {
+	// /completion
+	Content         string `json:"content"`
+	Stop            bool   `json:"stop"`
+	TokensEvaluated int    `json:"tokens_evaluated"`
+	TokensPredicted int    `json:"tokens_predicted"`
+	// /v1/chat/completions
+	Choices []struct {
+		Delta struct {
+			Content   string            `json:"content"`
+			ToolCalls []openai.ToolCall `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openai.Usage `json:"usage"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readStream 解析SSE数据块
+// 结束时通过上下文上报token用量
+func (p *Provider) readStream(ctx context.Context, body io.Reader, handle func(chunk types.Response)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logrus.WithError(err).Debug("llama.cpp数据块解析失败: " + data)
+			continue
+		}
+		if chunk.Error != nil {
+			return types.NewHTTPError("llamacpp", chunk.Error.Code, chunk.Error.Message)
+		}
+
+		if p.endpoint == endpointCompletion {
+			if chunk.Content != "" {
+				handle(types.Response{Content: chunk.Content})
+			}
+			if chunk.Stop {
+				types.ReportUsage(ctx, types.Usage{
+					Model:            p.Config().ModelName,
+					PromptTokens:     chunk.TokensEvaluated,
+					CompletionTokens: chunk.TokensPredicted,
+				})
+				return nil
+			}
+			continue
+		}
+
+		if chunk.Usage != nil {
+			types.ReportUsage(ctx, types.Usage{
+				Model:            p.Config().ModelName,
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+			})
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		resp := types.Response{Content: delta.Content}
+		for _, tc := range delta.ToolCalls {
+			resp.ToolCalls = append(resp.ToolCalls, types.ToolCall{
+				ID:   tc.ID,
+				Type: string(tc.Type),
+				Function: types.FunctionCall{
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				},
+			})
+		}
+		if resp.Content != "" || len(resp.ToolCalls) > 0 {
+			handle(resp)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return types.NewProviderError("llamacpp", err)
+	}
+	return nil
+}
+
+// buildChatMLPrompt 按ChatML模板拼接对话，供 /completion 接口使用
+func buildChatMLPrompt(messages []types.Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		role := msg.Role
+		if role == "tool" {
+			// 原始补全接口没有工具角色，作为用户侧的补充信息
+			role = "user"
+		}
+		sb.WriteString("<|im_start|>")
+		sb.WriteString(role)
+		sb.WriteString("\n")
+		sb.WriteString(msg.Content)
+		sb.WriteString("<|im_end|>\n")
+	}
+	sb.WriteString("<|im_start|>assistant\n")
+	return sb.String()
+}
@@ -18,6 +18,20 @@ type Config struct {
 	Extra       map[string]interface{} `yaml:",inline"`
 }
 
+// IntExtra 读取额外配置中的整数，缺省或类型不符时返回默认值。
+// 从数据库或接口下发的JSON配置解码后数字为float64，需要兼容
+func (c *Config) IntExtra(key string, def int) int {
+	switch v := c.Extra[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return def
+}
+
 // Provider LLM提供者接口
 type Provider interface {
 	types.LLMProvider
@@ -78,5 +92,10 @@ func Create(name string, config *Config) (Provider, error) {
 		return nil, fmt.Errorf("初始化LLM提供者失败: %v", err)
 	}
 
+	// 不支持原生函数调用的模型，可通过 function_call_mode: json 启用JSON模式
+	if mode, _ := config.Extra["function_call_mode"].(string); mode == FunctionCallModeJSON {
+		provider = NewJSONModeProvider(provider, config.IntExtra("json_mode_retries", defaultJSONModeRetries))
+	}
+
 	return provider, nil
 }
@@ -0,0 +1,34 @@
+package llm
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIntExtra(t *testing.T) {
+	var fromJSON map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"json_mode_retries": 3}`), &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		extra    map[string]interface{}
+		expected int
+	}{
+		{name: "YAML整数", extra: map[string]interface{}{"json_mode_retries": 4}, expected: 4},
+		{name: "JSON数字", extra: fromJSON, expected: 3},
+		{name: "int64", extra: map[string]interface{}{"json_mode_retries": int64(1)}, expected: 1},
+		{name: "未配置", extra: nil, expected: defaultJSONModeRetries},
+		{name: "类型不符", extra: map[string]interface{}{"json_mode_retries": "5"}, expected: defaultJSONModeRetries},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Extra: tt.extra}
+			if got := config.IntExtra("json_mode_retries", defaultJSONModeRetries); got != tt.expected {
+				t.Errorf("IntExtra() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
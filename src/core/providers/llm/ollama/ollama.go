@@ -2,12 +2,17 @@ package ollama
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"xiaozhi-server-go/src/core/providers/llm"
+	openaillm "xiaozhi-server-go/src/core/providers/llm/openai"
+	"xiaozhi-server-go/src/core/providers/transport"
 	"xiaozhi-server-go/src/core/types"
 
 	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
 )
 
 // Provider Ollama LLM提供者
@@ -60,6 +65,12 @@ func (p *Provider) Initialize() error {
 	clientConfig := openai.DefaultConfig("ollama")
 	clientConfig.BaseURL = baseURL
 
+	httpClient, err := transport.NewHTTPClient(config.Extra, 0)
+	if err != nil {
+		return fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	clientConfig.HTTPClient = httpClient
+
 	p.client = openai.NewClientWithConfig(clientConfig)
 	return nil
 }
@@ -71,37 +82,15 @@ func (p *Provider) Cleanup() error {
 
 // Response types.LLMProvider接口实现
 func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	stream, err := p.createStream(ctx, messages, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	responseChan := make(chan string, 10)
 
 	go func() {
 		defer close(responseChan)
-
-		// 如果是qwen3模型，在用户最后一条消息中添加/no_think指令
-		if p.isQwen3 {
-			messages = p.addNoThinkDirective(messages)
-		}
-
-		// 转换消息格式
-		chatMessages := make([]openai.ChatCompletionMessage, len(messages))
-		for i, msg := range messages {
-			chatMessages[i] = openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
-			}
-		}
-
-		stream, err := p.client.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:    p.modelName,
-				Messages: chatMessages,
-				Stream:   true,
-			},
-		)
-		if err != nil {
-			responseChan <- fmt.Sprintf("【Ollama服务响应异常: %v】", err)
-			return
-		}
 		defer stream.Close()
 
 		isActive := true
@@ -110,8 +99,12 @@ func (p *Provider) Response(ctx context.Context, sessionID string, messages []ty
 		for {
 			response, err := stream.Recv()
 			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					logrus.WithError(types.NewProviderError("ollama", err)).Error("Ollama流式响应中断")
+				}
 				break
 			}
+			openaillm.ReportStreamUsage(ctx, response)
 
 			if len(response.Choices) > 0 {
 				content := response.Choices[0].Delta.Content
@@ -137,64 +130,15 @@ func (p *Provider) Response(ctx context.Context, sessionID string, messages []ty
 
 // ResponseWithFunctions types.LLMProvider接口实现
 func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	stream, err := p.createStream(ctx, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
 	responseChan := make(chan types.Response, 10)
 
 	go func() {
 		defer close(responseChan)
-
-		// 如果是qwen3模型，在用户最后一条消息中添加/no_think指令
-		if p.isQwen3 {
-			messages = p.addNoThinkDirective(messages)
-		}
-
-		// 转换消息格式
-		chatMessages := make([]openai.ChatCompletionMessage, len(messages))
-		for i, msg := range messages {
-			chatMessage := openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
-			}
-
-			// 处理tool_call_id字段（tool消息必需）
-			if msg.ToolCallID != "" {
-				chatMessage.ToolCallID = msg.ToolCallID
-			}
-
-			// 处理tool_calls字段（assistant消息中的工具调用）
-			if len(msg.ToolCalls) > 0 {
-				openaiToolCalls := make([]openai.ToolCall, len(msg.ToolCalls))
-				for j, tc := range msg.ToolCalls {
-					openaiToolCalls[j] = openai.ToolCall{
-						ID:   tc.ID,
-						Type: openai.ToolType(tc.Type),
-						Function: openai.FunctionCall{
-							Name:      tc.Function.Name,
-							Arguments: tc.Function.Arguments,
-						},
-					}
-				}
-				chatMessage.ToolCalls = openaiToolCalls
-			}
-
-			chatMessages[i] = chatMessage
-		}
-
-		stream, err := p.client.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:    p.modelName,
-				Messages: chatMessages,
-				Tools:    tools,
-				Stream:   true,
-			},
-		)
-		if err != nil {
-			responseChan <- types.Response{
-				Content: fmt.Sprintf("【Ollama服务响应异常: %v】", err),
-				Error:   err.Error(),
-			}
-			return
-		}
 		defer stream.Close()
 
 		isActive := true
@@ -203,8 +147,13 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 		for {
 			response, err := stream.Recv()
 			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					providerErr := types.NewProviderError("ollama", err)
+					responseChan <- types.Response{Error: providerErr.Error(), Err: providerErr}
+				}
 				break
 			}
+			openaillm.ReportStreamUsage(ctx, response)
 
 			if len(response.Choices) > 0 {
 				delta := response.Choices[0].Delta
@@ -251,6 +200,61 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 	return responseChan, nil
 }
 
+// createStream 转换消息格式并发起流式请求，失败时返回结构化错误
+func (p *Provider) createStream(ctx context.Context, messages []types.Message, tools []openai.Tool) (*openai.ChatCompletionStream, error) {
+	// 如果是qwen3模型，在用户最后一条消息中添加/no_think指令
+	if p.isQwen3 {
+		messages = p.addNoThinkDirective(messages)
+	}
+
+	// 转换消息格式
+	chatMessages := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		chatMessage := openai.ChatCompletionMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+
+		// 处理tool_call_id字段（tool消息必需）
+		if msg.ToolCallID != "" {
+			chatMessage.ToolCallID = msg.ToolCallID
+		}
+
+		// 处理tool_calls字段（assistant消息中的工具调用）
+		if len(msg.ToolCalls) > 0 {
+			openaiToolCalls := make([]openai.ToolCall, len(msg.ToolCalls))
+			for j, tc := range msg.ToolCalls {
+				openaiToolCalls[j] = openai.ToolCall{
+					ID:   tc.ID,
+					Type: openai.ToolType(tc.Type),
+					Function: openai.FunctionCall{
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					},
+				}
+			}
+			chatMessage.ToolCalls = openaiToolCalls
+		}
+
+		chatMessages[i] = chatMessage
+	}
+
+	stream, err := p.client.CreateChatCompletionStream(
+		ctx,
+		openai.ChatCompletionRequest{
+			Model:    p.modelName,
+			Messages: chatMessages,
+			Tools:         tools,
+			Stream:        true,
+			StreamOptions: openaillm.UsageStreamOptions(),
+		},
+	)
+	if err != nil {
+		return nil, types.NewProviderError("ollama", err)
+	}
+	return stream, nil
+}
+
 // addNoThinkDirective 为qwen3模型在用户最后一条消息中添加/no_think指令
 func (p *Provider) addNoThinkDirective(messages []types.Message) []types.Message {
 	// 复制消息列表
@@ -2,11 +2,15 @@ package openai
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/providers/transport"
 	"xiaozhi-server-go/src/core/types"
 
 	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
 )
 
 // Provider OpenAI LLM提供者
@@ -47,6 +51,12 @@ func (p *Provider) Initialize() error {
 		clientConfig.BaseURL = config.BaseURL
 	}
 
+	httpClient, err := transport.NewHTTPClient(config.Extra, 0)
+	if err != nil {
+		return fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	clientConfig.HTTPClient = httpClient
+
 	p.client = openai.NewClientWithConfig(clientConfig)
 	return nil
 }
@@ -58,64 +68,36 @@ func (p *Provider) Cleanup() error {
 
 // Response types.LLMProvider接口实现
 func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	stream, err := p.client.CreateChatCompletionStream(
+		ctx,
+		openai.ChatCompletionRequest{
+			Model:         p.Config().ModelName,
+			Messages:      ConvertMessages(messages),
+			Stream:        true,
+			StreamOptions: UsageStreamOptions(),
+			MaxTokens:     p.maxTokens,
+		},
+	)
+	if err != nil {
+		return nil, types.NewProviderError("openai", err)
+	}
+
 	responseChan := make(chan string, 10)
 
 	go func() {
 		defer close(responseChan)
-
-		// 转换消息格式
-		chatMessages := make([]openai.ChatCompletionMessage, len(messages))
-		for i, msg := range messages {
-			chatMessage := openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
-			}
-
-			// 处理tool_call_id字段（tool消息必需）
-			if msg.ToolCallID != "" {
-				chatMessage.ToolCallID = msg.ToolCallID
-			}
-
-			// 处理tool_calls字段（assistant消息中的工具调用）
-			if len(msg.ToolCalls) > 0 {
-				openaiToolCalls := make([]openai.ToolCall, len(msg.ToolCalls))
-				for j, tc := range msg.ToolCalls {
-					openaiToolCalls[j] = openai.ToolCall{
-						ID:   tc.ID,
-						Type: openai.ToolType(tc.Type),
-						Function: openai.FunctionCall{
-							Name:      tc.Function.Name,
-							Arguments: tc.Function.Arguments,
-						},
-					}
-				}
-				chatMessage.ToolCalls = openaiToolCalls
-			}
-
-			chatMessages[i] = chatMessage
-		}
-
-		stream, err := p.client.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:     p.Config().ModelName,
-				Messages:  chatMessages,
-				Stream:    true,
-				MaxTokens: p.maxTokens,
-			},
-		)
-		if err != nil {
-			responseChan <- fmt.Sprintf("【OpenAI服务响应异常: %v】", err)
-			return
-		}
 		defer stream.Close()
 
 		isActive := true
 		for {
 			response, err := stream.Recv()
 			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					logrus.WithError(types.NewProviderError("openai", err)).Error("OpenAI流式响应中断")
+				}
 				break
 			}
+			ReportStreamUsage(ctx, response)
 
 			if len(response.Choices) > 0 {
 				content := response.Choices[0].Delta.Content
@@ -134,66 +116,36 @@ func (p *Provider) Response(ctx context.Context, sessionID string, messages []ty
 
 // ResponseWithFunctions types.LLMProvider接口实现
 func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	stream, err := p.client.CreateChatCompletionStream(
+		ctx,
+		openai.ChatCompletionRequest{
+			Model:         p.Config().ModelName,
+			Messages:      ConvertMessages(messages),
+			Tools:         tools,
+			Stream:        true,
+			StreamOptions: UsageStreamOptions(),
+		},
+	)
+	if err != nil {
+		return nil, types.NewProviderError("openai", err)
+	}
+
 	responseChan := make(chan types.Response, 10)
 
 	go func() {
 		defer close(responseChan)
-
-		// 转换消息格式
-		chatMessages := make([]openai.ChatCompletionMessage, len(messages))
-		for i, msg := range messages {
-			chatMessage := openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
-			}
-
-			// 处理tool_call_id字段（tool消息必需）
-			if msg.ToolCallID != "" {
-				chatMessage.ToolCallID = msg.ToolCallID
-			}
-
-			// 处理tool_calls字段（assistant消息中的工具调用）
-			if len(msg.ToolCalls) > 0 {
-				openaiToolCalls := make([]openai.ToolCall, len(msg.ToolCalls))
-				for j, tc := range msg.ToolCalls {
-					openaiToolCalls[j] = openai.ToolCall{
-						ID:   tc.ID,
-						Type: openai.ToolType(tc.Type),
-						Function: openai.FunctionCall{
-							Name:      tc.Function.Name,
-							Arguments: tc.Function.Arguments,
-						},
-					}
-				}
-				chatMessage.ToolCalls = openaiToolCalls
-			}
-
-			chatMessages[i] = chatMessage
-		}
-
-		stream, err := p.client.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:    p.Config().ModelName,
-				Messages: chatMessages,
-				Tools:    tools,
-				Stream:   true,
-			},
-		)
-		if err != nil {
-			responseChan <- types.Response{
-				Content: fmt.Sprintf("【OpenAI服务响应异常: %v】", err),
-				Error:   err.Error(),
-			}
-			return
-		}
 		defer stream.Close()
 
 		for {
 			response, err := stream.Recv()
 			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					providerErr := types.NewProviderError("openai", err)
+					responseChan <- types.Response{Error: providerErr.Error(), Err: providerErr}
+				}
 				break
 			}
+			ReportStreamUsage(ctx, response)
 
 			if len(response.Choices) > 0 {
 				delta := response.Choices[0].Delta
@@ -225,6 +177,58 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 	return responseChan, nil
 }
 
+// UsageStreamOptions 请求在流式响应的最后一个数据块中返回token用量
+func UsageStreamOptions() *openai.StreamOptions {
+	return &openai.StreamOptions{IncludeUsage: true}
+}
+
+// ReportStreamUsage 上报流式响应数据块中携带的token用量
+func ReportStreamUsage(ctx context.Context, response openai.ChatCompletionStreamResponse) {
+	if response.Usage == nil {
+		return
+	}
+	types.ReportUsage(ctx, types.Usage{
+		Model:            response.Model,
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+	})
+}
+
+// ConvertMessages 转换为OpenAI消息格式，供兼容OpenAI接口的提供者复用
+func ConvertMessages(messages []types.Message) []openai.ChatCompletionMessage {
+	chatMessages := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		chatMessage := openai.ChatCompletionMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+
+		// 处理tool_call_id字段（tool消息必需）
+		if msg.ToolCallID != "" {
+			chatMessage.ToolCallID = msg.ToolCallID
+		}
+
+		// 处理tool_calls字段（assistant消息中的工具调用）
+		if len(msg.ToolCalls) > 0 {
+			openaiToolCalls := make([]openai.ToolCall, len(msg.ToolCalls))
+			for j, tc := range msg.ToolCalls {
+				openaiToolCalls[j] = openai.ToolCall{
+					ID:   tc.ID,
+					Type: openai.ToolType(tc.Type),
+					Function: openai.FunctionCall{
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					},
+				}
+			}
+			chatMessage.ToolCalls = openaiToolCalls
+		}
+
+		chatMessages[i] = chatMessage
+	}
+	return chatMessages
+}
+
 // handleThinkTags 处理思考标签
 func handleThinkTags(content string, isActive bool) (string, bool) {
 	if content == "" {
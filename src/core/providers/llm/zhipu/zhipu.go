@@ -0,0 +1,265 @@
+package zhipu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers/llm"
+	openaillm "xiaozhi-server-go/src/core/providers/llm/openai"
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBaseURL = "https://open.bigmodel.cn/api/paas/v4"
+	tokenTTL       = 30 * time.Minute
+	tokenRefresh   = time.Minute // 过期前提前刷新
+)
+
+// Provider 智谱GLM LLM提供者，接口兼容OpenAI，鉴权使用API Key签发的JWT
+type Provider struct {
+	*llm.BaseProvider
+	client    *openai.Client
+	maxTokens int
+}
+
+// 注册提供者
+func init() {
+	llm.Register("zhipu", NewProvider)
+}
+
+// NewProvider 创建智谱提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	base := llm.NewBaseProvider(config)
+	provider := &Provider{
+		BaseProvider: base,
+		maxTokens:    config.MaxTokens,
+	}
+	if provider.maxTokens <= 0 {
+		provider.maxTokens = 500
+	}
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	config := p.Config()
+	if config.APIKey == "" {
+		return fmt.Errorf("missing Zhipu API key")
+	}
+
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	clientConfig.BaseURL = defaultBaseURL
+	if config.BaseURL != "" {
+		clientConfig.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	}
+	httpClient, err := transport.NewHTTPClient(config.Extra, 0)
+	if err != nil {
+		return fmt.Errorf("创建HTTP客户端失败: %v", err)
+	}
+	clientConfig.HTTPClient = httpClient
+	// API Key 格式为 id.secret 时签发JWT，否则直接作为Bearer令牌使用
+	if id, secret, ok := strings.Cut(config.APIKey, "."); ok {
+		clientConfig.HTTPClient = &jwtClient{
+			client: httpClient,
+			id:     id,
+			secret: []byte(secret),
+		}
+	}
+
+	p.client = openai.NewClientWithConfig(clientConfig)
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	stream, err := p.client.CreateChatCompletionStream(ctx, p.newRequest(messages, nil))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer stream.Close()
+
+		for {
+			response, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					logrus.WithError(wrapError(err)).Error("智谱流式响应中断")
+				}
+				break
+			}
+			openaillm.ReportStreamUsage(ctx, response)
+			if len(response.Choices) == 0 {
+				continue
+			}
+			choice := response.Choices[0]
+			if choice.Delta.Content != "" {
+				responseChan <- choice.Delta.Content
+			}
+			if choice.FinishReason == finishReasonSensitive {
+				logrus.WithError(contentFilterError()).Warn("智谱回复被安全策略拦截")
+				break
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	stream, err := p.client.CreateChatCompletionStream(ctx, p.newRequest(messages, tools))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+		defer stream.Close()
+
+		for {
+			response, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					providerErr := wrapError(err)
+					responseChan <- types.Response{Error: providerErr.Error(), Err: providerErr}
+				}
+				break
+			}
+			openaillm.ReportStreamUsage(ctx, response)
+			if len(response.Choices) == 0 {
+				continue
+			}
+
+			choice := response.Choices[0]
+			chunk := types.Response{Content: choice.Delta.Content}
+			for _, tc := range choice.Delta.ToolCalls {
+				chunk.ToolCalls = append(chunk.ToolCalls, types.ToolCall{
+					ID:   tc.ID,
+					Type: string(tc.Type),
+					Function: types.FunctionCall{
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					},
+				})
+			}
+			if chunk.Content != "" || len(chunk.ToolCalls) > 0 {
+				responseChan <- chunk
+			}
+			if choice.FinishReason == finishReasonSensitive {
+				filterErr := contentFilterError()
+				responseChan <- types.Response{Error: filterErr.Error(), Err: filterErr}
+				break
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// newRequest 构造流式请求
+func (p *Provider) newRequest(messages []types.Message, tools []openai.Tool) openai.ChatCompletionRequest {
+	config := p.Config()
+	return openai.ChatCompletionRequest{
+		Model:         config.ModelName,
+		Messages:      openaillm.ConvertMessages(messages),
+		Tools:         tools,
+		Stream:        true,
+		StreamOptions: openaillm.UsageStreamOptions(),
+		MaxTokens:     p.maxTokens,
+		Temperature:   float32(config.Temperature),
+		TopP:          float32(config.TopP),
+	}
+}
+
+// finishReasonSensitive 智谱内容安全审核拦截时的结束原因
+const finishReasonSensitive openai.FinishReason = "sensitive"
+
+// contentFilterCode 智谱内容安全审核未通过的错误码
+const contentFilterCode = "1301"
+
+// wrapError 转换为ProviderError，内容安全错误单独标记
+func wrapError(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && fmt.Sprint(apiErr.Code) == contentFilterCode {
+		return &types.ProviderError{Kind: types.ErrorKindContentFilter, Provider: "zhipu", Err: err}
+	}
+	return types.NewProviderError("zhipu", err)
+}
+
+func contentFilterError() error {
+	return &types.ProviderError{
+		Kind:     types.ErrorKindContentFilter,
+		Provider: "zhipu",
+		Err:      fmt.Errorf("内容被安全策略拦截"),
+	}
+}
+
+// jwtClient 为每个请求附加由API Key签发的JWT，令牌临近过期时重新签发
+type jwtClient struct {
+	client *http.Client
+	id     string
+	secret []byte
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// Do openai.HTTPDoer接口实现
+func (c *jwtClient) Do(req *http.Request) (*http.Response, error) {
+	token, err := c.getToken()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return c.client.Do(req)
+}
+
+func (c *jwtClient) getToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.token != "" && now.Add(tokenRefresh).Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	expiresAt := now.Add(tokenTTL)
+	// 智谱要求 exp 和 timestamp 使用毫秒时间戳
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"api_key":   c.id,
+		"exp":       expiresAt.UnixMilli(),
+		"timestamp": now.UnixMilli(),
+	})
+	token.Header["sign_type"] = "SIGN"
+
+	signed, err := token.SignedString(c.secret)
+	if err != nil {
+		return "", fmt.Errorf("签发智谱鉴权令牌失败: %v", err)
+	}
+	c.token = signed
+	c.expiresAt = expiresAt
+	return signed, nil
+}
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	transportsMu sync.Mutex
+	transports   = make(map[string]*http.Transport)
+)
+
+// NewHTTPClient 按提供者配置创建HTTP客户端，未配置 tls、proxy 段时与默认客户端相同
+// 相同配置的客户端共享连接池
+func NewHTTPClient(extra map[string]interface{}, timeout time.Duration) (*http.Client, error) {
+	tlsOpts := ParseTLSOptions(extra)
+	proxyOpts := ParseProxyOptions(extra)
+	if tlsOpts == nil && proxyOpts == nil {
+		return &http.Client{Timeout: timeout}, nil
+	}
+
+	key := proxyOpts.key()
+	if tlsOpts != nil {
+		key += "|" + tlsOpts.key()
+	}
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	t, ok := transports[key]
+	if !ok {
+		tlsConfig, err := NewTLSConfig(tlsOpts)
+		if err != nil {
+			return nil, err
+		}
+		proxy, err := ProxyFunc(proxyOpts)
+		if err != nil {
+			return nil, err
+		}
+		t = http.DefaultTransport.(*http.Transport).Clone()
+		if tlsConfig != nil {
+			t.TLSClientConfig = tlsConfig
+		}
+		t.Proxy = proxy
+		transports[key] = t
+	}
+	return &http.Client{Transport: t, Timeout: timeout}, nil
+}
+
+// NewDialer 按提供者配置创建WebSocket拨号器，未配置 tls、proxy 段时与默认拨号器相同
+func NewDialer(extra map[string]interface{}) (*websocket.Dialer, error) {
+	dialer := *websocket.DefaultDialer
+	tlsConfig, err := NewTLSConfig(ParseTLSOptions(extra))
+	if err != nil {
+		return nil, err
+	}
+	dialer.TLSClientConfig = tlsConfig
+	if proxyOpts := ParseProxyOptions(extra); proxyOpts != nil {
+		if dialer.Proxy, err = ProxyFunc(proxyOpts); err != nil {
+			return nil, err
+		}
+	}
+	return &dialer, nil
+}
@@ -0,0 +1,211 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reloadCheckInterval 检查证书文件是否更新的最小间隔
+const reloadCheckInterval = 10 * time.Second
+
+// TLSOptions 提供者连接上游时的TLS配置，来自提供者配置中的 tls 段
+type TLSOptions struct {
+	CAFile             string // 自定义CA证书（PEM），在系统根证书基础上追加，用于企业代理或自签名的推理服务
+	CertFile           string // 客户端证书（PEM），用于mTLS
+	KeyFile            string // 客户端私钥（PEM）
+	ServerName         string // 覆盖证书校验使用的服务器名称
+	InsecureSkipVerify bool   // 跳过服务端证书校验，仅用于调试
+}
+
+// ParseTLSOptions 从提供者配置中解析 tls 段，未配置时返回nil
+func ParseTLSOptions(extra map[string]interface{}) *TLSOptions {
+	section, ok := extra["tls"].(map[string]interface{})
+	if !ok || len(section) == 0 {
+		return nil
+	}
+	str := func(key string) string {
+		v, _ := section[key].(string)
+		return v
+	}
+	insecure, _ := section["insecure_skip_verify"].(bool)
+	return &TLSOptions{
+		CAFile:             str("ca_file"),
+		CertFile:           str("cert_file"),
+		KeyFile:            str("key_file"),
+		ServerName:         str("server_name"),
+		InsecureSkipVerify: insecure,
+	}
+}
+
+// key 相同配置的提供者共享证书重载器
+func (o TLSOptions) key() string {
+	return fmt.Sprintf("%s|%s|%s|%s|%t", o.CAFile, o.CertFile, o.KeyFile, o.ServerName, o.InsecureSkipVerify)
+}
+
+var (
+	reloadersMu sync.Mutex
+	reloaders   = make(map[string]*certReloader)
+)
+
+// NewTLSConfig 按配置创建TLS配置，证书文件更新后新建立的连接自动使用新证书，无需重启
+func NewTLSConfig(opts *TLSOptions) (*tls.Config, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("客户端证书 cert_file 和私钥 key_file 需要同时配置")
+	}
+
+	reloadersMu.Lock()
+	r, ok := reloaders[opts.key()]
+	if !ok {
+		r = &certReloader{opts: *opts, modTimes: make(map[string]time.Time)}
+		if err := r.load(); err != nil {
+			reloadersMu.Unlock()
+			return nil, err
+		}
+		reloaders[opts.key()] = r
+	}
+	reloadersMu.Unlock()
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+	if opts.CertFile != "" {
+		config.GetClientCertificate = r.clientCertificate
+	}
+	if opts.CAFile != "" && !opts.InsecureSkipVerify {
+		// 标准校验使用的 RootCAs 创建后不能替换，改为在 VerifyConnection 中用当前的CA校验
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = r.verifyConnection
+	}
+	return config, nil
+}
+
+// certReloader 加载并按文件修改时间重载CA和客户端证书
+type certReloader struct {
+	opts TLSOptions
+
+	mu        sync.RWMutex
+	roots     *x509.CertPool
+	cert      *tls.Certificate
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+// load 读取证书文件
+func (r *certReloader) load() error {
+	var roots *x509.CertPool
+	if r.opts.CAFile != "" {
+		pem, err := os.ReadFile(r.opts.CAFile)
+		if err != nil {
+			return fmt.Errorf("读取CA证书失败: %v", err)
+		}
+		roots, err = x509.SystemCertPool()
+		if err != nil || roots == nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("CA证书 %s 中没有有效的PEM证书", r.opts.CAFile)
+		}
+	}
+
+	var cert *tls.Certificate
+	if r.opts.CertFile != "" {
+		loaded, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+		if err != nil {
+			return fmt.Errorf("加载客户端证书失败: %v", err)
+		}
+		cert = &loaded
+	}
+
+	r.mu.Lock()
+	r.roots, r.cert = roots, cert
+	for _, file := range r.files() {
+		if info, err := os.Stat(file); err == nil {
+			r.modTimes[file] = info.ModTime()
+		}
+	}
+	r.lastCheck = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) files() []string {
+	var files []string
+	for _, file := range []string{r.opts.CAFile, r.opts.CertFile, r.opts.KeyFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// maybeReload 距上次检查超过间隔且文件有更新时重载，失败时继续使用旧证书
+func (r *certReloader) maybeReload() {
+	r.mu.Lock()
+	if time.Since(r.lastCheck) < reloadCheckInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.lastCheck = time.Now()
+	changed := false
+	for _, file := range r.files() {
+		if info, err := os.Stat(file); err == nil && !info.ModTime().Equal(r.modTimes[file]) {
+			changed = true
+		}
+	}
+	r.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if err := r.load(); err != nil {
+		logrus.WithError(err).Warn("重新加载TLS证书失败，继续使用旧证书")
+		return
+	}
+	logrus.WithField("files", r.files()).Info("TLS证书已重新加载")
+}
+
+// clientCertificate tls.Config.GetClientCertificate 实现
+func (r *certReloader) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// verifyConnection 使用当前的CA校验服务端证书链和主机名
+func (r *certReloader) verifyConnection(cs tls.ConnectionState) error {
+	r.maybeReload()
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("服务端未提供证书")
+	}
+	r.mu.RLock()
+	roots := r.roots
+	r.mu.RUnlock()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	serverName := r.opts.ServerName
+	if serverName == "" {
+		serverName = cs.ServerName
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       serverName,
+		Intermediates: intermediates,
+	})
+	return err
+}
@@ -3,6 +3,7 @@ package doubao
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -11,12 +12,17 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/types"
+
+	"xiaozhi-server-go/src/core/providers/transport"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -67,13 +73,113 @@ func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
 
 // ToTTS 实现文本到语音的转换
 func (p *Provider) ToTTS(text string) (string, error) {
+	conn, err := p.sendRequest(text)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	// 创建临时文件
+	outputDir := p.Config().OutputDir
+	if outputDir == "" {
+		outputDir = "tmp"
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("创建输出目录失败: %v", err)
+	}
+
+	tempFile := filepath.Join(outputDir, fmt.Sprintf("doubao_tts_%d.mp3", time.Now().UnixNano()))
+	var audioData []byte
+
+	// 接收音频数据
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return "", types.NewProviderError("doubao", fmt.Errorf("接收响应失败: %w", err))
+		}
+
+		resp, err := p.parseResponse(message)
+		if err != nil {
+			return "", types.NewProviderError("doubao", fmt.Errorf("解析响应失败: %w", err))
+		}
+
+		audioData = append(audioData, resp.Audio...)
+		if resp.IsLast {
+			break
+		}
+	}
+
+	// 写入音频文件
+	if err := os.WriteFile(tempFile, audioData, 0644); err != nil {
+		return "", fmt.Errorf("写入音频文件失败: %v", err)
+	}
+
+	return tempFile, nil
+}
+
+// SupportsSSML 豆包TTS支持SSML输入
+func (p *Provider) SupportsSSML() bool {
+	return true
+}
+
+// SynthesizeStream 流式合成，服务端每返回一段音频即写入通道
+func (p *Provider) SynthesizeStream(ctx context.Context, text string) (<-chan []byte, error) {
+	conn, err := p.sendRequest(text)
+	if err != nil {
+		return nil, err
+	}
+
+	audioChan := make(chan []byte, 32)
+	go func() {
+		defer close(audioChan)
+		defer conn.Close()
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				logrus.WithError(err).Error("豆包TTS接收响应失败")
+				return
+			}
+
+			resp, err := p.parseResponse(message)
+			if err != nil {
+				logrus.WithError(err).Error("豆包TTS解析响应失败")
+				return
+			}
+
+			if len(resp.Audio) > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case audioChan <- resp.Audio:
+				}
+			}
+			if resp.IsLast {
+				return
+			}
+		}
+	}()
+
+	return audioChan, nil
+}
+
+// sendRequest 建立WebSocket连接并发送合成请求，调用方负责关闭连接
+func (p *Provider) sendRequest(text string) (*websocket.Conn, error) {
 	// 创建WebSocket连接
 	header := http.Header{"Authorization": []string{fmt.Sprintf("Bearer;%s", p.Config().Token)}}
-	conn, _, err := websocket.DefaultDialer.Dial(p.baseURL, header)
+	dialer, err := transport.NewDialer(p.Config().Extra)
 	if err != nil {
-		return "", fmt.Errorf("连接WebSocket服务器失败: %v", err)
+		return nil, err
+	}
+	conn, resp, err := dialer.Dial(p.baseURL, header)
+	if err != nil {
+		return nil, types.NewDialError("doubao", resp, err)
+	}
+
+	textType := "plain"
+	if strings.HasPrefix(strings.TrimSpace(text), "<speak>") {
+		textType = "ssml"
 	}
-	defer conn.Close()
 
 	// 准备请求参数
 	reqParams := map[string]map[string]interface{}{
@@ -88,14 +194,14 @@ func (p *Provider) ToTTS(text string) (string, error) {
 		"audio": {
 			"voice_type":   p.Config().Voice,
 			"encoding":     "mp3",
-			"speed_ratio":  1.0,
+			"speed_ratio":  p.SpeedRatio(),
 			"volume_ratio": 1.0,
 			"pitch_ratio":  1.0,
 		},
 		"request": {
 			"reqid":     uuid.New().String(),
 			"text":      text,
-			"text_type": "plain",
+			"text_type": textType,
 			"operation": "submit", // 使用流式合成
 		},
 	}
@@ -103,13 +209,15 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	// 序列化并压缩请求参数
 	jsonData, err := json.Marshal(reqParams)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求参数失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("序列化请求参数失败: %v", err)
 	}
 
 	var b bytes.Buffer
 	w := gzip.NewWriter(&b)
 	if _, err := w.Write(jsonData); err != nil {
-		return "", fmt.Errorf("压缩请求数据失败: %v", err)
+		conn.Close()
+		return nil, fmt.Errorf("压缩请求数据失败: %v", err)
 	}
 	w.Close()
 	compressed := b.Bytes()
@@ -124,45 +232,11 @@ func (p *Provider) ToTTS(text string) (string, error) {
 
 	// 发送请求
 	if err := conn.WriteMessage(websocket.BinaryMessage, request); err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
-	}
-
-	// 创建临时文件
-	outputDir := p.Config().OutputDir
-	if outputDir == "" {
-		outputDir = "tmp"
-	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return "", fmt.Errorf("创建输出目录失败: %v", err)
-	}
-
-	tempFile := filepath.Join(outputDir, fmt.Sprintf("doubao_tts_%d.mp3", time.Now().UnixNano()))
-	var audioData []byte
-
-	// 接收音频数据
-	for {
-		_, message, err := conn.ReadMessage()
-		if err != nil {
-			return "", fmt.Errorf("接收响应失败: %v", err)
-		}
-
-		resp, err := p.parseResponse(message)
-		if err != nil {
-			return "", fmt.Errorf("解析响应失败: %v", err)
-		}
-
-		audioData = append(audioData, resp.Audio...)
-		if resp.IsLast {
-			break
-		}
-	}
-
-	// 写入音频文件
-	if err := os.WriteFile(tempFile, audioData, 0644); err != nil {
-		return "", fmt.Errorf("写入音频文件失败: %v", err)
+		conn.Close()
+		return nil, types.NewProviderError("doubao", fmt.Errorf("发送请求失败: %w", err))
 	}
 
-	return tempFile, nil
+	return conn, nil
 }
 
 // parseResponse 解析服务器响应
@@ -203,7 +277,11 @@ func (p *Provider) parseResponse(res []byte) (resp synResp, err error) {
 			}
 			r.Close()
 		}
-		return resp, fmt.Errorf("服务器错误 [%d]: %s", code, string(errMsg))
+		return resp, &types.ProviderError{
+			Kind:     errorKindOfCode(code),
+			Provider: "doubao",
+			Err:      fmt.Errorf("服务器错误 [%d]: %s", code, string(errMsg)),
+		}
 	default:
 		return resp, fmt.Errorf("未知的消息类型: %d", messageType)
 	}
@@ -211,6 +289,19 @@ func (p *Provider) parseResponse(res []byte) (resp synResp, err error) {
 	return resp, nil
 }
 
+// errorKindOfCode 按豆包TTS的错误码分类，见火山引擎语音合成接口文档
+func errorKindOfCode(code int32) types.ErrorKind {
+	switch code {
+	case 3003: // 并发超限
+		return types.ErrorKindQuota
+	case 3005, 3006, 3031, 3040: // 后端繁忙、服务中断、处理错误、后端链路错误
+		return types.ErrorKindServer
+	case 3030, 3032: // 处理超时、等待超时
+		return types.ErrorKindTimeout
+	}
+	return types.ErrorKindUnknown
+}
+
 func init() {
 	tts.Register("doubao", func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
 		return NewProvider(config, deleteFile)
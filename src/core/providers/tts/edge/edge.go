@@ -1,11 +1,13 @@
 package edge
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/types"
 
 	"github.com/wujunwei928/edge-tts-go/edge_tts"
 )
@@ -26,12 +28,7 @@ func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
 // ToTTS 将文本转换为音频文件，并返回文件路径
 // 使用的edge库是github.com/wujunwei928/edge-tts-go，默认使用24k采样率
 func (p *Provider) ToTTS(text string) (string, error) {
-	// 获取配置的声音，如果未配置则使用默认值
 	edgeTTSStartTime := time.Now()
-	voice := p.BaseProvider.Config().Voice
-	if voice == "" {
-		voice = "zh-CN-XiaoxiaoNeural" // 默认声音
-	}
 
 	// 创建临时文件路径用于保存 edgeTTS 生成的 MP3
 	outputDir := p.BaseProvider.Config().OutputDir
@@ -45,21 +42,9 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	// Use a unique filename
 	tempFile := filepath.Join(outputDir, fmt.Sprintf("edge_tts_go_%d.mp3", time.Now().UnixNano()))
 
-	// 配置 edge-tts-go 连接选项
-	connOptions := []edge_tts.CommunicateOption{
-		edge_tts.SetVoice(voice),
-	}
-
-	// 创建 Communicate 实例
-	conn, err := edge_tts.NewCommunicate(text, connOptions...)
-	if err != nil {
-		return "", fmt.Errorf("创建 edge-tts-go Communicate 失败: %v", err)
-	}
-
-	// 获取音频流数据
-	audioData, err := conn.Stream()
+	audioData, err := p.synthesize(text)
 	if err != nil {
-		return "", fmt.Errorf("edge-tts-go 获取音频流失败: %v", err)
+		return "", err
 	}
 
 	ttsDuration := time.Since(edgeTTSStartTime)
@@ -82,6 +67,43 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	return tempFile, nil
 }
 
+// SynthesizeStream 流式合成，edge-tts-go 不支持分块回调，合成完成后按块输出MP3数据
+func (p *Provider) SynthesizeStream(ctx context.Context, text string) (<-chan []byte, error) {
+	audioData, err := p.synthesize(text)
+	if err != nil {
+		return nil, err
+	}
+	return tts.NewBytesStream(ctx, audioData), nil
+}
+
+// synthesize 调用 edge-tts-go 合成MP3音频数据
+func (p *Provider) synthesize(text string) ([]byte, error) {
+	// 获取配置的声音，如果未配置则使用默认值
+	voice := p.BaseProvider.Config().Voice
+	if voice == "" {
+		voice = "zh-CN-XiaoxiaoNeural" // 默认声音
+	}
+
+	// 配置 edge-tts-go 连接选项
+	connOptions := []edge_tts.CommunicateOption{
+		edge_tts.SetVoice(voice),
+		edge_tts.SetRate(fmt.Sprintf("%+d%%", int((p.SpeedRatio()-1)*100))), // 语速，如 +20%
+	}
+
+	// 创建 Communicate 实例
+	conn, err := edge_tts.NewCommunicate(text, connOptions...)
+	if err != nil {
+		return nil, types.NewProviderError("edge", fmt.Errorf("创建 edge-tts-go Communicate 失败: %w", err))
+	}
+
+	// 获取音频流数据
+	audioData, err := conn.Stream()
+	if err != nil {
+		return nil, types.NewProviderError("edge", fmt.Errorf("edge-tts-go 获取音频流失败: %w", err))
+	}
+	return audioData, nil
+}
+
 func init() {
 	// 注册Edge TTS提供者
 	tts.Register("edge", func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
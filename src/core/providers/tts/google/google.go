@@ -0,0 +1,195 @@
+package google
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/types"
+
+	"xiaozhi-server-go/src/core/providers/transport"
+)
+
+const defaultVoice = "cmn-CN-Wavenet-A"
+
+// synthesizeResponse Google Cloud TTS 合成响应
+type synthesizeResponse struct {
+	AudioContent string `json:"audioContent"`
+	Error        *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error,omitempty"`
+}
+
+// Provider Google Cloud TTS 提供者，支持 WaveNet/Neural2 音色及 SSML 输入
+type Provider struct {
+	*tts.BaseProvider
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewProvider 创建 Google Cloud TTS 提供者
+func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
+	base := tts.NewBaseProvider(config, deleteFile)
+	httpClient, err := transport.NewHTTPClient(config.Extra, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		BaseProvider: base,
+		baseURL:      "https://texttospeech.googleapis.com/v1/text:synthesize",
+		httpClient:   httpClient,
+	}, nil
+}
+
+// ToTTS 实现文本到语音的转换，文本以 <speak> 开头时按 SSML 处理
+func (p *Provider) ToTTS(text string) (string, error) {
+	audioData, err := p.synthesize(context.Background(), text)
+	if err != nil {
+		return "", err
+	}
+
+	// 创建临时文件
+	outputDir := p.Config().OutputDir
+	if outputDir == "" {
+		outputDir = "tmp"
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("创建输出目录失败: %v", err)
+	}
+
+	tempFile := filepath.Join(outputDir, fmt.Sprintf("google_tts_%d.mp3", time.Now().UnixNano()))
+	if err := os.WriteFile(tempFile, audioData, 0644); err != nil {
+		return "", fmt.Errorf("写入音频文件失败: %v", err)
+	}
+
+	return tempFile, nil
+}
+
+// SynthesizeStream 流式合成，Google 接口一次性返回完整音频，按块输出MP3数据；ctx 取消时中断请求
+func (p *Provider) SynthesizeStream(ctx context.Context, text string) (<-chan []byte, error) {
+	audioData, err := p.synthesize(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return tts.NewBytesStream(ctx, audioData), nil
+}
+
+// SupportsSSML Google Cloud TTS 支持SSML输入
+func (p *Provider) SupportsSSML() bool {
+	return true
+}
+
+// synthesize 调用 Google Cloud TTS 合成MP3音频数据
+func (p *Provider) synthesize(ctx context.Context, text string) ([]byte, error) {
+	if p.Config().Token == "" {
+		return nil, &types.ProviderError{Kind: types.ErrorKindAuth, Provider: "google", Err: fmt.Errorf("未配置 API Key(token)")}
+	}
+
+	voice := p.Config().Voice
+	if voice == "" {
+		voice = defaultVoice
+	}
+
+	input := map[string]string{}
+	if isSSML(text) {
+		input["ssml"] = text
+	} else {
+		input["text"] = text
+	}
+
+	reqBody := map[string]interface{}{
+		"input": input,
+		"voice": map[string]string{
+			"languageCode": languageCodeFromVoice(voice),
+			"name":         voice,
+		},
+		"audioConfig": map[string]interface{}{
+			"audioEncoding": "MP3",
+			"speakingRate":  p.SpeedRatio(),
+		},
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求参数失败: %v", err)
+	}
+
+	reqURL, err := url.Parse(p.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("解析接口地址失败: %v", err)
+	}
+	query := reqURL.Query()
+	query.Set("key", p.Config().Token)
+	reqURL.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL.String(), bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, types.NewProviderError("google", fmt.Errorf("发送请求失败: %w", err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewProviderError("google", fmt.Errorf("读取响应失败: %w", err))
+	}
+
+	var result synthesizeResponse
+	parseErr := json.Unmarshal(body, &result)
+	if resp.StatusCode != http.StatusOK || result.Error != nil {
+		statusCode, message := resp.StatusCode, string(body)
+		if result.Error != nil {
+			message = result.Error.Message
+			if result.Error.Code > 0 {
+				statusCode = result.Error.Code
+			}
+		}
+		return nil, types.NewHTTPError("google", statusCode, message)
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", parseErr)
+	}
+
+	audioData, err := base64.StdEncoding.DecodeString(result.AudioContent)
+	if err != nil {
+		return nil, fmt.Errorf("解码音频数据失败: %v", err)
+	}
+	return audioData, nil
+}
+
+// isSSML 判断文本是否为 SSML
+func isSSML(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "<speak>")
+}
+
+// languageCodeFromVoice 从音色名称中解析语言代码，如 cmn-CN-Wavenet-A -> cmn-CN
+func languageCodeFromVoice(voice string) string {
+	parts := strings.Split(voice, "-")
+	if len(parts) < 2 {
+		return "cmn-CN"
+	}
+	return parts[0] + "-" + parts[1]
+}
+
+func init() {
+	tts.Register("google", func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
+		return NewProvider(config, deleteFile)
+	})
+}
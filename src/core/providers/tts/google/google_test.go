@@ -0,0 +1,40 @@
+package google
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers/tts"
+)
+
+func TestSynthesizeStreamCancelsRequest(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	p, err := NewProvider(&tts.Config{Type: "google", Token: "key", OutputDir: t.TempDir()}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.baseURL = server.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := p.SynthesizeStream(ctx, "你好"); err == nil {
+		t.Fatal("SynthesizeStream succeeded after cancel")
+	}
+	// 取消后立即返回，不等待HTTP超时
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("SynthesizeStream returned after %s", elapsed)
+	}
+}
@@ -5,26 +5,37 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/types"
+
+	"xiaozhi-server-go/src/core/providers/transport"
 
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
 
+// pingTimeout 健康检查发送ping的超时时间
+const pingTimeout = 3 * time.Second
+
 // Provider Sherpa TTS提供者实现
 type Provider struct {
 	*tts.BaseProvider
-	conn *websocket.Conn
+	conn   *websocket.Conn
+	mu     sync.Mutex // 单个websocket连接上的请求与响应需要串行
+	broken error      // 请求失败时记录，之后连接不再可用
 }
 
 // NewProvider 创建Sherpa TTS提供者
 func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
 	base := tts.NewBaseProvider(config, deleteFile)
 
-	dialer := websocket.Dialer{
-		HandshakeTimeout: 10 * time.Second, // 设置握手超时
+	dialer, err := transport.NewDialer(config.Extra)
+	if err != nil {
+		return nil, err
 	}
+	dialer.HandshakeTimeout = 10 * time.Second // 设置握手超时
 	conn, _, err := dialer.DialContext(context.Background(), config.Cluster, map[string][]string{})
 	if err != nil {
 		return nil, err
@@ -53,11 +64,9 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	// Use a unique filename
 	tempFile := filepath.Join(outputDir, fmt.Sprintf("go_sherpa_tts_%d.wav", time.Now().UnixNano()))
 
-	p.conn.WriteMessage(websocket.TextMessage, []byte(text))
-	_, bytes, err := p.conn.ReadMessage()
-
+	bytes, err := p.synthesize(text)
 	if err != nil {
-		return "", fmt.Errorf("go-sherpa-tts 获取音频流失败: %v", err)
+		return "", err
 	}
 
 	ttsDuration := time.Since(SherpaTTSStartTime)
@@ -78,6 +87,60 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	return tempFile, nil
 }
 
+// SynthesizeStream 流式合成，sherpa 服务一次性返回WAV数据，按块输出
+func (p *Provider) SynthesizeStream(ctx context.Context, text string) (<-chan []byte, error) {
+	bytes, err := p.synthesize(text)
+	if err != nil {
+		return nil, err
+	}
+	return tts.NewBytesStream(ctx, bytes), nil
+}
+
+// synthesize 通过websocket请求sherpa服务合成WAV音频数据
+func (p *Provider) synthesize(text string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.broken != nil {
+		return nil, connectionError(fmt.Errorf("连接已断开: %w", p.broken))
+	}
+	if err := p.conn.WriteMessage(websocket.TextMessage, []byte(text)); err != nil {
+		p.broken = err
+		return nil, connectionError(fmt.Errorf("发送文本失败: %w", err))
+	}
+	_, bytes, err := p.conn.ReadMessage()
+	if err != nil {
+		p.broken = err
+		return nil, connectionError(fmt.Errorf("获取音频流失败: %w", err))
+	}
+	return bytes, nil
+}
+
+// connectionError 与sherpa服务的websocket连接出错，连接不会自动恢复，按网络错误分类以便切换到其他提供者
+func connectionError(err error) *types.ProviderError {
+	return &types.ProviderError{Kind: types.ErrorKindNetwork, Provider: "gosherpa", Err: err}
+}
+
+// HealthCheck 检查与sherpa服务的websocket连接，之前的请求失败或ping发送失败时返回错误
+func (p *Provider) HealthCheck() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.broken != nil {
+		return fmt.Errorf("go-sherpa-tts 连接已断开: %v", p.broken)
+	}
+	if err := p.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingTimeout)); err != nil {
+		p.broken = err
+		return fmt.Errorf("go-sherpa-tts 连接已断开: %v", err)
+	}
+	return nil
+}
+
+// Cleanup 关闭与sherpa服务的连接
+func (p *Provider) Cleanup() error {
+	p.conn.Close()
+	return p.BaseProvider.Cleanup()
+}
+
 func init() {
 	// 注册Sherpa TTS提供者
 	tts.Register("gosherpa", func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
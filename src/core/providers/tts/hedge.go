@@ -0,0 +1,136 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"os"
+	"unicode/utf8"
+
+	"xiaozhi-server-go/src/core/hedge"
+)
+
+// errEmptyStream 合成流没有输出任何数据就结束
+var errEmptyStream = errors.New("TTS未返回音频数据")
+
+// HedgeProvider 对短句合成发送对冲请求，主请求迟迟没有输出时再请求一次，使用先出声的结果
+type HedgeProvider struct {
+	Provider
+	policy hedge.Policy
+}
+
+// NewHedgeProvider 包装TTS提供者
+func NewHedgeProvider(provider Provider, policy hedge.Policy) *HedgeProvider {
+	return &HedgeProvider{
+		Provider: provider,
+		policy:   policy,
+	}
+}
+
+// Config 被包装提供者的配置
+func (p *HedgeProvider) Config() *Config {
+	if getter, ok := p.Provider.(interface{ Config() *Config }); ok {
+		return getter.Config()
+	}
+	return &Config{}
+}
+
+// SetSpeed 设置被包装提供者的语速
+func (p *HedgeProvider) SetSpeed(speed float64) error {
+	if setter, ok := p.Provider.(interface{ SetSpeed(float64) error }); ok {
+		return setter.SetSpeed(speed)
+	}
+	return nil
+}
+
+// SupportsSSML 被包装提供者是否支持SSML
+func (p *HedgeProvider) SupportsSSML() bool {
+	supporter, ok := p.Provider.(SSMLSupporter)
+	return ok && supporter.SupportsSSML()
+}
+
+// shouldHedge 只对短句对冲，长文本重复合成的成本过高
+func (p *HedgeProvider) shouldHedge(text string) bool {
+	return p.policy.MaxChars <= 0 || utf8.RuneCountInString(text) <= p.policy.MaxChars
+}
+
+// HealthCheck 检查被包装的提供者，providers.HealthChecker接口实现
+func (p *HedgeProvider) HealthCheck() error {
+	if checker, ok := p.Provider.(interface{ HealthCheck() error }); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// ToTTS 对冲合成音频文件，落后请求生成的文件会被删除
+func (p *HedgeProvider) ToTTS(text string) (string, error) {
+	if !p.shouldHedge(text) {
+		return p.Provider.ToTTS(text)
+	}
+	return hedge.Do(context.Background(), hedge.TargetTTS, p.policy, utf8.RuneCountInString(text),
+		func(ctx context.Context) (string, error) {
+			return p.Provider.ToTTS(text)
+		},
+		func(file string) {
+			os.Remove(file)
+		})
+}
+
+// hedgedStream 已收到首个数据块的合成流
+type hedgedStream struct {
+	first  []byte
+	rest   <-chan []byte
+	cancel context.CancelFunc
+}
+
+// SynthesizeStream 对冲流式合成，以首个音频数据块的到达时间决定使用哪个请求
+func (p *HedgeProvider) SynthesizeStream(ctx context.Context, text string) (<-chan []byte, error) {
+	if !p.shouldHedge(text) {
+		return p.Provider.SynthesizeStream(ctx, text)
+	}
+	stream, err := hedge.Do(ctx, hedge.TargetTTS, p.policy, utf8.RuneCountInString(text),
+		func(attemptCtx context.Context) (*hedgedStream, error) {
+			// 胜出的流在对冲结束后继续输出，不能使用对冲请求的context
+			streamCtx, cancel := context.WithCancel(ctx)
+			chunks, err := p.Provider.SynthesizeStream(streamCtx, text)
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			select {
+			case first, ok := <-chunks:
+				if !ok {
+					cancel()
+					return nil, errEmptyStream
+				}
+				return &hedgedStream{first: first, rest: chunks, cancel: cancel}, nil
+			case <-attemptCtx.Done():
+				cancel()
+				return nil, attemptCtx.Err()
+			}
+		},
+		func(s *hedgedStream) {
+			s.cancel()
+			for range s.rest {
+			}
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan []byte, 10)
+	go func() {
+		defer close(out)
+		defer stream.cancel()
+		out <- stream.first
+		for chunk := range stream.rest {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				for range stream.rest {
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
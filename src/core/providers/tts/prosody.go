@@ -0,0 +1,131 @@
+package tts
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 轻量韵律标记，供LLM在回复中控制停顿、重音和语速：
+//
+//	[pause 500ms]            停顿，支持 ms/s 单位
+//	[em]重点内容[/em]         重音强调
+//	[rate slow]慢慢说[/rate]  语速，取值 x-slow/slow/medium/fast/x-fast
+//
+// 标记中不含句读标点，流式分句时不会被截断。支持SSML的提供者会转换为SSML，其他提供者直接去除标记。
+
+// ProsodyPrompt 启用韵律标记时追加到系统提示词的说明
+const ProsodyPrompt = `
+[语音韵律标记]
+你的回复会被转换为语音，可以按需使用以下标记控制语气（不要滥用，不要使用其他标记）：
+- [pause 500ms] 停顿，时长单位为 ms 或 s
+- [em]文字[/em] 重读强调
+- [rate slow]文字[/rate] 调整语速，可选 x-slow、slow、medium、fast、x-fast`
+
+var (
+	reProsodyTag  = regexp.MustCompile(`\[(/?)(pause|em|rate)(?:\s+([^\[\]\s]+))?\]`)
+	rePauseTime   = regexp.MustCompile(`^\d+(ms|s)$`)
+	prosodyRates  = map[string]bool{"x-slow": true, "slow": true, "medium": true, "fast": true, "x-fast": true}
+	ssmlEscapeMap = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
+)
+
+// SSMLSupporter 支持SSML输入的TTS提供者实现该接口
+type SSMLSupporter interface {
+	SupportsSSML() bool
+}
+
+// HasProsodyMarkup 判断文本中是否包含韵律标记
+func HasProsodyMarkup(text string) bool {
+	return reProsodyTag.MatchString(text)
+}
+
+// StripProsodyMarkup 去除文本中的韵律标记
+func StripProsodyMarkup(text string) string {
+	return reProsodyTag.ReplaceAllString(text, "")
+}
+
+// MapProsodyText 对标记之外的普通文本执行 fn，标记本身保持不变
+func MapProsodyText(text string, fn func(string) string) string {
+	var builder strings.Builder
+	last := 0
+	for _, loc := range reProsodyTag.FindAllStringIndex(text, -1) {
+		builder.WriteString(fn(text[last:loc[0]]))
+		builder.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	builder.WriteString(fn(text[last:]))
+	return builder.String()
+}
+
+// ProsodyToSSML 将韵律标记转换为SSML，未闭合的标记在末尾自动闭合，多余的闭合标记被忽略
+func ProsodyToSSML(text string) string {
+	var builder strings.Builder
+	var stack []string // 已打开的SSML元素
+
+	closeTag := func(name string) {
+		builder.WriteString("</" + name + ">")
+	}
+
+	builder.WriteString("<speak>")
+	last := 0
+	for _, m := range reProsodyTag.FindAllStringSubmatchIndex(text, -1) {
+		builder.WriteString(ssmlEscapeMap.Replace(text[last:m[0]]))
+		last = m[1]
+
+		closing := m[3] > m[2]
+		tag := text[m[4]:m[5]]
+		value := ""
+		if m[6] >= 0 {
+			value = text[m[6]:m[7]]
+		}
+
+		element := "emphasis"
+		if tag == "rate" {
+			element = "prosody"
+		}
+
+		switch {
+		case tag == "pause":
+			if !closing && rePauseTime.MatchString(value) {
+				builder.WriteString(`<break time="` + value + `"/>`)
+			}
+		case closing:
+			// 关闭最近一个同名元素，其间未闭合的元素一并关闭
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] != element {
+					continue
+				}
+				for j := len(stack) - 1; j >= i; j-- {
+					closeTag(stack[j])
+				}
+				stack = stack[:i]
+				break
+			}
+		case tag == "em":
+			builder.WriteString(`<emphasis level="strong">`)
+			stack = append(stack, element)
+		case tag == "rate":
+			if !prosodyRates[value] {
+				value = "medium"
+			}
+			builder.WriteString(`<prosody rate="` + value + `">`)
+			stack = append(stack, element)
+		}
+	}
+	builder.WriteString(ssmlEscapeMap.Replace(text[last:]))
+	for i := len(stack) - 1; i >= 0; i-- {
+		closeTag(stack[i])
+	}
+	builder.WriteString("</speak>")
+	return builder.String()
+}
+
+// PrepareText 根据提供者能力处理韵律标记：支持SSML时转换为SSML，否则去除标记
+func PrepareText(provider interface{}, text string) string {
+	if !HasProsodyMarkup(text) {
+		return text
+	}
+	if supporter, ok := provider.(SSMLSupporter); ok && supporter.SupportsSSML() {
+		return ProsodyToSSML(text)
+	}
+	return StripProsodyMarkup(text)
+}
@@ -1,6 +1,7 @@
 package tts
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,22 +15,36 @@ import (
 
 // Config TTS配置结构
 type Config struct {
-	Type            string   `yaml:"type"`
-	OutputDir       string   `yaml:"output_dir"`
-	Voice           string   `yaml:"voice,omitempty"`
-	Format          string   `yaml:"format,omitempty"`
-	SampleRate      int      `yaml:"sample_rate,omitempty"`
-	AppID           string   `yaml:"appid"`
-	Token           string   `yaml:"token"`
-	Cluster         string   `yaml:"cluster"`
-	SurportedVoices []string `yaml:"surported_voices"` // 支持的语音列表
+	Type            string                 `yaml:"type"`
+	OutputDir       string                 `yaml:"output_dir"`
+	Voice           string                 `yaml:"voice,omitempty"`
+	Format          string                 `yaml:"format,omitempty"`
+	SampleRate      int                    `yaml:"sample_rate,omitempty"`
+	AppID           string                 `yaml:"appid"`
+	Token           string                 `yaml:"token"`
+	Cluster         string                 `yaml:"cluster"`
+	Speed           float64                `yaml:"speed,omitempty"`  // 语速倍率，默认1.0
+	SurportedVoices []string               `yaml:"surported_voices"` // 支持的语音列表
+	Extra           map[string]interface{} `yaml:",inline"`          // 额外配置，如 tls
 }
 
 // Provider TTS提供者接口
 type Provider interface {
 	providers.TTSProvider
+
+	// SynthesizeStream 流式合成，按块返回编码后的音频数据（MP3或WAV），合成结束后关闭通道
+	SynthesizeStream(ctx context.Context, text string) (<-chan []byte, error)
 }
 
+// streamChunkSize 非原生流式提供者输出时每个数据块的大小
+const streamChunkSize = 4096
+
+// 语速倍率允许范围
+const (
+	MinSpeed = 0.5
+	MaxSpeed = 2.0
+)
+
 // BaseProvider TTS基础实现
 type BaseProvider struct {
 	config     *Config
@@ -93,6 +108,24 @@ func (p *BaseProvider) SetVoice(voice string) error {
 	return nil
 }
 
+// SetSpeed 设置语速倍率
+func (p *BaseProvider) SetSpeed(speed float64) error {
+	if speed < MinSpeed || speed > MaxSpeed {
+		return fmt.Errorf("语速超出范围: %.2f, 允许范围: %.1f-%.1f", speed, MinSpeed, MaxSpeed)
+	}
+	p.Config().Speed = speed
+	logrus.WithField("speed", speed).Info("已设置语速")
+	return nil
+}
+
+// SpeedRatio 获取语速倍率，未配置时为1.0
+func (p *BaseProvider) SpeedRatio() float64 {
+	if p.config.Speed <= 0 {
+		return 1.0
+	}
+	return p.config.Speed
+}
+
 // Cleanup 清理资源
 func (p *BaseProvider) Cleanup() error {
 	if p.deleteFile {
@@ -111,6 +144,25 @@ func (p *BaseProvider) Cleanup() error {
 	return nil
 }
 
+// NewBytesStream 将完整的音频数据按块写入通道，供不支持原生流式合成的提供者实现 SynthesizeStream
+func NewBytesStream(ctx context.Context, data []byte) <-chan []byte {
+	ch := make(chan []byte, len(data)/streamChunkSize+1)
+	defer close(ch)
+
+	for start := 0; start < len(data); start += streamChunkSize {
+		end := start + streamChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		select {
+		case <-ctx.Done():
+			return ch
+		case ch <- data[start:end]:
+		}
+	}
+	return ch
+}
+
 // Factory TTS工厂函数类型
 type Factory func(config *Config, deleteFile bool) (Provider, error)
 
@@ -0,0 +1,146 @@
+package vad
+
+import (
+	"fmt"
+
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	defaultThreshold          = 0.5
+	defaultMinSilenceDuration = 700 // 毫秒
+	defaultMinSpeechDuration  = 250 // 毫秒
+	thresholdHysteresis       = 0.15
+)
+
+// Event 语音分段事件
+type Event int
+
+const (
+	EventSpeechStart Event = iota + 1 // 检测到说话开始
+	EventSpeechEnd                    // 说话结束，静音已持续 MinSilenceDuration
+)
+
+func (e Event) String() string {
+	switch e {
+	case EventSpeechStart:
+		return "speech_start"
+	case EventSpeechEnd:
+		return "speech_end"
+	}
+	return fmt.Sprintf("Event(%d)", int(e))
+}
+
+// Segmenter 把连续的16位PCM音频切分为语音段
+// 语音概率高于阈值并持续 min_speech_duration_ms 才判定为开始说话，
+// 低于阈值减去回差的静音持续 MinSilenceDuration 后判定为说话结束
+type Segmenter struct {
+	provider  Provider
+	resampler *utils.PCMResampler
+
+	windowMs     float64
+	threshold    float32
+	negThreshold float32
+	minSpeechMs  float64
+	minSilenceMs float64
+
+	window    []float32
+	speaking  bool
+	speechMs  float64
+	silenceMs float64
+}
+
+// NewSegmenter 创建分段器，sampleRate和channels为输入PCM的格式
+func NewSegmenter(provider Provider, config *Config, sampleRate, channels int) (*Segmenter, error) {
+	if provider.WindowSize() <= 0 {
+		return nil, fmt.Errorf("VAD提供者的窗口大小无效")
+	}
+	resampler, err := utils.NewPCMResampler(sampleRate, channels, provider.SampleRate())
+	if err != nil {
+		return nil, err
+	}
+	threshold := float32(config.Threshold)
+	if threshold <= 0 || threshold >= 1 {
+		threshold = defaultThreshold
+	}
+	minSilence := config.MinSilenceDuration
+	if minSilence <= 0 {
+		minSilence = defaultMinSilenceDuration
+	}
+	return &Segmenter{
+		provider:     provider,
+		resampler:    resampler,
+		windowMs:     float64(provider.WindowSize()) * 1000 / float64(provider.SampleRate()),
+		threshold:    threshold,
+		negThreshold: max(threshold-thresholdHysteresis, 0.01),
+		minSpeechMs:  float64(config.IntExtra("min_speech_duration_ms", defaultMinSpeechDuration)),
+		minSilenceMs: float64(minSilence),
+		window:       make([]float32, 0, provider.WindowSize()),
+	}, nil
+}
+
+// Feed 输入一段16位小端PCM，返回期间发生的分段事件
+func (s *Segmenter) Feed(pcm []byte) ([]Event, error) {
+	var events []Event
+	for _, sample := range s.resampler.Float32(pcm) {
+		s.window = append(s.window, sample)
+		if len(s.window) < cap(s.window) {
+			continue
+		}
+		prob, err := s.provider.SpeechProb(s.window)
+		s.window = s.window[:0]
+		if err != nil {
+			return events, fmt.Errorf("VAD判定失败: %v", err)
+		}
+		if event, ok := s.update(prob); ok {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// update 根据一个窗口的语音概率更新状态
+func (s *Segmenter) update(prob float32) (Event, bool) {
+	if !s.speaking {
+		switch {
+		case prob >= s.threshold:
+			s.speechMs += s.windowMs
+			if s.speechMs >= s.minSpeechMs {
+				s.speaking = true
+				s.silenceMs = 0
+				return EventSpeechStart, true
+			}
+		case prob < s.negThreshold:
+			s.speechMs = 0
+		}
+		return 0, false
+	}
+
+	switch {
+	case prob >= s.threshold:
+		s.silenceMs = 0
+	case prob < s.negThreshold:
+		s.silenceMs += s.windowMs
+		if s.silenceMs >= s.minSilenceMs {
+			s.speaking = false
+			s.speechMs = 0
+			return EventSpeechEnd, true
+		}
+	}
+	return 0, false
+}
+
+// Speaking 当前是否处于语音段中
+func (s *Segmenter) Speaking() bool {
+	return s.speaking
+}
+
+// Reset 丢弃缓冲的音频和分段状态
+func (s *Segmenter) Reset() error {
+	s.window = s.window[:0]
+	s.resampler.Reset()
+	s.speaking = false
+	s.speechMs = 0
+	s.silenceMs = 0
+	return s.provider.Reset()
+}
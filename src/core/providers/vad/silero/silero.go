@@ -0,0 +1,184 @@
+//go:build silero
+
+// Silero VAD 通过 onnxruntime 运行，需要CGO和onnxruntime动态库：
+//
+//	go get github.com/yalue/onnxruntime_go
+//	go build -tags silero ./src/main.go
+//
+// 未使用 silero 编译标签时见 silero_disabled.go
+package silero
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"xiaozhi-server-go/src/core/providers/vad"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+const (
+	defaultModelFile = "silero_vad.onnx"
+	defaultLibPath   = "libonnxruntime.so"
+	stateSize        = 2 * 1 * 128
+)
+
+var (
+	envOnce sync.Once
+	envErr  error
+)
+
+// initEnvironment 进程内只初始化一次onnxruntime环境
+func initEnvironment(libPath string) error {
+	envOnce.Do(func() {
+		ort.SetSharedLibraryPath(libPath)
+		envErr = ort.InitializeEnvironment()
+	})
+	if envErr != nil {
+		return fmt.Errorf("初始化onnxruntime失败: %v", envErr)
+	}
+	return nil
+}
+
+// Provider Silero VAD（v5模型）
+// 每个窗口之前拼接上一个窗口末尾的采样作为上下文，模型的循环状态在窗口之间传递
+type Provider struct {
+	sampleRate  int
+	windowSize  int
+	contextSize int
+
+	mu      sync.Mutex
+	session *ort.AdvancedSession
+	input   *ort.Tensor[float32]
+	state   *ort.Tensor[float32]
+	sr      *ort.Tensor[int64]
+	output  *ort.Tensor[float32]
+	stateN  *ort.Tensor[float32]
+}
+
+// NewProvider 加载Silero模型
+func NewProvider(config *vad.Config) (*Provider, error) {
+	sampleRate := config.IntExtra("sample_rate", 16000)
+	p := &Provider{sampleRate: sampleRate}
+	switch sampleRate {
+	case 16000:
+		p.windowSize, p.contextSize = 512, 64
+	case 8000:
+		p.windowSize, p.contextSize = 256, 32
+	default:
+		return nil, fmt.Errorf("不支持的采样率: %d，仅支持8000或16000", sampleRate)
+	}
+
+	if err := initEnvironment(config.StringExtra("onnxruntime_lib", defaultLibPath)); err != nil {
+		return nil, err
+	}
+	modelPath := filepath.Join(config.ModelDir, config.StringExtra("model_file", defaultModelFile))
+	if err := p.createSession(modelPath); err != nil {
+		p.destroy()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Provider) createSession(modelPath string) error {
+	var err error
+	if p.input, err = ort.NewEmptyTensor[float32](ort.NewShape(1, int64(p.contextSize+p.windowSize))); err != nil {
+		return fmt.Errorf("创建输入张量失败: %v", err)
+	}
+	if p.state, err = ort.NewEmptyTensor[float32](ort.NewShape(2, 1, 128)); err != nil {
+		return fmt.Errorf("创建状态张量失败: %v", err)
+	}
+	if p.sr, err = ort.NewTensor(ort.NewShape(), []int64{int64(p.sampleRate)}); err != nil {
+		return fmt.Errorf("创建采样率张量失败: %v", err)
+	}
+	if p.output, err = ort.NewEmptyTensor[float32](ort.NewShape(1, 1)); err != nil {
+		return fmt.Errorf("创建输出张量失败: %v", err)
+	}
+	if p.stateN, err = ort.NewEmptyTensor[float32](ort.NewShape(2, 1, 128)); err != nil {
+		return fmt.Errorf("创建状态张量失败: %v", err)
+	}
+	p.session, err = ort.NewAdvancedSession(modelPath,
+		[]string{"input", "state", "sr"}, []string{"output", "stateN"},
+		[]ort.Value{p.input, p.state, p.sr}, []ort.Value{p.output, p.stateN}, nil)
+	if err != nil {
+		return fmt.Errorf("加载Silero模型 %s 失败: %v", modelPath, err)
+	}
+	return nil
+}
+
+// SampleRate 实现 vad.Provider 接口
+func (p *Provider) SampleRate() int {
+	return p.sampleRate
+}
+
+// WindowSize 实现 vad.Provider 接口
+func (p *Provider) WindowSize() int {
+	return p.windowSize
+}
+
+// SpeechProb 实现 vad.Provider 接口
+func (p *Provider) SpeechProb(window []float32) (float32, error) {
+	if len(window) != p.windowSize {
+		return 0, fmt.Errorf("窗口大小应为%d，实际为%d", p.windowSize, len(window))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	input := p.input.GetData()
+	copy(input, input[p.windowSize:]) // 上一个窗口的末尾作为上下文
+	copy(input[p.contextSize:], window)
+	if err := p.session.Run(); err != nil {
+		return 0, fmt.Errorf("Silero推理失败: %v", err)
+	}
+	copy(p.state.GetData(), p.stateN.GetData()[:stateSize])
+	return p.output.GetData()[0], nil
+}
+
+// Reset 实现 vad.Provider 接口
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clear(p.input.GetData())
+	clear(p.state.GetData())
+	return nil
+}
+
+// Initialize 实现Provider接口的Initialize方法
+func (p *Provider) Initialize() error {
+	return nil
+}
+
+// Cleanup 实现Provider接口的Cleanup方法
+func (p *Provider) Cleanup() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.destroy()
+	return nil
+}
+
+// destroy 释放会话和张量
+func (p *Provider) destroy() {
+	if p.session != nil {
+		p.session.Destroy()
+		p.session = nil
+	}
+	destroyTensor(p.input)
+	destroyTensor(p.state)
+	destroyTensor(p.sr)
+	destroyTensor(p.output)
+	destroyTensor(p.stateN)
+	p.input, p.state, p.sr, p.output, p.stateN = nil, nil, nil, nil, nil
+}
+
+func destroyTensor[T ort.TensorData](t *ort.Tensor[T]) {
+	if t != nil {
+		t.Destroy()
+	}
+}
+
+func init() {
+	vad.Register("silero", func(config *vad.Config) (vad.Provider, error) {
+		return NewProvider(config)
+	})
+}
@@ -0,0 +1,16 @@
+//go:build !silero
+
+package silero
+
+import (
+	"fmt"
+
+	"xiaozhi-server-go/src/core/providers/vad"
+)
+
+// 未使用 silero 编译标签构建时没有onnxruntime，创建时返回错误，由调用方回退到webrtc
+func init() {
+	vad.Register("silero", func(config *vad.Config) (vad.Provider, error) {
+		return nil, fmt.Errorf("当前程序未包含Silero VAD，请使用 -tags silero 重新编译")
+	})
+}
@@ -0,0 +1,78 @@
+package vad
+
+import (
+	"fmt"
+
+	"xiaozhi-server-go/src/core/providers"
+)
+
+// Config VAD配置结构
+type Config struct {
+	Type               string
+	ModelDir           string
+	Threshold          float64 // 判定为语音的概率阈值（0~1）
+	MinSilenceDuration int     // 语音结束前需要持续的静音时长（毫秒）
+	Extra              map[string]interface{}
+}
+
+// Provider VAD提供者接口，按固定长度的窗口计算语音概率
+type Provider interface {
+	providers.Provider
+
+	// SampleRate 模型要求的采样率
+	SampleRate() int
+	// WindowSize 每次判定需要的采样点数
+	WindowSize() int
+	// SpeechProb 计算一个窗口（归一化到-1~1的单声道采样）包含语音的概率
+	SpeechProb(window []float32) (float32, error)
+	// Reset 清除跨窗口保留的状态，开始新的一段音频
+	Reset() error
+}
+
+// Factory VAD工厂函数类型
+type Factory func(config *Config) (Provider, error)
+
+var (
+	factories = make(map[string]Factory)
+)
+
+// Register 注册VAD提供者工厂
+func Register(name string, factory Factory) {
+	factories[name] = factory
+}
+
+// Create 创建VAD提供者实例
+func Create(name string, config *Config) (Provider, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的VAD提供者: %s", name)
+	}
+
+	provider, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建VAD提供者失败: %v", err)
+	}
+
+	return provider, nil
+}
+
+// IntExtra 读取额外配置中的整数，缺省或类型不符时返回默认值
+func (c *Config) IntExtra(key string, def int) int {
+	switch v := c.Extra[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return def
+}
+
+// StringExtra 读取额外配置中的字符串，缺省时返回默认值
+func (c *Config) StringExtra(key string, def string) string {
+	if v, ok := c.Extra[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,125 @@
+package webrtc
+
+import (
+	"fmt"
+	"math"
+
+	"xiaozhi-server-go/src/core/providers/vad"
+)
+
+const (
+	defaultMode          = 2
+	defaultFrameDuration = 30    // 毫秒，与WebRTC VAD一样支持10/20/30
+	defaultSampleRate    = 16000 // 支持8000/16000
+	initialNoiseDB       = -50.0 // 初始噪声估计（dBFS）
+	minSpeechDB          = -60.0 // 低于该能量的帧直接判为静音
+	noiseRiseRate        = 0.01  // 噪声估计随环境噪声上升的速度
+	speechNoiseRiseRate  = 0.001 // 疑似语音帧上噪声估计上升的速度，避免长句被当成背景噪声
+	noiseFallRate        = 0.2   // 噪声估计随环境变安静下降的速度
+	highPassPole         = 0.97  // 一阶高通滤波器的极点，16kHz下截止频率约80Hz，滤除直流和低频噪声
+)
+
+// modeSNR 各激进程度下判定为语音所需的信噪比（dB），越激进越不容易判为语音
+var modeSNR = [4]float64{6, 9, 12, 15}
+
+// Provider 纯Go实现的能量型VAD
+// 参照WebRTC VAD的做法按短帧判决、自适应估计背景噪声，并提供0~3四档激进程度；
+// 不依赖CGO和模型文件，在无法使用Silero时作为兜底
+type Provider struct {
+	sampleRate int
+	frameSize  int
+	snr        float64
+
+	noiseDB    float64
+	hasNoise   bool
+	lastInput  float64 // 高通滤波器上一个输入
+	lastOutput float64 // 高通滤波器上一个输出
+}
+
+// NewProvider 创建VAD提供者
+func NewProvider(config *vad.Config) (*Provider, error) {
+	mode := config.IntExtra("mode", defaultMode)
+	if mode < 0 || mode > 3 {
+		return nil, fmt.Errorf("mode 必须为0~3: %d", mode)
+	}
+	sampleRate := config.IntExtra("sample_rate", defaultSampleRate)
+	if sampleRate != 8000 && sampleRate != 16000 {
+		return nil, fmt.Errorf("不支持的采样率: %d，仅支持8000或16000", sampleRate)
+	}
+	frameDuration := config.IntExtra("frame_duration_ms", defaultFrameDuration)
+	if frameDuration != 10 && frameDuration != 20 && frameDuration != 30 {
+		return nil, fmt.Errorf("不支持的帧长: %dms，仅支持10、20或30", frameDuration)
+	}
+	return &Provider{
+		sampleRate: sampleRate,
+		frameSize:  sampleRate * frameDuration / 1000,
+		snr:        modeSNR[mode],
+	}, nil
+}
+
+// SampleRate 实现 vad.Provider 接口
+func (p *Provider) SampleRate() int {
+	return p.sampleRate
+}
+
+// WindowSize 实现 vad.Provider 接口
+func (p *Provider) WindowSize() int {
+	return p.frameSize
+}
+
+// SpeechProb 按高通滤波后的帧能量相对背景噪声的信噪比估算语音概率
+func (p *Provider) SpeechProb(window []float32) (float32, error) {
+	if len(window) != p.frameSize {
+		return 0, fmt.Errorf("窗口大小应为%d，实际为%d", p.frameSize, len(window))
+	}
+	var sum float64
+	for _, sample := range window {
+		x := float64(sample)
+		y := x - p.lastInput + highPassPole*p.lastOutput
+		sum += y * y
+		p.lastInput, p.lastOutput = x, y
+	}
+	energyDB := 10 * math.Log10(sum/float64(len(window))+1e-10)
+
+	if !p.hasNoise {
+		p.noiseDB = math.Min(energyDB, initialNoiseDB)
+		p.hasNoise = true
+	}
+	snr := energyDB - p.noiseDB
+	switch {
+	case energyDB < p.noiseDB:
+		p.noiseDB += noiseFallRate * (energyDB - p.noiseDB)
+	case snr < p.snr:
+		p.noiseDB += noiseRiseRate * (energyDB - p.noiseDB)
+	default:
+		p.noiseDB += speechNoiseRiseRate * (energyDB - p.noiseDB)
+	}
+
+	if energyDB < minSpeechDB {
+		return 0, nil
+	}
+	// 信噪比达到阈值时概率为0.5，每高出2dB概率显著上升
+	return float32(1 / (1 + math.Exp(-(snr-p.snr)/2))), nil
+}
+
+// Reset 实现 vad.Provider 接口，背景噪声估计在同一连接内保留
+func (p *Provider) Reset() error {
+	p.lastInput, p.lastOutput = 0, 0
+	return nil
+}
+
+// Initialize 实现Provider接口的Initialize方法
+func (p *Provider) Initialize() error {
+	return nil
+}
+
+// Cleanup 实现Provider接口的Cleanup方法
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+func init() {
+	vad.Register("webrtc", func(config *vad.Config) (vad.Provider, error) {
+		return NewProvider(config)
+	})
+}
@@ -0,0 +1,252 @@
+package vlllm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/core/image"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAnthropicBaseURL    = "https://api.anthropic.com"
+	defaultAnthropicAPIVersion = "2023-06-01"
+	defaultAnthropicMaxTokens  = 1024
+)
+
+// AnthropicRequest Claude Messages API 请求结构
+type AnthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []AnthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature,omitempty"`
+	TopP        float64            `json:"top_p,omitempty"`
+	Stream      bool               `json:"stream"`
+}
+
+// AnthropicMessage Claude消息结构，role 为 user 或 assistant
+type AnthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []AnthropicContentBlock `json:"content"`
+}
+
+// AnthropicContentBlock Claude内容块，text 或 image
+type AnthropicContentBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *AnthropicImageSource `json:"source,omitempty"`
+}
+
+// AnthropicImageSource base64图片来源
+type AnthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// AnthropicStreamEvent Claude流式事件，只解析用到的字段
+type AnthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+	// message_start 携带模型和输入token数，message_delta 携带累计的输出token数
+	Message struct {
+		Model string `json:"model"`
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// responseWithAnthropicVision 使用Claude Messages API，图片以base64内容块发送
+func (p *Provider) responseWithAnthropicVision(ctx context.Context, messages []providers.Message, frames []image.Frame, text string) (<-chan string, <-chan error, error) {
+	system, chatMessages := convertAnthropicMessages(messages)
+
+	// 添加包含图片的用户消息，图片放在问题之前，与前一条用户消息合并
+	visionBlocks := make([]AnthropicContentBlock, 0, len(frames)+1)
+	for _, frame := range frames {
+		visionBlocks = append(visionBlocks, AnthropicContentBlock{
+			Type: "image",
+			Source: &AnthropicImageSource{
+				Type:      "base64",
+				MediaType: imageMimeType(frame.Format),
+				Data:      frame.Data,
+			},
+		})
+	}
+	visionBlocks = append(visionBlocks, AnthropicContentBlock{Type: "text", Text: text})
+	if n := len(chatMessages); n > 0 && chatMessages[n-1].Role == "user" {
+		chatMessages[n-1].Content = append(chatMessages[n-1].Content, visionBlocks...)
+	} else {
+		chatMessages = append(chatMessages, AnthropicMessage{Role: "user", Content: visionBlocks})
+	}
+
+	maxTokens := p.config.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultAnthropicMaxTokens
+	}
+	requestBody, err := json.Marshal(AnthropicRequest{
+		Model:       p.config.ModelName,
+		System:      system,
+		Messages:    chatMessages,
+		MaxTokens:   maxTokens,
+		Temperature: p.config.Temperature,
+		TopP:        p.config.TopP,
+		Stream:      true,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("请求序列化失败: %v", err)
+	}
+
+	url := strings.TrimSuffix(p.config.BaseURL, "/") + "/v1/messages"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	apiVersion := defaultAnthropicAPIVersion
+	if version, ok := p.config.Data["anthropic_version"].(string); ok && version != "" {
+		apiVersion = version
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.config.APIKey)
+	req.Header.Set("anthropic-version", apiVersion)
+
+	logrus.WithFields(logrus.Fields{
+		"model": p.config.ModelName,
+		"text":  text,
+	}).Info("向Claude发送多模态请求")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		logrus.WithError(err).Error("Claude Vision API调用失败")
+		return nil, nil, types.NewProviderError("vlllm-anthropic", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		logrus.WithFields(logrus.Fields{
+			"status_code": resp.StatusCode,
+			"status":      resp.Status,
+		}).Error("Claude API返回错误")
+		return nil, nil, types.NewHTTPError("vlllm-anthropic", resp.StatusCode, string(body))
+	}
+
+	logrus.Info("Claude Vision API调用成功，开始接收流式回复")
+
+	responses, errs := streamResponses("Claude Vision", resp.Body, func(out chan<- string) error {
+		return readAnthropicStream(ctx, resp.Body, out)
+	})
+	return responses, errs, nil
+}
+
+// readAnthropicStream 解析SSE事件，转发文本增量，消息结束时上报用量
+func readAnthropicStream(ctx context.Context, body io.Reader, out chan<- string) error {
+	usage := types.Usage{}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var event AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			logrus.WithError(err).Debug("Claude事件解析失败: " + data)
+			continue
+		}
+		switch event.Type {
+		case "message_start":
+			usage.Model = event.Message.Model
+			usage.PromptTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			if event.Usage.OutputTokens > 0 {
+				usage.CompletionTokens = event.Usage.OutputTokens
+			}
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				select {
+				case out <- event.Delta.Text:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		case "error":
+			return types.NewHTTPError("vlllm-anthropic", anthropicErrorStatus(event.Error.Type),
+				fmt.Sprintf("%s: %s", event.Error.Type, event.Error.Message))
+		case "message_stop":
+			types.ReportUsage(ctx, usage)
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return types.NewProviderError("vlllm-anthropic", err)
+	}
+	return nil
+}
+
+// anthropicErrorStatus 流式 error 事件类型对应的HTTP状态码，用于错误分类
+func anthropicErrorStatus(errType string) int {
+	switch errType {
+	case "authentication_error", "permission_error":
+		return http.StatusUnauthorized
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "overloaded_error":
+		return 529
+	case "invalid_request_error":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// convertAnthropicMessages 转换历史消息，system消息合并为顶层system字段，相邻同角色消息合并
+// Claude要求第一条消息为用户消息，开头的助手消息（如欢迎语）会被丢弃
+func convertAnthropicMessages(messages []providers.Message) (string, []AnthropicMessage) {
+	var systemParts []string
+	var result []AnthropicMessage
+	for _, msg := range messages {
+		if msg.Content == "" {
+			continue
+		}
+		role := "user"
+		switch msg.Role {
+		case "system":
+			systemParts = append(systemParts, msg.Content)
+			continue
+		case "assistant":
+			if len(result) == 0 {
+				continue
+			}
+			role = "assistant"
+		}
+		block := AnthropicContentBlock{Type: "text", Text: msg.Content}
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Content = append(result[n-1].Content, block)
+			continue
+		}
+		result = append(result, AnthropicMessage{Role: role, Content: []AnthropicContentBlock{block}})
+	}
+	return strings.Join(systemParts, "\n\n"), result
+}
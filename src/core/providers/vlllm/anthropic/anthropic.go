@@ -0,0 +1,28 @@
+package anthropic
+
+import (
+	"xiaozhi-server-go/src/core/providers/vlllm"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewProvider 创建Claude VLLLM提供者实例
+func NewProvider(config *vlllm.Config) (*vlllm.Provider, error) {
+	// 图片处理与流式输出由基础VLLLM Provider完成，按 type: anthropic 调用 Messages API，图片以base64内容块发送
+	provider, err := vlllm.NewProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"model_name": config.ModelName,
+		"base_url":   config.BaseURL,
+	}).Debug("Claude VLLLM Provider创建成功")
+
+	return provider, nil
+}
+
+// init 注册Claude VLLLM提供者
+func init() {
+	vlllm.Register("anthropic", NewProvider)
+}
@@ -0,0 +1,343 @@
+package vlllm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"xiaozhi-server-go/src/core/image"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDashScopeBaseURL = "https://dashscope.aliyuncs.com/api/v1"
+	// 解码后超过该大小的图片先上传到DashScope临时存储，再以 oss:// 地址引用
+	defaultDashScopeOSSThreshold = 1024 * 1024
+)
+
+// DashScopeRequest DashScope多模态生成请求结构
+type DashScopeRequest struct {
+	Model string `json:"model"`
+	Input struct {
+		Messages []DashScopeMessage `json:"messages"`
+	} `json:"input"`
+	Parameters DashScopeParameters `json:"parameters"`
+}
+
+// DashScopeMessage DashScope多模态消息，content 为文本或图片片段列表
+type DashScopeMessage struct {
+	Role    string             `json:"role"`
+	Content []DashScopeContent `json:"content"`
+}
+
+// DashScopeContent 消息片段，image 可以是公网URL、data URL 或 oss:// 临时地址
+type DashScopeContent struct {
+	Text  string `json:"text,omitempty"`
+	Image string `json:"image,omitempty"`
+}
+
+// DashScopeParameters 生成参数
+type DashScopeParameters struct {
+	IncrementalOutput bool    `json:"incremental_output"`
+	MaxTokens         int     `json:"max_tokens,omitempty"`
+	Temperature       float64 `json:"temperature,omitempty"`
+	TopP              float64 `json:"top_p,omitempty"`
+}
+
+// DashScopeStreamChunk 流式响应分块，只解析用到的字段；出错时 code 和 message 非空
+type DashScopeStreamChunk struct {
+	Output struct {
+		Choices []struct {
+			Message struct {
+				Content []DashScopeContent `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	} `json:"output"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// dashScopeUploadPolicy 临时存储上传凭证
+type dashScopeUploadPolicy struct {
+	Data struct {
+		Policy              string `json:"policy"`
+		Signature           string `json:"signature"`
+		UploadDir           string `json:"upload_dir"`
+		UploadHost          string `json:"upload_host"`
+		OSSAccessKeyID      string `json:"oss_access_key_id"`
+		XOSSObjectACL       string `json:"x_oss_object_acl"`
+		XOSSForbidOverwrite string `json:"x_oss_forbid_overwrite"`
+	} `json:"data"`
+}
+
+// responseWithDashScopeVision 使用DashScope原生多模态接口（通义千问VL）
+func (p *Provider) responseWithDashScopeVision(ctx context.Context, messages []providers.Message, frames []image.Frame, text string) (<-chan string, <-chan error, error) {
+	// 大图先上传到临时存储，失败时仍以base64内联发送
+	content := make([]DashScopeContent, 0, len(frames)+1)
+	ossResolve := false
+	for _, frame := range frames {
+		imageRef := fmt.Sprintf("data:%s;base64,%s", imageMimeType(frame.Format), frame.Data)
+		if p.dashScopeOSSEnabled() && base64.StdEncoding.DecodedLen(len(frame.Data)) > p.dashScopeOSSThreshold() {
+			if ossURL, err := p.uploadDashScopeImage(ctx, frame.Data, frame.Format); err != nil {
+				logrus.WithError(err).Warn("上传图片到DashScope临时存储失败，改为内联发送")
+			} else {
+				imageRef = ossURL
+				ossResolve = true
+			}
+		}
+		content = append(content, DashScopeContent{Image: imageRef})
+	}
+	content = append(content, DashScopeContent{Text: text})
+
+	var request DashScopeRequest
+	request.Model = p.config.ModelName
+	request.Input.Messages = convertDashScopeMessages(messages)
+	request.Input.Messages = append(request.Input.Messages, DashScopeMessage{
+		Role:    "user",
+		Content: content,
+	})
+	request.Parameters = DashScopeParameters{
+		IncrementalOutput: true,
+		MaxTokens:         p.config.MaxTokens,
+		Temperature:       p.config.Temperature,
+		TopP:              p.config.TopP,
+	}
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("请求序列化失败: %v", err)
+	}
+
+	url := strings.TrimSuffix(p.config.BaseURL, "/") + "/services/aigc/multimodal-generation/generation"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	req.Header.Set("X-DashScope-SSE", "enable")
+	if ossResolve {
+		req.Header.Set("X-DashScope-OssResourceResolve", "enable")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"model": p.config.ModelName,
+		"text":  text,
+		"oss":   ossResolve,
+	}).Info("向DashScope发送多模态请求")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		logrus.WithError(err).Error("DashScope Vision API调用失败")
+		return nil, nil, types.NewProviderError("vlllm-dashscope", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		logrus.WithFields(logrus.Fields{
+			"status_code": resp.StatusCode,
+			"status":      resp.Status,
+		}).Error("DashScope API返回错误")
+		var chunk DashScopeStreamChunk
+		if json.Unmarshal(body, &chunk) == nil && isDashScopeContentFilter(chunk.Code) {
+			return nil, nil, dashScopeContentFilterError(chunk.Message)
+		}
+		return nil, nil, types.NewHTTPError("vlllm-dashscope", resp.StatusCode, string(body))
+	}
+
+	logrus.Info("DashScope Vision API调用成功，开始接收流式回复")
+
+	responses, errs := streamResponses("DashScope Vision", resp.Body, func(out chan<- string) error {
+		return p.readDashScopeStream(ctx, resp.Body, out)
+	})
+	return responses, errs, nil
+}
+
+// readDashScopeStream 解析SSE分块，转发文本并在结束时上报用量
+func (p *Provider) readDashScopeStream(ctx context.Context, body io.Reader, out chan<- string) error {
+	usage := types.Usage{Model: p.config.ModelName}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var chunk DashScopeStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logrus.WithError(err).Debug("DashScope分块解析失败: " + data)
+			continue
+		}
+		if chunk.Code != "" {
+			if isDashScopeContentFilter(chunk.Code) {
+				return dashScopeContentFilterError(chunk.Message)
+			}
+			return types.NewProviderError("vlllm-dashscope", fmt.Errorf("%s: %s (request_id=%s)", chunk.Code, chunk.Message, chunk.RequestID))
+		}
+		if chunk.Usage.InputTokens > 0 || chunk.Usage.OutputTokens > 0 {
+			usage.PromptTokens = chunk.Usage.InputTokens
+			usage.CompletionTokens = chunk.Usage.OutputTokens
+		}
+		for _, choice := range chunk.Output.Choices {
+			for _, content := range choice.Message.Content {
+				if content.Text == "" {
+					continue
+				}
+				select {
+				case out <- content.Text:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return types.NewProviderError("vlllm-dashscope", err)
+	}
+	types.ReportUsage(ctx, usage)
+	return nil
+}
+
+// dashScopeOSSEnabled 是否开启大图上传，默认开启，配置 oss_upload: false 关闭
+func (p *Provider) dashScopeOSSEnabled() bool {
+	enabled, ok := p.config.Data["oss_upload"].(bool)
+	return !ok || enabled
+}
+
+// dashScopeOSSThreshold 需要上传的图片大小阈值（字节）
+func (p *Provider) dashScopeOSSThreshold() int {
+	if kb, ok := p.config.Data["oss_threshold_kb"].(int); ok && kb > 0 {
+		return kb * 1024
+	}
+	return defaultDashScopeOSSThreshold
+}
+
+// uploadDashScopeImage 上传图片到DashScope临时存储，返回 oss:// 地址，文件48小时后自动删除
+func (p *Provider) uploadDashScopeImage(ctx context.Context, base64Image string, format string) (string, error) {
+	imageData, err := base64.StdEncoding.DecodeString(base64Image)
+	if err != nil {
+		return "", fmt.Errorf("解码图片失败: %v", err)
+	}
+
+	// 获取上传凭证
+	policyURL := fmt.Sprintf("%s/uploads?action=getPolicy&model=%s", strings.TrimSuffix(p.config.BaseURL, "/"), url.QueryEscape(p.config.ModelName))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, policyURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("获取上传凭证失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("获取上传凭证失败: HTTP %d %s", resp.StatusCode, string(body))
+	}
+	var policy dashScopeUploadPolicy
+	if err := json.NewDecoder(resp.Body).Decode(&policy); err != nil {
+		return "", fmt.Errorf("解析上传凭证失败: %v", err)
+	}
+
+	// 以表单上传到OSS
+	key := fmt.Sprintf("%s/%s.%s", policy.Data.UploadDir, uuid.New().String(), strings.TrimPrefix(imageMimeType(format), "image/"))
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	for name, value := range map[string]string{
+		"OSSAccessKeyId":         policy.Data.OSSAccessKeyID,
+		"Signature":              policy.Data.Signature,
+		"policy":                 policy.Data.Policy,
+		"key":                    key,
+		"x-oss-object-acl":       policy.Data.XOSSObjectACL,
+		"x-oss-forbid-overwrite": policy.Data.XOSSForbidOverwrite,
+		"success_action_status":  "200",
+	} {
+		if err := writer.WriteField(name, value); err != nil {
+			return "", fmt.Errorf("构建上传表单失败: %v", err)
+		}
+	}
+	// file 字段必须位于表单最后
+	part, err := writer.CreateFormFile("file", key[strings.LastIndex(key, "/")+1:])
+	if err != nil {
+		return "", fmt.Errorf("构建上传表单失败: %v", err)
+	}
+	if _, err := part.Write(imageData); err != nil {
+		return "", fmt.Errorf("构建上传表单失败: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("构建上传表单失败: %v", err)
+	}
+
+	uploadReq, err := http.NewRequestWithContext(ctx, http.MethodPost, policy.Data.UploadHost, &form)
+	if err != nil {
+		return "", fmt.Errorf("创建上传请求失败: %v", err)
+	}
+	uploadReq.Header.Set("Content-Type", writer.FormDataContentType())
+	uploadResp, err := p.httpClient.Do(uploadReq)
+	if err != nil {
+		return "", fmt.Errorf("上传图片失败: %v", err)
+	}
+	defer uploadResp.Body.Close()
+	if uploadResp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(uploadResp.Body, 1024))
+		return "", fmt.Errorf("上传图片失败: HTTP %d %s", uploadResp.StatusCode, string(body))
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"key":  key,
+		"size": len(imageData),
+	}).Debug("图片已上传到DashScope临时存储")
+	return "oss://" + key, nil
+}
+
+// isDashScopeContentFilter 输入或输出未通过阿里云内容安全审核
+func isDashScopeContentFilter(code string) bool {
+	return code == "DataInspectionFailed" || code == "data_inspection_failed"
+}
+
+func dashScopeContentFilterError(message string) error {
+	return &types.ProviderError{
+		Kind:     types.ErrorKindContentFilter,
+		Provider: "vlllm-dashscope",
+		Err:      fmt.Errorf("内容被安全策略拦截: %s", message),
+	}
+}
+
+// convertDashScopeMessages 转换历史消息为多模态消息格式
+func convertDashScopeMessages(messages []providers.Message) []DashScopeMessage {
+	result := make([]DashScopeMessage, 0, len(messages)+1)
+	for _, msg := range messages {
+		if msg.Content == "" {
+			continue
+		}
+		role := msg.Role
+		if role != "system" && role != "assistant" {
+			role = "user"
+		}
+		result = append(result, DashScopeMessage{
+			Role:    role,
+			Content: []DashScopeContent{{Text: msg.Content}},
+		})
+	}
+	return result
+}
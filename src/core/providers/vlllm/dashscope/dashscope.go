@@ -0,0 +1,28 @@
+package dashscope
+
+import (
+	"xiaozhi-server-go/src/core/providers/vlllm"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewProvider 创建Qwen-VL VLLLM提供者实例
+func NewProvider(config *vlllm.Config) (*vlllm.Provider, error) {
+	// 图片处理与流式输出由基础VLLLM Provider完成，按 type: dashscope 调用DashScope原生多模态接口，大图先上传到临时存储
+	provider, err := vlllm.NewProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"model_name": config.ModelName,
+		"base_url":   config.BaseURL,
+	}).Debug("Qwen-VL VLLLM Provider创建成功")
+
+	return provider, nil
+}
+
+// init 注册Qwen-VL VLLLM提供者
+func init() {
+	vlllm.Register("dashscope", NewProvider)
+}
@@ -37,6 +37,8 @@ func Create(name string, vlllmConfig *configs.VLLMConfig) (*Provider, error) {
 		MaxTokens:   vlllmConfig.MaxTokens,
 		TopP:        vlllmConfig.TopP,
 		Security:    vlllmConfig.Security,
+		Clip:        vlllmConfig.Clip,
+		Preprocess:  vlllmConfig.Preprocess,
 		Data:        vlllmConfig.Extra,
 	}
 
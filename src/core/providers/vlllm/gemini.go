@@ -0,0 +1,239 @@
+package vlllm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/core/image"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// GeminiRequest Gemini generateContent 请求结构
+type GeminiRequest struct {
+	Contents          []GeminiContent        `json:"contents"`
+	SystemInstruction *GeminiContent         `json:"systemInstruction,omitempty"`
+	GenerationConfig  GeminiGenerationConfig `json:"generationConfig"`
+}
+
+// GeminiContent Gemini消息结构，role 为 user 或 model
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart Gemini消息片段，文本或内嵌图片
+type GeminiPart struct {
+	Text       string            `json:"text,omitempty"`
+	InlineData *GeminiInlineData `json:"inlineData,omitempty"`
+}
+
+// GeminiInlineData 内嵌的base64数据
+type GeminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// GeminiGenerationConfig 生成参数
+type GeminiGenerationConfig struct {
+	Temperature     float64 `json:"temperature,omitempty"`
+	TopP            float64 `json:"topP,omitempty"`
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+}
+
+// GeminiStreamChunk Gemini流式响应分块，只解析用到的字段
+type GeminiStreamChunk struct {
+	Candidates []struct {
+		Content      GeminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+}
+
+// responseWithGeminiVision 使用Gemini多模态 streamGenerateContent 接口
+func (p *Provider) responseWithGeminiVision(ctx context.Context, messages []providers.Message, frames []image.Frame, text string) (<-chan string, <-chan error, error) {
+	system, contents := convertGeminiMessages(messages)
+
+	// 添加包含图片的用户消息，与前一条用户消息合并
+	visionParts := make([]GeminiPart, 0, len(frames)+1)
+	for _, frame := range frames {
+		visionParts = append(visionParts, GeminiPart{
+			InlineData: &GeminiInlineData{MimeType: imageMimeType(frame.Format), Data: frame.Data},
+		})
+	}
+	visionParts = append(visionParts, GeminiPart{Text: text})
+	if n := len(contents); n > 0 && contents[n-1].Role == "user" {
+		contents[n-1].Parts = append(contents[n-1].Parts, visionParts...)
+	} else {
+		contents = append(contents, GeminiContent{Role: "user", Parts: visionParts})
+	}
+
+	requestBody, err := json.Marshal(GeminiRequest{
+		Contents:          contents,
+		SystemInstruction: system,
+		GenerationConfig: GeminiGenerationConfig{
+			Temperature:     p.config.Temperature,
+			TopP:            p.config.TopP,
+			MaxOutputTokens: p.config.MaxTokens,
+		},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("请求序列化失败: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", strings.TrimSuffix(p.config.BaseURL, "/"), p.config.ModelName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", p.config.APIKey)
+
+	logrus.WithFields(logrus.Fields{
+		"model": p.config.ModelName,
+		"text":  text,
+	}).Info("向Gemini发送多模态请求")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		logrus.WithError(err).Error("Gemini Vision API调用失败")
+		return nil, nil, types.NewProviderError("vlllm-gemini", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		logrus.WithFields(logrus.Fields{
+			"status_code": resp.StatusCode,
+			"status":      resp.Status,
+		}).Error("Gemini API返回错误")
+		return nil, nil, types.NewHTTPError("vlllm-gemini", resp.StatusCode, string(body))
+	}
+
+	logrus.Info("Gemini Vision API调用成功，开始接收流式回复")
+
+	responses, errs := streamResponses("Gemini Vision", resp.Body, func(out chan<- string) error {
+		return readGeminiStream(ctx, resp.Body, out)
+	})
+	return responses, errs, nil
+}
+
+// readGeminiStream 解析SSE分块，转发文本并在结束时上报用量
+func readGeminiStream(ctx context.Context, body io.Reader, out chan<- string) error {
+	usage := types.Usage{}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var chunk GeminiStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logrus.WithError(err).Debug("Gemini分块解析失败: " + data)
+			continue
+		}
+		if chunk.Error != nil {
+			return types.NewHTTPError("vlllm-gemini", chunk.Error.Code, fmt.Sprintf("%s: %s", chunk.Error.Status, chunk.Error.Message))
+		}
+		if reason := chunk.PromptFeedback.BlockReason; reason != "" {
+			return geminiContentFilterError(reason)
+		}
+		if chunk.UsageMetadata != nil {
+			usage.Model = chunk.ModelVersion
+			usage.PromptTokens = chunk.UsageMetadata.PromptTokenCount
+			usage.CompletionTokens = chunk.UsageMetadata.CandidatesTokenCount
+		}
+		for _, candidate := range chunk.Candidates {
+			for _, part := range candidate.Content.Parts {
+				if part.Text == "" {
+					continue
+				}
+				select {
+				case out <- part.Text:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			switch candidate.FinishReason {
+			case "SAFETY", "PROHIBITED_CONTENT", "BLOCKLIST", "SPII":
+				return geminiContentFilterError(candidate.FinishReason)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return types.NewProviderError("vlllm-gemini", err)
+	}
+	types.ReportUsage(ctx, usage)
+	return nil
+}
+
+func geminiContentFilterError(reason string) error {
+	return &types.ProviderError{
+		Kind:     types.ErrorKindContentFilter,
+		Provider: "vlllm-gemini",
+		Err:      fmt.Errorf("内容被安全策略拦截: %s", reason),
+	}
+}
+
+// convertGeminiMessages 转换历史消息，system消息合并为 systemInstruction，
+// assistant 对应 model 角色，相邻同角色消息合并
+func convertGeminiMessages(messages []providers.Message) (*GeminiContent, []GeminiContent) {
+	var systemParts []GeminiPart
+	var contents []GeminiContent
+	for _, msg := range messages {
+		if msg.Content == "" {
+			continue
+		}
+		role := "user"
+		switch msg.Role {
+		case "system":
+			systemParts = append(systemParts, GeminiPart{Text: msg.Content})
+			continue
+		case "assistant":
+			role = "model"
+		}
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, GeminiPart{Text: msg.Content})
+			continue
+		}
+		contents = append(contents, GeminiContent{Role: role, Parts: []GeminiPart{{Text: msg.Content}}})
+	}
+	if len(systemParts) == 0 {
+		return nil, contents
+	}
+	return &GeminiContent{Parts: systemParts}, contents
+}
+
+// imageMimeType 图片格式对应的MIME类型
+func imageMimeType(format string) string {
+	format = strings.ToLower(format)
+	switch format {
+	case "", "jpg":
+		format = "jpeg"
+	}
+	return "image/" + format
+}
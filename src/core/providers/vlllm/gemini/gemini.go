@@ -0,0 +1,28 @@
+package gemini
+
+import (
+	"xiaozhi-server-go/src/core/providers/vlllm"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewProvider 创建Gemini VLLLM提供者实例
+func NewProvider(config *vlllm.Config) (*vlllm.Provider, error) {
+	// 图片处理与流式输出由基础VLLLM Provider完成，按 type: gemini 调用 generateContent 多模态接口
+	provider, err := vlllm.NewProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"model_name": config.ModelName,
+		"base_url":   config.BaseURL,
+	}).Debug("Gemini VLLLM Provider创建成功")
+
+	return provider, nil
+}
+
+// init 注册Gemini VLLLM提供者
+func init() {
+	vlllm.Register("gemini", NewProvider)
+}
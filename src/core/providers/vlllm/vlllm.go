@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"regexp"
 	"strings"
@@ -14,6 +16,9 @@ import (
 	"xiaozhi-server-go/src/core/image"
 	"xiaozhi-server-go/src/core/providers"
 
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
 	"github.com/sirupsen/logrus"
 
 	"github.com/sashabaranov/go-openai"
@@ -29,6 +34,8 @@ type Config struct {
 	MaxTokens   int
 	TopP        float64
 	Security    configs.SecurityConfig
+	Clip        configs.ClipConfig
+	Preprocess  configs.PreprocessConfig
 	Data        map[string]interface{}
 }
 
@@ -39,7 +46,7 @@ type Provider struct {
 
 	// 直接的API客户端
 	openaiClient *openai.Client // 用于OpenAI类型
-	httpClient   *http.Client   // 用于Ollama类型
+	httpClient   *http.Client   // 用于Ollama、Gemini、Anthropic、DashScope类型
 }
 
 // OllamaRequest Ollama API请求结构
@@ -65,7 +72,9 @@ type OllamaResponse struct {
 		Role    string `json:"role"`
 		Content string `json:"content"`
 	} `json:"message"`
-	Done bool `json:"done"`
+	Done            bool `json:"done"`
+	PromptEvalCount int  `json:"prompt_eval_count"` // done 时返回的输入token数
+	EvalCount       int  `json:"eval_count"`        // done 时返回的输出token数
 }
 
 // NewProvider 创建新的VLLLM提供者
@@ -80,6 +89,8 @@ func NewProvider(config *Config) (*Provider, error) {
 		MaxTokens:   config.MaxTokens,
 		TopP:        config.TopP,
 		Security:    config.Security,
+		Clip:        config.Clip,
+		Preprocess:  config.Preprocess,
 	}
 
 	// 创建图片处理器
@@ -88,10 +99,15 @@ func NewProvider(config *Config) (*Provider, error) {
 		return nil, fmt.Errorf("创建图片处理器失败: %v", err)
 	}
 
+	httpClient, err := transport.NewHTTPClient(config.Data, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
 	provider := &Provider{
 		config:         config,
 		imageProcessor: imageProcessor,
-		httpClient:     &http.Client{Timeout: 30 * time.Second},
+		httpClient:     httpClient,
 	}
 
 	return provider, nil
@@ -110,6 +126,11 @@ func (p *Provider) Initialize() error {
 		if p.config.BaseURL != "" {
 			clientConfig.BaseURL = p.config.BaseURL
 		}
+		httpClient, err := transport.NewHTTPClient(p.config.Data, 0)
+		if err != nil {
+			return err
+		}
+		clientConfig.HTTPClient = httpClient
 		p.openaiClient = openai.NewClientWithConfig(clientConfig)
 
 	case "ollama":
@@ -122,6 +143,30 @@ func (p *Provider) Initialize() error {
 			"model":    p.config.ModelName,
 		}).Debug("Ollama VLLLM初始化成功")
 
+	case "gemini":
+		if p.config.APIKey == "" {
+			return fmt.Errorf("Gemini API key is required")
+		}
+		if p.config.BaseURL == "" {
+			p.config.BaseURL = defaultGeminiBaseURL
+		}
+
+	case "anthropic":
+		if p.config.APIKey == "" {
+			return fmt.Errorf("Anthropic API key is required")
+		}
+		if p.config.BaseURL == "" {
+			p.config.BaseURL = defaultAnthropicBaseURL
+		}
+
+	case "dashscope":
+		if p.config.APIKey == "" {
+			return fmt.Errorf("DashScope API key is required")
+		}
+		if p.config.BaseURL == "" {
+			p.config.BaseURL = defaultDashScopeBaseURL
+		}
+
 	default:
 		return fmt.Errorf("不支持的VLLLM类型: %s", p.config.Type)
 	}
@@ -146,262 +191,288 @@ func (p *Provider) Cleanup() error {
 }
 
 // ResponseWithImage 处理包含图片的请求 - 核心方法
-func (p *Provider) ResponseWithImage(ctx context.Context, sessionID string, messages []providers.Message, imageData image.ImageData, text string) (<-chan string, error) {
-	// 处理图片
-	base64Image, err := p.imageProcessor.ProcessImage(ctx, imageData)
+// 文本分片从第一个通道返回；流式过程中的错误在文本通道关闭后从第二个通道读取，
+// 均为 types.ProviderError，由调用方决定是否向用户致歉
+func (p *Provider) ResponseWithImage(ctx context.Context, sessionID string, messages []providers.Message, imageData image.ImageData, text string) (<-chan string, <-chan error, error) {
+	// 处理图片，启用抽帧时短视频和动图会被拆成多帧
+	frames, err := p.imageProcessor.ProcessFrames(ctx, imageData)
 	if err != nil {
-		return nil, fmt.Errorf("图片处理失败: %v", err)
+		return nil, nil, fmt.Errorf("图片处理失败: %v", err)
+	}
+	if len(frames) > 1 {
+		text = clipPrompt(frames, text)
 	}
 
+	imageSize := 0
+	for _, frame := range frames {
+		imageSize += len(frame.Data)
+	}
 	logrus.WithFields(logrus.Fields{
 		"type":       p.config.Type,
 		"model_name": p.config.ModelName,
 		"text":       text,
-		"image_size": len(base64Image),
+		"frames":     len(frames),
+		"image_size": imageSize,
 	}).Debug("开始调用多模态API")
 
 	// 根据类型调用对应的多模态API
 	switch strings.ToLower(p.config.Type) {
 	case "openai":
-		return p.responseWithOpenAIVision(ctx, messages, base64Image, text, imageData.Format)
+		return p.responseWithOpenAIVision(ctx, messages, frames, text)
 	case "ollama":
-		return p.responseWithOllamaVision(ctx, messages, base64Image, text, imageData.Format)
+		return p.responseWithOllamaVision(ctx, messages, frames, text)
+	case "gemini":
+		return p.responseWithGeminiVision(ctx, messages, frames, text)
+	case "anthropic":
+		return p.responseWithAnthropicVision(ctx, messages, frames, text)
+	case "dashscope":
+		return p.responseWithDashScopeVision(ctx, messages, frames, text)
 	default:
-		return nil, fmt.Errorf("不支持的VLLLM类型: %s", p.config.Type)
+		return nil, nil, fmt.Errorf("不支持的VLLLM类型: %s", p.config.Type)
 	}
 }
 
-// responseWithOpenAIVision 使用OpenAI Vision API
-func (p *Provider) responseWithOpenAIVision(ctx context.Context, messages []providers.Message, base64Image string, text string, format string) (<-chan string, error) {
+// clipPrompt 告诉模型多张图片是同一段视频按时间顺序抽取的画面，便于回答与动作相关的问题
+func clipPrompt(frames []image.Frame, text string) string {
+	offsets := make([]string, 0, len(frames))
+	for _, frame := range frames {
+		offsets = append(offsets, fmt.Sprintf("%.1fs", frame.Offset.Seconds()))
+	}
+	return fmt.Sprintf("以下%d张图片是同一段视频按时间顺序抽取的画面，时间点依次为 %s。请结合画面之间的变化回答：%s",
+		len(frames), strings.Join(offsets, "、"), text)
+}
+
+// streamResponses 在goroutine中读取流式回复，read 返回的错误在文本通道关闭前写入错误通道
+func streamResponses(name string, body io.Closer, read func(out chan<- string) error) (<-chan string, <-chan error) {
 	responseChan := make(chan string, 10)
+	errChan := make(chan error, 1)
 
 	go func() {
+		defer close(errChan)
 		defer close(responseChan)
+		defer body.Close()
 
-		// 构建OpenAI多模态消息
-		chatMessages := make([]openai.ChatCompletionMessage, 0, len(messages)+1)
-
-		// 添加历史消息
-		for _, msg := range messages {
-			chatMessages = append(chatMessages, openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
-			})
+		if err := read(responseChan); err != nil {
+			logrus.WithError(err).Error(name + "流式响应中断")
+			errChan <- err
+			return
 		}
+		logrus.Info(name + " API流式回复完成")
+	}()
 
-		// 构建包含图片的多模态消息
-		visionMessage := openai.ChatCompletionMessage{
-			Role: openai.ChatMessageRoleUser,
-			MultiContent: []openai.ChatMessagePart{
-				{
-					Type: openai.ChatMessagePartTypeText,
-					Text: text,
-				},
-				{
-					Type: openai.ChatMessagePartTypeImageURL,
-					ImageURL: &openai.ChatMessageImageURL{
-						URL: fmt.Sprintf("data:image/%s;base64,%s", format, base64Image),
-					},
-				},
-			},
-		}
-		// 打印visionMessage的内容
-		logrus.WithField("vision_message", visionMessage).Debug("构建的OpenAI Vision消息")
-		chatMessages = append(chatMessages, visionMessage)
-
-		// 调用OpenAI Vision API
-		stream, err := p.openaiClient.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:       p.config.ModelName,
-				Messages:    chatMessages,
-				Stream:      true,
-				Temperature: float32(p.config.Temperature),
-				TopP:        float32(p.config.TopP),
-			},
-		)
-		if err != nil {
-			responseChan <- fmt.Sprintf("【VLLLM服务响应异常: %v】", err)
-			logrus.WithError(err).Error("OpenAI Vision API调用失败")
-			logrus.WithFields(logrus.Fields{
-				"model_name":  p.config.ModelName,
-				"max_tokens":  p.config.MaxTokens,
-				"temperature": p.config.Temperature,
-				"top_p":       p.config.TopP,
-			}).Info("OpenAI Vision API调用失败")
+	return responseChan, errChan
+}
 
-			return
-		}
-		defer stream.Close()
+// responseWithOpenAIVision 使用OpenAI Vision API
+func (p *Provider) responseWithOpenAIVision(ctx context.Context, messages []providers.Message, frames []image.Frame, text string) (<-chan string, <-chan error, error) {
+	// 构建OpenAI多模态消息
+	chatMessages := make([]openai.ChatCompletionMessage, 0, len(messages)+1)
+
+	// 添加历史消息
+	for _, msg := range messages {
+		chatMessages = append(chatMessages, openai.ChatCompletionMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		})
+	}
+
+	// 构建包含图片的多模态消息
+	parts := []openai.ChatMessagePart{
+		{
+			Type: openai.ChatMessagePartTypeText,
+			Text: text,
+		},
+	}
+	for _, frame := range frames {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{
+				URL: fmt.Sprintf("data:image/%s;base64,%s", frame.Format, frame.Data),
+			},
+		})
+	}
+	visionMessage := openai.ChatCompletionMessage{
+		Role:         openai.ChatMessageRoleUser,
+		MultiContent: parts,
+	}
+	// 打印visionMessage的内容
+	logrus.WithField("vision_message", visionMessage).Debug("构建的OpenAI Vision消息")
+	chatMessages = append(chatMessages, visionMessage)
+
+	// 调用OpenAI Vision API
+	stream, err := p.openaiClient.CreateChatCompletionStream(
+		ctx,
+		openai.ChatCompletionRequest{
+			Model:         p.config.ModelName,
+			Messages:      chatMessages,
+			Stream:        true,
+			StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+			MaxTokens:     p.config.MaxTokens,
+			Temperature:   float32(p.config.Temperature),
+			TopP:          float32(p.config.TopP),
+		},
+	)
+	if err != nil {
+		logrus.WithError(err).Error("OpenAI Vision API调用失败")
+		logrus.WithFields(logrus.Fields{
+			"model_name":  p.config.ModelName,
+			"max_tokens":  p.config.MaxTokens,
+			"temperature": p.config.Temperature,
+			"top_p":       p.config.TopP,
+		}).Info("OpenAI Vision API调用失败")
+		return nil, nil, types.NewProviderError("vlllm-openai", err)
+	}
 
-		logrus.Info("OpenAI Vision API调用成功，开始接收流式回复")
+	logrus.Info("OpenAI Vision API调用成功，开始接收流式回复")
 
-		isActive := true
+	responses, errs := streamResponses("OpenAI Vision", stream, func(out chan<- string) error {
 		for {
 			response, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
 			if err != nil {
-				break
+				return types.NewProviderError("vlllm-openai", err)
 			}
 
+			if response.Usage != nil {
+				types.ReportUsage(ctx, types.Usage{
+					Model:            response.Model,
+					PromptTokens:     response.Usage.PromptTokens,
+					CompletionTokens: response.Usage.CompletionTokens,
+				})
+			}
 			if len(response.Choices) > 0 {
-				content := response.Choices[0].Delta.Content
-				if content != "" {
-					// 处理思考标签
-					if content, isActive = p.handleThinkTags(content, isActive); content != "" {
-						responseChan <- content
+				// 思考标签等由调用方的后处理链处理
+				if content := response.Choices[0].Delta.Content; content != "" {
+					select {
+					case out <- content:
+					case <-ctx.Done():
+						return ctx.Err()
 					}
 				}
 			}
 		}
-
-		logrus.Info("OpenAI Vision API流式回复完成")
-	}()
-
-	return responseChan, nil
+	})
+	return responses, errs, nil
 }
 
 // responseWithOllamaVision 使用Ollama Vision API
-func (p *Provider) responseWithOllamaVision(ctx context.Context, messages []providers.Message, base64Image string, text string, format string) (<-chan string, error) {
-	responseChan := make(chan string, 10)
-
-	go func() {
-		defer close(responseChan)
+func (p *Provider) responseWithOllamaVision(ctx context.Context, messages []providers.Message, frames []image.Frame, text string) (<-chan string, <-chan error, error) {
+	// 构建Ollama请求
+	ollamaMessages := make([]OllamaMessage, 0, len(messages)+1)
+
+	// 添加历史消息
+	for _, msg := range messages {
+		ollamaMessages = append(ollamaMessages, OllamaMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		})
+	}
 
-		// 构建Ollama请求
-		ollamaMessages := make([]OllamaMessage, 0, len(messages)+1)
+	// 添加包含图片的用户消息，Ollama需要纯base64，不需要data URL前缀
+	images := make([]string, 0, len(frames))
+	for _, frame := range frames {
+		images = append(images, frame.Data)
+	}
+	visionMessage := OllamaMessage{
+		Role:    "user",
+		Content: text,
+		Images:  images,
+	}
+	ollamaMessages = append(ollamaMessages, visionMessage)
+
+	// 构建请求
+	request := OllamaRequest{
+		Model:    p.config.ModelName,
+		Messages: ollamaMessages,
+		Stream:   true,
+		Options: map[string]interface{}{
+			"temperature": p.config.Temperature,
+			"top_p":       p.config.TopP,
+		},
+	}
+	if p.config.MaxTokens > 0 {
+		request.Options["num_predict"] = p.config.MaxTokens
+	}
 
-		// 添加历史消息
-		for _, msg := range messages {
-			ollamaMessages = append(ollamaMessages, OllamaMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
-			})
-		}
+	// 序列化请求
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("请求序列化失败: %v", err)
+	}
 
-		// 添加包含图片的用户消息
-		visionMessage := OllamaMessage{
-			Role:    "user",
-			Content: text,
-			Images:  []string{base64Image}, // Ollama需要纯base64，不需要data URL前缀
-		}
-		ollamaMessages = append(ollamaMessages, visionMessage)
-
-		// 构建请求
-		request := OllamaRequest{
-			Model:    p.config.ModelName,
-			Messages: ollamaMessages,
-			Stream:   true,
-			Options: map[string]interface{}{
-				"temperature": p.config.Temperature,
-				"top_p":       p.config.TopP,
-			},
-		}
+	// 发送请求到Ollama
+	url := fmt.Sprintf("%s/api/chat", strings.TrimSuffix(p.config.BaseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建请求失败: %v", err)
+	}
 
-		// 序列化请求
-		requestBody, err := json.Marshal(request)
-		if err != nil {
-			responseChan <- fmt.Sprintf("【请求序列化失败: %v】", err)
-			logrus.WithError(err).Error("Ollama请求序列化失败")
-			return
-		}
+	req.Header.Set("Content-Type", "application/json")
 
-		// 发送请求到Ollama
-		url := fmt.Sprintf("%s/api/chat", strings.TrimSuffix(p.config.BaseURL, "/"))
-		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
-		if err != nil {
-			responseChan <- fmt.Sprintf("【创建请求失败: %v】", err)
-			logrus.WithError(err).Error("创建Ollama请求失败")
-			return
-		}
+	logrus.WithFields(logrus.Fields{
+		"url":   url,
+		"model": p.config.ModelName,
+		"text":  text,
+	}).Info("向Ollama发送多模态请求")
 
-		req.Header.Set("Content-Type", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		logrus.WithError(err).Error("Ollama API调用失败")
+		return nil, nil, types.NewProviderError("vlllm-ollama", err)
+	}
 
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
 		logrus.WithFields(logrus.Fields{
-			"url":   url,
-			"model": p.config.ModelName,
-			"text":  text,
-		}).Info("向Ollama发送多模态请求")
-
-		resp, err := p.httpClient.Do(req)
-		if err != nil {
-			responseChan <- fmt.Sprintf("【Ollama API调用失败: %v】", err)
-			logrus.WithError(err).Error("Ollama API调用失败")
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			responseChan <- fmt.Sprintf("【Ollama API返回错误: %d】", resp.StatusCode)
-			logrus.WithFields(logrus.Fields{
-				"status_code": resp.StatusCode,
-				"status":      resp.Status,
-			}).Error("Ollama API返回错误")
-			return
-		}
+			"status_code": resp.StatusCode,
+			"status":      resp.Status,
+		}).Error("Ollama API返回错误")
+		return nil, nil, types.NewHTTPError("vlllm-ollama", resp.StatusCode, string(body))
+	}
 
-		logrus.Info("Ollama Vision API调用成功，开始接收流式回复")
+	logrus.Info("Ollama Vision API调用成功，开始接收流式回复")
 
+	responses, errs := streamResponses("Ollama Vision", resp.Body, func(out chan<- string) error {
 		// 处理流式响应
 		decoder := json.NewDecoder(resp.Body)
-		isActive := true
 
 		for {
 			var response OllamaResponse
 			if err := decoder.Decode(&response); err != nil {
-				if err.Error() != "EOF" {
-					logrus.WithError(err).Error("解析Ollama响应失败")
+				if errors.Is(err, io.EOF) {
+					return nil
 				}
-				break
+				return types.NewProviderError("vlllm-ollama", fmt.Errorf("解析Ollama响应失败: %v", err))
 			}
 
-			content := response.Message.Content
-			if content != "" {
-				// 处理思考标签
-				if content, isActive = p.handleThinkTags(content, isActive); content != "" {
-					responseChan <- content
+			// 思考标签等由调用方的后处理链处理
+			if content := response.Message.Content; content != "" {
+				select {
+				case out <- content:
+				case <-ctx.Done():
+					return ctx.Err()
 				}
 			}
 
 			if response.Done {
-				break
+				types.ReportUsage(ctx, types.Usage{
+					Model:            response.Model,
+					PromptTokens:     response.PromptEvalCount,
+					CompletionTokens: response.EvalCount,
+				})
+				return nil
 			}
 		}
-
-		logrus.Info("Ollama Vision API流式回复完成")
-	}()
-
-	return responseChan, nil
+	})
+	return responses, errs, nil
 }
 
-// Response 普通文本响应（降级处理）
+// Response 普通文本请求，VLLLM不支持，返回错误由调用方降级到LLM
 func (p *Provider) Response(ctx context.Context, sessionID string, messages []providers.Message) (<-chan string, error) {
-	// 如果没有图片，就作为普通文本处理
-	responseChan := make(chan string, 1)
-	go func() {
-		defer close(responseChan)
-		responseChan <- "VLLLM Provider只支持图片处理，普通文本请使用LLM Provider"
-	}()
-	return responseChan, nil
-}
-
-// handleThinkTags 处理思考标签
-func (p *Provider) handleThinkTags(content string, isActive bool) (string, bool) {
-	if content == "" {
-		return "", isActive
-	}
-
-	if content == "<think>" {
-		return "", false
-	}
-	if content == "</think>" {
-		return "", true
-	}
-
-	if !isActive {
-		return "", isActive
-	}
-
-	return content, isActive
+	return nil, types.NewProviderError("vlllm", errors.New("VLLLM Provider只支持图片处理，普通文本请使用LLM Provider"))
 }
 
 // detectMultimodalMessage 检测是否为多模态消息（向后兼容）
@@ -0,0 +1,202 @@
+package openwakeword
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/providers/wakeword"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sampleRate  = 16000
+	dialTimeout = 10 * time.Second
+)
+
+// Provider 连接 openWakeWord 流式检测服务（examples/web/streaming_server.py 协议）
+// 建立连接后先发送采样率文本，之后发送16位PCM，服务端在模型得分超过阈值时返回 {"activations": [模型名]}
+// 检测阈值在服务端设置；唤醒词通过 models 映射到服务端加载的模型名称，自定义唤醒词需要在服务端加载对应模型
+type Provider struct {
+	config *wakeword.Config
+	addr   string
+	models map[string]string // 唤醒词 -> 模型名称
+
+	mu       sync.Mutex
+	conn     *websocket.Conn
+	active   map[string]string // 当前检测的模型名称 -> 唤醒词
+	detected chan string
+}
+
+// NewProvider 创建openWakeWord检测提供者，首次输入音频时才建立连接
+func NewProvider(config *wakeword.Config) (*Provider, error) {
+	addr, _ := config.Extra["addr"].(string)
+	if addr == "" {
+		return nil, fmt.Errorf("缺少openWakeWord服务地址 addr")
+	}
+	p := &Provider{
+		config:   config,
+		addr:     addr,
+		models:   config.StringMapExtra("models"),
+		detected: make(chan string, 1),
+	}
+	if err := p.SetKeywords(nil); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SampleRate 实现 wakeword.Provider 接口
+func (p *Provider) SampleRate() int {
+	return sampleRate
+}
+
+// SetKeywords 实现 wakeword.Provider 接口，没有对应模型的唤醒词会返回错误
+func (p *Provider) SetKeywords(keywords []string) error {
+	active := make(map[string]string)
+	for _, keyword := range p.config.ActiveKeywords(keywords) {
+		model, ok := p.models[keyword]
+		if !ok {
+			return fmt.Errorf("唤醒词 %s 没有对应的openWakeWord模型，请在 models 中配置", keyword)
+		}
+		active[model] = keyword
+	}
+	if len(active) == 0 {
+		return fmt.Errorf("没有可检测的唤醒词")
+	}
+	p.mu.Lock()
+	p.active = active
+	p.mu.Unlock()
+	return nil
+}
+
+// AcceptAudio 实现 wakeword.Provider 接口，检测结果异步返回，在之后的调用中取出
+func (p *Provider) AcceptAudio(samples []float32) (string, error) {
+	select {
+	case keyword := <-p.detected:
+		return keyword, nil
+	default:
+	}
+
+	conn, err := p.connect()
+	if err != nil {
+		return "", err
+	}
+	data := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(int16(max(-1, min(s, 1))*32767)))
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		p.closeConn(conn)
+		return "", fmt.Errorf("发送音频到openWakeWord失败: %v", err)
+	}
+	return "", nil
+}
+
+// connect 建立到检测服务的连接并启动读取协程
+func (p *Provider) connect() (*websocket.Conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		return p.conn, nil
+	}
+
+	dialer, err := transport.NewDialer(p.config.Extra)
+	if err != nil {
+		return nil, err
+	}
+	dialer.HandshakeTimeout = dialTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	conn, _, err := dialer.DialContext(ctx, p.addr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("连接openWakeWord服务失败: %v", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(strconv.Itoa(sampleRate))); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("发送采样率失败: %v", err)
+	}
+	p.conn = conn
+	go p.readLoop(conn)
+	return conn, nil
+}
+
+// readLoop 读取检测结果，只上报当前设备启用的唤醒词
+func (p *Provider) readLoop(conn *websocket.Conn) {
+	defer p.closeConn(conn)
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			logrus.WithError(err).Debug("openWakeWord连接已断开")
+			return
+		}
+		var result struct {
+			Activations []string `json:"activations"`
+		}
+		if err := json.Unmarshal(message, &result); err != nil {
+			logrus.WithError(err).Warn("解析openWakeWord结果失败")
+			continue
+		}
+		for _, model := range result.Activations {
+			p.mu.Lock()
+			keyword, ok := p.active[model]
+			p.mu.Unlock()
+			if !ok {
+				continue
+			}
+			select {
+			case p.detected <- keyword:
+			default:
+			}
+			break
+		}
+	}
+}
+
+// closeConn 关闭连接，下次输入音频时重新连接
+func (p *Provider) closeConn(conn *websocket.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conn.Close()
+	if p.conn == conn {
+		p.conn = nil
+	}
+}
+
+// Reset 实现 wakeword.Provider 接口，丢弃尚未取出的检测结果并断开连接，服务端的模型缓冲随之清空
+func (p *Provider) Reset() error {
+	select {
+	case <-p.detected:
+	default:
+	}
+	p.mu.Lock()
+	conn := p.conn
+	p.mu.Unlock()
+	if conn != nil {
+		p.closeConn(conn)
+	}
+	return nil
+}
+
+// Initialize 实现Provider接口的Initialize方法
+func (p *Provider) Initialize() error {
+	return nil
+}
+
+// Cleanup 实现Provider接口的Cleanup方法
+func (p *Provider) Cleanup() error {
+	return p.Reset()
+}
+
+func init() {
+	wakeword.Register("openwakeword", func(config *wakeword.Config) (wakeword.Provider, error) {
+		return NewProvider(config)
+	})
+}
@@ -0,0 +1,186 @@
+//go:build sherpa
+
+// sherpa-onnx 关键词检测（KWS）在进程内运行，需要CGO和sherpa-onnx动态库：
+//
+//	go get github.com/k2-fsa/sherpa-onnx-go
+//	go build -tags sherpa ./src/main.go
+//
+// 未使用 sherpa 编译标签时见 sherpa_disabled.go
+package sherpa
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"xiaozhi-server-go/src/core/providers/wakeword"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+const (
+	sampleRate        = 16000
+	defaultNumThreads = 1
+	defaultThreshold  = 0.25
+)
+
+// Provider sherpa-onnx 关键词检测
+// 唤醒词需要先切分为模型的建模单元，在 keywords_tokens 中配置唤醒词到切分结果的映射，如 小智: "x iǎo zh ì"
+type Provider struct {
+	config   *wakeword.Config
+	keywords map[string]string // 唤醒词 -> 建模单元
+
+	mu      sync.Mutex
+	spotter *sherpa.KeywordSpotter
+	stream  *sherpa.OnlineStream
+	spec    string // 当前检测的唤醒词，格式为 "建模单元 @唤醒词"，多个以 / 分隔
+}
+
+// NewProvider 加载关键词检测模型
+func NewProvider(config *wakeword.Config) (*Provider, error) {
+	p := &Provider{
+		config:   config,
+		keywords: config.StringMapExtra("keywords_tokens"),
+	}
+	spec, err := p.buildSpec(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	modelDir, _ := config.Extra["model_dir"].(string)
+	threshold := config.Threshold
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+	numThreads := defaultNumThreads
+	if n, ok := config.Extra["num_threads"].(int); ok && n > 0 {
+		numThreads = n
+	}
+	kwsConfig := sherpa.KeywordSpotterConfig{}
+	kwsConfig.FeatConfig.SampleRate = sampleRate
+	kwsConfig.FeatConfig.FeatureDim = 80
+	kwsConfig.ModelConfig.Transducer.Encoder = filepath.Join(modelDir, modelFile(config, "encoder"))
+	kwsConfig.ModelConfig.Transducer.Decoder = filepath.Join(modelDir, modelFile(config, "decoder"))
+	kwsConfig.ModelConfig.Transducer.Joiner = filepath.Join(modelDir, modelFile(config, "joiner"))
+	kwsConfig.ModelConfig.Tokens = filepath.Join(modelDir, modelFile(config, "tokens"))
+	kwsConfig.ModelConfig.NumThreads = numThreads
+	kwsConfig.ModelConfig.Provider = "cpu"
+	kwsConfig.KeywordsThreshold = float32(threshold)
+	kwsConfig.KeywordsScore = 1.0
+	kwsConfig.KeywordsBuf = strings.ReplaceAll(spec, "/", "\n")
+	kwsConfig.KeywordsBufSize = len(kwsConfig.KeywordsBuf)
+
+	p.spotter = sherpa.NewKeywordSpotter(&kwsConfig)
+	if p.spotter == nil {
+		return nil, fmt.Errorf("加载sherpa-onnx关键词检测模型失败，请检查 %s", modelDir)
+	}
+	p.resetStream(spec)
+	return p, nil
+}
+
+// modelFile 模型文件名，可在配置中覆盖
+func modelFile(config *wakeword.Config, name string) string {
+	if file, ok := config.Extra[name].(string); ok && file != "" {
+		return file
+	}
+	if name == "tokens" {
+		return "tokens.txt"
+	}
+	return name + ".onnx"
+}
+
+// buildSpec 把唤醒词转换为sherpa-onnx的关键词格式
+func (p *Provider) buildSpec(keywords []string) (string, error) {
+	var specs []string
+	for _, keyword := range p.config.ActiveKeywords(keywords) {
+		tokens, ok := p.keywords[keyword]
+		if !ok {
+			return "", fmt.Errorf("唤醒词 %s 没有配置建模单元，请在 keywords_tokens 中配置", keyword)
+		}
+		specs = append(specs, fmt.Sprintf("%s @%s", tokens, keyword))
+	}
+	if len(specs) == 0 {
+		return "", fmt.Errorf("没有可检测的唤醒词")
+	}
+	return strings.Join(specs, "/"), nil
+}
+
+// resetStream 以指定唤醒词重新创建检测流，调用方持有锁或尚未并发使用
+func (p *Provider) resetStream(spec string) {
+	if p.stream != nil {
+		sherpa.DeleteOnlineStream(p.stream)
+	}
+	p.spec = spec
+	p.stream = sherpa.NewKeywordStreamWithKeywords(p.spotter, spec)
+}
+
+// SampleRate 实现 wakeword.Provider 接口
+func (p *Provider) SampleRate() int {
+	return sampleRate
+}
+
+// SetKeywords 实现 wakeword.Provider 接口
+func (p *Provider) SetKeywords(keywords []string) error {
+	spec, err := p.buildSpec(keywords)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resetStream(spec)
+	return nil
+}
+
+// AcceptAudio 实现 wakeword.Provider 接口
+func (p *Provider) AcceptAudio(samples []float32) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream == nil {
+		return "", fmt.Errorf("关键词检测已释放")
+	}
+	p.stream.AcceptWaveform(sampleRate, samples)
+	for p.spotter.IsReady(p.stream) {
+		p.spotter.Decode(p.stream)
+		if keyword := p.spotter.GetResult(p.stream).Keyword; keyword != "" {
+			return keyword, nil
+		}
+	}
+	return "", nil
+}
+
+// Reset 实现 wakeword.Provider 接口
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream != nil {
+		p.resetStream(p.spec)
+	}
+	return nil
+}
+
+// Initialize 实现Provider接口的Initialize方法
+func (p *Provider) Initialize() error {
+	return nil
+}
+
+// Cleanup 实现Provider接口的Cleanup方法
+func (p *Provider) Cleanup() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream != nil {
+		sherpa.DeleteOnlineStream(p.stream)
+		p.stream = nil
+	}
+	if p.spotter != nil {
+		sherpa.DeleteKeywordSpotter(p.spotter)
+		p.spotter = nil
+	}
+	return nil
+}
+
+func init() {
+	wakeword.Register("sherpa", func(config *wakeword.Config) (wakeword.Provider, error) {
+		return NewProvider(config)
+	})
+}
@@ -0,0 +1,16 @@
+//go:build !sherpa
+
+package sherpa
+
+import (
+	"fmt"
+
+	"xiaozhi-server-go/src/core/providers/wakeword"
+)
+
+// 未使用 sherpa 编译标签构建时没有sherpa-onnx，创建时返回错误
+func init() {
+	wakeword.Register("sherpa", func(config *wakeword.Config) (wakeword.Provider, error) {
+		return nil, fmt.Errorf("当前程序未包含sherpa-onnx关键词检测，请使用 -tags sherpa 重新编译")
+	})
+}
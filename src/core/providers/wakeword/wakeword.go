@@ -0,0 +1,76 @@
+package wakeword
+
+import (
+	"fmt"
+
+	"xiaozhi-server-go/src/core/providers"
+)
+
+// Config 唤醒词检测配置结构
+type Config struct {
+	Type      string
+	Keywords  []string // 默认唤醒词，设备未单独设置时使用
+	Threshold float64  // 检测阈值，含义由具体实现决定
+	Extra     map[string]interface{}
+}
+
+// Provider 唤醒词检测提供者接口
+type Provider interface {
+	providers.Provider
+
+	// SampleRate 要求输入的采样率
+	SampleRate() int
+	// SetKeywords 设置要检测的唤醒词，为空时恢复默认唤醒词
+	SetKeywords(keywords []string) error
+	// AcceptAudio 输入归一化到-1~1的单声道采样，检测到唤醒词时返回该唤醒词，否则返回空字符串
+	AcceptAudio(samples []float32) (string, error)
+	// Reset 清除已输入的音频，避免同一段唤醒词被重复检测
+	Reset() error
+}
+
+// Factory 唤醒词检测工厂函数类型
+type Factory func(config *Config) (Provider, error)
+
+var (
+	factories = make(map[string]Factory)
+)
+
+// Register 注册唤醒词检测提供者工厂
+func Register(name string, factory Factory) {
+	factories[name] = factory
+}
+
+// Create 创建唤醒词检测提供者实例
+func Create(name string, config *Config) (Provider, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的唤醒词检测提供者: %s", name)
+	}
+
+	provider, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建唤醒词检测提供者失败: %v", err)
+	}
+
+	return provider, nil
+}
+
+// StringMapExtra 读取额外配置中的字符串映射，如唤醒词到模型名称的映射
+func (c *Config) StringMapExtra(key string) map[string]string {
+	result := make(map[string]string)
+	raw, _ := c.Extra[key].(map[string]interface{})
+	for k, v := range raw {
+		if s, ok := v.(string); ok {
+			result[k] = s
+		}
+	}
+	return result
+}
+
+// ActiveKeywords 实际生效的唤醒词：设备设置优先，否则使用默认唤醒词
+func (c *Config) ActiveKeywords(keywords []string) []string {
+	if len(keywords) > 0 {
+		return keywords
+	}
+	return c.Keywords
+}
@@ -0,0 +1,305 @@
+// Package recording 按会话和轮次保存对话录音（用户说的话和合成的回复），录音索引保存在数据库中
+package recording
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// 录音类型
+const (
+	KindUser  = "user"  // 用户说的话
+	KindReply = "reply" // 合成的回复，每句一段
+)
+
+const (
+	defaultDir      = "data/recordings"
+	cleanupInterval = time.Hour
+	cleanupBatch    = 500
+	maxPageSize     = 100
+)
+
+// Meta 录音所属的对话
+type Meta struct {
+	SessionID string
+	DeviceID  string
+	Round     int
+	Sentence  int    // 回复中的句子序号
+	Text      string // 识别结果或回复文本
+}
+
+// Filter 录音查询条件，空值表示不过滤
+type Filter struct {
+	DeviceID  string
+	SessionID string
+	Round     int // 大于0时只查询该轮次
+	Kind      string
+	Page      int // 从1开始
+	PageSize  int
+}
+
+// Store 录音存储，所有连接共享
+type Store struct {
+	dir       string
+	db        *gorm.DB
+	user      bool
+	reply     bool
+	retention time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New 按配置创建录音存储，未启用时返回nil；配置了保留天数时定期清理过期录音
+func New(cfg configs.RecordingConfig, db *gorm.DB) (*Store, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if db == nil {
+		return nil, fmt.Errorf("保存录音需要连接数据库")
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		dir = defaultDir
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建录音目录失败: %v", err)
+	}
+	s := &Store{
+		dir:       dir,
+		db:        db,
+		user:      cfg.User,
+		reply:     cfg.Reply,
+		retention: time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+		stop:      make(chan struct{}),
+	}
+	if s.retention > 0 {
+		go s.cleanupLoop()
+	}
+	return s, nil
+}
+
+// RecordsUser 是否保存用户说的话
+func (s *Store) RecordsUser() bool {
+	return s != nil && s.user
+}
+
+// RecordsReply 是否保存合成的回复
+func (s *Store) RecordsReply() bool {
+	return s != nil && s.reply
+}
+
+// SaveUser 把用户一句话的16位PCM保存为WAV
+func (s *Store) SaveUser(meta Meta, pcm []byte, sampleRate, channels int) (*models.Recording, error) {
+	if len(pcm) == 0 || sampleRate <= 0 || channels <= 0 {
+		return nil, fmt.Errorf("录音为空")
+	}
+	rel, path, err := s.newPath(meta, KindUser, "wav")
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.SaveAudioToWavFile(pcm, path, sampleRate, channels, 16); err != nil {
+		return nil, fmt.Errorf("保存录音失败: %v", err)
+	}
+	durationMs := int64(len(pcm)) * 1000 / int64(sampleRate*channels*2)
+	return s.insert(meta, KindUser, rel, "wav", int64(len(pcm))+44, durationMs)
+}
+
+// SaveReply 保存回复一句话合成的音频，format 为音频格式（如 mp3、wav），durationMs 未知时为0
+func (s *Store) SaveReply(meta Meta, format string, data []byte, durationMs int64) (*models.Recording, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("录音为空")
+	}
+	rel, path, err := s.newPath(meta, KindReply, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("保存录音失败: %v", err)
+	}
+	return s.insert(meta, KindReply, rel, format, int64(len(data)), durationMs)
+}
+
+// DetectFormat 按文件头判断合成音频的格式，TTS只输出WAV或MP3
+func DetectFormat(data []byte) string {
+	if len(data) >= 4 && string(data[:4]) == "RIFF" {
+		return "wav"
+	}
+	return "mp3"
+}
+
+// newPath 生成录音文件路径：日期/会话ID/轮次-类型[-句子序号].格式，返回相对路径和完整路径
+func (s *Store) newPath(meta Meta, kind, format string) (string, string, error) {
+	name := fmt.Sprintf("%03d-%s", meta.Round, kind)
+	if kind == KindReply {
+		name += fmt.Sprintf("-%03d", meta.Sentence)
+	}
+	rel := filepath.Join(time.Now().Format("20060102"), safeName(meta.SessionID), name+"."+safeName(format))
+	path := filepath.Join(s.dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", "", fmt.Errorf("创建录音目录失败: %v", err)
+	}
+	// 同一轮次重复保存（如重新合成）时加序号，不覆盖之前的录音
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		rel = filepath.Join(filepath.Dir(rel), fmt.Sprintf("%s-%d.%s", name, i, safeName(format)))
+		path = filepath.Join(s.dir, rel)
+	}
+	return filepath.ToSlash(rel), path, nil
+}
+
+// safeName 只保留字母、数字、短横线和下划线，避免会话ID中的特殊字符跳出录音目录
+func safeName(name string) string {
+	if name == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func (s *Store) insert(meta Meta, kind, rel, format string, size, durationMs int64) (*models.Recording, error) {
+	record := &models.Recording{
+		SessionID:  meta.SessionID,
+		DeviceID:   meta.DeviceID,
+		Round:      meta.Round,
+		Kind:       kind,
+		Sentence:   meta.Sentence,
+		Text:       meta.Text,
+		Path:       rel,
+		Format:     format,
+		Size:       size,
+		DurationMs: durationMs,
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		os.Remove(filepath.Join(s.dir, filepath.FromSlash(rel)))
+		return nil, fmt.Errorf("保存录音索引失败: %v", err)
+	}
+	return record, nil
+}
+
+// List 按条件分页查询录音，最新保存的在前，返回本页录音和总数
+func (s *Store) List(filter Filter) ([]models.Recording, int64, error) {
+	query := s.db.Model(&models.Recording{})
+	if filter.DeviceID != "" {
+		query = query.Where("device_id = ?", filter.DeviceID)
+	}
+	if filter.SessionID != "" {
+		query = query.Where("session_id = ?", filter.SessionID)
+	}
+	if filter.Round > 0 {
+		query = query.Where("round = ?", filter.Round)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询录音失败: %v", err)
+	}
+
+	page, size := Page(filter.Page, filter.PageSize)
+	var recordings []models.Recording
+	err := query.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&recordings).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询录音失败: %v", err)
+	}
+	return recordings, total, nil
+}
+
+// Page 规范分页参数：页码从1开始，每页默认20条，最多100条
+func Page(page, size int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if size <= 0 {
+		size = 20
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	return page, size
+}
+
+// Get 查询一段录音，返回录音和音频文件的完整路径，不存在时返回 gorm.ErrRecordNotFound
+func (s *Store) Get(id int64) (*models.Recording, string, error) {
+	var record models.Recording
+	if err := s.db.Where("id = ?", id).Take(&record).Error; err != nil {
+		return nil, "", err
+	}
+	return &record, filepath.Join(s.dir, filepath.FromSlash(record.Path)), nil
+}
+
+// Cleanup 删除超过保留天数的录音，返回删除的数量
+func (s *Store) Cleanup() (int64, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-s.retention)
+	var deleted int64
+	for {
+		var expired []models.Recording
+		err := s.db.Select("id", "path").Where("created_at < ?", cutoff).Limit(cleanupBatch).Find(&expired).Error
+		if err != nil {
+			return deleted, fmt.Errorf("查询过期录音失败: %v", err)
+		}
+		if len(expired) == 0 {
+			return deleted, nil
+		}
+		ids := make([]int64, 0, len(expired))
+		for _, record := range expired {
+			path := filepath.Join(s.dir, filepath.FromSlash(record.Path))
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				logrus.Warnf("删除录音文件失败: %v", err)
+			}
+			// 会话和日期目录为空时一并删除，不为空时删除失败，忽略
+			os.Remove(filepath.Dir(path))
+			os.Remove(filepath.Dir(filepath.Dir(path)))
+			ids = append(ids, record.ID)
+		}
+		if err := s.db.Where("id IN ?", ids).Delete(&models.Recording{}).Error; err != nil {
+			return deleted, fmt.Errorf("删除过期录音失败: %v", err)
+		}
+		deleted += int64(len(ids))
+	}
+}
+
+// cleanupLoop 定期清理过期录音，直到 Close
+func (s *Store) cleanupLoop() {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.Cleanup(); err != nil {
+			logrus.Errorf("清理过期录音失败: %v", err)
+		} else if n > 0 {
+			logrus.Infof("已清理 %d 段过期录音", n)
+		}
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close 停止定期清理
+func (s *Store) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	return nil
+}
@@ -0,0 +1,110 @@
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/sirupsen/logrus"
+)
+
+// webhookPayload webhook 回调请求体
+type webhookPayload struct {
+	Rule string `json:"rule"`
+	Event
+}
+
+// execute 依次执行规则的所有动作，单个动作失败不影响后续动作
+func (e *Engine) execute(ctx context.Context, rule *configs.RuleConfig, event Event) error {
+	var errs []string
+	for _, action := range rule.Actions {
+		var err error
+		switch action.Type {
+		case ActionWebhook:
+			err = e.callWebhook(ctx, rule, action, event)
+		case ActionDeviceCommand:
+			err = e.pushToDevice(action, event, copyPayload(action.Payload))
+		case ActionNotification:
+			err = e.pushToDevice(action, event, map[string]interface{}{
+				"type":    "alert",
+				"status":  expand(action.Title, event),
+				"message": expand(action.Message, event),
+				"emotion": "neutral",
+			})
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"rule":   rule.Name,
+				"action": action.Type,
+			}).Warn(fmt.Sprintf("规则动作执行失败: %v", err))
+			errs = append(errs, fmt.Sprintf("%s: %v", action.Type, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("规则 %s 执行失败: %s", rule.Name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// callWebhook 将事件以JSON POST到回调地址
+func (e *Engine) callWebhook(ctx context.Context, rule *configs.RuleConfig, action configs.RuleActionConfig, event Event) error {
+	data, err := json.Marshal(webhookPayload{Rule: rule.Name, Event: event})
+	if err != nil {
+		return fmt.Errorf("序列化回调数据失败: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, action.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("创建回调请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range action.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("回调请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("回调返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// pushToDevice 向目标设备下发消息，未指定目标时发给触发规则的设备
+func (e *Engine) pushToDevice(action configs.RuleActionConfig, event Event, msg map[string]interface{}) error {
+	if e.messenger == nil {
+		return fmt.Errorf("设备消息下发器未设置")
+	}
+	deviceID := action.DeviceID
+	if deviceID == "" {
+		deviceID = event.DeviceID
+	}
+	return e.messenger.PushToDevice(deviceID, msg)
+}
+
+// copyPayload 复制下发消息，避免多次触发共享同一个map
+func copyPayload(payload map[string]interface{}) map[string]interface{} {
+	msg := make(map[string]interface{}, len(payload))
+	for key, value := range payload {
+		msg[key] = value
+	}
+	return msg
+}
+
+// expand 替换消息模板中的事件占位符
+func expand(template string, event Event) string {
+	return strings.NewReplacer(
+		"{device_id}", event.DeviceID,
+		"{text}", event.Text,
+		"{intent}", event.Intent,
+	).Replace(template)
+}
@@ -0,0 +1,228 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/task"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TriggerType 规则触发类型
+type TriggerType string
+
+const (
+	TriggerIntent        TriggerType = "intent"         // LLM调用了指定函数
+	TriggerKeyword       TriggerType = "keyword"        // 用户语音中出现关键词
+	TriggerDeviceOffline TriggerType = "device_offline" // 设备断开连接
+)
+
+// 规则动作类型
+const (
+	ActionWebhook       = "webhook"
+	ActionDeviceCommand = "device_command"
+	ActionNotification  = "notification"
+)
+
+// TaskTypeRuleAction 规则动作任务，动作在任务管理器的工作池中执行，不阻塞对话
+const TaskTypeRuleAction task.TaskType = "rule_action"
+
+func init() {
+	task.RegisterTaskExecutor(TaskTypeRuleAction, func(t *task.Task) error {
+		run, ok := t.Params.(*ruleRun)
+		if !ok {
+			return fmt.Errorf("invalid params for task type %v", TaskTypeRuleAction)
+		}
+		return run.engine.execute(t.Context, run.rule, run.event)
+	})
+}
+
+// Event 触发规则的事件
+type Event struct {
+	Type     TriggerType `json:"trigger"`
+	DeviceID string      `json:"device_id"`
+	Text     string      `json:"text,omitempty"`   // 用户语音识别文本
+	Intent   string      `json:"intent,omitempty"` // 函数调用名称
+	Time     time.Time   `json:"time"`
+}
+
+// DeviceMessenger 向在线设备下发消息
+type DeviceMessenger interface {
+	PushToDevice(deviceID string, msg map[string]interface{}) error
+}
+
+// ruleRun 规则动作任务参数
+type ruleRun struct {
+	engine *Engine
+	rule   *configs.RuleConfig
+	event  Event
+}
+
+// Engine 自动化规则引擎，由连接处理器和服务器上报事件，匹配的规则提交到任务管理器异步执行
+type Engine struct {
+	rules     []*configs.RuleConfig
+	taskMgr   *task.TaskManager
+	messenger DeviceMessenger
+	client    *http.Client
+
+	mu        sync.Mutex
+	lastFired map[string]time.Time // 规则名+设备ID -> 上次触发时间
+}
+
+// NewEngine 创建规则引擎，配置不合法的规则会被跳过
+func NewEngine(ruleConfigs []configs.RuleConfig, taskMgr *task.TaskManager) *Engine {
+	e := &Engine{
+		taskMgr:   taskMgr,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		lastFired: make(map[string]time.Time),
+	}
+	for i := range ruleConfigs {
+		rule := &ruleConfigs[i]
+		if rule.Disabled {
+			continue
+		}
+		if err := validateRule(rule); err != nil {
+			logrus.WithField("rule", rule.Name).Warn(fmt.Sprintf("自动化规则配置无效，已跳过: %v", err))
+			continue
+		}
+		e.rules = append(e.rules, rule)
+	}
+	if len(e.rules) > 0 {
+		logrus.WithField("count", len(e.rules)).Info("自动化规则加载完成")
+	}
+	return e
+}
+
+// SetMessenger 设置设备消息下发器
+func (e *Engine) SetMessenger(messenger DeviceMessenger) {
+	e.messenger = messenger
+}
+
+// Fire 上报事件，匹配的规则在冷却期外时提交执行
+func (e *Engine) Fire(event Event) {
+	if e == nil || len(e.rules) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	for _, rule := range e.rules {
+		if !matches(rule, event) || !e.acquire(rule, event) {
+			continue
+		}
+		t, _ := task.NewTask(context.Background(), TaskTypeRuleAction, &ruleRun{engine: e, rule: rule, event: event})
+		if err := e.taskMgr.SubmitInternalTask(t); err != nil {
+			logrus.WithField("rule", rule.Name).Warn(fmt.Sprintf("提交规则动作失败: %v", err))
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"rule":      rule.Name,
+			"trigger":   event.Type,
+			"device_id": event.DeviceID,
+		}).Info("自动化规则已触发")
+	}
+}
+
+// acquire 检查并记录冷却时间
+func (e *Engine) acquire(rule *configs.RuleConfig, event Event) bool {
+	if rule.Cooldown <= 0 {
+		return true
+	}
+	key := rule.Name + "|" + event.DeviceID
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if last, ok := e.lastFired[key]; ok && event.Time.Sub(last) < time.Duration(rule.Cooldown)*time.Second {
+		return false
+	}
+	e.lastFired[key] = event.Time
+	return true
+}
+
+// matches 判断事件是否满足规则的触发条件
+func matches(rule *configs.RuleConfig, event Event) bool {
+	trigger := rule.Trigger
+	if TriggerType(trigger.Type) != event.Type {
+		return false
+	}
+	if len(trigger.Devices) > 0 && !containsFold(trigger.Devices, event.DeviceID) {
+		return false
+	}
+
+	switch event.Type {
+	case TriggerIntent:
+		return containsFold(trigger.Intents, event.Intent)
+	case TriggerKeyword:
+		text := strings.ToLower(event.Text)
+		for _, keyword := range trigger.Keywords {
+			if keyword != "" && strings.Contains(text, strings.ToLower(keyword)) {
+				return true
+			}
+		}
+		return false
+	case TriggerDeviceOffline:
+		return true
+	}
+	return false
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateRule(rule *configs.RuleConfig) error {
+	if rule.Name == "" {
+		return fmt.Errorf("缺少规则名称")
+	}
+	switch TriggerType(rule.Trigger.Type) {
+	case TriggerIntent:
+		if len(rule.Trigger.Intents) == 0 {
+			return fmt.Errorf("intent 触发器缺少 intents")
+		}
+	case TriggerKeyword:
+		if len(rule.Trigger.Keywords) == 0 {
+			return fmt.Errorf("keyword 触发器缺少 keywords")
+		}
+	case TriggerDeviceOffline:
+	default:
+		return fmt.Errorf("未知的触发类型: %s", rule.Trigger.Type)
+	}
+
+	if len(rule.Actions) == 0 {
+		return fmt.Errorf("缺少动作")
+	}
+	for _, action := range rule.Actions {
+		switch action.Type {
+		case ActionWebhook:
+			if !strings.HasPrefix(action.URL, "http://") && !strings.HasPrefix(action.URL, "https://") {
+				return fmt.Errorf("webhook 地址必须是http或https地址")
+			}
+		case ActionDeviceCommand:
+			if _, ok := action.Payload["type"].(string); !ok {
+				return fmt.Errorf("device_command 的 payload 缺少 type 字段")
+			}
+		case ActionNotification:
+			if action.Message == "" {
+				return fmt.Errorf("notification 缺少 message")
+			}
+		default:
+			return fmt.Errorf("未知的动作类型: %s", action.Type)
+		}
+		// 设备离线后无法再向其下发消息，必须指定其他目标设备
+		if TriggerType(rule.Trigger.Type) == TriggerDeviceOffline && action.Type != ActionWebhook && action.DeviceID == "" {
+			return fmt.Errorf("device_offline 规则的 %s 动作必须指定 device_id", action.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package skills
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+// CalendarRange 日程查询的时间范围
+const (
+	CalendarToday    = "today"
+	CalendarTomorrow = "tomorrow"
+	CalendarWeek     = "week"
+)
+
+// calendarQuery CalDAV calendar-query 请求，由服务端展开重复日程
+const calendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop>
+    <C:calendar-data>
+      <C:expand start="%[1]s" end="%[2]s"/>
+    </C:calendar-data>
+  </D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="%[1]s" end="%[2]s"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`
+
+// calendarEvent 解析出的单个日程
+type calendarEvent struct {
+	Summary  string
+	Location string
+	Start    time.Time
+	AllDay   bool
+}
+
+// Calendar 查询CalDAV日历中指定范围内的日程，返回交给LLM组织回复的文字描述
+func Calendar(ctx context.Context, cfg configs.CalendarSkillConfig, span string) (string, error) {
+	if cfg.URL == "" {
+		return "", fmt.Errorf("未配置日历地址")
+	}
+	loc := time.Local
+	if cfg.Timezone != "" {
+		l, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("日历时区 %s 无效: %v", cfg.Timezone, err)
+		}
+		loc = l
+	}
+
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	start, end, label := today, today.AddDate(0, 0, 1), "今天"
+	switch span {
+	case CalendarTomorrow:
+		start, end, label = today.AddDate(0, 0, 1), today.AddDate(0, 0, 2), "明天"
+	case CalendarWeek:
+		end, label = today.AddDate(0, 0, 7), "未来七天"
+	}
+
+	events, err := queryCalendar(ctx, cfg, start, end, loc)
+	if err != nil {
+		return "", err
+	}
+	if len(events) == 0 {
+		return label + "没有日程安排。", nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s共有%d个日程：", label, len(events))
+	for _, event := range events {
+		if span == CalendarWeek {
+			b.WriteString(event.Start.Format("1月2日") + " ")
+		}
+		if event.AllDay {
+			b.WriteString("全天 ")
+		} else {
+			b.WriteString(event.Start.Format("15:04") + " ")
+		}
+		b.WriteString(event.Summary)
+		if event.Location != "" {
+			b.WriteString("（地点：" + event.Location + "）")
+		}
+		b.WriteString("；")
+	}
+	return b.String(), nil
+}
+
+// queryCalendar 发送 REPORT 请求并解析返回的日程，按开始时间排序
+func queryCalendar(ctx context.Context, cfg configs.CalendarSkillConfig, start, end time.Time, loc *time.Location) ([]calendarEvent, error) {
+	const layout = "20060102T150405Z"
+	body := fmt.Sprintf(calendarQuery, start.UTC().Format(layout), end.UTC().Format(layout))
+	req, err := http.NewRequestWithContext(ctx, "REPORT", cfg.URL, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建日历请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	if cfg.Username != "" {
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("查询日历失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取日历响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusMultiStatus && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("查询日历失败，状态码 %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Responses []struct {
+			Propstats []struct {
+				CalendarData string `xml:"prop>calendar-data"`
+			} `xml:"propstat"`
+		} `xml:"response"`
+	}
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("解析日历响应失败: %v", err)
+	}
+	var events []calendarEvent
+	for _, response := range result.Responses {
+		for _, propstat := range response.Propstats {
+			events = append(events, parseICS(propstat.CalendarData, loc)...)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+	return events, nil
+}
+
+// parseICS 从iCalendar文本中解析VEVENT，只读取播报需要的字段
+func parseICS(data string, loc *time.Location) []calendarEvent {
+	// 续行以空格或制表符开头，拼接到上一行
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+
+	var events []calendarEvent
+	var current *calendarEvent
+	for _, line := range strings.Split(data, "\n") {
+		switch {
+		case line == "BEGIN:VEVENT":
+			current = &calendarEvent{}
+			continue
+		case line == "END:VEVENT":
+			if current != nil && !current.Start.IsZero() {
+				events = append(events, *current)
+			}
+			current = nil
+			continue
+		case current == nil:
+			continue
+		}
+
+		nameAndParams, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		params := strings.Split(nameAndParams, ";")
+		switch strings.ToUpper(params[0]) {
+		case "SUMMARY":
+			current.Summary = unescapeICS(value)
+		case "LOCATION":
+			current.Location = unescapeICS(value)
+		case "DTSTART":
+			current.Start, current.AllDay = parseICSTime(value, params[1:], loc)
+		}
+	}
+	return events
+}
+
+// parseICSTime 解析DTSTART，支持UTC时间、TZID指定时区、浮动时间与全天日期
+func parseICSTime(value string, params []string, loc *time.Location) (time.Time, bool) {
+	if len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t.In(loc), false
+	}
+	eventLoc := loc
+	for _, param := range params {
+		if tzid, ok := strings.CutPrefix(param, "TZID="); ok {
+			if l, err := time.LoadLocation(strings.Trim(tzid, `"`)); err == nil {
+				eventLoc = l
+			}
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, eventLoc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.In(loc), false
+}
+
+var icsUnescaper = strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescapeICS(value string) string {
+	return strings.TrimSpace(icsUnescaper.Replace(value))
+}
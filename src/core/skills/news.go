@@ -0,0 +1,110 @@
+package skills
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultNewsItems = 5
+
+// feed RSS 2.0 与 Atom 共用的解析结构，只读取标题
+type feed struct {
+	Channel struct {
+		Items []struct {
+			Title string `xml:"title"`
+		} `xml:"item"`
+	} `xml:"channel"`
+	Entries []struct {
+		Title string `xml:"title"`
+	} `xml:"entry"`
+}
+
+// NewsCategories 已配置的新闻分类，按名称排序
+func NewsCategories(cfg configs.NewsSkillConfig) []string {
+	names := make([]string, 0, len(cfg.Feeds))
+	for name := range cfg.Feeds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// News 读取订阅源生成新闻简报，返回交给LLM组织回复的文字描述
+// category 为空或不存在时依次读取全部订阅源，直到凑够条数
+func News(ctx context.Context, cfg configs.NewsSkillConfig, category string) (string, error) {
+	if len(cfg.Feeds) == 0 {
+		return "", fmt.Errorf("未配置新闻订阅源")
+	}
+	maxItems := cfg.MaxItems
+	if maxItems <= 0 {
+		maxItems = defaultNewsItems
+	}
+	categories := NewsCategories(cfg)
+	if _, ok := cfg.Feeds[category]; ok {
+		categories = []string{category}
+	}
+
+	var titles []string
+	var lastErr error
+	for _, name := range categories {
+		items, err := fetchFeed(ctx, cfg.Feeds[name])
+		if err != nil {
+			logrus.WithField("feed", name).WithError(err).Warn("读取新闻订阅源失败")
+			lastErr = err
+			continue
+		}
+		for _, title := range items {
+			if len(titles) >= maxItems {
+				break
+			}
+			titles = append(titles, title)
+		}
+		if len(titles) >= maxItems {
+			break
+		}
+	}
+	if len(titles) == 0 {
+		if lastErr != nil {
+			return "", fmt.Errorf("读取新闻失败: %v", lastErr)
+		}
+		return "暂时没有新闻。", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("最新新闻：")
+	for i, title := range titles {
+		fmt.Fprintf(&b, "%d. %s；", i+1, title)
+	}
+	return b.String(), nil
+}
+
+// fetchFeed 读取一个RSS或Atom订阅源的标题列表
+func fetchFeed(ctx context.Context, url string) ([]string, error) {
+	body, err := getBody(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	var f feed
+	if err := xml.Unmarshal(body, &f); err != nil {
+		return nil, fmt.Errorf("解析订阅源失败: %v", err)
+	}
+	var titles []string
+	for _, item := range f.Channel.Items {
+		if title := strings.TrimSpace(item.Title); title != "" {
+			titles = append(titles, title)
+		}
+	}
+	for _, entry := range f.Entries {
+		if title := strings.TrimSpace(entry.Title); title != "" {
+			titles = append(titles, title)
+		}
+	}
+	return titles, nil
+}
@@ -0,0 +1,124 @@
+// Package skills 内置技能：天气查询、CalDAV日历、RSS新闻播报
+// 由本地MCP工具调用，凭据按设备所属用户（租户）在 skills.tenants 中覆盖全局配置
+package skills
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+const requestTimeout = 10 * time.Second
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// Resolve 按调用设备所属的用户合并技能配置，租户中填写的项覆盖全局配置
+func Resolve(ctx context.Context, cfg *configs.SkillsConfig) configs.SkillsTenantConfig {
+	resolved := configs.SkillsTenantConfig{
+		Weather:  cfg.Weather,
+		Calendar: cfg.Calendar,
+		News:     cfg.News,
+	}
+	if len(cfg.Tenants) == 0 {
+		return resolved
+	}
+	tenant, ok := cfg.Tenants[tenantID(types.DeviceIDFromContext(ctx))]
+	if !ok {
+		return resolved
+	}
+
+	if tenant.Weather.APIKey != "" {
+		resolved.Weather.APIKey = tenant.Weather.APIKey
+		if tenant.Weather.Provider != "" {
+			resolved.Weather.Provider = tenant.Weather.Provider
+			resolved.Weather.APIURL = tenant.Weather.APIURL
+		}
+	}
+	if tenant.Weather.DefaultCity != "" {
+		resolved.Weather.DefaultCity = tenant.Weather.DefaultCity
+	}
+	if tenant.Calendar.URL != "" {
+		resolved.Calendar = tenant.Calendar
+		if resolved.Calendar.Timezone == "" {
+			resolved.Calendar.Timezone = cfg.Calendar.Timezone
+		}
+	}
+	if len(tenant.News.Feeds) > 0 {
+		resolved.News.Feeds = tenant.News.Feeds
+	}
+	if tenant.News.MaxItems > 0 {
+		resolved.News.MaxItems = tenant.News.MaxItems
+	}
+	return resolved
+}
+
+// tenantID 设备通过 PUT /api/devices/<设备ID>/user 绑定的用户ID，设备未绑定用户或查询失败时返回空字符串
+func tenantID(deviceID string) string {
+	if database.DB == nil || deviceID == "" {
+		return ""
+	}
+	userID, err := service.DeviceOwner(deviceID)
+	if err != nil {
+		if !errors.Is(err, service.ErrDeviceNotFound) {
+			logrus.WithError(err).Warn("查询设备所属用户失败")
+		}
+		return ""
+	}
+	if userID == 0 {
+		return ""
+	}
+	return strconv.FormatInt(userID, 10)
+}
+
+// getBody 发送GET请求并读取响应内容，非2xx状态码视为失败
+func getBody(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("请求失败，状态码 %d: %s", resp.StatusCode, truncate(string(body), 200))
+	}
+	return body, nil
+}
+
+// getJSON 发送GET请求并解析JSON响应
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	body, err := getBody(ctx, url)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "..."
+}
@@ -0,0 +1,147 @@
+package skills
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+const (
+	openWeatherMapURL = "https://api.openweathermap.org"
+	qweatherGeoURL    = "https://geoapi.qweather.com/v2/city/lookup"
+	qweatherURL       = "https://devapi.qweather.com"
+)
+
+// Weather 查询城市天气，返回交给LLM组织回复的文字描述
+// city 为空时使用默认城市
+func Weather(ctx context.Context, cfg configs.WeatherSkillConfig, city string) (string, error) {
+	city = strings.TrimSpace(city)
+	if city == "" {
+		city = cfg.DefaultCity
+	}
+	if city == "" {
+		return "", fmt.Errorf("没有指定城市，也没有配置默认城市")
+	}
+	if cfg.APIKey == "" {
+		return "", fmt.Errorf("未配置天气接口密钥")
+	}
+
+	switch cfg.Provider {
+	case "", "openweathermap":
+		return openWeatherMap(ctx, cfg, city)
+	case "qweather":
+		return qweather(ctx, cfg, city)
+	}
+	return "", fmt.Errorf("不支持的天气提供者: %s", cfg.Provider)
+}
+
+// openWeatherMap 查询 OpenWeatherMap 当前天气
+func openWeatherMap(ctx context.Context, cfg configs.WeatherSkillConfig, city string) (string, error) {
+	base := strings.TrimRight(cfg.APIURL, "/")
+	if base == "" {
+		base = openWeatherMapURL
+	}
+	query := url.Values{
+		"q":     {city},
+		"appid": {cfg.APIKey},
+		"units": {"metric"},
+		"lang":  {"zh_cn"},
+	}
+	var result struct {
+		Name    string `json:"name"`
+		Weather []struct {
+			Description string `json:"description"`
+		} `json:"weather"`
+		Main struct {
+			Temp      float64 `json:"temp"`
+			FeelsLike float64 `json:"feels_like"`
+			TempMin   float64 `json:"temp_min"`
+			TempMax   float64 `json:"temp_max"`
+			Humidity  int     `json:"humidity"`
+		} `json:"main"`
+		Wind struct {
+			Speed float64 `json:"speed"`
+		} `json:"wind"`
+	}
+	if err := getJSON(ctx, base+"/data/2.5/weather?"+query.Encode(), &result); err != nil {
+		return "", fmt.Errorf("查询%s天气失败: %v", city, err)
+	}
+
+	description := ""
+	if len(result.Weather) > 0 {
+		description = result.Weather[0].Description + "，"
+	}
+	return fmt.Sprintf("%s当前天气：%s气温%.0f℃，体感%.0f℃，最低%.0f℃，最高%.0f℃，湿度%d%%，风速%.1f米每秒。",
+		city, description, result.Main.Temp, result.Main.FeelsLike, result.Main.TempMin, result.Main.TempMax,
+		result.Main.Humidity, result.Wind.Speed), nil
+}
+
+// qweather 查询和风天气的实况与三天预报，先通过城市查询接口获取地区ID
+// 配置了 api_url（和风天气分配的API Host）时城市查询与天气接口都使用该地址
+func qweather(ctx context.Context, cfg configs.WeatherSkillConfig, city string) (string, error) {
+	geoURL, base := qweatherGeoURL, qweatherURL
+	if cfg.APIURL != "" {
+		base = strings.TrimRight(cfg.APIURL, "/")
+		geoURL = base + "/geo/v2/city/lookup"
+	}
+
+	var geo struct {
+		Code     string `json:"code"`
+		Location []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"location"`
+	}
+	query := url.Values{"location": {city}, "key": {cfg.APIKey}}
+	if err := getJSON(ctx, geoURL+"?"+query.Encode(), &geo); err != nil {
+		return "", fmt.Errorf("查询城市%s失败: %v", city, err)
+	}
+	if geo.Code != "200" || len(geo.Location) == 0 {
+		return "", fmt.Errorf("没有找到城市%s（返回码 %s）", city, geo.Code)
+	}
+	query = url.Values{"location": {geo.Location[0].ID}, "key": {cfg.APIKey}}
+
+	var now struct {
+		Code string `json:"code"`
+		Now  struct {
+			Temp      string `json:"temp"`
+			FeelsLike string `json:"feelsLike"`
+			Text      string `json:"text"`
+			WindDir   string `json:"windDir"`
+			WindScale string `json:"windScale"`
+			Humidity  string `json:"humidity"`
+		} `json:"now"`
+	}
+	if err := getJSON(ctx, base+"/v7/weather/now?"+query.Encode(), &now); err != nil {
+		return "", fmt.Errorf("查询%s天气失败: %v", city, err)
+	}
+	if now.Code != "200" {
+		return "", fmt.Errorf("查询%s天气失败，返回码 %s", city, now.Code)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s当前天气：%s，气温%s℃，体感%s℃，湿度%s%%，%s%s级。",
+		geo.Location[0].Name, now.Now.Text, now.Now.Temp, now.Now.FeelsLike, now.Now.Humidity, now.Now.WindDir, now.Now.WindScale)
+
+	var daily struct {
+		Code  string `json:"code"`
+		Daily []struct {
+			FxDate    string `json:"fxDate"`
+			TempMax   string `json:"tempMax"`
+			TempMin   string `json:"tempMin"`
+			TextDay   string `json:"textDay"`
+			TextNight string `json:"textNight"`
+		} `json:"daily"`
+	}
+	// 预报只是补充信息，失败时仍返回实况天气
+	if err := getJSON(ctx, base+"/v7/weather/3d?"+query.Encode(), &daily); err == nil && daily.Code == "200" {
+		b.WriteString("未来三天预报：")
+		for _, day := range daily.Daily {
+			fmt.Fprintf(&b, "%s白天%s夜间%s，%s~%s℃；", day.FxDate, day.TextDay, day.TextNight, day.TempMin, day.TempMax)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,122 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/speaker"
+)
+
+const requestTimeout = 10 * time.Second
+
+// Extractor 通过HTTP声纹服务提取声纹向量，如部署了3D-Speaker模型的Python服务
+// 请求：POST <url>，multipart 字段 file 为16kHz单声道16位WAV音频；响应：{"embedding":[...]}
+type Extractor struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewExtractor 创建HTTP声纹提取器
+func NewExtractor(config *configs.VoiceprintConfig) (*Extractor, error) {
+	url, _ := config.Extra["url"].(string)
+	if url == "" {
+		return nil, fmt.Errorf("缺少声纹服务地址 url")
+	}
+	apiKey, _ := config.Extra["api_key"].(string)
+	return &Extractor{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// Embed 实现 speaker.Extractor 接口
+func (e *Extractor) Embed(ctx context.Context, samples []float32) ([]float32, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(encodeWAV(samples)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求声纹服务失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取声纹服务响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("声纹服务返回错误，状态码 %d: %s", resp.StatusCode, string(data))
+	}
+	var result struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("解析声纹服务响应失败: %v", err)
+	}
+	if len(result.Embedding) == 0 {
+		return nil, fmt.Errorf("声纹服务没有返回声纹向量")
+	}
+	return result.Embedding, nil
+}
+
+// Close 实现 speaker.Extractor 接口
+func (e *Extractor) Close() error {
+	return nil
+}
+
+// encodeWAV 把采样编码为16kHz单声道16位WAV
+func encodeWAV(samples []float32) []byte {
+	dataSize := len(samples) * 2
+	buf := bytes.NewBuffer(make([]byte, 0, 44+dataSize))
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(buf, binary.LittleEndian, uint32(16))
+	binary.Write(buf, binary.LittleEndian, uint16(1)) // PCM
+	binary.Write(buf, binary.LittleEndian, uint16(1)) // 单声道
+	binary.Write(buf, binary.LittleEndian, uint32(speaker.SampleRate))
+	binary.Write(buf, binary.LittleEndian, uint32(speaker.SampleRate*2))
+	binary.Write(buf, binary.LittleEndian, uint16(2))
+	binary.Write(buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, uint32(dataSize))
+	for _, sample := range samples {
+		value := math.Max(-1, math.Min(1, float64(sample)))
+		binary.Write(buf, binary.LittleEndian, int16(value*32767))
+	}
+	return buf.Bytes()
+}
+
+func init() {
+	speaker.Register("rest", func(config *configs.VoiceprintConfig) (speaker.Extractor, error) {
+		return NewExtractor(config)
+	})
+}
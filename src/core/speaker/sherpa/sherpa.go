@@ -0,0 +1,83 @@
+//go:build sherpa
+
+// sherpa-onnx 声纹向量提取在进程内运行，使用3D-Speaker等说话人识别模型，需要CGO和sherpa-onnx动态库：
+//
+//	go get github.com/k2-fsa/sherpa-onnx-go
+//	go build -tags sherpa ./src/main.go
+//
+// 未使用 sherpa 编译标签时见 sherpa_disabled.go
+package sherpa
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/speaker"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+const defaultNumThreads = 1
+
+// Extractor sherpa-onnx 声纹向量提取，模型如 3dspeaker_speech_eres2net_base_sv_zh-cn_3dspeaker_16k.onnx
+type Extractor struct {
+	mu        sync.Mutex
+	extractor *sherpa.SpeakerEmbeddingExtractor
+}
+
+// NewExtractor 加载声纹模型
+func NewExtractor(config *configs.VoiceprintConfig) (*Extractor, error) {
+	model, _ := config.Extra["model"].(string)
+	if model == "" {
+		return nil, fmt.Errorf("缺少声纹模型路径 model")
+	}
+	numThreads := defaultNumThreads
+	if n, ok := config.Extra["num_threads"].(int); ok && n > 0 {
+		numThreads = n
+	}
+	extractor := sherpa.NewSpeakerEmbeddingExtractor(&sherpa.SpeakerEmbeddingExtractorConfig{
+		Model:      model,
+		NumThreads: numThreads,
+		Provider:   "cpu",
+	})
+	if extractor == nil {
+		return nil, fmt.Errorf("加载声纹模型失败: %s", model)
+	}
+	return &Extractor{extractor: extractor}, nil
+}
+
+// Embed 实现 speaker.Extractor 接口，模型不支持并发推理，多个连接依次提取
+func (e *Extractor) Embed(ctx context.Context, samples []float32) ([]float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.extractor == nil {
+		return nil, fmt.Errorf("声纹提取器已关闭")
+	}
+	stream := e.extractor.CreateStream()
+	defer sherpa.DeleteOnlineStream(stream)
+	stream.AcceptWaveform(speaker.SampleRate, samples)
+	stream.InputFinished()
+	if !e.extractor.IsReady(stream) {
+		return nil, fmt.Errorf("音频太短，无法提取声纹")
+	}
+	return e.extractor.Compute(stream), nil
+}
+
+// Close 实现 speaker.Extractor 接口
+func (e *Extractor) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.extractor != nil {
+		sherpa.DeleteSpeakerEmbeddingExtractor(e.extractor)
+		e.extractor = nil
+	}
+	return nil
+}
+
+func init() {
+	speaker.Register("3d-speaker", func(config *configs.VoiceprintConfig) (speaker.Extractor, error) {
+		return NewExtractor(config)
+	})
+}
@@ -0,0 +1,17 @@
+//go:build !sherpa
+
+package sherpa
+
+import (
+	"fmt"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/speaker"
+)
+
+// 未使用 sherpa 编译标签构建时没有sherpa-onnx，创建时返回错误
+func init() {
+	speaker.Register("3d-speaker", func(config *configs.VoiceprintConfig) (speaker.Extractor, error) {
+		return nil, fmt.Errorf("当前程序未包含sherpa-onnx声纹识别，请使用 -tags sherpa 重新编译，或使用 rest 声纹服务")
+	})
+}
@@ -0,0 +1,230 @@
+// Package speaker 声纹识别：登记用户的声纹向量，对话时按相似度识别正在说话的用户
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+)
+
+// SampleRate 声纹模型使用的采样率，音频需要先转换为该采样率的单声道采样
+const SampleRate = 16000
+
+const (
+	defaultThreshold  = 0.6
+	defaultMinSeconds = 1.0
+	defaultMaxSeconds = 10.0
+	cacheTTL          = time.Minute // 声纹缓存的有效期，多实例部署时其他实例登记的声纹在过期后生效
+)
+
+// Extractor 声纹向量提取接口
+type Extractor interface {
+	// Embed 提取一段16kHz单声道音频（采样值-1~1）的声纹向量
+	Embed(ctx context.Context, samples []float32) ([]float32, error)
+	Close() error
+}
+
+// ExtractorFactory 声纹提取器工厂函数类型
+type ExtractorFactory func(config *configs.VoiceprintConfig) (Extractor, error)
+
+var (
+	factories = make(map[string]ExtractorFactory)
+)
+
+// Register 注册声纹提取器工厂
+func Register(name string, factory ExtractorFactory) {
+	factories[name] = factory
+}
+
+// Create 创建声纹提取器实例
+func Create(name string, config *configs.VoiceprintConfig) (Extractor, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的声纹提取器: %s", name)
+	}
+
+	extractor, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建声纹提取器失败: %v", err)
+	}
+
+	return extractor, nil
+}
+
+// Match 识别结果
+type Match struct {
+	UserID int64
+	Score  float64 // 余弦相似度
+}
+
+// enrolled 缓存的一段声纹
+type enrolled struct {
+	userID int64
+	vector []float32
+}
+
+// Service 声纹识别服务，所有连接共享
+type Service struct {
+	extractor  Extractor
+	db         *gorm.DB
+	threshold  float64
+	minSamples int
+	maxSamples int
+
+	mu       sync.Mutex
+	cache    []enrolled
+	loadedAt time.Time
+}
+
+// New 按配置创建声纹识别服务，未启用时返回nil
+func New(cfg configs.VoiceprintConfig, db *gorm.DB) (*Service, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if db == nil {
+		return nil, fmt.Errorf("声纹识别需要连接数据库")
+	}
+	extractor, err := Create(cfg.Type, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		extractor:  extractor,
+		db:         db,
+		threshold:  cfg.Threshold,
+		minSamples: int(cfg.MinSeconds * SampleRate),
+		maxSamples: int(cfg.MaxSeconds * SampleRate),
+	}
+	if s.threshold <= 0 {
+		s.threshold = defaultThreshold
+	}
+	if s.minSamples <= 0 {
+		s.minSamples = int(defaultMinSeconds * SampleRate)
+	}
+	if s.maxSamples <= 0 {
+		s.maxSamples = int(defaultMaxSeconds * SampleRate)
+	}
+	return s, nil
+}
+
+// MaxSamples 识别时使用的最长音频采样数，连接只需要保留最近这么多采样
+func (s *Service) MaxSamples() int {
+	return s.maxSamples
+}
+
+// Enroll 登记用户的一段声纹
+func (s *Service) Enroll(ctx context.Context, userID int64, label string, samples []float32) (*models.Voiceprint, error) {
+	if len(samples) < s.minSamples {
+		return nil, fmt.Errorf("音频太短，至少需要%.1f秒", float64(s.minSamples)/SampleRate)
+	}
+	vector, err := s.extractor.Embed(ctx, samples)
+	if err != nil {
+		return nil, fmt.Errorf("提取声纹失败: %v", err)
+	}
+	data, err := json.Marshal(vector)
+	if err != nil {
+		return nil, err
+	}
+	voiceprint := &models.Voiceprint{UserID: userID, Label: label, Embedding: data}
+	if err := s.db.WithContext(ctx).Create(voiceprint).Error; err != nil {
+		return nil, fmt.Errorf("保存声纹失败: %v", err)
+	}
+	s.invalidate()
+	return voiceprint, nil
+}
+
+// Delete 删除一段声纹，返回是否存在
+func (s *Service) Delete(id int64) (bool, error) {
+	result := s.db.Delete(&models.Voiceprint{}, id)
+	if result.Error != nil {
+		return false, fmt.Errorf("删除声纹失败: %v", result.Error)
+	}
+	s.invalidate()
+	return result.RowsAffected > 0, nil
+}
+
+// Identify 识别音频中说话的用户，音频太短或相似度不足时返回false
+func (s *Service) Identify(ctx context.Context, samples []float32) (Match, bool, error) {
+	if len(samples) < s.minSamples {
+		return Match{}, false, nil
+	}
+	if len(samples) > s.maxSamples {
+		samples = samples[len(samples)-s.maxSamples:]
+	}
+	candidates, err := s.enrolled()
+	if err != nil || len(candidates) == 0 {
+		return Match{}, false, err
+	}
+	vector, err := s.extractor.Embed(ctx, samples)
+	if err != nil {
+		return Match{}, false, fmt.Errorf("提取声纹失败: %v", err)
+	}
+
+	var best Match
+	for _, candidate := range candidates {
+		if score := cosine(vector, candidate.vector); score > best.Score {
+			best = Match{UserID: candidate.userID, Score: score}
+		}
+	}
+	return best, best.Score >= s.threshold, nil
+}
+
+// enrolled 已登记的声纹，缓存过期或有变更时重新从数据库加载
+func (s *Service) enrolled() ([]enrolled, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache != nil && time.Since(s.loadedAt) < cacheTTL {
+		return s.cache, nil
+	}
+	var voiceprints []models.Voiceprint
+	if err := s.db.Find(&voiceprints).Error; err != nil {
+		return nil, fmt.Errorf("加载声纹失败: %v", err)
+	}
+	cache := make([]enrolled, 0, len(voiceprints))
+	for _, voiceprint := range voiceprints {
+		var vector []float32
+		if err := json.Unmarshal(voiceprint.Embedding, &vector); err != nil || len(vector) == 0 {
+			continue
+		}
+		cache = append(cache, enrolled{userID: voiceprint.UserID, vector: vector})
+	}
+	s.cache, s.loadedAt = cache, time.Now()
+	return cache, nil
+}
+
+// invalidate 登记或删除声纹后使缓存失效
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache = nil
+}
+
+// Close 释放声纹提取器
+func (s *Service) Close() error {
+	return s.extractor.Close()
+}
+
+// cosine 余弦相似度，维度不一致时返回0（更换模型后旧声纹不再匹配）
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
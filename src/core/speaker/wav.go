@@ -0,0 +1,51 @@
+package speaker
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"xiaozhi-server-go/src/core/utils"
+)
+
+// DecodeWAV 解析16位PCM编码的WAV音频，转换为16kHz单声道采样
+func DecodeWAV(data []byte) ([]float32, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("不是WAV音频")
+	}
+	var sampleRate, channels, bitsPerSample int
+	for offset := 12; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := data[offset+8:]
+		if size > len(body) {
+			size = len(body)
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("WAV格式块不完整")
+			}
+			if format := binary.LittleEndian.Uint16(body[0:2]); format != 1 {
+				return nil, fmt.Errorf("只支持PCM编码的WAV音频")
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+		case "data":
+			if sampleRate == 0 {
+				return nil, fmt.Errorf("WAV音频缺少格式块")
+			}
+			if bitsPerSample != 16 {
+				return nil, fmt.Errorf("只支持16位采样的WAV音频")
+			}
+			resampler, err := utils.NewPCMResampler(sampleRate, channels, SampleRate)
+			if err != nil {
+				return nil, err
+			}
+			return resampler.Float32(body[:size]), nil
+		}
+		// 块大小为奇数时有一个填充字节
+		offset += 8 + size + size%2
+	}
+	return nil, fmt.Errorf("WAV音频缺少数据块")
+}
@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/types"
+)
+
+// deviceInfoNames 设备上报信息的中文名称，按播报顺序排列
+var deviceInfoNames = []struct {
+	key, name, unit string
+}{
+	{"device_name", "设备名称", ""},
+	{"battery", "电量", "%"},
+	{"location", "位置", ""},
+}
+
+// getDeviceStatus 查询当前设备的连接和运行状态
+func getDeviceStatus(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error) {
+	device, ok := DeviceFromContext(ctx)
+	if !ok {
+		return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: "无法获取设备状态"}, nil
+	}
+
+	parts := []string{"设备ID " + device.ID}
+	if !device.ConnectedAt.IsZero() {
+		parts = append(parts, "本次已连接"+formatDuration(time.Since(device.ConnectedAt)))
+	}
+	if device.ListenMode != "" {
+		parts = append(parts, "拾音模式 "+device.ListenMode)
+	}
+	for _, field := range deviceInfoNames {
+		if value := device.Info[field.key]; value != "" {
+			parts = append(parts, field.name+" "+value+field.unit)
+		}
+	}
+	if device.Info["battery"] == "" {
+		parts = append(parts, "设备没有上报电量")
+	}
+	return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: "设备状态：" + strings.Join(parts, "，") + "。"}, nil
+}
+
+// formatDuration 把时长转换为口语化的描述
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "不到1分钟"
+	case d < time.Hour:
+		return fmt.Sprintf("%d分钟", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%d小时%d分钟", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+func init() {
+	Register(Tool{
+		Name:        "get_device_status",
+		Description: "用户询问设备自身的状态时调用，如电量、连接时长、设备名称",
+		Handler:     getDeviceStatus,
+	})
+}
@@ -0,0 +1,28 @@
+package tools
+
+import (
+	"context"
+
+	"xiaozhi-server-go/src/core/types"
+)
+
+func init() {
+	Register(Tool{
+		Name:        "draw_image",
+		Description: "用户让你画图、生成图片时调用，如“帮我画一只在月亮上的猫”。图片在后台生成，画好后推送到设备",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"prompt": map[string]interface{}{
+					"type":        "string",
+					"description": "画面描述，把用户的要求扩写成具体的主体、场景和风格",
+				},
+			},
+			"required": []string{"prompt"},
+		},
+		Handler: func(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error) {
+			prompt, _ := arguments["prompt"].(string)
+			return callHandler("mcp_handler_draw_image", prompt), nil
+		},
+	})
+}
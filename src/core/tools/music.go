@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"context"
+
+	"xiaozhi-server-go/src/core/types"
+)
+
+// callHandler 交给对话处理器执行的动作，由处理器在当前连接上完成播放等操作
+func callHandler(name string, args interface{}) types.ActionResponse {
+	return types.ActionResponse{
+		Action: types.ActionTypeCallHandler,
+		Result: types.ActionResponseCall{FuncName: name, Args: args},
+	}
+}
+
+func init() {
+	Register(Tool{
+		Name:        "play_song",
+		Description: "用户想听歌、播放某首歌或某位歌手的歌时调用，在配置的音乐源中搜索并推流播放",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "歌名和/或歌手，如“晴天”“周杰伦 晴天”；用户没有指定时传 random",
+				},
+			},
+			"required": []string{"query"},
+		},
+		Handler: func(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error) {
+			query, _ := arguments["query"].(string)
+			return callHandler("mcp_handler_play_song", query), nil
+		},
+	})
+
+	Register(Tool{
+		Name:        "music_control",
+		Description: "控制正在播放的音乐：暂停、继续播放、下一首、停止",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"action": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"pause", "resume", "skip", "stop"},
+					"description": "pause 暂停，resume 继续播放，skip 下一首，stop 停止",
+				},
+			},
+			"required": []string{"action"},
+		},
+		Handler: func(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error) {
+			action, _ := arguments["action"].(string)
+			return callHandler("mcp_handler_music_control", action), nil
+		},
+	})
+}
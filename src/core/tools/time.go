@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/types"
+)
+
+var weekdayNames = [...]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// getTime 查询当前日期和时间，可指定时区
+func getTime(ctx context.Context, arguments map[string]interface{}) (types.ActionResponse, error) {
+	now := time.Now()
+	if name, _ := arguments["timezone"].(string); name != "" {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: fmt.Sprintf("不支持的时区: %s", name)}, nil
+		}
+		now = now.In(location)
+	}
+	text := fmt.Sprintf("当前时间是%s，今天是%s。", now.Format("2006年1月2日 15点04分"), weekdayNames[now.Weekday()])
+	return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: text}, nil
+}
+
+func init() {
+	Register(Tool{
+		Name:        "get_time",
+		Description: "获取当前日期、时间或星期时调用，询问其他城市的时间时传入对应的时区",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"timezone": map[string]interface{}{
+					"type":        "string",
+					"description": "IANA时区名称，如 Asia/Shanghai、America/New_York，查询本地时间时不传",
+				},
+			},
+		},
+		Handler: getTime,
+	})
+}
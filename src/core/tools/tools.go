@@ -0,0 +1,105 @@
+// Package tools 函数调用插件：插件在 init 中注册名称、参数的JSON Schema和执行逻辑，
+// 在配置 function_plugins 中启用后自动提供给支持函数调用的LLM，LLM调用时由对话处理器分发执行
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/function"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Tool 函数插件
+type Tool struct {
+	Name        string
+	Description string                 // 告诉LLM什么时候调用
+	Parameters  map[string]interface{} // 参数的JSON Schema，为nil时表示没有参数
+	Handler     function.Handler
+}
+
+var (
+	plugins = make(map[string]Tool)
+)
+
+// Register 注册函数插件，同名插件后注册的覆盖先注册的
+func Register(tool Tool) {
+	plugins[tool.Name] = tool
+}
+
+// Get 获取已注册的函数插件
+func Get(name string) (Tool, bool) {
+	tool, ok := plugins[name]
+	return tool, ok
+}
+
+// Names 已注册的函数插件名称，按名称排序
+func Names() []string {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Definition 转换为LLM函数调用的工具定义
+func (t Tool) Definition() openai.Tool {
+	parameters := t.Parameters
+	if parameters == nil {
+		parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  parameters,
+		},
+	}
+}
+
+// Bind 把启用的插件注册到连接的函数注册表，未知或重名的插件跳过，其余插件照常注册
+func Bind(registry *function.FunctionRegistry, enabled []string) error {
+	var failed []string
+	for _, name := range enabled {
+		tool, ok := plugins[name]
+		if !ok {
+			failed = append(failed, fmt.Sprintf("未知的函数插件: %s", name))
+			continue
+		}
+		if err := registry.RegisterFunctionWithHandler(name, tool.Definition(), tool.Handler); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("注册函数插件失败: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Device 发起调用的设备及其运行时信息，由对话处理器在调用插件前放入上下文
+type Device struct {
+	ID          string
+	ClientID    string
+	ConnectedAt time.Time
+	ListenMode  string
+	Info        map[string]string // 设备通过 context 消息上报的信息，如电量、位置
+}
+
+type deviceKey struct{}
+
+// WithDevice 在插件调用上下文中附加设备信息
+func WithDevice(ctx context.Context, device Device) context.Context {
+	return context.WithValue(ctx, deviceKey{}, device)
+}
+
+// DeviceFromContext 读取上下文中的设备信息
+func DeviceFromContext(ctx context.Context) (Device, bool) {
+	device, ok := ctx.Value(deviceKey{}).(Device)
+	return device, ok
+}
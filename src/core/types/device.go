@@ -0,0 +1,16 @@
+package types
+
+import "context"
+
+type deviceIDKey struct{}
+
+// WithDeviceID 在工具调用上下文中附加发起调用的设备ID，本地工具据此读取设备或用户的设置
+func WithDeviceID(ctx context.Context, deviceID string) context.Context {
+	return context.WithValue(ctx, deviceIDKey{}, deviceID)
+}
+
+// DeviceIDFromContext 读取上下文中的设备ID，没有时返回空字符串
+func DeviceIDFromContext(ctx context.Context) string {
+	deviceID, _ := ctx.Value(deviceIDKey{}).(string)
+	return deviceID
+}
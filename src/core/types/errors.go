@@ -0,0 +1,203 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrorKind 提供者错误分类
+type ErrorKind string
+
+const (
+	ErrorKindAuth          ErrorKind = "auth"           // 鉴权失败，API Key无效或无权限
+	ErrorKindQuota         ErrorKind = "quota"          // 限流或额度耗尽
+	ErrorKindTimeout       ErrorKind = "timeout"        // 请求超时
+	ErrorKindContentFilter ErrorKind = "content_filter" // 内容被安全策略拦截
+	ErrorKindNetwork       ErrorKind = "network"        // 网络连接失败
+	ErrorKindServer        ErrorKind = "server"         // 服务端内部错误
+	ErrorKindUnknown       ErrorKind = "unknown"
+)
+
+// Retryable 该类错误是否值得重试
+func (k ErrorKind) Retryable() bool {
+	switch k {
+	case ErrorKindTimeout, ErrorKindNetwork, ErrorKindServer:
+		return true
+	default:
+		return false
+	}
+}
+
+// SpokenMessage 该类错误对用户播报的致歉语
+func (k ErrorKind) SpokenMessage() string {
+	switch k {
+	case ErrorKindAuth:
+		return "抱歉，服务鉴权失败，请联系管理员检查配置"
+	case ErrorKindQuota:
+		return "抱歉，服务调用太频繁或额度已用完，请稍后再试"
+	case ErrorKindTimeout:
+		return "抱歉，服务响应超时了，请稍后再试"
+	case ErrorKindContentFilter:
+		return "抱歉，这个问题我不方便回答，我们换个话题吧"
+	case ErrorKindNetwork:
+		return "抱歉，网络好像出了点问题，请稍后再试"
+	default:
+		return "抱歉，服务暂时不可用，请稍后再试"
+	}
+}
+
+// ProviderError 提供者返回的结构化错误
+type ProviderError struct {
+	Kind       ErrorKind
+	Provider   string // 提供者名称，如 openai、ollama
+	StatusCode int    // HTTP状态码，无则为0
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("%s服务错误[%s, %d]: %v", e.Provider, e.Kind, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s服务错误[%s]: %v", e.Provider, e.Kind, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// NewProviderError 根据底层错误自动分类，包装为结构化错误
+func NewProviderError(provider string, err error) *ProviderError {
+	if err == nil {
+		return nil
+	}
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		return pe
+	}
+
+	statusCode := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		statusCode = apiErr.HTTPStatusCode
+		if code, ok := apiErr.Code.(string); ok && isContentFilterText(code) {
+			return &ProviderError{Kind: ErrorKindContentFilter, Provider: provider, StatusCode: statusCode, Err: err}
+		}
+	case errors.As(err, &reqErr):
+		statusCode = reqErr.HTTPStatusCode
+	}
+
+	return &ProviderError{
+		Kind:       classifyError(err, statusCode),
+		Provider:   provider,
+		StatusCode: statusCode,
+		Err:        err,
+	}
+}
+
+// NewHTTPError 根据HTTP状态码和响应内容构造结构化错误
+func NewHTTPError(provider string, statusCode int, body string) *ProviderError {
+	err := fmt.Errorf("HTTP %d: %s", statusCode, body)
+	return &ProviderError{
+		Kind:       classifyError(err, statusCode),
+		Provider:   provider,
+		StatusCode: statusCode,
+		Err:        err,
+	}
+}
+
+// NewDialError WebSocket握手失败时按响应的HTTP状态码分类，没有响应时按网络错误分类
+func NewDialError(provider string, resp *http.Response, err error) *ProviderError {
+	wrapped := fmt.Errorf("连接WebSocket服务器失败: %w", err)
+	if resp != nil && resp.StatusCode >= http.StatusBadRequest {
+		return &ProviderError{
+			Kind:       classifyError(wrapped, resp.StatusCode),
+			Provider:   provider,
+			StatusCode: resp.StatusCode,
+			Err:        wrapped,
+		}
+	}
+	return NewProviderError(provider, wrapped)
+}
+
+// AsProviderError 从错误链中提取结构化错误
+func AsProviderError(err error) (*ProviderError, bool) {
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		return pe, true
+	}
+	return nil, false
+}
+
+// ErrorKindOf 获取错误分类，非结构化错误按内容推断
+func ErrorKindOf(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	if pe, ok := AsProviderError(err); ok {
+		return pe.Kind
+	}
+	return classifyError(err, 0)
+}
+
+// classifyError 按状态码、错误类型和错误内容推断分类
+func classifyError(err error, statusCode int) ErrorKind {
+	msg := strings.ToLower(err.Error())
+	if isContentFilterText(msg) {
+		return ErrorKindContentFilter
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrorKindAuth
+	case statusCode == http.StatusTooManyRequests || statusCode == http.StatusPaymentRequired:
+		return ErrorKindQuota
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		return ErrorKindTimeout
+	case statusCode >= 500:
+		return ErrorKindServer
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorKindTimeout
+		}
+		return ErrorKindNetwork
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorKindNetwork
+	}
+
+	switch {
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "超时"):
+		return ErrorKindTimeout
+	case strings.Contains(msg, "quota") || strings.Contains(msg, "rate limit") || strings.Contains(msg, "余额"):
+		return ErrorKindQuota
+	case strings.Contains(msg, "unauthorized") || strings.Contains(msg, "api key") || strings.Contains(msg, "鉴权"):
+		return ErrorKindAuth
+	case strings.Contains(msg, "connection refused") || strings.Contains(msg, "no such host") || strings.Contains(msg, "connection reset"):
+		return ErrorKindNetwork
+	}
+	return ErrorKindUnknown
+}
+
+// isContentFilterText 判断错误内容是否为内容安全拦截
+func isContentFilterText(text string) bool {
+	text = strings.ToLower(text)
+	return strings.Contains(text, "content_filter") ||
+		strings.Contains(text, "content_policy") ||
+		strings.Contains(text, "data_inspection_failed") ||
+		strings.Contains(text, "sensitive")
+}
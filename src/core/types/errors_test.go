@@ -0,0 +1,47 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestNewDialError(t *testing.T) {
+	handshake := errors.New("websocket: bad handshake")
+	tests := []struct {
+		name     string
+		resp     *http.Response
+		err      error
+		expected ErrorKind
+	}{
+		{name: "鉴权失败", resp: &http.Response{StatusCode: http.StatusUnauthorized}, err: handshake, expected: ErrorKindAuth},
+		{name: "限流", resp: &http.Response{StatusCode: http.StatusTooManyRequests}, err: handshake, expected: ErrorKindQuota},
+		{name: "服务端错误", resp: &http.Response{StatusCode: http.StatusBadGateway}, err: handshake, expected: ErrorKindServer},
+		{name: "没有响应", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, expected: ErrorKindNetwork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDialError("doubao", tt.resp, tt.err)
+			if err.Kind != tt.expected {
+				t.Errorf("Kind = %s, want %s", err.Kind, tt.expected)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Error("错误链中缺少原始错误")
+			}
+		})
+	}
+}
+
+func TestErrorKindOfWrapped(t *testing.T) {
+	inner := &ProviderError{Kind: ErrorKindQuota, Provider: "doubao", Err: errors.New("并发超限")}
+	wrapped := fmt.Errorf("TTS合成失败: %w", inner)
+	if kind := ErrorKindOf(wrapped); kind != ErrorKindQuota {
+		t.Errorf("ErrorKindOf() = %s, want %s", kind, ErrorKindQuota)
+	}
+	if got := NewProviderError("edge", wrapped); got != inner {
+		t.Error("已分类的错误不应重新分类")
+	}
+}
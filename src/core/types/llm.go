@@ -81,6 +81,8 @@ func (m *Message) Print() {
 }
 
 // ToolCall 工具调用结构
+// 流式响应中可以分多个增量返回：带ID的增量开始一个新的调用，后续不带ID的增量追加名称和参数，
+// 同一次回复中出现多个不同ID表示并行调用
 type ToolCall struct {
 	ID       string       `json:"id"`
 	Type     string       `json:"type"`
@@ -100,6 +102,7 @@ type Response struct {
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	StopReason string     `json:"stop_reason,omitempty"`
 	Error      string     `json:"error,omitempty"`
+	Err        error      `json:"-"` // 结构化错误，通常为 *ProviderError，非空时 Error 为其文本
 }
 
 // Provider 基础提供者接口
@@ -118,8 +121,11 @@ type FunctionRegistryInterface interface {
 }
 
 // LLMProvider 大语言模型提供者接口
+// 请求失败时返回 *ProviderError，流式过程中的错误通过 Response.Err 传递，不再以文本形式写入回复
 type LLMProvider interface {
 	Provider
 	Response(ctx context.Context, sessionID string, messages []Message) (<-chan string, error)
+	// ResponseWithFunctions 带工具定义（OpenAI格式）请求回复，模型决定调用工具时通过 Response.ToolCalls 返回；
+	// 调用结果以 assistant(tool_calls) + tool(tool_call_id) 消息写回 messages 后再次请求
 	ResponseWithFunctions(ctx context.Context, sessionID string, messages []Message, tools []openai.Tool) (<-chan Response, error)
 }
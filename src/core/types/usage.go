@@ -0,0 +1,31 @@
+package types
+
+import "context"
+
+// Usage 单次请求的token用量
+type Usage struct {
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// UsageReporter 接收提供者上报的token用量
+type UsageReporter func(usage Usage)
+
+type usageReporterKey struct{}
+
+// WithUsageReporter 在请求上下文中附加用量上报回调
+// 提供者的返回通道只传递文本，用量统一通过上下文上报，调用方按设备汇总
+func WithUsageReporter(ctx context.Context, reporter UsageReporter) context.Context {
+	return context.WithValue(ctx, usageReporterKey{}, reporter)
+}
+
+// ReportUsage 上报一次请求的token用量，上下文中没有回调或用量为空时忽略
+func ReportUsage(ctx context.Context, usage Usage) {
+	if usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+		return
+	}
+	if reporter, ok := ctx.Value(usageReporterKey{}).(UsageReporter); ok && reporter != nil {
+		reporter(usage)
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
@@ -13,6 +14,18 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// DefaultOutputSampleRate 设备没有协商采样率时下发音频的采样率
+const DefaultOutputSampleRate = 24000
+
+// IsOpusSampleRate 判断采样率是否可以直接用于Opus编码
+func IsOpusSampleRate(sampleRate int) bool {
+	switch sampleRate {
+	case 8000, 12000, 16000, 24000, 48000:
+		return true
+	}
+	return false
+}
+
 // OpusDecoder 封装opus解码器
 type OpusDecoder struct {
 	decoder   *opus.OpusDecoder
@@ -220,8 +233,8 @@ func SaveAudioToWavFile(data []byte, fileName string, sampleRate int, channels i
 	return nil
 }
 
-// 写入WAV文件头
-func writeWavHeader(file *os.File, dataSize int, sampleRate, channels, bitsPerSample int) error {
+// wavHeader 生成44字节的PCM WAV文件头
+func wavHeader(dataSize int, sampleRate, channels, bitsPerSample int) []byte {
 	// RIFF块
 	header := make([]byte, 44)
 	copy(header[0:4], []byte("RIFF"))
@@ -284,7 +297,12 @@ func writeWavHeader(file *os.File, dataSize int, sampleRate, channels, bitsPerSa
 	header[42] = byte(dataSize >> 16)
 	header[43] = byte(dataSize >> 24)
 
-	_, err := file.Write(header)
+	return header
+}
+
+// 写入WAV文件头
+func writeWavHeader(file *os.File, dataSize int, sampleRate, channels, bitsPerSample int) error {
+	_, err := file.Write(wavHeader(dataSize, sampleRate, channels, bitsPerSample))
 	return err
 }
 
@@ -316,7 +334,8 @@ func ReadPCMDataFromWavFile(filePath string) ([]byte, error) {
 	return pcmData, nil
 }
 
-func AudioToPCMData(audioFile string) ([][]byte, float64, error) {
+// AudioToPCMData 将MP3文件解码为指定采样率的单声道PCM，整段PCM作为一个切片返回
+func AudioToPCMData(audioFile string, targetSampleRate int) ([][]byte, float64, error) {
 	file, err := os.Open(audioFile)
 	if err != nil {
 		return nil, 0, fmt.Errorf("打开音频文件失败: %v", err)
@@ -330,8 +349,6 @@ func AudioToPCMData(audioFile string) ([][]byte, float64, error) {
 
 	mp3SampleRate := decoder.SampleRate()
 	//fmt.Println("AudioToPCMData 原始MP3采样率:", mp3SampleRate)
-	// 目标采样率设为24kHz
-	targetSampleRate := 24000
 
 	// decoder.Length() 返回解码后的PCM数据总字节数 (16-bit little-endian stereo)
 	pcmBytes := make([]byte, decoder.Length())
@@ -397,37 +414,85 @@ func AudioToPCMData(audioFile string) ([][]byte, float64, error) {
 	return [][]byte{monoPcmDataBytes}, duration, nil
 }
 
-// AudioToOpusData 将音频文件转换为Opus数据块
-func AudioToOpusData(audioFile string) ([][]byte, float64, error) {
-
-	var pcmData [][]byte
-	var err error
-	var duration float64
+// LongformAudioDir 长文本合成音频的保存目录，播放后不随临时音频删除
+const LongformAudioDir = "tmp/longform"
 
-	// 获取采样率 (固定使用24000Hz作为Opus编码的采样率)
-	// 如果采样率不是24000Hz，PCMSlicesToOpusData会处理重采样
-	opusSampleRate := 24000
-	channels := 1
+// IsLongformAudioFile 判断是否为长文本合成音频文件
+func IsLongformAudioFile(filePath string) bool {
+	return strings.HasPrefix(filepath.ToSlash(filepath.Clean(filePath)), LongformAudioDir+"/")
+}
 
+// AudioFileToPCM 将MP3或WAV文件解码为指定采样率的单声道PCM，整段PCM作为一个切片返回
+func AudioFileToPCM(audioFile string, sampleRate int) ([][]byte, float64, error) {
 	if strings.HasSuffix(audioFile, ".mp3") {
-		// 先将MP3转为PCM
-		pcmData, duration, err = AudioToPCMData(audioFile)
-		if err != nil {
-			return nil, 0, fmt.Errorf("PCM转换失败: %v", err)
-		}
+		return AudioToPCMData(audioFile, sampleRate)
+	}
 
-		if len(pcmData) == 0 {
-			return nil, 0, fmt.Errorf("PCM转换结果为空")
+	file, err := os.Open(audioFile)
+	if err != nil {
+		return nil, 0, fmt.Errorf("打开WAV文件失败: %v", err)
+	}
+	defer file.Close()
+	header := make([]byte, 44)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, 0, fmt.Errorf("读取WAV头失败: %v", err)
+	}
+	channels := int(binary.LittleEndian.Uint16(header[22:24]))
+	wavSampleRate := int(binary.LittleEndian.Uint32(header[24:28]))
+	if channels <= 0 || wavSampleRate <= 0 {
+		return nil, 0, fmt.Errorf("无效的WAV参数: sample_rate=%d, channels=%d", wavSampleRate, channels)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("读取PCM数据失败: %v", err)
+	}
+
+	// 混合为单声道后重采样
+	bytesPerSample := 2 * channels
+	mono := make([]int16, len(data)/bytesPerSample)
+	for i := range mono {
+		var sum int32
+		for c := 0; c < channels; c++ {
+			offset := i*bytesPerSample + c*2
+			sum += int32(int16(binary.LittleEndian.Uint16(data[offset : offset+2])))
 		}
+		mono[i] = int16(sum / int32(channels))
+	}
+	resampled := resamplePCM(mono, wavSampleRate, sampleRate)
+	pcm := make([]byte, len(resampled)*2)
+	for i, sample := range resampled {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(sample))
+	}
+	return [][]byte{pcm}, float64(len(resampled)) / float64(sampleRate), nil
+}
 
-	} else {
-		var singlePcmData []byte
-		singlePcmData, _ = ReadPCMDataFromWavFile(audioFile)
-		pcmData = [][]byte{singlePcmData}
+// SplitPCMFrames 将单声道PCM按帧时长切帧，最后一帧不足时补齐静音
+func SplitPCMFrames(pcm []byte, sampleRate, frameDuration int) [][]byte {
+	bytesPerFrame := sampleRate * frameDuration / 1000 * 2
+	if bytesPerFrame <= 0 {
+		return [][]byte{pcm}
+	}
+	frames := make([][]byte, 0, (len(pcm)+bytesPerFrame-1)/bytesPerFrame)
+	for start := 0; start < len(pcm); start += bytesPerFrame {
+		frame := make([]byte, bytesPerFrame)
+		copy(frame, pcm[start:])
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// AudioToOpusData 将MP3或WAV文件转换为指定采样率的单声道Opus数据块
+func AudioToOpusData(audioFile string, sampleRate int) ([][]byte, float64, error) {
+	pcmData, duration, err := AudioFileToPCM(audioFile, sampleRate)
+	if err != nil {
+		return nil, 0, fmt.Errorf("PCM转换失败: %v", err)
+	}
+	if len(pcmData) == 0 || len(pcmData[0]) == 0 {
+		return nil, 0, fmt.Errorf("PCM转换结果为空")
 	}
 
 	// 将PCM转换为Opus
-	opusData, err := PCMSlicesToOpusData(pcmData, opusSampleRate, channels, 0)
+	opusData, err := PCMSlicesToOpusData(pcmData, sampleRate, 1, 0)
 	if err != nil {
 		return nil, 0, fmt.Errorf("PCM转Opus失败: %v", err)
 	}
@@ -791,3 +856,41 @@ func resamplePCM(input []int16, inputSampleRate, outputSampleRate int) []int16 {
 
 	return output
 }
+
+// PCMResampler 把连续的16位小端PCM转换为指定采样率的单声道float32采样（-1~1）
+// 按最近采样点重采样，跨多次调用保持位置连续，适合VAD、唤醒词等对音质不敏感的检测
+type PCMResampler struct {
+	channels int
+	step     float64 // 每个输出采样对应的输入采样数
+	pos      float64 // 下一个输出采样在当前输入块中的位置
+}
+
+// NewPCMResampler 创建重采样器，多声道输入只取第一个声道
+func NewPCMResampler(inputSampleRate, channels, outputSampleRate int) (*PCMResampler, error) {
+	if inputSampleRate <= 0 || outputSampleRate <= 0 {
+		return nil, fmt.Errorf("无效的采样率: %d -> %d", inputSampleRate, outputSampleRate)
+	}
+	return &PCMResampler{
+		channels: max(channels, 1),
+		step:     float64(inputSampleRate) / float64(outputSampleRate),
+	}, nil
+}
+
+// Float32 转换一段PCM
+func (r *PCMResampler) Float32(pcm []byte) []float32 {
+	frame := 2 * r.channels
+	n := len(pcm) / frame
+	out := make([]float32, 0, int(float64(n)/r.step)+1)
+	for ; r.pos < float64(n); r.pos += r.step {
+		offset := int(r.pos) * frame
+		sample := int16(uint16(pcm[offset]) | uint16(pcm[offset+1])<<8)
+		out = append(out, float32(sample)/32768)
+	}
+	r.pos -= float64(n)
+	return out
+}
+
+// Reset 丢弃跨调用保留的位置
+func (r *PCMResampler) Reset() {
+	r.pos = 0
+}
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	opus "github.com/qrtc/opus-go"
+)
+
+// 下发音频的编码格式
+const (
+	AudioFormatOpus = "opus" // 原始Opus数据包，小智设备默认使用
+	AudioFormatPCM  = "pcm"  // 16位小端单声道PCM
+	AudioFormatWAV  = "wav"  // 每块都是带文件头的完整WAV，浏览器可以直接解码
+	AudioFormatMP3  = "mp3"  // MP3字节流，需要ffmpeg编码
+)
+
+const mp3Bitrate = "64k"
+
+// AudioOutput 下发音频的编码参数
+type AudioOutput struct {
+	Format     string // opus、pcm、wav 或 mp3
+	SampleRate int
+	FFmpegPath string // mp3 编码使用的 ffmpeg，为空时从 PATH 查找
+	// Encoder 多段音频共用的编码器，非空时直接使用，不会 Flush 或 Close，由调用方在整段下发结束时处理。
+	// 为空时每次调用新建编码器并在结束时 Flush
+	Encoder FrameEncoder
+}
+
+// FrameEncoder 把单声道16位PCM帧编码为下发给设备的音频块
+type FrameEncoder interface {
+	// Encode 编码一帧PCM，返回可以立即下发的数据，编码器有缓冲时可能为空
+	Encode(pcm []byte) ([]byte, error)
+	// Flush 结束编码，返回缓冲中剩余的数据，之后不能再调用 Encode
+	Flush() ([]byte, error)
+	// Close 释放编码器，在 Flush 之前调用时丢弃剩余数据
+	Close() error
+}
+
+// NewFrameEncoder 按下发格式创建编码器
+func NewFrameEncoder(output AudioOutput) (FrameEncoder, error) {
+	switch output.Format {
+	case AudioFormatOpus:
+		encoder, err := opus.CreateOpusEncoder(&opus.OpusEncoderConfig{
+			SampleRate:    output.SampleRate,
+			MaxChannels:   1,
+			Application:   opus.AppVoIP,
+			FrameDuration: opus.Framesize60Ms,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("创建Opus编码器失败: %v", err)
+		}
+		return &opusFrameEncoder{encoder: encoder}, nil
+	case AudioFormatPCM:
+		return pcmFrameEncoder{}, nil
+	case AudioFormatWAV:
+		return wavFrameEncoder{sampleRate: output.SampleRate}, nil
+	case AudioFormatMP3:
+		return newMP3FrameEncoder(output)
+	default:
+		return nil, fmt.Errorf("不支持的音频格式: %s", output.Format)
+	}
+}
+
+// encoderFor 返回 output 指定的共用编码器，没有时新建，owned 表示编码器由本次调用创建
+func encoderFor(output AudioOutput) (encoder FrameEncoder, owned bool, err error) {
+	if output.Encoder != nil {
+		return output.Encoder, false, nil
+	}
+	encoder, err = NewFrameEncoder(output)
+	return encoder, err == nil, err
+}
+
+// FFmpegAvailable 判断 ffmpeg 是否可用，path 为空时从 PATH 查找
+func FFmpegAvailable(path string) bool {
+	if path == "" {
+		path = "ffmpeg"
+	}
+	_, err := exec.LookPath(path)
+	return err == nil
+}
+
+// opusFrameEncoder 每帧编码为一个Opus数据包
+type opusFrameEncoder struct {
+	encoder *opus.OpusEncoder
+}
+
+func (e *opusFrameEncoder) Encode(pcm []byte) ([]byte, error) {
+	out := make([]byte, len(pcm))
+	n, err := e.encoder.Encode(pcm, out)
+	if err != nil || n == 0 {
+		return nil, nil // 跳过编码失败的帧
+	}
+	return out[:n], nil
+}
+
+func (e *opusFrameEncoder) Flush() ([]byte, error) { return nil, nil }
+
+func (e *opusFrameEncoder) Close() error { return e.encoder.Close() }
+
+// pcmFrameEncoder 原样下发PCM
+type pcmFrameEncoder struct{}
+
+func (pcmFrameEncoder) Encode(pcm []byte) ([]byte, error) {
+	out := make([]byte, len(pcm))
+	copy(out, pcm)
+	return out, nil
+}
+
+func (pcmFrameEncoder) Flush() ([]byte, error) { return nil, nil }
+
+func (pcmFrameEncoder) Close() error { return nil }
+
+// wavFrameEncoder 每帧加上WAV文件头，设备可以逐块独立解码播放
+type wavFrameEncoder struct {
+	sampleRate int
+}
+
+func (e wavFrameEncoder) Encode(pcm []byte) ([]byte, error) {
+	return append(wavHeader(len(pcm), e.sampleRate, 1, 16), pcm...), nil
+}
+
+func (wavFrameEncoder) Flush() ([]byte, error) { return nil, nil }
+
+func (wavFrameEncoder) Close() error { return nil }
+
+// mp3FrameEncoder 通过 ffmpeg 进程流式编码MP3，每次返回进程已经输出的数据
+type mp3FrameEncoder struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	done   chan struct{} // 读取输出的协程结束时关闭
+	stderr bytes.Buffer  // 由 exec 的协程写入，只能在 Wait 返回后读取
+
+	mu      sync.Mutex
+	pending bytes.Buffer
+
+	finishOnce sync.Once
+	finishErr  error
+}
+
+func newMP3FrameEncoder(output AudioOutput) (*mp3FrameEncoder, error) {
+	ffmpeg := output.FFmpegPath
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	e := &mp3FrameEncoder{done: make(chan struct{})}
+	e.cmd = exec.Command(ffmpeg,
+		"-v", "error",
+		"-f", "s16le",
+		"-ar", strconv.Itoa(output.SampleRate),
+		"-ac", "1",
+		"-i", "pipe:0",
+		"-f", "mp3",
+		"-b:a", mp3Bitrate,
+		"-flush_packets", "1",
+		"pipe:1",
+	)
+	e.cmd.Stderr = &e.stderr
+	stdin, err := e.cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建MP3编码器失败: %v", err)
+	}
+	stdout, err := e.cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建MP3编码器失败: %v", err)
+	}
+	if err := e.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动ffmpeg失败: %v", err)
+	}
+	e.stdin = stdin
+
+	go func() {
+		defer close(e.done)
+		buf := make([]byte, 4096)
+		for {
+			n, err := stdout.Read(buf)
+			if n > 0 {
+				e.mu.Lock()
+				e.pending.Write(buf[:n])
+				e.mu.Unlock()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return e, nil
+}
+
+// take 取出已经编码好的数据
+func (e *mp3FrameEncoder) take() []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pending.Len() == 0 {
+		return nil
+	}
+	out := make([]byte, e.pending.Len())
+	copy(out, e.pending.Bytes())
+	e.pending.Reset()
+	return out
+}
+
+// finish 关闭输入并等待 ffmpeg 退出，kill 时不等待剩余数据编码完成。
+// 只执行一次，之后的调用返回第一次的结果；进程退出后才读取 stderr
+func (e *mp3FrameEncoder) finish(kill bool) error {
+	e.finishOnce.Do(func() {
+		e.stdin.Close()
+		if kill {
+			e.cmd.Process.Kill()
+		}
+		<-e.done
+		if err := e.cmd.Wait(); err != nil && !kill {
+			e.finishErr = fmt.Errorf("ffmpeg编码MP3失败: %v %s", err, strings.TrimSpace(e.stderr.String()))
+		}
+	})
+	return e.finishErr
+}
+
+func (e *mp3FrameEncoder) Encode(pcm []byte) ([]byte, error) {
+	if _, err := e.stdin.Write(pcm); err != nil {
+		// 写入失败说明 ffmpeg 已退出或编码器已关闭，等待进程结束后再读取错误输出
+		if finishErr := e.finish(false); finishErr != nil {
+			return nil, finishErr
+		}
+		return nil, fmt.Errorf("MP3编码失败: %v", err)
+	}
+	return e.take(), nil
+}
+
+func (e *mp3FrameEncoder) Flush() ([]byte, error) {
+	err := e.finish(false)
+	return e.take(), err
+}
+
+func (e *mp3FrameEncoder) Close() error {
+	e.finish(true)
+	return nil
+}
+
+// AudioFileToFrames 将MP3或WAV文件解码后按帧时长切帧并编码为下发格式，返回的帧可能为空（编码器缓冲中），
+// 发送时仍按帧时长推进播放进度
+func AudioFileToFrames(audioFile string, output AudioOutput, frameDuration int) ([][]byte, float64, error) {
+	pcmData, duration, err := AudioFileToPCM(audioFile, output.SampleRate)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(pcmData) == 0 || len(pcmData[0]) == 0 {
+		return nil, 0, fmt.Errorf("PCM转换结果为空")
+	}
+
+	encoder, owned, err := encoderFor(output)
+	if err != nil {
+		return nil, 0, err
+	}
+	frames := SplitPCMFrames(pcmData[0], output.SampleRate, frameDuration)
+	if !owned {
+		// 共用编码器缓冲中的数据随下一段音频或调用方的 Flush 输出
+		for i, frame := range frames {
+			if frames[i], err = encoder.Encode(frame); err != nil {
+				return nil, 0, err
+			}
+		}
+		return frames, duration, nil
+	}
+	defer encoder.Close()
+	for i, frame := range frames {
+		if frames[i], err = encoder.Encode(frame); err != nil {
+			return nil, 0, err
+		}
+	}
+	rest, err := encoder.Flush()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(rest) > 0 {
+		frames = append(frames, rest)
+	}
+	return frames, duration, nil
+}
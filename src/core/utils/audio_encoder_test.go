@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFFmpeg 写一个代替 ffmpeg 的脚本，忽略参数执行 body
+func fakeFFmpeg(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMP3EncoderFlush(t *testing.T) {
+	output := AudioOutput{Format: AudioFormatMP3, SampleRate: 16000, FFmpegPath: fakeFFmpeg(t, "exec cat")}
+	encoder, err := NewFrameEncoder(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encoder.Close()
+
+	var got []byte
+	for _, frame := range [][]byte{[]byte("abc"), []byte("def")} {
+		out, err := encoder.Encode(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, out...)
+	}
+	rest, err := encoder.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got = append(got, rest...); string(got) != "abcdef" {
+		t.Errorf("output = %q, want %q", got, "abcdef")
+	}
+	// Flush 之后再 Close 不报错
+	if err := encoder.Close(); err != nil {
+		t.Errorf("Close after Flush: %v", err)
+	}
+}
+
+func TestMP3EncoderReportsStderr(t *testing.T) {
+	output := AudioOutput{Format: AudioFormatMP3, SampleRate: 16000, FFmpegPath: fakeFFmpeg(t, "echo bad input >&2; exit 1")}
+	encoder, err := NewFrameEncoder(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encoder.Close()
+
+	// 进程退出后写入失败，错误信息包含 ffmpeg 的错误输出
+	frame := bytes.Repeat([]byte{0}, 64*1024)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err = encoder.Encode(frame)
+		if err != nil || time.Now().After(deadline) {
+			break
+		}
+	}
+	if err == nil || !strings.Contains(err.Error(), "bad input") {
+		t.Fatalf("Encode error = %v, want stderr output", err)
+	}
+	if _, err := encoder.Flush(); err == nil || !strings.Contains(err.Error(), "bad input") {
+		t.Errorf("Flush error = %v, want stderr output", err)
+	}
+}
+
+func TestSharedEncoderNotFlushed(t *testing.T) {
+	output := AudioOutput{Format: AudioFormatMP3, SampleRate: 16000, FFmpegPath: fakeFFmpeg(t, "exec cat")}
+	encoder, err := NewFrameEncoder(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encoder.Close()
+	output.Encoder = encoder
+
+	// 两段音频共用编码器，第一段结束后编码器仍可继续使用
+	for i := 0; i < 2; i++ {
+		if _, err := StreamAudioToFrames(fillChunks(3200), output, func([]byte) error { return nil }); err != nil {
+			t.Fatalf("segment %d: %v", i, err)
+		}
+	}
+	if _, err := encoder.Encode([]byte{0, 0}); err != nil {
+		t.Errorf("shared encoder closed by StreamAudioToFrames: %v", err)
+	}
+}
+
+// fillChunks 返回一段 size 字节静音的WAV数据流
+func fillChunks(size int) <-chan []byte {
+	chunks := make(chan []byte, 2)
+	chunks <- wavHeader(size, 16000, 1, 16)
+	chunks <- make([]byte, size)
+	close(chunks)
+	return chunks
+}
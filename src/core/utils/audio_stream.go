@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+const streamFrameDuration = 60 // 流式输出的帧时长（毫秒）
+
+// StreamAudioToFrames 将流式到达的音频数据（MP3或WAV）实时解码、重采样为下发采样率的单声道，
+// 按60ms切帧、编码为下发格式后回调 onFrame，每帧回调一次；编码器缓冲中时 frame 为空，只需推进播放进度，
+// 结束时编码器剩余的数据再回调一次（使用 output.Encoder 共用的编码器时由调用方 Flush）。onFrame 返回错误时立即停止处理并返回该错误。返回值为已输出音频的时长（秒）。
+func StreamAudioToFrames(chunks <-chan []byte, output AudioOutput, onFrame func(frame []byte) error) (float64, error) {
+	if output.SampleRate <= 0 {
+		output.SampleRate = DefaultOutputSampleRate
+	}
+	targetSampleRate := output.SampleRate
+	pr, pw := io.Pipe()
+	go func() {
+		for chunk := range chunks {
+			if _, err := pw.Write(chunk); err != nil {
+				// 读取端已关闭，继续消费剩余数据避免生产者阻塞
+				for range chunks {
+				}
+				return
+			}
+		}
+		pw.Close()
+	}()
+	defer pr.Close()
+
+	reader := bufio.NewReader(pr)
+	head, err := reader.Peek(4)
+	if err != nil {
+		if err == io.EOF {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("读取音频流失败: %v", err)
+	}
+
+	var src io.Reader
+	var sampleRate, channels int
+	if string(head) == "RIFF" {
+		// WAV格式，跳过44字节的文件头
+		header := make([]byte, 44)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return 0, fmt.Errorf("读取WAV头失败: %v", err)
+		}
+		channels = int(binary.LittleEndian.Uint16(header[22:24]))
+		sampleRate = int(binary.LittleEndian.Uint32(header[24:28]))
+		src = reader
+	} else {
+		decoder, err := mp3.NewDecoder(reader)
+		if err != nil {
+			return 0, fmt.Errorf("创建MP3解码器失败: %v", err)
+		}
+		// go-mp3 固定解码为16位立体声
+		channels = 2
+		sampleRate = decoder.SampleRate()
+		src = decoder
+	}
+	if channels <= 0 || sampleRate <= 0 {
+		return 0, fmt.Errorf("无效的音频参数: sample_rate=%d, channels=%d", sampleRate, channels)
+	}
+
+	encoder, owned, err := encoderFor(output)
+	if err != nil {
+		return 0, err
+	}
+	if owned {
+		defer encoder.Close()
+	}
+
+	bytesPerFrame := targetSampleRate * streamFrameDuration / 1000 * 2
+	emit := func(frame []byte) error {
+		out, err := encoder.Encode(frame)
+		if err != nil {
+			return err
+		}
+		return onFrame(out)
+	}
+
+	bytesPerSample := 2 * channels
+	buf := make([]byte, 4608*bytesPerSample)
+	var leftover, pending []byte
+	totalSamples := 0
+
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			raw := append(leftover, buf[:n]...)
+			usable := len(raw) - len(raw)%bytesPerSample
+			leftover = append([]byte{}, raw[usable:]...)
+
+			// 混合为单声道
+			mono := make([]int16, usable/bytesPerSample)
+			for i := range mono {
+				var sum int32
+				for c := 0; c < channels; c++ {
+					offset := i*bytesPerSample + c*2
+					sum += int32(int16(binary.LittleEndian.Uint16(raw[offset : offset+2])))
+				}
+				mono[i] = int16(sum / int32(channels))
+			}
+
+			resampled := resamplePCM(mono, sampleRate, targetSampleRate)
+			totalSamples += len(resampled)
+			for _, sample := range resampled {
+				pending = append(pending, byte(sample), byte(sample>>8))
+			}
+
+			for len(pending) >= bytesPerFrame {
+				if err := emit(pending[:bytesPerFrame]); err != nil {
+					return float64(totalSamples) / float64(targetSampleRate), err
+				}
+				pending = pending[bytesPerFrame:]
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return float64(totalSamples) / float64(targetSampleRate), fmt.Errorf("解码音频流失败: %v", readErr)
+		}
+	}
+
+	// 最后一帧不足时补齐静音
+	if len(pending) > 0 {
+		frame := make([]byte, bytesPerFrame)
+		copy(frame, pending)
+		if err := emit(frame); err != nil {
+			return float64(totalSamples) / float64(targetSampleRate), err
+		}
+	}
+	if !owned {
+		// 共用编码器由调用方在整段下发结束时 Flush
+		return float64(totalSamples) / float64(targetSampleRate), nil
+	}
+	rest, err := encoder.Flush()
+	if err != nil {
+		return float64(totalSamples) / float64(targetSampleRate), err
+	}
+	if len(rest) > 0 {
+		if err := onFrame(rest); err != nil {
+			return float64(totalSamples) / float64(targetSampleRate), err
+		}
+	}
+
+	return float64(totalSamples) / float64(targetSampleRate), nil
+}
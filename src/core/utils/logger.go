@@ -37,6 +37,10 @@ type Logger struct {
 	mu          sync.RWMutex  // 读写锁保护
 	ticker      *time.Ticker  // 定时器
 	stopCh      chan struct{} // 停止信号
+
+	root     *Logger                       // 派生记录器指向根记录器，共享输出与轮转
+	fields   map[string]interface{}        // 固定附加字段
+	fieldsFn func() map[string]interface{} // 动态附加字段，每条日志记录时求值
 }
 
 // configLogLevelToLogrusLevel 将配置中的日志级别转换为logrus.Level
@@ -84,6 +88,19 @@ func NewLogger(config *configs.Config) (*Logger, error) {
 	multiWriter := io.MultiWriter(file, os.Stdout)
 	logger.SetOutput(multiWriter)
 
+	// 日志脱敏，同时作用于直接使用logrus标准记录器的模块
+	if config.Redaction.Logs {
+		redactor, err := NewRedactor(config.Redaction)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if redactor != nil {
+			logger.SetFormatter(&redactFormatter{Formatter: logger.Formatter, redactor: redactor})
+			logrus.SetFormatter(&redactFormatter{Formatter: logrus.StandardLogger().Formatter, redactor: redactor})
+		}
+	}
+
 	loggerInstance := &Logger{
 		config:      config,
 		logger:      logger,
@@ -170,19 +187,31 @@ func (l *Logger) rotateLogFile(newDate string) {
 
 // cleanOldLogs 清理旧日志文件
 func (l *Logger) cleanOldLogs() {
-	logDir := l.config.Log.LogDir
+	removed, err := CleanOldLogs(l.config)
+	for _, fileName := range removed {
+		l.logger.WithField("file", fileName).Info("已删除旧日志文件")
+	}
+	if err != nil {
+		l.logger.WithError(err).Error("清理旧日志文件失败")
+	}
+}
+
+// CleanOldLogs 删除超过保留天数的已轮转日志文件，返回删除的文件名，供轮转和定时清理任务调用
+func CleanOldLogs(config *configs.Config) ([]string, error) {
+	logDir := config.Log.LogDir
 
 	// 读取日志目录
 	entries, err := os.ReadDir(logDir)
 	if err != nil {
-		l.logger.WithError(err).Error("读取日志目录失败")
-		return
+		return nil, fmt.Errorf("读取日志目录失败: %v", err)
 	}
 
 	// 计算保留截止日期
 	cutoffDate := time.Now().AddDate(0, 0, -LogRetentionDays)
-	baseFileName := strings.TrimSuffix(l.config.Log.LogFile, filepath.Ext(l.config.Log.LogFile))
-	ext := filepath.Ext(l.config.Log.LogFile)
+	baseFileName := strings.TrimSuffix(config.Log.LogFile, filepath.Ext(config.Log.LogFile))
+	ext := filepath.Ext(config.Log.LogFile)
+	var removed []string
+	var firstErr error
 
 	for _, entry := range entries {
 		if entry.IsDir() {
@@ -206,20 +235,71 @@ func (l *Logger) cleanOldLogs() {
 			if fileDate.Before(cutoffDate) {
 				filePath := filepath.Join(logDir, fileName)
 				if err := os.Remove(filePath); err != nil {
-					l.logger.WithFields(logrus.Fields{
-						"file":  fileName,
-						"error": err.Error(),
-					}).Error("删除旧日志文件失败")
+					if firstErr == nil {
+						firstErr = fmt.Errorf("删除旧日志文件 %s 失败: %v", fileName, err)
+					}
 				} else {
-					l.logger.WithField("file", fileName).Info("已删除旧日志文件")
+					removed = append(removed, fileName)
 				}
 			}
 		}
 	}
+	return removed, firstErr
+}
+
+// WithFields 派生一个附加固定字段的日志记录器，与原记录器共享输出
+func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
+	merged := make(map[string]interface{}, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{
+		config:   l.config,
+		root:     l.rootLogger(),
+		fields:   merged,
+		fieldsFn: l.fieldsFn,
+	}
+}
+
+// WithFieldsFunc 派生一个附加动态字段的日志记录器，fn 在每条日志记录时求值
+// 用于连接级别的日志上下文，如 device_id、session_id、turn_id 等会变化的字段
+func (l *Logger) WithFieldsFunc(fn func() map[string]interface{}) *Logger {
+	parentFn := l.fieldsFn
+	return &Logger{
+		config: l.config,
+		root:   l.rootLogger(),
+		fields: l.fields,
+		fieldsFn: func() map[string]interface{} {
+			if parentFn == nil {
+				return fn()
+			}
+			merged := parentFn()
+			for k, v := range fn() {
+				merged[k] = v
+			}
+			return merged
+		},
+	}
+}
+
+// rootLogger 获取持有输出的根记录器
+func (l *Logger) rootLogger() *Logger {
+	if l.root != nil {
+		return l.root
+	}
+	return l
 }
 
 // Close 关闭日志文件
 func (l *Logger) Close() error {
+	// 派生记录器不持有文件
+	if l.root != nil {
+		return nil
+	}
+
 	// 停止定时器
 	if l.ticker != nil {
 		l.ticker.Stop()
@@ -238,10 +318,24 @@ func (l *Logger) Close() error {
 // log 通用日志记录函数（内部使用）
 func (l *Logger) log(level logrus.Level, msg string, fields ...interface{}) {
 	// 使用读锁保护并发访问
-	l.mu.RLock()
-	defer l.mu.RUnlock()
+	root := l.rootLogger()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+
+	base := root.logger
+	if base == nil {
+		// 未通过 NewLogger 初始化时退回到logrus标准记录器
+		base = logrus.StandardLogger()
+	}
+	entry := base.WithField("time", time.Now())
 
-	entry := l.logger.WithField("time", time.Now())
+	// 附加上下文字段
+	if len(l.fields) > 0 {
+		entry = entry.WithFields(logrus.Fields(l.fields))
+	}
+	if l.fieldsFn != nil {
+		entry = entry.WithFields(logrus.Fields(l.fieldsFn()))
+	}
 
 	// 处理fields参数
 	if len(fields) > 0 && fields[0] != nil {
@@ -267,7 +361,7 @@ func (l *Logger) log(level logrus.Level, msg string, fields ...interface{}) {
 
 // Debug 记录调试级别日志
 func (l *Logger) Debug(msg string, args ...interface{}) {
-	if l.config.Log.LogLevel == "DEBUG" {
+	if l.config == nil || l.config.Log.LogLevel == "DEBUG" {
 		if len(args) > 0 && containsFormatPlaceholders(msg) {
 			formattedMsg := fmt.Sprintf(msg, args...)
 			l.log(logrus.DebugLevel, formattedMsg)
@@ -269,3 +269,8 @@ func max(a, b int) int {
 	}
 	return b
 }
+
+// MusicSimilarity 计算搜索词与歌曲名称的相似度（0-1之间），忽略大小写和标点
+func MusicSimilarity(query, name string) float64 {
+	return calculateSimilarity(normalizeString(query), normalizeString(name))
+}
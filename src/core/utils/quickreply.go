@@ -10,22 +10,34 @@ import (
 
 // QuickReplyCache 快速回复缓存配置
 type QuickReplyCache struct {
-	CacheDir    string // 缓存目录，默认为 "wake_replay"
-	TTSProvider string // TTS提供商名称
-	VoiceName   string // 音色名称
-	AudioFormat string // 音频格式，默认为 "mp3"
+	CacheDir    string  // 缓存目录，默认为 "wake_replay"
+	TTSProvider string  // TTS提供商名称
+	VoiceName   string  // 音色名称
+	Speed       float64 // 语速倍率，不同语速的音频分开缓存
+	AudioFormat string  // 音频格式，默认为 "mp3"
 }
 
 // NewQuickReplyCache 创建快速回复缓存配置
-func NewQuickReplyCache(ttsProvider, voiceName string) *QuickReplyCache {
+func NewQuickReplyCache(ttsProvider, voiceName string, speed float64) *QuickReplyCache {
 	return &QuickReplyCache{
 		CacheDir:    "wake_replay",
 		TTSProvider: ttsProvider,
 		VoiceName:   voiceName,
+		Speed:       speed,
 		AudioFormat: "mp3",
 	}
 }
 
+// Variant 影响合成结果的参数，作为缓存文件名的一部分。
+// 默认语速不写入文件名，与之前缓存的文件兼容
+func (qrc *QuickReplyCache) Variant() string {
+	variant := fmt.Sprintf("%s_%s", qrc.TTSProvider, qrc.VoiceName)
+	if qrc.Speed > 0 && qrc.Speed != 1 {
+		variant += fmt.Sprintf("_x%.2f", qrc.Speed)
+	}
+	return variant + "." + qrc.AudioFormat
+}
+
 // FindCachedAudio 查找已缓存的快速回复音频文件
 func (qrc *QuickReplyCache) FindCachedAudio(text string) string {
 	// 检查目录是否存在
@@ -72,10 +84,8 @@ func (qrc *QuickReplyCache) generateFilename(text string) string {
 	// 对文本进行安全化处理
 	safeText := qrc.sanitizeFilename(text)
 
-	// 生成文件名格式: text_provider_voice.format
-	filename := fmt.Sprintf("%s_%s_%s.%s", safeText, qrc.TTSProvider, qrc.VoiceName, qrc.AudioFormat)
-
-	return filename
+	// 生成文件名格式: text_provider_voice[_x语速].format
+	return safeText + "_" + qrc.Variant()
 }
 
 // sanitizeFilename 清理文件名，移除不安全的字符
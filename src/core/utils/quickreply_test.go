@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQuickReplyCacheVariant(t *testing.T) {
+	tests := []struct {
+		name     string
+		cache    *QuickReplyCache
+		expected string
+	}{
+		{name: "默认语速与旧缓存文件名兼容", cache: NewQuickReplyCache("edge", "zh-CN-XiaoxiaoNeural", 1), expected: "edge_zh-CN-XiaoxiaoNeural.mp3"},
+		{name: "未配置语速", cache: NewQuickReplyCache("edge", "zh-CN-XiaoxiaoNeural", 0), expected: "edge_zh-CN-XiaoxiaoNeural.mp3"},
+		{name: "加快语速", cache: NewQuickReplyCache("edge", "zh-CN-XiaoxiaoNeural", 1.2), expected: "edge_zh-CN-XiaoxiaoNeural_x1.20.mp3"},
+		{name: "放慢语速", cache: NewQuickReplyCache("edge", "zh-CN-XiaoxiaoNeural", 0.8), expected: "edge_zh-CN-XiaoxiaoNeural_x0.80.mp3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cache.Variant(); got != tt.expected {
+				t.Errorf("Variant() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestQuickReplyCacheSeparatesSpeeds(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.mp3")
+	if err := os.WriteFile(source, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	normal := NewQuickReplyCache("edge", "voice", 1)
+	normal.CacheDir = filepath.Join(dir, "cache")
+	fast := NewQuickReplyCache("edge", "voice", 1.5)
+	fast.CacheDir = normal.CacheDir
+
+	if err := normal.SaveCachedAudio("你好", source); err != nil {
+		t.Fatal(err)
+	}
+	if normal.FindCachedAudio("你好") == "" {
+		t.Fatal("默认语速的缓存未命中")
+	}
+	if got := fast.FindCachedAudio("你好"); got != "" {
+		t.Fatalf("不同语速命中了默认语速的缓存: %s", got)
+	}
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultRedactReplacement = "[已脱敏]"
+
+// builtinRedactRules 内置脱敏规则，按顺序匹配，身份证号需在手机号之前
+var builtinRedactRules = []struct {
+	name        string
+	pattern     string
+	replacement string
+}{
+	{"id_card", `\b[1-9]\d{5}(?:19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`, "[身份证号]"},
+	{"phone", `(?:\+86[- ]?|\b)1[3-9]\d{9}\b|\b0\d{2,3}-\d{7,8}\b`, "[电话]"},
+	{"address", `(?:\p{Han}{1,4}?(?:省|自治区|市|区|县|镇|乡|街道))*\p{Han}{1,6}?(?:路|街|巷|弄|胡同|大道)\d+号(?:\d+(?:栋|幢|楼|单元|层|室|号))*`, "[地址]"},
+}
+
+type redactRule struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// Redactor 屏蔽文本中的手机号、身份证号、地址等个人信息
+type Redactor struct {
+	rules []redactRule
+}
+
+// NewRedactor 按配置创建脱敏器，未开启时返回nil；nil脱敏器原样返回文本
+func NewRedactor(config configs.RedactionConfig) (*Redactor, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	enabled := make(map[string]bool, len(config.Builtin))
+	for _, name := range config.Builtin {
+		enabled[name] = true
+	}
+
+	r := &Redactor{}
+	for _, rule := range builtinRedactRules {
+		if len(enabled) == 0 || enabled[rule.name] {
+			r.rules = append(r.rules, redactRule{regexp.MustCompile(rule.pattern), rule.replacement})
+		}
+	}
+	for _, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("脱敏规则 %s 无效: %v", pattern.Name, err)
+		}
+		replacement := pattern.Replacement
+		if replacement == "" {
+			replacement = defaultRedactReplacement
+		}
+		r.rules = append(r.rules, redactRule{re, replacement})
+	}
+	return r, nil
+}
+
+// Redact 返回脱敏后的文本
+func (r *Redactor) Redact(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	for _, rule := range r.rules {
+		text = rule.re.ReplaceAllLiteralString(text, rule.replacement)
+	}
+	return text
+}
+
+// redactFormatter 输出前对日志消息和字符串字段脱敏
+type redactFormatter struct {
+	logrus.Formatter
+	redactor *Redactor
+}
+
+// Format logrus.Formatter接口实现，在副本上脱敏，不修改原日志条目
+func (f *redactFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	redacted := *entry
+	redacted.Message = f.redactor.Redact(entry.Message)
+	redacted.Data = make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		switch value := v.(type) {
+		case string:
+			redacted.Data[k] = f.redactor.Redact(value)
+		case error:
+			redacted.Data[k] = f.redactor.Redact(value.Error())
+		default:
+			redacted.Data[k] = v
+		}
+	}
+	return f.Formatter.Format(&redacted)
+}
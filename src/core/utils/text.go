@@ -53,6 +53,31 @@ func SplitByPunctuation(text string) []string {
 	return result
 }
 
+var (
+	negativeReplyWords    = []string{"不", "否", "错", "别", "no"}
+	affirmativeReplyWords = []string{"是", "对", "好", "确认", "确定", "可以", "行", "没问题", "嗯", "ok", "yes"}
+)
+
+// IsNegativeReply 判断用户对是非问题的回答是否为否定
+func IsNegativeReply(text string) bool {
+	return containsAnyWord(strings.ToLower(text), negativeReplyWords)
+}
+
+// IsAffirmativeReply 判断用户对是非问题的回答是否为肯定，“不对”“不行”等否定回答不算
+func IsAffirmativeReply(text string) bool {
+	text = strings.ToLower(text)
+	return !containsAnyWord(text, negativeReplyWords) && containsAnyWord(text, affirmativeReplyWords)
+}
+
+func containsAnyWord(text string, words []string) bool {
+	for _, word := range words {
+		if strings.Contains(text, word) {
+			return true
+		}
+	}
+	return false
+}
+
 func RemoveMarkdownSyntax(text string) string {
 	// 替换Markdown符号为空格
 	cleaned := reMarkdownChars.ReplaceAllString(text, "")
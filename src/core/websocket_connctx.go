@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"xiaozhi-server-go/src/core/pool"
 	"xiaozhi-server-go/src/core/utils"
@@ -13,12 +14,14 @@ type ConnectionContext struct {
 	handler     *ConnectionHandler
 	providerSet *pool.ProviderSet
 	poolManager *pool.PoolManager
+	selection   pool.ProviderSelection // 唤醒后重新申请资源时沿用的用户选择
 	clientID    string
 	logger      *utils.Logger
 	conn        Connection
 	ctx         context.Context
 	cancel      context.CancelFunc
 	closed      int32 // 原子操作标志，0=活跃，1=已关闭
+	providerMu  sync.Mutex
 }
 
 // NewConnectionContext 创建新的连接上下文
@@ -75,6 +78,58 @@ func (c *ConnectionContext) CreateSafeCallback() func(func(*ConnectionHandler))
 	}
 }
 
+// ReleaseProviders 设备休眠时归还语音相关资源，MCP管理器与连接绑定，继续保留
+func (c *ConnectionContext) ReleaseProviders() error {
+	c.providerMu.Lock()
+	defer c.providerMu.Unlock()
+
+	if c.providerSet == nil || c.poolManager == nil {
+		return nil
+	}
+
+	if c.handler != nil {
+		c.handler.unbindProviders()
+	}
+	speechSet := &pool.ProviderSet{
+		ASR:   c.providerSet.ASR,
+		LLM:   c.providerSet.LLM,
+		TTS:   c.providerSet.TTS,
+		VLLLM: c.providerSet.VLLLM,
+	}
+	c.providerSet = &pool.ProviderSet{MCP: c.providerSet.MCP}
+
+	if err := c.poolManager.ReturnProviderSet(speechSet); err != nil {
+		return fmt.Errorf("归还资源失败: %v", err)
+	}
+	c.logger.Info("客户端 %s 进入休眠，语音资源已归还到池中", c.clientID)
+	return nil
+}
+
+// AcquireProviders 设备唤醒时重新申请语音相关资源
+func (c *ConnectionContext) AcquireProviders() error {
+	c.providerMu.Lock()
+	defer c.providerMu.Unlock()
+
+	if c.poolManager == nil {
+		return fmt.Errorf("资源池管理器未初始化")
+	}
+
+	set, err := c.poolManager.GetSpeechProviderSet(c.clientID, c.selection)
+	if err != nil {
+		return err
+	}
+	if c.providerSet != nil {
+		set.MCP = c.providerSet.MCP
+	}
+	c.providerSet = set
+
+	if c.handler != nil {
+		c.handler.bindProviders(set)
+	}
+	c.logger.Info("客户端 %s 已唤醒，语音资源已重新分配", c.clientID)
+	return nil
+}
+
 // Close 关闭连接并归还资源
 func (c *ConnectionContext) Close() error {
 	// 使用原子操作标记为已关闭
@@ -98,6 +153,8 @@ func (c *ConnectionContext) Close() error {
 	}
 
 	// 归还资源到池中
+	c.providerMu.Lock()
+	defer c.providerMu.Unlock()
 	if c.providerSet != nil && c.poolManager != nil {
 		if err := c.poolManager.ReturnProviderSet(c.providerSet); err != nil {
 			errs = append(errs, fmt.Errorf("归还资源失败: %v", err))
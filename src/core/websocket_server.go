@@ -4,14 +4,27 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
 	"xiaozhi-server-go/src/core/auth"
+	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/core/llmcache"
+	"xiaozhi-server-go/src/core/memory"
+	"xiaozhi-server-go/src/core/music"
 	"xiaozhi-server-go/src/core/pool"
+	"xiaozhi-server-go/src/core/recording"
+	"xiaozhi-server-go/src/core/rules"
+	"xiaozhi-server-go/src/core/speaker"
+	"xiaozhi-server-go/src/core/tools"
 	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/imagegen"
+	"xiaozhi-server-go/src/models"
+	"xiaozhi-server-go/src/schedule"
 	"xiaozhi-server-go/src/task"
 
 	"github.com/gorilla/websocket"
@@ -24,8 +37,19 @@ type WebSocketServer struct {
 	server            *http.Server
 	upgrader          Upgrader
 	taskMgr           *task.TaskManager
-	poolManager       *pool.PoolManager // 替换providers
-	activeConnections sync.Map          // 存储 clientID -> *ConnectionContext
+	poolManager       *pool.PoolManager   // 替换providers
+	activeConnections sync.Map            // 存储 clientID -> *ConnectionContext
+	sessionStore      *chat.SessionStore  // 待接续的会话快照
+	rules             *rules.Engine       // 自动化规则引擎
+	events            *EventStore         // 按设备保存的下行事件序号与未确认事件
+	llmCache          *llmcache.Cache     // 短问题的LLM回复缓存，所有连接共享
+	memories          *memory.Service     // 长期记忆，未启用时为nil
+	musicSource       music.Source        // 音乐源，未配置时为nil
+	speakers          *speaker.Service    // 声纹识别，未启用时为nil
+	recordings        *recording.Store    // 对话录音存储，未启用时为nil
+	scheduler         *schedule.Scheduler // 定时任务调度，未启用时为nil
+	images            *imagegen.Service   // 文生图，未配置时为nil
+	logger            *utils.Logger       // 根日志记录器，每个连接派生带上下文字段的记录器
 }
 
 // Upgrader WebSocket升级器接口
@@ -33,16 +57,83 @@ type Upgrader interface {
 	Upgrade(w http.ResponseWriter, r *http.Request) (Connection, error)
 }
 
+// 任务管理器默认配置
+const (
+	defaultTaskWorkers       = 12
+	defaultMaxTasksPerClient = 20
+)
+
+// useSharedTaskQueue 按配置启用多实例共享的任务队列，连接失败时只在本实例执行
+func useSharedTaskQueue(tm *task.TaskManager, cfg configs.TaskQueueConfig) {
+	switch cfg.Type {
+	case "":
+		return
+	case "redis":
+	default:
+		logrus.Errorf("不支持的共享任务队列类型: %s，只在本实例执行", cfg.Type)
+		return
+	}
+	key := cfg.Key
+	if key == "" {
+		key = "xiaozhi:tasks"
+	}
+	instanceID := cfg.InstanceID
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+	queue, err := task.NewRedisQueue(task.RedisOptions{
+		Addr:       cfg.Addr,
+		Username:   cfg.Username,
+		Password:   cfg.Password,
+		DB:         cfg.DB,
+		TLS:        cfg.TLS,
+		Key:        key,
+		InstanceID: instanceID,
+	})
+	if err != nil {
+		logrus.Errorf("初始化共享任务队列失败，只在本实例执行: %v", err)
+		return
+	}
+	tm.UseSharedQueue(queue, cfg.Consumers)
+}
+
+// newTaskResourceConfig 按 task 配置生成任务管理器的资源配置，未配置的项使用默认值
+func newTaskResourceConfig(cfg configs.TaskConfig) task.ResourceConfig {
+	rc := task.ResourceConfig{
+		MaxWorkers:           cfg.MaxWorkers,
+		MaxTasksPerClient:    cfg.MaxTasksPerClient,
+		MaxBackgroundWorkers: cfg.MaxBackgroundWorkers,
+		RatePerMinute:        cfg.RatePerMinute,
+		RateBurst:            cfg.Burst,
+	}
+	if rc.MaxWorkers <= 0 {
+		rc.MaxWorkers = defaultTaskWorkers
+	}
+	if rc.MaxTasksPerClient <= 0 {
+		rc.MaxTasksPerClient = defaultMaxTasksPerClient
+	}
+	return rc
+}
+
 // NewWebSocketServer 创建新的WebSocket服务器
-func NewWebSocketServer(config *configs.Config) (*WebSocketServer, error) {
+func NewWebSocketServer(config *configs.Config, logger *utils.Logger) (*WebSocketServer, error) {
+	if logger == nil {
+		logger = &utils.Logger{}
+	}
 	ws := &WebSocketServer{
-		config:   config,
-		upgrader: NewDefaultUpgrader(),
+		config:       config,
+		logger:       logger,
+		upgrader:     NewDefaultUpgrader(),
+		sessionStore: chat.NewSessionStore(10 * time.Minute),
+		events:       NewEventStore(),
 		taskMgr: func() *task.TaskManager {
-			tm := task.NewTaskManager(task.ResourceConfig{
-				MaxWorkers:        12,
-				MaxTasksPerClient: 20,
-			})
+			tm := task.NewTaskManager(newTaskResourceConfig(config.Task))
+			useSharedTaskQueue(tm, config.Task.Queue)
+			for taskType, seconds := range config.Task.Timeouts {
+				if seconds > 0 {
+					task.RegisterTaskTimeout(task.TaskType(taskType), time.Duration(seconds)*time.Second)
+				}
+			}
 			tm.Start()
 			return tm
 		}(),
@@ -54,6 +145,38 @@ func NewWebSocketServer(config *configs.Config) (*WebSocketServer, error) {
 		return nil, fmt.Errorf("初始化资源池管理器失败: %v", err)
 	}
 	ws.poolManager = poolManager
+	poolManager.SetConnectionCounter(ws.GetActiveConnectionsCount)
+	poolManager.SetOwnerChecker(ws.isActiveClient)
+	ws.llmCache = llmcache.New(config.LLMCache)
+	if ws.llmCache != nil && config.LLMCache.Semantic {
+		ws.llmCache.SetEmbedder(ws.embed)
+	}
+	if ws.memories, err = memory.New(config.Memory, ws.embed); err != nil {
+		logrus.Errorf("初始化长期记忆失败，不启用长期记忆: %v", err)
+	}
+	if config.Music.Type != "" {
+		if ws.musicSource, err = music.Create(config.Music.Type, &config.Music); err != nil {
+			logrus.Errorf("初始化音乐源失败，不启用音乐播放: %v", err)
+		}
+	}
+	if ws.speakers, err = speaker.New(config.Voiceprint, database.DB); err != nil {
+		logrus.Errorf("初始化声纹识别失败，不启用声纹识别: %v", err)
+	}
+	if ws.recordings, err = recording.New(config.Recording, database.DB); err != nil {
+		logrus.Errorf("初始化录音存储失败，不保存录音: %v", err)
+	}
+	ws.rules = rules.NewEngine(config.Rules, ws.taskMgr)
+	ws.rules.SetMessenger(ws)
+	if config.ImageGen.Type != "" {
+		if ws.images, err = imagegen.NewService(&config.ImageGen, ws.taskMgr, ws); err != nil {
+			logrus.Errorf("初始化文生图失败，不启用画图: %v", err)
+		}
+	}
+	if config.Schedule.Enabled {
+		if ws.scheduler, err = schedule.NewScheduler(config, database.DB, ws.taskMgr, ws); err != nil {
+			logrus.Errorf("初始化定时任务调度失败，不启用定时任务: %v", err)
+		}
+	}
 	return ws, nil
 }
 
@@ -65,6 +188,11 @@ func (ws *WebSocketServer) Start(ctx context.Context) error {
 		return fmt.Errorf("资源池管理器未初始化")
 	}
 
+	if ws.scheduler != nil {
+		ws.scheduler.Start(ctx)
+	}
+	ws.prewarmTTS(ws.config)
+
 	addr := fmt.Sprintf("%s:%d", ws.config.Server.IP, ws.config.Server.Port)
 
 	mux := http.NewServeMux()
@@ -146,6 +274,19 @@ func (ws *WebSocketServer) Stop() error {
 		if ws.poolManager != nil {
 			ws.poolManager.Close()
 		}
+		if ws.memories != nil {
+			if err := ws.memories.Close(); err != nil {
+				logrus.Errorf("关闭记忆向量库失败: %v", err)
+			}
+		}
+		if ws.speakers != nil {
+			if err := ws.speakers.Close(); err != nil {
+				logrus.Errorf("关闭声纹识别失败: %v", err)
+			}
+		}
+		if ws.recordings != nil {
+			ws.recordings.Close()
+		}
 
 		// 关闭服务器
 		if err := ws.server.Close(); err != nil {
@@ -173,8 +314,12 @@ func (ws *WebSocketServer) handleWebSocket(w http.ResponseWriter, r *http.Reques
 
 	clientID := fmt.Sprintf("%p", conn)
 
-	// 从资源池获取提供者集合
-	providerSet, err := ws.poolManager.GetProviderSet()
+	// 从资源池获取提供者集合，设备绑定的用户选择了其他ASR、LLM、TTS、VLLLM时使用对应的资源池
+	selection := userProviderSelection(r.Header.Get("Device-Id"))
+	if selection != (pool.ProviderSelection{}) {
+		logrus.Infof("客户端 %s 使用用户选择的提供者: %+v", clientID, selection)
+	}
+	providerSet, err := ws.poolManager.GetProviderSet(clientID, selection)
 	if err != nil {
 		logrus.Errorf("获取提供者集合失败: %v", err)
 		conn.Close()
@@ -182,16 +327,30 @@ func (ws *WebSocketServer) handleWebSocket(w http.ResponseWriter, r *http.Reques
 	}
 
 	connCtx, connCancel := context.WithCancel(context.Background())
-	// 创建新的连接处理器
-	// 创建临时的 utils.Logger 实例
-	tempLogger := &utils.Logger{}
-	handler := NewConnectionHandler(ws.config, providerSet, tempLogger, r, connCtx)
+	// 创建新的连接处理器，处理器内部会派生带 device_id/session_id/turn_id 的日志记录器
+	handler := NewConnectionHandler(ws.config, providerSet, ws.logger, r, connCtx)
 
-	connContext := NewConnectionContext(handler, providerSet, ws.poolManager, clientID, tempLogger, conn, connCtx, connCancel)
+	connContext := NewConnectionContext(handler, providerSet, ws.poolManager, clientID, handler.logger, conn, connCtx, connCancel)
+	connContext.selection = selection
+	handler.providerSelection = selection
 
 	// 设置TaskManager的回调（使用安全回调）
 	handler.taskMgr = ws.taskMgr
 	handler.SetTaskCallback(connContext.CreateSafeCallback())
+	handler.sessionTransferer = ws
+	handler.powerController = connContext
+	handler.rules = ws.rules
+	handler.intercom = ws
+	handler.eventStore = ws.events
+	handler.llmCache = ws.llmCache
+	handler.memories = ws.memories
+	handler.musicSource = ws.musicSource
+	handler.speakers = ws.speakers
+	handler.recordings = ws.recordings
+	handler.images = ws.images
+	if ws.scheduler != nil {
+		handler.reminders = ws.scheduler
+	}
 
 	// 存储连接上下文
 	ws.activeConnections.Store(clientID, connContext)
@@ -201,17 +360,28 @@ func (ws *WebSocketServer) handleWebSocket(w http.ResponseWriter, r *http.Reques
 	// 启动连接处理，并在结束时清理资源
 	go func() {
 		defer func() {
-			// 连接结束时清理
-			ws.activeConnections.Delete(clientID)
+			// 连接结束时清理，归还资源前先用本连接的LLM整理长期记忆。
+			// 资源归还之后再移除连接，整理期间借用者仍视为在线，资源不会被泄漏检测强制回收
+			handler.saveMemories()
 			if err := connContext.Close(); err != nil {
 				logrus.Errorf("清理连接上下文失败: %v", err)
 			}
+			ws.activeConnections.Delete(clientID)
+			if handler.deviceID != "" {
+				ws.rules.Fire(rules.Event{Type: rules.TriggerDeviceOffline, DeviceID: handler.deviceID})
+			}
 		}()
 
 		handler.Handle(conn)
 	}()
 }
 
+// isActiveClient 客户端连接是否仍然存在
+func (ws *WebSocketServer) isActiveClient(clientID string) bool {
+	_, ok := ws.activeConnections.Load(clientID)
+	return ok
+}
+
 // GetPoolStats 获取资源池统计信息（用于监控）
 func (ws *WebSocketServer) GetPoolStats() map[string]map[string]int {
 	if ws.poolManager == nil {
@@ -220,6 +390,36 @@ func (ws *WebSocketServer) GetPoolStats() map[string]map[string]int {
 	return ws.poolManager.GetDetailedStats()
 }
 
+// PoolManager 获取资源池管理器，供HTTP服务复用
+func (ws *WebSocketServer) PoolManager() *pool.PoolManager {
+	return ws.poolManager
+}
+
+// TaskManager 获取异步任务管理器，供HTTP服务复用
+func (ws *WebSocketServer) TaskManager() *task.TaskManager {
+	return ws.taskMgr
+}
+
+// Recordings 对话录音存储，未启用时返回nil
+func (ws *WebSocketServer) Recordings() *recording.Store {
+	return ws.recordings
+}
+
+// Scheduler 定时任务调度，未启用时返回nil
+func (ws *WebSocketServer) Scheduler() *schedule.Scheduler {
+	return ws.scheduler
+}
+
+// Images 文生图服务，未配置时返回nil
+func (ws *WebSocketServer) Images() *imagegen.Service {
+	return ws.images
+}
+
+// Speakers 声纹识别服务，未启用时返回nil
+func (ws *WebSocketServer) Speakers() *speaker.Service {
+	return ws.speakers
+}
+
 // GetActiveConnectionsCount 获取活跃连接数
 func (ws *WebSocketServer) GetActiveConnectionsCount() int {
 	count := 0
@@ -230,6 +430,168 @@ func (ws *WebSocketServer) GetActiveConnectionsCount() int {
 	return count
 }
 
+// TransferSession 将会话快照转移到同一用户的另一台设备
+// 快照先写入会话存储，目标设备在线时立即通知其接续，离线时在下次连接时接续
+func (ws *WebSocketServer) TransferSession(snapshot *chat.SessionSnapshot) error {
+	userID, err := ws.verifySameOwner(snapshot.FromDeviceID, snapshot.ToDeviceID)
+	if err != nil {
+		return err
+	}
+	snapshot.UserID = userID
+	ws.sessionStore.Put(snapshot)
+
+	notified := false
+	ws.activeConnections.Range(func(key, value interface{}) bool {
+		connCtx, ok := value.(*ConnectionContext)
+		if !ok || !connCtx.IsActive() || connCtx.handler == nil {
+			return true
+		}
+		if connCtx.handler.deviceID == snapshot.ToDeviceID {
+			notified = connCtx.handler.notifySessionResume()
+			return false
+		}
+		return true
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"from_device_id": snapshot.FromDeviceID,
+		"to_device_id":   snapshot.ToDeviceID,
+		"user_id":        userID,
+		"online":         notified,
+	}).Info("会话转移已登记")
+	return nil
+}
+
+// findHandler 查找指定设备的在线连接处理器
+func (ws *WebSocketServer) findHandler(deviceID string) *ConnectionHandler {
+	var handler *ConnectionHandler
+	ws.activeConnections.Range(func(key, value interface{}) bool {
+		connCtx, ok := value.(*ConnectionContext)
+		if ok && connCtx.IsActive() && connCtx.handler != nil && connCtx.handler.deviceID == deviceID {
+			handler = connCtx.handler
+			return false
+		}
+		return true
+	})
+	return handler
+}
+
+// PlayAudioOnDevice 在指定的在线设备上播放音频文件
+func (ws *WebSocketServer) PlayAudioOnDevice(deviceID string, filepath string, text string) error {
+	handler := ws.findHandler(deviceID)
+	if handler == nil {
+		return fmt.Errorf("设备不在线: %s", deviceID)
+	}
+	return handler.PlayAudioFile(filepath, text)
+}
+
+// PushToDevice 向指定的在线设备推送消息，rules.DeviceMessenger接口实现
+func (ws *WebSocketServer) PushToDevice(deviceID string, msg map[string]interface{}) error {
+	handler := ws.findHandler(deviceID)
+	if handler == nil {
+		return fmt.Errorf("设备不在线: %s", deviceID)
+	}
+	return handler.PushMessage(msg, false)
+}
+
+// OnlineDevices 所有在线设备的状态
+func (ws *WebSocketServer) OnlineDevices() []tools.Device {
+	var devices []tools.Device
+	ws.activeConnections.Range(func(key, value interface{}) bool {
+		connCtx, ok := value.(*ConnectionContext)
+		if ok && connCtx.IsActive() && connCtx.handler != nil && connCtx.handler.deviceID != "" {
+			devices = append(devices, connCtx.handler.deviceStatus())
+		}
+		return true
+	})
+	return devices
+}
+
+// DeviceStatus 指定在线设备的状态，设备不在线时返回false
+func (ws *WebSocketServer) DeviceStatus(deviceID string) (tools.Device, bool) {
+	handler := ws.findHandler(deviceID)
+	if handler == nil {
+		return tools.Device{}, false
+	}
+	return handler.deviceStatus(), true
+}
+
+// SpeakOnDevice 打断指定在线设备的当前播报，合成并播报文本
+func (ws *WebSocketServer) SpeakOnDevice(deviceID string, text string) error {
+	handler := ws.findHandler(deviceID)
+	if handler == nil {
+		return fmt.Errorf("设备不在线: %s", deviceID)
+	}
+	return handler.SpeakAnnouncement(text)
+}
+
+// RebootDevice 让指定的在线设备重启，设备重启后会请求OTA接口检查固件更新
+func (ws *WebSocketServer) RebootDevice(deviceID string) error {
+	handler := ws.findHandler(deviceID)
+	if handler == nil {
+		return fmt.Errorf("设备不在线: %s", deviceID)
+	}
+	return handler.PushMessage(map[string]interface{}{"type": "system", "command": "reboot"}, true)
+}
+
+// SwitchDeviceRole 切换指定在线设备的角色，role 为nil时恢复默认角色，roles.DeviceSwitcher接口实现
+func (ws *WebSocketServer) SwitchDeviceRole(deviceID string, role *models.Role) error {
+	handler := ws.findHandler(deviceID)
+	if handler == nil {
+		return fmt.Errorf("设备不在线: %s", deviceID)
+	}
+	handler.applyRole(role)
+	return nil
+}
+
+// SendIntercom 将对讲消息投递到目标设备，Intercom接口实现
+func (ws *WebSocketServer) SendIntercom(msg IntercomMessage) error {
+	handler := ws.findHandler(msg.ToDeviceID)
+	if handler == nil {
+		return fmt.Errorf("设备不在线: %s", msg.ToDeviceID)
+	}
+	return handler.receiveIntercom(msg, ws.config.Intercom.Revoice)
+}
+
+// TakeTransferredSession 取出转移到指定设备的会话快照
+func (ws *WebSocketServer) TakeTransferredSession(deviceID string) (*chat.SessionSnapshot, bool) {
+	return ws.sessionStore.Take(deviceID)
+}
+
+// HasTransferredSession 检查指定设备是否有待接续的会话
+func (ws *WebSocketServer) HasTransferredSession(deviceID string) bool {
+	return ws.sessionStore.Has(deviceID)
+}
+
+// verifySameOwner 校验两台设备属于同一用户，返回用户ID
+func (ws *WebSocketServer) verifySameOwner(fromDeviceID, toDeviceID string) (int64, error) {
+	if database.DB == nil {
+		return 0, fmt.Errorf("数据库未初始化，无法校验设备归属")
+	}
+
+	var devices []models.Device
+	if err := database.DB.Where("device_id IN ?", []string{fromDeviceID, toDeviceID}).Find(&devices).Error; err != nil {
+		return 0, fmt.Errorf("查询设备失败: %v", err)
+	}
+
+	owners := make(map[string]int64, len(devices))
+	for _, device := range devices {
+		owners[device.DeviceID] = device.UserID
+	}
+	fromOwner, ok := owners[fromDeviceID]
+	if !ok {
+		return 0, fmt.Errorf("设备 %s 不存在", fromDeviceID)
+	}
+	toOwner, ok := owners[toDeviceID]
+	if !ok {
+		return 0, fmt.Errorf("设备 %s 不存在", toDeviceID)
+	}
+	if fromOwner == 0 || fromOwner != toOwner {
+		return 0, fmt.Errorf("设备不属于同一用户")
+	}
+	return fromOwner, nil
+}
+
 // verifyToken 验证Authorization token
 func (ws *WebSocketServer) verifyToken(r *http.Request) bool {
 	authHeader := r.Header.Get("Authorization")
@@ -262,3 +624,21 @@ func (ws *WebSocketServer) verifyToken(r *http.Request) bool {
 	logrus.WithField("device_id", deviceID).Debug("Token验证成功")
 	return true
 }
+
+// embed 从资源池借用Embedding提供者计算单条文本的向量
+func (ws *WebSocketServer) embed(ctx context.Context, text string) ([]float32, error) {
+	provider, err := ws.poolManager.GetEmbeddingProvider()
+	if err != nil {
+		return nil, err
+	}
+	defer ws.poolManager.ReturnEmbeddingProvider(provider)
+
+	vectors, err := provider.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("计算文本向量失败: %v", err)
+	}
+	if len(vectors) == 0 {
+		return nil, fmt.Errorf("Embedding提供者未返回向量")
+	}
+	return vectors[0], nil
+}
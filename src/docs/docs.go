@@ -78,27 +78,6 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "options": {
-                "description": "处理 OTA 接口的 OPTIONS 预检请求，返回 200",
-                "consumes": [
-                    "*/*"
-                ],
-                "produces": [
-                    "text/plain"
-                ],
-                "tags": [
-                    "OTA"
-                ],
-                "summary": "OTA 预检请求",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "string"
-                        }
-                    }
-                }
             }
         },
         "/ota_bin/{filename}": {
@@ -0,0 +1,215 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/auth"
+	"xiaozhi-server-go/src/core/pool"
+)
+
+const (
+	// 单次请求最多的文本数
+	MAX_INPUTS = 256
+	// 单条文本最大字数
+	MAX_INPUT_RUNES = 8192
+
+	requestTimeout = 60 * time.Second
+)
+
+// EmbeddingRequest 向量化请求，兼容OpenAI /v1/embeddings 格式
+type EmbeddingRequest struct {
+	Input          json.RawMessage `json:"input"`           // 字符串或字符串数组
+	Model          string          `json:"model"`           // 忽略，始终使用服务端配置的模型
+	EncodingFormat string          `json:"encoding_format"` // float（默认）或 base64
+}
+
+// EmbeddingData 单条向量结果
+type EmbeddingData struct {
+	Object    string      `json:"object"`
+	Index     int         `json:"index"`
+	Embedding interface{} `json:"embedding"` // []float32，base64格式时为字符串
+}
+
+// EmbeddingResponse 向量化响应
+type EmbeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+// DefaultEmbeddingService 文本向量化服务，对外提供HTTP接口，对内供记忆检索等模块调用
+type DefaultEmbeddingService struct {
+	config      *configs.Config
+	poolManager *pool.PoolManager
+	authToken   *auth.AuthToken
+}
+
+// NewDefaultEmbeddingService 构造函数
+func NewDefaultEmbeddingService(config *configs.Config, poolManager *pool.PoolManager) (*DefaultEmbeddingService, error) {
+	if poolManager == nil {
+		return nil, fmt.Errorf("资源池管理器未初始化")
+	}
+	return &DefaultEmbeddingService{
+		config:      config,
+		poolManager: poolManager,
+		authToken:   auth.NewAuthToken(config.Server.Token),
+	}, nil
+}
+
+// Start 注册向量化相关路由
+func (s *DefaultEmbeddingService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	apiGroup.POST("/v1/embeddings", s.handleEmbeddings)
+
+	logrus.Info("向量化HTTP服务路由注册完成")
+	return nil
+}
+
+// Embed 从资源池借用向量化提供者计算文本向量，返回结果与输入顺序一致
+func (s *DefaultEmbeddingService) Embed(ctx context.Context, texts []string) ([][]float32, string, error) {
+	provider, err := s.poolManager.GetEmbeddingProvider()
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if err := s.poolManager.ReturnEmbeddingProvider(provider); err != nil {
+			logrus.WithError(err).Warn("归还Embedding提供者失败")
+		}
+	}()
+
+	vectors, err := provider.Embed(ctx, texts)
+	if err != nil {
+		return nil, "", fmt.Errorf("计算向量失败: %v", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, "", fmt.Errorf("向量数量不匹配: 期望%d, 实际%d", len(texts), len(vectors))
+	}
+	return vectors, provider.ModelName(), nil
+}
+
+// handleEmbeddings 计算一条或多条文本的向量
+func (s *DefaultEmbeddingService) handleEmbeddings(c *gin.Context) {
+	if err := s.verifyAuth(c); err != nil {
+		s.respondError(c, http.StatusUnauthorized, "authentication_error", err.Error())
+		return
+	}
+
+	var req EmbeddingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+	if req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64" {
+		s.respondError(c, http.StatusBadRequest, "invalid_request_error", "encoding_format 仅支持 float 或 base64")
+		return
+	}
+
+	texts, err := parseInput(req.Input)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), requestTimeout)
+	defer cancel()
+	vectors, model, err := s.Embed(ctx, texts)
+	if err != nil {
+		logrus.WithError(err).Error("向量化请求失败")
+		s.respondError(c, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+
+	resp := EmbeddingResponse{
+		Object: "list",
+		Data:   make([]EmbeddingData, len(vectors)),
+		Model:  model,
+	}
+	for i, vector := range vectors {
+		data := EmbeddingData{Object: "embedding", Index: i, Embedding: vector}
+		if req.EncodingFormat == "base64" {
+			data.Embedding = encodeBase64(vector)
+		}
+		resp.Data[i] = data
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// parseInput 解析字符串或字符串数组形式的输入
+func parseInput(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("缺少input参数")
+	}
+	var texts []string
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		texts = []string{single}
+	} else if err := json.Unmarshal(raw, &texts); err != nil {
+		return nil, fmt.Errorf("input 必须是字符串或字符串数组")
+	}
+
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("input 不能为空")
+	}
+	if len(texts) > MAX_INPUTS {
+		return nil, fmt.Errorf("单次最多提交%d条文本", MAX_INPUTS)
+	}
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("第%d条文本为空", i)
+		}
+		if utf8.RuneCountInString(text) > MAX_INPUT_RUNES {
+			return nil, fmt.Errorf("第%d条文本超过%d字", i, MAX_INPUT_RUNES)
+		}
+	}
+	return texts, nil
+}
+
+// encodeBase64 按OpenAI约定将向量编码为小端float32字节序列的base64
+func encodeBase64(vector []float32) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// verifyAuth 验证设备token
+func (s *DefaultEmbeddingService) verifyAuth(c *gin.Context) error {
+	authHeader := c.GetHeader("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return fmt.Errorf("无效的认证token或token已过期")
+	}
+	isValid, _, err := s.authToken.VerifyToken(authHeader[7:])
+	if err != nil || !isValid {
+		logrus.Warn(fmt.Sprintf("向量化接口认证失败: %v", err))
+		return fmt.Errorf("无效的认证token或token已过期")
+	}
+	return nil
+}
+
+// respondError 返回OpenAI格式的错误响应
+func (s *DefaultEmbeddingService) respondError(c *gin.Context, statusCode int, errType string, message string) {
+	var resp errorResponse
+	resp.Error.Message = message
+	resp.Error.Type = errType
+	c.JSON(statusCode, resp)
+}
@@ -0,0 +1,110 @@
+package feedback
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// 评价结果
+const (
+	RatingGood = "good"
+	RatingBad  = "bad"
+)
+
+// 评价来源
+const (
+	SourceDevice = "device"
+	SourceAPI    = "api"
+)
+
+// maxCommentLength 评价备注的最大字符数，与 conversation_turns.comment 的长度一致
+const maxCommentLength = 500
+
+// ErrNoTurn 没有找到可评价的对话
+var ErrNoTurn = errors.New("没有可评价的对话")
+
+// ParseRating 校验评价结果，支持 good/bad 及 up/down
+func ParseRating(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case RatingGood, "up":
+		return RatingGood, nil
+	case RatingBad, "down":
+		return RatingBad, nil
+	}
+	return "", fmt.Errorf("无效的评价: %q，应为 good 或 bad", value)
+}
+
+// RecordTurn 保存一轮对话的问答，数据库未连接时忽略
+func RecordTurn(turn models.ConversationTurn) {
+	db := database.DB
+	if db == nil {
+		return
+	}
+	go func() {
+		if err := db.Create(&turn).Error; err != nil {
+			logrus.WithError(err).Warn("保存对话记录失败")
+		}
+	}()
+}
+
+// Target 要评价的对话：指定 TurnID 时评价该轮，否则评价会话或设备最近的一轮
+// 同时指定 TurnID 和 DeviceID 时校验该轮属于此设备
+type Target struct {
+	TurnID    int64
+	SessionID string
+	DeviceID  string
+}
+
+// Rate 记录用户对一轮对话的评价，重复评价时覆盖之前的结果
+func Rate(db *gorm.DB, target Target, rating, source, comment string) (*models.ConversationTurn, error) {
+	if db == nil {
+		return nil, errors.New("数据库未连接")
+	}
+	if runes := []rune(comment); len(runes) > maxCommentLength {
+		comment = string(runes[:maxCommentLength])
+	}
+
+	tx := db.Model(&models.ConversationTurn{})
+	switch {
+	case target.TurnID > 0:
+		tx = tx.Where("id = ?", target.TurnID)
+		if target.DeviceID != "" {
+			tx = tx.Where("device_id = ?", target.DeviceID)
+		}
+	case target.SessionID != "":
+		tx = tx.Where("session_id = ?", target.SessionID).Order("id DESC")
+	case target.DeviceID != "":
+		tx = tx.Where("device_id = ?", target.DeviceID).Order("id DESC")
+	default:
+		return nil, ErrNoTurn
+	}
+
+	var turn models.ConversationTurn
+	if err := tx.Take(&turn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoTurn
+		}
+		return nil, fmt.Errorf("查询对话记录失败: %v", err)
+	}
+
+	now := time.Now()
+	err := db.Model(&turn).Updates(map[string]interface{}{
+		"rating":          rating,
+		"feedback_source": source,
+		"comment":         comment,
+		"feedback_at":     now,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("保存评价失败: %v", err)
+	}
+	turn.Rating, turn.FeedbackSource, turn.Comment, turn.FeedbackAt = rating, source, comment, &now
+	return &turn, nil
+}
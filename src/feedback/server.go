@@ -0,0 +1,101 @@
+package feedback
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultFeedbackService 对话评价服务，供配套App等通过HTTP评价助手的回复
+type DefaultFeedbackService struct {
+	config    *configs.Config
+	authToken *auth.AuthToken
+}
+
+// NewDefaultFeedbackService 构造函数
+func NewDefaultFeedbackService(config *configs.Config) (*DefaultFeedbackService, error) {
+	return &DefaultFeedbackService{
+		config:    config,
+		authToken: auth.NewAuthToken(config.Server.Token),
+	}, nil
+}
+
+// Start 注册评价路由，未开启对话评价时不开放
+func (s *DefaultFeedbackService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	if !s.config.Feedback.Enabled {
+		return nil
+	}
+	apiGroup.POST("/feedback", s.handleFeedback)
+
+	logrus.Info("对话评价HTTP服务路由注册完成")
+	return nil
+}
+
+// feedbackRequest 评价请求，turn_id 为空时评价设备最近的一轮对话
+type feedbackRequest struct {
+	TurnID  int64  `json:"turn_id"`
+	Rating  string `json:"rating"`
+	Comment string `json:"comment"`
+}
+
+// handleFeedback 评价当前设备的一轮对话
+func (s *DefaultFeedbackService) handleFeedback(c *gin.Context) {
+	deviceID, ok := s.verifyAuth(c)
+	if !ok {
+		return
+	}
+	var req feedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "请求格式错误: "+err.Error())
+		return
+	}
+	rating, err := ParseRating(req.Rating)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if database.DB == nil {
+		s.respondError(c, http.StatusServiceUnavailable, "数据库未连接")
+		return
+	}
+
+	turn, err := Rate(database.DB, Target{TurnID: req.TurnID, DeviceID: deviceID}, rating, SourceAPI, req.Comment)
+	if errors.Is(err, ErrNoTurn) {
+		s.respondError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"turn_id": turn.ID,
+		"rating":  turn.Rating,
+	})
+}
+
+// verifyAuth 校验设备令牌，返回令牌对应的设备ID
+func (s *DefaultFeedbackService) verifyAuth(c *gin.Context) (string, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		if isValid, deviceID, err := s.authToken.VerifyToken(authHeader[7:]); err == nil && isValid && deviceID != "" {
+			return deviceID, true
+		}
+	}
+	s.respondError(c, http.StatusUnauthorized, "无效的认证token或token已过期")
+	return "", false
+}
+
+// respondError 返回错误响应
+func (s *DefaultFeedbackService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{"success": false, "message": message})
+}
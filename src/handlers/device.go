@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DeviceHandler 设备与用户绑定的管理接口
+type DeviceHandler struct {
+	deviceService *service.DeviceService
+}
+
+func NewDeviceHandler(config *configs.Config) *DeviceHandler {
+	return &DeviceHandler{
+		deviceService: service.NewDevice(config),
+	}
+}
+
+// BindUserRequest 绑定设备到用户请求
+type BindUserRequest struct {
+	UserID int64 `json:"user_id"`
+}
+
+// GetUser 查询设备绑定的用户，user_id 为0表示未绑定
+func (h *DeviceHandler) GetUser(c *gin.Context) {
+	userID, err := h.deviceService.DeviceUser(c.Param("device_id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "user_id": userID})
+}
+
+// BindUser 把设备绑定到用户
+// 请求体：{"user_id":1}
+func (h *DeviceHandler) BindUser(c *gin.Context) {
+	var req BindUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "请求格式错误: " + err.Error()})
+		return
+	}
+	deviceID := c.Param("device_id")
+	if err := h.deviceService.BindUser(deviceID, req.UserID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"device_id": deviceID, "user_id": req.UserID}).Info("设备已绑定用户")
+	c.JSON(http.StatusOK, gin.H{"success": true, "user_id": req.UserID})
+}
+
+// UnbindUser 解除设备与用户的绑定
+func (h *DeviceHandler) UnbindUser(c *gin.Context) {
+	deviceID := c.Param("device_id")
+	if err := h.deviceService.UnbindUser(deviceID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	logrus.WithField("device_id", deviceID).Info("设备已解除用户绑定")
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// respondError 设备或用户不存在返回404，其他错误返回500
+func (h *DeviceHandler) respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, service.ErrDeviceNotFound) || errors.Is(err, service.ErrUserNotFound) {
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{"success": false, "message": err.Error()})
+}
@@ -0,0 +1,43 @@
+// Package imagegen 文生图：对话中用户让助手画图时，在任务管理器的后台任务中调用
+// DALL·E、Stable Diffusion WebUI 等服务生成图片，保存后把图片地址推送到设备
+package imagegen
+
+import (
+	"context"
+	"fmt"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+// Generator 文生图服务接口
+type Generator interface {
+	// Generate 按提示词生成一张图片，返回PNG或JPEG编码的图片数据
+	Generate(ctx context.Context, prompt string) ([]byte, error)
+}
+
+// GeneratorFactory 文生图服务工厂函数类型
+type GeneratorFactory func(config *configs.ImageGenConfig) (Generator, error)
+
+var (
+	factories = make(map[string]GeneratorFactory)
+)
+
+// Register 注册文生图服务工厂
+func Register(name string, factory GeneratorFactory) {
+	factories[name] = factory
+}
+
+// Create 创建文生图服务实例
+func Create(name string, config *configs.ImageGenConfig) (Generator, error) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的文生图服务: %s", name)
+	}
+
+	generator, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建文生图服务失败: %v", err)
+	}
+
+	return generator, nil
+}
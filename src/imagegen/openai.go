@@ -0,0 +1,75 @@
+package imagegen
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers/transport"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAIGenerator DALL·E 等兼容OpenAI图片接口的文生图服务
+type OpenAIGenerator struct {
+	client *openai.Client
+	model  string
+	size   string
+}
+
+func init() {
+	Register("openai", func(config *configs.ImageGenConfig) (Generator, error) {
+		return NewOpenAIGenerator(config)
+	})
+}
+
+// NewOpenAIGenerator 创建OpenAI文生图服务
+func NewOpenAIGenerator(config *configs.ImageGenConfig) (*OpenAIGenerator, error) {
+	apiKey, _ := config.Extra["api_key"].(string)
+	if apiKey == "" {
+		return nil, fmt.Errorf("缺少OpenAI API key")
+	}
+	clientConfig := openai.DefaultConfig(apiKey)
+	if baseURL, _ := config.Extra["base_url"].(string); baseURL != "" {
+		clientConfig.BaseURL = baseURL
+	}
+	httpClient, err := transport.NewHTTPClient(config.Extra, 0)
+	if err != nil {
+		return nil, err
+	}
+	clientConfig.HTTPClient = httpClient
+
+	model, _ := config.Extra["model"].(string)
+	if model == "" {
+		model = openai.CreateImageModelDallE3
+	}
+	return &OpenAIGenerator{
+		client: openai.NewClientWithConfig(clientConfig),
+		model:  model,
+		size:   config.Size,
+	}, nil
+}
+
+// Generate Generator接口实现，以base64返回图片，不依赖服务商的临时图片地址
+func (g *OpenAIGenerator) Generate(ctx context.Context, prompt string) ([]byte, error) {
+	resp, err := g.client.CreateImage(ctx, openai.ImageRequest{
+		Prompt:         prompt,
+		Model:          g.model,
+		N:              1,
+		Size:           g.size,
+		ResponseFormat: openai.CreateImageResponseFormatB64JSON,
+	})
+	if err != nil {
+		return nil, types.NewProviderError("openai", err)
+	}
+	if len(resp.Data) == 0 || resp.Data[0].B64JSON == "" {
+		return nil, fmt.Errorf("OpenAI没有返回图片")
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Data[0].B64JSON)
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,137 @@
+package imagegen
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers/transport"
+)
+
+const defaultSDSteps = 20
+
+// SDWebUIGenerator Stable Diffusion WebUI（AUTOMATIC1111、Forge等）的 txt2img 接口，启动WebUI时需带 --api 参数
+type SDWebUIGenerator struct {
+	endpoint       string
+	negativePrompt string
+	steps          int
+	width, height  int
+	client         *http.Client
+}
+
+// sdRequest txt2img 请求，只包含用到的字段
+type sdRequest struct {
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	Steps          int    `json:"steps"`
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	BatchSize      int    `json:"batch_size"`
+}
+
+// sdResponse txt2img 响应，images 为base64编码的PNG
+type sdResponse struct {
+	Images []string `json:"images"`
+}
+
+func init() {
+	Register("sdwebui", func(config *configs.ImageGenConfig) (Generator, error) {
+		return NewSDWebUIGenerator(config)
+	})
+}
+
+// NewSDWebUIGenerator 创建SD WebUI文生图服务
+func NewSDWebUIGenerator(config *configs.ImageGenConfig) (*SDWebUIGenerator, error) {
+	endpoint, _ := config.Extra["endpoint"].(string)
+	if endpoint == "" {
+		return nil, fmt.Errorf("缺少SD WebUI服务地址 endpoint")
+	}
+	width, height, err := parseSize(config.Size)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := transport.NewHTTPClient(config.Extra, 0)
+	if err != nil {
+		return nil, err
+	}
+	g := &SDWebUIGenerator{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		steps:    defaultSDSteps,
+		width:    width,
+		height:   height,
+		client:   httpClient,
+	}
+	g.negativePrompt, _ = config.Extra["negative_prompt"].(string)
+	if steps, ok := config.Extra["steps"].(int); ok && steps > 0 {
+		g.steps = steps
+	}
+	return g, nil
+}
+
+// Generate Generator接口实现
+func (g *SDWebUIGenerator) Generate(ctx context.Context, prompt string) ([]byte, error) {
+	body, err := json.Marshal(sdRequest{
+		Prompt:         prompt,
+		NegativePrompt: g.negativePrompt,
+		Steps:          g.steps,
+		Width:          g.width,
+		Height:         g.height,
+		BatchSize:      1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint+"/sdapi/v1/txt2img", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求SD WebUI失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("SD WebUI返回错误: %s %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var result sdResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析SD WebUI响应失败: %v", err)
+	}
+	if len(result.Images) == 0 {
+		return nil, fmt.Errorf("SD WebUI没有返回图片")
+	}
+	// 部分版本返回 data URL
+	encoded := result.Images[0]
+	if i := strings.Index(encoded, ","); strings.HasPrefix(encoded, "data:") && i >= 0 {
+		encoded = encoded[i+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %v", err)
+	}
+	return data, nil
+}
+
+// parseSize 解析 宽x高 格式的图片尺寸，为空时为 512x512
+func parseSize(size string) (int, int, error) {
+	if size == "" {
+		return 512, 512, nil
+	}
+	w, h, ok := strings.Cut(strings.ToLower(size), "x")
+	width, errW := strconv.Atoi(strings.TrimSpace(w))
+	height, errH := strconv.Atoi(strings.TrimSpace(h))
+	if !ok || errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("图片尺寸格式错误: %q，应为 宽x高", size)
+	}
+	return width, height, nil
+}
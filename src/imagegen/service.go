@@ -0,0 +1,273 @@
+package imagegen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/task"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// TaskTypeImageGen 文生图任务
+const TaskTypeImageGen task.TaskType = "image_gen"
+
+const (
+	defaultDir         = "data/images"
+	defaultPreviewSize = 240              // 预览图的最大边长，适配设备的小屏幕
+	generateTimeout    = 3 * time.Minute  // 单次生成的最长时间
+	retryBackoff       = 10 * time.Second // 生成失败后重试前的等待
+)
+
+// imageNamePattern 可下载的图片文件名：图片ID加可选的预览后缀
+var imageNamePattern = regexp.MustCompile(`^[0-9a-f-]{36}(_preview)?\.(png|jpg|webp)$`)
+
+func init() {
+	task.RegisterTaskExecutor(TaskTypeImageGen, func(t *task.Task) error {
+		run, ok := t.Params.(*drawRun)
+		if !ok {
+			return fmt.Errorf("invalid params for task type %v", TaskTypeImageGen)
+		}
+		img, err := run.service.generate(t.Context, run.prompt)
+		if err != nil {
+			return err
+		}
+		t.Result = img
+		return nil
+	})
+	task.RegisterTaskTimeout(TaskTypeImageGen, generateTimeout)
+}
+
+// drawRun 文生图任务参数
+type drawRun struct {
+	service *Service
+	prompt  string
+}
+
+// Image 生成并保存的图片
+type Image struct {
+	ID      string
+	Prompt  string
+	File    string // 图片文件名
+	Preview string // 预览图文件名，无法生成预览时为空
+}
+
+// Notifier 通知在线设备图片已生成
+type Notifier interface {
+	PushToDevice(deviceID string, msg map[string]interface{}) error
+	SpeakOnDevice(deviceID string, text string) error
+}
+
+// Service 文生图服务，生成任务以后台优先级在任务管理器中执行，计入设备的任务配额
+type Service struct {
+	config    *configs.ImageGenConfig
+	generator Generator
+	taskMgr   *task.TaskManager
+	notifier  Notifier
+	dir       string
+}
+
+// NewService 创建文生图服务
+func NewService(config *configs.ImageGenConfig, taskMgr *task.TaskManager, notifier Notifier) (*Service, error) {
+	if taskMgr == nil {
+		return nil, fmt.Errorf("任务管理器未初始化")
+	}
+	generator, err := Create(config.Type, config)
+	if err != nil {
+		return nil, err
+	}
+	dir := config.Dir
+	if dir == "" {
+		dir = defaultDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建图片目录失败: %v", err)
+	}
+	if config.URL == "" {
+		logrus.Warn("未配置image_gen.url，推送给设备的消息中不包含图片地址")
+	}
+	return &Service{
+		config:    config,
+		generator: generator,
+		taskMgr:   taskMgr,
+		notifier:  notifier,
+		dir:       dir,
+	}, nil
+}
+
+// Draw 提交画图任务，图片生成后推送到设备。超出设备的任务配额时返回 *task.QuotaError
+func (s *Service) Draw(deviceID, prompt string) error {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return fmt.Errorf("画图内容为空")
+	}
+	t, _ := task.NewTask(context.Background(), TaskTypeImageGen, &drawRun{service: s, prompt: prompt})
+	t.Priority = task.PriorityBackground
+	t.Retry = task.RetryPolicy{MaxAttempts: 2, Backoff: retryBackoff}
+	t.Callback = task.NewCallBack(func(result interface{}) {
+		s.notify(deviceID, prompt, result)
+	})
+	return s.taskMgr.SubmitTask(deviceID, t)
+}
+
+// generate 生成图片并保存原图和预览图
+func (s *Service) generate(ctx context.Context, prompt string) (*Image, error) {
+	data, err := s.generator.Generate(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	var ext string
+	switch http.DetectContentType(data) {
+	case "image/png":
+		ext = ".png"
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/webp":
+		ext = ".webp"
+	default:
+		return nil, task.Permanent(fmt.Errorf("不支持的图片格式: %s", http.DetectContentType(data)))
+	}
+
+	img := &Image{ID: uuid.New().String(), Prompt: prompt}
+	img.File = img.ID + ext
+	if err := os.WriteFile(filepath.Join(s.dir, img.File), data, 0644); err != nil {
+		return nil, fmt.Errorf("保存图片失败: %v", err)
+	}
+
+	preview, err := makePreview(data, s.previewSize())
+	if err != nil {
+		logrus.WithError(err).WithField("image", img.ID).Warn("生成预览图失败")
+		return img, nil
+	}
+	name := img.ID + "_preview.jpg"
+	if err := os.WriteFile(filepath.Join(s.dir, name), preview, 0644); err != nil {
+		logrus.WithError(err).WithField("image", img.ID).Warn("保存预览图失败")
+		return img, nil
+	}
+	img.Preview = name
+	return img, nil
+}
+
+func (s *Service) previewSize() int {
+	if s.config.PreviewSize > 0 {
+		return s.config.PreviewSize
+	}
+	return defaultPreviewSize
+}
+
+// notify 推送生成结果并语音提示，设备已离线时只记录日志
+func (s *Service) notify(deviceID, prompt string, result interface{}) {
+	img, ok := result.(*Image)
+	if !ok {
+		logrus.WithFields(logrus.Fields{"device": deviceID, "result": result}).Warn("画图失败")
+		s.push(deviceID, map[string]interface{}{"type": "image", "state": "failed", "prompt": prompt})
+		s.speak(deviceID, "图片没有画成功，请稍后再试")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{"device": deviceID, "image": img.ID}).Info("图片已生成")
+	msg := map[string]interface{}{
+		"type":   "image",
+		"state":  "ready",
+		"id":     img.ID,
+		"prompt": prompt,
+	}
+	if s.config.URL != "" {
+		msg["url"] = s.imageURL(img.File)
+		if img.Preview != "" {
+			msg["preview_url"] = s.imageURL(img.Preview)
+		}
+	}
+	s.push(deviceID, msg)
+	s.speak(deviceID, "你要的图画好了")
+}
+
+func (s *Service) push(deviceID string, msg map[string]interface{}) {
+	if err := s.notifier.PushToDevice(deviceID, msg); err != nil {
+		logrus.WithError(err).WithField("device", deviceID).Warn("推送图片消息失败")
+	}
+}
+
+func (s *Service) speak(deviceID, text string) {
+	if err := s.notifier.SpeakOnDevice(deviceID, text); err != nil {
+		logrus.WithError(err).WithField("device", deviceID).Debug("播报画图结果失败")
+	}
+}
+
+// imageURL 设备下载图片的地址
+func (s *Service) imageURL(name string) string {
+	return strings.TrimRight(s.config.URL, "/") + "/" + name
+}
+
+// Start 注册图片下载路由，图片ID不可猜测，下载不需要认证
+func (s *Service) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	apiGroup.GET("/images/:name", s.handleImage)
+
+	logrus.Info("文生图HTTP服务路由注册完成")
+	return nil
+}
+
+// handleImage 下载生成的图片或预览图
+func (s *Service) handleImage(c *gin.Context) {
+	name := c.Param("name")
+	if !imageNamePattern.MatchString(name) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "图片不存在"})
+		return
+	}
+	path := filepath.Join(s.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "图片不存在"})
+		return
+	}
+	c.File(path)
+}
+
+// makePreview 按最大边长缩小图片并编码为JPEG，使用最近邻采样
+func makePreview(data []byte, size int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, height*size/width
+		} else {
+			width, height = width*size/height, size
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			sx := bounds.Min.X + x*bounds.Dx()/width
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,386 @@
+package longform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/auth"
+	"xiaozhi-server-go/src/core/pool"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/task"
+)
+
+const (
+	// 单次合成的最大字数
+	MAX_TEXT_RUNES = 20000
+
+	jobTimeout   = 30 * time.Minute // 单个任务的最长执行时间
+	jobRetention = 24 * time.Hour   // 合成结果保留时长，过期后删除音频文件
+)
+
+// TaskTypeLongformTTS 长文本合成任务
+const TaskTypeLongformTTS task.TaskType = "longform_tts"
+
+func init() {
+	task.RegisterTaskExecutor(TaskTypeLongformTTS, func(t *task.Task) error {
+		run, ok := t.Params.(*jobRun)
+		if !ok {
+			return fmt.Errorf("invalid params for task type %v", TaskTypeLongformTTS)
+		}
+		return run.service.runJob(t.Context, run.jobID)
+	})
+	task.RegisterTaskTimeout(TaskTypeLongformTTS, jobTimeout)
+}
+
+// jobRun 长文本合成任务参数
+type jobRun struct {
+	service *DefaultLongformService
+	jobID   string
+}
+
+// DevicePlayer 在在线设备上播放音频
+type DevicePlayer interface {
+	PlayAudioOnDevice(deviceID string, filepath string, text string) error
+}
+
+// configGetter 读取TTS提供者当前配置，用于合成后恢复音色和语速
+type configGetter interface {
+	Config() *tts.Config
+}
+
+type speedSetter interface {
+	SetSpeed(speed float64) error
+}
+
+// DefaultLongformService 长文本合成服务，分段合成后拼接为单个音频文件
+type DefaultLongformService struct {
+	config      *configs.Config
+	poolManager *pool.PoolManager
+	taskMgr     *task.TaskManager
+	player      DevicePlayer
+	authToken   *auth.AuthToken
+	ctx         context.Context
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewDefaultLongformService 构造函数，player 为空时不支持推送到设备播放
+func NewDefaultLongformService(config *configs.Config, poolManager *pool.PoolManager, taskMgr *task.TaskManager, player DevicePlayer) (*DefaultLongformService, error) {
+	if poolManager == nil || taskMgr == nil {
+		return nil, fmt.Errorf("资源池管理器或任务管理器未初始化")
+	}
+	return &DefaultLongformService{
+		config:      config,
+		poolManager: poolManager,
+		taskMgr:     taskMgr,
+		player:      player,
+		authToken:   auth.NewAuthToken(config.Server.Token),
+		ctx:         context.Background(),
+		jobs:        make(map[string]*Job),
+	}, nil
+}
+
+// Start 注册长文本合成相关路由
+func (s *DefaultLongformService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	s.ctx = ctx
+	apiGroup.POST("/tts/longform", s.handleSubmit)
+	apiGroup.GET("/tts/longform/:id", s.handleGet)
+	apiGroup.GET("/tts/longform/:id/audio", s.handleDownload)
+
+	logrus.Info("长文本合成HTTP服务路由注册完成")
+	return nil
+}
+
+// handleSubmit 提交长文本合成任务
+func (s *DefaultLongformService) handleSubmit(c *gin.Context) {
+	deviceID, err := s.verifyAuth(c)
+	if err != nil {
+		s.respondError(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req SynthesizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		s.respondError(c, http.StatusBadRequest, "缺少待合成的文本")
+		return
+	}
+	if utf8.RuneCountInString(req.Text) > MAX_TEXT_RUNES {
+		s.respondError(c, http.StatusBadRequest, fmt.Sprintf("文本过长，最多%d字", MAX_TEXT_RUNES))
+		return
+	}
+	if req.Speed != 0 && (req.Speed < tts.MinSpeed || req.Speed > tts.MaxSpeed) {
+		s.respondError(c, http.StatusBadRequest, fmt.Sprintf("语速超出范围(%.1f-%.1f)", tts.MinSpeed, tts.MaxSpeed))
+		return
+	}
+	if req.Play && s.player == nil {
+		s.respondError(c, http.StatusBadRequest, "当前服务不支持推送到设备播放")
+		return
+	}
+
+	job := s.addJob(deviceID, req)
+
+	ctx, cancel := context.WithTimeout(s.ctx, jobTimeout)
+	t, _ := task.NewTask(ctx, TaskTypeLongformTTS, &jobRun{service: s, jobID: job.ID})
+	t.Priority = task.PriorityBackground
+	t.Callback = task.NewCallBack(func(result interface{}) {
+		cancel()
+	})
+	if err := s.taskMgr.SubmitTask(deviceID, t); err != nil {
+		cancel()
+		s.removeJob(job.ID)
+		var quotaErr *task.QuotaError
+		if errors.As(err, &quotaErr) && quotaErr.Reason == task.QuotaRateLimited {
+			c.Header("Retry-After", strconv.Itoa(quotaErr.RetryAfterSeconds()))
+		}
+		s.respondError(c, http.StatusTooManyRequests, fmt.Sprintf("提交合成任务失败: %v", err))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"job_id":    job.ID,
+		"device_id": deviceID,
+		"chunks":    job.TotalChunks,
+	}).Info("长文本合成任务已提交")
+	c.JSON(http.StatusAccepted, JobResponse{Success: true, Job: s.getJob(job.ID)})
+}
+
+// handleGet 查询长文本合成任务状态
+func (s *DefaultLongformService) handleGet(c *gin.Context) {
+	job, ok := s.authorizedJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, JobResponse{Success: true, Job: job})
+}
+
+// handleDownload 下载合成完成的音频文件
+func (s *DefaultLongformService) handleDownload(c *gin.Context) {
+	job, ok := s.authorizedJob(c)
+	if !ok {
+		return
+	}
+	if job.Status != JobStatusComplete {
+		s.respondError(c, http.StatusConflict, "合成尚未完成")
+		return
+	}
+
+	s.mu.Lock()
+	audioPath := s.jobs[job.ID].audioPath
+	s.mu.Unlock()
+	c.FileAttachment(audioPath, job.ID+".wav")
+}
+
+// authorizedJob 校验认证并取出属于当前设备的任务，失败时直接写入错误响应
+func (s *DefaultLongformService) authorizedJob(c *gin.Context) (*Job, bool) {
+	deviceID, err := s.verifyAuth(c)
+	if err != nil {
+		s.respondError(c, http.StatusUnauthorized, err.Error())
+		return nil, false
+	}
+	job := s.getJob(c.Param("id"))
+	if job == nil || job.DeviceID != deviceID {
+		s.respondError(c, http.StatusNotFound, "任务不存在或已过期")
+		return nil, false
+	}
+	return job, true
+}
+
+// runJob 申请TTS提供者，依次合成所有分段并拼接为WAV文件
+func (s *DefaultLongformService) runJob(ctx context.Context, jobID string) error {
+	s.mu.Lock()
+	job, ok := s.jobs[jobID]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("任务不存在: %s", jobID)
+	}
+	job.Status = JobStatusRunning
+	req, chunks := job.request, job.chunks
+	s.mu.Unlock()
+
+	audioPath, duration, err := s.synthesize(ctx, jobID, req, chunks)
+
+	s.mu.Lock()
+	now := time.Now()
+	job.FinishedAt = &now
+	job.chunks = nil
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = JobStatusComplete
+		job.audioPath = audioPath
+		job.Duration = duration
+		job.AudioURL = fmt.Sprintf("/api/tts/longform/%s/audio", jobID)
+	}
+	status, deviceID := job.Status, job.DeviceID
+	s.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"job_id":   jobID,
+		"status":   status,
+		"duration": duration,
+	}).Info("长文本合成任务结束")
+
+	if err == nil && req.Play {
+		if playErr := s.player.PlayAudioOnDevice(deviceID, audioPath, chunks[0]); playErr != nil {
+			logrus.WithError(playErr).WithField("job_id", jobID).Warn("推送长文本合成音频到设备失败")
+		} else {
+			s.mu.Lock()
+			job.Played = true
+			s.mu.Unlock()
+		}
+	}
+	return err
+}
+
+// synthesize 分段合成并拼接，返回音频文件路径和时长
+func (s *DefaultLongformService) synthesize(ctx context.Context, jobID string, req SynthesizeRequest, chunks []string) (string, float64, error) {
+	provider, err := s.poolManager.GetTTSProvider()
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		if err := s.poolManager.ReturnTTSProvider(provider); err != nil {
+			logrus.WithError(err).Warn("归还TTS提供者失败")
+		}
+	}()
+
+	// 临时切换音色和语速，归还前恢复
+	if getter, ok := provider.(configGetter); ok {
+		voice, speed := getter.Config().Voice, getter.Config().Speed
+		defer func() {
+			provider.SetVoice(voice)
+			if setter, ok := provider.(speedSetter); ok && speed > 0 {
+				setter.SetSpeed(speed)
+			}
+		}()
+	}
+	if req.Voice != "" {
+		if err := provider.SetVoice(req.Voice); err != nil {
+			return "", 0, fmt.Errorf("设置音色失败: %v", err)
+		}
+	}
+	if req.Speed > 0 {
+		if setter, ok := provider.(speedSetter); ok {
+			if err := setter.SetSpeed(req.Speed); err != nil {
+				return "", 0, fmt.Errorf("设置语速失败: %v", err)
+			}
+		}
+	}
+
+	var pcm []byte
+	for i, chunk := range chunks {
+		if ctx.Err() != nil {
+			return "", 0, fmt.Errorf("合成任务超时或服务关闭: %v", ctx.Err())
+		}
+		data, err := synthesizeChunk(provider, chunk)
+		if err != nil {
+			return "", 0, fmt.Errorf("第%d段%v", i+1, err)
+		}
+		if len(pcm) > 0 && len(data) > 0 {
+			pcm = append(pcm, make([]byte, chunkGapBytes)...)
+		}
+		pcm = append(pcm, data...)
+
+		s.mu.Lock()
+		s.jobs[jobID].DoneChunks = i + 1
+		s.mu.Unlock()
+	}
+	if len(pcm) == 0 {
+		return "", 0, fmt.Errorf("合成结果为空")
+	}
+
+	if err := os.MkdirAll(utils.LongformAudioDir, 0755); err != nil {
+		return "", 0, fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	audioPath := filepath.Join(utils.LongformAudioDir, jobID+".wav")
+	if err := utils.SaveAudioToWavFile(pcm, audioPath, outputSampleRate, 1, 16); err != nil {
+		return "", 0, fmt.Errorf("保存音频文件失败: %v", err)
+	}
+	return audioPath, float64(len(pcm)) / 2 / outputSampleRate, nil
+}
+
+// addJob 创建任务记录，同时清理过期任务及其音频文件
+func (s *DefaultLongformService) addJob(deviceID string, req SynthesizeRequest) *Job {
+	chunks := splitText(req.Text)
+	job := &Job{
+		ID:          uuid.New().String(),
+		Status:      JobStatusPending,
+		DeviceID:    deviceID,
+		TotalChunks: len(chunks),
+		CreatedAt:   time.Now(),
+		request:     req,
+		chunks:      chunks,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for jobID, j := range s.jobs {
+		if j.FinishedAt != nil && time.Since(*j.FinishedAt) > jobRetention {
+			if j.audioPath != "" {
+				os.Remove(j.audioPath)
+			}
+			delete(s.jobs, jobID)
+		}
+	}
+	s.jobs[job.ID] = job
+	return job
+}
+
+func (s *DefaultLongformService) removeJob(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, jobID)
+}
+
+// getJob 返回任务的副本，避免与合成协程并发读写
+func (s *DefaultLongformService) getJob(jobID string) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil
+	}
+	copied := *job
+	copied.chunks = nil
+	return &copied
+}
+
+// verifyAuth 验证设备认证token，返回token对应的设备ID
+func (s *DefaultLongformService) verifyAuth(c *gin.Context) (string, error) {
+	authHeader := c.GetHeader("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return "", fmt.Errorf("无效的认证token或token已过期")
+	}
+	isValid, deviceID, err := s.authToken.VerifyToken(authHeader[7:])
+	if err != nil || !isValid {
+		logrus.Warn(fmt.Sprintf("长文本合成认证失败: %v", err))
+		return "", fmt.Errorf("无效的认证token或token已过期")
+	}
+	return deviceID, nil
+}
+
+// respondError 返回错误响应
+func (s *DefaultLongformService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, JobResponse{Success: false, Message: message})
+}
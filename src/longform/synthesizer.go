@@ -0,0 +1,86 @@
+package longform
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	maxChunkRunes    = 150   // 单次合成的最大字数
+	outputSampleRate = 24000 // 拼接后的音频采样率，与设备默认的下发采样率一致，协商了其他采样率的设备在下发时重采样
+	chunkGapBytes    = 9600  // 分段之间插入200ms静音，避免拼接处过于紧凑
+)
+
+// sentenceEnds 分段时优先断开的标点
+const sentenceEnds = "。！？!?；;\n"
+
+// splitText 按句子切分长文本，合并为不超过 maxChunkRunes 的分段，超长的单句按字数硬切
+func splitText(text string) []string {
+	var sentences []string
+	var current strings.Builder
+	for _, r := range text {
+		current.WriteRune(r)
+		if strings.ContainsRune(sentenceEnds, r) {
+			sentences = append(sentences, current.String())
+			current.Reset()
+		}
+	}
+	if current.Len() > 0 {
+		sentences = append(sentences, current.String())
+	}
+
+	var chunks []string
+	var chunk strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(chunk.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		chunk.Reset()
+	}
+	for _, sentence := range sentences {
+		if utf8.RuneCountInString(chunk.String())+utf8.RuneCountInString(sentence) > maxChunkRunes {
+			flush()
+		}
+		for utf8.RuneCountInString(sentence) > maxChunkRunes {
+			runes := []rune(sentence)
+			chunk.WriteString(string(runes[:maxChunkRunes]))
+			flush()
+			sentence = string(runes[maxChunkRunes:])
+		}
+		chunk.WriteString(sentence)
+	}
+	flush()
+	return chunks
+}
+
+// synthesizeChunk 合成一个分段并解码为PCM，合成的临时文件随即删除
+func synthesizeChunk(provider providers.TTSProvider, text string) ([]byte, error) {
+	text = tts.StripProsodyMarkup(utils.RemoveMarkdownSyntax(text))
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	path, err := provider.ToTTS(text)
+	if err != nil {
+		return nil, fmt.Errorf("合成失败: %v", err)
+	}
+	defer os.Remove(path)
+
+	if strings.HasSuffix(path, ".mp3") {
+		// MP3 解码后统一重采样为 outputSampleRate
+		pcm, _, err := utils.AudioToPCMData(path, outputSampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("解码音频失败: %v", err)
+		}
+		if len(pcm) == 0 {
+			return nil, nil
+		}
+		return pcm[0], nil
+	}
+	return utils.ReadPCMDataFromWavFile(path)
+}
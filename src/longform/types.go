@@ -0,0 +1,47 @@
+package longform
+
+import "time"
+
+// JobStatus 长文本合成任务状态
+type JobStatus string
+
+const (
+	JobStatusPending  JobStatus = "pending"
+	JobStatusRunning  JobStatus = "running"
+	JobStatusComplete JobStatus = "complete"
+	JobStatusFailed   JobStatus = "failed"
+)
+
+// SynthesizeRequest 长文本合成请求
+type SynthesizeRequest struct {
+	Text  string  `json:"text"`           // 待合成的长文本
+	Voice string  `json:"voice"`          // 可选，音色
+	Speed float64 `json:"speed"`          // 可选，语速倍率(0.5-2.0)
+	Play  bool    `json:"play_on_device"` // 合成完成后在当前设备上播放
+}
+
+// Job 长文本合成任务
+type Job struct {
+	ID          string     `json:"id"`
+	Status      JobStatus  `json:"status"`
+	DeviceID    string     `json:"device_id"`
+	TotalChunks int        `json:"total_chunks"`
+	DoneChunks  int        `json:"done_chunks"`
+	Duration    float64    `json:"duration,omitempty"`  // 音频时长（秒）
+	AudioURL    string     `json:"audio_url,omitempty"` // 完成后的下载地址
+	Played      bool       `json:"played,omitempty"`    // 是否已推送到设备播放
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+
+	request   SynthesizeRequest
+	chunks    []string
+	audioPath string
+}
+
+// JobResponse 长文本合成接口标准响应结构
+type JobResponse struct {
+	Success bool   `json:"success"`
+	Job     *Job   `json:"job,omitempty"`
+	Message string `json:"message,omitempty"`
+}
@@ -17,28 +17,67 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"xiaozhi-server-go/src/announce"
+	"xiaozhi-server-go/src/backup"
 	"xiaozhi-server-go/src/configs"
 	"xiaozhi-server-go/src/configs/database"
 	cfg "xiaozhi-server-go/src/configs/server"
 	"xiaozhi-server-go/src/core"
 	"xiaozhi-server-go/src/core/utils"
 	_ "xiaozhi-server-go/src/docs"
+	"xiaozhi-server-go/src/embeddings"
+	"xiaozhi-server-go/src/feedback"
+	"xiaozhi-server-go/src/longform"
+	"xiaozhi-server-go/src/maintenance"
+	"xiaozhi-server-go/src/mcpserver"
+	"xiaozhi-server-go/src/metrics"
+	"xiaozhi-server-go/src/recordings"
+	"xiaozhi-server-go/src/roles"
+	"xiaozhi-server-go/src/sandbox"
+	"xiaozhi-server-go/src/taskadmin"
+	"xiaozhi-server-go/src/transcribe"
+	"xiaozhi-server-go/src/usage"
 	"xiaozhi-server-go/src/vision"
+	"xiaozhi-server-go/src/voiceprint"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	// 导入所有providers以确保init函数被调用
+	_ "xiaozhi-server-go/src/core/memory/qdrant"
+	_ "xiaozhi-server-go/src/core/memory/sqlitevec"
 	_ "xiaozhi-server-go/src/core/providers/asr/doubao"
 	_ "xiaozhi-server-go/src/core/providers/asr/gosherpa"
+	_ "xiaozhi-server-go/src/core/providers/embedding/dashscope"
+	_ "xiaozhi-server-go/src/core/providers/embedding/local"
+	_ "xiaozhi-server-go/src/core/providers/embedding/openai"
+	_ "xiaozhi-server-go/src/core/providers/llm/anthropic"
+	_ "xiaozhi-server-go/src/core/providers/llm/azure_openai"
 	_ "xiaozhi-server-go/src/core/providers/llm/coze"
+	_ "xiaozhi-server-go/src/core/providers/llm/dashscope"
+	_ "xiaozhi-server-go/src/core/providers/llm/deepseek"
+	_ "xiaozhi-server-go/src/core/providers/llm/dify"
+	_ "xiaozhi-server-go/src/core/providers/llm/gateway"
+	_ "xiaozhi-server-go/src/core/providers/llm/gemini"
+	_ "xiaozhi-server-go/src/core/providers/llm/llamacpp"
 	_ "xiaozhi-server-go/src/core/providers/llm/ollama"
 	_ "xiaozhi-server-go/src/core/providers/llm/openai"
+	_ "xiaozhi-server-go/src/core/providers/llm/zhipu"
 	_ "xiaozhi-server-go/src/core/providers/tts/doubao"
 	_ "xiaozhi-server-go/src/core/providers/tts/edge"
+	_ "xiaozhi-server-go/src/core/providers/tts/google"
 	_ "xiaozhi-server-go/src/core/providers/tts/gosherpa"
+	_ "xiaozhi-server-go/src/core/providers/vad/silero"
+	_ "xiaozhi-server-go/src/core/providers/vad/webrtc"
+	_ "xiaozhi-server-go/src/core/providers/vlllm/anthropic"
+	_ "xiaozhi-server-go/src/core/providers/vlllm/dashscope"
+	_ "xiaozhi-server-go/src/core/providers/vlllm/gemini"
 	_ "xiaozhi-server-go/src/core/providers/vlllm/ollama"
 	_ "xiaozhi-server-go/src/core/providers/vlllm/openai"
+	_ "xiaozhi-server-go/src/core/providers/wakeword/openwakeword"
+	_ "xiaozhi-server-go/src/core/providers/wakeword/sherpa"
+	_ "xiaozhi-server-go/src/core/speaker/rest"
+	_ "xiaozhi-server-go/src/core/speaker/sherpa"
 
 	apiRouter "xiaozhi-server-go/src/router"
 
@@ -47,27 +86,27 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-func LoadConfigAndLogger() (*configs.Config, error) {
+func LoadConfigAndLogger() (*configs.Config, *utils.Logger, error) {
 	// 加载配置,默认使用.config.yaml
 	config, configPath, err := configs.LoadConfig()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// 初始化日志系统
-	_, err = utils.NewLogger(config)
+	logger, err := utils.NewLogger(config)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// 使用logrus记录
 	logrus.Infof("日志系统初始化成功, 配置文件路径: %s", configPath)
 
-	return config, nil
+	return config, logger, nil
 }
 
-func StartWSServer(config *configs.Config, g *errgroup.Group, groupCtx context.Context) (*core.WebSocketServer, error) {
+func StartWSServer(config *configs.Config, logger *utils.Logger, g *errgroup.Group, groupCtx context.Context) (*core.WebSocketServer, error) {
 	// 创建 WebSocket 服务
-	wsServer, err := core.NewWebSocketServer(config)
+	wsServer, err := core.NewWebSocketServer(config, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -99,7 +138,7 @@ func StartWSServer(config *configs.Config, g *errgroup.Group, groupCtx context.C
 	return wsServer, nil
 }
 
-func StartHttpServer(config *configs.Config, g *errgroup.Group, groupCtx context.Context) error {
+func StartHttpServer(config *configs.Config, wsServer *core.WebSocketServer, g *errgroup.Group, groupCtx context.Context) error {
 	// 初始化Gin引擎
 	if config.Log.LogLevel == "debug" {
 		gin.SetMode(gin.DebugMode)
@@ -112,12 +151,16 @@ func StartHttpServer(config *configs.Config, g *errgroup.Group, groupCtx context
 		logrus.Errorf("设置受信任代理失败: %v", err)
 		return err
 	}
+	// 全局中间件需在注册路由前挂载，OPTIONS预检统一由CORS中间件应答
+	router.Use(apiRouter.SecurityHeadersMiddleware(config.Web.Security))
+	router.Use(apiRouter.CORSMiddleware(config.Web.CORS))
 
 	// API路由全部挂载到/api前缀下
 	apiGroup := router.Group("/api")
 
 	apiRouter.OtaRouter(groupCtx, apiGroup, router, config)
 	apiRouter.ActiveRouter(groupCtx, apiGroup, config)
+	apiRouter.DeviceRouter(groupCtx, apiGroup, config)
 
 	// 启动Vision服务
 	visionService, err := vision.NewDefaultVisionService(config)
@@ -130,7 +173,180 @@ func StartHttpServer(config *configs.Config, g *errgroup.Group, groupCtx context
 		return err
 	}
 
-	cfgServer, err := cfg.NewDefaultCfgService(config, nil)
+	// 启动批量离线识别服务，复用WebSocket服务的ASR资源池和任务管理器
+	transcribeService, err := transcribe.NewDefaultTranscribeService(config, wsServer.PoolManager(), wsServer.TaskManager())
+	if err != nil {
+		logrus.Error("批量识别服务初始化失败", err)
+		return err
+	}
+	if err := transcribeService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("批量识别服务启动失败", err)
+		return err
+	}
+
+	// 启动长文本合成服务，复用WebSocket服务的TTS资源池，合成结果可推送到在线设备播放
+	longformService, err := longform.NewDefaultLongformService(config, wsServer.PoolManager(), wsServer.TaskManager(), wsServer)
+	if err != nil {
+		logrus.Error("长文本合成服务初始化失败", err)
+		return err
+	}
+	if err := longformService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("长文本合成服务启动失败", err)
+		return err
+	}
+
+	// 启动文生图图片下载服务，未配置文生图时不注册
+	if images := wsServer.Images(); images != nil {
+		if err := images.Start(groupCtx, router, apiGroup); err != nil {
+			logrus.Error("文生图服务启动失败", err)
+			return err
+		}
+	}
+
+	// 启动任务管理服务，查看和取消WebSocket服务任务管理器中的任务
+	taskAdminService, err := taskadmin.NewDefaultTaskAdminService(config, wsServer.TaskManager())
+	if err != nil {
+		logrus.Error("任务管理服务初始化失败", err)
+		return err
+	}
+	if err := taskAdminService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("任务管理服务启动失败", err)
+		return err
+	}
+
+	// 启动向量化服务，使用WebSocket服务的Embedding资源池
+	embeddingService, err := embeddings.NewDefaultEmbeddingService(config, wsServer.PoolManager())
+	if err != nil {
+		logrus.Error("向量化服务初始化失败", err)
+		return err
+	}
+	if err := embeddingService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("向量化服务启动失败", err)
+		return err
+	}
+
+	// 启动会话回放服务
+	sandboxService, err := sandbox.NewDefaultSandboxService(config)
+	if err != nil {
+		logrus.Error("回放服务初始化失败", err)
+		return err
+	}
+	if err := sandboxService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("回放服务启动失败", err)
+		return err
+	}
+
+	// 启动token用量查询服务
+	usageService, err := usage.NewDefaultUsageService(config)
+	if err != nil {
+		logrus.Error("用量查询服务初始化失败", err)
+		return err
+	}
+	if err := usageService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("用量查询服务启动失败", err)
+		return err
+	}
+
+	// 启动对话评价服务
+	feedbackService, err := feedback.NewDefaultFeedbackService(config)
+	if err != nil {
+		logrus.Error("对话评价服务初始化失败", err)
+		return err
+	}
+	if err := feedbackService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("对话评价服务启动失败", err)
+		return err
+	}
+
+	// 启动健康检查与维护模式服务
+	maintenanceService, err := maintenance.NewDefaultMaintenanceService(config)
+	if err != nil {
+		logrus.Error("维护模式服务初始化失败", err)
+		return err
+	}
+	if err := maintenanceService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("维护模式服务启动失败", err)
+		return err
+	}
+
+	// 启动MCP服务端点，通过WebSocket服务控制在线设备
+	mcpServerService, err := mcpserver.NewDefaultMCPServerService(config, wsServer)
+	if err != nil {
+		logrus.Error("MCP服务端点初始化失败", err)
+		return err
+	}
+	if err := mcpServerService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("MCP服务端点启动失败", err)
+		return err
+	}
+
+	// 启动角色管理服务，切换在线设备的角色时通过WebSocket服务生效
+	roleService, err := roles.NewDefaultRoleService(config, wsServer)
+	if err != nil {
+		logrus.Error("角色管理服务初始化失败", err)
+		return err
+	}
+	if err := roleService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("角色管理服务启动失败", err)
+		return err
+	}
+
+	// 启动声纹登记服务，与WebSocket服务共用声纹识别
+	voiceprintService, err := voiceprint.NewDefaultVoiceprintService(config, wsServer.Speakers())
+	if err != nil {
+		logrus.Error("声纹登记服务初始化失败", err)
+		return err
+	}
+	if err := voiceprintService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("声纹登记服务启动失败", err)
+		return err
+	}
+
+	// 启动主动播报服务，通过WebSocket服务让在线设备播报
+	speakService, err := announce.NewDefaultSpeakService(config, wsServer)
+	if err != nil {
+		logrus.Error("主动播报服务初始化失败", err)
+		return err
+	}
+	if err := speakService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("主动播报服务启动失败", err)
+		return err
+	}
+
+	// 启动录音查询服务，与WebSocket服务共用录音存储
+	recordingService, err := recordings.NewDefaultRecordingService(config, wsServer.Recordings())
+	if err != nil {
+		logrus.Error("录音查询服务初始化失败", err)
+		return err
+	}
+	if err := recordingService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("录音查询服务启动失败", err)
+		return err
+	}
+
+	// 启动定时备份，复用WebSocket服务的任务管理器
+	if config.Backup.Enabled {
+		backupScheduler, err := backup.NewScheduler(config, database.DB, wsServer.TaskManager())
+		if err != nil {
+			logrus.Error("定时备份初始化失败", err)
+			return err
+		}
+		backupScheduler.Start(groupCtx)
+	}
+
+	// 启动监控指标服务，导出WebSocket服务的资源池统计
+	metricsService, err := metrics.NewDefaultMetricsService(config, wsServer)
+	if err != nil {
+		logrus.Error("监控指标服务初始化失败", err)
+		return err
+	}
+	if err := metricsService.Start(groupCtx, router, apiGroup); err != nil {
+		logrus.Error("监控指标服务启动失败", err)
+		return err
+	}
+
+	// 配置服务，重载配置时重建WebSocket服务的资源池
+	cfgServer, err := cfg.NewDefaultCfgService(config, wsServer)
 	if err != nil {
 		logrus.Error("配置服务初始化失败", err)
 		return err
@@ -148,6 +364,8 @@ func StartHttpServer(config *configs.Config, g *errgroup.Group, groupCtx context
 
 	// 注册Swagger文档路由
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// WebSocket协议文档，由 src/protocol/protocol.yaml 生成
+	router.GET("/swagger-protocol/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.InstanceName("protocol")))
 
 	g.Go(func() error {
 		logrus.Info(fmt.Sprintf("Gin 服务已启动，访问地址: http://0.0.0.0:%d", config.Web.Port))
@@ -211,14 +429,18 @@ func GracefulShutdown(cancel context.CancelFunc, g *errgroup.Group) {
 	}
 }
 
-func startServices(config *configs.Config, g *errgroup.Group, groupCtx context.Context) error {
+func startServices(config *configs.Config, logger *utils.Logger, g *errgroup.Group, groupCtx context.Context) error {
+	// 在接受连接前设置维护状态，配置为维护模式时第一个会话就能收到公告
+	maintenance.Init(config.Maintenance)
+
 	// 启动 WebSocket 服务
-	if _, err := StartWSServer(config, g, groupCtx); err != nil {
+	wsServer, err := StartWSServer(config, logger, g, groupCtx)
+	if err != nil {
 		return fmt.Errorf("启动 WebSocket 服务失败: %w", err)
 	}
 
 	// 启动 Http 服务
-	if err := StartHttpServer(config, g, groupCtx); err != nil {
+	if err := StartHttpServer(config, wsServer, g, groupCtx); err != nil {
 		return fmt.Errorf("启动 Http 服务失败: %w", err)
 	}
 
@@ -227,7 +449,7 @@ func startServices(config *configs.Config, g *errgroup.Group, groupCtx context.C
 
 func main() {
 	// 加载配置和初始化日志系统
-	config, err := LoadConfigAndLogger()
+	config, logger, err := LoadConfigAndLogger()
 	if err != nil {
 		fmt.Println("加载配置或初始化日志系统失败:", err)
 		os.Exit(1)
@@ -247,6 +469,15 @@ func main() {
 		return
 	}
 
+	// restore 子命令：从备份恢复数据库和配置后退出
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		if err := backup.RunRestoreCommand(config, db, os.Args[2:]); err != nil {
+			fmt.Println("恢复失败:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 创建可取消的上下文
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -255,7 +486,7 @@ func main() {
 	g, groupCtx := errgroup.WithContext(ctx)
 
 	// 启动所有服务
-	if err := startServices(config, g, groupCtx); err != nil {
+	if err := startServices(config, logger, g, groupCtx); err != nil {
 		logrus.Error("启动服务失败:", err)
 		cancel()
 		os.Exit(1)
@@ -0,0 +1,88 @@
+package maintenance
+
+import (
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMessage    = "服务器正在维护升级，请稍后再试"
+	defaultRetryAfter = 600
+)
+
+// State 维护模式状态
+type State struct {
+	Enabled bool      `json:"enabled"`
+	Message string    `json:"message"` // 新会话播报的维护公告
+	Since   time.Time `json:"since"`   // 进入维护模式的时间，未维护时为零值
+}
+
+var (
+	mu         sync.RWMutex
+	state      State
+	retryAfter = defaultRetryAfter
+)
+
+// Init 按配置设置启动时的维护状态
+func Init(config configs.MaintenanceConfig) {
+	mu.Lock()
+	if config.RetryAfter > 0 {
+		retryAfter = config.RetryAfter
+	}
+	mu.Unlock()
+	if config.Enabled {
+		Enable(config.Message)
+	}
+}
+
+// Enable 进入维护模式，已在维护中时只更新公告
+func Enable(message string) State {
+	if message == "" {
+		message = defaultMessage
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !state.Enabled {
+		state.Since = time.Now()
+	}
+	state.Enabled = true
+	state.Message = message
+	logrus.WithField("message", message).Warn("已进入维护模式")
+	return state
+}
+
+// Disable 退出维护模式
+func Disable() State {
+	mu.Lock()
+	defer mu.Unlock()
+	if state.Enabled {
+		logrus.WithField("duration", time.Since(state.Since)).Info("已退出维护模式")
+	}
+	state = State{}
+	return state
+}
+
+// Current 当前维护状态
+func Current() State {
+	mu.RLock()
+	defer mu.RUnlock()
+	return state
+}
+
+// Active 是否处于维护模式
+func Active() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return state.Enabled
+}
+
+// RetryAfter 拒绝请求时建议客户端重试的间隔（秒）
+func RetryAfter() int {
+	mu.RLock()
+	defer mu.RUnlock()
+	return retryAfter
+}
@@ -0,0 +1,88 @@
+package maintenance
+
+import (
+	"context"
+	"net/http"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/router/admin"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMaintenanceService 维护模式服务，供管理员在运行时切换维护模式
+type DefaultMaintenanceService struct {
+	config *configs.Config
+}
+
+// NewDefaultMaintenanceService 构造函数
+func NewDefaultMaintenanceService(config *configs.Config) (*DefaultMaintenanceService, error) {
+	return &DefaultMaintenanceService{config: config}, nil
+}
+
+// Start 注册健康检查和维护模式路由，未配置管理员令牌时只开放健康检查
+func (s *DefaultMaintenanceService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	// 健康检查不受维护模式影响，避免负载均衡在维护期间摘除节点、断开已建立的会话
+	apiGroup.GET("/health", s.handleHealth)
+
+	if !admin.Enabled(s.config) {
+		logrus.Info("未配置web.admin_token，维护模式切换接口未开放")
+		return nil
+	}
+	group := admin.Group(apiGroup, s.config)
+	group.GET("/maintenance", s.handleGet)
+	group.POST("/maintenance", s.handleSet)
+
+	logrus.Info("维护模式HTTP服务路由注册完成")
+	return nil
+}
+
+// handleHealth 健康检查，维护期间同样返回200
+func (s *DefaultMaintenanceService) handleHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "ok",
+		"maintenance": Active(),
+	})
+}
+
+// handleGet 查询维护状态
+func (s *DefaultMaintenanceService) handleGet(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "state": Current()})
+}
+
+// setRequest 切换维护模式请求，message 为空时使用配置中的公告
+type setRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Message string `json:"message"`
+}
+
+// handleSet 进入或退出维护模式
+func (s *DefaultMaintenanceService) handleSet(c *gin.Context) {
+	var req setRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "请求格式错误: "+err.Error())
+		return
+	}
+	if req.Enabled == nil {
+		s.respondError(c, http.StatusBadRequest, "缺少 enabled 参数")
+		return
+	}
+
+	var state State
+	if *req.Enabled {
+		message := req.Message
+		if message == "" {
+			message = s.config.Maintenance.Message
+		}
+		state = Enable(message)
+	} else {
+		state = Disable()
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "state": state})
+}
+
+// respondError 返回错误响应
+func (s *DefaultMaintenanceService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{"success": false, "message": message})
+}
@@ -0,0 +1,149 @@
+// Package mcpserver 把服务端自身的能力（让设备播报、查询设备状态、触发OTA）以MCP服务的形式开放，
+// 桌面智能体等MCP客户端通过 WebSocket 连接 /api/mcp 后即可用工具调用控制设备
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/tools"
+	"xiaozhi-server-go/src/router/admin"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	serverName    = "xiaozhi-server"
+	serverVersion = "1.0.0"
+
+	pingInterval = 30 * time.Second
+	pongWait     = 60 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+// DeviceController 控制在线设备，由WebSocket服务实现
+type DeviceController interface {
+	OnlineDevices() []tools.Device
+	DeviceStatus(deviceID string) (tools.Device, bool)
+	SpeakOnDevice(deviceID string, text string) error
+	RebootDevice(deviceID string) error
+}
+
+// DefaultMCPServerService MCP服务端点
+type DefaultMCPServerService struct {
+	config     *configs.Config
+	controller DeviceController
+	mcp        *server.MCPServer
+	upgrader   websocket.Upgrader
+}
+
+// NewDefaultMCPServerService 构造函数
+func NewDefaultMCPServerService(config *configs.Config, controller DeviceController) (*DefaultMCPServerService, error) {
+	s := &DefaultMCPServerService{
+		config:     config,
+		controller: controller,
+		mcp:        server.NewMCPServer(serverName, serverVersion, server.WithToolCapabilities(false)),
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{"mcp"},
+			CheckOrigin: func(r *http.Request) bool {
+				return true // 通过令牌鉴权，允许所有来源
+			},
+		},
+	}
+	s.registerTools()
+	return s, nil
+}
+
+// Start 注册MCP端点路由，未开启或未配置管理员令牌时不开放
+func (s *DefaultMCPServerService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	if !s.config.MCPServer.Enabled {
+		logrus.Info("未开启mcp_server，MCP服务端点未开放")
+		return nil
+	}
+	if !admin.Enabled(s.config) {
+		logrus.Info("未配置web.admin_token，MCP服务端点未开放")
+		return nil
+	}
+	apiGroup.GET("/mcp", admin.QueryMiddleware(s.config.Web.AdminToken), func(c *gin.Context) {
+		s.handleWebSocket(ctx, c)
+	})
+
+	logrus.Info("MCP服务端点路由注册完成")
+	return nil
+}
+
+// handleWebSocket 升级为WebSocket连接，每条文本消息是一个JSON-RPC请求或通知
+func (s *DefaultMCPServerService) handleWebSocket(ctx context.Context, c *gin.Context) {
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logrus.Errorf("MCP客户端WebSocket升级失败: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	remote := c.ClientIP()
+	logrus.WithField("remote", remote).Info("MCP客户端已连接")
+	defer logrus.WithField("remote", remote).Info("MCP客户端已断开")
+
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// 请求可能耗时，并发处理，响应交给写协程串行写入
+	writes := make(chan interface{}, 16)
+	go s.writeLoop(connCtx, conn, writes)
+
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logrus.WithError(err).Warn("读取MCP客户端消息失败")
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		go func(message json.RawMessage) {
+			if response := s.mcp.HandleMessage(connCtx, message); response != nil {
+				select {
+				case writes <- response:
+				case <-connCtx.Done():
+				}
+			}
+		}(data)
+	}
+}
+
+// writeLoop 串行写入响应并定时发送ping保活
+func (s *DefaultMCPServerService) writeLoop(ctx context.Context, conn *websocket.Conn, writes <-chan interface{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case response := <-writes:
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteJSON(response); err != nil {
+				logrus.WithError(err).Warn("发送MCP响应失败")
+				conn.Close()
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/tools"
+	"xiaozhi-server-go/src/models"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"gorm.io/gorm"
+)
+
+// maxSpeakRunes 单次播报的最大字数
+const maxSpeakRunes = 500
+
+// deviceStatus 返回给MCP客户端的设备状态
+type deviceStatus struct {
+	DeviceID    string            `json:"device_id"`
+	Online      bool              `json:"online"`
+	ConnectedAt *time.Time        `json:"connected_at,omitempty"`
+	ListenMode  string            `json:"listen_mode,omitempty"`
+	Info        map[string]string `json:"info,omitempty"` // 设备上报的电量、位置等
+	Activated   *bool             `json:"activated,omitempty"`
+	LastSeen    *time.Time        `json:"last_seen,omitempty"`
+}
+
+// registerTools 注册对外开放的工具
+func (s *DefaultMCPServerService) registerTools() {
+	s.mcp.AddTool(mcp.NewTool("list_devices",
+		mcp.WithDescription("列出当前在线的小智设备及其状态"),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.listDevices)
+
+	s.mcp.AddTool(mcp.NewTool("get_device_status",
+		mcp.WithDescription("查询设备状态：是否在线、连接时间、电量、位置、最后在线时间"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("device_id", mcp.Required(), mcp.Description("设备ID（MAC地址）")),
+	), s.getDeviceStatus)
+
+	s.mcp.AddTool(mcp.NewTool("speak",
+		mcp.WithDescription("让在线设备打断当前播报并朗读一段文本"),
+		mcp.WithString("device_id", mcp.Required(), mcp.Description("设备ID（MAC地址）")),
+		mcp.WithString("text", mcp.Required(), mcp.Description(fmt.Sprintf("要朗读的文本，最多%d字", maxSpeakRunes))),
+	), s.speak)
+
+	s.mcp.AddTool(mcp.NewTool("trigger_ota",
+		mcp.WithDescription("让在线设备重启并检查固件更新，有新固件时设备会自动升级"),
+		mcp.WithString("device_id", mcp.Required(), mcp.Description("设备ID（MAC地址）")),
+	), s.triggerOTA)
+}
+
+func (s *DefaultMCPServerService) listDevices(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	devices := s.controller.OnlineDevices()
+	statuses := make([]deviceStatus, 0, len(devices))
+	for _, device := range devices {
+		statuses = append(statuses, onlineStatus(device))
+	}
+	return jsonResult(statuses)
+}
+
+func (s *DefaultMCPServerService) getDeviceStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	deviceID, err := request.RequireString("device_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	status := deviceStatus{DeviceID: deviceID}
+	if device, ok := s.controller.DeviceStatus(deviceID); ok {
+		status = onlineStatus(device)
+	}
+	if database.DB != nil {
+		var device models.Device
+		err := database.DB.Where("device_id = ?", deviceID).Take(&device).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return mcp.NewToolResultErrorFromErr("查询设备失败", err), nil
+		}
+		if err == nil {
+			status.Activated = &device.Activated
+			status.LastSeen = &device.LastSeen
+		} else if !status.Online {
+			return mcp.NewToolResultError(fmt.Sprintf("设备 %s 不存在", deviceID)), nil
+		}
+	}
+	return jsonResult(status)
+}
+
+func (s *DefaultMCPServerService) speak(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	deviceID, err := request.RequireString("device_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	text := strings.TrimSpace(request.GetString("text", ""))
+	if text == "" {
+		return mcp.NewToolResultError("缺少要朗读的文本"), nil
+	}
+	if len([]rune(text)) > maxSpeakRunes {
+		return mcp.NewToolResultError(fmt.Sprintf("文本过长，最多%d字", maxSpeakRunes)), nil
+	}
+	if err := s.controller.SpeakOnDevice(deviceID, text); err != nil {
+		return mcp.NewToolResultErrorFromErr("播报失败", err), nil
+	}
+	return mcp.NewToolResultText("设备已开始播报"), nil
+}
+
+func (s *DefaultMCPServerService) triggerOTA(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	deviceID, err := request.RequireString("device_id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := s.controller.RebootDevice(deviceID); err != nil {
+		return mcp.NewToolResultErrorFromErr("触发OTA失败", err), nil
+	}
+	return mcp.NewToolResultText("设备正在重启，重启后会检查固件更新"), nil
+}
+
+// onlineStatus 在线设备的状态
+func onlineStatus(device tools.Device) deviceStatus {
+	connectedAt := device.ConnectedAt
+	return deviceStatus{
+		DeviceID:    device.ID,
+		Online:      true,
+		ConnectedAt: &connectedAt,
+		ListenMode:  device.ListenMode,
+		Info:        device.Info,
+	}
+}
+
+// jsonResult 以JSON文本返回工具结果
+func jsonResult(v interface{}) (*mcp.CallToolResult, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化结果失败: %v", err)
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/router/admin"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// StatsSource 提供资源池统计和在线连接数
+type StatsSource interface {
+	GetPoolStats() map[string]map[string]int
+	GetActiveConnectionsCount() int
+}
+
+// counters 累计计数的统计项，其余为当前值
+var counters = map[string]bool{
+	"acquired":       true,
+	"acquire_failed": true,
+	"waited":         true,
+	"created":        true,
+	"create_failed":  true,
+	"destroyed":      true,
+	"returned":       true,
+	"evicted":        true,
+}
+
+// DefaultMetricsService 监控指标服务，以 Prometheus 文本格式或JSON导出资源池统计，供运维按实际负载确定池大小
+type DefaultMetricsService struct {
+	config *configs.Config
+	source StatsSource
+}
+
+// NewDefaultMetricsService 构造函数
+func NewDefaultMetricsService(config *configs.Config, source StatsSource) (*DefaultMetricsService, error) {
+	return &DefaultMetricsService{config: config, source: source}, nil
+}
+
+// Start 注册监控指标路由，未开启时不注册；配置了管理员令牌时抓取需要该令牌
+func (s *DefaultMetricsService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	if !s.config.Metrics.Enabled {
+		logrus.Info("未开启metrics，监控指标接口未开放")
+		return nil
+	}
+	if admin.Enabled(s.config) {
+		admin.Group(apiGroup, s.config).GET("/metrics", s.handleMetrics)
+	} else {
+		apiGroup.GET("/metrics", s.handleMetrics)
+	}
+
+	logrus.Info("监控指标HTTP服务路由注册完成")
+	return nil
+}
+
+// handleMetrics 导出资源池统计，默认为 Prometheus 文本格式，format=json 时返回JSON
+func (s *DefaultMetricsService) handleMetrics(c *gin.Context) {
+	pools := s.source.GetPoolStats()
+	connections := s.source.GetActiveConnectionsCount()
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, gin.H{"success": true, "active_connections": connections, "pools": pools})
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(prometheusText(pools, connections)))
+}
+
+// prometheusText 按 Prometheus 文本格式输出，每个统计项一个指标，资源池名称作为 pool 标签
+func prometheusText(pools map[string]map[string]int, connections int) string {
+	var b strings.Builder
+	b.WriteString("# TYPE xiaozhi_active_connections gauge\n")
+	fmt.Fprintf(&b, "xiaozhi_active_connections %d\n", connections)
+
+	names := make([]string, 0, len(pools))
+	stats := make(map[string]bool)
+	for name, poolStats := range pools {
+		names = append(names, name)
+		for stat := range poolStats {
+			stats[stat] = true
+		}
+	}
+	sort.Strings(names)
+	statNames := make([]string, 0, len(stats))
+	for stat := range stats {
+		statNames = append(statNames, stat)
+	}
+	sort.Strings(statNames)
+
+	for _, stat := range statNames {
+		metric := "xiaozhi_pool_" + stat
+		kind := "gauge"
+		if counters[stat] {
+			metric += "_total"
+			kind = "counter"
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", metric, kind)
+		for _, name := range names {
+			if value, ok := pools[name][stat]; ok {
+				fmt.Fprintf(&b, "%s{pool=%q} %d\n", metric, name, value)
+			}
+		}
+	}
+	return b.String()
+}
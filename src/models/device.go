@@ -6,6 +6,8 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"time"
+
+	"gorm.io/datatypes"
 )
 
 // Device represents a device in the system.
@@ -14,15 +16,23 @@ type Device struct {
 	SerialNumber      string     `gorm:"uniqueIndex;size:64" json:"serial_number"`
 	DeviceID          string     `gorm:"index;size:17" json:"device_id"` // MAC地址
 	ClientID          string     `gorm:"index;size:36" json:"client_id"` // UUID
+	UserID            int64      `gorm:"index;default:0" json:"user_id"` // 所属用户ID，0表示未绑定
 	Token             string     `gorm:"size:256" json:"token"`
 	ActivationCode    string     `gorm:"size:32" json:"activation_code"`
 	Challenge         string     `gorm:"size:64" json:"challenge"`
 	ActivationVersion int        `gorm:"default:1" json:"activation_version"`
 	Activated         bool       `gorm:"default:false" json:"activated"`
 	ActivatedAt       *time.Time `json:"activated_at"`
+	Channel           string     `gorm:"size:16;default:''" json:"channel"` // OTA发布渠道（stable/beta/dev），为空表示stable
 	LastSeen          time.Time  `gorm:"autoUpdateTime" json:"last_seen"`
 	CreatedAt         time.Time  `json:"created_at"`
 	UpdatedAt         time.Time  `json:"updated_at"`
+
+	// 服务端唤醒词检测使用的唤醒词列表（JSON数组），为空时使用默认唤醒词
+	WakeWords datatypes.JSON `gorm:"type:json" json:"wake_words"`
+
+	// 设备标签（JSON数组），用于固件分阶段发布时选择设备
+	Tags datatypes.JSON `gorm:"type:json" json:"tags"`
 }
 
 // TableName ...
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Firmware OTA固件，文件保存在 ota.dir 下，配置S3时同时保存在S3兼容存储。
+// Board、Chip、Application 为空表示不限，只下发给上报信息匹配的设备
+type Firmware struct {
+	ID          int64          `json:"id" gorm:"primaryKey;autoIncrement;column:id;comment:主键ID"`
+	Version     string         `json:"version" gorm:"column:version;type:varchar(32);not null;default:'';index;comment:固件版本"`
+	Board       string         `json:"board" gorm:"column:board;type:varchar(64);not null;default:'';index;comment:开发板类型（board.type），为空表示通用固件"`
+	Chip        string         `json:"chip" gorm:"column:chip;type:varchar(32);not null;default:'';comment:芯片型号（chip_model_name），为空表示不限"`
+	Application string         `json:"application" gorm:"column:application;type:varchar(64);not null;default:'';comment:应用名称（application.name），为空表示不限"`
+	Channel     string         `json:"channel" gorm:"column:channel;type:varchar(16);not null;default:'stable';index;comment:发布渠道（stable/beta/dev）"`
+	Filename    string         `json:"filename" gorm:"column:filename;type:varchar(128);not null;uniqueIndex;comment:固件文件名"`
+	Size        int64          `json:"size" gorm:"column:size;not null;default:0;comment:文件大小（字节）"`
+	SHA256      string         `json:"sha256" gorm:"column:sha256;type:varchar(64);not null;default:'';comment:文件SHA-256（十六进制）"`
+	Tags        datatypes.JSON `json:"tags" gorm:"column:tags;type:json;comment:标签（JSON数组）"`
+	Notes       string         `json:"notes" gorm:"column:notes;type:varchar(500);not null;default:'';comment:更新说明"`
+	Rollout     datatypes.JSON `json:"rollout" gorm:"column:rollout;type:json;comment:分阶段发布规则（JSON），为空时发布给渠道内所有设备"`
+	Remote      string         `json:"-" gorm:"column:remote;type:varchar(255);not null;default:'';comment:S3地址，为空时只保存在本地"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"column:created_at;autoCreateTime;comment:上传时间"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"column:updated_at;autoUpdateTime;comment:更新时间"`
+}
+
+func (Firmware) TableName() string {
+	return "firmwares"
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// DeviceProfile 设备的个性化设置，首次连接引导完成或切换角色后写入
+type DeviceProfile struct {
+	ID          int64      `json:"id" gorm:"primaryKey;autoIncrement;column:id;comment:主键ID"`
+	DeviceID    string     `json:"device_id" gorm:"column:device_id;type:varchar(64);not null;uniqueIndex;comment:设备ID"`
+	Nickname    string     `json:"nickname" gorm:"column:nickname;type:varchar(32);not null;default:'';comment:助手名字"`
+	Voice       string     `json:"voice" gorm:"column:voice;type:varchar(100);not null;default:'';comment:音色"`
+	RoleID      int64      `json:"role_id" gorm:"column:role_id;not null;default:0;comment:当前角色ID，0表示默认角色"`
+	OnboardedAt *time.Time `json:"onboarded_at" gorm:"column:onboarded_at;comment:完成首次引导的时间"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"column:updated_at;autoUpdateTime;comment:更新时间"`
+}
+
+func (DeviceProfile) TableName() string {
+	return "device_profiles"
+}
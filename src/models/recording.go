@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Recording 一轮对话中保存的一段录音：用户说的话或助手回复的一句话，音频文件保存在 recording.dir 下
+type Recording struct {
+	ID         int64     `json:"id" gorm:"primaryKey;autoIncrement;column:id;comment:主键ID"`
+	SessionID  string    `json:"session_id" gorm:"column:session_id;type:varchar(64);not null;index;comment:会话ID"`
+	DeviceID   string    `json:"device_id" gorm:"column:device_id;type:varchar(64);not null;default:'';index;comment:设备ID"`
+	Round      int       `json:"round" gorm:"column:round;not null;default:0;comment:会话内的轮次"`
+	Kind       string    `json:"kind" gorm:"column:kind;type:varchar(8);not null;default:'';comment:录音类型（user/reply）"`
+	Sentence   int       `json:"sentence" gorm:"column:sentence;not null;default:0;comment:回复中的句子序号，用户录音为0"`
+	Text       string    `json:"text" gorm:"column:text;type:text;comment:识别结果或回复文本"`
+	Path       string    `json:"-" gorm:"column:path;type:varchar(255);not null;default:'';comment:音频文件相对录音目录的路径"`
+	Format     string    `json:"format" gorm:"column:format;type:varchar(8);not null;default:'';comment:音频格式（wav/mp3等）"`
+	Size       int64     `json:"size" gorm:"column:size;not null;default:0;comment:文件大小（字节）"`
+	DurationMs int64     `json:"duration_ms" gorm:"column:duration_ms;not null;default:0;comment:音频时长（毫秒），未知时为0"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at;autoCreateTime;index;comment:创建时间"`
+}
+
+func (Recording) TableName() string {
+	return "recordings"
+}
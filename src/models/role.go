@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Role 助手角色（人设），设备可以在对话中切换，切换后使用角色的提示词、音色和LLM
+type Role struct {
+	ID          int64     `json:"id" gorm:"primaryKey;autoIncrement;column:id;comment:主键ID"`
+	Name        string    `json:"name" gorm:"column:name;type:varchar(32);not null;uniqueIndex;comment:角色名称"`
+	Description string    `json:"description" gorm:"column:description;type:varchar(200);not null;default:'';comment:角色简介，提供给LLM选择角色"`
+	Prompt      string    `json:"prompt" gorm:"column:prompt;type:text;comment:角色提示词，为空时使用默认提示词"`
+	Voice       string    `json:"voice" gorm:"column:voice;type:varchar(100);not null;default:'';comment:音色，为空时使用默认音色"`
+	LLM         string    `json:"llm" gorm:"column:llm;type:varchar(100);not null;default:'';comment:LLM配置名称，为空时使用默认LLM"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at;autoCreateTime;comment:创建时间"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"column:updated_at;autoUpdateTime;comment:更新时间"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
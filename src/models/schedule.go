@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ScheduledJob 持久化的定时任务，到期后提交到任务管理器执行
+type ScheduledJob struct {
+	ID        int64      `json:"id" gorm:"primaryKey;autoIncrement;column:id;comment:主键ID"`
+	Name      string     `json:"name" gorm:"column:name;type:varchar(100);uniqueIndex;not null;comment:任务名称"`
+	Kind      string     `json:"kind" gorm:"column:kind;type:varchar(50);not null;comment:任务类型（reminder/log_cleanup/usage_report）"`
+	Payload   string     `json:"payload" gorm:"column:payload;type:text;comment:任务参数JSON"`
+	Cron      string     `json:"cron" gorm:"column:cron;type:varchar(100);not null;default:'';comment:cron表达式，为空表示只执行一次"`
+	NextRunAt time.Time  `json:"next_run_at" gorm:"column:next_run_at;index;comment:下次执行时间"`
+	LastRunAt *time.Time `json:"last_run_at" gorm:"column:last_run_at;comment:上次执行时间"`
+	LastError string     `json:"last_error" gorm:"column:last_error;type:varchar(500);not null;default:'';comment:上次执行的错误"`
+	Enabled   bool       `json:"enabled" gorm:"column:enabled;not null;default:true;index;comment:是否启用，一次性任务执行后停用"`
+	CreatedAt time.Time  `json:"created_at" gorm:"column:created_at;autoCreateTime;comment:创建时间"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"column:updated_at;autoUpdateTime;comment:更新时间"`
+}
+
+func (ScheduledJob) TableName() string {
+	return "scheduled_jobs"
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// ConversationTurn 一轮对话的问答记录，用户可对助手回复打分
+type ConversationTurn struct {
+	ID             int64      `json:"id" gorm:"primaryKey;autoIncrement;column:id;comment:主键ID"`
+	SessionID      string     `json:"session_id" gorm:"column:session_id;type:varchar(64);not null;index;comment:会话ID"`
+	DeviceID       string     `json:"device_id" gorm:"column:device_id;type:varchar(64);not null;default:'';index;comment:设备ID"`
+	Round          int        `json:"round" gorm:"column:round;not null;default:0;comment:会话内的轮次"`
+	Question       string     `json:"question" gorm:"column:question;type:text;comment:用户的问题"`
+	Answer         string     `json:"answer" gorm:"column:answer;type:text;comment:助手的回复"`
+	Model          string     `json:"model" gorm:"column:model;type:varchar(100);not null;default:'';comment:生成回复的模型"`
+	Rating         string     `json:"rating" gorm:"column:rating;type:varchar(8);not null;default:'';index;comment:用户评价（good/bad），为空表示未评价"`
+	FeedbackSource string     `json:"feedback_source" gorm:"column:feedback_source;type:varchar(16);not null;default:'';comment:评价来源（device/api）"`
+	Comment        string     `json:"comment" gorm:"column:comment;type:varchar(500);not null;default:'';comment:评价备注"`
+	FeedbackAt     *time.Time `json:"feedback_at" gorm:"column:feedback_at;comment:评价时间"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"column:created_at;autoCreateTime;index;comment:创建时间"`
+}
+
+func (ConversationTurn) TableName() string {
+	return "conversation_turns"
+}
+
+// ConversationSummary 会话早期对话的摘要，对话轮次超过阈值时更新，设备下次连接时载入
+type ConversationSummary struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement;column:id;comment:主键ID"`
+	SessionID string    `json:"session_id" gorm:"column:session_id;type:varchar(64);not null;uniqueIndex;comment:会话ID"`
+	DeviceID  string    `json:"device_id" gorm:"column:device_id;type:varchar(64);not null;default:'';index;comment:设备ID"`
+	Summary   string    `json:"summary" gorm:"column:summary;type:text;comment:早期对话的摘要"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;autoCreateTime;comment:创建时间"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at;autoUpdateTime;index;comment:更新时间"`
+}
+
+func (ConversationSummary) TableName() string {
+	return "conversation_summaries"
+}
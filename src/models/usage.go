@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// UsageRecord 按设备、日期和模型汇总的token用量与音频时长
+type UsageRecord struct {
+	ID               int64  `json:"id" gorm:"primaryKey;autoIncrement;column:id;comment:主键ID"`
+	DeviceID         string `json:"device_id" gorm:"column:device_id;type:varchar(64);not null;default:'';uniqueIndex:idx_usage_device_day;comment:设备ID"`
+	Day              string `json:"day" gorm:"column:day;type:varchar(10);not null;uniqueIndex:idx_usage_device_day;index;comment:日期（YYYY-MM-DD）"`
+	Kind             string `json:"kind" gorm:"column:kind;type:varchar(16);not null;uniqueIndex:idx_usage_device_day;comment:模块类型（LLM/VLLLM/ASR/TTS）"`
+	Model            string `json:"model" gorm:"column:model;type:varchar(100);not null;default:'';uniqueIndex:idx_usage_device_day;comment:模型名称"`
+	Requests         int64  `json:"requests" gorm:"column:requests;not null;default:0;comment:请求次数"`
+	PromptTokens     int64  `json:"prompt_tokens" gorm:"column:prompt_tokens;not null;default:0;comment:输入token数"`
+	CompletionTokens int64  `json:"completion_tokens" gorm:"column:completion_tokens;not null;default:0;comment:输出token数"`
+	AudioMs          int64  `json:"audio_ms" gorm:"column:audio_ms;not null;default:0;comment:音频时长（毫秒），ASR为识别的上行音频，TTS为下发的合成音频"`
+}
+
+func (UsageRecord) TableName() string {
+	return "usage_records"
+}
+
+// UsageBudget 设备或设备分组的每月用量预算
+type UsageBudget struct {
+	ID                  int64     `json:"id" gorm:"primaryKey;autoIncrement;column:id;comment:主键ID"`
+	Scope               string    `json:"scope" gorm:"column:scope;type:varchar(16);not null;uniqueIndex:idx_budget_subject;comment:预算对象类型（device/group）"`
+	Subject             string    `json:"subject" gorm:"column:subject;type:varchar(64);not null;uniqueIndex:idx_budget_subject;comment:设备ID或分组名称"`
+	MonthlyTokens       int64     `json:"monthly_tokens" gorm:"column:monthly_tokens;not null;default:0;comment:每月token上限，0表示不限"`
+	MonthlyAudioMinutes int64     `json:"monthly_audio_minutes" gorm:"column:monthly_audio_minutes;not null;default:0;comment:每月音频分钟数上限，0表示不限"`
+	UpdatedAt           time.Time `json:"updated_at" gorm:"column:updated_at;autoUpdateTime;comment:更新时间"`
+}
+
+func (UsageBudget) TableName() string {
+	return "usage_budgets"
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Voiceprint 用户的声纹，同一用户可以登记多段声纹，识别时取相似度最高的一段
+type Voiceprint struct {
+	ID        int64          `json:"id" gorm:"primaryKey;autoIncrement;column:id;comment:主键ID"`
+	UserID    int64          `json:"user_id" gorm:"column:user_id;not null;index;comment:用户ID"`
+	Label     string         `json:"label" gorm:"column:label;type:varchar(32);not null;default:'';comment:备注，如录音场景"`
+	Embedding datatypes.JSON `json:"-" gorm:"column:embedding;type:json;comment:声纹向量（JSON数组）"`
+	CreatedAt time.Time      `json:"created_at" gorm:"column:created_at;autoCreateTime;comment:创建时间"`
+}
+
+func (Voiceprint) TableName() string {
+	return "voiceprints"
+}
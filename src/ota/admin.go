@@ -0,0 +1,360 @@
+package ota
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// handleListFirmwares 按版本从新到旧列出固件，查询参数 board 不为空时只列出该开发板的专用固件和通用固件
+func (s *DefaultOTAService) handleListFirmwares(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	firmwares, err := s.store.list(database.DB)
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if board := c.Query("board"); board != "" {
+		filtered := firmwares[:0]
+		for _, firmware := range firmwares {
+			if firmware.Board == "" || strings.EqualFold(firmware.Board, board) {
+				filtered = append(filtered, firmware)
+			}
+		}
+		firmwares = filtered
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "firmwares": firmwares})
+}
+
+// handleUploadFirmware 上传固件，multipart 表单：
+// file 固件文件；version 版本号，为空时使用文件名（去掉 .bin）；board 开发板类型、chip 芯片型号、
+// application 应用名称，为空表示不限；channel 发布渠道，默认 stable；rollout_percent 首次发布的设备比例，
+// 为空时发布给渠道内的所有设备；tags 逗号分隔的标签；notes 更新说明；
+// size、sha256 声明的文件大小和SHA-256，填写时校验上传的文件，不一致时拒绝保存
+func (s *DefaultOTAService) handleUploadFirmware(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.maxUploadSize())
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.respondError(c, http.StatusRequestEntityTooLarge, "固件文件过大")
+			return
+		}
+		s.respondError(c, http.StatusBadRequest, "缺少固件文件 file")
+		return
+	}
+
+	firmware := models.Firmware{
+		Version:     strings.TrimSpace(c.PostForm("version")),
+		Board:       strings.TrimSpace(c.PostForm("board")),
+		Chip:        strings.TrimSpace(c.PostForm("chip")),
+		Application: strings.TrimSpace(c.PostForm("application")),
+		Channel:     c.PostForm("channel"),
+		Notes:       strings.TrimSpace(c.PostForm("notes")),
+		SHA256:      strings.TrimSpace(c.PostForm("sha256")),
+	}
+	if value := strings.TrimSpace(c.PostForm("size")); value != "" {
+		if firmware.Size, err = strconv.ParseInt(value, 10, 64); err != nil || firmware.Size <= 0 {
+			s.respondError(c, http.StatusBadRequest, "无效的文件大小 size")
+			return
+		}
+	}
+	if firmware.Version == "" {
+		firmware.Version = strings.TrimSuffix(file.Filename, ".bin")
+	}
+	if utf8.RuneCountInString(firmware.Notes) > maxNotesLength {
+		s.respondError(c, http.StatusBadRequest, "更新说明过长")
+		return
+	}
+	if firmware.Tags, err = encodeTags(strings.Split(c.PostForm("tags"), ",")); err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if value := strings.TrimSpace(c.PostForm("rollout_percent")); value != "" {
+		percent, err := strconv.Atoi(value)
+		rollout := Rollout{Percent: percent}
+		if err == nil {
+			err = rollout.validate()
+		}
+		if err != nil {
+			s.respondError(c, http.StatusBadRequest, "无效的发布比例 rollout_percent")
+			return
+		}
+		firmware.Rollout, _ = json.Marshal(rollout)
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, "读取固件文件失败: "+err.Error())
+		return
+	}
+	defer reader.Close()
+	if err := s.store.save(database.DB, reader, &firmware); err != nil {
+		s.respondFirmwareError(c, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"version": firmware.Version, "board": firmware.Board, "channel": firmware.Channel, "size": firmware.Size, "sha256": firmware.SHA256}).Info("已上传固件")
+	c.JSON(http.StatusOK, gin.H{"success": true, "firmware": firmware})
+}
+
+// updateFirmwareRequest 修改固件请求，channel 为空时不修改渠道
+type updateFirmwareRequest struct {
+	Tags    []string `json:"tags"`
+	Notes   string   `json:"notes"`
+	Channel string   `json:"channel"`
+}
+
+// handleUpdateFirmware 修改固件的标签、更新说明和发布渠道，测试通过后把渠道改为 stable 即推送给所有设备
+// 请求体：{"tags":["已验证"],"notes":"修复唤醒后偶尔无声","channel":"stable"}
+func (s *DefaultOTAService) handleUpdateFirmware(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	id, ok := s.firmwareID(c)
+	if !ok {
+		return
+	}
+	var req updateFirmwareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "请求格式错误: "+err.Error())
+		return
+	}
+	req.Notes = strings.TrimSpace(req.Notes)
+	if utf8.RuneCountInString(req.Notes) > maxNotesLength {
+		s.respondError(c, http.StatusBadRequest, "更新说明过长")
+		return
+	}
+	firmware, err := s.store.update(database.DB, id, req.Tags, req.Notes, req.Channel)
+	if err != nil {
+		s.respondFirmwareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "firmware": firmware})
+}
+
+// handleSetRollout 设置固件的分阶段发布规则
+// 请求体：{"percent":10,"tags":["内测"],"serials":[{"from":"SN0001","to":"SN0100"}],"paused":false}
+func (s *DefaultOTAService) handleSetRollout(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	id, ok := s.firmwareID(c)
+	if !ok {
+		return
+	}
+	var rollout Rollout
+	if err := c.ShouldBindJSON(&rollout); err != nil {
+		s.respondError(c, http.StatusBadRequest, "请求格式错误: "+err.Error())
+		return
+	}
+	s.updateRollout(c, id, &rollout)
+}
+
+// handleClearRollout 清除固件的发布规则，发布给渠道内的所有设备
+func (s *DefaultOTAService) handleClearRollout(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	id, ok := s.firmwareID(c)
+	if !ok {
+		return
+	}
+	s.updateRollout(c, id, nil)
+}
+
+// updateRollout 保存发布规则并返回修改后的固件
+func (s *DefaultOTAService) updateRollout(c *gin.Context, id int64, rollout *Rollout) {
+	firmware, err := s.store.setRollout(database.DB, id, rollout)
+	if err != nil {
+		s.respondFirmwareError(c, err)
+		return
+	}
+	logrus.WithFields(logrus.Fields{"version": firmware.Version, "rollout": string(firmware.Rollout)}).Info("已设置固件发布规则")
+	c.JSON(http.StatusOK, gin.H{"success": true, "firmware": firmware})
+}
+
+// handleDeleteFirmware 删除固件记录和文件
+func (s *DefaultOTAService) handleDeleteFirmware(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	id, ok := s.firmwareID(c)
+	if !ok {
+		return
+	}
+	firmware, err := s.store.remove(database.DB, id)
+	if err != nil {
+		s.respondFirmwareError(c, err)
+		return
+	}
+	logrus.WithField("version", firmware.Version).Info("已删除固件")
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// handleGetDeviceChannel 查询设备的发布渠道
+func (s *DefaultOTAService) handleGetDeviceChannel(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	device, ok := s.findDevice(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "channel": normalizeChannel(device.Channel)})
+}
+
+// setDeviceChannelRequest 设置设备发布渠道请求
+type setDeviceChannelRequest struct {
+	Channel string `json:"channel"`
+}
+
+// handleSetDeviceChannel 设置设备的发布渠道，设备下次检查更新时生效
+// 请求体：{"channel":"beta"}
+func (s *DefaultOTAService) handleSetDeviceChannel(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	var req setDeviceChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "请求格式错误: "+err.Error())
+		return
+	}
+	channel, err := validateChannel(req.Channel)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	device, ok := s.findDevice(c)
+	if !ok {
+		return
+	}
+	if err := database.DB.Model(device).Update("channel", channel).Error; err != nil {
+		s.respondError(c, http.StatusInternalServerError, "设置发布渠道失败: "+err.Error())
+		return
+	}
+	logrus.WithFields(logrus.Fields{"device_id": device.DeviceID, "channel": channel}).Info("已设置设备发布渠道")
+	c.JSON(http.StatusOK, gin.H{"success": true, "channel": channel})
+}
+
+// handleGetDeviceTags 查询设备的标签
+func (s *DefaultOTAService) handleGetDeviceTags(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	device, ok := s.findDevice(c)
+	if !ok {
+		return
+	}
+	tags := []string{}
+	_ = json.Unmarshal(device.Tags, &tags)
+	c.JSON(http.StatusOK, gin.H{"success": true, "tags": tags})
+}
+
+// setDeviceTagsRequest 设置设备标签请求
+type setDeviceTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// handleSetDeviceTags 设置设备的标签，固件分阶段发布时按标签选择设备
+// 请求体：{"tags":["内测","办公室"]}
+func (s *DefaultOTAService) handleSetDeviceTags(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	var req setDeviceTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "请求格式错误: "+err.Error())
+		return
+	}
+	tags, err := encodeTags(req.Tags)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	device, ok := s.findDevice(c)
+	if !ok {
+		return
+	}
+	if err := database.DB.Model(device).Update("tags", datatypes.JSON(tags)).Error; err != nil {
+		s.respondError(c, http.StatusInternalServerError, "设置设备标签失败: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "tags": json.RawMessage(tags)})
+}
+
+// findDevice 按路径中的设备ID（MAC地址）查询已登记的设备
+func (s *DefaultOTAService) findDevice(c *gin.Context) (*models.Device, bool) {
+	var device models.Device
+	err := database.DB.Where("device_id = ?", c.Param("device_id")).Take(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.respondError(c, http.StatusNotFound, "设备不存在")
+		return nil, false
+	}
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, "查询设备失败: "+err.Error())
+		return nil, false
+	}
+	return &device, true
+}
+
+// maxUploadSize 上传请求的大小上限
+func (s *DefaultOTAService) maxUploadSize() int64 {
+	mb := s.Config.OTA.MaxUploadMB
+	if mb <= 0 {
+		mb = defaultMaxUploadMB
+	}
+	// 预留multipart表单其他字段的空间
+	return int64(mb)<<20 + 64<<10
+}
+
+// firmwareID 解析路径中的固件ID
+func (s *DefaultOTAService) firmwareID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		s.respondError(c, http.StatusBadRequest, "无效的固件ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// requireDB 固件记录保存在数据库中，数据库未连接时返回503
+func (s *DefaultOTAService) requireDB(c *gin.Context) bool {
+	if database.DB == nil {
+		s.respondError(c, http.StatusServiceUnavailable, "数据库未连接")
+		return false
+	}
+	return true
+}
+
+// respondFirmwareError 固件不存在返回404，重复上传返回409，校验失败和其他错误返回400
+func (s *DefaultOTAService) respondFirmwareError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrFirmwareNotFound):
+		s.respondError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrFirmwareExists):
+		s.respondError(c, http.StatusConflict, err.Error())
+	default:
+		s.respondError(c, http.StatusBadRequest, err.Error())
+	}
+}
+
+// respondError 返回错误响应
+func (s *DefaultOTAService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, ErrorResponse{Success: false, Message: message})
+}
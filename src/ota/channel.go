@@ -0,0 +1,47 @@
+package ota
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 固件发布渠道。测试版设备同时接收稳定版固件，开发版设备接收所有渠道的固件，
+// 新固件先发布到 beta 在测试设备上验证，再把渠道改为 stable 推送给所有设备
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+	ChannelDev    = "dev"
+)
+
+// channelRank 渠道的开放程度，设备接收不高于其渠道的固件
+var channelRank = map[string]int{
+	ChannelStable: 0,
+	ChannelBeta:   1,
+	ChannelDev:    2,
+}
+
+// normalizeChannel 未设置或未知的渠道按稳定版处理
+func normalizeChannel(channel string) string {
+	channel = strings.ToLower(strings.TrimSpace(channel))
+	if _, ok := channelRank[channel]; !ok {
+		return ChannelStable
+	}
+	return channel
+}
+
+// validateChannel 校验渠道名称，为空时返回稳定版
+func validateChannel(channel string) (string, error) {
+	channel = strings.ToLower(strings.TrimSpace(channel))
+	if channel == "" {
+		return ChannelStable, nil
+	}
+	if _, ok := channelRank[channel]; !ok {
+		return "", fmt.Errorf("未知的发布渠道: %s，可选 stable、beta、dev", channel)
+	}
+	return channel, nil
+}
+
+// channelIncludes 该渠道的设备是否接收指定渠道的固件
+func channelIncludes(deviceChannel, firmwareChannel string) bool {
+	return channelRank[normalizeChannel(firmwareChannel)] <= channelRank[normalizeChannel(deviceChannel)]
+}
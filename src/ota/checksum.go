@@ -0,0 +1,60 @@
+package ota
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// checksumEntry 缓存的文件校验和，文件修改时间或大小变化后重新计算
+type checksumEntry struct {
+	modTime time.Time
+	size    int64
+	sum     string
+}
+
+var (
+	checksumMu    sync.Mutex
+	checksumCache = make(map[string]checksumEntry)
+)
+
+// fileChecksum 计算文件的大小和SHA-256（十六进制小写），结果按路径缓存
+func fileChecksum(path string) (int64, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, "", err
+	}
+	checksumMu.Lock()
+	entry, ok := checksumCache[path]
+	checksumMu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.size, entry.sum, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", fmt.Errorf("计算固件校验和失败: %v", err)
+	}
+	entry = checksumEntry{modTime: info.ModTime(), size: size, sum: hex.EncodeToString(hash.Sum(nil))}
+	checksumMu.Lock()
+	checksumCache[path] = entry
+	checksumMu.Unlock()
+	return entry.size, entry.sum, nil
+}
+
+// forgetChecksum 删除文件后清除缓存
+func forgetChecksum(path string) {
+	checksumMu.Lock()
+	delete(checksumCache, path)
+	checksumMu.Unlock()
+}
@@ -0,0 +1,488 @@
+package ota
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"xiaozhi-server-go/src/backup"
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultFirmwareDir = "ota_bin"
+	defaultMaxUploadMB = 32
+	maxVersionLength   = 32
+	maxBoardLength     = 64
+	maxChipLength      = 32
+	maxAppLength       = 64
+	maxNotesLength     = 500
+	maxTags            = 10
+	maxTagLength       = 32
+)
+
+var (
+	// ErrFirmwareNotFound 固件不存在
+	ErrFirmwareNotFound = errors.New("固件不存在")
+	// ErrFirmwareExists 同名固件已存在
+	ErrFirmwareExists = errors.New("同名固件已存在")
+	// ErrChecksumMismatch 上传的固件与声明的大小或SHA-256不一致
+	ErrChecksumMismatch = errors.New("固件校验失败")
+)
+
+// sha256Pattern 十六进制的SHA-256
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+var (
+	// versionPattern 固件版本号，同时作为文件名的一部分
+	versionPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._+-]*$`)
+	// modelPattern 开发板类型、芯片型号和应用名称，同时作为文件名的一部分
+	modelPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._-]*$`)
+)
+
+// deviceModel 设备在OTA请求中上报的型号信息
+type deviceModel struct {
+	Board       string // board.type
+	Chip        string // chip_model_name
+	Application string // application.name
+}
+
+// match 固件是否适用于设备，specific 表示固件指定了开发板类型。
+// 设备没有上报的信息不参与匹配，兼容只上报版本号的旧固件
+func (d deviceModel) match(firmware *models.Firmware) (ok bool, specific bool) {
+	if firmware.Chip != "" && d.Chip != "" && !strings.EqualFold(firmware.Chip, d.Chip) {
+		return false, false
+	}
+	if firmware.Application != "" && d.Application != "" && !strings.EqualFold(firmware.Application, d.Application) {
+		return false, false
+	}
+	if firmware.Board == "" {
+		return true, false
+	}
+	if d.Board == "" {
+		return false, false
+	}
+	return strings.EqualFold(firmware.Board, d.Board), true
+}
+
+// firmwareStore 固件文件和记录。文件保存在本地目录，配置S3时同时上传，
+// 本地没有的文件在下载时从S3拉取，本地目录相当于缓存
+type firmwareStore struct {
+	dir string
+	s3  *backup.S3Client
+	mu  sync.Mutex // 串行化上传、删除和从S3拉取
+}
+
+// newFirmwareStore 创建固件目录和S3客户端
+func newFirmwareStore(config *configs.OTAConfig) (*firmwareStore, error) {
+	dir := config.Dir
+	if dir == "" {
+		dir = defaultFirmwareDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建固件目录失败: %v", err)
+	}
+	s3, err := backup.NewS3Client(config.S3)
+	if err != nil {
+		return nil, err
+	}
+	return &firmwareStore{dir: dir, s3: s3}, nil
+}
+
+// list 按版本从新到旧列出固件
+func (s *firmwareStore) list(db *gorm.DB) ([]models.Firmware, error) {
+	var firmwares []models.Firmware
+	if err := db.Find(&firmwares).Error; err != nil {
+		return nil, fmt.Errorf("查询固件失败: %v", err)
+	}
+	sort.SliceStable(firmwares, func(i, j int) bool {
+		return versionLess(firmwares[j].Version, firmwares[i].Version)
+	})
+	return firmwares, nil
+}
+
+// get 按ID查询固件
+func (s *firmwareStore) get(db *gorm.DB, id int64) (*models.Firmware, error) {
+	var firmware models.Firmware
+	err := db.Where("id = ?", id).Take(&firmware).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFirmwareNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询固件失败: %v", err)
+	}
+	return &firmware, nil
+}
+
+// latest 设备渠道内适用于设备、且设备在发布范围内的最新固件，没有固件时返回nil。
+// 指定了设备开发板类型的固件优先，没有时才使用通用固件。数据库未连接时按文件名扫描固件目录，
+// 不区分型号、渠道和发布范围
+func (s *firmwareStore) latest(db *gorm.DB, device deviceModel, channel string, target rolloutTarget) (*models.Firmware, error) {
+	if db == nil {
+		bins, _ := filepath.Glob(filepath.Join(s.dir, "*.bin"))
+		if len(bins) == 0 {
+			return nil, nil
+		}
+		sort.Slice(bins, func(i, j int) bool {
+			return versionLess(strings.TrimSuffix(filepath.Base(bins[j]), ".bin"), strings.TrimSuffix(filepath.Base(bins[i]), ".bin"))
+		})
+		name := filepath.Base(bins[0])
+		firmware := &models.Firmware{Version: strings.TrimSuffix(name, ".bin"), Filename: name}
+		if size, sum, err := fileChecksum(bins[0]); err == nil {
+			firmware.Size, firmware.SHA256 = size, sum
+		}
+		return firmware, nil
+	}
+	all, err := s.list(db)
+	if err != nil {
+		return nil, err
+	}
+	firmwares := all[:0]
+	for _, firmware := range all {
+		if channelIncludes(channel, firmware.Channel) {
+			firmwares = append(firmwares, firmware)
+		}
+	}
+	var generic *models.Firmware
+	for i := range firmwares {
+		ok, specific := device.match(&firmwares[i])
+		if !ok || !rolloutIncludes(&firmwares[i], target) {
+			continue
+		}
+		if specific {
+			return &firmwares[i], nil
+		}
+		if generic == nil {
+			generic = &firmwares[i]
+		}
+	}
+	if generic != nil && device.Board != "" && s.hasBoard(firmwares, device.Board) {
+		// 该开发板有专用固件，但都不适用（芯片或应用不匹配、不在发布范围内），不下发通用固件
+		return nil, nil
+	}
+	return generic, nil
+}
+
+// hasBoard 是否有指定该开发板类型的固件
+func (s *firmwareStore) hasBoard(firmwares []models.Firmware, board string) bool {
+	for _, firmware := range firmwares {
+		if strings.EqualFold(firmware.Board, board) {
+			return true
+		}
+	}
+	return false
+}
+
+// save 保存上传的固件文件和记录，文件名为 开发板_芯片_应用_版本号.bin，省略为空的部分。
+// firmware.Size、firmware.SHA256 不为空时校验上传的文件，不一致时返回 ErrChecksumMismatch
+func (s *firmwareStore) save(db *gorm.DB, r io.Reader, firmware *models.Firmware) error {
+	if err := validateFirmware(firmware); err != nil {
+		return err
+	}
+	declaredSize := firmware.Size
+	declaredSum := strings.ToLower(firmware.SHA256)
+	if declaredSum != "" && !sha256Pattern.MatchString(declaredSum) {
+		return fmt.Errorf("无效的SHA-256: %s", firmware.SHA256)
+	}
+	channel, err := validateChannel(firmware.Channel)
+	if err != nil {
+		return err
+	}
+	firmware.ID = 0
+	firmware.Channel = channel
+	firmware.Filename = firmwareFilename(firmware)
+	firmware.Remote = ""
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	if err := db.Model(&models.Firmware{}).Where("filename = ?", firmware.Filename).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询固件失败: %v", err)
+	}
+	path := filepath.Join(s.dir, firmware.Filename)
+	if _, err := os.Stat(path); count > 0 || err == nil {
+		return ErrFirmwareExists
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("保存固件失败: %v", err)
+	}
+	if size == 0 {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("固件文件为空")
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if (declaredSize > 0 && declaredSize != size) || (declaredSum != "" && declaredSum != sum) {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("%w: 收到 %d 字节，SHA-256 %s", ErrChecksumMismatch, size, sum)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("保存固件失败: %v", err)
+	}
+	firmware.Size = size
+	firmware.SHA256 = sum
+
+	if s.s3 != nil {
+		remote, err := s.s3.Upload(path)
+		if err != nil {
+			os.Remove(path)
+			return err
+		}
+		firmware.Remote = remote
+	}
+	if err := db.Create(firmware).Error; err != nil {
+		s.removeFiles(firmware)
+		return fmt.Errorf("保存固件记录失败: %v", err)
+	}
+	return nil
+}
+
+// setRollout 设置固件的发布规则，rollout 为nil时发布给渠道内的所有设备
+func (s *firmwareStore) setRollout(db *gorm.DB, id int64, rollout *Rollout) (*models.Firmware, error) {
+	firmware, err := s.get(db, id)
+	if err != nil {
+		return nil, err
+	}
+	firmware.Rollout = nil
+	if rollout != nil {
+		if err := rollout.validate(); err != nil {
+			return nil, err
+		}
+		if firmware.Rollout, err = json.Marshal(rollout); err != nil {
+			return nil, err
+		}
+	}
+	if err := db.Model(firmware).Select("rollout").Updates(firmware).Error; err != nil {
+		return nil, fmt.Errorf("设置发布规则失败: %v", err)
+	}
+	return s.get(db, id)
+}
+
+// update 修改固件的标签、更新说明和发布渠道，channel 为空时不修改渠道
+func (s *firmwareStore) update(db *gorm.DB, id int64, tags []string, notes, channel string) (*models.Firmware, error) {
+	firmware, err := s.get(db, id)
+	if err != nil {
+		return nil, err
+	}
+	if firmware.Tags, err = encodeTags(tags); err != nil {
+		return nil, err
+	}
+	if channel != "" {
+		if firmware.Channel, err = validateChannel(channel); err != nil {
+			return nil, err
+		}
+	}
+	firmware.Notes = notes
+	if err := db.Model(firmware).Select("tags", "notes", "channel").Updates(firmware).Error; err != nil {
+		return nil, fmt.Errorf("修改固件失败: %v", err)
+	}
+	return s.get(db, id)
+}
+
+// remove 删除固件记录、本地文件和S3对象
+func (s *firmwareStore) remove(db *gorm.DB, id int64) (*models.Firmware, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	firmware, err := s.get(db, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Delete(&models.Firmware{}, id).Error; err != nil {
+		return nil, fmt.Errorf("删除固件失败: %v", err)
+	}
+	s.removeFiles(firmware)
+	return firmware, nil
+}
+
+// removeFiles 删除固件的本地文件和S3对象，失败时只记录日志
+func (s *firmwareStore) removeFiles(firmware *models.Firmware) {
+	path := filepath.Join(s.dir, firmware.Filename)
+	forgetChecksum(path)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).WithField("filename", firmware.Filename).Warn("删除固件文件失败")
+	}
+	if firmware.Remote != "" && s.s3 != nil {
+		if err := s.s3.Delete(firmware.Remote); err != nil {
+			logrus.WithError(err).WithField("remote", firmware.Remote).Warn("删除S3固件失败")
+		}
+	}
+}
+
+// localPath 固件的本地路径，本地没有而记录中有S3地址时先拉取到本地
+func (s *firmwareStore) localPath(db *gorm.DB, filename string) (string, error) {
+	if filename == "" || filename != filepath.Base(filename) || strings.HasPrefix(filename, ".") {
+		return "", ErrFirmwareNotFound
+	}
+	path := filepath.Join(s.dir, filename)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if db == nil || s.s3 == nil {
+		return "", ErrFirmwareNotFound
+	}
+
+	var firmware models.Firmware
+	if err := db.Where("filename = ?", filename).Take(&firmware).Error; err != nil || firmware.Remote == "" {
+		return "", ErrFirmwareNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	tmp := filepath.Join(s.dir, ".download-"+filename)
+	if err := s.s3.Download(firmware.Remote, tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if _, sum, err := fileChecksum(tmp); err != nil || (firmware.SHA256 != "" && sum != firmware.SHA256) {
+		forgetChecksum(tmp)
+		os.Remove(tmp)
+		return "", fmt.Errorf("%w: 从S3拉取的固件 %s 与记录不一致", ErrChecksumMismatch, filename)
+	}
+	forgetChecksum(tmp)
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("保存固件失败: %v", err)
+	}
+	logrus.WithField("filename", filename).Info("已从S3拉取固件")
+	return path, nil
+}
+
+// importLocal 登记固件目录中没有记录的 .bin 文件，兼容手动放入目录的固件，
+// 同时为没有校验和的记录补算SHA-256
+func (s *firmwareStore) importLocal(db *gorm.DB) error {
+	bins, err := filepath.Glob(filepath.Join(s.dir, "*.bin"))
+	if err != nil {
+		return err
+	}
+	for _, path := range bins {
+		name := filepath.Base(path)
+		var existing models.Firmware
+		err := db.Where("filename = ?", name).Take(&existing).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("查询固件失败: %v", err)
+		}
+		size, sum, checksumErr := fileChecksum(path)
+		if checksumErr != nil {
+			logrus.WithError(checksumErr).WithField("filename", name).Warn("计算固件校验和失败")
+			continue
+		}
+		if err == nil {
+			if existing.SHA256 == "" {
+				if err := db.Model(&existing).Updates(map[string]interface{}{"size": size, "sha256": sum}).Error; err != nil {
+					return fmt.Errorf("保存固件校验和失败: %v", err)
+				}
+			}
+			continue
+		}
+		firmware := models.Firmware{
+			Version:  strings.TrimSuffix(name, ".bin"),
+			Filename: name,
+			Size:     size,
+			SHA256:   sum,
+			Channel:  ChannelStable,
+			Tags:     []byte("[]"),
+		}
+		if len(firmware.Version) > maxVersionLength {
+			logrus.WithField("filename", name).Warn("固件文件名过长，跳过登记")
+			continue
+		}
+		if err := db.Create(&firmware).Error; err != nil {
+			return fmt.Errorf("登记固件失败: %v", err)
+		}
+		logrus.WithField("filename", name).Info("已登记固件目录中的固件")
+	}
+	return nil
+}
+
+// validateFirmware 校验版本号和型号信息
+func validateFirmware(firmware *models.Firmware) error {
+	switch version := firmware.Version; {
+	case version == "":
+		return fmt.Errorf("缺少固件版本号 version")
+	case len(version) > maxVersionLength:
+		return fmt.Errorf("固件版本号不能超过%d个字符", maxVersionLength)
+	case !versionPattern.MatchString(version):
+		return fmt.Errorf("固件版本号只能包含字母、数字和 . _ + -")
+	}
+	fields := []struct {
+		name, value string
+		max         int
+	}{
+		{"开发板类型 board", firmware.Board, maxBoardLength},
+		{"芯片型号 chip", firmware.Chip, maxChipLength},
+		{"应用名称 application", firmware.Application, maxAppLength},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		if len(field.value) > field.max {
+			return fmt.Errorf("%s不能超过%d个字符", field.name, field.max)
+		}
+		if !modelPattern.MatchString(field.value) {
+			return fmt.Errorf("%s只能包含字母、数字和 . _ -", field.name)
+		}
+	}
+	return nil
+}
+
+// firmwareFilename 固件文件名，同一版本不同型号的固件互不覆盖
+func firmwareFilename(firmware *models.Firmware) string {
+	var parts []string
+	for _, part := range []string{firmware.Board, firmware.Chip, firmware.Application, firmware.Version} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "_") + ".bin"
+}
+
+// encodeTags 去掉空白和重复的标签后编码为JSON数组
+func encodeTags(tags []string) ([]byte, error) {
+	cleaned := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("标签不能超过%d个字符", maxTagLength)
+		}
+		seen[tag] = true
+		cleaned = append(cleaned, tag)
+	}
+	if len(cleaned) > maxTags {
+		return nil, fmt.Errorf("标签不能超过%d个", maxTags)
+	}
+	return json.Marshal(cleaned)
+}
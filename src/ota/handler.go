@@ -1,21 +1,23 @@
 package ota
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
 	"os"
-	"path/filepath"
-	"sort"
-	"strings"
+	"strconv"
 	"time"
-	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
 	"xiaozhi-server-go/src/core/auth"
+	"xiaozhi-server-go/src/maintenance"
+	"xiaozhi-server-go/src/models"
 	"xiaozhi-server-go/src/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
-// OtaFirmwareResponse 定义OTA固件接口返回结构
+// OtaFirmwareResponse 定义OTA固件接口返回结构，firmware 中的 size、sha256 供设备校验下载的固件
 type OtaFirmwareResponse struct {
 	ServerTime struct {
 		Timestamp      int64 `json:"timestamp" example:"1688443200000"`
@@ -24,6 +26,8 @@ type OtaFirmwareResponse struct {
 	Firmware struct {
 		Version string `json:"version" example:"1.0.3"`
 		URL     string `json:"url" example:"/ota_bin/1.0.3.bin"`
+		Size    int64  `json:"size,omitempty" example:"2097152"`
+		SHA256  string `json:"sha256,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
 	} `json:"firmware"`
 	Websocket struct {
 		URL   string `json:"url" example:"wss://example.com/ota"`
@@ -37,17 +41,6 @@ type ErrorResponse struct {
 	Message string `json:"message" example:"缺少 device-id"`
 }
 
-// @Summary OTA 预检请求
-// @Description 处理 OTA 接口的 OPTIONS 预检请求，返回 200
-// @Tags OTA
-// @Accept */*
-// @Produce plain
-// @Success 200 {string} string "OK"
-// @Router /ota/ [options]
-func handleOtaOptions(c *gin.Context) {
-	c.Status(http.StatusOK)
-}
-
 // @Summary 获取 OTA 状态
 // @Description 获取 OTA 服务状态和 WebSocket 地址，供设备查询
 // @Tags OTA
@@ -58,15 +51,35 @@ func handleOtaGet(c *gin.Context, updateURL string) {
 	c.String(http.StatusOK, "OTA interface is running, websocket address: "+updateURL)
 }
 
-// 请求体结构体定义
+// 请求体结构体定义，只包含选择固件用到的字段
 type OtaRequest struct {
 	Application struct {
+		Name    string `json:"name" example:"xiaozhi"`
 		Version string `json:"version" example:"1.0.0"`
 	} `json:"application"`
+	Board struct {
+		Type string `json:"type" example:"bread-compact-wifi"`
+		Name string `json:"name" example:"bread-compact-wifi"`
+	} `json:"board"`
+	ChipModelName string `json:"chip_model_name" example:"esp32s3"`
+}
+
+// model 设备上报的型号信息，旧固件没有 board.type 时使用 board.name
+func (r *OtaRequest) model() deviceModel {
+	board := r.Board.Type
+	if board == "" {
+		board = r.Board.Name
+	}
+	return deviceModel{
+		Board:       board,
+		Chip:        r.ChipModelName,
+		Application: r.Application.Name,
+	}
 }
 
 // @Summary 上传设备信息获取最新固件
-// @Description 设备上传信息后，返回最新固件版本和下载地址
+// @Description 设备上传信息后，返回设备发布渠道内适用于其开发板、芯片和应用的最新固件版本和下载地址，
+// @Description 只有比设备上报的版本更新（按语义化版本比较）时才返回下载地址
 // @Tags OTA
 // @Accept json
 // @Produce json
@@ -74,8 +87,12 @@ type OtaRequest struct {
 // @Param body body OtaRequest true "请求体"
 // @Success 200 {object} OtaFirmwareResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
 // @Router /ota/ [post]
-func handleOtaPost(c *gin.Context, updateURL string, config *configs.Config) {
+func (s *DefaultOTAService) handleOtaPost(c *gin.Context) {
+	if rejectDuringMaintenance(c) {
+		return
+	}
 	deviceID := c.GetHeader("device-id")
 	if deviceID == "" {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Success: false, Message: "缺少 device-id"})
@@ -87,22 +104,39 @@ func handleOtaPost(c *gin.Context, updateURL string, config *configs.Config) {
 		return
 	}
 
-	version := body.Application.Version
+	reported := body.Application.Version
+	version := reported
 	if version == "" {
 		version = "1.0.0"
 	}
 
-	otaDir := filepath.Join(".", "ota_bin")
-	_ = os.MkdirAll(otaDir, 0755)
-	bins, _ := filepath.Glob(filepath.Join(otaDir, "*.bin"))
 	firmwareURL := ""
-	if len(bins) > 0 {
-		sort.Slice(bins, func(i, j int) bool {
-			return versionLess(bins[j], bins[i])
-		})
-		latest := filepath.Base(bins[0])
-		version = strings.TrimSuffix(latest, ".bin")
-		firmwareURL = "/ota_bin/" + latest
+	// 已登记的设备按其发布渠道、序列号和标签选择固件，未登记的设备使用稳定版
+	var device *models.Device
+	if database.DB != nil {
+		device, _ = service.NewDevice(s.Config).IdentifyDevice("", deviceID, c.GetHeader("client-id"))
+	}
+	channel := ChannelStable
+	target := rolloutTarget{DeviceID: deviceID}
+	if device != nil {
+		channel = normalizeChannel(device.Channel)
+		target.SerialNumber = device.SerialNumber
+		_ = json.Unmarshal(device.Tags, &target.Tags)
+	}
+
+	model := body.model()
+	latest, err := s.store.latest(database.DB, model, channel, target)
+	if err != nil {
+		logrus.WithError(err).Warn("查询最新固件失败")
+	} else if latest == nil {
+		logrus.WithFields(logrus.Fields{"device_id": deviceID, "board": model.Board, "chip": model.Chip, "channel": channel}).Debug("没有适用于设备的固件")
+	} else if reported != "" && !versionLess(reported, latest.Version) {
+		// 只下发比设备当前版本更新的固件，设备切换到较低的渠道时不会降级
+		logrus.WithFields(logrus.Fields{"device_id": deviceID, "version": reported, "latest": latest.Version}).Debug("设备固件已是最新")
+		latest = nil
+	} else {
+		version = latest.Version
+		firmwareURL = "/ota_bin/" + latest.Filename
 	}
 
 	resp := OtaFirmwareResponse{}
@@ -110,14 +144,16 @@ func handleOtaPost(c *gin.Context, updateURL string, config *configs.Config) {
 	resp.ServerTime.TimezoneOffset = 8 * 60
 	resp.Firmware.Version = version
 	resp.Firmware.URL = firmwareURL
-	resp.Websocket.URL = updateURL
+	if latest != nil {
+		resp.Firmware.Size = latest.Size
+		resp.Firmware.SHA256 = latest.SHA256
+	}
+	resp.Websocket.URL = s.UpdateURL
 
 	// 为已激活的设备生成token
-	deviceService := service.NewDevice(config)
-	clientID := c.GetHeader("client-id")
-	if device, err := deviceService.IdentifyDevice("", deviceID, clientID); err == nil && device != nil && device.Activated {
+	if device != nil && device.Activated {
 		// 设备已激活，生成新的token
-		authToken := auth.NewAuthToken(config.Server.Token)
+		authToken := auth.NewAuthToken(s.Config.Server.Token)
 		if token, err := authToken.GenerateToken(device.DeviceID); err == nil {
 			resp.Websocket.Token = token
 			logrus.WithField("device_id", deviceID).Info("为已激活设备生成了新token")
@@ -132,33 +168,63 @@ func handleOtaPost(c *gin.Context, updateURL string, config *configs.Config) {
 }
 
 // @Summary 下载 OTA 固件文件
-// @Description 根据文件名下载 OTA 固件
+// @Description 根据文件名下载 OTA 固件，支持 Range 断点续传和 ETag（固件SHA-256）条件请求，按 ota.download_rate_kb 限速
 // @Tags OTA
 // @Produce application/octet-stream
 // @Param filename path string true "固件文件名"
+// @Param Range header string false "下载范围，如 bytes=1048576-"
+// @Param If-None-Match header string false "已下载固件的ETag"
 // @Success 200 "文件流"
+// @Success 206 "部分文件流"
+// @Success 304 "固件未变化"
 // @Failure 404 {object} ErrorResponse
+// @Failure 416 "无效的下载范围"
+// @Failure 503 {object} ErrorResponse
 // @Router /ota_bin/{filename} [get]
-func handleOtaBinDownload(c *gin.Context) {
+func (s *DefaultOTAService) handleOtaBinDownload(c *gin.Context) {
+	if rejectDuringMaintenance(c) {
+		return
+	}
 	fname := c.Param("filename")
-	p := filepath.Join("ota_bin", fname)
-	if _, err := os.Stat(p); os.IsNotExist(err) {
+	p, err := s.store.localPath(database.DB, fname)
+	if err != nil {
+		if err != ErrFirmwareNotFound {
+			logrus.WithError(err).WithField("filename", fname).Warn("获取固件失败")
+		}
+		c.JSON(http.StatusNotFound, ErrorResponse{Success: false, Message: "file not found"})
+		return
+	}
+	file, err := os.Open(p)
+	if err != nil {
 		c.JSON(http.StatusNotFound, ErrorResponse{Success: false, Message: "file not found"})
 		return
 	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Success: false, Message: "file not found"})
+		return
+	}
+	if _, sum, err := fileChecksum(p); err == nil {
+		c.Header("ETag", `"`+sum+`"`)
+	}
 	c.Header("Content-Type", "application/octet-stream")
 	c.Header("Content-Disposition", "attachment; filename="+fname)
-	c.File(p)
+
+	// ServeContent 处理 Range、If-Range、If-None-Match 等条件请求
+	var content io.ReadSeeker = file
+	if rate := s.Config.OTA.DownloadRateKB; rate > 0 {
+		content = newThrottledReader(c.Request.Context(), file, int64(rate)<<10)
+	}
+	http.ServeContent(c.Writer, c.Request, fname, info.ModTime(), content)
 }
 
-// versionLess 比较版本号语义 a < b
-func versionLess(a, b string) bool {
-	aV := strings.Split(strings.TrimSuffix(filepath.Base(a), ".bin"), ".")
-	bV := strings.Split(strings.TrimSuffix(filepath.Base(b), ".bin"), ".")
-	for i := 0; i < len(aV) && i < len(bV); i++ {
-		if aV[i] != bV[i] {
-			return aV[i] < bV[i]
-		}
+// rejectDuringMaintenance 维护期间拒绝固件检查、激活和下载，设备按 Retry-After 稍后重试
+func rejectDuringMaintenance(c *gin.Context) bool {
+	if !maintenance.Active() {
+		return false
 	}
-	return len(aV) < len(bV)
+	c.Header("Retry-After", strconv.Itoa(maintenance.RetryAfter()))
+	c.JSON(http.StatusServiceUnavailable, ErrorResponse{Success: false, Message: maintenance.Current().Message})
+	return true
 }
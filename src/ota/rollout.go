@@ -0,0 +1,111 @@
+package ota
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/datatypes"
+)
+
+const maxSerialRanges = 20
+
+// Rollout 固件的分阶段发布规则，保存在 firmwares.rollout，没有规则时发布给渠道内的所有设备。
+// 带有指定标签或序列号在指定范围内的设备始终接收，其他设备按比例抽取
+type Rollout struct {
+	Percent int           `json:"percent"`           // 按设备ID散列抽取的设备比例（0-100），提高比例时已抽中的设备保持不变
+	Tags    []string      `json:"tags,omitempty"`    // 带有任一标签的设备
+	Serials []SerialRange `json:"serials,omitempty"` // 序列号在任一范围内的设备
+	Paused  bool          `json:"paused"`            // 暂停发布，发现问题时停止向所有设备推送
+}
+
+// SerialRange 序列号范围，包含两端，按字符串比较，两端应为同一格式
+type SerialRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// rolloutTarget 分阶段发布用来选择设备的信息，未登记的设备只有设备ID
+type rolloutTarget struct {
+	DeviceID     string
+	SerialNumber string
+	Tags         []string
+}
+
+// decodeRollout 解析固件的发布规则，没有规则时返回nil
+func decodeRollout(data datatypes.JSON) (*Rollout, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var rollout Rollout
+	if err := json.Unmarshal(data, &rollout); err != nil {
+		return nil, fmt.Errorf("解析发布规则失败: %v", err)
+	}
+	return &rollout, nil
+}
+
+// validate 校验发布规则并整理标签
+func (r *Rollout) validate() error {
+	if r.Percent < 0 || r.Percent > 100 {
+		return fmt.Errorf("发布比例应在0到100之间")
+	}
+	data, err := encodeTags(r.Tags)
+	if err != nil {
+		return err
+	}
+	r.Tags = nil
+	if err := json.Unmarshal(data, &r.Tags); err != nil {
+		return err
+	}
+	if len(r.Serials) > maxSerialRanges {
+		return fmt.Errorf("序列号范围不能超过%d个", maxSerialRanges)
+	}
+	for _, serials := range r.Serials {
+		if serials.From == "" || serials.To == "" || serials.From > serials.To {
+			return fmt.Errorf("无效的序列号范围: %s - %s", serials.From, serials.To)
+		}
+	}
+	return nil
+}
+
+// includes 设备是否在发布范围内
+func (r *Rollout) includes(firmware *models.Firmware, target rolloutTarget) bool {
+	if r.Paused {
+		return false
+	}
+	for _, tag := range r.Tags {
+		for _, deviceTag := range target.Tags {
+			if tag == deviceTag {
+				return true
+			}
+		}
+	}
+	if target.SerialNumber != "" {
+		for _, serials := range r.Serials {
+			if serials.From <= target.SerialNumber && target.SerialNumber <= serials.To {
+				return true
+			}
+		}
+	}
+	return rolloutBucket(firmware.Filename, target.DeviceID) < r.Percent
+}
+
+// rolloutIncludes 设备是否在固件的发布范围内，规则无法解析时按暂停处理
+func rolloutIncludes(firmware *models.Firmware, target rolloutTarget) bool {
+	rollout, err := decodeRollout(firmware.Rollout)
+	if err != nil {
+		return false
+	}
+	return rollout == nil || rollout.includes(firmware, target)
+}
+
+// rolloutBucket 设备在该固件发布中的分桶（0-99），同一设备对同一固件的分桶固定
+func rolloutBucket(filename, deviceID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(filename))
+	h.Write([]byte{0})
+	h.Write([]byte(deviceID))
+	return int(h.Sum32() % 100)
+}
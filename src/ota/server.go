@@ -3,13 +3,17 @@ package ota
 import (
 	"context"
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/router/admin"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 type DefaultOTAService struct {
 	UpdateURL string
 	Config    *configs.Config
+	store     *firmwareStore
 }
 
 // NewDefaultOTAService 构造函数
@@ -20,14 +24,41 @@ func NewDefaultOTAService(updateURL string, config *configs.Config) *DefaultOTAS
 	}
 }
 
-// Start 注册 OTA 相关路由
+// Start 注册 OTA 相关路由，配置管理员令牌时同时注册固件管理路由
 func (s *DefaultOTAService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
-	apiGroup.OPTIONS("/ota/", handleOtaOptions)
+	store, err := newFirmwareStore(&s.Config.OTA)
+	if err != nil {
+		return err
+	}
+	s.store = store
+	if database.DB != nil {
+		if err := store.importLocal(database.DB); err != nil {
+			logrus.WithError(err).Warn("登记固件目录中的固件失败")
+		}
+	}
+
 	apiGroup.GET("/ota/", func(c *gin.Context) { handleOtaGet(c, s.UpdateURL) })
-	apiGroup.POST("/ota/", func(c *gin.Context) { handleOtaPost(c, s.UpdateURL, s.Config) })
-	apiGroup.POST("/ota/activate", func(c *gin.Context) { handleOtaPost(c, s.UpdateURL, s.Config) })
+	apiGroup.POST("/ota/", s.handleOtaPost)
+	apiGroup.POST("/ota/activate", s.handleOtaPost)
 
-	engine.GET("/ota_bin/:filename", handleOtaBinDownload)
+	engine.GET("/ota_bin/:filename", s.handleOtaBinDownload)
+
+	if !admin.Enabled(s.Config) {
+		logrus.Info("未配置web.admin_token，固件管理接口未开放")
+		return nil
+	}
+	group := admin.Group(apiGroup, s.Config)
+	group.GET("/admin/firmwares", s.handleListFirmwares)
+	group.POST("/admin/firmwares", s.handleUploadFirmware)
+	group.PUT("/admin/firmwares/:id", s.handleUpdateFirmware)
+	group.PUT("/admin/firmwares/:id/rollout", s.handleSetRollout)
+	group.DELETE("/admin/firmwares/:id/rollout", s.handleClearRollout)
+	group.DELETE("/admin/firmwares/:id", s.handleDeleteFirmware)
+	group.GET("/devices/:device_id/ota_channel", s.handleGetDeviceChannel)
+	group.PUT("/devices/:device_id/ota_channel", s.handleSetDeviceChannel)
+	group.GET("/devices/:device_id/tags", s.handleGetDeviceTags)
+	group.PUT("/devices/:device_id/tags", s.handleSetDeviceTags)
 
+	logrus.Info("固件管理HTTP服务路由注册完成")
 	return nil
 }
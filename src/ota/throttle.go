@@ -0,0 +1,48 @@
+package ota
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// throttledReader 按固定速率读取文件，限制单个固件下载占用的带宽。
+// Seek 后重新计时，断点续传的请求同样限速
+type throttledReader struct {
+	ctx   context.Context
+	src   io.ReadSeeker
+	rate  int64 // 每秒字节数
+	start time.Time
+	read  int64
+}
+
+func newThrottledReader(ctx context.Context, src io.ReadSeeker, rate int64) *throttledReader {
+	return &throttledReader{ctx: ctx, src: src, rate: rate, start: time.Now()}
+}
+
+// Read 每次最多读取0.1秒的额度，读取超前时等待
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if chunk := r.rate / 10; chunk > 0 && int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := r.src.Read(p)
+	r.read += int64(n)
+	wait := time.Duration(r.read)*time.Second/time.Duration(r.rate) - time.Since(r.start)
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-r.ctx.Done():
+			return n, r.ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return n, err
+}
+
+// Seek 实现 io.Seeker，供 http.ServeContent 处理 Range 请求
+func (r *throttledReader) Seek(offset int64, whence int) (int64, error) {
+	r.start = time.Now()
+	r.read = 0
+	return r.src.Seek(offset, whence)
+}
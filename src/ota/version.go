@@ -0,0 +1,109 @@
+package ota
+
+import (
+	"strconv"
+	"strings"
+)
+
+// semver 解析后的版本号，核心部分个数不限，1.0 与 1.0.0 相等
+type semver struct {
+	core       []int
+	prerelease []string
+}
+
+// parseVersion 按语义化版本解析，允许 v 前缀，忽略 + 之后的构建信息
+func parseVersion(version string) (semver, bool) {
+	version = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(version), "v"), "V")
+	version, _, _ = strings.Cut(version, "+")
+	version, prerelease, hasPrerelease := strings.Cut(version, "-")
+
+	var v semver
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		v.core = append(v.core, n)
+	}
+	if hasPrerelease {
+		if prerelease == "" {
+			return semver{}, false
+		}
+		v.prerelease = strings.Split(prerelease, ".")
+	}
+	return v, true
+}
+
+// compareVersions 比较两个版本号，a < b 返回负数，相等返回0，a > b 返回正数。
+// 无法解析的版本号低于所有合法版本号，之间按字符串比较
+func compareVersions(a, b string) int {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	switch {
+	case !okA && !okB:
+		return strings.Compare(a, b)
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+
+	for i := 0; i < len(va.core) || i < len(vb.core); i++ {
+		var x, y int
+		if i < len(va.core) {
+			x = va.core[i]
+		}
+		if i < len(vb.core) {
+			y = vb.core[i]
+		}
+		if x != y {
+			return compareInt(x, y)
+		}
+	}
+
+	// 预发布版本低于正式版本，如 1.0.0-beta.2 < 1.0.0
+	switch {
+	case len(va.prerelease) == 0 && len(vb.prerelease) == 0:
+		return 0
+	case len(va.prerelease) == 0:
+		return 1
+	case len(vb.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(va.prerelease) && i < len(vb.prerelease); i++ {
+		if c := comparePrerelease(va.prerelease[i], vb.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(va.prerelease), len(vb.prerelease))
+}
+
+// comparePrerelease 比较预发布标识：数字按数值比较且低于非数字标识，非数字按字符串比较
+func comparePrerelease(a, b string) int {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return compareInt(x, y)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInt(x, y int) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// versionLess 比较版本号语义 a < b
+func versionLess(a, b string) bool {
+	return compareVersions(a, b) < 0
+}
@@ -0,0 +1,59 @@
+package ota
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected int
+	}{
+		{name: "相同版本", a: "1.0.0", b: "1.0.0", expected: 0},
+		{name: "按数值比较而不是字符串", a: "1.0.10", b: "1.0.9", expected: 1},
+		{name: "次版本号", a: "1.2.0", b: "1.10.0", expected: -1},
+		{name: "主版本号", a: "2.0.0", b: "1.99.99", expected: 1},
+		{name: "缺省的核心部分视为0", a: "1.0", b: "1.0.0", expected: 0},
+		{name: "更长的核心部分", a: "1.0.0.1", b: "1.0.0", expected: 1},
+		{name: "v前缀", a: "v1.2.3", b: "1.2.3", expected: 0},
+		{name: "预发布低于正式版本", a: "1.0.0-beta", b: "1.0.0", expected: -1},
+		{name: "正式版本高于预发布", a: "1.0.0", b: "1.0.0-rc.1", expected: 1},
+		{name: "预发布数字标识按数值比较", a: "1.0.0-beta.2", b: "1.0.0-beta.11", expected: -1},
+		{name: "预发布非数字标识按字符串比较", a: "1.0.0-alpha", b: "1.0.0-beta", expected: -1},
+		{name: "数字标识低于非数字标识", a: "1.0.0-1", b: "1.0.0-alpha", expected: -1},
+		{name: "预发布标识多的更高", a: "1.0.0-alpha.1", b: "1.0.0-alpha", expected: 1},
+		{name: "忽略构建信息", a: "1.0.0+20240101", b: "1.0.0+20250101", expected: 0},
+		{name: "预发布加构建信息", a: "1.0.0-beta+build.5", b: "1.0.0-beta", expected: 0},
+		{name: "旧预发布低于新正式版本", a: "1.0.1-beta", b: "1.0.0", expected: 1},
+		{name: "无法解析的版本低于合法版本", a: "nightly", b: "0.0.1", expected: -1},
+		{name: "合法版本高于无法解析的版本", a: "0.0.1", b: "1.x", expected: 1},
+		{name: "都无法解析时按字符串比较", a: "abc", b: "abd", expected: -1},
+		{name: "空的预发布标识无法解析", a: "1.0.0-", b: "1.0.0", expected: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareVersions(tt.a, tt.b); got != tt.expected {
+				t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.expected)
+			}
+			// 比较结果必须对称
+			if got := compareVersions(tt.b, tt.a); got != -tt.expected {
+				t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.expected)
+			}
+		})
+	}
+}
+
+func TestVersionLessSort(t *testing.T) {
+	versions := []string{"1.0.10", "1.0.0", "1.0.0-rc.1", "1.0.9", "1.0.0-beta.2", "1.0.0-beta.11", "0.9"}
+	expected := []string{"0.9", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.9", "1.0.10"}
+
+	sort.Slice(versions, func(i, j int) bool { return versionLess(versions[i], versions[j]) })
+	for i := range expected {
+		if versions[i] != expected[i] {
+			t.Fatalf("排序结果 %v, want %v", versions, expected)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package protocol
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// DialOptions 连接参数
+type DialOptions struct {
+	DeviceID    string       // 设备ID（必填）
+	ClientID    string       // 客户端ID
+	Token       string       // 设备令牌，非空时以 Bearer 方式发送
+	SessionID   string       // 指定会话ID，为空由服务端生成
+	AudioParams *AudioParams // 上行音频参数
+	DisableV2   bool         // 不启用事件协议，使用v1明文消息
+	LastSeq     int64        // 重连前已收到的最大序号，用于重发未确认事件
+}
+
+// Incoming 收到的一条下行数据，Audio 非空时为二进制音频帧
+type Incoming struct {
+	Message Message
+	Event   *EventEnvelope // 事件协议下的原始信封，v1为nil
+	Audio   []byte
+}
+
+// Client 小智 WebSocket 客户端
+type Client struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	lastSeq int64
+	hello   *ServerHello
+}
+
+// Dial 建立连接并完成 hello 握手
+func Dial(ctx context.Context, url string, opts DialOptions) (*Client, error) {
+	if opts.DeviceID == "" {
+		return nil, fmt.Errorf("缺少设备ID")
+	}
+	header := http.Header{}
+	header.Set("Device-Id", opts.DeviceID)
+	if opts.ClientID != "" {
+		header.Set("Client-Id", opts.ClientID)
+	}
+	if opts.Token != "" {
+		header.Set("Authorization", "Bearer "+opts.Token)
+	}
+	if opts.SessionID != "" {
+		header.Set("Session-Id", opts.SessionID)
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		return nil, fmt.Errorf("连接失败: %v", err)
+	}
+	c := &Client{conn: conn, lastSeq: opts.LastSeq}
+
+	version := Version
+	if opts.DisableV2 {
+		version = 1
+	}
+	if err := c.Send(ClientHello{
+		Version:     version,
+		Transport:   "websocket",
+		AudioParams: opts.AudioParams,
+		LastSeq:     opts.LastSeq,
+	}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	for c.hello == nil {
+		in, err := c.Receive()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("等待服务端hello失败: %v", err)
+		}
+		if hello, ok := in.Message.(*ServerHello); ok {
+			c.hello = hello
+		}
+	}
+	return c, nil
+}
+
+// Hello 服务端hello消息
+func (c *Client) Hello() *ServerHello {
+	return c.hello
+}
+
+// Send 发送上行消息
+func (c *Client) Send(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化%s消息失败: %v", msg.MessageType(), err)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// SendAudio 发送一帧上行音频
+func (c *Client) SendAudio(frame []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+// Receive 阻塞读取下一条下行数据，事件协议下自动解开信封并确认关键事件
+// 服务端新增了SDK未知的消息类型时，Message 为nil
+func (c *Client) Receive() (*Incoming, error) {
+	messageType, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	if messageType == websocket.BinaryMessage {
+		return &Incoming{Audio: data}, nil
+	}
+
+	var envelope EventEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("解析下行消息失败: %v", err)
+	}
+	if envelope.V < 2 || envelope.Seq == 0 {
+		// v1 明文消息
+		msg, _ := DecodeServerMessage(envelope.Type, data)
+		return &Incoming{Message: msg}, nil
+	}
+
+	if envelope.Seq > atomic.LoadInt64(&c.lastSeq) {
+		atomic.StoreInt64(&c.lastSeq, envelope.Seq)
+	}
+	if envelope.Ack {
+		if err := c.Send(Ack{Seq: atomic.LoadInt64(&c.lastSeq)}); err != nil {
+			return nil, fmt.Errorf("确认事件失败: %v", err)
+		}
+	}
+	payload, err := json.Marshal(envelope.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化事件内容失败: %v", err)
+	}
+	msg, _ := DecodeServerMessage(envelope.Type, payload)
+	return &Incoming{Message: msg, Event: &envelope}, nil
+}
+
+// LastSeq 已收到的最大事件序号，重连时传给 DialOptions.LastSeq
+func (c *Client) LastSeq() int64 {
+	return atomic.LoadInt64(&c.lastSeq)
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,848 @@
+// Code generated by src/protocol/gen from src/protocol/protocol.yaml. DO NOT EDIT.
+
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version 协议版本
+const Version = 2
+
+// 消息类型
+const (
+	TypeHello     = "hello"
+	TypeListen    = "listen"
+	TypeAbort     = "abort"
+	TypeInterrupt = "interrupt"
+	TypeChat      = "chat"
+	TypeImage     = "image"
+	TypeIOT       = "iot"
+	TypeRole      = "role"
+	TypeTranslate = "translate"
+	TypeMCP       = "mcp"
+	TypeSession   = "session"
+	TypeTTSConfig = "tts_config"
+	TypePower     = "power"
+	TypeContext   = "context"
+	TypeFeedback  = "feedback"
+	TypeAck       = "ack"
+	TypeMusic     = "music"
+	TypeSTT       = "stt"
+	TypeLLM       = "llm"
+	TypeTTS       = "tts"
+	TypeIntercom  = "intercom"
+	TypeWakeword  = "wakeword"
+	TypeAlert     = "alert"
+	TypeSystem    = "system"
+)
+
+// AudioParams 音频参数
+type AudioParams struct {
+	Format        string        `json:"format,omitempty"`         // 编码格式：opus 或 pcm，服务端下行还可能是 wav 或 mp3
+	SampleRate    int           `json:"sample_rate,omitempty"`    // 采样率
+	Channels      int           `json:"channels,omitempty"`       // 声道数
+	FrameDuration int           `json:"frame_duration,omitempty"` // 帧时长（毫秒）
+	OutputFormats []AudioFormat `json:"output_formats,omitempty"` // 仅客户端，按优先顺序声明支持的下行音频格式，服务端选择第一个支持的格式
+}
+
+// AudioFormat 下行音频格式
+type AudioFormat struct {
+	Format     string `json:"format"`                // 编码格式：opus、pcm、wav（每帧为完整WAV）或 mp3（MP3字节流）
+	SampleRate int    `json:"sample_rate,omitempty"` // 采样率，Opus 支持 8000/12000/16000/24000/48000
+	Channels   int    `json:"channels,omitempty"`    // 声道数，服务端只输出单声道
+}
+
+// ImageData 图片数据，url 与 data 二选一
+type ImageData struct {
+	URL    string `json:"url,omitempty"`    // 图片地址
+	Data   string `json:"data,omitempty"`   // Base64编码的图片内容
+	Format string `json:"format,omitempty"` // 图片格式，如 jpeg、png
+}
+
+// EventEnvelope 事件协议（v2）下行信封，hello 中协商 version>=2 后所有下行文本消息均使用该格式
+type EventEnvelope struct {
+	V          int                    `json:"v"`                    // 协议版本
+	Type       string                 `json:"type"`                 // 原消息类型
+	Seq        int64                  `json:"seq"`                  // 设备内单调递增的序号，断线重连后延续
+	TurnID     int                    `json:"turn_id"`              // 对话轮次
+	Ack        bool                   `json:"ack,omitempty"`        // 为 true 时客户端需回复 ack 消息
+	Retransmit bool                   `json:"retransmit,omitempty"` // 重连后重发的未确认事件
+	Payload    map[string]interface{} `json:"payload"`              // 原消息除 type 以外的字段
+}
+
+// ClientHello 连接建立后客户端发送的第一条消息（上行，type=hello）
+type ClientHello struct {
+	Version     int          `json:"version,omitempty"`      // 协议版本，2 表示启用事件协议
+	Transport   string       `json:"transport,omitempty"`    // 传输方式，固定为 websocket
+	AudioParams *AudioParams `json:"audio_params,omitempty"` // 客户端上行音频参数
+	LastSeq     int64        `json:"last_seq,omitempty"`     // 事件协议下重连前已收到的最大序号
+}
+
+// MessageType Message接口实现
+func (ClientHello) MessageType() string { return TypeHello }
+
+// MarshalJSON 序列化时附加type字段
+func (m ClientHello) MarshalJSON() ([]byte, error) {
+	type alias ClientHello
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeHello, alias(m)})
+}
+
+// Listen 拾音控制；mode 为 text 时是文字聊天，直接把 text 交给对话流程，不需要 state（上行，type=listen）
+type Listen struct {
+	State    string `json:"state,omitempty"`     // start、stop 或 detect（唤醒词检测），文字聊天时不需要
+	Mode     string `json:"mode,omitempty"`      // 拾音模式：auto、manual、realtime、wakeword（服务端检测唤醒词）或 text（文字聊天）
+	Text     string `json:"text,omitempty"`      // detect 时识别到的唤醒词文本，文字聊天时为用户输入的文本
+	TextOnly bool   `json:"text_only,omitempty"` // 文字聊天时不合成回复语音，只通过 tts 消息的 sentence_start 下发回复文本
+}
+
+// MessageType Message接口实现
+func (Listen) MessageType() string { return TypeListen }
+
+// MarshalJSON 序列化时附加type字段
+func (m Listen) MarshalJSON() ([]byte, error) {
+	type alias Listen
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeListen, alias(m)})
+}
+
+// Abort 打断当前播报（上行，type=abort）
+type Abort struct {
+	Reason string `json:"reason,omitempty"` // 打断原因，如 wake_word_detected
+}
+
+// MessageType Message接口实现
+func (Abort) MessageType() string { return TypeAbort }
+
+// MarshalJSON 序列化时附加type字段
+func (m Abort) MarshalJSON() ([]byte, error) {
+	type alias Abort
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeAbort, alias(m)})
+}
+
+// Interrupt 设备按键打断，服务端立即取消当前LLM请求与语音合成、清空待播放音频，不依赖语音打断（上行，type=interrupt）
+type Interrupt struct {
+	Reason string `json:"reason,omitempty"` // 打断原因，缺省为 button
+}
+
+// MessageType Message接口实现
+func (Interrupt) MessageType() string { return TypeInterrupt }
+
+// MarshalJSON 序列化时附加type字段
+func (m Interrupt) MarshalJSON() ([]byte, error) {
+	type alias Interrupt
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeInterrupt, alias(m)})
+}
+
+// Chat 直接发送文本对话（上行，type=chat）
+type Chat struct {
+	Text string `json:"text"` // 用户文本
+}
+
+// MessageType Message接口实现
+func (Chat) MessageType() string { return TypeChat }
+
+// MarshalJSON 序列化时附加type字段
+func (m Chat) MarshalJSON() ([]byte, error) {
+	type alias Chat
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeChat, alias(m)})
+}
+
+// Image 发送图片并提问，需要配置VLLLM（上行，type=image）
+type Image struct {
+	Text      string    `json:"text,omitempty"` // 提问内容，缺省为“请描述这张图片”
+	ImageData ImageData `json:"image_data"`     // 图片数据
+}
+
+// MessageType Message接口实现
+func (Image) MessageType() string { return TypeImage }
+
+// MarshalJSON 序列化时附加type字段
+func (m Image) MarshalJSON() ([]byte, error) {
+	type alias Image
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeImage, alias(m)})
+}
+
+// IoT 上报IoT外设描述与状态，外设的方法注册为LLM函数，同名外设重复上报时覆盖（上行，type=iot）
+type IoT struct {
+	Update      bool                     `json:"update,omitempty"`      // 为 true 时 states 只包含变化的属性
+	Descriptors []map[string]interface{} `json:"descriptors,omitempty"` // 外设描述 {name, description, properties, methods}
+	States      []map[string]interface{} `json:"states,omitempty"`      // 外设状态 {name, state}
+}
+
+// MessageType Message接口实现
+func (IoT) MessageType() string { return TypeIOT }
+
+// MarshalJSON 序列化时附加type字段
+func (m IoT) MarshalJSON() ([]byte, error) {
+	type alias IoT
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeIOT, alias(m)})
+}
+
+// RoleSwitch 切换数据库中的助手角色，切换结果保存在设备个性化设置中，服务端回复 role 消息（上行，type=role）
+type RoleSwitch struct {
+	Name string `json:"name,omitempty"` // 角色名称，为空或“默认”时恢复默认角色
+}
+
+// MessageType Message接口实现
+func (RoleSwitch) MessageType() string { return TypeRole }
+
+// MarshalJSON 序列化时附加type字段
+func (m RoleSwitch) MarshalJSON() ([]byte, error) {
+	type alias RoleSwitch
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeRole, alias(m)})
+}
+
+// Translate 开始或结束同声传译模式，服务端回复 translate 消息（上行，type=translate）
+type Translate struct {
+	Action string `json:"action"`           // start 或 stop
+	Target string `json:"target,omitempty"` // 目标语言，如“日语”，为空时使用配置的默认语言
+}
+
+// MessageType Message接口实现
+func (Translate) MessageType() string { return TypeTranslate }
+
+// MarshalJSON 序列化时附加type字段
+func (m Translate) MarshalJSON() ([]byte, error) {
+	type alias Translate
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeTranslate, alias(m)})
+}
+
+// ClientMCP 设备端MCP（JSON-RPC 2.0）响应（上行，type=mcp）
+type ClientMCP struct {
+	SessionID string                 `json:"session_id,omitempty"` // 会话ID
+	Payload   map[string]interface{} `json:"payload"`              // JSON-RPC 消息
+}
+
+// MessageType Message接口实现
+func (ClientMCP) MessageType() string { return TypeMCP }
+
+// MarshalJSON 序列化时附加type字段
+func (m ClientMCP) MarshalJSON() ([]byte, error) {
+	type alias ClientMCP
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeMCP, alias(m)})
+}
+
+// SessionControl 会话转移与接续（上行，type=session）
+type SessionControl struct {
+	Action         string `json:"action"`                     // transfer 或 resume
+	TargetDeviceID string `json:"target_device_id,omitempty"` // transfer 时的目标设备ID
+}
+
+// MessageType Message接口实现
+func (SessionControl) MessageType() string { return TypeSession }
+
+// MarshalJSON 序列化时附加type字段
+func (m SessionControl) MarshalJSON() ([]byte, error) {
+	type alias SessionControl
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeSession, alias(m)})
+}
+
+// TTSConfig 调整当前连接的音色与语速（上行，type=tts_config）
+type TTSConfig struct {
+	Voice string  `json:"voice,omitempty"` // 音色名称
+	Speed float64 `json:"speed,omitempty"` // 语速倍率
+}
+
+// MessageType Message接口实现
+func (TTSConfig) MessageType() string { return TypeTTSConfig }
+
+// MarshalJSON 序列化时附加type字段
+func (m TTSConfig) MarshalJSON() ([]byte, error) {
+	type alias TTSConfig
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeTTSConfig, alias(m)})
+}
+
+// Power 休眠与唤醒（上行，type=power）
+type Power struct {
+	State string `json:"state"` // sleep、wake 或 keepalive
+}
+
+// MessageType Message接口实现
+func (Power) MessageType() string { return TypePower }
+
+// MarshalJSON 序列化时附加type字段
+func (m Power) MarshalJSON() ([]byte, error) {
+	type alias Power
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypePower, alias(m)})
+}
+
+// DeviceContext 上报设备运行时信息，作为提示词模板变量，只需上报变化的字段（上行，type=context）
+type DeviceContext struct {
+	DeviceName string `json:"device_name,omitempty"` // 设备名称
+	Nickname   string `json:"nickname,omitempty"`    // 用户昵称
+	Location   string `json:"location,omitempty"`    // 所在位置
+	Battery    int    `json:"battery,omitempty"`     // 电量百分比
+}
+
+// MessageType Message接口实现
+func (DeviceContext) MessageType() string { return TypeContext }
+
+// MarshalJSON 序列化时附加type字段
+func (m DeviceContext) MarshalJSON() ([]byte, error) {
+	type alias DeviceContext
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeContext, alias(m)})
+}
+
+// Feedback 评价助手的上一条回复，通常由设备按键触发（上行，type=feedback）
+type Feedback struct {
+	Rating  string `json:"rating"`            // good 或 bad
+	Comment string `json:"comment,omitempty"` // 评价备注
+}
+
+// MessageType Message接口实现
+func (Feedback) MessageType() string { return TypeFeedback }
+
+// MarshalJSON 序列化时附加type字段
+func (m Feedback) MarshalJSON() ([]byte, error) {
+	type alias Feedback
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeFeedback, alias(m)})
+}
+
+// Ack 事件协议下确认关键事件，累计确认序号不大于 seq 的事件（上行，type=ack）
+type Ack struct {
+	Seq int64 `json:"seq"` // 已处理的最大序号
+}
+
+// MessageType Message接口实现
+func (Ack) MessageType() string { return TypeAck }
+
+// MarshalJSON 序列化时附加type字段
+func (m Ack) MarshalJSON() ([]byte, error) {
+	type alias Ack
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeAck, alias(m)})
+}
+
+// MusicControl 控制正在播放的音乐，通常由设备按键触发（上行，type=music）
+type MusicControl struct {
+	Action string `json:"action"` // pause、resume、skip 或 stop
+}
+
+// MessageType Message接口实现
+func (MusicControl) MessageType() string { return TypeMusic }
+
+// MarshalJSON 序列化时附加type字段
+func (m MusicControl) MarshalJSON() ([]byte, error) {
+	type alias MusicControl
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeMusic, alias(m)})
+}
+
+// ServerHello 服务端对客户端 hello 的回复（下行，type=hello）
+type ServerHello struct {
+	Version     int         `json:"version"`      // 协商后的协议版本
+	Transport   string      `json:"transport"`    // 传输方式
+	SessionID   string      `json:"session_id"`   // 会话ID
+	AudioParams AudioParams `json:"audio_params"` // 服务端下行音频参数
+}
+
+// MessageType Message接口实现
+func (ServerHello) MessageType() string { return TypeHello }
+
+// MarshalJSON 序列化时附加type字段
+func (m ServerHello) MarshalJSON() ([]byte, error) {
+	type alias ServerHello
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeHello, alias(m)})
+}
+
+// STT 语音识别结果（下行，type=stt）
+type STT struct {
+	Text      string `json:"text"`                 // 识别文本
+	SessionID string `json:"session_id,omitempty"` // 会话ID
+}
+
+// MessageType Message接口实现
+func (STT) MessageType() string { return TypeSTT }
+
+// MarshalJSON 序列化时附加type字段
+func (m STT) MarshalJSON() ([]byte, error) {
+	type alias STT
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeSTT, alias(m)})
+}
+
+// LLM 回复情绪（下行，type=llm）
+type LLM struct {
+	Text      string `json:"text,omitempty"`       // 情绪对应的表情
+	Emotion   string `json:"emotion"`              // 情绪名称
+	SessionID string `json:"session_id,omitempty"` // 会话ID
+}
+
+// MessageType Message接口实现
+func (LLM) MessageType() string { return TypeLLM }
+
+// MarshalJSON 序列化时附加type字段
+func (m LLM) MarshalJSON() ([]byte, error) {
+	type alias LLM
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeLLM, alias(m)})
+}
+
+// TTS 语音播报状态，音频数据通过二进制帧下发（下行，type=tts）
+type TTS struct {
+	State      string `json:"state"`                 // start、sentence_start、sentence_end 或 stop
+	Text       string `json:"text,omitempty"`        // 当前句子文本
+	Index      int    `json:"index,omitempty"`       // 句子序号
+	AudioCodec string `json:"audio_codec,omitempty"` // 音频编码：opus、pcm、wav 或 mp3
+	Emotion    string `json:"emotion,omitempty"`     // 本句回复的情绪，仅 sentence_start 携带
+	SessionID  string `json:"session_id,omitempty"`  // 会话ID
+}
+
+// MessageType Message接口实现
+func (TTS) MessageType() string { return TypeTTS }
+
+// MarshalJSON 序列化时附加type字段
+func (m TTS) MarshalJSON() ([]byte, error) {
+	type alias TTS
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeTTS, alias(m)})
+}
+
+// ServerMCP 服务端发起的MCP（JSON-RPC 2.0）请求（下行，type=mcp）
+type ServerMCP struct {
+	SessionID string                 `json:"session_id,omitempty"` // 会话ID
+	Payload   map[string]interface{} `json:"payload"`              // JSON-RPC 消息
+}
+
+// MessageType Message接口实现
+func (ServerMCP) MessageType() string { return TypeMCP }
+
+// MarshalJSON 序列化时附加type字段
+func (m ServerMCP) MarshalJSON() ([]byte, error) {
+	type alias ServerMCP
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeMCP, alias(m)})
+}
+
+// SessionState 会话转移结果（下行，type=session）
+type SessionState struct {
+	State          string `json:"state"`                      // transferred、resumed 或 error
+	SessionID      string `json:"session_id,omitempty"`       // 会话ID
+	TargetDeviceID string `json:"target_device_id,omitempty"` // transferred 时的目标设备
+	FromDeviceID   string `json:"from_device_id,omitempty"`   // resumed 时的来源设备
+	MessageCount   int    `json:"message_count,omitempty"`    // resumed 时接续的消息数
+	Message        string `json:"message,omitempty"`          // error 时的错误信息
+}
+
+// MessageType Message接口实现
+func (SessionState) MessageType() string { return TypeSession }
+
+// MarshalJSON 序列化时附加type字段
+func (m SessionState) MarshalJSON() ([]byte, error) {
+	type alias SessionState
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeSession, alias(m)})
+}
+
+// TTSConfigResult 音色/语速调整结果（下行，type=tts_config）
+type TTSConfigResult struct {
+	State     string  `json:"state"`                // success 或 error
+	SessionID string  `json:"session_id,omitempty"` // 会话ID
+	Voice     string  `json:"voice,omitempty"`      // 当前音色
+	Speed     float64 `json:"speed,omitempty"`      // 当前语速
+	Message   string  `json:"message,omitempty"`    // error 时的错误信息
+}
+
+// MessageType Message接口实现
+func (TTSConfigResult) MessageType() string { return TypeTTSConfig }
+
+// MarshalJSON 序列化时附加type字段
+func (m TTSConfigResult) MarshalJSON() ([]byte, error) {
+	type alias TTSConfigResult
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeTTSConfig, alias(m)})
+}
+
+// FeedbackResult 评价结果（下行，type=feedback）
+type FeedbackResult struct {
+	State     string `json:"state"`                // success 或 error
+	SessionID string `json:"session_id,omitempty"` // 会话ID
+	TurnID    int    `json:"turn_id,omitempty"`    // 被评价的对话记录ID
+	Rating    string `json:"rating,omitempty"`     // 记录的评价
+	Message   string `json:"message,omitempty"`    // error 时的错误信息
+}
+
+// MessageType Message接口实现
+func (FeedbackResult) MessageType() string { return TypeFeedback }
+
+// MarshalJSON 序列化时附加type字段
+func (m FeedbackResult) MarshalJSON() ([]byte, error) {
+	type alias FeedbackResult
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeFeedback, alias(m)})
+}
+
+// PowerState 休眠状态（下行，type=power）
+type PowerState struct {
+	State             string `json:"state"`                        // sleep、wake、keepalive 或 error
+	SessionID         string `json:"session_id,omitempty"`         // 会话ID
+	KeepaliveInterval int    `json:"keepalive_interval,omitempty"` // 休眠期间心跳间隔（秒）
+	Message           string `json:"message,omitempty"`            // error 时的错误信息
+}
+
+// MessageType Message接口实现
+func (PowerState) MessageType() string { return TypePower }
+
+// MarshalJSON 序列化时附加type字段
+func (m PowerState) MarshalJSON() ([]byte, error) {
+	type alias PowerState
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypePower, alias(m)})
+}
+
+// Intercom 来自同组设备的对讲消息（下行，type=intercom）
+type Intercom struct {
+	From         string `json:"from"`           // 来源设备名称
+	FromDeviceID string `json:"from_device_id"` // 来源设备ID
+	Text         string `json:"text"`           // 对讲内容
+}
+
+// MessageType Message接口实现
+func (Intercom) MessageType() string { return TypeIntercom }
+
+// MarshalJSON 序列化时附加type字段
+func (m Intercom) MarshalJSON() ([]byte, error) {
+	type alias Intercom
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeIntercom, alias(m)})
+}
+
+// InterruptResult 打断已完成，之后收到的旧轮次音频均可丢弃（下行，type=interrupt）
+type InterruptResult struct {
+	State     string `json:"state"`                // 固定为 done
+	TurnID    int    `json:"turn_id"`              // 被打断的对话轮次
+	SessionID string `json:"session_id,omitempty"` // 会话ID
+}
+
+// MessageType Message接口实现
+func (InterruptResult) MessageType() string { return TypeInterrupt }
+
+// MarshalJSON 序列化时附加type字段
+func (m InterruptResult) MarshalJSON() ([]byte, error) {
+	type alias InterruptResult
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeInterrupt, alias(m)})
+}
+
+// AbortNotice 播报期间服务端VAD检测到用户开口，已取消本轮回复，设备应立即停止播放并继续拾音（下行，type=abort）
+type AbortNotice struct {
+	Reason    string `json:"reason"`               // 中止原因，语音打断时为 barge_in
+	SessionID string `json:"session_id,omitempty"` // 会话ID
+}
+
+// MessageType Message接口实现
+func (AbortNotice) MessageType() string { return TypeAbort }
+
+// MarshalJSON 序列化时附加type字段
+func (m AbortNotice) MarshalJSON() ([]byte, error) {
+	type alias AbortNotice
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeAbort, alias(m)})
+}
+
+// WakeWord 服务端在 wakeword 拾音模式下检测到唤醒词，之后按 auto 模式拾音（下行，type=wakeword）
+type WakeWord struct {
+	State     string `json:"state"`                // 固定为 detected
+	Keyword   string `json:"keyword"`              // 检测到的唤醒词
+	SessionID string `json:"session_id,omitempty"` // 会话ID
+}
+
+// MessageType Message接口实现
+func (WakeWord) MessageType() string { return TypeWakeword }
+
+// MarshalJSON 序列化时附加type字段
+func (m WakeWord) MarshalJSON() ([]byte, error) {
+	type alias WakeWord
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeWakeword, alias(m)})
+}
+
+// Alert 自动化规则推送的通知（下行，type=alert）
+type Alert struct {
+	Status  string `json:"status,omitempty"`  // 标题
+	Message string `json:"message"`           // 内容
+	Emotion string `json:"emotion,omitempty"` // 展示情绪
+}
+
+// MessageType Message接口实现
+func (Alert) MessageType() string { return TypeAlert }
+
+// MarshalJSON 序列化时附加type字段
+func (m Alert) MarshalJSON() ([]byte, error) {
+	type alias Alert
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeAlert, alias(m)})
+}
+
+// SystemCommand 服务端下发的系统指令，reboot 时设备重启，重启后请求OTA接口检查固件更新（下行，type=system）
+type SystemCommand struct {
+	Command string `json:"command"` // 指令，目前只有 reboot
+}
+
+// MessageType Message接口实现
+func (SystemCommand) MessageType() string { return TypeSystem }
+
+// MarshalJSON 序列化时附加type字段
+func (m SystemCommand) MarshalJSON() ([]byte, error) {
+	type alias SystemCommand
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeSystem, alias(m)})
+}
+
+// IoTCommand 下发IoT外设指令，设备执行后上报新的状态（下行，type=iot）
+type IoTCommand struct {
+	SessionID string                   `json:"session_id,omitempty"` // 会话ID
+	Commands  []map[string]interface{} `json:"commands"`             // 指令 {name, method, parameters}
+}
+
+// MessageType Message接口实现
+func (IoTCommand) MessageType() string { return TypeIOT }
+
+// MarshalJSON 序列化时附加type字段
+func (m IoTCommand) MarshalJSON() ([]byte, error) {
+	type alias IoTCommand
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeIOT, alias(m)})
+}
+
+// RoleStatus 设备当前的角色，切换角色后下发（下行，type=role）
+type RoleStatus struct {
+	SessionID string `json:"session_id,omitempty"` // 会话ID
+	Name      string `json:"name"`                 // 角色名称，默认角色为“默认”
+	RoleID    int    `json:"role_id,omitempty"`    // 角色ID，默认角色时不下发
+}
+
+// MessageType Message接口实现
+func (RoleStatus) MessageType() string { return TypeRole }
+
+// MarshalJSON 序列化时附加type字段
+func (m RoleStatus) MarshalJSON() ([]byte, error) {
+	type alias RoleStatus
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeRole, alias(m)})
+}
+
+// TranslateStatus 同声传译模式的状态，开始或结束翻译后下发（下行，type=translate）
+type TranslateStatus struct {
+	SessionID string `json:"session_id,omitempty"` // 会话ID
+	State     string `json:"state"`                // started 或 stopped
+	Target    string `json:"target,omitempty"`     // 目标语言，翻译中时下发
+}
+
+// MessageType Message接口实现
+func (TranslateStatus) MessageType() string { return TypeTranslate }
+
+// MarshalJSON 序列化时附加type字段
+func (m TranslateStatus) MarshalJSON() ([]byte, error) {
+	type alias TranslateStatus
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeTranslate, alias(m)})
+}
+
+// MusicStatus 音乐播放状态，播放的音频通过TTS音频通道下发（下行，type=music）
+type MusicStatus struct {
+	State     string `json:"state"`                // playing、paused 或 stopped
+	Title     string `json:"title,omitempty"`      // 歌名
+	Artist    string `json:"artist,omitempty"`     // 歌手
+	Index     int    `json:"index,omitempty"`      // 当前歌曲在播放列表中的序号，从1开始
+	Total     int    `json:"total,omitempty"`      // 播放列表的歌曲数
+	Duration  int    `json:"duration,omitempty"`   // 歌曲时长（秒）
+	SessionID string `json:"session_id,omitempty"` // 会话ID
+}
+
+// MessageType Message接口实现
+func (MusicStatus) MessageType() string { return TypeMusic }
+
+// MarshalJSON 序列化时附加type字段
+func (m MusicStatus) MarshalJSON() ([]byte, error) {
+	type alias MusicStatus
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		alias
+	}{TypeMusic, alias(m)})
+}
+
+// DecodeServerMessage 解码服务端下行的消息（不含事件信封），未知类型返回错误
+func DecodeServerMessage(msgType string, data []byte) (Message, error) {
+	var msg Message
+	switch msgType {
+	case TypeHello:
+		msg = &ServerHello{}
+	case TypeSTT:
+		msg = &STT{}
+	case TypeLLM:
+		msg = &LLM{}
+	case TypeTTS:
+		msg = &TTS{}
+	case TypeMCP:
+		msg = &ServerMCP{}
+	case TypeSession:
+		msg = &SessionState{}
+	case TypeTTSConfig:
+		msg = &TTSConfigResult{}
+	case TypeFeedback:
+		msg = &FeedbackResult{}
+	case TypePower:
+		msg = &PowerState{}
+	case TypeIntercom:
+		msg = &Intercom{}
+	case TypeInterrupt:
+		msg = &InterruptResult{}
+	case TypeAbort:
+		msg = &AbortNotice{}
+	case TypeWakeword:
+		msg = &WakeWord{}
+	case TypeAlert:
+		msg = &Alert{}
+	case TypeSystem:
+		msg = &SystemCommand{}
+	case TypeIOT:
+		msg = &IoTCommand{}
+	case TypeRole:
+		msg = &RoleStatus{}
+	case TypeTranslate:
+		msg = &TranslateStatus{}
+	case TypeMusic:
+		msg = &MusicStatus{}
+	default:
+		return nil, fmt.Errorf("未知的消息类型: %s", msgType)
+	}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("解析%s消息失败: %v", msgType, err)
+	}
+	return msg, nil
+}
+
+// DecodeClientMessage 解码客户端上行的消息，未知类型返回错误
+func DecodeClientMessage(msgType string, data []byte) (Message, error) {
+	var msg Message
+	switch msgType {
+	case TypeHello:
+		msg = &ClientHello{}
+	case TypeListen:
+		msg = &Listen{}
+	case TypeAbort:
+		msg = &Abort{}
+	case TypeInterrupt:
+		msg = &Interrupt{}
+	case TypeChat:
+		msg = &Chat{}
+	case TypeImage:
+		msg = &Image{}
+	case TypeIOT:
+		msg = &IoT{}
+	case TypeRole:
+		msg = &RoleSwitch{}
+	case TypeTranslate:
+		msg = &Translate{}
+	case TypeMCP:
+		msg = &ClientMCP{}
+	case TypeSession:
+		msg = &SessionControl{}
+	case TypeTTSConfig:
+		msg = &TTSConfig{}
+	case TypePower:
+		msg = &Power{}
+	case TypeContext:
+		msg = &DeviceContext{}
+	case TypeFeedback:
+		msg = &Feedback{}
+	case TypeAck:
+		msg = &Ack{}
+	case TypeMusic:
+		msg = &MusicControl{}
+	default:
+		return nil, fmt.Errorf("未知的消息类型: %s", msgType)
+	}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("解析%s消息失败: %v", msgType, err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,13 @@
+// Package protocol 小智 WebSocket 协议的 Go SDK
+//
+// 消息类型由 protocol.yaml 生成（messages_gen.go），修改协议后运行：
+//
+//	make protocol
+package protocol
+
+//go:generate go run ./gen -root ../..
+
+// Message 协议消息，MessageType 返回消息的 type 字段
+type Message interface {
+	MessageType() string
+}
@@ -0,0 +1,135 @@
+package recordings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/recording"
+	"xiaozhi-server-go/src/models"
+	"xiaozhi-server-go/src/router/admin"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// recordingItem 录音列表中的一项，附带下载地址
+type recordingItem struct {
+	models.Recording
+	DownloadURL string `json:"download_url"`
+}
+
+// DefaultRecordingService 对话录音查询服务，供调试和质检下载录音
+type DefaultRecordingService struct {
+	config *configs.Config
+	store  *recording.Store
+}
+
+// NewDefaultRecordingService 构造函数，store 为nil表示未启用录音
+func NewDefaultRecordingService(config *configs.Config, store *recording.Store) (*DefaultRecordingService, error) {
+	return &DefaultRecordingService{config: config, store: store}, nil
+}
+
+// Start 注册录音查询路由，未启用录音或未配置管理员令牌时不开放
+func (s *DefaultRecordingService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	if s.store == nil {
+		return nil
+	}
+	if !admin.Enabled(s.config) {
+		logrus.Info("未配置web.admin_token，录音查询接口未开放")
+		return nil
+	}
+	group := admin.Group(apiGroup, s.config)
+	group.GET("/recordings", s.handleList)
+	group.GET("/recordings/:id/download", s.handleDownload)
+
+	logrus.Info("录音查询HTTP服务路由注册完成")
+	return nil
+}
+
+// handleList 分页查询录音
+// 查询参数：device_id、session_id、round、kind（user/reply）用于过滤，page 从1开始，page_size 默认20，最多100
+func (s *DefaultRecordingService) handleList(c *gin.Context) {
+	filter := recording.Filter{
+		DeviceID:  c.Query("device_id"),
+		SessionID: c.Query("session_id"),
+		Kind:      c.Query("kind"),
+	}
+	if filter.Kind != "" && filter.Kind != recording.KindUser && filter.Kind != recording.KindReply {
+		s.respondError(c, http.StatusBadRequest, "kind 只能是 user 或 reply")
+		return
+	}
+	for name, target := range map[string]*int{"round": &filter.Round, "page": &filter.Page, "page_size": &filter.PageSize} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			s.respondError(c, http.StatusBadRequest, fmt.Sprintf("无效的%s", name))
+			return
+		}
+		*target = n
+	}
+
+	list, total, err := s.store.List(filter)
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	prefix := strings.TrimSuffix(c.Request.URL.Path, "/")
+	items := make([]recordingItem, 0, len(list))
+	for _, record := range list {
+		items = append(items, recordingItem{
+			Recording:   record,
+			DownloadURL: path.Join(prefix, strconv.FormatInt(record.ID, 10), "download"),
+		})
+	}
+	page, size := recording.Page(filter.Page, filter.PageSize)
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"total":      total,
+		"page":       page,
+		"page_size":  size,
+		"recordings": items,
+	})
+}
+
+// handleDownload 下载一段录音的音频文件
+func (s *DefaultRecordingService) handleDownload(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		s.respondError(c, http.StatusBadRequest, "无效的录音ID")
+		return
+	}
+	record, file, err := s.store.Get(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.respondError(c, http.StatusNotFound, "录音不存在")
+			return
+		}
+		s.respondError(c, http.StatusInternalServerError, "查询录音失败: "+err.Error())
+		return
+	}
+	if _, err := os.Stat(file); err != nil {
+		s.respondError(c, http.StatusNotFound, "录音文件已删除")
+		return
+	}
+	name := fmt.Sprintf("%s-%d-%s", record.SessionID, record.Round, record.Kind)
+	if record.Kind == recording.KindReply {
+		name += fmt.Sprintf("-%d", record.Sentence)
+	}
+	c.FileAttachment(file, name+"."+record.Format)
+}
+
+// respondError 返回错误响应
+func (s *DefaultRecordingService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{"success": false, "message": message})
+}
@@ -0,0 +1,143 @@
+// Package roles 助手角色（人设）管理：角色保存在 roles 表，设备当前的角色保存在 device_profiles.role_id
+package roles
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultName 切换回默认角色时使用的名称
+const DefaultName = "默认"
+
+const (
+	maxNameLength        = 32
+	maxDescriptionLength = 200
+)
+
+// ErrNotFound 角色不存在
+var ErrNotFound = errors.New("角色不存在")
+
+// Validate 校验角色，LLM必须是配置文件中的LLM
+func Validate(config *configs.Config, role *models.Role) error {
+	role.Name = strings.TrimSpace(role.Name)
+	switch n := utf8.RuneCountInString(role.Name); {
+	case n == 0:
+		return fmt.Errorf("角色名称不能为空")
+	case n > maxNameLength:
+		return fmt.Errorf("角色名称不能超过%d个字符", maxNameLength)
+	case role.Name == DefaultName:
+		return fmt.Errorf("角色名称不能为“%s”", DefaultName)
+	}
+	if utf8.RuneCountInString(role.Description) > maxDescriptionLength {
+		return fmt.Errorf("角色简介不能超过%d个字符", maxDescriptionLength)
+	}
+	if role.LLM != "" {
+		if _, ok := config.LLM[role.LLM]; !ok {
+			return fmt.Errorf("LLM配置 %s 不存在", role.LLM)
+		}
+	}
+	return nil
+}
+
+// List 列出所有角色
+func List(db *gorm.DB) ([]models.Role, error) {
+	var roles []models.Role
+	if err := db.Order("id").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("查询角色失败: %v", err)
+	}
+	return roles, nil
+}
+
+// Get 按ID查询角色
+func Get(db *gorm.DB, id int64) (*models.Role, error) {
+	var role models.Role
+	err := db.Where("id = ?", id).Take(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询角色失败: %v", err)
+	}
+	return &role, nil
+}
+
+// Find 按名称查找角色，没有同名角色时查找名称包含在 name 中的角色，如“英语老师模式”匹配“英语老师”
+func Find(db *gorm.DB, name string) (*models.Role, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrNotFound
+	}
+	roles, err := List(db)
+	if err != nil {
+		return nil, err
+	}
+	var match *models.Role
+	for i := range roles {
+		if roles[i].Name == name {
+			return &roles[i], nil
+		}
+		// 多个角色都匹配时使用名称最长的
+		if strings.Contains(name, roles[i].Name) && (match == nil || len(roles[i].Name) > len(match.Name)) {
+			match = &roles[i]
+		}
+	}
+	if match == nil {
+		return nil, ErrNotFound
+	}
+	return match, nil
+}
+
+// Delete 删除角色，使用该角色的设备恢复默认角色
+func Delete(db *gorm.DB, id int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Role{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("删除角色失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Model(&models.DeviceProfile{}).Where("role_id = ?", id).Update("role_id", 0).Error; err != nil {
+			return fmt.Errorf("重置设备角色失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// DeviceRole 查询设备当前的角色，使用默认角色时返回nil
+func DeviceRole(db *gorm.DB, deviceID string) (*models.Role, error) {
+	var profile models.DeviceProfile
+	err := db.Select("role_id").Where("device_id = ?", deviceID).Take(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && profile.RoleID == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询设备角色失败: %v", err)
+	}
+	role, err := Get(db, profile.RoleID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return role, err
+}
+
+// SetDeviceRole 保存设备当前的角色，roleID 为0表示恢复默认角色
+func SetDeviceRole(db *gorm.DB, deviceID string, roleID int64) error {
+	profile := models.DeviceProfile{DeviceID: deviceID, RoleID: roleID}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "device_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role_id", "updated_at"}),
+	}).Create(&profile).Error
+	if err != nil {
+		return fmt.Errorf("保存设备角色失败: %v", err)
+	}
+	return nil
+}
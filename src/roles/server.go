@@ -0,0 +1,221 @@
+package roles
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/models"
+	"xiaozhi-server-go/src/router/admin"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DeviceSwitcher 切换在线设备的角色，设备不在线时返回错误
+type DeviceSwitcher interface {
+	SwitchDeviceRole(deviceID string, role *models.Role) error
+}
+
+// DefaultRoleService 角色管理服务，供管理员维护角色和切换设备的角色
+type DefaultRoleService struct {
+	config   *configs.Config
+	switcher DeviceSwitcher
+}
+
+// NewDefaultRoleService 构造函数
+func NewDefaultRoleService(config *configs.Config, switcher DeviceSwitcher) (*DefaultRoleService, error) {
+	return &DefaultRoleService{config: config, switcher: switcher}, nil
+}
+
+// Start 注册角色管理路由，未开启或未配置管理员令牌时不开放
+func (s *DefaultRoleService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	if !s.config.RoleAPI.Enabled {
+		logrus.Info("未开启role_api，角色管理接口未开放")
+		return nil
+	}
+	if !admin.Enabled(s.config) {
+		logrus.Info("未配置web.admin_token，角色管理接口未开放")
+		return nil
+	}
+	group := admin.Group(apiGroup, s.config)
+	group.GET("/roles", s.handleList)
+	group.POST("/roles", s.handleCreate)
+	group.PUT("/roles/:id", s.handleUpdate)
+	group.DELETE("/roles/:id", s.handleDelete)
+	group.GET("/devices/:device_id/role", s.handleGetDeviceRole)
+	group.PUT("/devices/:device_id/role", s.handleSetDeviceRole)
+
+	logrus.Info("角色管理HTTP服务路由注册完成")
+	return nil
+}
+
+// handleList 列出所有角色
+func (s *DefaultRoleService) handleList(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	roles, err := List(database.DB)
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "roles": roles})
+}
+
+// handleCreate 创建角色
+// 请求体：{"name":"英语老师","description":"陪你练习英语口语","prompt":"...","voice":"zh-CN-XiaoyiNeural","llm":"OpenAILLM"}
+func (s *DefaultRoleService) handleCreate(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	var role models.Role
+	if err := c.ShouldBindJSON(&role); err != nil {
+		s.respondError(c, http.StatusBadRequest, "请求格式错误: "+err.Error())
+		return
+	}
+	role.ID = 0
+	if err := Validate(s.config, &role); err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := database.DB.Create(&role).Error; err != nil {
+		s.respondError(c, http.StatusBadRequest, "创建角色失败: "+err.Error())
+		return
+	}
+	logrus.WithField("name", role.Name).Info("已创建角色")
+	c.JSON(http.StatusOK, gin.H{"success": true, "role": role})
+}
+
+// handleUpdate 修改角色，使用该角色的在线设备在下次切换或重新连接后生效
+func (s *DefaultRoleService) handleUpdate(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	id, ok := s.roleID(c)
+	if !ok {
+		return
+	}
+	if _, err := Get(database.DB, id); err != nil {
+		s.respondRoleError(c, err)
+		return
+	}
+	var role models.Role
+	if err := c.ShouldBindJSON(&role); err != nil {
+		s.respondError(c, http.StatusBadRequest, "请求格式错误: "+err.Error())
+		return
+	}
+	role.ID = id
+	if err := Validate(s.config, &role); err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := database.DB.Select("name", "description", "prompt", "voice", "llm").Updates(&role).Error; err != nil {
+		s.respondError(c, http.StatusBadRequest, "修改角色失败: "+err.Error())
+		return
+	}
+	updated, err := Get(database.DB, id)
+	if err != nil {
+		s.respondRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "role": updated})
+}
+
+// handleDelete 删除角色
+func (s *DefaultRoleService) handleDelete(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	id, ok := s.roleID(c)
+	if !ok {
+		return
+	}
+	if err := Delete(database.DB, id); err != nil {
+		s.respondRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// handleGetDeviceRole 查询设备当前的角色，使用默认角色时 role 为null
+func (s *DefaultRoleService) handleGetDeviceRole(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	role, err := DeviceRole(database.DB, c.Param("device_id"))
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "role": role})
+}
+
+// setDeviceRoleRequest 切换设备角色请求，role_id 为0表示恢复默认角色
+type setDeviceRoleRequest struct {
+	RoleID int64 `json:"role_id"`
+}
+
+// handleSetDeviceRole 切换设备的角色，设备在线时立即生效，否则在下次连接时生效
+func (s *DefaultRoleService) handleSetDeviceRole(c *gin.Context) {
+	if !s.requireDB(c) {
+		return
+	}
+	var req setDeviceRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, "请求格式错误: "+err.Error())
+		return
+	}
+	var role *models.Role
+	if req.RoleID != 0 {
+		var err error
+		if role, err = Get(database.DB, req.RoleID); err != nil {
+			s.respondRoleError(c, err)
+			return
+		}
+	}
+
+	deviceID := c.Param("device_id")
+	if err := SetDeviceRole(database.DB, deviceID, req.RoleID); err != nil {
+		s.respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	online := s.switcher != nil && s.switcher.SwitchDeviceRole(deviceID, role) == nil
+	c.JSON(http.StatusOK, gin.H{"success": true, "role": role, "online": online})
+}
+
+// roleID 解析路径中的角色ID
+func (s *DefaultRoleService) roleID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		s.respondError(c, http.StatusBadRequest, "无效的角色ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// requireDB 角色保存在数据库中，数据库未连接时返回503
+func (s *DefaultRoleService) requireDB(c *gin.Context) bool {
+	if database.DB == nil {
+		s.respondError(c, http.StatusServiceUnavailable, "数据库未连接")
+		return false
+	}
+	return true
+}
+
+// respondRoleError 角色不存在时返回404，其他错误返回500
+func (s *DefaultRoleService) respondRoleError(c *gin.Context, err error) {
+	if errors.Is(err, ErrNotFound) {
+		s.respondError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	s.respondError(c, http.StatusInternalServerError, err.Error())
+}
+
+// respondError 返回错误响应
+func (s *DefaultRoleService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{"success": false, "message": message})
+}
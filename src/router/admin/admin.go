@@ -0,0 +1,48 @@
+// Package admin 管理接口统一的管理员令牌鉴权。
+// 各服务在自己的包中注册路由，router 包依赖部分服务，因此鉴权放在独立的子包中避免循环引用
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Enabled 是否配置了 web.admin_token，未配置时不开放管理接口
+func Enabled(config *configs.Config) bool {
+	return config.Web.AdminToken != ""
+}
+
+// Group 在 apiGroup 下创建需要管理员令牌的路由组，路径不变
+func Group(apiGroup *gin.RouterGroup, config *configs.Config) *gin.RouterGroup {
+	return apiGroup.Group("", Middleware(config.Web.AdminToken))
+}
+
+// Middleware 校验 Authorization: Bearer 管理员令牌
+func Middleware(token string) gin.HandlerFunc {
+	return middleware(token, false)
+}
+
+// QueryMiddleware 同 Middleware，浏览器WebSocket等无法设置请求头的客户端可以使用 token 查询参数
+func QueryMiddleware(token string) gin.HandlerFunc {
+	return middleware(token, true)
+}
+
+func middleware(token string, allowQuery bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if got == "" && allowQuery {
+			got = c.Query("token")
+		}
+		// 未配置令牌时拒绝所有请求，避免空令牌通过校验
+		if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "message": "无效的管理员令牌"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		query  string
+		want   int
+	}{
+		{name: "令牌正确", token: "secret", header: "Bearer secret", want: http.StatusOK},
+		{name: "令牌错误", token: "secret", header: "Bearer wrong", want: http.StatusUnauthorized},
+		{name: "缺少令牌", token: "secret", want: http.StatusUnauthorized},
+		{name: "不接受查询参数", token: "secret", query: "secret", want: http.StatusUnauthorized},
+		{name: "未配置令牌时拒绝空令牌", token: "", header: "Bearer ", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &configs.Config{}
+			config.Web.AdminToken = tt.token
+			engine := gin.New()
+			Group(engine.Group("/api"), config).GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "/api/ping?token="+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/mcp", QueryMiddleware("secret"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for query, want := range map[string]int{"secret": http.StatusOK, "wrong": http.StatusUnauthorized} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/mcp?token="+query, nil))
+		if w.Code != want {
+			t.Errorf("token=%s: status = %d, want %d", query, w.Code, want)
+		}
+	}
+}
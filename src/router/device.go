@@ -0,0 +1,29 @@
+package router
+
+import (
+	"context"
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/handlers"
+	"xiaozhi-server-go/src/router/admin"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DeviceRouter 注册设备绑定用户的管理路由，未配置管理员令牌时不开放
+func DeviceRouter(ctx context.Context, apiGroup *gin.RouterGroup, config *configs.Config) {
+	if !admin.Enabled(config) {
+		logrus.Info("未配置web.admin_token，设备绑定接口未开放")
+		return
+	}
+	deviceHandler := handlers.NewDeviceHandler(config)
+
+	group := admin.Group(apiGroup, config)
+	{
+		group.GET("/devices/:device_id/user", deviceHandler.GetUser)
+		group.PUT("/devices/:device_id/user", deviceHandler.BindUser)
+		group.DELETE("/devices/:device_id/user", deviceHandler.UnbindUser)
+	}
+
+	logrus.Info("设备绑定HTTP服务路由注册完成")
+}
@@ -0,0 +1,146 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	defaultCORSAllowHeaders  = []string{"Authorization", "Content-Type", "Client-Id", "Device-Id", "X-API-Key"}
+	defaultCORSExposeHeaders = []string{"X-Quota-Remaining"}
+	corsAllowMethods         = "GET, POST, PUT, DELETE, OPTIONS"
+)
+
+// CORSMiddleware 统一处理跨域请求与OPTIONS预检，替代各服务自行注册的OPTIONS路由
+func CORSMiddleware(cfg configs.CORSConfig) gin.HandlerFunc {
+	allowHeaders := cfg.AllowHeaders
+	if len(allowHeaders) == 0 {
+		allowHeaders = defaultCORSAllowHeaders
+	}
+	exposeHeaders := cfg.ExposeHeaders
+	if exposeHeaders == nil {
+		exposeHeaders = defaultCORSExposeHeaders
+	}
+	allowHeadersValue := strings.Join(allowHeaders, ", ")
+	exposeHeadersValue := strings.Join(exposeHeaders, ", ")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions
+
+		if origin == "" {
+			// 非浏览器跨域请求，OPTIONS直接应答即可
+			if preflight {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		origins, wildcard := corsOriginsFor(cfg, c.Request.URL.Path)
+		if !wildcard && !containsOrigin(origins, origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			// 不添加CORS头，由浏览器拦截响应
+			c.Next()
+			return
+		}
+
+		// 允许所有来源时不回显来源也不允许携带凭证，配置加载时已拒绝与 allow_credentials 同时使用
+		if wildcard {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+			if cfg.AllowCredentials {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		if exposeHeadersValue != "" {
+			c.Header("Access-Control-Expose-Headers", exposeHeadersValue)
+		}
+
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+			c.Header("Access-Control-Allow-Headers", allowHeadersValue)
+			if cfg.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// corsOriginsFor 返回路径对应的来源白名单，按最长前缀匹配路由配置
+func corsOriginsFor(cfg configs.CORSConfig, path string) ([]string, bool) {
+	origins := cfg.AllowOrigins
+	wildcard := len(origins) == 0
+	matched := -1
+	for _, route := range cfg.Routes {
+		if route.Prefix == "" || !strings.HasPrefix(path, route.Prefix) {
+			continue
+		}
+		if len(route.Prefix) > matched {
+			matched = len(route.Prefix)
+			origins = route.AllowOrigins
+			// 路由级配置为空表示禁止跨域，而不是允许所有来源
+			wildcard = false
+		}
+	}
+	if containsOrigin(origins, "*") {
+		wildcard = true
+	}
+	return origins, wildcard
+}
+
+func containsOrigin(origins []string, origin string) bool {
+	for _, o := range origins {
+		if strings.EqualFold(strings.TrimRight(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// SecurityHeadersMiddleware 为所有HTTP响应添加标准安全响应头
+func SecurityHeadersMiddleware(cfg configs.SecurityHeaderConfig) gin.HandlerFunc {
+	frameOptions := cfg.FrameOptions
+	if frameOptions == "" {
+		frameOptions = "DENY"
+	}
+	referrerPolicy := cfg.ReferrerPolicy
+	if referrerPolicy == "" {
+		referrerPolicy = "no-referrer"
+	}
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(cfg.HSTSMaxAge) + "; includeSubDomains"
+	}
+
+	return func(c *gin.Context) {
+		if cfg.Disabled {
+			c.Next()
+			return
+		}
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("X-Frame-Options", frameOptions)
+		c.Header("Referrer-Policy", referrerPolicy)
+		if cfg.ContentSecurityPolicy != "" {
+			c.Header("Content-Security-Policy", cfg.ContentSecurityPolicy)
+		}
+		// HSTS仅在HTTPS（含反向代理转发）下发送
+		if hsts != "" && (c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")) {
+			c.Header("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
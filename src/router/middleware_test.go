@@ -0,0 +1,62 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name            string
+		config          configs.CORSConfig
+		origin          string
+		wantOrigin      string
+		wantCredentials string
+	}{
+		{
+			name:       "允许所有来源时不回显来源",
+			config:     configs.CORSConfig{AllowOrigins: []string{"*"}},
+			origin:     "https://evil.example.com",
+			wantOrigin: "*",
+		},
+		{
+			name:            "白名单来源回显并允许凭证",
+			config:          configs.CORSConfig{AllowOrigins: []string{"https://admin.example.com"}, AllowCredentials: true},
+			origin:          "https://admin.example.com",
+			wantOrigin:      "https://admin.example.com",
+			wantCredentials: "true",
+		},
+		{
+			name:   "不在白名单的来源不添加CORS头",
+			config: configs.CORSConfig{AllowOrigins: []string{"https://admin.example.com"}, AllowCredentials: true},
+			origin: "https://evil.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			engine.Use(CORSMiddleware(tt.config))
+			engine.GET("/api/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "/api/ping", nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDir = "tmp/sandbox"
+	// 单个录制最多保存的轮次，超出后停止录制
+	maxTurns = 100
+)
+
+// Recording 一次会话的LLM交互录制
+type Recording struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	DeviceID  string    `json:"device_id"`
+	Provider  string    `json:"provider"` // 录制时使用的LLM类型
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	Turns     []Turn    `json:"turns"`
+}
+
+// Turn 一次LLM调用的输入与输出
+type Turn struct {
+	Round     int              `json:"round"`
+	Messages  []types.Message  `json:"messages"`
+	Tools     []openai.Tool    `json:"tools,omitempty"`
+	Output    string           `json:"output"`
+	ToolCalls []types.ToolCall `json:"tool_calls,omitempty"`
+	LatencyMs int64            `json:"latency_ms"`
+	Feedback  string           `json:"feedback,omitempty"` // 用户对本轮回复的评价（good/bad）
+}
+
+// Recorder 会话录制器，每轮结束后整体写回录制文件
+type Recorder struct {
+	path     string
+	redactor *utils.Redactor // 写入前对对话内容脱敏，可为nil
+
+	mu  sync.Mutex
+	rec *Recording
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// NewRecorder 按配置创建录制器，未开启录制或设备不在录制范围内时返回nil
+func NewRecorder(config configs.SandboxConfig, sessionID, deviceID, provider, model string) *Recorder {
+	if !config.Record {
+		return nil
+	}
+	if len(config.Devices) > 0 {
+		matched := false
+		for _, d := range config.Devices {
+			if d == deviceID {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil
+		}
+	}
+
+	now := time.Now()
+	id := now.Format("20060102150405") + "_" + unsafeChars.ReplaceAllString(sessionID, "_")
+	return &Recorder{
+		path: filepath.Join(recordDir(config), id+".json"),
+		rec: &Recording{
+			ID:        id,
+			SessionID: sessionID,
+			DeviceID:  deviceID,
+			Provider:  provider,
+			Model:     model,
+			CreatedAt: now,
+		},
+	}
+}
+
+// SetRedactor 设置脱敏器，之后记录的对话内容先脱敏再写入
+func (r *Recorder) SetRedactor(redactor *utils.Redactor) {
+	if r == nil {
+		return
+	}
+	r.redactor = redactor
+}
+
+// RecordTurn 记录一轮LLM交互，写文件失败只记录日志
+func (r *Recorder) RecordTurn(turn Turn) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.rec.Turns) >= maxTurns {
+		return
+	}
+	// 复制消息，避免后续对话修改历史切片
+	turn.Messages = append([]types.Message(nil), turn.Messages...)
+	if r.redactor != nil {
+		r.redact(&turn)
+	}
+	r.rec.Turns = append(r.rec.Turns, turn)
+
+	if err := writeRecording(r.path, r.rec); err != nil {
+		logrus.WithError(err).Warn("写入会话录制失败")
+	}
+}
+
+// SetFeedback 把用户评价记录到指定轮次的最后一次LLM调用上
+func (r *Recorder) SetFeedback(round int, rating string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.rec.Turns) - 1; i >= 0; i-- {
+		if r.rec.Turns[i].Round == round {
+			r.rec.Turns[i].Feedback = rating
+			if err := writeRecording(r.path, r.rec); err != nil {
+				logrus.WithError(err).Warn("写入会话录制失败")
+			}
+			return
+		}
+	}
+}
+
+// redact 对本轮的消息、输出和工具调用参数脱敏，消息已是副本，工具调用需另行复制
+func (r *Recorder) redact(turn *Turn) {
+	for i := range turn.Messages {
+		msg := &turn.Messages[i]
+		msg.Content = r.redactor.Redact(msg.Content)
+		msg.ToolCalls = r.redactToolCalls(msg.ToolCalls)
+	}
+	turn.Output = r.redactor.Redact(turn.Output)
+	turn.ToolCalls = r.redactToolCalls(turn.ToolCalls)
+}
+
+func (r *Recorder) redactToolCalls(calls []types.ToolCall) []types.ToolCall {
+	if len(calls) == 0 {
+		return calls
+	}
+	redacted := make([]types.ToolCall, len(calls))
+	for i, call := range calls {
+		call.Function.Arguments = r.redactor.Redact(call.Function.Arguments)
+		redacted[i] = call
+	}
+	return redacted
+}
+
+func recordDir(config configs.SandboxConfig) string {
+	if config.Dir != "" {
+		return config.Dir
+	}
+	return defaultDir
+}
+
+func writeRecording(path string, rec *Recording) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建录制目录失败: %v", err)
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化录制失败: %v", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入录制文件失败: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// RecordingSummary 录制列表项
+type RecordingSummary struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	DeviceID  string    `json:"device_id"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	Turns     int       `json:"turns"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// listRecordings 列出目录下的所有录制，按创建时间倒序
+func listRecordings(dir string) ([]RecordingSummary, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]RecordingSummary, 0, len(files))
+	for i := len(files) - 1; i >= 0; i-- {
+		rec, err := readRecording(files[i])
+		if err != nil {
+			logrus.WithError(err).Warn("读取会话录制失败: " + files[i])
+			continue
+		}
+		summaries = append(summaries, RecordingSummary{
+			ID:        rec.ID,
+			SessionID: rec.SessionID,
+			DeviceID:  rec.DeviceID,
+			Provider:  rec.Provider,
+			Model:     rec.Model,
+			Turns:     len(rec.Turns),
+			CreatedAt: rec.CreatedAt,
+		})
+	}
+	return summaries, nil
+}
+
+// loadRecording 按ID读取录制
+func loadRecording(dir, id string) (*Recording, error) {
+	if id == "" || unsafeChars.MatchString(id) {
+		return nil, fmt.Errorf("无效的录制ID")
+	}
+	return readRecording(filepath.Join(dir, id+".json"))
+}
+
+func readRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("解析录制文件失败: %v", err)
+	}
+	return &rec, nil
+}
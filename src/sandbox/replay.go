@@ -0,0 +1,156 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/types"
+)
+
+// ReplayRequest 回放请求，在录制的LLM配置之外覆盖模型或提示词
+type ReplayRequest struct {
+	LLMModule    string   `json:"llm_module"`    // 使用配置文件中的LLM模块，为空时使用当前选择的LLM
+	ModelName    string   `json:"model_name"`    // 可选，覆盖模型名称
+	Temperature  *float64 `json:"temperature"`   // 可选，覆盖温度，设为0可减少输出随机性
+	SystemPrompt string   `json:"system_prompt"` // 可选，替换录制中的系统提示词
+	Rounds       []int    `json:"rounds"`        // 可选，只回放指定轮次
+}
+
+// Output 一次LLM调用的输出
+type Output struct {
+	Content   string           `json:"content"`
+	ToolCalls []types.ToolCall `json:"tool_calls,omitempty"`
+	LatencyMs int64            `json:"latency_ms"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// TurnComparison 单轮的原始输出与回放输出对比
+type TurnComparison struct {
+	Round    int    `json:"round"`
+	Input    string `json:"input"` // 本轮最后一条用户消息
+	Original Output `json:"original"`
+	Replayed Output `json:"replayed"`
+}
+
+// ReplayResult 回放结果
+type ReplayResult struct {
+	RecordingID string           `json:"recording_id"`
+	Provider    string           `json:"provider"`
+	Model       string           `json:"model"`
+	Turns       []TurnComparison `json:"turns"`
+}
+
+// replay 使用新的LLM配置依次重放录制中每一轮的原始输入
+// 每轮都使用录制时的完整上下文，而不是回放产生的回复，保证各轮输入一致可比
+func replay(ctx context.Context, rec *Recording, config *llm.Config, req ReplayRequest) (*ReplayResult, error) {
+	provider, err := llm.Create(config.Type, config)
+	if err != nil {
+		return nil, err
+	}
+	defer provider.Cleanup()
+
+	result := &ReplayResult{
+		RecordingID: rec.ID,
+		Provider:    config.Type,
+		Model:       config.ModelName,
+	}
+	for _, turn := range rec.Turns {
+		if len(req.Rounds) > 0 && !containsRound(req.Rounds, turn.Round) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("回放中断: %v", err)
+		}
+
+		messages := replaceSystemPrompt(turn.Messages, req.SystemPrompt)
+		result.Turns = append(result.Turns, TurnComparison{
+			Round: turn.Round,
+			Input: lastUserMessage(turn.Messages),
+			Original: Output{
+				Content:   turn.Output,
+				ToolCalls: turn.ToolCalls,
+				LatencyMs: turn.LatencyMs,
+			},
+			Replayed: runTurn(ctx, provider, rec.SessionID, messages, turn),
+		})
+	}
+	return result, nil
+}
+
+// runTurn 调用LLM并汇总流式输出，工具调用按与连接处理器相同的方式合并
+func runTurn(ctx context.Context, provider llm.Provider, sessionID string, messages []types.Message, turn Turn) Output {
+	start := time.Now()
+	var out Output
+	responses, err := provider.ResponseWithFunctions(ctx, sessionID, messages, turn.Tools)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+
+	var toolCall *types.ToolCall
+	for response := range responses {
+		if response.Err != nil || response.Error != "" {
+			respErr := response.Err
+			if respErr == nil {
+				respErr = errors.New(response.Error)
+			}
+			out.Error = respErr.Error()
+			continue
+		}
+		out.Content += response.Content
+		if len(response.ToolCalls) > 0 {
+			tc := response.ToolCalls[0]
+			if toolCall == nil {
+				toolCall = &types.ToolCall{Type: "function"}
+			}
+			if tc.ID != "" {
+				toolCall.ID = tc.ID
+			}
+			if tc.Function.Name != "" {
+				toolCall.Function.Name = tc.Function.Name
+			}
+			toolCall.Function.Arguments += tc.Function.Arguments
+		}
+	}
+	if toolCall != nil {
+		out.ToolCalls = []types.ToolCall{*toolCall}
+	}
+	out.LatencyMs = time.Since(start).Milliseconds()
+	return out
+}
+
+// replaceSystemPrompt 替换第一条系统消息，prompt为空时原样返回
+func replaceSystemPrompt(messages []types.Message, prompt string) []types.Message {
+	result := append([]types.Message(nil), messages...)
+	if prompt == "" {
+		return result
+	}
+	for i := range result {
+		if result[i].Role == "system" {
+			result[i].Content = prompt
+			return result
+		}
+	}
+	return append([]types.Message{{Role: "system", Content: prompt}}, result...)
+}
+
+func lastUserMessage(messages []types.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+func containsRound(rounds []int, round int) bool {
+	for _, r := range rounds {
+		if r == round {
+			return true
+		}
+	}
+	return false
+}
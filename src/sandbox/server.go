@@ -0,0 +1,145 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/router/admin"
+)
+
+// 单次回放的最长执行时间
+const replayTimeout = 5 * time.Minute
+
+// DefaultSandboxService 会话录制查看与回放服务，仅供管理员调试提示词和模型
+type DefaultSandboxService struct {
+	config *configs.Config
+}
+
+// NewDefaultSandboxService 构造函数
+func NewDefaultSandboxService(config *configs.Config) (*DefaultSandboxService, error) {
+	return &DefaultSandboxService{config: config}, nil
+}
+
+// Start 注册回放相关路由，未配置管理员令牌时不开放
+func (s *DefaultSandboxService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	if !admin.Enabled(s.config) {
+		logrus.Info("未配置web.admin_token，回放接口未开放")
+		return nil
+	}
+	group := admin.Group(apiGroup, s.config)
+	group.GET("/sandbox/recordings", s.handleList)
+	group.GET("/sandbox/recordings/:id", s.handleGet)
+	group.POST("/sandbox/recordings/:id/replay", s.handleReplay)
+
+	logrus.Info("回放HTTP服务路由注册完成")
+	return nil
+}
+
+// handleList 列出所有录制
+func (s *DefaultSandboxService) handleList(c *gin.Context) {
+	summaries, err := listRecordings(recordDir(s.config.Sandbox))
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, fmt.Sprintf("读取录制列表失败: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "recordings": summaries})
+}
+
+// handleGet 获取单个录制的完整内容
+func (s *DefaultSandboxService) handleGet(c *gin.Context) {
+	rec, ok := s.load(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "recording": rec})
+}
+
+// handleReplay 使用修改后的配置回放录制，返回逐轮对比
+func (s *DefaultSandboxService) handleReplay(c *gin.Context) {
+	rec, ok := s.load(c)
+	if !ok {
+		return
+	}
+
+	var req ReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.respondError(c, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+	llmConfig, err := s.llmConfig(req)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), replayTimeout)
+	defer cancel()
+	result, err := replay(ctx, rec, llmConfig, req)
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, fmt.Sprintf("回放失败: %v", err))
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"recording": rec.ID,
+		"provider":  result.Provider,
+		"model":     result.Model,
+		"turns":     len(result.Turns),
+	}).Info("会话回放完成")
+	c.JSON(http.StatusOK, gin.H{"success": true, "result": result})
+}
+
+// llmConfig 从配置文件中的LLM模块构造回放配置，并应用请求中的覆盖项
+func (s *DefaultSandboxService) llmConfig(req ReplayRequest) (*llm.Config, error) {
+	module := req.LLMModule
+	if module == "" {
+		module = s.config.SelectedModule["LLM"]
+	}
+	cfg, ok := s.config.LLM[module]
+	if !ok {
+		return nil, fmt.Errorf("找不到LLM配置: %s", module)
+	}
+
+	config := &llm.Config{
+		Type:        cfg.Type,
+		ModelName:   cfg.ModelName,
+		BaseURL:     cfg.BaseURL,
+		APIKey:      cfg.APIKey,
+		Temperature: cfg.Temperature,
+		MaxTokens:   cfg.MaxTokens,
+		TopP:        cfg.TopP,
+		Extra:       cfg.Extra,
+	}
+	if req.ModelName != "" {
+		config.ModelName = req.ModelName
+	}
+	if req.Temperature != nil {
+		config.Temperature = *req.Temperature
+	}
+	return config, nil
+}
+
+func (s *DefaultSandboxService) load(c *gin.Context) (*Recording, bool) {
+	rec, err := loadRecording(recordDir(s.config.Sandbox), c.Param("id"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.respondError(c, http.StatusNotFound, "录制不存在")
+		} else {
+			s.respondError(c, http.StatusBadRequest, err.Error())
+		}
+		return nil, false
+	}
+	return rec, true
+}
+
+// respondError 返回错误响应
+func (s *DefaultSandboxService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{"success": false, "message": message})
+}
@@ -0,0 +1,117 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/models"
+	"xiaozhi-server-go/src/task"
+	"xiaozhi-server-go/src/usage"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// 内置的任务类型
+const (
+	KindReminder    = "reminder"     // 到时在设备上播报的提醒
+	KindLogCleanup  = "log_cleanup"  // 清理过期日志
+	KindUsageReport = "usage_report" // 生成前一天的用量报告
+)
+
+// 配置中的周期任务名称
+const (
+	jobLogCleanup  = "log_cleanup"
+	jobUsageReport = "usage_report"
+)
+
+// reminderExpiry 服务停机等原因错过提醒超过该时长后不再播报
+const reminderExpiry = 10 * time.Minute
+
+// Speaker 在在线设备上播报文本
+type Speaker interface {
+	SpeakOnDevice(deviceID string, text string) error
+}
+
+// reminderPayload 提醒任务的参数
+type reminderPayload struct {
+	DeviceID string `json:"device_id"`
+	Text     string `json:"text"`
+}
+
+// registerJobs 注册内置任务，提醒时间敏感，使用普通优先级
+func (s *Scheduler) registerJobs(speaker Speaker) {
+	if speaker != nil {
+		s.Handle(KindReminder, task.PriorityNormal, func(ctx context.Context, job *models.ScheduledJob) error {
+			return runReminder(speaker, job)
+		})
+	}
+	s.Handle(KindLogCleanup, task.PriorityBackground, s.runLogCleanup)
+	s.Handle(KindUsageReport, task.PriorityBackground, s.runUsageReport)
+}
+
+// syncConfigJobs 按配置创建、更新或删除日志清理和用量报告任务
+func (s *Scheduler) syncConfigJobs() {
+	jobs := []struct{ name, kind, expr string }{
+		{jobLogCleanup, KindLogCleanup, s.config.Schedule.LogCleanup},
+		{jobUsageReport, KindUsageReport, s.config.Schedule.UsageReport},
+	}
+	for _, job := range jobs {
+		var err error
+		if job.expr == "" {
+			_, err = s.Cancel(job.name)
+		} else {
+			err = s.Cron(job.name, job.kind, job.expr, "")
+		}
+		if err != nil {
+			logrus.WithError(err).WithField("job", job.name).Error("同步定时任务失败")
+		}
+	}
+}
+
+// Remind 创建到时在设备上播报的提醒，ReminderScheduler接口实现
+func (s *Scheduler) Remind(deviceID, text string, at time.Time) error {
+	if _, ok := s.handler(KindReminder); !ok {
+		return fmt.Errorf("提醒功能未启用")
+	}
+	payload, err := json.Marshal(reminderPayload{DeviceID: deviceID, Text: text})
+	if err != nil {
+		return fmt.Errorf("序列化提醒失败: %v", err)
+	}
+	return s.At("reminder-"+uuid.New().String(), KindReminder, at, string(payload))
+}
+
+// runReminder 在设备上播报提醒，设备不在线或已错过太久时放弃
+func runReminder(speaker Speaker, job *models.ScheduledJob) error {
+	var payload reminderPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return fmt.Errorf("解析提醒参数失败: %v", err)
+	}
+	if late := time.Since(job.NextRunAt); late > reminderExpiry {
+		return fmt.Errorf("提醒已错过 %s，不再播报", late.Round(time.Minute))
+	}
+	return speaker.SpeakOnDevice(payload.DeviceID, "提醒你，"+payload.Text)
+}
+
+// runLogCleanup 删除超过保留天数的日志
+func (s *Scheduler) runLogCleanup(ctx context.Context, job *models.ScheduledJob) error {
+	removed, err := utils.CleanOldLogs(s.config)
+	if len(removed) > 0 {
+		logrus.WithField("files", removed).Info("已清理过期日志")
+	}
+	return err
+}
+
+// runUsageReport 生成前一天的用量报告
+func (s *Scheduler) runUsageReport(ctx context.Context, job *models.ScheduledJob) error {
+	day := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	path, err := usage.WriteDailyReport(s.db, s.config, day)
+	if err != nil {
+		return err
+	}
+	logrus.WithField("path", path).Info("已生成用量报告")
+	return nil
+}
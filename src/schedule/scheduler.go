@@ -0,0 +1,281 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/models"
+	"xiaozhi-server-go/src/task"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	pollInterval = 5 * time.Second // 检查到期任务的间隔
+	pollBatch    = 100
+	maxErrorLen  = 500
+)
+
+const (
+	// TaskTypeScheduledJob 到期的定时任务
+	TaskTypeScheduledJob task.TaskType = "scheduled_job"
+	// TaskTypeSharedScheduledJob 通过共享队列提交的后台定时任务，参数为任务记录的JSON
+	TaskTypeSharedScheduledJob task.TaskType = "scheduled_job_shared"
+)
+
+func init() {
+	task.RegisterTaskExecutor(TaskTypeScheduledJob, func(t *task.Task) error {
+		run, ok := t.Params.(*jobRun)
+		if !ok {
+			return fmt.Errorf("invalid params for task type %v", TaskTypeScheduledJob)
+		}
+		return run.scheduler.execute(t.Context, run.job)
+	})
+}
+
+// JobFunc 执行一个到期的定时任务，job.NextRunAt 为本次计划的执行时间
+type JobFunc func(ctx context.Context, job *models.ScheduledJob) error
+
+type handler struct {
+	priority task.TaskPriority
+	run      JobFunc
+}
+
+// jobRun 提交到任务管理器的任务参数
+type jobRun struct {
+	scheduler *Scheduler
+	job       *models.ScheduledJob
+}
+
+// Scheduler 定时任务调度器，任务保存在数据库中，到期后提交到任务管理器执行
+// 一次性任务执行后停用，周期任务按cron表达式计算下次执行时间
+type Scheduler struct {
+	config  *configs.Config
+	db      *gorm.DB
+	taskMgr *task.TaskManager
+
+	mu       sync.RWMutex
+	handlers map[string]handler
+}
+
+// NewScheduler 构造函数，注册内置的提醒、日志清理和用量报告任务
+func NewScheduler(config *configs.Config, db *gorm.DB, taskMgr *task.TaskManager, speaker Speaker) (*Scheduler, error) {
+	if db == nil || taskMgr == nil {
+		return nil, fmt.Errorf("数据库或任务管理器未初始化")
+	}
+	s := &Scheduler{
+		config:   config,
+		db:       db,
+		taskMgr:  taskMgr,
+		handlers: make(map[string]handler),
+	}
+	s.registerJobs(speaker)
+	task.RegisterTaskExecutor(TaskTypeSharedScheduledJob, func(t *task.Task) error {
+		var job models.ScheduledJob
+		if err := t.DecodeParams(&job); err != nil {
+			return fmt.Errorf("解析定时任务参数失败: %v", err)
+		}
+		return s.execute(t.Context, &job)
+	})
+	return s, nil
+}
+
+// Handle 注册任务类型的执行函数，priority 为提交到任务管理器时的优先级，
+// 后台优先级的任务不依赖本实例的状态，通过共享队列提交
+func (s *Scheduler) Handle(kind string, priority task.TaskPriority, run JobFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[kind] = handler{priority: priority, run: run}
+}
+
+func (s *Scheduler) handler(kind string) (handler, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h, ok := s.handlers[kind]
+	return h, ok
+}
+
+// At 创建一次性任务，同名任务已存在时替换
+func (s *Scheduler) At(name, kind string, at time.Time, payload string) error {
+	return s.save(models.ScheduledJob{
+		Name:      name,
+		Kind:      kind,
+		Payload:   payload,
+		NextRunAt: at.Truncate(time.Second),
+		Enabled:   true,
+	})
+}
+
+// Cron 创建或更新周期任务，表达式和参数都没有变化时保留原来的下次执行时间
+func (s *Scheduler) Cron(name, kind, expr, payload string) error {
+	schedule, err := task.ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	var existing []models.ScheduledJob
+	if err := s.db.Where("name = ?", name).Limit(1).Find(&existing).Error; err != nil {
+		return fmt.Errorf("查询定时任务失败: %v", err)
+	}
+	if len(existing) > 0 {
+		job := existing[0]
+		if job.Enabled && job.Kind == kind && job.Cron == expr && job.Payload == payload {
+			return nil
+		}
+	}
+
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		return fmt.Errorf("cron表达式 %q 没有可执行的时间", expr)
+	}
+	return s.save(models.ScheduledJob{
+		Name:      name,
+		Kind:      kind,
+		Payload:   payload,
+		Cron:      expr,
+		NextRunAt: next,
+		Enabled:   true,
+	})
+}
+
+// Cancel 删除任务，任务不存在时返回false
+func (s *Scheduler) Cancel(name string) (bool, error) {
+	result := s.db.Where("name = ?", name).Delete(&models.ScheduledJob{})
+	if result.Error != nil {
+		return false, fmt.Errorf("删除定时任务失败: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// save 按名称新建或覆盖任务
+func (s *Scheduler) save(job models.ScheduledJob) error {
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"kind", "payload", "cron", "next_run_at", "enabled", "last_error", "updated_at"}),
+	}).Create(&job).Error
+	if err != nil {
+		return fmt.Errorf("保存定时任务失败: %v", err)
+	}
+	return nil
+}
+
+// Start 同步配置中的周期任务并开始轮询到期任务，ctx 取消后停止
+func (s *Scheduler) Start(ctx context.Context) {
+	s.syncConfigJobs()
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.poll(ctx)
+			}
+		}
+	}()
+	logrus.Info("定时任务调度已启动")
+}
+
+// poll 取出到期的任务并逐个提交
+func (s *Scheduler) poll(ctx context.Context) {
+	var jobs []models.ScheduledJob
+	err := s.db.Where("enabled = ? AND next_run_at <= ?", true, time.Now()).
+		Order("next_run_at").Limit(pollBatch).Find(&jobs).Error
+	if err != nil {
+		logrus.WithError(err).Error("查询到期的定时任务失败")
+		return
+	}
+	for i := range jobs {
+		s.dispatch(ctx, &jobs[i])
+	}
+}
+
+// dispatch 先在数据库中推进任务的下次执行时间，成功后再提交执行，
+// 多个实例共用数据库时同一次到期只有一个实例执行，执行中重启也不会重复执行
+func (s *Scheduler) dispatch(ctx context.Context, job *models.ScheduledJob) {
+	now := time.Now()
+	updates := map[string]interface{}{"last_run_at": now}
+	h, ok := s.handler(job.Kind)
+	switch {
+	case !ok:
+		updates["enabled"] = false
+		updates["last_error"] = fmt.Sprintf("未知的任务类型: %s", job.Kind)
+	case job.Cron == "":
+		updates["enabled"] = false
+	default:
+		schedule, err := task.ParseCron(job.Cron)
+		var next time.Time
+		if err == nil {
+			next = schedule.Next(now)
+		}
+		if next.IsZero() {
+			ok = false
+			updates["enabled"] = false
+			updates["last_error"] = fmt.Sprintf("cron表达式无效: %s", job.Cron)
+		} else {
+			updates["next_run_at"] = next
+		}
+	}
+
+	result := s.db.Model(&models.ScheduledJob{}).
+		Where("id = ? AND enabled = ? AND next_run_at = ?", job.ID, true, job.NextRunAt).
+		Updates(updates)
+	if result.Error != nil {
+		logrus.WithError(result.Error).WithField("job", job.Name).Error("更新定时任务失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		return // 已被其他实例执行或被修改
+	}
+	if !ok {
+		logrus.WithField("job", job.Name).Warn(updates["last_error"])
+		return
+	}
+
+	if h.priority == task.PriorityBackground {
+		// 后台任务不依赖本实例的连接，配置了共享队列时由任一空闲实例执行
+		if _, err := s.taskMgr.SubmitShared(TaskTypeSharedScheduledJob, job, task.RetryPolicy{}); err != nil {
+			s.finish(job, fmt.Errorf("提交定时任务失败: %v", err))
+		}
+		return
+	}
+	t, _ := task.NewTask(ctx, TaskTypeScheduledJob, &jobRun{scheduler: s, job: job})
+	t.Priority = h.priority
+	if err := s.taskMgr.SubmitInternalTask(t); err != nil {
+		s.finish(job, fmt.Errorf("提交定时任务失败: %v", err))
+	}
+}
+
+// execute 在任务管理器的工作者中执行任务并记录结果
+func (s *Scheduler) execute(ctx context.Context, job *models.ScheduledJob) error {
+	h, ok := s.handler(job.Kind)
+	if !ok {
+		return fmt.Errorf("未知的任务类型: %s", job.Kind)
+	}
+	err := h.run(ctx, job)
+	s.finish(job, err)
+	return err
+}
+
+// finish 记录任务的执行结果
+func (s *Scheduler) finish(job *models.ScheduledJob, err error) {
+	message := ""
+	if err != nil {
+		message = err.Error()
+		if runes := []rune(message); len(runes) > maxErrorLen {
+			message = string(runes[:maxErrorLen])
+		}
+		logrus.WithField("job", job.Name).Warnf("定时任务执行失败: %v", err)
+	} else {
+		logrus.WithField("job", job.Name).Info("定时任务执行完成")
+	}
+	if dbErr := s.db.Model(&models.ScheduledJob{}).Where("id = ?", job.ID).
+		Update("last_error", message).Error; dbErr != nil {
+		logrus.WithError(dbErr).WithField("job", job.Name).Error("记录定时任务结果失败")
+	}
+}
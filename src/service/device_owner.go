@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeviceNotFound = errors.New("设备不存在")
+	ErrUserNotFound   = errors.New("用户不存在")
+)
+
+// BindUser 把设备绑定到用户。绑定后设备使用该用户的设置、技能和长期记忆，
+// 同一用户的设备之间可以转移会话
+func (s *DeviceService) BindUser(deviceID string, userID int64) error {
+	if database.DB == nil {
+		return fmt.Errorf("数据库未连接")
+	}
+	if userID <= 0 {
+		return ErrUserNotFound
+	}
+	var user models.User
+	if err := database.DB.Select("id").Where("id = ?", userID).Take(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("查询用户失败: %v", err)
+	}
+	return s.setDeviceUser(deviceID, userID)
+}
+
+// UnbindUser 解除设备与用户的绑定
+func (s *DeviceService) UnbindUser(deviceID string) error {
+	if database.DB == nil {
+		return fmt.Errorf("数据库未连接")
+	}
+	return s.setDeviceUser(deviceID, 0)
+}
+
+// DeviceUser 查询设备绑定的用户ID，0表示未绑定
+func (s *DeviceService) DeviceUser(deviceID string) (int64, error) {
+	return DeviceOwner(deviceID)
+}
+
+// DeviceOwner 查询设备绑定的用户ID，0表示未绑定。按用户区分的提供者选择、技能配置和长期记忆都以此为准
+func DeviceOwner(deviceID string) (int64, error) {
+	if database.DB == nil {
+		return 0, fmt.Errorf("数据库未连接")
+	}
+	var device models.Device
+	if err := database.DB.Select("user_id").Where("device_id = ?", deviceID).Take(&device).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrDeviceNotFound
+		}
+		return 0, fmt.Errorf("查询设备失败: %v", err)
+	}
+	return device.UserID, nil
+}
+
+// setDeviceUser 更新设备的所属用户
+func (s *DeviceService) setDeviceUser(deviceID string, userID int64) error {
+	result := database.DB.Model(&models.Device{}).Where("device_id = ?", deviceID).Update("user_id", userID)
+	if result.Error != nil {
+		return fmt.Errorf("更新设备所属用户失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
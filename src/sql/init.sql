@@ -80,6 +80,94 @@ CREATE TABLE `module_configs` (
     UNIQUE KEY `idx_name` (`name`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='模块配置表';
 
+-- ==============================================
+-- 5. token用量表 (usage_records)
+-- ==============================================
+DROP TABLE IF EXISTS `usage_records`;
+CREATE TABLE `usage_records` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `device_id` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '设备ID',
+    `day` VARCHAR(10) NOT NULL COMMENT '日期（YYYY-MM-DD）',
+    `kind` VARCHAR(16) NOT NULL COMMENT '模块类型（LLM/VLLLM）',
+    `model` VARCHAR(100) NOT NULL DEFAULT '' COMMENT '模型名称',
+    `requests` BIGINT NOT NULL DEFAULT 0 COMMENT '请求次数',
+    `prompt_tokens` BIGINT NOT NULL DEFAULT 0 COMMENT '输入token数',
+    `completion_tokens` BIGINT NOT NULL DEFAULT 0 COMMENT '输出token数',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_usage_device_day` (`device_id`, `day`, `kind`, `model`),
+    KEY `idx_usage_records_day` (`day`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='token用量表';
+
+-- ==============================================
+-- 6. 设备个性化设置表 (device_profiles)
+-- ==============================================
+DROP TABLE IF EXISTS `device_profiles`;
+CREATE TABLE `device_profiles` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `device_id` VARCHAR(64) NOT NULL COMMENT '设备ID',
+    `nickname` VARCHAR(32) NOT NULL DEFAULT '' COMMENT '助手名字',
+    `voice` VARCHAR(100) NOT NULL DEFAULT '' COMMENT '音色',
+    `role_id` BIGINT NOT NULL DEFAULT 0 COMMENT '当前角色ID，0表示默认角色',
+    `onboarded_at` DATETIME NULL COMMENT '完成首次引导的时间',
+    `updated_at` DATETIME NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_device_profiles_device_id` (`device_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='设备个性化设置表';
+
+-- ==============================================
+-- 7. 助手角色表 (roles)
+-- ==============================================
+DROP TABLE IF EXISTS `roles`;
+CREATE TABLE `roles` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `name` VARCHAR(32) NOT NULL COMMENT '角色名称',
+    `description` VARCHAR(200) NOT NULL DEFAULT '' COMMENT '角色简介，提供给LLM选择角色',
+    `prompt` TEXT COMMENT '角色提示词，为空时使用默认提示词',
+    `voice` VARCHAR(100) NOT NULL DEFAULT '' COMMENT '音色，为空时使用默认音色',
+    `llm` VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'LLM配置名称，为空时使用默认LLM',
+    `created_at` DATETIME NULL COMMENT '创建时间',
+    `updated_at` DATETIME NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_roles_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='助手角色表';
+
+-- ==============================================
+-- 8. 用户声纹表 (voiceprints)
+-- ==============================================
+DROP TABLE IF EXISTS `voiceprints`;
+CREATE TABLE `voiceprints` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `user_id` BIGINT NOT NULL COMMENT '用户ID',
+    `label` VARCHAR(32) NOT NULL DEFAULT '' COMMENT '备注，如录音场景',
+    `embedding` JSON COMMENT '声纹向量（JSON数组）',
+    `created_at` DATETIME NULL COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_voiceprints_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='用户声纹表';
+
+-- ==============================================
+-- 9. 对话录音表 (recordings)
+-- ==============================================
+DROP TABLE IF EXISTS `recordings`;
+CREATE TABLE `recordings` (
+    `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `session_id` VARCHAR(64) NOT NULL COMMENT '会话ID',
+    `device_id` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '设备ID',
+    `round` INT NOT NULL DEFAULT 0 COMMENT '会话内的轮次',
+    `kind` VARCHAR(8) NOT NULL DEFAULT '' COMMENT '录音类型（user/reply）',
+    `sentence` INT NOT NULL DEFAULT 0 COMMENT '回复中的句子序号，用户录音为0',
+    `text` TEXT COMMENT '识别结果或回复文本',
+    `path` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '音频文件相对录音目录的路径',
+    `format` VARCHAR(8) NOT NULL DEFAULT '' COMMENT '音频格式（wav/mp3等）',
+    `size` BIGINT NOT NULL DEFAULT 0 COMMENT '文件大小（字节）',
+    `duration_ms` BIGINT NOT NULL DEFAULT 0 COMMENT '音频时长（毫秒），未知时为0',
+    `created_at` DATETIME NULL COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_recordings_session_id` (`session_id`),
+    KEY `idx_recordings_device_id` (`device_id`),
+    KEY `idx_recordings_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='对话录音表';
+
 -- ==============================================
 -- 插入默认数据
 -- ==============================================
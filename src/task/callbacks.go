@@ -1,6 +1,8 @@
 package task
 
 import (
+	"errors"
+
 	"github.com/sirupsen/logrus"
 )
 
@@ -35,9 +37,13 @@ func (cb *CallBack) OnError(err error) {
 					logrus.WithField("panic", r).Error("Error callback panic recovered")
 				}
 			}()
+			status := "failed"
+			if errors.Is(err, ErrTaskTimeout) {
+				status = "timeout"
+			}
 			result := map[string]interface{}{
 				"error":  err.Error(),
-				"status": "failed",
+				"status": status,
 			}
 			cb.taskCallback(result)
 		}()
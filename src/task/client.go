@@ -1,7 +1,6 @@
 package task
 
 import (
-	"fmt"
 	"sync"
 	"time"
 
@@ -10,13 +9,15 @@ import (
 
 // ClientManager manages client contexts and resources
 type ClientManager struct {
+	config  ResourceConfig
 	clients map[string]*ClientContext
 	mu      sync.RWMutex
 }
 
 // NewClientManager creates a new client manager
-func NewClientManager() *ClientManager {
+func NewClientManager(config ResourceConfig) *ClientManager {
 	return &ClientManager{
+		config:  config,
 		clients: make(map[string]*ClientContext),
 	}
 }
@@ -37,12 +38,47 @@ func (cm *ClientManager) GetClientContext(clientID string) (*ClientContext, erro
 		TaskQueue:          make(chan *Task, 100),
 		ActiveTasks:        make(map[string]*Task),
 		ResourceQuota:      NewResourceQuota(),
+		limiter:            newTokenBucket(cm.config.RatePerMinute, cm.config.RateBurst),
+	}
+	if cm.config.MaxTasksPerClient > 0 {
+		ctx.MaxConcurrentTasks = cm.config.MaxTasksPerClient
+		ctx.ResourceQuota.MaxConcurrentTasks = cm.config.MaxTasksPerClient
 	}
 
 	cm.clients[clientID] = ctx
 	return ctx, nil
 }
 
+// admit checks the client's rate limit and quotas before accepting a task,
+// returns a *QuotaError when the task is rejected
+func (c *ClientContext) admit() error {
+	if c.limiter != nil {
+		if ok, wait := c.limiter.take(time.Now()); !ok {
+			return &QuotaError{ClientID: c.ID, Reason: QuotaRateLimited, RetryAfter: wait}
+		}
+	}
+	if err := c.ResourceQuota.TryIncrementQuota(); err != nil {
+		// 被配额拒绝的任务不消耗提交频率
+		if c.limiter != nil {
+			c.limiter.refund()
+		}
+		if quotaErr, ok := err.(*QuotaError); ok {
+			quotaErr.ClientID = c.ID
+		}
+		return err
+	}
+	return nil
+}
+
+// reject rolls back admit for a task that could not be queued
+func (c *ClientContext) reject(task *Task) {
+	c.ResourceQuota.DecrementQuota(task.Type) // 减少总配额
+	c.ResourceQuota.CompleteTask(task.Type)   // 减少并发计数
+	if c.limiter != nil {
+		c.limiter.refund()
+	}
+}
+
 func (cm *ClientManager) checkDailyReset() {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
@@ -123,10 +159,10 @@ func (rq *ResourceQuota) TryIncrementQuota() error {
 
 	// 原子检查和增加
 	if rq.TotalUsedQuota >= rq.MaxTotalTasks {
-		return fmt.Errorf("daily task quota exceeded")
+		return &QuotaError{Reason: QuotaDaily}
 	}
 	if rq.TotalRunningTasks >= rq.MaxConcurrentTasks {
-		return fmt.Errorf("concurrent task limit exceeded")
+		return &QuotaError{Reason: QuotaConcurrent}
 	}
 
 	rq.TotalUsedQuota++
@@ -0,0 +1,98 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "每天3点", expr: "0 3 * * *"},
+		{name: "列表范围和步长", expr: "*/15 8-18 * * 1-5"},
+		{name: "范围加步长", expr: "0-30/10 * * * *"},
+		{name: "起始值加步长", expr: "5/10 * * * *"},
+		{name: "星期日写作7", expr: "0 0 * * 7"},
+		{name: "简写", expr: "@daily"},
+		{name: "字段不足", expr: "0 3 * *", wantErr: true},
+		{name: "字段过多", expr: "0 3 * * * *", wantErr: true},
+		{name: "分钟超出范围", expr: "60 * * * *", wantErr: true},
+		{name: "日期不能为0", expr: "0 0 0 * *", wantErr: true},
+		{name: "月份超出范围", expr: "0 0 1 13 *", wantErr: true},
+		{name: "星期超出范围", expr: "0 0 * * 8", wantErr: true},
+		{name: "范围颠倒", expr: "0 18-8 * * *", wantErr: true},
+		{name: "步长为0", expr: "*/0 * * * *", wantErr: true},
+		{name: "非数字", expr: "a * * * *", wantErr: true},
+		{name: "未知简写", expr: "@never", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		expr     string
+		from     string
+		expected string // 为空表示找不到匹配的时间
+	}{
+		{name: "严格晚于起始时间", expr: "0 3 * * *", from: "2025-03-10 03:00", expected: "2025-03-11 03:00"},
+		{name: "忽略秒", expr: "* * * * *", from: "2025-03-10 03:00", expected: "2025-03-10 03:01"},
+		{name: "分钟步长", expr: "*/15 * * * *", from: "2025-03-10 10:16", expected: "2025-03-10 10:30"},
+		{name: "起始值加步长", expr: "5/20 * * * *", from: "2025-03-10 10:26", expected: "2025-03-10 10:45"},
+		{name: "范围加步长", expr: "0-30/10 * * * *", from: "2025-03-10 10:31", expected: "2025-03-10 11:00"},
+		{name: "列表", expr: "0 8,12,18 * * *", from: "2025-03-10 12:00", expected: "2025-03-10 18:00"},
+		{name: "工作日范围跨周末", expr: "*/15 8-18 * * 1-5", from: "2025-03-14 18:50", expected: "2025-03-17 08:00"},
+		{name: "星期日写作7", expr: "0 9 * * 7", from: "2025-03-10 00:00", expected: "2025-03-16 09:00"},
+		{name: "星期日写作0", expr: "0 9 * * 0", from: "2025-03-10 00:00", expected: "2025-03-16 09:00"},
+		{name: "日期和星期都限制时满足任一", expr: "0 0 13 * 5", from: "2025-06-01 00:00", expected: "2025-06-06 00:00"},
+		{name: "日期和星期都限制时日期先到", expr: "0 0 13 * 5", from: "2025-06-07 00:00", expected: "2025-06-13 00:00"},
+		{name: "只限制星期", expr: "0 0 * * 1", from: "2025-06-01 00:00", expected: "2025-06-02 00:00"},
+		{name: "只限制日期", expr: "0 0 15 * *", from: "2025-06-16 00:00", expected: "2025-07-15 00:00"},
+		{name: "跳过没有31日的月份", expr: "0 0 31 * *", from: "2025-04-01 00:00", expected: "2025-05-31 00:00"},
+		{name: "跨年", expr: "0 0 1 1 *", from: "2025-12-31 23:59", expected: "2026-01-01 00:00"},
+		{name: "月末跨月", expr: "30 23 * * *", from: "2025-01-31 23:30", expected: "2025-02-01 23:30"},
+		{name: "闰年2月29日", expr: "0 0 29 2 *", from: "2025-03-01 00:00", expected: "2028-02-29 00:00"},
+		{name: "指定月份", expr: "0 6 1 3,9 *", from: "2025-03-01 06:00", expected: "2025-09-01 06:00"},
+		{name: "简写每周", expr: "@weekly", from: "2025-03-10 00:00", expected: "2025-03-16 00:00"},
+		{name: "简写每月", expr: "@monthly", from: "2025-03-10 00:00", expected: "2025-04-01 00:00"},
+		{name: "不存在的日期", expr: "0 0 30 2 *", from: "2025-01-01 00:00", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) error = %v", tt.expr, err)
+			}
+			got := schedule.Next(at(tt.from).Add(25 * time.Second))
+			if tt.expected == "" {
+				if !got.IsZero() {
+					t.Errorf("Next() = %v, want zero time", got)
+				}
+				return
+			}
+			if want := at(tt.expected); !got.Equal(want) {
+				t.Errorf("Next() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package task
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -13,12 +14,13 @@ type TaskManager struct {
 	workerPool     *WorkerPool
 	scheduledTasks *ScheduledTasks
 	clientManager  *ClientManager
+	shared         *sharedConsumer // 多实例共享的任务队列，未配置时为nil
 }
 
 // NewTaskManager creates a new TaskManager instance
 func NewTaskManager(config ResourceConfig) *TaskManager {
 	tm := &TaskManager{
-		clientManager: NewClientManager(),
+		clientManager: NewClientManager(config),
 	}
 
 	tm.workerPool = NewWorkerPool(config, nil, tm.clientManager)
@@ -32,10 +34,16 @@ func NewTaskManager(config ResourceConfig) *TaskManager {
 func (tm *TaskManager) Start() {
 	tm.workerPool.Start()
 	tm.scheduledTasks.Start()
+	if tm.shared != nil {
+		tm.shared.start(tm)
+	}
 }
 
 // Stop stops the task manager and its components
 func (tm *TaskManager) Stop() {
+	if tm.shared != nil {
+		tm.shared.stop()
+	}
 	tm.workerPool.Stop()
 	tm.scheduledTasks.Stop()
 }
@@ -53,6 +61,20 @@ func (tm *TaskManager) SubmitTask(clientID string, task *Task) error {
 	return tm.submitImmediateTask(clientID, task)
 }
 
+// SubmitInternalTask submits a server-internal task that bypasses client quotas,
+// bounded only by the worker pool capacity
+func (tm *TaskManager) SubmitInternalTask(task *Task) error {
+	if _, exists := GetTaskExecutor(task.Type); !exists {
+		return fmt.Errorf("task type %v is not registered", task.Type)
+	}
+	tm.workerPool.track(task, "")
+	if err := tm.workerPool.Submit(task); err != nil {
+		tm.workerPool.tracker.discard(task)
+		return err
+	}
+	return nil
+}
+
 // submitImmediateTask submits a task for immediate execution
 func (tm *TaskManager) submitImmediateTask(clientID string, task *Task) error {
 	// Get or create client context
@@ -61,17 +83,18 @@ func (tm *TaskManager) submitImmediateTask(clientID string, task *Task) error {
 		return fmt.Errorf("failed to get client context: %v", err)
 	}
 
-	// 原子检查和增加配额
-	if err := ctx.ResourceQuota.TryIncrementQuota(); err != nil {
+	// 检查提交频率，原子检查和增加配额
+	if err := ctx.admit(); err != nil {
 		return err
 	}
 
 	task.ClinetID = clientID
+	tm.workerPool.track(task, clientID)
 
 	// 提交到工作池，失败时回滚
 	if err := tm.workerPool.Submit(task); err != nil {
-		ctx.ResourceQuota.DecrementQuota(task.Type) // 减少总配额
-		ctx.ResourceQuota.CompleteTask(task.Type)   // 减少并发计数
+		tm.workerPool.tracker.discard(task)
+		ctx.reject(task)
 		return err
 	}
 
@@ -89,15 +112,85 @@ func (tm *TaskManager) scheduleTask(clientID string, task *Task) error {
 		return fmt.Errorf("failed to get client context: %v", err)
 	}
 
-	// 检查是否可以接受任务（使用总配额检查）
-	if err := ctx.ResourceQuota.TryIncrementQuota(); err != nil {
+	// 检查是否可以接受任务（提交频率和总配额）
+	if err := ctx.admit(); err != nil {
 		return err
 	}
 
+	// 执行结束后释放并发计数
+	task.ClinetID = clientID
+	tm.workerPool.track(task, clientID)
 	tm.scheduledTasks.AddTask(task)
 	return nil
 }
 
+// ListTasks returns the unfinished and recently finished tasks, newest first.
+// status 为空时返回全部，否则只返回该状态的任务
+func (tm *TaskManager) ListTasks(status TaskStatus) []TaskInfo {
+	infos := tm.workerPool.tracker.list()
+	if status == "" {
+		return infos
+	}
+	filtered := infos[:0]
+	for _, info := range infos {
+		if info.Status == status {
+			filtered = append(filtered, info)
+		}
+	}
+	return filtered
+}
+
+// PoolStats is a snapshot of the worker pool load
+type PoolStats struct {
+	Workers           int                    `json:"workers"`
+	BusyWorkers       int                    `json:"busy_workers"`
+	Abandoned         int                    `json:"abandoned"` // 超时或取消后执行器仍未返回的任务数
+	RunningBackground int                    `json:"running_background"`
+	BackgroundLimit   int                    `json:"background_limit"`
+	Queued            map[string]int         `json:"queued"` // 按优先级统计的排队任务数
+	Scheduled         int                    `json:"scheduled"`
+	Types             map[TaskType]TypeStats `json:"types"`
+}
+
+// Stats returns the current pool load and the per-type counters
+func (tm *TaskManager) Stats() PoolStats {
+	wp := tm.workerPool
+	stats := PoolStats{
+		Workers:           wp.config.MaxWorkers,
+		BusyWorkers:       int(atomic.LoadInt32(&wp.runningTasks)),
+		Abandoned:         int(atomic.LoadInt32(&wp.abandoned)),
+		RunningBackground: int(atomic.LoadInt32(&wp.runningBackground)),
+		BackgroundLimit:   int(wp.backgroundLimit),
+		Queued:            make(map[string]int, len(priorityOrder)),
+		Scheduled:         tm.scheduledTasks.count(),
+		Types:             wp.tracker.statsSnapshot(),
+	}
+	for _, priority := range priorityOrder {
+		stats.Queued[priority.String()] = len(wp.queues[priority])
+	}
+	return stats
+}
+
+// CancelTask cancels an unfinished task by ID. 排队和等待重试的任务不再执行，
+// 执行中的任务取消其context，由执行器自行退出，工作者立即释放。
+// 任务的回调以 ErrCanceled 调用 OnError，未知或已结束的任务返回 ErrTaskNotFound
+func (tm *TaskManager) CancelTask(id string) error {
+	task, ok := tm.workerPool.tracker.get(id)
+	if !ok {
+		return ErrTaskNotFound
+	}
+	tm.workerPool.tracker.canceling(task)
+	if tm.scheduledTasks.remove(id) {
+		tm.workerPool.finishTask(task, ErrCanceled)
+		return nil
+	}
+	if task.cancel != nil {
+		task.cancel(ErrCanceled)
+	}
+	logrus.WithField("taskID", id).Info("任务已取消")
+	return nil
+}
+
 // ScheduledTasks manages scheduled tasks
 type ScheduledTasks struct {
 	tasks      map[string]*Task
@@ -135,6 +228,24 @@ func (st *ScheduledTasks) AddTask(task *Task) {
 	st.tasks[task.ID] = task
 }
 
+// remove removes a task that has not been due yet, returns false when it is not scheduled
+func (st *ScheduledTasks) remove(id string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.tasks[id]; !ok {
+		return false
+	}
+	delete(st.tasks, id)
+	return true
+}
+
+// count returns the number of tasks waiting for their scheduled time
+func (st *ScheduledTasks) count() int {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return len(st.tasks)
+}
+
 // run processes scheduled tasks
 func (st *ScheduledTasks) run() {
 	for {
@@ -169,7 +280,8 @@ func (st *ScheduledTasks) processScheduledTasks() {
 							logrus.WithField("panic", r).Error("Scheduled task panic")
 						}
 					}()
-					t.Execute()
+					st.workerPool.tracker.started(t)
+					st.workerPool.finishTask(t, t.run(t.baseContext()))
 				}(task)
 			}
 			delete(st.tasks, id)
@@ -0,0 +1,102 @@
+package task
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// QuotaReason tells why a task submission was rejected
+type QuotaReason string
+
+const (
+	QuotaRateLimited QuotaReason = "rate_limited" // 提交过于频繁
+	QuotaDaily       QuotaReason = "daily_quota"  // 当天的任务数已用完
+	QuotaConcurrent  QuotaReason = "concurrent"   // 同时执行的任务过多
+)
+
+// QuotaError is returned by SubmitTask when a client exceeds its quota or rate limit,
+// callers can use errors.As to tell the user when to try again
+type QuotaError struct {
+	ClientID   string
+	Reason     QuotaReason
+	RetryAfter time.Duration // 限流时距离下一个可用令牌的时间，其他原因为0
+}
+
+func (e *QuotaError) Error() string {
+	switch e.Reason {
+	case QuotaRateLimited:
+		return fmt.Sprintf("task rate limit exceeded, retry after %s", e.RetryAfter.Round(time.Second))
+	case QuotaDaily:
+		return "daily task quota exceeded"
+	default:
+		return "concurrent task limit exceeded"
+	}
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to whole seconds, for Retry-After headers
+func (e *QuotaError) RetryAfterSeconds() int {
+	seconds := int((e.RetryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// tokenBucket limits task submissions of one client, refilled continuously at rate per second
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket, returns nil when ratePerMinute is not positive
+func newTokenBucket(ratePerMinute float64, burst int) *tokenBucket {
+	if ratePerMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(ratePerMinute)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   ratePerMinute / 60,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// take consumes one token, when the bucket is empty returns false and the wait for the next token
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// refund returns a token taken for a submission that was rejected later
+func (b *tokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
@@ -0,0 +1,124 @@
+package task
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const redisIOTimeout = 5 * time.Second
+
+// RedisOptions configures a RedisQueue
+type RedisOptions struct {
+	Addr       string // host:port
+	Username   string // Redis 6 ACL 用户名，为空时只用密码认证
+	Password   string
+	DB         int
+	TLS        bool   // 使用TLS连接
+	Key        string // 队列的键名，处理中的任务保存在 <Key>:processing:<InstanceID>
+	InstanceID string // 实例标识，需在实例间唯一且重启后不变
+}
+
+// RedisQueue is a SharedQueue on a Redis list. 弹出的任务原子地移入本实例的处理中列表，
+// 执行结束后删除，实例异常退出时留下的任务在下次启动时放回队列。
+// 连接池、认证和断线重连由 go-redis 处理
+type RedisQueue struct {
+	client        *redis.Client
+	key           string
+	processingKey string
+}
+
+// NewRedisQueue connects to Redis and requeues the tasks this instance left unfinished
+func NewRedisQueue(opts RedisOptions) (*RedisQueue, error) {
+	if opts.Addr == "" || opts.Key == "" || opts.InstanceID == "" {
+		return nil, fmt.Errorf("Redis地址、键名和实例标识不能为空")
+	}
+	options := &redis.Options{
+		Addr:         opts.Addr,
+		Username:     opts.Username,
+		Password:     opts.Password,
+		DB:           opts.DB,
+		DialTimeout:  redisIOTimeout,
+		ReadTimeout:  redisIOTimeout,
+		WriteTimeout: redisIOTimeout,
+	}
+	if opts.TLS {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	q := &RedisQueue{
+		client:        redis.NewClient(options),
+		key:           opts.Key,
+		processingKey: opts.Key + ":processing:" + opts.InstanceID,
+	}
+	recovered, err := q.recover(context.Background())
+	if err != nil {
+		q.client.Close()
+		return nil, fmt.Errorf("连接Redis失败: %v", err)
+	}
+	if recovered > 0 {
+		logrus.WithField("count", recovered).Info("已将上次未完成的共享任务放回队列")
+	}
+	return q, nil
+}
+
+// recover moves the tasks left in the processing list back to the queue
+func (q *RedisQueue) recover(ctx context.Context) (int, error) {
+	count := 0
+	for {
+		err := q.client.RPopLPush(ctx, q.processingKey, q.key).Err()
+		if errors.Is(err, redis.Nil) {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// Push appends a task to the queue
+func (q *RedisQueue) Push(ctx context.Context, msg *SharedMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化共享任务失败: %v", err)
+	}
+	return q.client.LPush(ctx, q.key, data).Err()
+}
+
+// Pop waits up to timeout for the next task and moves it to the processing list.
+// ctx 取消时中断阻塞的读取，已移入处理中列表的任务在下次启动时放回队列
+func (q *RedisQueue) Pop(ctx context.Context, timeout time.Duration) (*SharedMessage, error) {
+	if timeout < time.Second {
+		timeout = time.Second
+	}
+	raw, err := q.client.BRPopLPush(ctx, q.key, q.processingKey, timeout).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	msg := &SharedMessage{raw: raw}
+	if err := json.Unmarshal([]byte(raw), msg); err != nil {
+		// 无法解析的任务直接删除，避免反复读取
+		q.Ack(context.Background(), msg)
+		return nil, fmt.Errorf("解析共享任务失败: %v", err)
+	}
+	return msg, nil
+}
+
+// Ack removes a popped task from the processing list
+func (q *RedisQueue) Ack(ctx context.Context, msg *SharedMessage) error {
+	return q.client.LRem(ctx, q.processingKey, 1, msg.raw).Err()
+}
+
+// Close closes the connection pool
+func (q *RedisQueue) Close() error {
+	return q.client.Close()
+}
@@ -0,0 +1,86 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisQueue(t *testing.T, server *miniredis.Miniredis) *RedisQueue {
+	t.Helper()
+	q, err := NewRedisQueue(RedisOptions{Addr: server.Addr(), Key: "tasks", InstanceID: "node-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestRedisQueuePushPopAck(t *testing.T) {
+	server := miniredis.RunT(t)
+	q := newTestRedisQueue(t, server)
+	defer q.Close()
+	ctx := context.Background()
+
+	retry := RetryPolicy{MaxAttempts: 3, Backoff: time.Minute}
+	for _, id := range []string{"a", "b"} {
+		if err := q.Push(ctx, &SharedMessage{ID: id, Type: "test", Payload: []byte(`{}`), Retry: retry}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 先入先出，弹出的任务移入处理中列表
+	msg, err := q.Pop(ctx, time.Second)
+	if err != nil || msg == nil {
+		t.Fatalf("Pop = %v, %v", msg, err)
+	}
+	if msg.ID != "a" || msg.Retry != retry {
+		t.Errorf("popped %+v, want task a with its retry policy", msg)
+	}
+	if items, _ := server.List("tasks:processing:node-1"); len(items) != 1 {
+		t.Errorf("processing list = %v, want the popped task", items)
+	}
+
+	if err := q.Ack(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if server.Exists("tasks:processing:node-1") {
+		t.Error("acked task still in the processing list")
+	}
+}
+
+func TestRedisQueuePopTimesOutOnEmptyQueue(t *testing.T) {
+	server := miniredis.RunT(t)
+	q := newTestRedisQueue(t, server)
+	defer q.Close()
+
+	msg, err := q.Pop(context.Background(), time.Second)
+	if err != nil || msg != nil {
+		t.Fatalf("Pop = %v, %v, want nil, nil", msg, err)
+	}
+}
+
+func TestNewRedisQueueRequeuesUnfinishedTasks(t *testing.T) {
+	server := miniredis.RunT(t)
+	q := newTestRedisQueue(t, server)
+	ctx := context.Background()
+	if err := q.Push(ctx, &SharedMessage{ID: "a", Type: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := q.Pop(ctx, time.Second); err != nil || msg == nil {
+		t.Fatalf("Pop = %v, %v", msg, err)
+	}
+	// 未确认就退出，模拟实例崩溃
+	q.Close()
+
+	q = newTestRedisQueue(t, server)
+	defer q.Close()
+	if server.Exists("tasks:processing:node-1") {
+		t.Error("processing list not emptied on restart")
+	}
+	msg, err := q.Pop(ctx, time.Second)
+	if err != nil || msg == nil || msg.ID != "a" {
+		t.Fatalf("Pop after restart = %v, %v, want task a", msg, err)
+	}
+}
@@ -0,0 +1,152 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultRetryBackoff = time.Second
+
+// RetryPolicy configures retries of a failed task. The zero value disables retries
+type RetryPolicy struct {
+	MaxAttempts int           // 最多执行次数（含首次），不大于1时不重试
+	Backoff     time.Duration // 第一次重试前的等待时长，之后每次翻倍，为0时使用1秒
+	MaxBackoff  time.Duration // 等待时长上限，为0时不限制
+	Jitter      float64       // 等待时长的随机浮动比例（0~1），避免大量任务同时重试
+}
+
+// TaskAttempt records one execution of a task
+type TaskAttempt struct {
+	Number     int
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Error      string // 成功时为空
+	Abandoned  bool   // 超时或取消后执行器仍未返回，工作者不再等待
+}
+
+// permanentError marks an error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the task fails immediately without retrying,
+// e.g. for invalid params returned from an executor
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// shouldRetry reports whether the task may run again after err
+func (t *Task) shouldRetry(err error) bool {
+	if err == nil || err == errTaskCanceled || len(t.Attempts) >= t.Retry.MaxAttempts {
+		return false
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	if n := len(t.Attempts); n > 0 && t.Attempts[n-1].Abandoned {
+		// 上一次执行仍在运行，重试会与其并行执行同一个任务
+		logrus.WithField("taskID", t.ID).Warn("任务执行超时后仍未退出，不再重试")
+		return false
+	}
+	// 连接断开等原因取消的任务不再重试
+	return t.baseContext().Err() == nil
+}
+
+// delay returns the wait before the retry that follows the given attempt number
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		backoff += time.Duration((rand.Float64()*2 - 1) * jitter * float64(backoff))
+	}
+	return backoff
+}
+
+// baseContext returns the context the task was submitted with
+func (t *Task) baseContext() context.Context {
+	if t.baseCtx == nil {
+		t.baseCtx = t.Context
+	}
+	return t.baseCtx
+}
+
+// retryLater resubmits the task after the backoff of its last attempt.
+// 重试等待期间任务仍占用客户端的并发配额，最终结束时才释放
+func (wp *WorkerPool) retryLater(task *Task, err error) {
+	attempt := len(task.Attempts)
+	delay := task.Retry.delay(attempt)
+	task.Status = TaskStatusRetrying
+	task.Error = err
+	wp.tracker.retrying(task, err)
+	logrus.WithFields(logrus.Fields{
+		"taskID":  task.ID,
+		"attempt": attempt,
+		"delay":   delay,
+	}).Warnf("任务执行失败，稍后重试: %v", err)
+
+	time.AfterFunc(delay, func() {
+		if ctxErr := task.baseContext().Err(); ctxErr != nil {
+			if errors.Is(context.Cause(task.baseContext()), ErrCanceled) {
+				err = ErrCanceled
+			}
+			wp.finishTask(task, err)
+			return
+		}
+		if submitErr := wp.Submit(task); submitErr != nil {
+			wp.finishTask(task, err)
+		}
+	})
+}
+
+// finishTask ends the task with its final error and releases its client slot
+func (wp *WorkerPool) finishTask(task *Task, err error) {
+	if err != nil && len(task.Attempts) > 1 {
+		logrus.WithFields(logrus.Fields{
+			"taskID":   task.ID,
+			"attempts": len(task.Attempts),
+		}).Errorf("任务重试后仍然失败: %v", err)
+	}
+	task.finish(err)
+	wp.tracker.finished(task, err)
+	if task.cancel != nil {
+		// 释放可取消context的资源
+		task.cancel(nil)
+	}
+	wp.releaseClientTask(task)
+}
+
+// releaseClientTask 任务结束，减少客户端的并发计数
+func (wp *WorkerPool) releaseClientTask(task *Task) {
+	if task.ClinetID != "" && wp.clientManager != nil {
+		if ctx, err := wp.clientManager.GetClientContext(task.ClinetID); err == nil {
+			ctx.ResourceQuota.CompleteTask(task.Type)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// resultCallback 把任务的最终结果发送到通道
+type resultCallback struct {
+	result chan interface{}
+	err    chan error
+}
+
+func newResultCallback() *resultCallback {
+	return &resultCallback{result: make(chan interface{}, 1), err: make(chan error, 1)}
+}
+
+func (c *resultCallback) OnComplete(result interface{}) { c.result <- result }
+
+func (c *resultCallback) OnError(err error) { c.err <- err }
+
+func startTestManager(t *testing.T) *TaskManager {
+	t.Helper()
+	tm := NewTaskManager(ResourceConfig{MaxWorkers: 2, MaxTasksPerClient: 2})
+	tm.Start()
+	t.Cleanup(tm.Stop)
+	return tm
+}
+
+func TestAbandonedAttemptIsNotRetried(t *testing.T) {
+	const taskType TaskType = "test_abandoned_attempt"
+	var runs atomic.Int32
+	release := make(chan struct{})
+	RegisterTaskExecutor(taskType, func(task *Task) error {
+		runs.Add(1)
+		// 不响应context取消，超时后仍在运行并继续读写任务
+		<-release
+		_ = task.Context.Err()
+		task.Result = "late"
+		return nil
+	})
+
+	tm := startTestManager(t)
+	callback := newResultCallback()
+	task, _ := NewTask(context.Background(), taskType, nil)
+	task.Timeout = 20 * time.Millisecond
+	task.Retry = RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	task.Callback = callback
+	if err := tm.SubmitInternalTask(task); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-callback.err:
+		if !errors.Is(err, ErrTaskTimeout) {
+			t.Errorf("err = %v, want ErrTaskTimeout", err)
+		}
+	case <-callback.result:
+		t.Fatal("timed out task completed")
+	case <-time.After(time.Second):
+		t.Fatal("task did not finish")
+	}
+
+	// 被放弃的执行器返回后不影响已结束的任务，也没有并行的重试
+	close(release)
+	time.Sleep(50 * time.Millisecond)
+	if n := runs.Load(); n != 1 {
+		t.Errorf("executor ran %d times, want 1", n)
+	}
+	if len(task.Attempts) != 1 || !task.Attempts[0].Abandoned {
+		t.Errorf("attempts = %+v, want one abandoned attempt", task.Attempts)
+	}
+}
+
+func TestRetriedAttemptResultReachesCallback(t *testing.T) {
+	const taskType TaskType = "test_retried_result"
+	var runs atomic.Int32
+	RegisterTaskExecutor(taskType, func(task *Task) error {
+		if runs.Add(1) == 1 {
+			task.Result = "first"
+			return errors.New("temporary failure")
+		}
+		task.Result = "second"
+		return nil
+	})
+
+	tm := startTestManager(t)
+	callback := newResultCallback()
+	task, _ := NewTask(context.Background(), taskType, nil)
+	task.Retry = RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+	task.Callback = callback
+	if err := tm.SubmitInternalTask(task); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case result := <-callback.result:
+		if result != "second" {
+			t.Errorf("result = %v, want the result of the last attempt", result)
+		}
+	case err := <-callback.err:
+		t.Fatalf("task failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("task did not finish")
+	}
+}
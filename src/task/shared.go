@@ -0,0 +1,215 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// sharedPopTimeout 消费协程每次等待共享队列的时长，超时后重新检查是否已停止
+const sharedPopTimeout = 5 * time.Second
+
+// SharedQueue is a queue backend shared by several server instances. Tasks submitted
+// with SubmitShared go through it and run on whichever instance pops them first
+type SharedQueue interface {
+	// Push appends a task to the queue
+	Push(ctx context.Context, msg *SharedMessage) error
+	// Pop waits up to timeout for the next task, returns nil when there is none
+	Pop(ctx context.Context, timeout time.Duration) (*SharedMessage, error)
+	// Ack removes a popped task after it finished, successfully or not
+	Ack(ctx context.Context, msg *SharedMessage) error
+	// Close releases the connections of the queue
+	Close() error
+}
+
+// SharedMessage is a task encoded for a SharedQueue
+type SharedMessage struct {
+	ID         string          `json:"id"`
+	Type       TaskType        `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	Retry      RetryPolicy     `json:"retry"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+
+	raw string // 队列中的原始编码，Ack时按原值删除
+}
+
+// sharedConsumer pulls tasks from the shared queue into the local worker pool
+type sharedConsumer struct {
+	queue     SharedQueue
+	consumers int
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// UseSharedQueue makes SubmitShared go through queue, consumers tasks of the queue run
+// on this instance at once. 需在 Start 之前调用
+func (tm *TaskManager) UseSharedQueue(queue SharedQueue, consumers int) {
+	if consumers <= 0 {
+		consumers = int(tm.workerPool.backgroundLimit)
+	}
+	tm.shared = &sharedConsumer{queue: queue, consumers: consumers}
+}
+
+// SubmitShared submits a background task whose params are JSON encoded so any server
+// instance can run it. The executor reads the params with DecodeParams, retry applies
+// on the instance that runs the task. 未配置共享队列时在本实例执行。
+// 依赖本实例内存状态的任务（如转写、长文本合成的进度）不能通过共享队列提交
+func (tm *TaskManager) SubmitShared(taskType TaskType, params interface{}, retry RetryPolicy) (string, error) {
+	if _, exists := GetTaskExecutor(taskType); !exists {
+		return "", fmt.Errorf("task type %v is not registered", taskType)
+	}
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("序列化任务参数失败: %v", err)
+	}
+	msg := &SharedMessage{
+		ID:         uuid.New().String(),
+		Type:       taskType,
+		Payload:    payload,
+		Retry:      retry,
+		EnqueuedAt: time.Now(),
+	}
+	if tm.shared == nil {
+		return msg.ID, tm.SubmitInternalTask(msg.task(context.Background()))
+	}
+	if err := tm.shared.queue.Push(context.Background(), msg); err != nil {
+		return "", fmt.Errorf("提交到共享队列失败: %v", err)
+	}
+	return msg.ID, nil
+}
+
+// DecodeParams decodes the JSON params of a task submitted with SubmitShared
+func (t *Task) DecodeParams(v interface{}) error {
+	payload, ok := t.Params.(json.RawMessage)
+	if !ok {
+		return fmt.Errorf("invalid params for task type %v", t.Type)
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// task builds the local task of a shared message
+func (msg *SharedMessage) task(ctx context.Context) *Task {
+	return &Task{
+		ID:        msg.ID,
+		Type:      msg.Type,
+		Priority:  PriorityBackground,
+		Status:    TaskStatusPending,
+		Params:    msg.Payload,
+		Retry:     msg.Retry,
+		CreatedAt: msg.EnqueuedAt,
+		Context:   ctx,
+	}
+}
+
+// start starts the consumer goroutines
+func (sc *sharedConsumer) start(tm *TaskManager) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sc.cancel = cancel
+	for i := 0; i < sc.consumers; i++ {
+		sc.wg.Add(1)
+		go func() {
+			defer sc.wg.Done()
+			sc.consume(ctx, tm)
+		}()
+	}
+	logrus.WithField("consumers", sc.consumers).Info("共享任务队列已启动")
+}
+
+// stop stops consuming and closes the queue, 执行中的任务不确认，下次启动时重新入队
+func (sc *sharedConsumer) stop() {
+	if sc.cancel != nil {
+		sc.cancel()
+	}
+	sc.wg.Wait()
+	if err := sc.queue.Close(); err != nil {
+		logrus.WithError(err).Warn("关闭共享任务队列失败")
+	}
+}
+
+// consume runs tasks of the shared queue one at a time until ctx is done
+func (sc *sharedConsumer) consume(ctx context.Context, tm *TaskManager) {
+	for ctx.Err() == nil {
+		msg, err := sc.queue.Pop(ctx, sharedPopTimeout)
+		if err != nil {
+			if ctx.Err() == nil {
+				logrus.WithError(err).Warn("读取共享任务队列失败")
+				sleepContext(ctx, time.Second)
+			}
+			continue
+		}
+		if msg == nil {
+			continue
+		}
+		if !sc.run(ctx, tm, msg) {
+			return
+		}
+		if err := sc.queue.Ack(context.Background(), msg); err != nil {
+			logrus.WithError(err).WithField("taskID", msg.ID).Warn("确认共享任务失败")
+		}
+	}
+}
+
+// run executes a shared task in the local worker pool and waits for it,
+// returns false when the manager stopped before the task finished
+func (sc *sharedConsumer) run(ctx context.Context, tm *TaskManager, msg *SharedMessage) bool {
+	if _, exists := GetTaskExecutor(msg.Type); !exists {
+		// 其他版本的实例提交的任务，确认后丢弃
+		logrus.WithFields(logrus.Fields{
+			"taskID":   msg.ID,
+			"taskType": msg.Type,
+		}).Error("共享任务类型未注册，丢弃任务")
+		return true
+	}
+
+	done := make(chan struct{})
+	t := msg.task(ctx)
+	t.Callback = &doneCallback{done: done}
+	for {
+		err := tm.SubmitInternalTask(t)
+		if err == nil {
+			break
+		}
+		// 本地队列已满，稍后重试
+		if !sleepContext(ctx, time.Second) {
+			return false
+		}
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// doneCallback closes done when the task finished
+type doneCallback struct {
+	done chan struct{}
+	once sync.Once
+}
+
+func (c *doneCallback) OnComplete(result interface{}) {
+	c.once.Do(func() { close(c.done) })
+}
+
+func (c *doneCallback) OnError(err error) {
+	c.once.Do(func() { close(c.done) })
+}
+
+// sleepContext waits for d, returns false when ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
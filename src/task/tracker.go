@@ -0,0 +1,253 @@
+package task
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxFinishedTasks 保留的已结束任务数，供管理接口查看最近的任务
+const maxFinishedTasks = 200
+
+// ErrCanceled is the error of a task canceled through CancelTask
+var ErrCanceled = errors.New("task canceled")
+
+// ErrTaskNotFound is returned by CancelTask for unknown or already finished tasks
+var ErrTaskNotFound = errors.New("task not found")
+
+// TaskInfo is a snapshot of a task for the admin API
+type TaskInfo struct {
+	ID         string     `json:"id"`
+	Type       TaskType   `json:"type"`
+	Priority   string     `json:"priority"`
+	Status     TaskStatus `json:"status"`
+	ClientID   string     `json:"client_id,omitempty"`
+	Attempts   int        `json:"attempts"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	WaitMs     int64      `json:"wait_ms"`     // 从提交到首次开始执行，未开始时为至今的时长
+	DurationMs int64      `json:"duration_ms"` // 从首次开始执行到结束，包括重试等待，执行中为至今的时长
+}
+
+// TypeStats counts the tasks of one type since the manager started
+type TypeStats struct {
+	Submitted     int   `json:"submitted"`
+	Completed     int   `json:"completed"`
+	Failed        int   `json:"failed"`
+	Canceled      int   `json:"canceled"`
+	TimedOut      int   `json:"timed_out"`
+	Retried       int   `json:"retried"` // 重试的次数，同一任务可能重试多次
+	AvgDurationMs int64 `json:"avg_duration_ms"`
+	MaxDurationMs int64 `json:"max_duration_ms"`
+
+	totalDuration time.Duration
+	measured      int
+}
+
+// taskRecord tracks one unfinished task, info is only touched under the tracker lock
+type taskRecord struct {
+	task *Task
+	info TaskInfo
+}
+
+// tracker keeps the bookkeeping of submitted tasks for the admin API
+type tracker struct {
+	mu      sync.Mutex
+	active  map[string]*taskRecord
+	history []TaskInfo // 最近结束的任务，按结束顺序
+	stats   map[TaskType]*TypeStats
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		active: make(map[string]*taskRecord),
+		stats:  make(map[TaskType]*TypeStats),
+	}
+}
+
+func (tr *tracker) typeStats(taskType TaskType) *TypeStats {
+	stats, ok := tr.stats[taskType]
+	if !ok {
+		stats = &TypeStats{}
+		tr.stats[taskType] = stats
+	}
+	return stats
+}
+
+// submitted starts tracking a task before it is queued or scheduled
+func (tr *tracker) submitted(task *Task, clientID string) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.active[task.ID] = &taskRecord{
+		task: task,
+		info: TaskInfo{
+			ID:        task.ID,
+			Type:      task.Type,
+			Priority:  task.Priority.String(),
+			Status:    TaskStatusPending,
+			ClientID:  clientID,
+			CreatedAt: time.Now(),
+		},
+	}
+	tr.typeStats(task.Type).Submitted++
+}
+
+// discard stops tracking a task that could not be queued
+func (tr *tracker) discard(task *Task) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if _, ok := tr.active[task.ID]; ok {
+		delete(tr.active, task.ID)
+		tr.typeStats(task.Type).Submitted--
+	}
+}
+
+// started records the start of an attempt
+func (tr *tracker) started(task *Task) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	record, ok := tr.active[task.ID]
+	if !ok {
+		return
+	}
+	if record.info.StartedAt == nil {
+		now := time.Now()
+		record.info.StartedAt = &now
+	}
+	record.info.Status = TaskStatusRunning
+	record.info.Attempts++
+}
+
+// retrying records a failed attempt that will be retried
+func (tr *tracker) retrying(task *Task, err error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	record, ok := tr.active[task.ID]
+	if !ok {
+		return
+	}
+	record.info.Status = TaskStatusRetrying
+	record.info.Error = err.Error()
+	tr.typeStats(task.Type).Retried++
+}
+
+// canceling marks a task whose cancellation was requested
+func (tr *tracker) canceling(task *Task) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if record, ok := tr.active[task.ID]; ok && record.info.Status != TaskStatusRunning {
+		record.info.Status = TaskStatusCanceled
+	}
+}
+
+// finished moves a task to the finished history and updates the counters
+func (tr *tracker) finished(task *Task, err error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	record, ok := tr.active[task.ID]
+	if !ok {
+		return
+	}
+	delete(tr.active, task.ID)
+
+	now := time.Now()
+	info := record.info
+	info.FinishedAt = &now
+	info.Error = ""
+	stats := tr.typeStats(task.Type)
+	switch {
+	case err == nil:
+		info.Status = TaskStatusComplete
+		stats.Completed++
+	case isCanceled(err):
+		info.Status = TaskStatusCanceled
+		info.Error = err.Error()
+		stats.Canceled++
+	case errors.Is(err, ErrTaskTimeout):
+		info.Status = TaskStatusTimeout
+		info.Error = err.Error()
+		stats.TimedOut++
+	default:
+		info.Status = TaskStatusFailed
+		info.Error = err.Error()
+		stats.Failed++
+	}
+	if info.StartedAt != nil {
+		duration := now.Sub(*info.StartedAt)
+		stats.totalDuration += duration
+		stats.measured++
+		if ms := duration.Milliseconds(); ms > stats.MaxDurationMs {
+			stats.MaxDurationMs = ms
+		}
+	}
+
+	tr.history = append(tr.history, info)
+	if len(tr.history) > maxFinishedTasks {
+		tr.history = tr.history[len(tr.history)-maxFinishedTasks:]
+	}
+}
+
+// get returns an unfinished task by ID
+func (tr *tracker) get(id string) (*Task, bool) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	record, ok := tr.active[id]
+	if !ok {
+		return nil, false
+	}
+	return record.task, true
+}
+
+// list returns the unfinished and recently finished tasks, newest first
+func (tr *tracker) list() []TaskInfo {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	now := time.Now()
+	infos := make([]TaskInfo, 0, len(tr.active)+len(tr.history))
+	for _, record := range tr.active {
+		infos = append(infos, record.info)
+	}
+	infos = append(infos, tr.history...)
+	for i := range infos {
+		infos[i].WaitMs, infos[i].DurationMs = taskDurations(&infos[i], now)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.After(infos[j].CreatedAt)
+	})
+	return infos
+}
+
+// taskDurations returns the queue wait and run durations of a task in milliseconds
+func taskDurations(info *TaskInfo, now time.Time) (int64, int64) {
+	end := now
+	if info.FinishedAt != nil {
+		end = *info.FinishedAt
+	}
+	if info.StartedAt == nil {
+		return end.Sub(info.CreatedAt).Milliseconds(), 0
+	}
+	return info.StartedAt.Sub(info.CreatedAt).Milliseconds(), end.Sub(*info.StartedAt).Milliseconds()
+}
+
+// statsSnapshot returns a copy of the per-type counters
+func (tr *tracker) statsSnapshot() map[TaskType]TypeStats {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	snapshot := make(map[TaskType]TypeStats, len(tr.stats))
+	for taskType, stats := range tr.stats {
+		s := *stats
+		if s.measured > 0 {
+			s.AvgDurationMs = (s.totalDuration / time.Duration(s.measured)).Milliseconds()
+		}
+		snapshot[taskType] = s
+	}
+	return snapshot
+}
+
+// isCanceled reports whether err means the task was canceled rather than failed
+func isCanceled(err error) bool {
+	return err == errTaskCanceled || errors.Is(err, ErrCanceled)
+}
@@ -2,6 +2,7 @@ package task
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -24,17 +25,61 @@ const (
 	TaskStatusRunning  TaskStatus = "running"
 	TaskStatusComplete TaskStatus = "complete"
 	TaskStatusFailed   TaskStatus = "failed"
+	TaskStatusRetrying TaskStatus = "retrying" // 执行失败，等待重试
+	TaskStatusCanceled TaskStatus = "canceled" // 通过 CancelTask 或连接断开取消
+	TaskStatusTimeout  TaskStatus = "timeout"  // 执行超时
 )
 
+// DefaultTaskTimeout is the timeout of one attempt for task types without a registered timeout
+const DefaultTaskTimeout = 5 * time.Minute
+
+// TaskPriority represents the scheduling priority of a task
+type TaskPriority int
+
+const (
+	PriorityNormal     TaskPriority = iota // 默认优先级
+	PriorityRealtime                       // 实时对话任务，如TTS预合成，有空闲工作者时最先执行
+	PriorityBackground                     // 后台任务，如图片分析、报告生成，只能占用部分工作者
+)
+
+// priorityOrder 工作者空闲时按此顺序取任务
+var priorityOrder = []TaskPriority{PriorityRealtime, PriorityNormal, PriorityBackground}
+
+func (p TaskPriority) String() string {
+	switch p {
+	case PriorityRealtime:
+		return "realtime"
+	case PriorityBackground:
+		return "background"
+	default:
+		return "normal"
+	}
+}
+
+// TaskTypeFunc 内部闭包任务，Params 为 func() error，用于复用工作池执行服务端内部工作
+const TaskTypeFunc TaskType = "func"
+
+func init() {
+	RegisterTaskExecutor(TaskTypeFunc, func(t *Task) error {
+		fn, ok := t.Params.(func() error)
+		if !ok {
+			return fmt.Errorf("invalid params for task type %v", TaskTypeFunc)
+		}
+		return fn()
+	})
+}
+
 // TaskRegistry manages task type to executor mappings
 type TaskRegistry struct {
 	executors map[TaskType]TaskExecutor
+	timeouts  map[TaskType]time.Duration
 	mu        sync.RWMutex
 }
 
 // Global task registry instance
 var taskRegistry = &TaskRegistry{
 	executors: make(map[TaskType]TaskExecutor),
+	timeouts:  make(map[TaskType]time.Duration),
 }
 
 // RegisterTaskExecutor registers a task executor for a specific task type
@@ -53,6 +98,28 @@ func GetTaskExecutor(taskType TaskType) (TaskExecutor, bool) {
 	return executor, exists
 }
 
+// RegisterTaskTimeout sets the timeout of one attempt for a task type,
+// a non-positive timeout restores DefaultTaskTimeout
+func RegisterTaskTimeout(taskType TaskType, timeout time.Duration) {
+	taskRegistry.mu.Lock()
+	defer taskRegistry.mu.Unlock()
+	if timeout <= 0 {
+		delete(taskRegistry.timeouts, taskType)
+		return
+	}
+	taskRegistry.timeouts[taskType] = timeout
+}
+
+// GetTaskTimeout returns the timeout of one attempt for a task type
+func GetTaskTimeout(taskType TaskType) time.Duration {
+	taskRegistry.mu.RLock()
+	defer taskRegistry.mu.RUnlock()
+	if timeout, ok := taskRegistry.timeouts[taskType]; ok {
+		return timeout
+	}
+	return DefaultTaskTimeout
+}
+
 // GetRegisteredTaskTypes returns all registered task types
 func GetRegisteredTaskTypes() []TaskType {
 	taskRegistry.mu.RLock()
@@ -68,6 +135,7 @@ func GetRegisteredTaskTypes() []TaskType {
 type Task struct {
 	ID            string
 	Type          TaskType
+	Priority      TaskPriority
 	Status        TaskStatus
 	Params        interface{}
 	Result        interface{}
@@ -78,6 +146,13 @@ type Task struct {
 	UpdatedAt     time.Time
 	ClinetID      string
 	Context       context.Context
+	Retry         RetryPolicy   // 失败后的重试策略，零值不重试
+	Attempts      []TaskAttempt // 每次执行的记录，任务结束后读取
+	Timeout       time.Duration // 每次执行的超时时间，为0时使用任务类型的超时时间
+
+	queuedAt time.Time               // 进入工作池队列的时间
+	baseCtx  context.Context         // 提交时的上下文，每次执行在此基础上派生超时
+	cancel   context.CancelCauseFunc // 取消 baseCtx，未经 TaskManager 提交时为nil
 }
 
 func NewTask(ctx context.Context, taskType TaskType, params interface{}) (task *Task, id string) {
@@ -92,26 +167,45 @@ func NewTask(ctx context.Context, taskType TaskType, params interface{}) (task *
 	}, id
 }
 
-// Execute executes the task and calls appropriate callbacks
+// errTaskCanceled is returned when the task context was done before the task started
+var errTaskCanceled = fmt.Errorf("task canceled before start")
+
+// ErrTaskTimeout is wrapped by the error of an attempt that exceeded its timeout
+var ErrTaskTimeout = errors.New("task timed out")
+
+// timeout returns the timeout of one attempt of the task
+func (t *Task) timeout() time.Duration {
+	if t.Timeout > 0 {
+		return t.Timeout
+	}
+	return GetTaskTimeout(t.Type)
+}
+
+// Execute executes the task once and calls appropriate callbacks
 func (t *Task) Execute() {
+	t.finish(t.run(t.Context))
+}
+
+// run executes the task once with its registered executor under ctx, recovering panics
+func (t *Task) run(ctx context.Context) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			t.Status = TaskStatusFailed
-			t.Error = fmt.Errorf("task panicked: %v", r)
+			err = fmt.Errorf("task panicked: %v", r)
 			logrus.WithFields(logrus.Fields{
 				"taskID": t.ID,
 				"panic":  r,
 			}).Error("任务执行时发生panic")
-			if t.Callback != nil {
-				t.Callback.OnError(t.Error)
-			}
 		}
 	}()
 
 	select {
-	case <-t.Context.Done():
+	case <-ctx.Done():
+		if errors.Is(context.Cause(ctx), ErrCanceled) {
+			logrus.WithField("taskID", t.ID).Info("任务在开始前被取消")
+			return ErrCanceled
+		}
 		logrus.WithField("taskID", t.ID).Info("任务因连接断开而取消")
-		return
+		return errTaskCanceled
 	default:
 	}
 
@@ -120,24 +214,32 @@ func (t *Task) Execute() {
 
 	executor, exists := GetTaskExecutor(t.Type)
 	if !exists {
-		t.Error = fmt.Errorf("no executor registered for task type: %v", t.Type)
-		t.Status = TaskStatusFailed
-	} else {
-		// Execute the task using the registered executor
-		t.Error = executor(t)
+		return fmt.Errorf("no executor registered for task type: %v", t.Type)
 	}
+	// Execute the task using the registered executor
+	return executor(t)
+}
 
-	// Call appropriate callback
-	if t.Error != nil {
-		t.Status = TaskStatusFailed
-		if t.Callback != nil {
-			t.Callback.OnError(t.Error)
-		}
-	} else {
+// finish records the final outcome and calls the appropriate callback.
+// 连接断开而取消的任务不回调，通过 CancelTask 取消的任务以 ErrCanceled 回调 OnError
+func (t *Task) finish(err error) {
+	t.Error = err
+	t.UpdatedAt = time.Now()
+	if err == nil {
 		t.Status = TaskStatusComplete
 		if t.Callback != nil {
 			t.Callback.OnComplete(t.Result)
 		}
+		return
+	}
+	t.Status = TaskStatusFailed
+	if isCanceled(err) {
+		t.Status = TaskStatusCanceled
+	} else if errors.Is(err, ErrTaskTimeout) {
+		t.Status = TaskStatusTimeout
+	}
+	if t.Callback != nil && err != errTaskCanceled {
+		t.Callback.OnError(err)
 	}
 }
 
@@ -173,6 +275,7 @@ type ClientContext struct {
 	TaskQueue          chan *Task
 	ActiveTasks        map[string]*Task
 	ResourceQuota      *ResourceQuota
+	limiter            *tokenBucket // 提交频率限制，未配置时为nil
 }
 
 // WorkerStatus represents the current status of a worker
@@ -188,4 +291,11 @@ const (
 type ResourceConfig struct {
 	MaxWorkers        int
 	MaxTasksPerClient int
+	// MaxBackgroundWorkers 后台任务最多同时占用的工作者数，其余工作者留给实时和普通任务，
+	// 为0时使用 MaxWorkers 的四分之三
+	MaxBackgroundWorkers int
+	// RatePerMinute 每个客户端每分钟可以提交的任务数，按令牌桶限流，为0时不限流
+	RatePerMinute float64
+	// RateBurst 令牌桶容量，即允许连续提交的任务数，为0时等于 RatePerMinute
+	RateBurst int
 }
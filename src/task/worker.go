@@ -2,23 +2,36 @@ package task
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// queueTimeout 实时和普通任务在队列中等待工作者的最长时间，后台任务不受限制
+const queueTimeout = 10 * time.Second
+
 // WorkerPool manages a pool of workers for executing tasks
 type WorkerPool struct {
 	config        ResourceConfig
 	workers       []*Worker
-	taskQueue     chan *Task
+	queues        map[TaskPriority]chan *Task // 每个优先级一个队列
 	scheduler     *ScheduledTasks
 	stopChan      chan struct{}
 	idleWorkers   chan *Worker
 	clientManager *ClientManager
 	mu            sync.RWMutex
+
+	backgroundLimit   int32
+	runningTasks      int32         // 执行中的任务数
+	abandoned         int32         // 超时或取消后执行器仍未返回的任务数
+	runningBackground int32         // 执行中的后台任务数
+	backgroundFreed   chan struct{} // 后台任务结束时通知分发协程重新检查后台队列
+
+	tracker *tracker // 任务记录，供管理接口查询和取消
 }
 
 // Worker represents a task execution worker
@@ -33,12 +46,18 @@ type Worker struct {
 // NewWorkerPool creates a new worker pool
 func NewWorkerPool(config ResourceConfig, scheduler *ScheduledTasks, clientManager *ClientManager) *WorkerPool {
 	wp := &WorkerPool{
-		config:        config,
-		taskQueue:     make(chan *Task, config.MaxWorkers*2),
-		scheduler:     scheduler,
-		stopChan:      make(chan struct{}),
-		idleWorkers:   make(chan *Worker, config.MaxWorkers),
-		clientManager: clientManager,
+		config:          config,
+		queues:          make(map[TaskPriority]chan *Task, len(priorityOrder)),
+		scheduler:       scheduler,
+		stopChan:        make(chan struct{}),
+		idleWorkers:     make(chan *Worker, config.MaxWorkers),
+		clientManager:   clientManager,
+		backgroundLimit: int32(backgroundLimit(config)),
+		backgroundFreed: make(chan struct{}, 1),
+		tracker:         newTracker(),
+	}
+	for _, priority := range priorityOrder {
+		wp.queues[priority] = make(chan *Task, config.MaxWorkers*2)
 	}
 
 	// Initialize worker types
@@ -82,32 +101,120 @@ func (wp *WorkerPool) Stop() {
 	}
 }
 
+// backgroundLimit returns how many workers background tasks may occupy at once
+func backgroundLimit(config ResourceConfig) int {
+	limit := config.MaxBackgroundWorkers
+	if limit <= 0 {
+		limit = config.MaxWorkers * 3 / 4
+	}
+	if limit > config.MaxWorkers {
+		limit = config.MaxWorkers
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// track starts the bookkeeping of a task and makes it cancelable through CancelTask
+func (wp *WorkerPool) track(task *Task, clientID string) {
+	ctx := task.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	task.Context, task.baseCtx, task.cancel = ctx, ctx, cancel
+	wp.tracker.submitted(task, clientID)
+}
+
+// queue returns the queue for the task's priority, unknown priorities use the normal queue
+func (wp *WorkerPool) queue(task *Task) chan *Task {
+	if q, ok := wp.queues[task.Priority]; ok {
+		return q
+	}
+	return wp.queues[PriorityNormal]
+}
+
 // Submit submits a task to the worker pool
 func (wp *WorkerPool) Submit(task *Task) error {
+	task.queuedAt = time.Now()
 	select {
-	case wp.taskQueue <- task:
+	case wp.queue(task) <- task:
 		return nil
 	default:
-		return fmt.Errorf("task queue is full")
+		return fmt.Errorf("%s task queue is full", task.Priority)
 	}
 }
 
-// distributeItems distributes tasks to appropriate workers
+// distributeItems waits for an idle worker and hands it the highest-priority task
 func (wp *WorkerPool) distributeItems() {
+	var worker *Worker
+	for {
+		if worker == nil {
+			select {
+			case <-wp.stopChan:
+				return
+			case worker = <-wp.idleWorkers:
+			}
+		}
+		task := wp.nextTask()
+		if task == nil {
+			return
+		}
+		if wp.assignTask(worker, task) {
+			worker = nil
+		}
+	}
+}
+
+// nextTask blocks until a task can run, preferring higher priorities.
+// 后台任务占满配额时只取实时和普通任务，返回nil表示工作池已停止
+func (wp *WorkerPool) nextTask() *Task {
 	for {
+		background := wp.queues[PriorityBackground]
+		if atomic.LoadInt32(&wp.runningBackground) >= wp.backgroundLimit {
+			background = nil
+		}
+		for _, priority := range priorityOrder {
+			q := wp.queues[priority]
+			if priority == PriorityBackground {
+				q = background
+			}
+			select {
+			case task := <-q:
+				return task
+			default:
+			}
+		}
+
 		select {
 		case <-wp.stopChan:
-			return
-		case task := <-wp.taskQueue:
-			wp.assignTask(task)
+			return nil
+		case task := <-wp.queues[PriorityRealtime]:
+			return task
+		case task := <-wp.queues[PriorityNormal]:
+			return task
+		case task := <-background:
+			return task
+		case <-wp.backgroundFreed:
+			// 有后台任务结束，重新检查后台队列
 		}
 	}
 }
 
+// backgroundFinished 后台任务结束，释放占用的后台配额
+func (wp *WorkerPool) backgroundFinished() {
+	atomic.AddInt32(&wp.runningBackground, -1)
+	select {
+	case wp.backgroundFreed <- struct{}{}:
+	default:
+	}
+}
+
 // 新增一个安全地重新排队的方法
 func (wp *WorkerPool) requeueTask(task *Task) {
 	select {
-	case wp.taskQueue <- task:
+	case wp.queue(task) <- task:
 		// 成功加入队列
 	default:
 		// 队列已满，处理这种情况
@@ -119,35 +226,37 @@ func (wp *WorkerPool) requeueTask(task *Task) {
 	}
 }
 
-// assignTask assigns a task to an available worker
-func (wp *WorkerPool) assignTask(task *Task) {
+// assignTask assigns a task to the idle worker, returns false when the task
+// was rejected and the worker is still idle
+func (wp *WorkerPool) assignTask(worker *Worker, task *Task) bool {
 	// 检查是否有注册的执行器
 	if _, exists := GetTaskExecutor(task.Type); !exists {
-		task.Error = fmt.Errorf("no executor registered for task type: %v", task.Type)
-		task.Status = TaskStatusFailed
-		if task.Callback != nil {
-			task.Callback.OnError(task.Error)
-		}
-		return
+		wp.finishTask(task, fmt.Errorf("no executor registered for task type: %v", task.Type))
+		return false
 	}
 
-	select {
-	case worker := <-wp.idleWorkers:
-		worker.assignTask(task)
-	case <-time.After(10 * time.Second): // 10秒超时
-		// 超时处理：直接失败，不重排队
-		task.Status = TaskStatusFailed
-		task.Error = fmt.Errorf("no available workers within timeout")
+	if errors.Is(context.Cause(task.baseContext()), ErrCanceled) {
+		// 排队期间已取消，不占用工作者
+		wp.finishTask(task, ErrCanceled)
+		return false
+	}
+
+	if task.Priority != PriorityBackground && time.Since(task.queuedAt) > queueTimeout {
+		// 超时处理：直接失败，不重排队，退还配额
 		if task.ClinetID != "" && wp.clientManager != nil {
 			if ctx, err := wp.clientManager.GetClientContext(task.ClinetID); err == nil {
 				ctx.ResourceQuota.DecrementQuota(task.Type)
-				ctx.ResourceQuota.CompleteTask(task.Type)
 			}
 		}
-		if task.Callback != nil {
-			task.Callback.OnError(task.Error)
-		}
+		wp.finishTask(task, fmt.Errorf("no available workers within timeout"))
+		return false
 	}
+
+	if task.Priority == PriorityBackground {
+		atomic.AddInt32(&wp.runningBackground, 1)
+	}
+	worker.assignTask(task)
+	return true
 }
 
 // workerFinished 当工作者完成任务时调用
@@ -184,60 +293,90 @@ func (w *Worker) start() {
 	}
 }
 
-// executeTask executes a task
+// executeTask executes one attempt of a task, failed tasks with retries left are
+// resubmitted after a backoff and only the final outcome reaches the callback
 func (w *Worker) executeTask(task *Task) {
 	w.status = WorkerStatusBusy
+	atomic.AddInt32(&w.pool.runningTasks, 1)
 
-	defer func() {
-		w.status = WorkerStatusIdle
-		w.pool.workerFinished(w)
-		// 任务完成，减少并发计数
-		if task.ClinetID != "" && w.pool.clientManager != nil {
-			if ctx, err := w.pool.clientManager.GetClientContext(task.ClinetID); err == nil {
-				ctx.ResourceQuota.CompleteTask(task.Type)
-			}
-		}
-	}()
-	// 创建带取消的context
-	ctx, cancel := context.WithTimeout(task.Context, 5*time.Minute)
-	defer cancel()
+	err := w.runAttempt(task)
+	retrying := task.shouldRetry(err)
 
-	// 在新的context中执行任务
-	task.Context = ctx
+	w.status = WorkerStatusIdle
+	atomic.AddInt32(&w.pool.runningTasks, -1)
+	if task.Priority == PriorityBackground {
+		w.pool.backgroundFinished()
+	}
+	w.pool.workerFinished(w)
 
-	done := make(chan struct{})
-	go func() {
-		defer close(done)
-		defer func() {
-			if r := recover(); r != nil {
-				task.Status = TaskStatusFailed
-				task.Error = fmt.Errorf("task panicked: %v", r)
-			}
-		}()
+	if retrying {
+		w.pool.retryLater(task, err)
+		return
+	}
+	w.pool.finishTask(task, err)
+}
 
-		// 检查context是否已取消
-		select {
-		case <-ctx.Done():
-			task.Status = TaskStatusFailed
-			task.Error = ctx.Err()
-			return
-		default:
-		}
+// runAttempt runs the task once under the attempt timeout and records it in the task's history
+func (w *Worker) runAttempt(task *Task) error {
+	// 每次执行都从提交时的context派生，重试不受上一次超时的影响
+	timeout := task.timeout()
+	ctx, cancel := context.WithTimeoutCause(task.baseContext(), timeout,
+		fmt.Errorf("%w after %s", ErrTaskTimeout, timeout))
+	defer cancel()
 
-		task.Execute()
+	attempt := TaskAttempt{Number: len(task.Attempts) + 1, StartedAt: time.Now()}
+	task.Status = TaskStatusRunning
+	task.UpdatedAt = attempt.StartedAt
+	w.pool.tracker.started(task)
+	// 每次执行使用任务的副本，被放弃的执行器继续运行时不会与任务的后续读写竞争
+	run := *task
+	run.Context = ctx
+	run.Result = nil
+	done := make(chan error, 1)
+	go func() {
+		done <- run.run(ctx)
 	}()
 
+	var err error
 	select {
-	case <-done:
+	case err = <-done:
 		// 任务正常完成
+		task.Result = run.Result
 	case <-ctx.Done():
-		// 超时或取消
-		task.Status = TaskStatusFailed
-		task.Error = ctx.Err()
-		if task.Callback != nil {
-			task.Callback.OnError(task.Error)
+		// 超时或取消，通过 CancelTask 取消时为 ErrCanceled。
+		// 工作者不再等待，执行器收到context取消后自行退出
+		err = context.Cause(ctx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			// 提交时的context到期
+			err = fmt.Errorf("%w: %v", ErrTaskTimeout, err)
+		}
+		if errors.Is(err, ErrTaskTimeout) {
+			logrus.WithFields(logrus.Fields{
+				"taskID":   task.ID,
+				"taskType": task.Type,
+				"timeout":  timeout,
+			}).Warn("任务执行超时")
 		}
+		w.pool.abandon(task, done)
+		attempt.Abandoned = true
+	}
+
+	attempt.FinishedAt = time.Now()
+	if err != nil {
+		attempt.Error = err.Error()
 	}
+	task.Attempts = append(task.Attempts, attempt)
+	return err
+}
+
+// abandon counts an executor that is still running after the worker gave up on it
+func (wp *WorkerPool) abandon(task *Task, done <-chan error) {
+	atomic.AddInt32(&wp.abandoned, 1)
+	go func() {
+		<-done
+		atomic.AddInt32(&wp.abandoned, -1)
+		logrus.WithField("taskID", task.ID).Debug("已放弃的任务执行器已返回")
+	}()
 }
 
 // stop stops the worker
@@ -0,0 +1,87 @@
+package taskadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/router/admin"
+	"xiaozhi-server-go/src/task"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultTaskAdminService 任务管理服务，供管理员查看任务队列、执行情况并取消任务
+type DefaultTaskAdminService struct {
+	config  *configs.Config
+	taskMgr *task.TaskManager
+}
+
+// NewDefaultTaskAdminService 构造函数
+func NewDefaultTaskAdminService(config *configs.Config, taskMgr *task.TaskManager) (*DefaultTaskAdminService, error) {
+	if taskMgr == nil {
+		return nil, fmt.Errorf("任务管理器未初始化")
+	}
+	return &DefaultTaskAdminService{config: config, taskMgr: taskMgr}, nil
+}
+
+// Start 注册任务管理路由，未配置管理员令牌时不开放
+func (s *DefaultTaskAdminService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	if !admin.Enabled(s.config) {
+		logrus.Info("未配置web.admin_token，任务管理接口未开放")
+		return nil
+	}
+	group := admin.Group(apiGroup, s.config)
+	group.GET("/admin/tasks", s.handleList)
+	group.GET("/admin/tasks/stats", s.handleStats)
+	group.DELETE("/admin/tasks/:id", s.handleCancel)
+
+	logrus.Info("任务管理HTTP服务路由注册完成")
+	return nil
+}
+
+// handleList 列出排队、执行中和最近结束的任务，可按 status 和 type 过滤
+func (s *DefaultTaskAdminService) handleList(c *gin.Context) {
+	tasks := s.taskMgr.ListTasks(task.TaskStatus(c.Query("status")))
+	if taskType := c.Query("type"); taskType != "" {
+		filtered := tasks[:0]
+		for _, info := range tasks {
+			if string(info.Type) == taskType {
+				filtered = append(filtered, info)
+			}
+		}
+		tasks = filtered
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"tasks":   tasks,
+		"stats":   s.taskMgr.Stats(),
+	})
+}
+
+// handleStats 返回工作池负载和按任务类型统计的计数，适合定时轮询
+func (s *DefaultTaskAdminService) handleStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "stats": s.taskMgr.Stats()})
+}
+
+// handleCancel 取消未结束的任务
+func (s *DefaultTaskAdminService) handleCancel(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.taskMgr.CancelTask(id); err != nil {
+		if errors.Is(err, task.ErrTaskNotFound) {
+			s.respondError(c, http.StatusNotFound, "任务不存在或已结束")
+			return
+		}
+		s.respondError(c, http.StatusInternalServerError, "取消任务失败: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id})
+}
+
+// respondError 返回错误响应
+func (s *DefaultTaskAdminService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{"success": false, "message": message})
+}
@@ -0,0 +1,99 @@
+package transcribe
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// callbackGuard 限制结果回调的目标，防止通过 callback_url 访问内网服务和云主机元数据
+type callbackGuard struct {
+	hosts []string // 允许的主机名，*.example.com 匹配其子域名
+}
+
+func newCallbackGuard(hosts []string) *callbackGuard {
+	g := &callbackGuard{}
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			g.hosts = append(g.hosts, host)
+		}
+	}
+	return g
+}
+
+// allowedHost 主机名是否在允许列表中
+func (g *callbackGuard) allowedHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range g.hosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// checkURL 校验回调地址的协议和主机名，解析后的IP在建立连接时检查
+func (g *callbackGuard) checkURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("callback_url 必须是http或https地址")
+	}
+	if u.User != nil {
+		return fmt.Errorf("callback_url 不能包含用户信息")
+	}
+	if !g.allowedHost(u.Hostname()) {
+		return fmt.Errorf("callback_url 的主机 %s 不在允许列表中", u.Hostname())
+	}
+	return nil
+}
+
+// client 返回回调使用的HTTP客户端：不使用代理，连接前检查DNS解析后的地址，重定向同样校验
+func (g *callbackGuard) client() *http.Client {
+	dialer := &net.Dialer{Timeout: callbackTimeout, Control: dialControl}
+	return &http.Client{
+		Timeout: callbackTimeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   callbackTimeout,
+			ResponseHeaderTimeout: callbackTimeout,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return fmt.Errorf("回调重定向次数过多")
+			}
+			return g.checkURL(req.URL.String())
+		},
+	}
+}
+
+// dialControl 拒绝连接内网、回环、链路本地等地址，address 为DNS解析后的 ip:port
+func dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("回调地址 %s 不是公网地址", host)
+	}
+	return nil
+}
+
+// sharedAddressSpace 运营商级NAT地址段 100.64.0.0/10
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP 是否为可以回调的公网地址
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
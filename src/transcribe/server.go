@@ -0,0 +1,414 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/auth"
+	"xiaozhi-server-go/src/core/pool"
+	"xiaozhi-server-go/src/task"
+)
+
+const (
+	// 单个音频文件最大20MB（16kHz单声道约10分钟）
+	MAX_FILE_SIZE = 20 * 1024 * 1024
+	// 单次请求最多上传的文件数
+	MAX_FILES = 10
+
+	jobTimeout      = 30 * time.Minute // 单个任务的最长执行时间
+	jobRetention    = time.Hour        // 完成的任务结果保留时长，期间可轮询
+	callbackTimeout = 10 * time.Second
+
+	defaultMaxJobs      = 100
+	defaultMaxPendingMB = 200
+)
+
+// TaskTypeBatchASR 批量离线识别任务
+const TaskTypeBatchASR task.TaskType = "asr_batch"
+
+func init() {
+	task.RegisterTaskExecutor(TaskTypeBatchASR, func(t *task.Task) error {
+		run, ok := t.Params.(*jobRun)
+		if !ok {
+			return fmt.Errorf("invalid params for task type %v", TaskTypeBatchASR)
+		}
+		return run.service.runJob(t.Context, run.jobID)
+	})
+	task.RegisterTaskTimeout(TaskTypeBatchASR, jobTimeout)
+}
+
+// jobRun 批量识别任务参数
+type jobRun struct {
+	service *DefaultTranscribeService
+	jobID   string
+}
+
+// DefaultTranscribeService 批量离线识别服务，通过ASR资源池和任务管理器异步处理上传的录音
+type DefaultTranscribeService struct {
+	config      *configs.Config
+	poolManager *pool.PoolManager
+	taskMgr     *task.TaskManager
+	authToken   *auth.AuthToken
+	ctx         context.Context
+	callbacks   *callbackGuard
+	maxJobs     int
+	maxPending  int // 等待识别的PCM数据总量上限（字节）
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	pending int // 尚未识别完成的任务的PCM数据总量
+}
+
+// NewDefaultTranscribeService 构造函数
+func NewDefaultTranscribeService(config *configs.Config, poolManager *pool.PoolManager, taskMgr *task.TaskManager) (*DefaultTranscribeService, error) {
+	if poolManager == nil || taskMgr == nil {
+		return nil, fmt.Errorf("资源池管理器或任务管理器未初始化")
+	}
+	maxJobs := config.Transcribe.MaxJobs
+	if maxJobs <= 0 {
+		maxJobs = defaultMaxJobs
+	}
+	maxPendingMB := config.Transcribe.MaxPendingMB
+	if maxPendingMB <= 0 {
+		maxPendingMB = defaultMaxPendingMB
+	}
+	return &DefaultTranscribeService{
+		config:      config,
+		poolManager: poolManager,
+		taskMgr:     taskMgr,
+		authToken:   auth.NewAuthToken(config.Server.Token),
+		ctx:         context.Background(),
+		callbacks:   newCallbackGuard(config.Transcribe.CallbackHosts),
+		maxJobs:     maxJobs,
+		maxPending:  maxPendingMB << 20,
+		jobs:        make(map[string]*Job),
+	}, nil
+}
+
+// Start 注册批量识别相关路由
+func (s *DefaultTranscribeService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	s.ctx = ctx
+	apiGroup.POST("/asr/batch", s.handleSubmit)
+	apiGroup.GET("/asr/batch/:id", s.handleGet)
+
+	logrus.Info("批量识别HTTP服务路由注册完成")
+	return nil
+}
+
+// handleSubmit 上传音频文件并创建批量识别任务
+// 表单字段 file 可重复，callback_url 可选，任务完成后将结果以JSON POST到该地址
+func (s *DefaultTranscribeService) handleSubmit(c *gin.Context) {
+	deviceID, err := s.verifyAuth(c)
+	if err != nil {
+		s.respondError(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if err := c.Request.ParseMultipartForm(MAX_FILE_SIZE); err != nil {
+		s.respondError(c, http.StatusBadRequest, fmt.Sprintf("解析multipart表单失败: %v", err))
+		return
+	}
+	headers := c.Request.MultipartForm.File["file"]
+	if len(headers) == 0 {
+		s.respondError(c, http.StatusBadRequest, "缺少音频文件")
+		return
+	}
+	if len(headers) > MAX_FILES {
+		s.respondError(c, http.StatusBadRequest, fmt.Sprintf("单次最多上传%d个文件", MAX_FILES))
+		return
+	}
+
+	callbackURL := c.Request.FormValue("callback_url")
+	if callbackURL != "" {
+		if err := s.callbacks.checkURL(callbackURL); err != nil {
+			s.respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	files := make([]audioFile, 0, len(headers))
+	for _, header := range headers {
+		if header.Size > MAX_FILE_SIZE {
+			s.respondError(c, http.StatusBadRequest, fmt.Sprintf("%s 大小超过限制，最大允许%dMB", header.Filename, MAX_FILE_SIZE/1024/1024))
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			s.respondError(c, http.StatusBadRequest, fmt.Sprintf("读取%s失败: %v", header.Filename, err))
+			return
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			s.respondError(c, http.StatusBadRequest, fmt.Sprintf("读取%s失败: %v", header.Filename, err))
+			return
+		}
+		pcm, err := decodeAudio(header.Filename, data)
+		if err != nil {
+			s.respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		files = append(files, audioFile{name: header.Filename, pcm: pcm})
+	}
+
+	job, err := s.addJob(deviceID, callbackURL, files)
+	if err != nil {
+		s.respondError(c, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, jobTimeout)
+	t, _ := task.NewTask(ctx, TaskTypeBatchASR, &jobRun{service: s, jobID: job.ID})
+	t.Priority = task.PriorityBackground
+	t.Callback = task.NewCallBack(func(result interface{}) {
+		cancel()
+		// 排队超时等情况下任务没有执行，释放音频数据
+		s.abortJob(job.ID, "识别任务未能执行")
+	})
+	if err := s.taskMgr.SubmitTask(deviceID, t); err != nil {
+		cancel()
+		s.removeJob(job.ID)
+		var quotaErr *task.QuotaError
+		if errors.As(err, &quotaErr) && quotaErr.Reason == task.QuotaRateLimited {
+			c.Header("Retry-After", strconv.Itoa(quotaErr.RetryAfterSeconds()))
+		}
+		s.respondError(c, http.StatusTooManyRequests, fmt.Sprintf("提交识别任务失败: %v", err))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"job_id":    job.ID,
+		"device_id": deviceID,
+		"files":     len(files),
+	}).Info("批量识别任务已提交")
+	c.JSON(http.StatusAccepted, JobResponse{Success: true, Job: s.getJob(job.ID)})
+}
+
+// handleGet 轮询批量识别任务状态与结果
+func (s *DefaultTranscribeService) handleGet(c *gin.Context) {
+	deviceID, err := s.verifyAuth(c)
+	if err != nil {
+		s.respondError(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	job := s.getJob(c.Param("id"))
+	if job == nil || job.DeviceID != deviceID {
+		s.respondError(c, http.StatusNotFound, "任务不存在或已过期")
+		return
+	}
+	c.JSON(http.StatusOK, JobResponse{Success: true, Job: job})
+}
+
+// runJob 依次识别任务中的所有文件，ASR提供者在整个任务期间独占
+func (s *DefaultTranscribeService) runJob(ctx context.Context, jobID string) error {
+	s.mu.Lock()
+	job, ok := s.jobs[jobID]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("任务不存在: %s", jobID)
+	}
+	job.Status = JobStatusRunning
+	files := job.files
+	s.mu.Unlock()
+
+	err := s.transcribeFiles(ctx, jobID, files)
+
+	s.mu.Lock()
+	now := time.Now()
+	job.FinishedAt = &now
+	s.releaseFiles(job)
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = JobStatusComplete
+	}
+	status, callbackURL := job.Status, job.CallbackURL
+	s.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"job_id": jobID,
+		"status": status,
+	}).Info("批量识别任务结束")
+
+	if callbackURL != "" {
+		s.notifyCallback(callbackURL, s.getJob(jobID))
+	}
+	return err
+}
+
+// transcribeFiles 申请ASR提供者并逐个识别，单个文件失败只记录在该文件结果中
+func (s *DefaultTranscribeService) transcribeFiles(ctx context.Context, jobID string, files []audioFile) error {
+	asr, err := s.poolManager.GetASRProvider()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := s.poolManager.ReturnASRProvider(asr); err != nil {
+			logrus.WithError(err).Warn("归还ASR提供者失败")
+		}
+	}()
+
+	for _, file := range files {
+		result := FileResult{Filename: file.name}
+		text, err := transcribePCM(ctx, asr, file.pcm)
+		result.Text = text
+		if err != nil {
+			result.Error = err.Error()
+		}
+
+		s.mu.Lock()
+		if job, ok := s.jobs[jobID]; ok {
+			job.Results = append(job.Results, result)
+		}
+		s.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("识别任务超时或服务关闭: %v", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// notifyCallback 将任务结果POST到回调地址
+func (s *DefaultTranscribeService) notifyCallback(callbackURL string, job *Job) {
+	body, err := json.Marshal(JobResponse{Success: job.Status == JobStatusComplete, Job: job})
+	if err != nil {
+		logrus.WithError(err).Error("序列化回调数据失败")
+		return
+	}
+	resp, err := s.callbacks.client().Post(callbackURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logrus.WithError(err).WithField("job_id", job.ID).Warn("批量识别结果回调失败")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logrus.WithField("job_id", job.ID).Warn(fmt.Sprintf("批量识别结果回调返回状态码: %d", resp.StatusCode))
+	}
+}
+
+// addJob 创建任务记录，同时清理过期的任务；任务数或待识别的音频总量超出上限时返回错误
+func (s *DefaultTranscribeService) addJob(deviceID, callbackURL string, files []audioFile) (*Job, error) {
+	size := 0
+	for _, file := range files {
+		size += len(file.pcm)
+	}
+	id := uuid.New().String()
+	job := &Job{
+		ID:          id,
+		Status:      JobStatusPending,
+		DeviceID:    deviceID,
+		CallbackURL: callbackURL,
+		Results:     []FileResult{},
+		CreatedAt:   time.Now(),
+		files:       files,
+		size:        size,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneJobs()
+	if len(s.jobs) >= s.maxJobs {
+		return nil, fmt.Errorf("识别任务过多，请稍后再试")
+	}
+	if s.pending+size > s.maxPending {
+		return nil, fmt.Errorf("待识别的音频过多，请稍后再试")
+	}
+	s.jobs[id] = job
+	s.pending += size
+	return job, nil
+}
+
+// pruneJobs 删除结果保留期已过的任务；未结束的任务超过执行时限与保留期之和也一并删除，调用方需持有锁
+func (s *DefaultTranscribeService) pruneJobs() {
+	for jobID, job := range s.jobs {
+		expired := job.FinishedAt != nil && time.Since(*job.FinishedAt) > jobRetention
+		if job.FinishedAt == nil && time.Since(job.CreatedAt) > jobTimeout+jobRetention {
+			expired = true
+		}
+		if expired {
+			s.releaseFiles(job)
+			delete(s.jobs, jobID)
+		}
+	}
+}
+
+// releaseFiles 释放任务的PCM数据，调用方需持有锁
+func (s *DefaultTranscribeService) releaseFiles(job *Job) {
+	s.pending -= job.size
+	job.files = nil
+	job.size = 0
+}
+
+// abortJob 任务没有执行就结束时标记为失败并释放音频数据，已结束的任务不受影响
+func (s *DefaultTranscribeService) abortJob(jobID, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[jobID]
+	if !ok || job.FinishedAt != nil || job.Status == JobStatusRunning {
+		return
+	}
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = JobStatusFailed
+	job.Error = reason
+	s.releaseFiles(job)
+}
+
+func (s *DefaultTranscribeService) removeJob(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[jobID]; ok {
+		s.releaseFiles(job)
+		delete(s.jobs, jobID)
+	}
+}
+
+// getJob 返回任务的副本，避免与识别协程并发读写
+func (s *DefaultTranscribeService) getJob(jobID string) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil
+	}
+	copied := *job
+	copied.Results = append([]FileResult{}, job.Results...)
+	copied.files = nil
+	return &copied
+}
+
+// verifyAuth 验证设备认证token，返回token对应的设备ID
+func (s *DefaultTranscribeService) verifyAuth(c *gin.Context) (string, error) {
+	authHeader := c.GetHeader("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return "", fmt.Errorf("无效的认证token或token已过期")
+	}
+	isValid, deviceID, err := s.authToken.VerifyToken(authHeader[7:])
+	if err != nil || !isValid {
+		logrus.Warn(fmt.Sprintf("批量识别认证失败: %v", err))
+		return "", fmt.Errorf("无效的认证token或token已过期")
+	}
+	return deviceID, nil
+}
+
+// respondError 返回错误响应
+func (s *DefaultTranscribeService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, JobResponse{Success: false, Message: message})
+}
@@ -0,0 +1,169 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+)
+
+const (
+	pcmSampleRate     = 16000
+	pcmChunkSize      = 3200                  // 100ms的16kHz单声道16bit PCM
+	pcmChunkInterval  = 20 * time.Millisecond // 以约5倍实时速度送入ASR
+	trailingSilence   = 32000                 // 结尾补1秒静音，促使ASR输出最终结果
+	resultIdleTimeout = 2 * time.Second       // 音频送完后无新结果即认为识别结束
+	resultMaxWait     = 10 * time.Second      // 音频送完后最长等待时间
+)
+
+// resultCollector 收集流式识别结果，ASR返回的是整段累计文本，保留最新的非空结果
+type resultCollector struct {
+	mu      sync.Mutex
+	text    string
+	updated chan struct{}
+}
+
+func newResultCollector() *resultCollector {
+	return &resultCollector{updated: make(chan struct{}, 1)}
+}
+
+// OnAsrResult 实现 providers.AsrEventListener，始终返回false以持续接收结果
+func (c *resultCollector) OnAsrResult(result string) bool {
+	if result == "" {
+		return false
+	}
+	c.mu.Lock()
+	c.text = result
+	c.mu.Unlock()
+	select {
+	case c.updated <- struct{}{}:
+	default:
+	}
+	return false
+}
+
+func (c *resultCollector) Text() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.text
+}
+
+// transcribePCM 通过流式ASR识别一段完整的PCM音频
+func transcribePCM(ctx context.Context, asr providers.ASRProvider, pcm []byte) (string, error) {
+	collector := newResultCollector()
+	if err := asr.Reset(); err != nil {
+		return "", fmt.Errorf("重置ASR失败: %v", err)
+	}
+	asr.SetListener(collector)
+	defer func() {
+		asr.SetListener(nil)
+		asr.Reset()
+	}()
+
+	audio := append(pcm, make([]byte, trailingSilence)...)
+	for offset := 0; offset < len(audio); offset += pcmChunkSize {
+		end := offset + pcmChunkSize
+		if end > len(audio) {
+			end = len(audio)
+		}
+		if err := asr.AddAudio(audio[offset:end]); err != nil {
+			return "", fmt.Errorf("发送音频数据失败: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(pcmChunkInterval):
+		}
+	}
+
+	// 等待最终结果，连续 resultIdleTimeout 无新结果即结束
+	maxWait := time.NewTimer(resultMaxWait)
+	defer maxWait.Stop()
+	idle := time.NewTimer(resultIdleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return collector.Text(), ctx.Err()
+		case <-maxWait.C:
+			return collector.Text(), nil
+		case <-idle.C:
+			return collector.Text(), nil
+		case <-collector.updated:
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(resultIdleTimeout)
+		}
+	}
+}
+
+// decodeAudio 将上传的音频转换为16kHz单声道16bit PCM，支持WAV和裸PCM
+func decodeAudio(filename string, data []byte) ([]byte, error) {
+	if len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE" {
+		return decodeWAV(data)
+	}
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("%s 不是有效的16bit PCM数据", filename)
+	}
+	return data, nil
+}
+
+// decodeWAV 解析WAV文件，要求为16kHz单声道16bit PCM
+func decodeWAV(data []byte) ([]byte, error) {
+	reader := bytes.NewReader(data[12:])
+	var format struct {
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+	}
+	hasFormat := false
+
+	for {
+		var chunkID [4]byte
+		var chunkSize uint32
+		if err := binary.Read(reader, binary.LittleEndian, &chunkID); err != nil {
+			return nil, fmt.Errorf("WAV文件缺少data块")
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &chunkSize); err != nil {
+			return nil, fmt.Errorf("WAV文件块头不完整")
+		}
+
+		switch string(chunkID[:]) {
+		case "fmt ":
+			if chunkSize < 16 {
+				return nil, fmt.Errorf("WAV文件fmt块不完整")
+			}
+			if err := binary.Read(reader, binary.LittleEndian, &format); err != nil {
+				return nil, fmt.Errorf("读取WAV格式失败: %v", err)
+			}
+			reader.Seek(int64(chunkSize-16), 1)
+			hasFormat = true
+		case "data":
+			if !hasFormat {
+				return nil, fmt.Errorf("WAV文件缺少fmt块")
+			}
+			if format.AudioFormat != 1 || format.Channels != 1 || format.SampleRate != pcmSampleRate || format.BitsPerSample != 16 {
+				return nil, fmt.Errorf("仅支持16kHz单声道16bit PCM格式的WAV，当前为 %dHz %d声道 %dbit",
+					format.SampleRate, format.Channels, format.BitsPerSample)
+			}
+			size := int(chunkSize)
+			if size > reader.Len() {
+				size = reader.Len()
+			}
+			pcm := make([]byte, size)
+			reader.Read(pcm)
+			return pcm, nil
+		default:
+			// 跳过LIST等其他块，块大小为奇数时有1字节填充
+			reader.Seek(int64(chunkSize+chunkSize%2), 1)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package transcribe
+
+import "time"
+
+// JobStatus 批量识别任务状态
+type JobStatus string
+
+const (
+	JobStatusPending  JobStatus = "pending"
+	JobStatusRunning  JobStatus = "running"
+	JobStatusComplete JobStatus = "complete"
+	JobStatusFailed   JobStatus = "failed"
+)
+
+// FileResult 单个音频文件的识别结果
+type FileResult struct {
+	Filename string `json:"filename"`
+	Text     string `json:"text"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Job 批量识别任务
+type Job struct {
+	ID          string       `json:"id"`
+	Status      JobStatus    `json:"status"`
+	DeviceID    string       `json:"device_id"`
+	CallbackURL string       `json:"-"`
+	Results     []FileResult `json:"results"`
+	Error       string       `json:"error,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	FinishedAt  *time.Time   `json:"finished_at,omitempty"`
+
+	files []audioFile // 待识别的PCM数据，识别完成后释放
+	size  int         // files 的PCM数据总量
+}
+
+// audioFile 上传的音频文件，已转换为16kHz单声道16bit PCM
+type audioFile struct {
+	name string
+	pcm  []byte
+}
+
+// JobResponse 批量识别接口标准响应结构
+type JobResponse struct {
+	Success bool   `json:"success"`
+	Job     *Job   `json:"job,omitempty"`
+	Message string `json:"message,omitempty"`
+}
@@ -0,0 +1,177 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 预算对象类型
+const (
+	BudgetScopeDevice  = "device"
+	BudgetScopeGroup   = "group"
+	BudgetScopeDefault = "default" // 未单独设置预算的设备使用配置中的默认值
+)
+
+// 超出预算后的处理方式
+const (
+	BudgetActionDowngrade = "downgrade"
+	BudgetActionDecline   = "decline"
+)
+
+// BudgetUsage 一项预算本月的使用情况
+type BudgetUsage struct {
+	Scope               string  `json:"scope"`
+	Subject             string  `json:"subject"`
+	Tokens              int64   `json:"tokens"`
+	MonthlyTokens       int64   `json:"monthly_tokens"`
+	AudioMinutes        float64 `json:"audio_minutes"`
+	MonthlyAudioMinutes int64   `json:"monthly_audio_minutes"`
+	Exceeded            bool    `json:"exceeded"`
+}
+
+// BudgetStatus 设备本月适用的各项预算
+type BudgetStatus struct {
+	DeviceID string        `json:"device_id"`
+	Month    string        `json:"month"`
+	Exceeded bool          `json:"exceeded"`
+	Budgets  []BudgetUsage `json:"budgets"`
+}
+
+// CheckBudget 计算设备本月用量是否超出预算
+// 设备自身的预算优先于默认预算；设备所在分组的预算按组内所有设备用量之和计算，任一预算超出即视为超出
+func CheckBudget(db *gorm.DB, config configs.BudgetConfig, deviceID string) (*BudgetStatus, error) {
+	month := time.Now().Format("2006-01")
+	status := &BudgetStatus{DeviceID: deviceID, Month: month}
+	groups := groupsOf(config, deviceID)
+
+	var budgets []models.UsageBudget
+	tx := db.Where("scope = ? AND subject = ?", BudgetScopeDevice, deviceID)
+	if len(groups) > 0 {
+		tx = tx.Or("scope = ? AND subject IN ?", BudgetScopeGroup, groups)
+	}
+	if err := tx.Order("scope, subject").Find(&budgets).Error; err != nil {
+		return nil, fmt.Errorf("查询预算失败: %v", err)
+	}
+
+	hasDevice := false
+	for _, b := range budgets {
+		if b.Scope == BudgetScopeDevice {
+			hasDevice = true
+		}
+	}
+	if !hasDevice && (config.MonthlyTokens > 0 || config.MonthlyAudioMinutes > 0) {
+		budgets = append([]models.UsageBudget{{
+			Scope:               BudgetScopeDefault,
+			Subject:             deviceID,
+			MonthlyTokens:       config.MonthlyTokens,
+			MonthlyAudioMinutes: config.MonthlyAudioMinutes,
+		}}, budgets...)
+	}
+
+	for _, b := range budgets {
+		devices := []string{deviceID}
+		if b.Scope == BudgetScopeGroup {
+			devices = config.Groups[b.Subject]
+		}
+		tokens, audioMs, err := monthUsage(db, devices, month)
+		if err != nil {
+			return nil, err
+		}
+		u := BudgetUsage{
+			Scope:               b.Scope,
+			Subject:             b.Subject,
+			Tokens:              tokens,
+			MonthlyTokens:       b.MonthlyTokens,
+			AudioMinutes:        float64(audioMs) / float64(time.Minute/time.Millisecond),
+			MonthlyAudioMinutes: b.MonthlyAudioMinutes,
+		}
+		u.Exceeded = (u.MonthlyTokens > 0 && u.Tokens >= u.MonthlyTokens) ||
+			(u.MonthlyAudioMinutes > 0 && u.AudioMinutes >= float64(u.MonthlyAudioMinutes))
+		status.Exceeded = status.Exceeded || u.Exceeded
+		status.Budgets = append(status.Budgets, u)
+	}
+	return status, nil
+}
+
+// monthUsage 设备本月的token总数（输入加输出）和音频时长
+func monthUsage(db *gorm.DB, devices []string, month string) (int64, int64, error) {
+	if len(devices) == 0 {
+		return 0, 0, nil
+	}
+	var sum struct {
+		Tokens  int64
+		AudioMs int64
+	}
+	err := db.Model(&models.UsageRecord{}).
+		Select("COALESCE(SUM(prompt_tokens + completion_tokens), 0) AS tokens, COALESCE(SUM(audio_ms), 0) AS audio_ms").
+		Where("device_id IN ? AND day >= ?", devices, month+"-01").
+		Scan(&sum).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("统计本月用量失败: %v", err)
+	}
+	return sum.Tokens, sum.AudioMs, nil
+}
+
+// groupsOf 设备所在的分组
+func groupsOf(config configs.BudgetConfig, deviceID string) []string {
+	var groups []string
+	for name, devices := range config.Groups {
+		for _, device := range devices {
+			if device == deviceID {
+				groups = append(groups, name)
+				break
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// ListBudgets 列出所有单独设置的预算
+func ListBudgets(db *gorm.DB) ([]models.UsageBudget, error) {
+	var budgets []models.UsageBudget
+	if err := db.Order("scope, subject").Find(&budgets).Error; err != nil {
+		return nil, fmt.Errorf("查询预算失败: %v", err)
+	}
+	return budgets, nil
+}
+
+// SetBudget 设置设备或分组的预算，已存在时覆盖
+func SetBudget(db *gorm.DB, config configs.BudgetConfig, budget models.UsageBudget) error {
+	switch budget.Scope {
+	case BudgetScopeDevice:
+	case BudgetScopeGroup:
+		if _, ok := config.Groups[budget.Subject]; !ok {
+			return fmt.Errorf("分组不存在: %s，请先在 usage.budget.groups 中配置", budget.Subject)
+		}
+	default:
+		return fmt.Errorf("不支持的预算对象类型: %s", budget.Scope)
+	}
+	if budget.Subject == "" {
+		return fmt.Errorf("缺少设备ID或分组名称")
+	}
+	if budget.MonthlyTokens < 0 || budget.MonthlyAudioMinutes < 0 {
+		return fmt.Errorf("预算不能为负数")
+	}
+	budget.ID = 0
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope"}, {Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{"monthly_tokens", "monthly_audio_minutes", "updated_at"}),
+	}).Create(&budget).Error
+}
+
+// DeleteBudget 删除单独设置的预算，设备恢复使用默认预算
+func DeleteBudget(db *gorm.DB, scope, subject string) (bool, error) {
+	result := db.Where("scope = ? AND subject = ?", scope, subject).Delete(&models.UsageBudget{})
+	if result.Error != nil {
+		return false, fmt.Errorf("删除预算失败: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
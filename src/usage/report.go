@@ -0,0 +1,69 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+
+	"gorm.io/gorm"
+)
+
+const defaultReportDir = "data/reports"
+
+// DailyReport 单日用量报告
+type DailyReport struct {
+	Day         string    `json:"day"`
+	Currency    string    `json:"currency"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Total       Row       `json:"total"`
+	ByDevice    []Row     `json:"by_device"`
+	ByModel     []Row     `json:"by_model"`
+}
+
+// WriteDailyReport 汇总指定日期（YYYY-MM-DD）的用量，写入 usage.report_dir 下的 usage-<日期>.json，返回文件路径
+func WriteDailyReport(db *gorm.DB, config *configs.Config, day string) (string, error) {
+	pricing := func(model string) (float64, float64) {
+		p := config.Usage.Pricing[model]
+		return p.Prompt, p.Completion
+	}
+	q := Query{From: day, To: day, GroupBy: "device"}
+	byDevice, total, err := Summarize(db, q, pricing)
+	if err != nil {
+		return "", err
+	}
+	q.GroupBy = "model"
+	byModel, _, err := Summarize(db, q, pricing)
+	if err != nil {
+		return "", err
+	}
+
+	report := DailyReport{
+		Day:         day,
+		Currency:    config.Usage.Currency,
+		GeneratedAt: time.Now(),
+		Total:       total,
+		ByDevice:    byDevice,
+		ByModel:     byModel,
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("序列化用量报告失败: %v", err)
+	}
+
+	dir := config.Usage.ReportDir
+	if dir == "" {
+		dir = defaultReportDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("创建报告目录失败: %v", err)
+	}
+	path := filepath.Join(dir, "usage-"+day+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("写入用量报告失败: %v", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,188 @@
+package usage
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/router/admin"
+
+	"xiaozhi-server-go/src/core/hedge"
+	"xiaozhi-server-go/src/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultUsageService token用量查询服务，供管理员做成本核算
+type DefaultUsageService struct {
+	config *configs.Config
+}
+
+// NewDefaultUsageService 构造函数
+func NewDefaultUsageService(config *configs.Config) (*DefaultUsageService, error) {
+	return &DefaultUsageService{config: config}, nil
+}
+
+// Start 注册用量查询路由，未配置管理员令牌时不开放
+func (s *DefaultUsageService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	if !admin.Enabled(s.config) {
+		logrus.Info("未配置web.admin_token，用量查询接口未开放")
+		return nil
+	}
+	group := admin.Group(apiGroup, s.config)
+	group.GET("/usage", s.handleSummary)
+	group.GET("/usage/hedge", s.handleHedge)
+	group.GET("/usage/budgets", s.handleListBudgets)
+	group.PUT("/usage/budgets", s.handleSetBudget)
+	group.DELETE("/usage/budgets/:scope/:subject", s.handleDeleteBudget)
+	group.GET("/usage/budgets/status", s.handleBudgetStatus)
+
+	logrus.Info("用量查询HTTP服务路由注册完成")
+	return nil
+}
+
+// handleSummary 按设备、日期或模型汇总用量
+// 查询参数：device_id（为空表示所有设备）、from/to（YYYY-MM-DD，默认最近30天）、group_by（device、day、model）
+func (s *DefaultUsageService) handleSummary(c *gin.Context) {
+	if database.DB == nil {
+		s.respondError(c, http.StatusServiceUnavailable, "数据库未连接")
+		return
+	}
+
+	now := time.Now()
+	q := Query{
+		DeviceID: c.Query("device_id"),
+		From:     c.DefaultQuery("from", now.AddDate(0, 0, -30).Format(dayLayout)),
+		To:       c.DefaultQuery("to", now.Format(dayLayout)),
+		GroupBy:  c.DefaultQuery("group_by", "device"),
+	}
+	for _, day := range []string{q.From, q.To} {
+		if _, err := time.Parse(dayLayout, day); err != nil {
+			s.respondError(c, http.StatusBadRequest, "日期格式应为YYYY-MM-DD: "+day)
+			return
+		}
+	}
+
+	rows, total, err := Summarize(database.DB, q, s.price)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"from":     q.From,
+		"to":       q.To,
+		"group_by": q.GroupBy,
+		"currency": s.config.Usage.Currency,
+		"rows":     rows,
+		"total":    total,
+	})
+}
+
+// handleHedge 请求对冲统计：对冲次数、胜出率和额外提交的字符数，统计自服务启动起
+func (s *DefaultUsageService) handleHedge(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"targets": hedge.Snapshot(),
+	})
+}
+
+// handleListBudgets 列出单独设置的预算和默认预算
+func (s *DefaultUsageService) handleListBudgets(c *gin.Context) {
+	if database.DB == nil {
+		s.respondError(c, http.StatusServiceUnavailable, "数据库未连接")
+		return
+	}
+	budgets, err := ListBudgets(database.DB)
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	cfg := s.config.Usage.Budget
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"enabled": cfg.Enabled,
+		"default": gin.H{
+			"monthly_tokens":        cfg.MonthlyTokens,
+			"monthly_audio_minutes": cfg.MonthlyAudioMinutes,
+		},
+		"groups":  cfg.Groups,
+		"budgets": budgets,
+	})
+}
+
+// handleSetBudget 设置设备或分组的每月预算，0表示不限
+// 请求体：{"scope":"device","subject":"<设备ID>","monthly_tokens":200000,"monthly_audio_minutes":300}
+func (s *DefaultUsageService) handleSetBudget(c *gin.Context) {
+	if database.DB == nil {
+		s.respondError(c, http.StatusServiceUnavailable, "数据库未连接")
+		return
+	}
+	var budget models.UsageBudget
+	if err := c.ShouldBindJSON(&budget); err != nil {
+		s.respondError(c, http.StatusBadRequest, "请求格式错误: "+err.Error())
+		return
+	}
+	if err := SetBudget(database.DB, s.config.Usage.Budget, budget); err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"scope":   budget.Scope,
+		"subject": budget.Subject,
+		"tokens":  budget.MonthlyTokens,
+		"minutes": budget.MonthlyAudioMinutes,
+	}).Info("已设置用量预算")
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// handleDeleteBudget 删除单独设置的预算
+func (s *DefaultUsageService) handleDeleteBudget(c *gin.Context) {
+	if database.DB == nil {
+		s.respondError(c, http.StatusServiceUnavailable, "数据库未连接")
+		return
+	}
+	deleted, err := DeleteBudget(database.DB, c.Param("scope"), c.Param("subject"))
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !deleted {
+		s.respondError(c, http.StatusNotFound, "预算不存在")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// handleBudgetStatus 查询设备本月的预算使用情况，查询参数 device_id
+func (s *DefaultUsageService) handleBudgetStatus(c *gin.Context) {
+	if database.DB == nil {
+		s.respondError(c, http.StatusServiceUnavailable, "数据库未连接")
+		return
+	}
+	deviceID := c.Query("device_id")
+	if deviceID == "" {
+		s.respondError(c, http.StatusBadRequest, "缺少 device_id")
+		return
+	}
+	status, err := CheckBudget(database.DB, s.config.Usage.Budget, deviceID)
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "status": status})
+}
+
+// price 模型每千token价格，未配置的模型成本记为0
+func (s *DefaultUsageService) price(model string) (float64, float64) {
+	p := s.config.Usage.Pricing[model]
+	return p.Prompt, p.Completion
+}
+
+// respondError 返回错误响应
+func (s *DefaultUsageService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{"success": false, "message": message})
+}
@@ -0,0 +1,155 @@
+package usage
+
+import (
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// dayLayout usage_records.day 的日期格式
+const dayLayout = "2006-01-02"
+
+// Record 累加一次请求的token用量到设备当天的汇总记录，数据库未连接时忽略
+func Record(deviceID, kind string, u types.Usage) {
+	db := database.DB
+	if db == nil {
+		return
+	}
+	record := models.UsageRecord{
+		DeviceID:         deviceID,
+		Day:              time.Now().Format(dayLayout),
+		Kind:             kind,
+		Model:            u.Model,
+		Requests:         1,
+		PromptTokens:     int64(u.PromptTokens),
+		CompletionTokens: int64(u.CompletionTokens),
+	}
+	go func() {
+		if err := upsert(db, record); err != nil {
+			logrus.WithError(err).Warn("记录token用量失败")
+		}
+	}()
+}
+
+// RecordAudio 累加设备当天的音频时长，kind 为 ASR 或 TTS，数据库未连接时忽略
+func RecordAudio(deviceID, kind, model string, audioMs int64) {
+	db := database.DB
+	if db == nil || audioMs <= 0 {
+		return
+	}
+	record := models.UsageRecord{
+		DeviceID: deviceID,
+		Day:      time.Now().Format(dayLayout),
+		Kind:     kind,
+		Model:    model,
+		Requests: 1,
+		AudioMs:  audioMs,
+	}
+	go func() {
+		if err := upsert(db, record); err != nil {
+			logrus.WithError(err).Warn("记录音频时长失败")
+		}
+	}()
+}
+
+// upsert 按 (device_id, day, kind, model) 插入或累加
+func upsert(db *gorm.DB, record models.UsageRecord) error {
+	table := record.TableName()
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "device_id"}, {Name: "day"}, {Name: "kind"}, {Name: "model"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":          gorm.Expr(table+".requests + ?", record.Requests),
+			"prompt_tokens":     gorm.Expr(table+".prompt_tokens + ?", record.PromptTokens),
+			"completion_tokens": gorm.Expr(table+".completion_tokens + ?", record.CompletionTokens),
+			"audio_ms":          gorm.Expr(table+".audio_ms + ?", record.AudioMs),
+		}),
+	}).Create(&record).Error
+}
+
+// Query 用量查询条件
+type Query struct {
+	DeviceID string // 为空表示所有设备
+	From     string // 起始日期（含），YYYY-MM-DD
+	To       string // 结束日期（含），YYYY-MM-DD
+	GroupBy  string // device、day 或 model
+}
+
+// Row 按维度汇总的用量
+type Row struct {
+	Key              string  `json:"key"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	AudioMs          int64   `json:"audio_ms"`
+	Cost             float64 `json:"cost"`
+}
+
+// Summarize 按条件汇总用量，按模型价格估算成本
+func Summarize(db *gorm.DB, q Query, pricing func(model string) (prompt, completion float64)) ([]Row, Row, error) {
+	var keyColumn string
+	switch q.GroupBy {
+	case "", "device":
+		keyColumn = "device_id"
+	case "day":
+		keyColumn = "day"
+	case "model":
+		keyColumn = "model"
+	default:
+		return nil, Row{}, fmt.Errorf("不支持的汇总维度: %s", q.GroupBy)
+	}
+
+	tx := db.Model(&models.UsageRecord{})
+	if q.DeviceID != "" {
+		tx = tx.Where("device_id = ?", q.DeviceID)
+	}
+	if q.From != "" {
+		tx = tx.Where("day >= ?", q.From)
+	}
+	if q.To != "" {
+		tx = tx.Where("day <= ?", q.To)
+	}
+	var records []models.UsageRecord
+	if err := tx.Order("day").Find(&records).Error; err != nil {
+		return nil, Row{}, fmt.Errorf("查询用量失败: %v", err)
+	}
+
+	// 成本按模型计算，先逐条计算再按维度汇总
+	rows := make([]Row, 0)
+	index := make(map[string]int)
+	total := Row{Key: "total"}
+	for _, r := range records {
+		var key string
+		switch keyColumn {
+		case "device_id":
+			key = r.DeviceID
+		case "day":
+			key = r.Day
+		default:
+			key = r.Model
+		}
+		promptPrice, completionPrice := pricing(r.Model)
+		cost := float64(r.PromptTokens)/1000*promptPrice + float64(r.CompletionTokens)/1000*completionPrice
+
+		i, ok := index[key]
+		if !ok {
+			i = len(rows)
+			index[key] = i
+			rows = append(rows, Row{Key: key})
+		}
+		for _, row := range []*Row{&rows[i], &total} {
+			row.Requests += r.Requests
+			row.PromptTokens += r.PromptTokens
+			row.CompletionTokens += r.CompletionTokens
+			row.AudioMs += r.AudioMs
+			row.Cost += cost
+		}
+	}
+	return rows, total, nil
+}
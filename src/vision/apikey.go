@@ -0,0 +1,102 @@
+package vision
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+// apiKeyEntry 单个API Key的配置与当日用量
+type apiKeyEntry struct {
+	name       string
+	key        string
+	dailyQuota int // 每日调用上限，<=0 表示不限
+	used       int
+	usedDate   string // 用量所属日期，跨天自动清零
+}
+
+// APIKeyManager 外部应用调用Vision接口的API Key及每日配额管理，与设备token相互独立。
+// 用量只记录在本进程内存中，服务重启后清零，多实例部署时每个实例分别计数，
+// 因此配额是近似的上限，用于防止滥用而不是精确计费；精确的调用量以 usage_records 为准
+type APIKeyManager struct {
+	mu      sync.Mutex
+	entries []*apiKeyEntry
+	now     func() time.Time
+}
+
+// NewAPIKeyManager 根据配置创建API Key管理器
+func NewAPIKeyManager(keys []configs.VisionAPIKeyConfig) *APIKeyManager {
+	m := &APIKeyManager{now: time.Now}
+	for _, k := range keys {
+		if k.Key == "" {
+			continue
+		}
+		m.entries = append(m.entries, &apiKeyEntry{
+			name:       k.Name,
+			key:        k.Key,
+			dailyQuota: k.DailyQuota,
+		})
+	}
+	return m
+}
+
+// Enabled 是否配置了API Key
+func (m *APIKeyManager) Enabled() bool {
+	return len(m.entries) > 0
+}
+
+// Consume 校验API Key并消耗一次配额，返回调用方名称和当日剩余次数（不限时为-1）
+func (m *APIKeyManager) Consume(key string) (name string, remaining int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.find(key)
+	if entry == nil {
+		return "", 0, errInvalidAPIKey
+	}
+
+	today := m.now().Format("2006-01-02")
+	if entry.usedDate != today {
+		entry.usedDate = today
+		entry.used = 0
+	}
+
+	if entry.dailyQuota <= 0 {
+		entry.used++
+		return entry.name, -1, nil
+	}
+	if entry.used >= entry.dailyQuota {
+		return entry.name, 0, errQuotaExceeded
+	}
+	entry.used++
+	return entry.name, entry.dailyQuota - entry.used, nil
+}
+
+// Refund 请求无效或模型调用失败时退还配额
+func (m *APIKeyManager) Refund(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry := m.find(key); entry != nil && entry.used > 0 {
+		entry.used--
+	}
+}
+
+// find 常量时间比较查找API Key
+func (m *APIKeyManager) find(key string) *apiKeyEntry {
+	var found *apiKeyEntry
+	for _, entry := range m.entries {
+		if subtle.ConstantTimeCompare([]byte(entry.key), []byte(key)) == 1 {
+			found = entry
+		}
+	}
+	return found
+}
+
+var (
+	errInvalidAPIKey = fmt.Errorf("无效的API Key")
+	errQuotaExceeded = fmt.Errorf("API Key今日调用次数已用完")
+)
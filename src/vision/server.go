@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,8 +16,11 @@ import (
 	"xiaozhi-server-go/src/configs"
 	"xiaozhi-server-go/src/core/auth"
 	"xiaozhi-server-go/src/core/image"
+	"xiaozhi-server-go/src/core/postprocess"
 	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/vlllm"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/usage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,9 +31,11 @@ const (
 )
 
 type DefaultVisionService struct {
-	config    *configs.Config
-	vlllmMap  map[string]*vlllm.Provider // 支持多个VLLLM provider
-	authToken *auth.AuthToken            // 认证工具
+	config      *configs.Config
+	vlllmMap    map[string]*vlllm.Provider // 支持多个VLLLM provider
+	authToken   *auth.AuthToken            // 认证工具
+	apiKeys     *APIKeyManager             // 外部应用API Key及配额
+	postProcess *postprocess.Chain         // VLLLM输出后处理链
 }
 
 // NewDefaultVisionService 构造函数
@@ -40,6 +46,13 @@ func NewDefaultVisionService(config *configs.Config) (*DefaultVisionService, err
 	}
 
 	service.authToken = auth.NewAuthToken(config.Server.Token)
+	service.apiKeys = NewAPIKeyManager(config.Web.VisionAPIKeys)
+
+	postProcess, err := postprocess.NewChain(config.PostProcess)
+	if err != nil {
+		return nil, fmt.Errorf("初始化后处理链失败: %v", err)
+	}
+	service.postProcess = postProcess
 
 	// 初始化VLLLM providers
 	if err := service.initVLLMProviders(); err != nil {
@@ -70,6 +83,8 @@ func (s *DefaultVisionService) initVLLMProviders() error {
 		MaxTokens:   vlllmConfig.MaxTokens,
 		TopP:        vlllmConfig.TopP,
 		Security:    vlllmConfig.Security,
+		Clip:        vlllmConfig.Clip,
+		Preprocess:  vlllmConfig.Preprocess,
 	}
 
 	// 创建provider实例
@@ -101,23 +116,14 @@ func (s *DefaultVisionService) Start(ctx context.Context, engine *gin.Engine, ap
 	// Vision 主接口（GET用于状态检查，POST用于图片分析）
 	apiGroup.GET("/vision", s.handleGet)
 	apiGroup.POST("/vision", s.handlePost)
-	apiGroup.OPTIONS("/vision", s.handleOptions)
 
 	logrus.Info("Vision HTTP服务路由注册完成")
 	return nil
 }
 
-// handleOptions 处理OPTIONS请求（CORS）
-func (s *DefaultVisionService) handleOptions(c *gin.Context) {
-	logrus.Info("收到Vision CORS预检请求 options")
-	s.addCORSHeaders(c)
-	c.Status(http.StatusOK)
-}
-
 // handleGet 处理GET请求（状态检查）
 func (s *DefaultVisionService) handleGet(c *gin.Context) {
 	logrus.Info("收到Vision状态检查请求 get")
-	s.addCORSHeaders(c)
 
 	// 检查Vision服务状态
 	var message string
@@ -132,10 +138,19 @@ func (s *DefaultVisionService) handleGet(c *gin.Context) {
 
 // handlePost 处理POST请求（图片分析）
 func (s *DefaultVisionService) handlePost(c *gin.Context) {
-	s.addCORSHeaders(c)
-
 	deviceID := c.GetHeader("Device-Id")
 
+	// 外部应用使用API Key认证，按每日配额计费
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+		name, ok := s.consumeAPIKey(c, apiKey)
+		if !ok {
+			return
+		}
+		deviceID = "apikey_" + name
+		s.handleVisionRequest(c, deviceID, func() { s.apiKeys.Refund(apiKey) })
+		return
+	}
+
 	// 验证认证
 	authResult, err := s.verifyAuth(c)
 	if err != nil {
@@ -150,9 +165,17 @@ func (s *DefaultVisionService) handlePost(c *gin.Context) {
 		return
 	}
 
+	s.handleVisionRequest(c, deviceID, nil)
+}
+
+// handleVisionRequest 认证通过后解析并处理图片分析请求，refund 在请求无效或分析失败时退还配额
+func (s *DefaultVisionService) handleVisionRequest(c *gin.Context, deviceID string, refund func()) {
 	// 解析multipart表单
 	req, err := s.parseMultipartRequest(c, deviceID)
 	if err != nil {
+		if refund != nil {
+			refund()
+		}
 		s.respondError(c, http.StatusBadRequest, err.Error())
 		logrus.Warn(fmt.Sprintf("Vision请求解析失败: %v", err))
 		return
@@ -176,6 +199,9 @@ func (s *DefaultVisionService) handlePost(c *gin.Context) {
 	}
 
 	if err != nil {
+		if refund != nil {
+			refund()
+		}
 		s.respondError(c, http.StatusInternalServerError, err.Error())
 		logrus.Warn(fmt.Sprintf("Vision请求处理失败: %v", err))
 		// 返回成功响应
@@ -191,6 +217,27 @@ func (s *DefaultVisionService) handlePost(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// consumeAPIKey 校验API Key并扣减当日配额，失败时直接写入错误响应
+func (s *DefaultVisionService) consumeAPIKey(c *gin.Context, apiKey string) (string, bool) {
+	name, remaining, err := s.apiKeys.Consume(apiKey)
+	switch err {
+	case nil:
+		if remaining >= 0 {
+			c.Header("X-Quota-Remaining", strconv.Itoa(remaining))
+		}
+		logrus.WithField("api_key_name", name).Debug("Vision API Key认证通过")
+		return name, true
+	case errQuotaExceeded:
+		c.Header("X-Quota-Remaining", "0")
+		s.respondError(c, http.StatusTooManyRequests, err.Error())
+		logrus.Warn(fmt.Sprintf("Vision API Key配额已用完: %s", name))
+	default:
+		s.respondError(c, http.StatusUnauthorized, err.Error())
+		logrus.Warn("Vision API Key认证失败")
+	}
+	return "", false
+}
+
 // verifyAuth 验证认证token
 func (s *DefaultVisionService) verifyAuth(c *gin.Context) (*AuthVerifyResult, error) {
 	// 获取Authorization头
@@ -278,7 +325,7 @@ func (s *DefaultVisionService) parseMultipartRequest(c *gin.Context, deviceID st
 
 	// 验证图片格式
 	if !s.isValidImageFile(imageData) {
-		return nil, fmt.Errorf("不支持的文件格式，请上传有效的图片文件（支持JPEG、PNG、GIF、BMP、TIFF、WEBP格式）")
+		return nil, fmt.Errorf("不支持的文件格式，请上传有效的图片文件（支持JPEG、PNG、GIF、BMP、TIFF、WEBP格式，启用抽帧后支持MP4短视频）")
 	}
 
 	// 将图片保存在本地
@@ -338,16 +385,23 @@ func (s *DefaultVisionService) processVisionRequest(req *VisionRequest) (string,
 	}).Debug("处理图片数据")
 	// 调用VLLLM provider
 	messages := []providers.Message{} // 空的历史消息
-	responseChan, err := provider.ResponseWithImage(context.Background(), "", messages, imageData, req.Question)
+	ctx := types.WithUsageReporter(context.Background(), func(u types.Usage) {
+		usage.Record(req.DeviceID, "VLLLM", u)
+	})
+	responseChan, errChan, err := provider.ResponseWithImage(ctx, "", messages, imageData, req.Question)
 	if err != nil {
 		return "", fmt.Errorf("调用VLLLM失败: %v", err)
 	}
 
 	// 收集所有响应内容
 	var result strings.Builder
-	for content := range responseChan {
+	for content := range s.postProcess.Wrap(responseChan) {
 		result.WriteString(content)
 	}
+	if err := <-errChan; err != nil {
+		// 中断的分析结果不完整，不返回给调用方
+		return "", fmt.Errorf("VLLLM分析中断: %v", err)
+	}
 	logrus.Info(fmt.Sprintf("VLLLM分析结果: %s", result.String()))
 
 	return result.String(), nil
@@ -381,7 +435,8 @@ func (s *DefaultVisionService) isValidImageFile(data []byte) bool {
 		s.hasPNGHeader(data) ||
 		s.hasGIFHeader(data) ||
 		s.hasBMPHeader(data) ||
-		s.hasWebPHeader(data)
+		s.hasWebPHeader(data) ||
+		image.DetectClipFormat(data, "") == image.ClipFormatMP4
 }
 
 // hasJPEGHeader 检查JPEG文件头
@@ -420,6 +475,10 @@ func (s *DefaultVisionService) detectImageFormat(data []byte) string {
 	if s.hasJPEGHeader(data) {
 		return "jpeg"
 	}
+	// 短视频由VLLLM抽帧后分析，MJPEG按jpeg保存，处理时再识别
+	if image.DetectClipFormat(data, "") == image.ClipFormatMP4 {
+		return image.ClipFormatMP4
+	}
 	if s.hasPNGHeader(data) {
 		return "png"
 	}
@@ -435,14 +494,6 @@ func (s *DefaultVisionService) detectImageFormat(data []byte) string {
 	return "jpeg" // 默认格式
 }
 
-// addCORSHeaders 添加CORS头
-func (s *DefaultVisionService) addCORSHeaders(c *gin.Context) {
-	c.Header("Access-Control-Allow-Headers", "client-id, content-type, device-id, authorization")
-	c.Header("Access-Control-Allow-Credentials", "true")
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-}
-
 // respondError 返回错误响应
 func (s *DefaultVisionService) respondError(c *gin.Context, statusCode int, message string) {
 	response := VisionResponse{
@@ -0,0 +1,151 @@
+package voiceprint
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/configs/database"
+	"xiaozhi-server-go/src/core/speaker"
+	"xiaozhi-server-go/src/models"
+	"xiaozhi-server-go/src/router/admin"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	maxAudioSize   = 10 << 20 // 登记录音的最大字节数
+	maxLabelLength = 32
+)
+
+// DefaultVoiceprintService 声纹登记服务，供管理员为用户登记和删除声纹
+type DefaultVoiceprintService struct {
+	config   *configs.Config
+	speakers *speaker.Service
+}
+
+// NewDefaultVoiceprintService 构造函数，speakers 为nil表示未启用声纹识别
+func NewDefaultVoiceprintService(config *configs.Config, speakers *speaker.Service) (*DefaultVoiceprintService, error) {
+	return &DefaultVoiceprintService{config: config, speakers: speakers}, nil
+}
+
+// Start 注册声纹登记路由，未启用声纹识别或未配置管理员令牌时不开放
+func (s *DefaultVoiceprintService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	if s.speakers == nil {
+		return nil
+	}
+	if !admin.Enabled(s.config) {
+		logrus.Info("未配置web.admin_token，声纹登记接口未开放")
+		return nil
+	}
+	group := admin.Group(apiGroup, s.config)
+	group.GET("/voiceprints", s.handleList)
+	group.POST("/voiceprints", s.handleEnroll)
+	group.DELETE("/voiceprints/:id", s.handleDelete)
+
+	logrus.Info("声纹登记HTTP服务路由注册完成")
+	return nil
+}
+
+// handleList 列出已登记的声纹，查询参数 user_id 为空时列出所有用户的声纹
+func (s *DefaultVoiceprintService) handleList(c *gin.Context) {
+	query := database.DB.Order("id")
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	var voiceprints []models.Voiceprint
+	if err := query.Find(&voiceprints).Error; err != nil {
+		s.respondError(c, http.StatusInternalServerError, "查询声纹失败: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "voiceprints": voiceprints})
+}
+
+// handleEnroll 为用户登记一段声纹
+// multipart 表单：user_id（用户ID）、label（备注，可选）、file（16位PCM编码的WAV录音，建议3~10秒）
+func (s *DefaultVoiceprintService) handleEnroll(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.PostForm("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		s.respondError(c, http.StatusBadRequest, "无效的用户ID")
+		return
+	}
+	label := strings.TrimSpace(c.PostForm("label"))
+	if utf8.RuneCountInString(label) > maxLabelLength {
+		s.respondError(c, http.StatusBadRequest, "备注过长")
+		return
+	}
+	var user models.User
+	if err := database.DB.Select("id").Where("id = ?", userID).Take(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.respondError(c, http.StatusNotFound, "用户不存在")
+			return
+		}
+		s.respondError(c, http.StatusInternalServerError, "查询用户失败: "+err.Error())
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, "缺少录音文件 file")
+		return
+	}
+	if file.Size > maxAudioSize {
+		s.respondError(c, http.StatusRequestEntityTooLarge, "录音文件过大")
+		return
+	}
+	reader, err := file.Open()
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, "读取录音文件失败: "+err.Error())
+		return
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxAudioSize))
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, "读取录音文件失败: "+err.Error())
+		return
+	}
+	samples, err := speaker.DecodeWAV(data)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	voiceprint, err := s.speakers.Enroll(c.Request.Context(), userID, label, samples)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	logrus.WithField("user_id", userID).Info("已登记声纹")
+	c.JSON(http.StatusOK, gin.H{"success": true, "voiceprint": voiceprint})
+}
+
+// handleDelete 删除一段声纹
+func (s *DefaultVoiceprintService) handleDelete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		s.respondError(c, http.StatusBadRequest, "无效的声纹ID")
+		return
+	}
+	deleted, err := s.speakers.Delete(id)
+	if err != nil {
+		s.respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !deleted {
+		s.respondError(c, http.StatusNotFound, "声纹不存在")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// respondError 返回错误响应
+func (s *DefaultVoiceprintService) respondError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{"success": false, "message": message})
+}